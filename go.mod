@@ -4,6 +4,7 @@ go 1.24
 
 require (
 	github.com/refraction-networking/utls v1.8.2
+	golang.org/x/crypto v0.36.0
 	golang.org/x/net v0.38.0
 	modernc.org/sqlite v1.34.5
 )
@@ -16,7 +17,6 @@ require (
 	github.com/mattn/go-isatty v0.0.20 // indirect
 	github.com/ncruces/go-strftime v0.1.9 // indirect
 	github.com/remyoudompheng/bigfft v0.0.0-20230129092748-24d4a6f8daec // indirect
-	golang.org/x/crypto v0.36.0 // indirect
 	golang.org/x/sys v0.31.0 // indirect
 	golang.org/x/text v0.23.0 // indirect
 	modernc.org/libc v1.55.3 // indirect
@@ -14,18 +14,48 @@ import (
 const (
 	adminSessionCookieName = "api_monitor_admin_session"
 
-	settingProxyMasterToken   = "proxy_master_token"
-	settingDefaultIntervalMin = "default_interval_min"
-	settingLogCleanupEnabled  = "log_cleanup_enabled"
-	settingLogMaxSizeMB       = "log_max_size_mb"
-	settingVisitorModeEnabled = "visitor_mode_enabled"
+	settingProxyMasterToken    = "proxy_master_token"
+	settingDefaultIntervalMin  = "default_interval_min"
+	settingLogCleanupEnabled   = "log_cleanup_enabled"
+	settingLogMaxSizeMB        = "log_max_size_mb"
+	settingLogMaxAgeDays       = "log_max_age_days"
+	settingVisitorModeEnabled  = "visitor_mode_enabled"
+	settingCertExpiryWarnDays  = "cert_expiry_warn_days"
+	settingMonitorPaused       = "monitor_paused"
+	settingLatencyAnomalySigma = "latency_anomaly_sigma"
+	settingLatencyAnomalyPct   = "latency_anomaly_pct"
+	settingNotifyFlapThreshold = "notify_flap_threshold"
+	settingOIDCIssuer          = "oidc_issuer"
+	settingOIDCClientID        = "oidc_client_id"
+	settingOIDCClientSecret    = "oidc_client_secret"
+	settingOIDCRedirectURL     = "oidc_redirect_url"
+	settingOIDCScopes          = "oidc_scopes"
+	settingOIDCGroupsClaim     = "oidc_groups_claim"
+	settingOIDCAdminGroups     = "oidc_admin_groups"
+	settingOIDCVisitorGroups   = "oidc_visitor_groups"
+	settingAdminIPAllowlist    = "admin_ip_allowlist"
+	settingWriteIPAllowlist    = "write_ip_allowlist"
+	settingTrustedProxies      = "trusted_proxies"
+	settingAPIKeyRedaction     = "api_key_redaction"
+	settingDetectConcurrency   = "detect_concurrency"
+	settingMaxParallelTargets  = "max_parallel_targets"
+	settingMaxRunDurationS     = "max_run_duration_s"
+	settingRunArchiveDays      = "run_archive_days"
 )
 
+// adminSession is one logged-in browser. Password logins are always admin; OIDC
+// logins carry the role mapped from the identity provider's claims.
+type adminSession struct {
+	expireAt time.Time
+	role     authRole
+	method   string
+}
+
 type AdminSessionManager struct {
 	password string
 	ttl      time.Duration
 	mu       sync.Mutex
-	sessions map[string]time.Time
+	sessions map[string]adminSession
 }
 
 func NewAdminSessionManager(password string, ttl time.Duration) *AdminSessionManager {
@@ -35,7 +65,7 @@ func NewAdminSessionManager(password string, ttl time.Duration) *AdminSessionMan
 	return &AdminSessionManager{
 		password: strings.TrimSpace(password),
 		ttl:      ttl,
-		sessions: make(map[string]time.Time),
+		sessions: make(map[string]adminSession),
 	}
 }
 
@@ -60,33 +90,47 @@ func (m *AdminSessionManager) Login(password string) (string, bool) {
 	if len(passA) != len(passB) || subtle.ConstantTimeCompare(passA, passB) != 1 {
 		return "", false
 	}
-	token, err := m.createToken()
+	token, err := m.CreateSession(authRoleAdmin, "session")
 	if err != nil {
 		return "", false
 	}
-	expireAt := time.Now().Add(m.ttl)
+	return token, true
+}
+
+// CreateSession starts a session for a login that was verified elsewhere (OIDC).
+func (m *AdminSessionManager) CreateSession(role authRole, method string) (string, error) {
+	token, err := m.createToken()
+	if err != nil {
+		return "", err
+	}
 	m.mu.Lock()
-	m.sessions[token] = expireAt
+	m.sessions[token] = adminSession{expireAt: time.Now().Add(m.ttl), role: role, method: method}
 	m.mu.Unlock()
-	return token, true
+	return token, nil
 }
 
+// Validate reports whether token is a live admin session.
 func (m *AdminSessionManager) Validate(token string) bool {
+	s, ok := m.lookup(token)
+	return ok && s.role == authRoleAdmin
+}
+
+func (m *AdminSessionManager) lookup(token string) (adminSession, bool) {
 	if token == "" || !m.Enabled() {
-		return false
+		return adminSession{}, false
 	}
 	now := time.Now()
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	expireAt, ok := m.sessions[token]
+	s, ok := m.sessions[token]
 	if !ok {
-		return false
+		return adminSession{}, false
 	}
-	if now.After(expireAt) {
+	if now.After(s.expireAt) {
 		delete(m.sessions, token)
-		return false
+		return adminSession{}, false
 	}
-	return true
+	return s, true
 }
 
 func (m *AdminSessionManager) Logout(token string) {
@@ -98,21 +142,18 @@ func (m *AdminSessionManager) Logout(token string) {
 	m.mu.Unlock()
 }
 
-// UpdatePassword replaces admin password and keeps only the current session token (if any).
+// UpdatePassword replaces admin password and ends every password session except
+// keepToken (if any). OIDC sessions do not depend on the password and are kept.
 func (m *AdminSessionManager) UpdatePassword(password, keepToken string) {
 	password = strings.TrimSpace(password)
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	m.password = password
 
-	if keepToken == "" {
-		m.sessions = make(map[string]time.Time)
-		return
-	}
-	expireAt, ok := m.sessions[keepToken]
-	m.sessions = make(map[string]time.Time)
-	if ok {
-		m.sessions[keepToken] = expireAt
+	for token, s := range m.sessions {
+		if token != keepToken && s.method == "session" {
+			delete(m.sessions, token)
+		}
 	}
 }
 
@@ -140,7 +181,7 @@ func setAdminSessionCookie(w http.ResponseWriter, token string, ttl time.Duratio
 		Path:     "/",
 		HttpOnly: true,
 		SameSite: http.SameSiteLaxMode,
-		Secure:   false,
+		Secure:   secureCookies.Load(),
 		MaxAge:   int(ttl.Seconds()),
 	})
 }
@@ -152,41 +193,11 @@ func clearAdminSessionCookie(w http.ResponseWriter) {
 		Path:     "/",
 		HttpOnly: true,
 		SameSite: http.SameSiteLaxMode,
-		Secure:   false,
+		Secure:   secureCookies.Load(),
 		MaxAge:   -1,
 	})
 }
 
-func adminPageMiddleware(admin *AdminSessionManager, next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if admin == nil || !admin.Enabled() {
-			http.Error(w, "admin panel is disabled: set API_MONITOR_TOKEN_ADMIN", http.StatusServiceUnavailable)
-			return
-		}
-		token := adminSessionTokenFromRequest(r)
-		if !admin.Validate(token) {
-			http.Redirect(w, r, "/admin/login", http.StatusFound)
-			return
-		}
-		next.ServeHTTP(w, r)
-	})
-}
-
-func adminAPIMiddleware(admin *AdminSessionManager, next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if admin == nil || !admin.Enabled() {
-			writeJSON(w, http.StatusServiceUnavailable, map[string]any{"detail": "admin panel is disabled"})
-			return
-		}
-		token := adminSessionTokenFromRequest(r)
-		if !admin.Validate(token) {
-			writeJSON(w, http.StatusUnauthorized, map[string]any{"detail": "admin login required"})
-			return
-		}
-		next.ServeHTTP(w, r)
-	})
-}
-
 func parseBoolString(v string, def bool) bool {
 	s := strings.TrimSpace(strings.ToLower(v))
 	if s == "" {
@@ -221,18 +232,71 @@ type adminSettingsPatchRequest struct {
 	ProxyMasterToken       *string `json:"proxy_master_token"`
 	LogCleanupEnabled      *bool   `json:"log_cleanup_enabled"`
 	LogMaxSizeMB           *int    `json:"log_max_size_mb"`
+	LogMaxAgeDays          *int    `json:"log_max_age_days"`
+	CertExpiryWarnDays     *int    `json:"cert_expiry_warn_days"`
+	LatencyAnomalySigma    *int    `json:"latency_anomaly_sigma"`
+	LatencyAnomalyPct      *int    `json:"latency_anomaly_pct"`
+	NotifyFlapThreshold    *int    `json:"notify_flap_threshold"`
+	DetectConcurrency      *int    `json:"detect_concurrency"`
+	MaxParallelTargets     *int    `json:"max_parallel_targets"`
+	MaxRunDurationS        *int    `json:"max_run_duration_s"`
+	RunArchiveDays         *int    `json:"run_archive_days"`
+	VisitorRateLimitPerMin *int    `json:"visitor_rate_limit_per_min"`
+	VisitorRateLimitBurst  *int    `json:"visitor_rate_limit_burst"`
+	OIDCIssuer             *string `json:"oidc_issuer"`
+	OIDCClientID           *string `json:"oidc_client_id"`
+	OIDCClientSecret       *string `json:"oidc_client_secret"`
+	OIDCRedirectURL        *string `json:"oidc_redirect_url"`
+	OIDCScopes             *string `json:"oidc_scopes"`
+	OIDCGroupsClaim        *string `json:"oidc_groups_claim"`
+	OIDCAdminGroups        *string `json:"oidc_admin_groups"`
+	OIDCVisitorGroups      *string `json:"oidc_visitor_groups"`
+	S3Endpoint             *string `json:"s3_endpoint"`
+	S3Region               *string `json:"s3_region"`
+	S3Bucket               *string `json:"s3_bucket"`
+	S3AccessKeyID          *string `json:"s3_access_key_id"`
+	S3SecretAccessKey      *string `json:"s3_secret_access_key"`
+	S3Prefix               *string `json:"s3_prefix"`
+	S3DeleteLocal          *bool   `json:"s3_delete_local"`
+	PagerDutyRoutingKey    *string `json:"pagerduty_routing_key"`
+	AdminIPAllowlist       *string `json:"admin_ip_allowlist"`
+	WriteIPAllowlist       *string `json:"write_ip_allowlist"`
+	TrustedProxies         *string `json:"trusted_proxies"`
+	APIKeyRedaction        *string `json:"api_key_redaction"`
 }
 
 type adminChannelAdvancedPatchRequest struct {
-	VerifySSL                    *bool   `json:"verify_ssl"`
-	Prompt                       *string `json:"prompt"`
-	AnthropicVersion             *string `json:"anthropic_version"`
-	MaxModels                    *int    `json:"max_models"`
-	VisitorChannelActionsEnabled *bool   `json:"visitor_channel_actions_enabled"`
+	VerifySSL                    *bool              `json:"verify_ssl"`
+	Prompt                       *string            `json:"prompt"`
+	AnthropicVersion             *string            `json:"anthropic_version"`
+	MaxModels                    *int               `json:"max_models"`
+	VisitorChannelActionsEnabled *bool              `json:"visitor_channel_actions_enabled"`
+	ExtraHeaders                 *map[string]string `json:"extra_headers"`
+	ProxyURL                     *string            `json:"proxy_url"`
+	TLSFingerprint               *string            `json:"tls_fingerprint"`
+	ModelOverrides               *map[string]any    `json:"model_overrides"`
+	ProbeEndpoints               *[]any             `json:"probe_endpoints"`
+	StreamProbe                  *bool              `json:"stream_probe"`
+	ToolProbe                    *bool              `json:"tool_probe"`
+	CapabilityProbes             *[]any             `json:"capability_probes"`
+	FastRetryMin                 *int               `json:"fast_retry_min"`
+	DetectConcurrency            *int               `json:"detect_concurrency"`
+	MaxRunDurationS              *int               `json:"max_run_duration_s"`
+	DebugCapture                 *bool              `json:"debug_capture"`
+	LogMaxAgeDays                *int               `json:"log_max_age_days"`
+	BalanceProbe                 *string            `json:"balance_probe"`
+	BalanceAlertBelow            *float64           `json:"balance_alert_below"`
+	Tags                         *[]any             `json:"tags"`
+	Severity                     *string            `json:"severity"`
+	ProxyTransform               *map[string]any    `json:"proxy_transform"`
+	// AgentID assigns the channel to a probe agent; 0 returns it to the central scheduler.
+	AgentID *int `json:"agent_id"`
 }
 
 type adminChannelModelsPatchRequest struct {
-	SelectedModels []string `json:"selected_models"`
+	SelectedModels  []string  `json:"selected_models"`
+	IncludePatterns *[]string `json:"include_patterns"`
+	ExcludePatterns *[]string `json:"exclude_patterns"`
 }
 
 func adminChannelItem(t *Target) map[string]any {
@@ -252,6 +316,30 @@ func adminChannelItem(t *Target) map[string]any {
 		"max_models":                      t.MaxModels,
 		"visitor_channel_actions_enabled": t.VisitorChannelActionsEnabled,
 		"selected_models":                 t.SelectedModels,
+		"extra_headers":                   t.ExtraHeaders,
+		"proxy_url":                       t.ProxyURL,
+		"tls_fingerprint":                 t.TLSFingerprint,
+		"model_overrides":                 t.ModelOverrides,
+		"include_patterns":                t.IncludePatterns,
+		"exclude_patterns":                t.ExcludePatterns,
+		"probe_endpoints":                 t.ProbeEndpoints,
+		"stream_probe":                    t.StreamProbe,
+		"tool_probe":                      t.ToolProbe,
+		"capability_probes":               t.CapabilityProbes,
+		"fast_retry_min":                  t.FastRetryMin,
+		"detect_concurrency":              t.DetectConcurrency,
+		"max_run_duration_s":              t.MaxRunDurationS,
+		"debug_capture":                   t.DebugCapture,
+		"log_max_age_days":                t.LogMaxAgeDays,
+		"balance_probe":                   t.BalanceProbe,
+		"balance_alert_below":             t.BalanceAlertBelow,
+		"balance_remaining":               t.BalanceRemaining,
+		"balance_checked_at":              t.BalanceCheckedAt,
+		"balance_error":                   t.BalanceError,
+		"tags":                            t.Tags,
+		"severity":                        t.Severity,
+		"proxy_transform":                 t.ProxyTransform,
+		"agent_id":                        t.AgentID,
 		"source_url":                      t.SourceURL,
 		"updated_at":                      t.UpdatedAt,
 	}
@@ -262,24 +350,98 @@ func (h *Handlers) loadAdminSettings() (map[string]any, error) {
 		settingProxyMasterToken,
 		settingLogCleanupEnabled,
 		settingLogMaxSizeMB,
+		settingAdminIPAllowlist,
+		settingWriteIPAllowlist,
+		settingTrustedProxies,
 	})
 	if err != nil {
 		return nil, err
 	}
 
 	cleanupEnabled, cleanupMaxMB := h.monitor.LogCleanupConfig()
+	anomalySigma, anomalyPct := h.monitor.LatencyAnomalyConfig()
+	detectConcurrency, maxParallel := h.monitor.Concurrency()
 	proxyMasterToken := strings.TrimSpace(settings[settingProxyMasterToken])
+	ratePerMin, rateBurst := getVisitorRateLimit()
+	oidc, err := h.db.GetSettings(oidcSettingKeys)
+	if err != nil {
+		return nil, err
+	}
+	s3, err := h.db.GetSettings(s3SettingKeys)
+	if err != nil {
+		return nil, err
+	}
 
 	return map[string]any{
-		"api_monitor_token_admin":   getAdminAuthToken(),
-		"api_monitor_token_visitor": getVisitorAuthToken(),
-		"visitor_mode_enabled":      isVisitorModeEnabled(),
-		"proxy_master_token":        proxyMasterToken,
-		"log_cleanup_enabled":       cleanupEnabled,
-		"log_max_size_mb":           cleanupMaxMB,
+		"api_monitor_token_admin":    getAdminAuthToken(),
+		"api_monitor_token_visitor":  getVisitorAuthToken(),
+		"visitor_mode_enabled":       isVisitorModeEnabled(),
+		"visitor_rate_limit_per_min": ratePerMin,
+		"visitor_rate_limit_burst":   rateBurst,
+		"proxy_master_token":         proxyMasterToken,
+		"log_cleanup_enabled":        cleanupEnabled,
+		"log_max_size_mb":            cleanupMaxMB,
+		"log_max_age_days":           h.monitor.LogMaxAgeDays(),
+		"cert_expiry_warn_days":      h.monitor.CertExpiryWarnDays(),
+		"monitor_paused":             h.monitor.SchedulerPaused(),
+		"latency_anomaly_sigma":      anomalySigma,
+		"latency_anomaly_pct":        anomalyPct,
+		"notify_flap_threshold":      h.monitor.NotifyFlapThreshold(),
+		"detect_concurrency":         detectConcurrency,
+		"max_parallel_targets":       maxParallel,
+		"max_run_duration_s":         int(h.monitor.MaxRunDuration() / time.Second),
+		"run_archive_days":           h.monitor.RunArchiveDays(),
+		"oidc_issuer":                oidc[settingOIDCIssuer],
+		"oidc_client_id":             oidc[settingOIDCClientID],
+		"oidc_client_secret":         oidc[settingOIDCClientSecret],
+		"oidc_redirect_url":          oidc[settingOIDCRedirectURL],
+		"oidc_scopes":                oidc[settingOIDCScopes],
+		"oidc_groups_claim":          oidc[settingOIDCGroupsClaim],
+		"oidc_admin_groups":          oidc[settingOIDCAdminGroups],
+		"oidc_visitor_groups":        oidc[settingOIDCVisitorGroups],
+		"s3_endpoint":                s3[settingS3Endpoint],
+		"s3_region":                  s3[settingS3Region],
+		"s3_bucket":                  s3[settingS3Bucket],
+		"s3_access_key_id":           s3[settingS3AccessKeyID],
+		"s3_secret_access_key":       s3[settingS3SecretAccessKey],
+		"pagerduty_routing_key":      getPagerDutyRoutingKey(),
+		"s3_prefix":                  s3[settingS3Prefix],
+		"s3_delete_local":            parseBoolString(s3[settingS3DeleteLocal], false),
+		"admin_ip_allowlist":         settings[settingAdminIPAllowlist],
+		"write_ip_allowlist":         settings[settingWriteIPAllowlist],
+		"trusted_proxies":            settings[settingTrustedProxies],
+		"api_key_redaction":          getAPIKeyRedaction(),
 	}, nil
 }
 
+// AdminPauseMonitor handles POST /api/admin/monitor/pause
+func (h *Handlers) AdminPauseMonitor(w http.ResponseWriter, r *http.Request) {
+	h.setMonitorPaused(w, r, true)
+}
+
+// AdminResumeMonitor handles POST /api/admin/monitor/resume
+func (h *Handlers) AdminResumeMonitor(w http.ResponseWriter, r *http.Request) {
+	h.setMonitorPaused(w, r, false)
+}
+
+func (h *Handlers) setMonitorPaused(w http.ResponseWriter, r *http.Request, paused bool) {
+	before := h.monitor.SchedulerPaused()
+	if err := h.db.SetSetting(settingMonitorPaused, strconv.FormatBool(paused)); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return
+	}
+	h.monitor.SetSchedulerPaused(paused)
+	action := "monitor.resume"
+	if paused {
+		action = "monitor.pause"
+	}
+	h.audit(r, action, "settings", 0, map[string]any{"monitor_paused": map[string]any{"from": before, "to": paused}})
+	writeJSON(w, http.StatusOK, map[string]any{
+		"ok":   true,
+		"item": map[string]any{"paused": paused, "running_target_ids": h.monitor.RunningTargetIDs()},
+	})
+}
+
 // AdminLogin handles POST /api/admin/login
 func (h *Handlers) AdminLogin(w http.ResponseWriter, r *http.Request) {
 	if h.admin == nil || !h.admin.Enabled() {
@@ -293,7 +455,7 @@ func (h *Handlers) AdminLogin(w http.ResponseWriter, r *http.Request) {
 	}
 	var req adminLoginRequest
 	if err := readJSON(r, &req); err != nil {
-		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "invalid JSON"})
+		writeRequestBodyError(w, err)
 		return
 	}
 	token, ok := h.admin.Login(strings.TrimSpace(req.Password))
@@ -330,7 +492,12 @@ func (h *Handlers) AdminGetSettings(w http.ResponseWriter, r *http.Request) {
 func (h *Handlers) AdminPatchSettings(w http.ResponseWriter, r *http.Request) {
 	var req adminSettingsPatchRequest
 	if err := readJSON(r, &req); err != nil {
-		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "invalid JSON"})
+		writeRequestBodyError(w, err)
+		return
+	}
+	before, err := h.loadAdminSettings()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
 		return
 	}
 
@@ -405,11 +572,256 @@ func (h *Handlers) AdminPatchSettings(w http.ResponseWriter, r *http.Request) {
 
 	h.monitor.UpdateLogCleanupConfig(cleanupEnabled, cleanupMaxMB)
 
+	if req.LogMaxAgeDays != nil {
+		if *req.LogMaxAgeDays < 0 || *req.LogMaxAgeDays > maxLogAgeDays {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "log_max_age_days must be 0-3650"})
+			return
+		}
+		if err := h.db.SetSetting(settingLogMaxAgeDays, strconv.Itoa(*req.LogMaxAgeDays)); err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+			return
+		}
+		h.monitor.UpdateLogMaxAgeDays(*req.LogMaxAgeDays)
+	}
+
+	if req.CertExpiryWarnDays != nil {
+		if *req.CertExpiryWarnDays < 0 || *req.CertExpiryWarnDays > 365 {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "cert_expiry_warn_days must be 0-365"})
+			return
+		}
+		if err := h.db.SetSetting(settingCertExpiryWarnDays, strconv.Itoa(*req.CertExpiryWarnDays)); err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+			return
+		}
+		h.monitor.UpdateCertExpiryWarnDays(*req.CertExpiryWarnDays)
+	}
+
+	if req.LatencyAnomalySigma != nil || req.LatencyAnomalyPct != nil {
+		sigma, pct := h.monitor.LatencyAnomalyConfig()
+		if req.LatencyAnomalySigma != nil {
+			if *req.LatencyAnomalySigma < 0 || *req.LatencyAnomalySigma > 10 {
+				writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "latency_anomaly_sigma must be 0-10"})
+				return
+			}
+			sigma = *req.LatencyAnomalySigma
+		}
+		if req.LatencyAnomalyPct != nil {
+			if *req.LatencyAnomalyPct < 0 || *req.LatencyAnomalyPct > 1000 {
+				writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "latency_anomaly_pct must be 0-1000"})
+				return
+			}
+			pct = *req.LatencyAnomalyPct
+		}
+		if err := h.db.SetSetting(settingLatencyAnomalySigma, strconv.Itoa(sigma)); err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+			return
+		}
+		if err := h.db.SetSetting(settingLatencyAnomalyPct, strconv.Itoa(pct)); err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+			return
+		}
+		h.monitor.UpdateLatencyAnomalyConfig(sigma, pct)
+	}
+
+	if req.NotifyFlapThreshold != nil {
+		if *req.NotifyFlapThreshold < 0 || *req.NotifyFlapThreshold > 1000 {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "notify_flap_threshold must be 0-1000"})
+			return
+		}
+		if err := h.db.SetSetting(settingNotifyFlapThreshold, strconv.Itoa(*req.NotifyFlapThreshold)); err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+			return
+		}
+		h.monitor.UpdateNotifyFlapThreshold(*req.NotifyFlapThreshold)
+	}
+
+	if req.DetectConcurrency != nil || req.MaxParallelTargets != nil {
+		detect, parallel := h.monitor.Concurrency()
+		if req.DetectConcurrency != nil {
+			if *req.DetectConcurrency < 1 || *req.DetectConcurrency > maxDetectConcurrency {
+				writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "detect_concurrency must be 1-64"})
+				return
+			}
+			detect = *req.DetectConcurrency
+		}
+		if req.MaxParallelTargets != nil {
+			if *req.MaxParallelTargets < 1 || *req.MaxParallelTargets > maxParallelTargets {
+				writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "max_parallel_targets must be 1-64"})
+				return
+			}
+			parallel = *req.MaxParallelTargets
+		}
+		if err := h.db.SetSetting(settingDetectConcurrency, strconv.Itoa(detect)); err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+			return
+		}
+		if err := h.db.SetSetting(settingMaxParallelTargets, strconv.Itoa(parallel)); err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+			return
+		}
+		h.monitor.UpdateConcurrency(detect, parallel)
+	}
+
+	if req.MaxRunDurationS != nil {
+		if *req.MaxRunDurationS < 0 || *req.MaxRunDurationS > maxRunDurationS {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "max_run_duration_s must be 0-86400"})
+			return
+		}
+		if err := h.db.SetSetting(settingMaxRunDurationS, strconv.Itoa(*req.MaxRunDurationS)); err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+			return
+		}
+		h.monitor.UpdateMaxRunDuration(time.Duration(*req.MaxRunDurationS) * time.Second)
+	}
+
+	if req.RunArchiveDays != nil {
+		if *req.RunArchiveDays < 0 || *req.RunArchiveDays > maxRunArchiveDays {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "run_archive_days must be 0-3650"})
+			return
+		}
+		if err := h.db.SetSetting(settingRunArchiveDays, strconv.Itoa(*req.RunArchiveDays)); err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+			return
+		}
+		h.monitor.UpdateRunArchiveDays(*req.RunArchiveDays)
+	}
+
+	if req.VisitorRateLimitPerMin != nil || req.VisitorRateLimitBurst != nil {
+		perMin, burst := getVisitorRateLimit()
+		if req.VisitorRateLimitPerMin != nil {
+			perMin = *req.VisitorRateLimitPerMin
+		}
+		if req.VisitorRateLimitBurst != nil {
+			burst = *req.VisitorRateLimitBurst
+		}
+		if perMin < 0 || perMin > maxVisitorRateLimit {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "visitor_rate_limit_per_min must be 0-100000"})
+			return
+		}
+		if burst < 0 || burst > maxVisitorRateLimit {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "visitor_rate_limit_burst must be 0-100000"})
+			return
+		}
+		if err := h.db.SetSetting(settingVisitorRateLimitPerMin, strconv.Itoa(perMin)); err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+			return
+		}
+		if err := h.db.SetSetting(settingVisitorRateLimitBurst, strconv.Itoa(burst)); err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+			return
+		}
+		setVisitorRateLimit(perMin, burst)
+	}
+
+	oidcPatch := []struct {
+		key   string
+		value *string
+	}{
+		{settingOIDCIssuer, req.OIDCIssuer},
+		{settingOIDCClientID, req.OIDCClientID},
+		{settingOIDCClientSecret, req.OIDCClientSecret},
+		{settingOIDCRedirectURL, req.OIDCRedirectURL},
+		{settingOIDCScopes, req.OIDCScopes},
+		{settingOIDCGroupsClaim, req.OIDCGroupsClaim},
+		{settingOIDCAdminGroups, req.OIDCAdminGroups},
+		{settingOIDCVisitorGroups, req.OIDCVisitorGroups},
+	}
+	for _, p := range oidcPatch {
+		if p.value == nil {
+			continue
+		}
+		value := strings.TrimSpace(*p.value)
+		if detail := validateOIDCSetting(p.key, value); detail != "" {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"detail": detail})
+			return
+		}
+	}
+	for _, p := range oidcPatch {
+		if p.value == nil {
+			continue
+		}
+		if err := h.db.SetSetting(p.key, strings.TrimSpace(*p.value)); err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+			return
+		}
+	}
+
+	var s3DeleteLocal *string
+	if req.S3DeleteLocal != nil {
+		s3DeleteLocal = ptrTo(strconv.FormatBool(*req.S3DeleteLocal))
+	}
+	s3Patch := []struct {
+		key   string
+		value *string
+	}{
+		{settingS3Endpoint, req.S3Endpoint},
+		{settingS3Region, req.S3Region},
+		{settingS3Bucket, req.S3Bucket},
+		{settingS3AccessKeyID, req.S3AccessKeyID},
+		{settingS3SecretAccessKey, req.S3SecretAccessKey},
+		{settingS3Prefix, req.S3Prefix},
+		{settingS3DeleteLocal, s3DeleteLocal},
+	}
+	for _, p := range s3Patch {
+		if p.value == nil {
+			continue
+		}
+		if detail := validateS3Setting(p.key, strings.TrimSpace(*p.value)); detail != "" {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"detail": detail})
+			return
+		}
+	}
+	for _, p := range s3Patch {
+		if p.value == nil {
+			continue
+		}
+		if err := h.db.SetSetting(p.key, strings.TrimSpace(*p.value)); err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+			return
+		}
+	}
+
+	if req.PagerDutyRoutingKey != nil {
+		key := strings.TrimSpace(*req.PagerDutyRoutingKey)
+		if detail := validatePagerDutyRoutingKey("pagerduty_routing_key", key); detail != "" {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"detail": detail})
+			return
+		}
+		if err := h.db.SetSetting(settingPagerDutyRoutingKey, key); err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+			return
+		}
+		setPagerDutyRoutingKey(key)
+	}
+
+	if req.AdminIPAllowlist != nil || req.WriteIPAllowlist != nil || req.TrustedProxies != nil {
+		if status, detail := h.applyNetworkPolicyPatch(r, req, before); status != 0 {
+			writeJSON(w, status, map[string]any{"detail": detail})
+			return
+		}
+	}
+
+	if req.APIKeyRedaction != nil {
+		mode, err := normalizeAPIKeyRedaction(*req.APIKeyRedaction)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"detail": err.Error()})
+			return
+		}
+		if err := h.db.SetSetting(settingAPIKeyRedaction, mode); err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+			return
+		}
+		_ = setAPIKeyRedaction(mode)
+	}
+
 	item, err := h.loadAdminSettings()
 	if err != nil {
 		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
 		return
 	}
+	if diff := auditDiff(before, item, nil, auditSettingsSecretFields); len(diff) > 0 {
+		h.audit(r, "settings.update", "settings", 0, diff)
+	}
 	writeJSON(w, http.StatusOK, map[string]any{"ok": true, "item": item})
 }
 
@@ -448,7 +860,7 @@ func (h *Handlers) AdminPatchChannelAdvanced(w http.ResponseWriter, r *http.Requ
 
 	var req adminChannelAdvancedPatchRequest
 	if err := readJSON(r, &req); err != nil {
-		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "invalid JSON"})
+		writeRequestBodyError(w, err)
 		return
 	}
 
@@ -468,15 +880,89 @@ func (h *Handlers) AdminPatchChannelAdvanced(w http.ResponseWriter, r *http.Requ
 	if req.VisitorChannelActionsEnabled != nil {
 		updates["visitor_channel_actions_enabled"] = *req.VisitorChannelActionsEnabled
 	}
-	if len(updates) == 0 {
+	if req.ExtraHeaders != nil {
+		updates["extra_headers"] = *req.ExtraHeaders
+	}
+	if req.ProxyURL != nil {
+		updates["proxy_url"] = strings.TrimSpace(*req.ProxyURL)
+	}
+	if req.TLSFingerprint != nil {
+		updates["tls_fingerprint"] = strings.TrimSpace(*req.TLSFingerprint)
+	}
+	if req.ModelOverrides != nil {
+		updates["model_overrides"] = *req.ModelOverrides
+	}
+	if req.ProbeEndpoints != nil {
+		updates["probe_endpoints"] = *req.ProbeEndpoints
+	}
+	if req.StreamProbe != nil {
+		updates["stream_probe"] = *req.StreamProbe
+	}
+	if req.ToolProbe != nil {
+		updates["tool_probe"] = *req.ToolProbe
+	}
+	if req.CapabilityProbes != nil {
+		updates["capability_probes"] = *req.CapabilityProbes
+	}
+	if req.FastRetryMin != nil {
+		updates["fast_retry_min"] = *req.FastRetryMin
+	}
+	if req.DetectConcurrency != nil {
+		updates["detect_concurrency"] = *req.DetectConcurrency
+	}
+	if req.MaxRunDurationS != nil {
+		updates["max_run_duration_s"] = *req.MaxRunDurationS
+	}
+	if req.DebugCapture != nil {
+		updates["debug_capture"] = *req.DebugCapture
+	}
+	if req.LogMaxAgeDays != nil {
+		updates["log_max_age_days"] = *req.LogMaxAgeDays
+	}
+	if req.BalanceProbe != nil {
+		updates["balance_probe"] = *req.BalanceProbe
+	}
+	if req.BalanceAlertBelow != nil {
+		updates["balance_alert_below"] = *req.BalanceAlertBelow
+	}
+	if req.Tags != nil {
+		updates["tags"] = *req.Tags
+	}
+	if req.Severity != nil {
+		updates["severity"] = *req.Severity
+	}
+	if req.ProxyTransform != nil {
+		updates["proxy_transform"] = *req.ProxyTransform
+	}
+	if len(updates) == 0 && req.AgentID == nil {
 		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "no advanced fields provided"})
 		return
 	}
 	if err := validateTargetPayload(updates); err != nil {
-		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": err.Error()})
+		writeValidationError(w, err)
 		return
 	}
 
+	if req.AgentID != nil {
+		var agentID *int
+		if *req.AgentID != 0 {
+			agent, err := h.db.GetAgent(*req.AgentID)
+			if err != nil {
+				writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+				return
+			}
+			if agent == nil || !agent.Enabled {
+				writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "agent_id must reference an active agent"})
+				return
+			}
+			agentID = &agent.ID
+		}
+		if err := h.db.AssignTargetAgent(id, agentID); err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+			return
+		}
+	}
+
 	updated, err := h.db.UpdateTarget(id, updates)
 	if err != nil {
 		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": err.Error()})
@@ -486,6 +972,7 @@ func (h *Handlers) AdminPatchChannelAdvanced(w http.ResponseWriter, r *http.Requ
 		writeJSON(w, http.StatusNotFound, map[string]any{"detail": "target not found"})
 		return
 	}
+	h.audit(r, "target.update", "target", id, auditTargetDiff(existing, updated))
 	writeJSON(w, http.StatusOK, map[string]any{"ok": true, "item": adminChannelItem(updated)})
 }
 
@@ -525,6 +1012,8 @@ func (h *Handlers) AdminGetChannelModels(w http.ResponseWriter, r *http.Request)
 			"target_id":        target.ID,
 			"target_name":      target.Name,
 			"selected_models":  target.SelectedModels,
+			"include_patterns": target.IncludePatterns,
+			"exclude_patterns": target.ExcludePatterns,
 			"available_models": items,
 		},
 	})
@@ -550,14 +1039,20 @@ func (h *Handlers) AdminPatchChannelModels(w http.ResponseWriter, r *http.Reques
 
 	var req adminChannelModelsPatchRequest
 	if err := readJSON(r, &req); err != nil {
-		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "invalid JSON"})
+		writeRequestBodyError(w, err)
 		return
 	}
 	updates := map[string]any{
 		"selected_models": req.SelectedModels,
 	}
+	if req.IncludePatterns != nil {
+		updates["include_patterns"] = *req.IncludePatterns
+	}
+	if req.ExcludePatterns != nil {
+		updates["exclude_patterns"] = *req.ExcludePatterns
+	}
 	if err := validateTargetPayload(updates); err != nil {
-		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": err.Error()})
+		writeValidationError(w, err)
 		return
 	}
 	updated, err := h.db.UpdateTarget(id, updates)
@@ -569,5 +1064,6 @@ func (h *Handlers) AdminPatchChannelModels(w http.ResponseWriter, r *http.Reques
 		writeJSON(w, http.StatusNotFound, map[string]any{"detail": "target not found"})
 		return
 	}
+	h.audit(r, "target.update", "target", id, auditTargetDiff(target, updated))
 	writeJSON(w, http.StatusOK, map[string]any{"ok": true, "item": adminChannelItem(updated)})
 }
@@ -0,0 +1,623 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// agentLeaseTTL bounds how long an agent may hold a target before the run is failed and
+// the target becomes due again.
+const agentLeaseTTL = 30 * time.Minute
+
+// agentMaxAssignments caps how many targets one assignments poll hands out.
+const agentMaxAssignments = 10
+
+var (
+	errAgentInvalidToken  = errors.New("invalid or revoked agent token")
+	errAgentLeaseNotFound = errors.New("run is not leased to this agent or the lease expired")
+)
+
+// Agent is a remote probe worker that runs detections for the targets assigned to it.
+type Agent struct {
+	ID          int      `json:"id"`
+	Name        string   `json:"name"`
+	TokenPrefix string   `json:"token_prefix"`
+	Region      string   `json:"region"`
+	Description string   `json:"description"`
+	Enabled     bool     `json:"enabled"`
+	CreatedAt   float64  `json:"created_at"`
+	RevokedAt   *float64 `json:"revoked_at"`
+	LastSeenAt  *float64 `json:"last_seen_at"`
+	LastIP      *string  `json:"last_ip"`
+	Hostname    *string  `json:"hostname"`
+	Version     *string  `json:"version"`
+}
+
+type createAgentRequest struct {
+	Name        string `json:"name"`
+	Region      string `json:"region"`
+	Description string `json:"description"`
+}
+
+type agentRegisterRequest struct {
+	Hostname string `json:"hostname"`
+	Version  string `json:"version"`
+	Region   string `json:"region"`
+}
+
+// agentAssignment is one leased target handed to an agent.
+type agentAssignment struct {
+	RunID  int    `json:"run_id"`
+	Target Target `json:"target"`
+	// ExpiresAt is when the lease runs out; the agent stops probing by then.
+	ExpiresAt float64 `json:"expires_at"`
+}
+
+// agentRunReport is what an agent posts back after running an assignment.
+type agentRunReport struct {
+	RunID int `json:"run_id"`
+	// Error is set when the run failed before any model was probed (model listing, client setup).
+	Error        string            `json:"error"`
+	Rows         []DetectionResult `json:"rows"`
+	CertChain    []tlsCertInfo     `json:"cert_chain"`
+	CertNotAfter *float64          `json:"cert_not_after"`
+}
+
+type agentLease struct {
+	agentID   int
+	runID     int
+	targetID  int
+	logFile   string
+	expiresAt time.Time
+}
+
+// ---------------------------------------------------------------------------
+// Storage
+// ---------------------------------------------------------------------------
+
+func (d *Database) EnsureAgentSchema() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	_, err := d.conn.Exec(`
+		CREATE TABLE IF NOT EXISTS agents (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL UNIQUE,
+			token_hash TEXT NOT NULL UNIQUE,
+			token_prefix TEXT NOT NULL,
+			region TEXT NOT NULL DEFAULT '',
+			description TEXT NOT NULL DEFAULT '',
+			enabled INTEGER NOT NULL DEFAULT 1,
+			created_at REAL NOT NULL,
+			revoked_at REAL,
+			last_seen_at REAL,
+			last_ip TEXT,
+			hostname TEXT,
+			version TEXT
+		);
+	`)
+	if err != nil {
+		return fmt.Errorf("init agent schema: %w", err)
+	}
+	return nil
+}
+
+const agentColumns = `id, name, token_prefix, region, description, enabled, created_at,
+	revoked_at, last_seen_at, last_ip, hostname, version`
+
+func scanAgent(r interface{ Scan(dest ...any) error }) (*Agent, error) {
+	var a Agent
+	var enabledInt int
+	if err := r.Scan(
+		&a.ID, &a.Name, &a.TokenPrefix, &a.Region, &a.Description, &enabledInt, &a.CreatedAt,
+		&a.RevokedAt, &a.LastSeenAt, &a.LastIP, &a.Hostname, &a.Version,
+	); err != nil {
+		return nil, err
+	}
+	a.Enabled = enabledInt != 0
+	return &a, nil
+}
+
+// GetAgent returns an agent by id, or nil when it does not exist.
+func (d *Database) GetAgent(id int) (*Agent, error) {
+	a, err := scanAgent(d.read.QueryRow("SELECT "+agentColumns+" FROM agents WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return a, err
+}
+
+func (d *Database) CreateAgent(name, region, description string) (*Agent, string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, "", fmt.Errorf("name is required")
+	}
+	now := float64(time.Now().UnixMilli()) / 1000.0
+
+	for i := 0; i < 5; i++ {
+		raw, err := generateProxyToken()
+		if err != nil {
+			return nil, "", err
+		}
+		token := "agt-" + strings.TrimPrefix(raw, "sk-")
+		prefix := token[:12]
+
+		d.mu.Lock()
+		res, err := d.conn.Exec(`
+			INSERT INTO agents (name, token_hash, token_prefix, region, description, enabled, created_at)
+			VALUES (?, ?, ?, ?, ?, 1, ?)`,
+			name, proxyKeyHash(token), prefix, strings.TrimSpace(region), description, now,
+		)
+		d.mu.Unlock()
+		if err != nil {
+			msg := strings.ToLower(err.Error())
+			if strings.Contains(msg, "agents.name") {
+				return nil, "", fmt.Errorf("agent name already exists")
+			}
+			if strings.Contains(msg, "unique") {
+				continue
+			}
+			return nil, "", err
+		}
+
+		id64, _ := res.LastInsertId()
+		created, err := d.GetAgent(int(id64))
+		if err != nil {
+			return nil, "", err
+		}
+		if created == nil {
+			return nil, "", fmt.Errorf("agent created but not found")
+		}
+		return created, token, nil
+	}
+	return nil, "", fmt.Errorf("failed to create unique agent token")
+}
+
+func (d *Database) ListAgents() ([]Agent, error) {
+	rows, err := d.read.Query("SELECT " + agentColumns + " FROM agents ORDER BY created_at DESC, id DESC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]Agent, 0)
+	for rows.Next() {
+		a, err := scanAgent(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *a)
+	}
+	return out, rows.Err()
+}
+
+// RevokeAgent disables an agent and hands its targets back to the central scheduler.
+func (d *Database) RevokeAgent(id int) (bool, error) {
+	defer d.touchTargets()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	res, err := d.conn.Exec(`
+		UPDATE agents
+		SET enabled = 0, revoked_at = ?
+		WHERE id = ? AND revoked_at IS NULL`,
+		float64(time.Now().UnixMilli())/1000.0, id,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	if n == 0 {
+		return false, nil
+	}
+	if _, err := d.conn.Exec("UPDATE targets SET agent_id = NULL WHERE agent_id = ?", id); err != nil {
+		return true, err
+	}
+	return true, nil
+}
+
+func (d *Database) GetActiveAgentByToken(token string) (*Agent, error) {
+	a, err := scanAgent(d.read.QueryRow(
+		"SELECT "+agentColumns+" FROM agents WHERE token_hash = ? AND enabled = 1 AND revoked_at IS NULL LIMIT 1",
+		proxyKeyHash(token),
+	))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return a, err
+}
+
+// TouchAgent records a heartbeat. Empty hostname, version or region keep the stored value.
+func (d *Database) TouchAgent(id int, ip, hostname, version, region string) error {
+	d.mu.Lock()
+	_, err := d.conn.Exec(`
+		UPDATE agents
+		SET last_seen_at = ?, last_ip = ?,
+			hostname = COALESCE(NULLIF(?, ''), hostname),
+			version = COALESCE(NULLIF(?, ''), version),
+			region = COALESCE(NULLIF(?, ''), region)
+		WHERE id = ?`,
+		float64(time.Now().UnixMilli())/1000.0, ip, hostname, version, strings.TrimSpace(region), id,
+	)
+	d.mu.Unlock()
+	return err
+}
+
+// AssignTargetAgent moves a target to an agent, or back to the central scheduler when agentID is nil.
+func (d *Database) AssignTargetAgent(targetID int, agentID *int) error {
+	defer d.touchTargets()
+	d.mu.Lock()
+	_, err := d.conn.Exec(
+		"UPDATE targets SET agent_id = ?, updated_at = ? WHERE id = ?",
+		agentID, float64(time.Now().UnixMilli())/1000.0, targetID,
+	)
+	d.mu.Unlock()
+	return err
+}
+
+// ---------------------------------------------------------------------------
+// Leases
+// ---------------------------------------------------------------------------
+
+// LeaseAgentTargets opens a run for every due target assigned to agent and marks it running
+// until the agent reports back or the lease expires.
+func (ms *MonitorService) LeaseAgentTargets(agent *Agent) ([]agentAssignment, error) {
+	ms.expireAgentLeases()
+	if ms.SchedulerPaused() {
+		return []agentAssignment{}, nil
+	}
+
+	nowTS := float64(time.Now().UnixMilli()) / 1000.0
+	targets, err := ms.db.ListDueTargets(nowTS, &agent.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]agentAssignment, 0)
+	for i := range targets {
+		if len(out) >= agentMaxAssignments {
+			break
+		}
+		target := targets[i]
+		ms.mu.Lock()
+		if ms.runningTargets[target.ID] {
+			ms.mu.Unlock()
+			continue
+		}
+		ms.runningTargets[target.ID] = true
+		ms.mu.Unlock()
+
+		logFile := ms.newRunLogFile(&target)
+		runID, err := ms.db.CreateRun(target.ID, nowTS, logFile, &agent.ID, runModeFull)
+		if err != nil {
+			ms.releaseTarget(target.ID)
+			return out, err
+		}
+		expiresAt := time.Now().Add(agentLeaseTTL)
+		ms.mu.Lock()
+		ms.agentLeases[target.ID] = &agentLease{
+			agentID:   agent.ID,
+			runID:     runID,
+			targetID:  target.ID,
+			logFile:   logFile,
+			expiresAt: expiresAt,
+		}
+		ms.mu.Unlock()
+		ms.logger().Info("run leased", "target", target.Name, "target_id", target.ID, "agent", agent.Name, "run_id", runID)
+		out = append(out, agentAssignment{RunID: runID, Target: target, ExpiresAt: float64(expiresAt.UnixMilli()) / 1000.0})
+	}
+	return out, nil
+}
+
+// AgentLeasedRunIDs lists the runs agent currently holds a lease for. Agents abort local
+// runs missing from this list, e.g. after a cancel or an expired lease.
+func (ms *MonitorService) AgentLeasedRunIDs(agentID int) []int {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ids := make([]int, 0)
+	for _, l := range ms.agentLeases {
+		if l.agentID == agentID {
+			ids = append(ids, l.runID)
+		}
+	}
+	return ids
+}
+
+// CompleteAgentRun records the results an agent reported for a leased run.
+func (ms *MonitorService) CompleteAgentRun(agent *Agent, report agentRunReport) error {
+	ms.mu.Lock()
+	var lease *agentLease
+	for _, l := range ms.agentLeases {
+		if l.runID == report.RunID && l.agentID == agent.ID {
+			lease = l
+			break
+		}
+	}
+	if lease != nil {
+		delete(ms.agentLeases, lease.targetID)
+		ms.activeLogFiles[lease.logFile] = true
+	}
+	ms.mu.Unlock()
+	if lease == nil {
+		return errAgentLeaseNotFound
+	}
+	defer func() {
+		ms.mu.Lock()
+		delete(ms.activeLogFiles, lease.logFile)
+		ms.mu.Unlock()
+		ms.releaseTarget(lease.targetID)
+		ms.shipRunLog(ms.compressRunLog(lease.logFile))
+		ms.cleanupDataLogs()
+	}()
+
+	target, err := ms.db.GetTarget(lease.targetID)
+	if err != nil {
+		return err
+	}
+	if target == nil {
+		return fmt.Errorf("target not found")
+	}
+
+	if report.Error != "" {
+		ms.markRunError(target, lease.runID, lease.logFile, errors.New(report.Error))
+		ms.logger().Warn("agent run failed", "target", target.Name, "target_id", target.ID, "agent", agent.Name, "run_id", lease.runID, "error", report.Error)
+		return nil
+	}
+
+	resultCh := make(chan DetectionResult, len(report.Rows))
+	for _, row := range report.Rows {
+		// Agents from before error categories report failures without one.
+		if !row.Success && row.ErrorCategory == "" && row.Error != nil {
+			row.ErrorCategory = classifyDetectionError(row.StatusCode, *row.Error)
+		}
+		resultCh <- row
+	}
+	close(resultCh)
+	rows, err := ms.writeRunLog(target, lease.runID, lease.logFile, resultCh, nil)
+	if err != nil {
+		ms.markRunError(target, lease.runID, lease.logFile, err)
+		return nil
+	}
+	var notAfter time.Time
+	if report.CertNotAfter != nil {
+		notAfter = time.UnixMilli(int64(*report.CertNotAfter * 1000))
+	}
+	ms.logger().Info("agent run reported", "target", target.Name, "target_id", target.ID, "agent", agent.Name, "run_id", lease.runID, "rows", len(rows))
+	ms.completeRun(context.Background(), target, lease.runID, lease.logFile, rows, report.CertChain, notAfter, runModeFull)
+	return nil
+}
+
+// expireAgentLeases fails runs whose agent did not report back in time.
+func (ms *MonitorService) expireAgentLeases() {
+	now := time.Now()
+	ms.mu.Lock()
+	var expired []*agentLease
+	for targetID, l := range ms.agentLeases {
+		if now.After(l.expiresAt) {
+			expired = append(expired, l)
+			delete(ms.agentLeases, targetID)
+		}
+	}
+	ms.mu.Unlock()
+	ms.failAgentLeases(expired, fmt.Errorf("agent did not report results within %s", agentLeaseTTL))
+}
+
+// ReleaseAgentLeases fails every run leased to agentID, e.g. after its token was revoked
+// and the agent can no longer report back.
+func (ms *MonitorService) ReleaseAgentLeases(agentID int) {
+	ms.mu.Lock()
+	var leases []*agentLease
+	for targetID, l := range ms.agentLeases {
+		if l.agentID == agentID {
+			leases = append(leases, l)
+			delete(ms.agentLeases, targetID)
+		}
+	}
+	ms.mu.Unlock()
+	ms.failAgentLeases(leases, fmt.Errorf("agent revoked before reporting results"))
+}
+
+// failAgentLeases frees the targets of leases already removed from agentLeases and marks their runs errored.
+func (ms *MonitorService) failAgentLeases(leases []*agentLease, runErr error) {
+	for _, l := range leases {
+		ms.releaseTarget(l.targetID)
+		target, err := ms.db.GetTarget(l.targetID)
+		if err != nil || target == nil {
+			continue
+		}
+		ms.markRunError(target, l.runID, l.logFile, runErr)
+		ms.logger().Warn("agent lease released", "target", target.Name, "target_id", target.ID, "run_id", l.runID, "error", runErr)
+	}
+}
+
+func (ms *MonitorService) releaseTarget(targetID int) {
+	ms.mu.Lock()
+	delete(ms.runningTargets, targetID)
+	ms.mu.Unlock()
+	ms.startQueuedRuns()
+}
+
+// ---------------------------------------------------------------------------
+// Authentication
+// ---------------------------------------------------------------------------
+
+// agentTokenAuthenticator accepts an active agent token.
+type agentTokenAuthenticator struct {
+	db *Database
+}
+
+func (a agentTokenAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	token, err := parseProxyBearerToken(r)
+	if err != nil {
+		return nil, nil
+	}
+	agent, err := a.db.GetActiveAgentByToken(token)
+	if err != nil {
+		return nil, err
+	}
+	if agent == nil {
+		return nil, errAgentInvalidToken
+	}
+	return &Principal{Role: authRoleAgent, Method: "agent_token", Agent: agent}, nil
+}
+
+func agentAPIMiddleware(db *Database, next http.Handler) http.Handler {
+	return authMiddleware(
+		agentTokenAuthenticator{db: db},
+		authPolicy{
+			roles:        []authRole{authRoleAgent},
+			failureScope: authFailureScopeToken,
+			deny:         denyJSON(http.StatusUnauthorized, "agent token required"),
+		},
+		next,
+	)
+}
+
+// ---------------------------------------------------------------------------
+// Admin API
+// ---------------------------------------------------------------------------
+
+// AdminListAgents handles GET /api/admin/agents
+func (h *Handlers) AdminListAgents(w http.ResponseWriter, r *http.Request) {
+	items, err := h.db.ListAgents()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"items": items})
+}
+
+// AdminCreateAgent handles POST /api/admin/agents
+func (h *Handlers) AdminCreateAgent(w http.ResponseWriter, r *http.Request) {
+	var req createAgentRequest
+	if err := readJSON(r, &req); err != nil {
+		writeRequestBodyError(w, err)
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > 128 {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "name must be 1-128 chars"})
+		return
+	}
+	if len(req.Region) > 64 {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "region must be <= 64 chars"})
+		return
+	}
+	if len(req.Description) > 512 {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "description must be <= 512 chars"})
+		return
+	}
+
+	item, token, err := h.db.CreateAgent(req.Name, req.Region, req.Description)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": err.Error()})
+		return
+	}
+	h.audit(r, "agent.create", "agent", item.ID, auditDiff(nil, item, nil, nil))
+	writeJSON(w, http.StatusOK, map[string]any{
+		"item":        item,
+		"agent_token": token, // only returned once at creation
+	})
+}
+
+// AdminRevokeAgent handles DELETE /api/admin/agents/{id}
+func (h *Handlers) AdminRevokeAgent(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(r)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "invalid id"})
+		return
+	}
+	revoked, err := h.db.RevokeAgent(id)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return
+	}
+	if !revoked {
+		writeJSON(w, http.StatusNotFound, map[string]any{"detail": "agent not found or already revoked"})
+		return
+	}
+	h.monitor.ReleaseAgentLeases(id)
+	h.audit(r, "agent.revoke", "agent", id, nil)
+	writeJSON(w, http.StatusOK, map[string]any{"ok": true})
+}
+
+// ---------------------------------------------------------------------------
+// Agent API (authenticated by agent token)
+// ---------------------------------------------------------------------------
+
+// AgentRegister handles POST /api/agent/register
+func (h *Handlers) AgentRegister(w http.ResponseWriter, r *http.Request) {
+	agent := principalFromRequest(r).Agent
+	var req agentRegisterRequest
+	if err := readJSON(r, &req); err != nil {
+		writeRequestBodyError(w, err)
+		return
+	}
+	if len(req.Hostname) > 255 || len(req.Version) > 64 || len(req.Region) > 64 {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "hostname, version or region too long"})
+		return
+	}
+	if err := h.db.TouchAgent(agent.ID, clientIPFromRequest(r), req.Hostname, req.Version, req.Region); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return
+	}
+	item, err := h.db.GetAgent(agent.ID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return
+	}
+	h.logger("monitor").Info("agent registered", "agent", agent.Name, "host", req.Hostname, "version", req.Version)
+	writeJSON(w, http.StatusOK, map[string]any{"item": item})
+}
+
+// AgentAssignments handles GET /api/agent/assignments
+func (h *Handlers) AgentAssignments(w http.ResponseWriter, r *http.Request) {
+	agent := principalFromRequest(r).Agent
+	if err := h.db.TouchAgent(agent.ID, clientIPFromRequest(r), "", "", ""); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return
+	}
+	rules, err := h.db.ListRouteRules()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return
+	}
+	items, err := h.monitor.LeaseAgentTargets(agent)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"items":          items,
+		"route_rules":    rules,
+		"active_run_ids": h.monitor.AgentLeasedRunIDs(agent.ID),
+	})
+}
+
+// AgentSubmitResults handles POST /api/agent/results
+func (h *Handlers) AgentSubmitResults(w http.ResponseWriter, r *http.Request) {
+	agent := principalFromRequest(r).Agent
+	var report agentRunReport
+	if err := readJSON(r, &report); err != nil {
+		writeRequestBodyError(w, err)
+		return
+	}
+	if report.RunID < 1 {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "run_id is required"})
+		return
+	}
+	if err := h.monitor.CompleteAgentRun(agent, report); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, errAgentLeaseNotFound) {
+			status = http.StatusConflict
+		}
+		writeJSON(w, status, map[string]any{"detail": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"ok": true})
+}
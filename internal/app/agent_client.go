@@ -0,0 +1,266 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// agentVersion is reported to the server on registration.
+const agentVersion = "1"
+
+// errAgentRunWithdrawn aborts a local run the server no longer leases to this agent.
+var errAgentRunWithdrawn = errors.New("run no longer leased by the server")
+
+// agentClient talks to the central server's /api/agent/* endpoints.
+type agentClient struct {
+	serverURL string
+	token     string
+	http      *http.Client
+}
+
+func (c *agentClient) do(ctx context.Context, method, path string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("marshal body: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.serverURL+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	raw, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		var detail struct {
+			Detail string `json:"detail"`
+		}
+		_ = json.Unmarshal(raw, &detail)
+		if detail.Detail == "" {
+			detail.Detail = truncStr(string(raw), 200)
+		}
+		return fmt.Errorf("%s %s: HTTP %d: %s", method, path, resp.StatusCode, detail.Detail)
+	}
+	if out != nil {
+		if err := json.Unmarshal(raw, out); err != nil {
+			return fmt.Errorf("decode %s response: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// runAgentAssignment probes one leased target locally and builds the report for the server.
+// When ctx ends early the report carries an error instead of partial rows.
+func (ms *MonitorService) runAgentAssignment(ctx context.Context, a agentAssignment) agentRunReport {
+	report := agentRunReport{RunID: a.RunID, Rows: []DetectionResult{}}
+	target := a.Target
+	ctx, span := startSpan(ctx, "detection.run", "target.id", target.ID, "target.name", target.Name, "run.id", a.RunID)
+	defer func() {
+		var err error
+		if report.Error != "" {
+			err = errors.New(report.Error)
+		}
+		span.SetAttrs("rows", len(report.Rows))
+		span.End(err)
+	}()
+
+	client, err := targetHTTPClient(&target)
+	if err != nil {
+		report.Error = err.Error()
+		return report
+	}
+	certObserver := &tlsCertObserver{}
+	observeClientCerts(client, certObserver)
+
+	resultCh, _, err := ms.detectModels(ctx, &target, client)
+	if err == nil {
+		for row := range resultCh {
+			report.Rows = append(report.Rows, row)
+		}
+	}
+	if ctx.Err() != nil {
+		report.Rows = []DetectionResult{}
+		report.Error = fmt.Sprintf("run aborted on agent: %v", context.Cause(ctx))
+		return report
+	}
+	if err != nil {
+		report.Error = err.Error()
+		return report
+	}
+	if chain, notAfter, ok := certObserver.Result(); ok {
+		ts := float64(notAfter.UnixMilli()) / 1000.0
+		report.CertChain = chain
+		report.CertNotAfter = &ts
+	}
+	return report
+}
+
+// StartAgent runs the binary as a probe agent: it polls the central server for targets
+// assigned to this agent, runs the detections locally and pushes the results back.
+func StartAgent() {
+	logger := componentLogger(nil, "agent")
+	serverURL := strings.TrimRight(strings.TrimSpace(os.Getenv("AGENT_SERVER_URL")), "/")
+	token := strings.TrimSpace(os.Getenv("AGENT_TOKEN"))
+	if serverURL == "" || token == "" {
+		fatal(logger, "AGENT_SERVER_URL and AGENT_TOKEN are required in agent mode")
+	}
+	pollInterval := time.Duration(envInt("AGENT_POLL_INTERVAL_S", 30)) * time.Second
+	if pollInterval < 5*time.Second {
+		pollInterval = 5 * time.Second
+	}
+	maxParallelTargets := envInt("MONITOR_MAX_PARALLEL_TARGETS", 2)
+	if maxParallelTargets < 1 {
+		maxParallelTargets = 2
+	}
+	detectConcurrency := envInt("MONITOR_DETECT_CONCURRENCY", 3)
+	if detectConcurrency < 1 {
+		detectConcurrency = 3
+	}
+
+	client := &agentClient{
+		serverURL: serverURL,
+		token:     token,
+		http:      &http.Client{Timeout: 60 * time.Second},
+	}
+	// The agent keeps no local state: no database, logs or scheduler.
+	ms := &MonitorService{detectConcurrency: detectConcurrency, log: logger}
+	shutdownTracing, err := startTracing(nil)
+	if err != nil {
+		fatal(logger, "tracing configuration invalid", "error", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+
+	hostname, _ := os.Hostname()
+	register := agentRegisterRequest{
+		Hostname: hostname,
+		Version:  agentVersion,
+		Region:   strings.TrimSpace(os.Getenv("AGENT_REGION")),
+	}
+	for {
+		var resp struct {
+			Item Agent `json:"item"`
+		}
+		err := client.do(ctx, http.MethodPost, "/api/v1/agent/register", register, &resp)
+		if err == nil {
+			logger.Info("registered", "agent", resp.Item.Name, "agent_id", resp.Item.ID, "server", serverURL)
+			break
+		}
+		logger.Warn("register failed", "error", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(pollInterval):
+		}
+	}
+
+	sem := make(chan struct{}, maxParallelTargets)
+	var wg sync.WaitGroup
+	// runs cancels in-flight assignments, keyed by run id.
+	var runMu sync.Mutex
+	runs := make(map[int]context.CancelCauseFunc)
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		var resp struct {
+			Items        []agentAssignment `json:"items"`
+			RouteRules   []RouteRule       `json:"route_rules"`
+			ActiveRunIDs []int             `json:"active_run_ids"`
+		}
+		if err := client.do(ctx, http.MethodGet, "/api/v1/agent/assignments", nil, &resp); err != nil {
+			if ctx.Err() == nil {
+				logger.Warn("fetch assignments failed", "error", err)
+			}
+		} else {
+			ms.applyRouteRules(resp.RouteRules)
+			// Runs the server cancelled or expired are no longer listed; stop probing them.
+			active := make(map[int]bool, len(resp.ActiveRunIDs))
+			for _, id := range resp.ActiveRunIDs {
+				active[id] = true
+			}
+			runMu.Lock()
+			for runID, cancel := range runs {
+				if !active[runID] {
+					logger.Info("run withdrawn by server", "run_id", runID)
+					cancel(errAgentRunWithdrawn)
+				}
+			}
+			runMu.Unlock()
+
+			for _, a := range resp.Items {
+				deadline := time.Now().Add(agentLeaseTTL)
+				if a.ExpiresAt > 0 {
+					deadline = time.UnixMilli(int64(a.ExpiresAt * 1000))
+				}
+				// Shutdown, lease expiry and server withdrawal all cancel the run.
+				cancelCtx, cancel := context.WithCancelCause(ctx)
+				runCtx, stopDeadline := context.WithDeadline(cancelCtx, deadline)
+				runMu.Lock()
+				runs[a.RunID] = cancel
+				runMu.Unlock()
+
+				wg.Add(1)
+				go func(a agentAssignment) {
+					defer wg.Done()
+					defer func() {
+						runMu.Lock()
+						delete(runs, a.RunID)
+						runMu.Unlock()
+						stopDeadline()
+						cancel(nil)
+					}()
+					select {
+					case sem <- struct{}{}:
+						defer func() { <-sem }()
+					case <-runCtx.Done():
+					}
+					logger.Info("run start", "target", a.Target.Name, "target_id", a.Target.ID, "run_id", a.RunID)
+					report := ms.runAgentAssignment(runCtx, a)
+					if errors.Is(context.Cause(runCtx), errAgentRunWithdrawn) {
+						return
+					}
+					// Results are pushed even during shutdown so the server does not wait for the lease to expire.
+					if err := client.do(context.Background(), http.MethodPost, "/api/v1/agent/results", report, nil); err != nil {
+						logger.Error("push results failed", "target", a.Target.Name, "target_id", a.Target.ID, "run_id", a.RunID, "error", err)
+						return
+					}
+					logger.Info("run finished", "target", a.Target.Name, "target_id", a.Target.ID, "run_id", a.RunID, "rows", len(report.Rows))
+				}(a)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			logger.Info("shutdown signal received, aborting running detections")
+			wg.Wait()
+			flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			shutdownTracing(flushCtx)
+			cancel()
+			logger.Info("shutdown completed")
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package app
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestAgentLeaseAndReport(t *testing.T) {
+	db, err := NewDatabase(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDatabase failed: %v", err)
+	}
+	t.Cleanup(func() { _ = db.conn.Close() })
+	if err := db.EnsureAgentSchema(); err != nil {
+		t.Fatalf("EnsureAgentSchema failed: %v", err)
+	}
+	ms := NewMonitorService(MonitorConfig{DB: db, LogDir: t.TempDir()})
+
+	agent, token, err := db.CreateAgent("edge-1", "eu", "")
+	if err != nil {
+		t.Fatalf("CreateAgent failed: %v", err)
+	}
+	if found, err := db.GetActiveAgentByToken(token); err != nil || found == nil || found.ID != agent.ID {
+		t.Fatalf("agent token should authenticate, got=%v err=%v", found, err)
+	}
+	target, err := db.CreateTarget(map[string]any{"name": "t1", "base_url": "https://example.com", "api_key": "k"})
+	if err != nil {
+		t.Fatalf("CreateTarget failed: %v", err)
+	}
+	if _, err := db.CreateTarget(map[string]any{"name": "t2", "base_url": "https://example.org", "api_key": "k"}); err != nil {
+		t.Fatalf("CreateTarget failed: %v", err)
+	}
+	if err := db.AssignTargetAgent(target.ID, &agent.ID); err != nil {
+		t.Fatalf("AssignTargetAgent failed: %v", err)
+	}
+
+	central, err := db.ListDueTargets(0, nil)
+	if err != nil || len(central) != 1 || central[0].Name != "t2" {
+		t.Fatalf("central scheduler should only see unassigned targets, got=%v err=%v", central, err)
+	}
+
+	items, err := ms.LeaseAgentTargets(agent)
+	if err != nil || len(items) != 1 || items[0].Target.ID != target.ID {
+		t.Fatalf("agent should lease its target, got=%v err=%v", items, err)
+	}
+	if !ms.IsTargetRunning(target.ID) {
+		t.Fatalf("leased target should be marked running")
+	}
+	if again, _ := ms.LeaseAgentTargets(agent); len(again) != 0 {
+		t.Fatalf("leased target should not be handed out twice, got=%d", len(again))
+	}
+
+	other, _, err := db.CreateAgent("edge-2", "", "")
+	if err != nil {
+		t.Fatalf("CreateAgent failed: %v", err)
+	}
+	if err := ms.CompleteAgentRun(other, agentRunReport{RunID: items[0].RunID}); err != errAgentLeaseNotFound {
+		t.Fatalf("another agent should not complete the lease, got=%v", err)
+	}
+
+	report := agentRunReport{
+		RunID: items[0].RunID,
+		Rows: []DetectionResult{
+			{Protocol: "openai", Model: "m1", Success: true, TransportSuccess: true, Route: "chat", Endpoint: "/v1/chat/completions"},
+			{Protocol: "openai", Model: "m2", Success: false, Route: "chat", Endpoint: "/v1/chat/completions"},
+		},
+	}
+	if err := ms.CompleteAgentRun(agent, report); err != nil {
+		t.Fatalf("CompleteAgentRun failed: %v", err)
+	}
+	if ms.IsTargetRunning(target.ID) {
+		t.Fatalf("target should be released after report")
+	}
+
+	updated, _ := db.GetTarget(target.ID)
+	if updated.LastStatus == nil || *updated.LastStatus != "degraded" {
+		t.Fatalf("target status should be degraded, got=%v", updated.LastStatus)
+	}
+	runs, err := db.ListRuns(target.ID, 10)
+	if err != nil || len(runs) != 1 {
+		t.Fatalf("one run should be recorded, got=%v err=%v", runs, err)
+	}
+	if runs[0].AgentID == nil || *runs[0].AgentID != agent.ID || runs[0].Status != "completed" {
+		t.Fatalf("run should be completed by the agent, got=%+v", runs[0])
+	}
+
+	if ok, err := db.RevokeAgent(agent.ID); err != nil || !ok {
+		t.Fatalf("RevokeAgent failed: ok=%v err=%v", ok, err)
+	}
+	if updated, _ := db.GetTarget(target.ID); updated.AgentID != nil {
+		t.Fatalf("revoking an agent should unassign its targets, got=%v", *updated.AgentID)
+	}
+}
+
+func TestReleaseAgentLeases_FreesTargetsOnRevoke(t *testing.T) {
+	db, err := NewDatabase(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDatabase failed: %v", err)
+	}
+	t.Cleanup(func() { _ = db.conn.Close() })
+	if err := db.EnsureAgentSchema(); err != nil {
+		t.Fatalf("EnsureAgentSchema failed: %v", err)
+	}
+	ms := NewMonitorService(MonitorConfig{DB: db, LogDir: t.TempDir()})
+
+	agent, _, err := db.CreateAgent("edge-1", "", "")
+	if err != nil {
+		t.Fatalf("CreateAgent failed: %v", err)
+	}
+	target, err := db.CreateTarget(map[string]any{"name": "t1", "base_url": "https://example.com", "api_key": "k"})
+	if err != nil {
+		t.Fatalf("CreateTarget failed: %v", err)
+	}
+	if err := db.AssignTargetAgent(target.ID, &agent.ID); err != nil {
+		t.Fatalf("AssignTargetAgent failed: %v", err)
+	}
+	items, err := ms.LeaseAgentTargets(agent)
+	if err != nil || len(items) != 1 {
+		t.Fatalf("agent should lease its target, got=%v err=%v", items, err)
+	}
+	if ids := ms.AgentLeasedRunIDs(agent.ID); len(ids) != 1 || ids[0] != items[0].RunID {
+		t.Fatalf("leased run should be listed as active, got=%v", ids)
+	}
+
+	if ok, err := db.RevokeAgent(agent.ID); err != nil || !ok {
+		t.Fatalf("RevokeAgent failed: ok=%v err=%v", ok, err)
+	}
+	ms.ReleaseAgentLeases(agent.ID)
+
+	if ms.IsTargetRunning(target.ID) {
+		t.Fatalf("revoked agent's target should no longer be running")
+	}
+	if ids := ms.AgentLeasedRunIDs(agent.ID); len(ids) != 0 {
+		t.Fatalf("revoked agent should hold no leases, got=%v", ids)
+	}
+	runs, err := db.ListRuns(target.ID, 10)
+	if err != nil || len(runs) != 1 || runs[0].Status != "error" {
+		t.Fatalf("leased run should be marked error, got=%+v err=%v", runs, err)
+	}
+}
@@ -0,0 +1,630 @@
+package app
+
+import (
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Analytics bucket widths in seconds.
+var analyticsBuckets = map[string]int{
+	"hour": 3600,
+	"day":  86400,
+}
+
+// Maximum lookback per bucket width, in hours.
+var analyticsMaxHours = map[string]int{
+	"hour": 24 * 31,
+	"day":  24 * 366,
+}
+
+// AnalyticsPoint aggregates run_models rows of one target (and optionally one model)
+// over a UTC-aligned time bucket.
+type AnalyticsPoint struct {
+	TargetID    int      `json:"target_id"`
+	Model       string   `json:"model,omitempty"`
+	BucketStart float64  `json:"bucket_start"`
+	Total       int      `json:"total"`
+	Success     int      `json:"success"`
+	SuccessRate float64  `json:"success_rate"`
+	AvgDuration *float64 `json:"avg_duration"`
+}
+
+// TimeseriesQuery selects the rows aggregated by GetTimeseries.
+type TimeseriesQuery struct {
+	BucketSeconds int
+	Since         float64
+	Until         float64
+	TargetID      *int
+	Model         *string
+	ByModel       bool
+}
+
+// GetTimeseries buckets detection results by time in SQL. avg_duration only
+// counts successful checks so timeouts do not skew latency charts. Day buckets of days
+// that are rolled up are read from model_daily_stats, the rest from run_models.
+func (d *Database) GetTimeseries(q TimeseriesQuery) ([]AnalyticsPoint, error) {
+	if q.BucketSeconds == rollupDaySeconds {
+		return d.getDailyTimeseries(q)
+	}
+	return d.getRawTimeseries(q, "")
+}
+
+// getDailyTimeseries answers a day-bucketed query from the rollups of the whole days in
+// the window and from run_models for the rest, ordered like getRawTimeseries.
+func (d *Database) getDailyTimeseries(q TimeseriesQuery) ([]AnalyticsPoint, error) {
+	pending, err := d.firstPendingDay()
+	if err != nil {
+		return nil, err
+	}
+	from := math.Ceil(q.Since/rollupDaySeconds) * rollupDaySeconds
+	to := math.Min(pending, math.Floor(q.Until/rollupDaySeconds)*rollupDaySeconds)
+	if math.IsInf(pending, 1) || from >= to {
+		return d.getRawTimeseries(q, "")
+	}
+	raw, err := d.getRawTimeseries(q, "AND (timestamp < ? OR timestamp >= ?)", from, to)
+	if err != nil {
+		return nil, err
+	}
+	rolled, err := d.getRollupTimeseries(q, from, to)
+	if err != nil {
+		return nil, err
+	}
+	points := append(raw, rolled...)
+	sort.SliceStable(points, func(i, j int) bool {
+		a, b := points[i], points[j]
+		if a.TargetID != b.TargetID {
+			return a.TargetID < b.TargetID
+		}
+		if a.Model != b.Model {
+			return a.Model < b.Model
+		}
+		return a.BucketStart < b.BucketStart
+	})
+	return points, nil
+}
+
+// getRollupTimeseries sums the rollups of days within [from, to) per target (or per
+// model); the average latency is weighted by successful checks.
+func (d *Database) getRollupTimeseries(q TimeseriesQuery, from, to float64) ([]AnalyticsPoint, error) {
+	groupCols := "target_id"
+	selectModel := "''"
+	if q.ByModel {
+		groupCols = "target_id, model"
+		selectModel = "model"
+	}
+	args := []any{from, to}
+	filter := ""
+	if q.TargetID != nil {
+		filter = "AND target_id = ?"
+		args = append(args, *q.TargetID)
+	}
+	if q.Model != nil {
+		filter += " AND model = ?"
+		args = append(args, *q.Model)
+	}
+	rows, err := d.read.Query(`
+		SELECT target_id, `+selectModel+`, day, SUM(total), SUM(success),
+			SUM(avg_duration * success) / NULLIF(SUM(CASE WHEN avg_duration IS NOT NULL THEN success END), 0)
+		FROM model_daily_stats
+		WHERE day >= ? AND day < ? `+filter+`
+			AND target_id IN (SELECT id FROM targets WHERE deleted_at IS NULL)
+		GROUP BY `+groupCols+`, day`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	points := []AnalyticsPoint{}
+	for rows.Next() {
+		var p AnalyticsPoint
+		if err := rows.Scan(&p.TargetID, &p.Model, &p.BucketStart, &p.Total, &p.Success, &p.AvgDuration); err != nil {
+			return nil, err
+		}
+		p.SuccessRate = percentRate(p.Success, p.Total)
+		p.AvgDuration = roundSeconds(p.AvgDuration)
+		points = append(points, p)
+	}
+	return points, rows.Err()
+}
+
+// getRawTimeseries buckets run_models rows; extraFilter with extraArgs further restricts
+// the rows.
+func (d *Database) getRawTimeseries(q TimeseriesQuery, extraFilter string, extraArgs ...any) ([]AnalyticsPoint, error) {
+	groupCols := "target_id"
+	selectModel := "''"
+	if q.ByModel {
+		groupCols = "target_id, model"
+		selectModel = "model"
+	}
+	args := []any{q.BucketSeconds, q.BucketSeconds, q.Since, q.Until}
+	targetFilter := ""
+	if q.TargetID != nil {
+		targetFilter = "AND target_id = ?"
+		args = append(args, *q.TargetID)
+	}
+	if q.Model != nil {
+		targetFilter += " AND model = ?"
+		args = append(args, *q.Model)
+	}
+	targetFilter += " " + extraFilter
+	args = append(args, extraArgs...)
+
+	rows, err := d.read.Query(`
+		SELECT target_id, `+selectModel+`,
+			CAST(timestamp / ? AS INTEGER) * ? AS bucket,
+			COUNT(*), SUM(success),
+			AVG(CASE WHEN success = 1 THEN duration END)
+		FROM run_models
+		WHERE timestamp >= ? AND timestamp < ? `+targetFilter+`
+			AND target_id IN (SELECT id FROM targets WHERE deleted_at IS NULL)
+		GROUP BY `+groupCols+`, bucket
+		ORDER BY `+groupCols+`, bucket`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	points := []AnalyticsPoint{}
+	for rows.Next() {
+		var p AnalyticsPoint
+		var bucket int64
+		if err := rows.Scan(&p.TargetID, &p.Model, &bucket, &p.Total, &p.Success, &p.AvgDuration); err != nil {
+			return nil, err
+		}
+		p.BucketStart = float64(bucket)
+		p.SuccessRate = percentRate(p.Success, p.Total)
+		if p.AvgDuration != nil {
+			avg := math.Round(*p.AvgDuration*1000.0) / 1000.0
+			p.AvgDuration = &avg
+		}
+		points = append(points, p)
+	}
+	return points, rows.Err()
+}
+
+// GetModelHistory returns the newest limit raw results of one model within [since, until),
+// oldest first.
+func (d *Database) GetModelHistory(targetID int, model string, since, until float64, limit int) ([]ModelHistoryPoint, error) {
+	rows, err := d.read.Query(`
+		SELECT success, duration, timestamp, error, status_code
+		FROM run_models
+		WHERE target_id = ? AND model = ? AND timestamp >= ? AND timestamp < ?
+		ORDER BY timestamp DESC, id DESC
+		LIMIT ?`, targetID, model, since, until, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	points := []ModelHistoryPoint{}
+	for rows.Next() {
+		var p ModelHistoryPoint
+		var success int
+		if err := rows.Scan(&success, &p.Duration, &p.Timestamp, &p.Error, &p.StatusCode); err != nil {
+			return nil, err
+		}
+		p.Success = success != 0
+		points = append(points, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(points)-1; i < j; i, j = i+1, j-1 {
+		points[i], points[j] = points[j], points[i]
+	}
+	return points, nil
+}
+
+// StatusCodeCount is the number of detections of one target that ended with one HTTP
+// status code, optionally per model and per time bucket. StatusCode is null for
+// detections that got no response.
+type StatusCodeCount struct {
+	Model       string   `json:"model,omitempty"`
+	BucketStart *float64 `json:"bucket_start,omitempty"`
+	StatusCode  *int     `json:"status_code"`
+	Count       int      `json:"count"`
+}
+
+// StatusCodeQuery selects the rows counted by GetStatusCodes; a zero BucketSeconds
+// counts the whole window at once.
+type StatusCodeQuery struct {
+	TargetID      int
+	Since         float64
+	Until         float64
+	Model         *string
+	ByModel       bool
+	BucketSeconds int
+}
+
+// GetStatusCodes counts the detections of a target by HTTP status code.
+func (d *Database) GetStatusCodes(q StatusCodeQuery) ([]StatusCodeCount, error) {
+	selectModel, selectBucket := "''", "NULL"
+	groupCols := []string{"status_code"}
+	var args []any
+	if q.BucketSeconds > 0 {
+		selectBucket = "CAST(timestamp / ? AS INTEGER) * ?"
+		args = append(args, q.BucketSeconds, q.BucketSeconds)
+		groupCols = append([]string{"bucket"}, groupCols...)
+	}
+	if q.ByModel {
+		selectModel = "model"
+		groupCols = append([]string{"model"}, groupCols...)
+	}
+	args = append(args, q.TargetID, q.Since, q.Until)
+	modelFilter := ""
+	if q.Model != nil {
+		modelFilter = "AND model = ?"
+		args = append(args, *q.Model)
+	}
+	group := joinStrings(groupCols, ", ")
+
+	rows, err := d.read.Query(`
+		SELECT `+selectModel+`, `+selectBucket+` AS bucket, status_code, COUNT(*)
+		FROM run_models
+		WHERE target_id = ? AND timestamp >= ? AND timestamp < ? `+modelFilter+`
+		GROUP BY `+group+`
+		ORDER BY `+group, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []StatusCodeCount{}
+	for rows.Next() {
+		var c StatusCodeCount
+		var bucket *int64
+		if err := rows.Scan(&c.Model, &bucket, &c.StatusCode, &c.Count); err != nil {
+			return nil, err
+		}
+		if bucket != nil {
+			start := float64(*bucket)
+			c.BucketStart = &start
+		}
+		items = append(items, c)
+	}
+	return items, rows.Err()
+}
+
+// ModelComparison summarises how one target served a model over a time window.
+type ModelComparison struct {
+	TargetID       int      `json:"target_id"`
+	TargetName     string   `json:"target_name"`
+	Enabled        bool     `json:"enabled"`
+	LatestSuccess  bool     `json:"latest_success"`
+	LatestAt       *float64 `json:"latest_at"`
+	LatestError    *string  `json:"latest_error"`
+	LatestStatus   *int     `json:"latest_status_code"`
+	Total          int      `json:"total"`
+	Success        int      `json:"success"`
+	UptimePct      float64  `json:"uptime_pct"`
+	MedianDuration *float64 `json:"median_duration"`
+}
+
+// CompareModel returns one entry per target that probed model within [since, until).
+// Uptime is the share of successful checks; the median only counts successful checks.
+func (d *Database) CompareModel(model string, since, until float64) ([]ModelComparison, error) {
+	rows, err := d.read.Query(`
+		WITH win AS (
+			SELECT id, target_id, success, duration, timestamp, error, status_code
+			FROM run_models
+			WHERE model = ? AND timestamp >= ? AND timestamp < ?
+		),
+		latest AS (
+			SELECT target_id, success, timestamp, error, status_code,
+				ROW_NUMBER() OVER (PARTITION BY target_id ORDER BY timestamp DESC, id DESC) AS rn
+			FROM win
+		),
+		ok AS (
+			SELECT target_id, duration,
+				ROW_NUMBER() OVER (PARTITION BY target_id ORDER BY duration) AS rn,
+				COUNT(*) OVER (PARTITION BY target_id) AS cnt
+			FROM win
+			WHERE success = 1
+		),
+		med AS (
+			SELECT target_id, AVG(duration) AS median
+			FROM ok
+			WHERE rn IN ((cnt + 1) / 2, (cnt + 2) / 2)
+			GROUP BY target_id
+		),
+		agg AS (
+			SELECT target_id, COUNT(*) AS total, SUM(success) AS success
+			FROM win
+			GROUP BY target_id
+		)
+		SELECT t.id, t.name, t.enabled, l.success, l.timestamp, l.error, l.status_code,
+			a.total, a.success, m.median
+		FROM agg a
+		JOIN targets t ON t.id = a.target_id AND t.deleted_at IS NULL
+		JOIN latest l ON l.target_id = a.target_id AND l.rn = 1
+		LEFT JOIN med m ON m.target_id = a.target_id`, model, since, until)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []ModelComparison{}
+	for rows.Next() {
+		var c ModelComparison
+		var enabled, latestSuccess int
+		if err := rows.Scan(&c.TargetID, &c.TargetName, &enabled, &latestSuccess, &c.LatestAt,
+			&c.LatestError, &c.LatestStatus, &c.Total, &c.Success, &c.MedianDuration); err != nil {
+			return nil, err
+		}
+		c.Enabled = enabled != 0
+		c.LatestSuccess = latestSuccess != 0
+		c.UptimePct = percentRate(c.Success, c.Total)
+		if c.MedianDuration != nil {
+			median := math.Round(*c.MedianDuration*1000.0) / 1000.0
+			c.MedianDuration = &median
+		}
+		items = append(items, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	// Best channel first: higher uptime, then lower median latency.
+	sort.SliceStable(items, func(i, j int) bool {
+		if items[i].UptimePct != items[j].UptimePct {
+			return items[i].UptimePct > items[j].UptimePct
+		}
+		mi, mj := items[i].MedianDuration, items[j].MedianDuration
+		if mi == nil || mj == nil {
+			return mi != nil
+		}
+		return *mi < *mj
+	})
+	return items, nil
+}
+
+// percentRate returns success/total as a percentage rounded to one decimal.
+func percentRate(success, total int) float64 {
+	if total <= 0 {
+		return 0
+	}
+	return math.Round(float64(success)*1000.0/float64(total)) / 10.0
+}
+
+// Timeseries -- GET /api/analytics/timeseries?bucket=hour|day&hours=24&target_id=1&by_model=1
+func (h *Handlers) Timeseries(w http.ResponseWriter, r *http.Request) {
+	bucket := r.URL.Query().Get("bucket")
+	if bucket == "" {
+		bucket = "hour"
+	}
+	bucketSeconds, ok := analyticsBuckets[bucket]
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "bucket must be hour or day"})
+		return
+	}
+	defaultHours := 24
+	if bucket == "day" {
+		defaultHours = 24 * 30
+	}
+	hours := queryInt(r, "hours", defaultHours, 1, analyticsMaxHours[bucket])
+
+	q := TimeseriesQuery{BucketSeconds: bucketSeconds}
+	if s := r.URL.Query().Get("target_id"); s != "" {
+		id, err := strconv.Atoi(s)
+		if err != nil || id < 1 {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "invalid target_id"})
+			return
+		}
+		q.TargetID = &id
+	}
+	switch r.URL.Query().Get("by_model") {
+	case "1", "true":
+		q.ByModel = true
+	}
+	now := float64(time.Now().UnixMilli()) / 1000.0
+	q.Until = now
+	// Align the window start to a bucket boundary so the first bucket is complete.
+	q.Since = math.Floor((now-float64(hours*3600))/float64(bucketSeconds)) * float64(bucketSeconds)
+
+	items, err := h.db.GetTimeseries(q)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"bucket": bucket,
+		"since":  q.Since,
+		"until":  q.Until,
+		"items":  items,
+	})
+}
+
+// queryTimeRange reads since/until unix-second parameters; since defaults to
+// until minus defaultHours.
+func queryTimeRange(r *http.Request, defaultHours int) (float64, float64, bool) {
+	until := float64(time.Now().UnixMilli()) / 1000.0
+	if s := r.URL.Query().Get("until"); s != "" {
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil || v <= 0 || math.IsInf(v, 0) {
+			return 0, 0, false
+		}
+		until = v
+	}
+	since := until - float64(defaultHours*3600)
+	if s := r.URL.Query().Get("since"); s != "" {
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil || v < 0 || v >= until {
+			return 0, 0, false
+		}
+		since = v
+	}
+	return since, until, true
+}
+
+// ModelHistory -- GET /api/targets/{id}/models/{model}/history?since=&until=&bucket=raw|hour|day&limit=500
+// Model names containing "/" must be sent URL-escaped (%2F).
+func (h *Handlers) ModelHistory(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(r)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "invalid id"})
+		return
+	}
+	model := r.PathValue("model")
+	if model == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "model is required"})
+		return
+	}
+	target, err := h.db.GetTarget(id)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return
+	}
+	if target == nil {
+		writeJSON(w, http.StatusNotFound, map[string]any{"detail": "target not found"})
+		return
+	}
+	since, until, ok := queryTimeRange(r, 24)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "since/until must be unix timestamps with since < until"})
+		return
+	}
+
+	bucket := r.URL.Query().Get("bucket")
+	if bucket == "" {
+		bucket = "raw"
+	}
+	result := map[string]any{
+		"target_id": id,
+		"model":     model,
+		"bucket":    bucket,
+		"since":     since,
+		"until":     until,
+	}
+	if bucket == "raw" {
+		limit := queryInt(r, "limit", 500, 1, 5000)
+		points, err := h.db.GetModelHistory(id, model, since, until, limit)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+			return
+		}
+		result["items"] = points
+		writeJSON(w, http.StatusOK, result)
+		return
+	}
+	bucketSeconds, ok := analyticsBuckets[bucket]
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "bucket must be raw, hour or day"})
+		return
+	}
+	if until-since > float64(analyticsMaxHours[bucket]*3600) {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "time range too large for bucket " + bucket})
+		return
+	}
+	points, err := h.db.GetTimeseries(TimeseriesQuery{
+		BucketSeconds: bucketSeconds,
+		Since:         since,
+		Until:         until,
+		TargetID:      &id,
+		Model:         &model,
+		ByModel:       true,
+	})
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return
+	}
+	result["items"] = points
+	writeJSON(w, http.StatusOK, result)
+}
+
+// StatusCodes -- GET /api/targets/{id}/analytics/status-codes?since=&until=&model=&by_model=1&bucket=hour|day
+// Counts the target's detections by HTTP status code (default last 24 hours); totals
+// is keyed by status code, with "none" for detections that got no response.
+func (h *Handlers) StatusCodes(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(r)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "invalid id"})
+		return
+	}
+	target, err := h.db.GetTarget(id)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return
+	}
+	if target == nil {
+		writeJSON(w, http.StatusNotFound, map[string]any{"detail": "target not found"})
+		return
+	}
+	since, until, ok := queryTimeRange(r, 24)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "since/until must be unix timestamps with since < until"})
+		return
+	}
+	q := StatusCodeQuery{TargetID: id, Since: since, Until: until, ByModel: queryFlag(r, "by_model")}
+	if model := r.URL.Query().Get("model"); model != "" {
+		q.Model = &model
+	}
+	bucket := r.URL.Query().Get("bucket")
+	if bucket != "" {
+		bucketSeconds, ok := analyticsBuckets[bucket]
+		if !ok {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "bucket must be hour or day"})
+			return
+		}
+		if until-since > float64(analyticsMaxHours[bucket]*3600) {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "time range too large for bucket " + bucket})
+			return
+		}
+		q.BucketSeconds = bucketSeconds
+	}
+
+	items, err := h.db.GetStatusCodes(q)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return
+	}
+	totals := map[string]int{}
+	for _, item := range items {
+		key := "none"
+		if item.StatusCode != nil {
+			key = strconv.Itoa(*item.StatusCode)
+		}
+		totals[key] += item.Count
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"target_id": id,
+		"since":     since,
+		"until":     until,
+		"totals":    totals,
+		"items":     items,
+	})
+}
+
+// CompareModel -- GET /api/analytics/models/{model}/compare?since=&until=
+// Ranks every target that served the model in the window (default last 24 hours).
+func (h *Handlers) CompareModel(w http.ResponseWriter, r *http.Request) {
+	model := r.PathValue("model")
+	if model == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "model is required"})
+		return
+	}
+	since, until, ok := queryTimeRange(r, 24)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "since/until must be unix timestamps with since < until"})
+		return
+	}
+	items, err := h.db.CompareModel(model, since, until)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return
+	}
+	visible := items[:0]
+	for _, item := range items {
+		if principalAllowsTarget(r, item.TargetID) {
+			visible = append(visible, item)
+		}
+	}
+	items = visible
+	writeJSON(w, http.StatusOK, map[string]any{
+		"model": model,
+		"since": since,
+		"until": until,
+		"items": items,
+	})
+}
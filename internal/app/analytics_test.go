@@ -0,0 +1,226 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newAnalyticsTestDB(t *testing.T) (*Database, *Target) {
+	t.Helper()
+	db, err := NewDatabase(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDatabase failed: %v", err)
+	}
+	t.Cleanup(func() { _ = db.conn.Close() })
+	target, err := db.CreateTarget(map[string]any{"name": "t1", "base_url": "https://example.com", "api_key": "k"})
+	if err != nil {
+		t.Fatalf("CreateTarget failed: %v", err)
+	}
+	return db, target
+}
+
+func insertAnalyticsRows(t *testing.T, db *Database, targetID int, rows []DetectionResult) {
+	t.Helper()
+	runID, err := db.CreateRun(targetID, rows[0].Timestamp, "", nil, runModeFull)
+	if err != nil {
+		t.Fatalf("CreateRun failed: %v", err)
+	}
+	if err := db.InsertModelRows(runID, targetID, rows); err != nil {
+		t.Fatalf("InsertModelRows failed: %v", err)
+	}
+}
+
+func TestGetTimeseries_BucketsByHour(t *testing.T) {
+	db, target := newAnalyticsTestDB(t)
+	base := 1_700_000_000.0 - float64(int64(1_700_000_000)%3600)
+	insertAnalyticsRows(t, db, target.ID, []DetectionResult{
+		{Model: "gpt-a", Success: true, Duration: 1.0, Timestamp: base + 10},
+		{Model: "gpt-b", Success: false, Duration: 30.0, Timestamp: base + 20},
+		{Model: "gpt-a", Success: true, Duration: 3.0, Timestamp: base + 3600 + 5},
+	})
+
+	points, err := db.GetTimeseries(TimeseriesQuery{BucketSeconds: 3600, Since: base, Until: base + 7200})
+	if err != nil {
+		t.Fatalf("GetTimeseries failed: %v", err)
+	}
+	if len(points) != 2 {
+		t.Fatalf("expected two hourly buckets, got=%+v", points)
+	}
+	first := points[0]
+	if first.BucketStart != base || first.Total != 2 || first.Success != 1 || first.SuccessRate != 50 {
+		t.Fatalf("first bucket should aggregate both rows, got=%+v", first)
+	}
+	if first.AvgDuration == nil || *first.AvgDuration != 1.0 {
+		t.Fatalf("avg_duration should only count successful checks, got=%v", first.AvgDuration)
+	}
+
+	byModel, err := db.GetTimeseries(TimeseriesQuery{BucketSeconds: 86400, Since: base - 86400, Until: base + 86400, ByModel: true})
+	if err != nil {
+		t.Fatalf("GetTimeseries by model failed: %v", err)
+	}
+	models := map[string]int{}
+	for _, p := range byModel {
+		models[p.Model] += p.Total
+	}
+	if models["gpt-a"] != 2 || models["gpt-b"] != 1 {
+		t.Fatalf("by_model should split rows per model, got=%v", models)
+	}
+}
+
+func TestModelHistory_ReturnsRangeForEscapedModel(t *testing.T) {
+	db, target := newAnalyticsTestDB(t)
+	base := 1_700_000_000.0
+	insertAnalyticsRows(t, db, target.ID, []DetectionResult{
+		{Model: "org/gpt-a", Success: true, Duration: 1.0, Timestamp: base},
+		{Model: "org/gpt-a", Success: false, Duration: 2.0, Timestamp: base + 60},
+		{Model: "org/gpt-a", Success: true, Duration: 3.0, Timestamp: base + 7200},
+		{Model: "gpt-b", Success: true, Duration: 1.0, Timestamp: base + 30},
+	})
+	mux := http.NewServeMux()
+	h := &Handlers{db: db}
+	mux.HandleFunc("GET /api/targets/{id}/models/{model}/history", h.ModelHistory)
+
+	url := fmt.Sprintf("/api/targets/%d/models/org%%2Fgpt-a/history?since=%d&until=%d", target.ID, int64(base), int64(base+3600))
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, url, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("history should succeed, got=%d body=%s", rec.Code, rec.Body.String())
+	}
+	var resp struct {
+		Model string              `json:"model"`
+		Items []ModelHistoryPoint `json:"items"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	if resp.Model != "org/gpt-a" || len(resp.Items) != 2 || !resp.Items[0].Success || resp.Items[1].Success {
+		t.Fatalf("history should return the model's points in range oldest first, got=%+v", resp)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, url+"&bucket=week", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("unknown bucket should be rejected, got=%d", rec.Code)
+	}
+}
+
+func TestCompareModel_RanksTargets(t *testing.T) {
+	db, slow := newAnalyticsTestDB(t)
+	fast, err := db.CreateTarget(map[string]any{"name": "t2", "base_url": "https://example.org", "api_key": "k"})
+	if err != nil {
+		t.Fatalf("CreateTarget failed: %v", err)
+	}
+	base := 1_700_000_000.0
+	insertAnalyticsRows(t, db, slow.ID, []DetectionResult{
+		{Model: "gpt-4o", Success: true, Duration: 4.0, Timestamp: base},
+		{Model: "gpt-4o", Success: true, Duration: 2.0, Timestamp: base + 60},
+		{Model: "gpt-4o", Success: false, Duration: 30.0, Timestamp: base + 120},
+	})
+	insertAnalyticsRows(t, db, fast.ID, []DetectionResult{
+		{Model: "gpt-4o", Success: true, Duration: 1.0, Timestamp: base},
+		{Model: "gpt-4o", Success: true, Duration: 3.0, Timestamp: base + 60},
+		{Model: "other", Success: true, Duration: 1.0, Timestamp: base},
+	})
+
+	items, err := db.CompareModel("gpt-4o", base-1, base+3600)
+	if err != nil {
+		t.Fatalf("CompareModel failed: %v", err)
+	}
+	if len(items) != 2 || items[0].TargetID != fast.ID {
+		t.Fatalf("fully healthy target should rank first, got=%+v", items)
+	}
+	if items[0].UptimePct != 100 || items[0].MedianDuration == nil || *items[0].MedianDuration != 2.0 {
+		t.Fatalf("unexpected stats for first target, got=%+v", items[0])
+	}
+	second := items[1]
+	if second.LatestSuccess || second.UptimePct != 66.7 || second.MedianDuration == nil || *second.MedianDuration != 3.0 {
+		t.Fatalf("unexpected stats for second target, got=%+v", second)
+	}
+}
+
+func TestStatusCodesCountsByCode(t *testing.T) {
+	db, target := newAnalyticsTestDB(t)
+	ok, limited := 200, 429
+	insertAnalyticsRows(t, db, target.ID, []DetectionResult{
+		{Model: "a", Success: true, Timestamp: 3600, StatusCode: &ok},
+		{Model: "a", Success: false, Timestamp: 3601, StatusCode: &limited},
+		{Model: "b", Success: false, Timestamp: 7300, StatusCode: &limited},
+		{Model: "b", Success: false, Timestamp: 7301},
+	})
+	h := &Handlers{db: db}
+	get := func(query string) map[string]any {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/targets/%d/analytics/status-codes?since=0&until=10000%s", target.ID, query), nil)
+		req.SetPathValue("id", fmt.Sprint(target.ID))
+		rr := httptest.NewRecorder()
+		h.StatusCodes(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("status codes should succeed, got=%d body=%s", rr.Code, rr.Body.String())
+		}
+		var out map[string]any
+		_ = json.Unmarshal(rr.Body.Bytes(), &out)
+		return out
+	}
+
+	out := get("")
+	totals, _ := out["totals"].(map[string]any)
+	if totals["200"] != float64(1) || totals["429"] != float64(2) || totals["none"] != float64(1) {
+		t.Fatalf("totals should be keyed by status code, got=%v", totals)
+	}
+	if items, _ := out["items"].([]any); len(items) != 3 {
+		t.Fatalf("whole-window counts should have one item per code, got=%v", items)
+	}
+	out = get("&model=b&bucket=hour")
+	items, _ := out["items"].([]any)
+	if len(items) != 2 || items[0].(map[string]any)["bucket_start"] != float64(7200) {
+		t.Fatalf("model filter and buckets should apply, got=%v", items)
+	}
+	out = get("&by_model=1")
+	if items, _ := out["items"].([]any); len(items) != 4 || items[0].(map[string]any)["model"] != "a" {
+		t.Fatalf("by_model should split counts per model, got=%v", items)
+	}
+}
+
+func TestDailyRollupsBackDayBuckets(t *testing.T) {
+	db, target := newAnalyticsTestDB(t)
+	day := 1_700_000_000.0 - float64(int64(1_700_000_000)%rollupDaySeconds)
+	insertAnalyticsRows(t, db, target.ID, []DetectionResult{
+		{Model: "m", Success: true, Duration: 1, Timestamp: day + 10},
+		{Model: "m", Success: true, Duration: 3, Timestamp: day + 20},
+		{Model: "m", Success: false, Duration: 9, Timestamp: day + 30},
+		{Model: "m", Success: true, Duration: 2, Timestamp: day + rollupDaySeconds + 10},
+	})
+	// Only the first day is over at this point.
+	now := time.Unix(int64(day)+rollupDaySeconds+3600, 0)
+	days, err := db.RollupFinishedDays(now)
+	if err != nil || days != 1 {
+		t.Fatalf("one finished day should be rolled up, got days=%d err=%v", days, err)
+	}
+	stats, err := db.ListDailyStats(day, day+2*rollupDaySeconds, &target.ID, nil)
+	if err != nil || len(stats) != 1 {
+		t.Fatalf("ListDailyStats should return the rolled-up day, got=%+v err=%v", stats, err)
+	}
+	if s := stats[0]; s.Total != 3 || s.Success != 2 || *s.AvgDuration != 2 || *s.P95Duration != 3 {
+		t.Fatalf("rollup should count checks and aggregate successful latencies, got=%+v", s)
+	}
+
+	// Raw rows of the rolled-up day can go; day buckets still cover it.
+	if _, err := db.conn.Exec(`DELETE FROM run_models WHERE timestamp < ?`, day+rollupDaySeconds); err != nil {
+		t.Fatalf("delete raw rows failed: %v", err)
+	}
+	points, err := db.GetTimeseries(TimeseriesQuery{BucketSeconds: rollupDaySeconds, Since: day, Until: day + 2*rollupDaySeconds})
+	if err != nil {
+		t.Fatalf("GetTimeseries failed: %v", err)
+	}
+	if len(points) != 2 || points[0].BucketStart != day || points[0].Total != 3 || points[1].Total != 1 {
+		t.Fatalf("day buckets should combine rollups and raw rows, got=%+v", points)
+	}
+	if days, err := db.RollupFinishedDays(now); err != nil || days != 0 {
+		t.Fatalf("rolled-up days should not be rolled up again, got days=%d err=%v", days, err)
+	}
+}
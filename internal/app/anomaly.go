@@ -0,0 +1,147 @@
+package app
+
+import (
+	"encoding/json"
+	"math"
+)
+
+const (
+	// latencyBaselineWindow is how many recent successful checks form a model's baseline.
+	latencyBaselineWindow = 50
+	// latencyBaselineMinSamples is the baseline size needed before anomalies are reported.
+	latencyBaselineMinSamples = 10
+	// latencyAnomalyMinDelta ignores regressions smaller than this many seconds, so
+	// very stable fast models are not flagged for jitter.
+	latencyAnomalyMinDelta = 0.5
+)
+
+// latencyBaseline summarises the recent successful durations of one model route.
+type latencyBaseline struct {
+	Count int
+	Mean  float64
+	Std   float64
+}
+
+func latencyBaselineKey(model, endpoint string) string {
+	return model + "\x00" + endpoint
+}
+
+// LatencyBaselines returns the mean and standard deviation of the last window
+// successful durations per model and endpoint of a target.
+func (d *Database) LatencyBaselines(targetID, window int) (map[string]latencyBaseline, error) {
+	rows, err := d.read.Query(`
+		WITH ranked AS (
+			SELECT model, COALESCE(endpoint, '') AS endpoint, duration,
+				ROW_NUMBER() OVER (
+					PARTITION BY model, COALESCE(endpoint, '')
+					ORDER BY COALESCE(timestamp, 0) DESC, id DESC
+				) AS rn
+			FROM run_models
+			WHERE target_id = ? AND success = 1 AND duration IS NOT NULL
+		)
+		SELECT model, endpoint, COUNT(*), AVG(duration), AVG(duration * duration)
+		FROM ranked
+		WHERE rn <= ?
+		GROUP BY model, endpoint`, targetID, window)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := map[string]latencyBaseline{}
+	for rows.Next() {
+		var model, endpoint string
+		var b latencyBaseline
+		var meanSq float64
+		if err := rows.Scan(&model, &endpoint, &b.Count, &b.Mean, &meanSq); err != nil {
+			return nil, err
+		}
+		b.Std = math.Sqrt(math.Max(meanSq-b.Mean*b.Mean, 0))
+		result[latencyBaselineKey(model, endpoint)] = b
+	}
+	return result, rows.Err()
+}
+
+// isLatencyAnomaly reports whether duration regressed beyond sigma standard deviations
+// or pct percent over the baseline. A zero threshold disables that check.
+func isLatencyAnomaly(duration float64, b latencyBaseline, sigma, pct int) bool {
+	if b.Count < latencyBaselineMinSamples || duration-b.Mean < latencyAnomalyMinDelta {
+		return false
+	}
+	if sigma > 0 && duration > b.Mean+float64(sigma)*b.Std {
+		return true
+	}
+	if pct > 0 && duration > b.Mean*(1+float64(pct)/100.0) {
+		return true
+	}
+	return false
+}
+
+// UpdateLatencyAnomalyConfig updates the anomaly thresholds at runtime.
+func (ms *MonitorService) UpdateLatencyAnomalyConfig(sigma, pct int) {
+	if sigma < 0 {
+		sigma = 0
+	}
+	if pct < 0 {
+		pct = 0
+	}
+	ms.mu.Lock()
+	ms.latencyAnomalySigma = sigma
+	ms.latencyAnomalyPct = pct
+	ms.mu.Unlock()
+}
+
+// LatencyAnomalyConfig returns the current sigma and percent thresholds.
+func (ms *MonitorService) LatencyAnomalyConfig() (int, int) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	return ms.latencyAnomalySigma, ms.latencyAnomalyPct
+}
+
+// flagLatencyAnomalies marks successful rows that are slow compared with the model's
+// baseline (computed before these rows are stored) and emits a latency_anomaly event.
+// Slow rows stay successful; the target status is not changed.
+func (ms *MonitorService) flagLatencyAnomalies(target *Target, rows []DetectionResult) {
+	for i := range rows {
+		rows[i].Slow = false
+	}
+	sigma, pct := ms.LatencyAnomalyConfig()
+	if (sigma <= 0 && pct <= 0) || len(rows) == 0 {
+		return
+	}
+	baselines, err := ms.db.LatencyBaselines(target.ID, latencyBaselineWindow)
+	if err != nil {
+		ms.logger().Error("load latency baseline failed", "target", target.Name, "target_id", target.ID, "error", err)
+		return
+	}
+
+	var slow []map[string]any
+	for i := range rows {
+		row := &rows[i]
+		if !row.Success {
+			continue
+		}
+		b, ok := baselines[latencyBaselineKey(row.Model, row.Endpoint)]
+		if !ok || !isLatencyAnomaly(row.Duration, b, sigma, pct) {
+			continue
+		}
+		row.Slow = true
+		slow = append(slow, map[string]any{
+			"model":         row.Model,
+			"endpoint":      row.Endpoint,
+			"duration":      row.Duration,
+			"baseline_mean": math.Round(b.Mean*1000.0) / 1000.0,
+			"baseline_std":  math.Round(b.Std*1000.0) / 1000.0,
+		})
+	}
+	if len(slow) == 0 {
+		return
+	}
+	ms.logger().Warn("latency anomaly", "target", target.Name, "target_id", target.ID, "models", len(slow))
+	data, _ := json.Marshal(map[string]any{
+		"target_id":   target.ID,
+		"target_name": target.Name,
+		"models":      slow,
+	})
+	ms.emitEvent("latency_anomaly", string(data))
+}
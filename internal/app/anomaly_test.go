@@ -0,0 +1,76 @@
+package app
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestIsLatencyAnomaly_Thresholds(t *testing.T) {
+	b := latencyBaseline{Count: 20, Mean: 2.0, Std: 0.2}
+	if !isLatencyAnomaly(3.0, b, 3, 0) {
+		t.Fatalf("duration beyond 3 sigma should be flagged")
+	}
+	if isLatencyAnomaly(2.3, b, 3, 0) {
+		t.Fatalf("duration within 3 sigma should not be flagged")
+	}
+	if !isLatencyAnomaly(4.5, b, 0, 100) {
+		t.Fatalf("duration beyond +100%% should be flagged")
+	}
+	if isLatencyAnomaly(3.0, b, 0, 0) {
+		t.Fatalf("disabled thresholds should not flag")
+	}
+	if isLatencyAnomaly(3.0, latencyBaseline{Count: 3, Mean: 2.0, Std: 0.2}, 3, 0) {
+		t.Fatalf("small baselines should not flag")
+	}
+	if isLatencyAnomaly(0.4, latencyBaseline{Count: 20, Mean: 0.1, Std: 0.01}, 3, 0) {
+		t.Fatalf("regressions below the minimum delta should not flag")
+	}
+}
+
+func TestFlagLatencyAnomalies_MarksSlowRows(t *testing.T) {
+	db, err := NewDatabase(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDatabase failed: %v", err)
+	}
+	t.Cleanup(func() { _ = db.conn.Close() })
+	ms := NewMonitorService(MonitorConfig{DB: db, LogDir: t.TempDir(), LatencyAnomalySigma: 3})
+	target, err := db.CreateTarget(map[string]any{"name": "t1", "base_url": "https://example.com", "api_key": "k"})
+	if err != nil {
+		t.Fatalf("CreateTarget failed: %v", err)
+	}
+
+	var history []DetectionResult
+	for i := 0; i < 20; i++ {
+		history = append(history,
+			DetectionResult{Model: "gpt-a", Success: true, Duration: 1.0 + float64(i%2)*0.1, Timestamp: float64(1000 + i)},
+			DetectionResult{Model: "gpt-b", Success: true, Duration: 1.0 + float64(i%2)*0.1, Timestamp: float64(1000 + i)},
+		)
+	}
+	runID, _ := db.CreateRun(target.ID, 1000, "", nil, runModeFull)
+	if err := db.InsertModelRows(runID, target.ID, history); err != nil {
+		t.Fatalf("InsertModelRows failed: %v", err)
+	}
+
+	var events []string
+	ms.SetEventCallback(func(eventType, data string) { events = append(events, eventType) })
+	rows := []DetectionResult{
+		{Model: "gpt-a", Success: true, Duration: 5.0, Timestamp: 2000},
+		{Model: "gpt-b", Success: true, Duration: 1.1, Timestamp: 2000},
+	}
+	ms.flagLatencyAnomalies(target, rows)
+	if !rows[0].Slow || rows[1].Slow {
+		t.Fatalf("only the regressed model should be slow, got=%v/%v", rows[0].Slow, rows[1].Slow)
+	}
+	if len(events) != 1 || events[0] != "latency_anomaly" {
+		t.Fatalf("a latency_anomaly event should be emitted, got=%v", events)
+	}
+
+	runID, _ = db.CreateRun(target.ID, 2000, "", nil, runModeFull)
+	if err := db.InsertModelRows(runID, target.ID, rows); err != nil {
+		t.Fatalf("InsertModelRows failed: %v", err)
+	}
+	latest, err := db.GetLatestModelStatusesBatch([]int{target.ID})
+	if err != nil || len(latest[target.ID]) != 2 || !latest[target.ID][0].Slow {
+		t.Fatalf("latest status should expose the slow flag, got=%+v err=%v", latest[target.ID], err)
+	}
+}
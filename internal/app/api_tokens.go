@@ -0,0 +1,492 @@
+package app
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// apiTokenPrefix marks scoped API tokens so unknown ones are rejected instead of
+// falling through to anonymous visitor access.
+const apiTokenPrefix = "amt-"
+
+// apiTokenTouchInterval throttles last_used_at writes for busy tokens.
+const apiTokenTouchInterval = time.Minute
+
+var errAPITokenInvalid = errors.New("invalid, expired or revoked api token")
+
+// apiTokenTargetListPaths answer GET requests with per-target data that the handler
+// filters to the token's targets, so target-limited tokens may read them without
+// target_id. Incident and model comparison routes are filtered the same way. Other
+// methods on these paths (POST /api/targets) still need target_id.
+var apiTokenTargetListPaths = map[string]bool{
+	"/api/dashboard":     true,
+	"/api/targets":       true,
+	"/api/targets/trash": true,
+	"/api/incidents":     true,
+}
+
+// APIToken is an additional API credential limited by scopes.
+type APIToken struct {
+	ID          int    `json:"id"`
+	Name        string `json:"name"`
+	TokenPrefix string `json:"token_prefix"`
+	// ReadOnly tokens act as visitors and may only send GET requests; others act as admin.
+	ReadOnly bool `json:"read_only"`
+	// TargetIDs limits the token to these targets; empty allows all.
+	TargetIDs []int `json:"target_ids"`
+	// Endpoints limits the token to these API path prefixes; empty allows all.
+	Endpoints   []string `json:"endpoints"`
+	Description string   `json:"description"`
+	CreatedAt   float64  `json:"created_at"`
+	ExpiresAt   *float64 `json:"expires_at"`
+	RevokedAt   *float64 `json:"revoked_at"`
+	LastUsedAt  *float64 `json:"last_used_at"`
+}
+
+type createAPITokenRequest struct {
+	Name          string   `json:"name"`
+	ReadOnly      *bool    `json:"read_only"`
+	TargetIDs     []int    `json:"target_ids"`
+	Endpoints     []string `json:"endpoints"`
+	Description   string   `json:"description"`
+	ExpiresInDays int      `json:"expires_in_days"`
+}
+
+// ---------------------------------------------------------------------------
+// Storage
+// ---------------------------------------------------------------------------
+
+func (d *Database) EnsureAPITokenSchema() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	_, err := d.conn.Exec(`
+		CREATE TABLE IF NOT EXISTS api_tokens (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL UNIQUE,
+			token_hash TEXT NOT NULL UNIQUE,
+			token_prefix TEXT NOT NULL,
+			read_only INTEGER NOT NULL DEFAULT 1,
+			target_ids TEXT NOT NULL DEFAULT '[]',
+			endpoints TEXT NOT NULL DEFAULT '[]',
+			description TEXT NOT NULL DEFAULT '',
+			created_at REAL NOT NULL,
+			expires_at REAL,
+			revoked_at REAL,
+			last_used_at REAL
+		);
+	`)
+	if err != nil {
+		return fmt.Errorf("init api token schema: %w", err)
+	}
+	return nil
+}
+
+const apiTokenColumns = `id, name, token_prefix, read_only, target_ids, endpoints, description,
+	created_at, expires_at, revoked_at, last_used_at`
+
+func scanAPIToken(r interface{ Scan(dest ...any) error }) (*APIToken, error) {
+	var (
+		t             APIToken
+		readOnlyInt   int
+		targetIDsJSON string
+		endpointsJSON string
+	)
+	if err := r.Scan(
+		&t.ID, &t.Name, &t.TokenPrefix, &readOnlyInt, &targetIDsJSON, &endpointsJSON, &t.Description,
+		&t.CreatedAt, &t.ExpiresAt, &t.RevokedAt, &t.LastUsedAt,
+	); err != nil {
+		return nil, err
+	}
+	t.ReadOnly = readOnlyInt != 0
+	if err := json.Unmarshal([]byte(targetIDsJSON), &t.TargetIDs); err != nil {
+		return nil, fmt.Errorf("decode target_ids: %w", err)
+	}
+	if err := json.Unmarshal([]byte(endpointsJSON), &t.Endpoints); err != nil {
+		return nil, fmt.Errorf("decode endpoints: %w", err)
+	}
+	if t.TargetIDs == nil {
+		t.TargetIDs = []int{}
+	}
+	if t.Endpoints == nil {
+		t.Endpoints = []string{}
+	}
+	return &t, nil
+}
+
+// GetAPIToken returns a token by id, or nil when it does not exist.
+func (d *Database) GetAPIToken(id int) (*APIToken, error) {
+	t, err := scanAPIToken(d.read.QueryRow("SELECT "+apiTokenColumns+" FROM api_tokens WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return t, err
+}
+
+func (d *Database) CreateAPIToken(spec APIToken) (*APIToken, string, error) {
+	name := strings.TrimSpace(spec.Name)
+	if name == "" {
+		return nil, "", fmt.Errorf("name is required")
+	}
+	targetsJSON, _ := json.Marshal(normalizeProxyAllowedTargets(spec.TargetIDs))
+	endpointsJSON, _ := json.Marshal(normalizeAPITokenEndpoints(spec.Endpoints))
+	now := float64(time.Now().UnixMilli()) / 1000.0
+
+	for i := 0; i < 5; i++ {
+		raw, err := generateProxyToken()
+		if err != nil {
+			return nil, "", err
+		}
+		token := apiTokenPrefix + strings.TrimPrefix(raw, "sk-")
+		prefix := token[:12]
+
+		d.mu.Lock()
+		res, err := d.conn.Exec(`
+			INSERT INTO api_tokens (name, token_hash, token_prefix, read_only, target_ids, endpoints, description, created_at, expires_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			name, proxyKeyHash(token), prefix, boolToInt(spec.ReadOnly), string(targetsJSON), string(endpointsJSON),
+			spec.Description, now, spec.ExpiresAt,
+		)
+		d.mu.Unlock()
+		if err != nil {
+			msg := strings.ToLower(err.Error())
+			if strings.Contains(msg, "api_tokens.name") {
+				return nil, "", fmt.Errorf("api token name already exists")
+			}
+			if strings.Contains(msg, "unique") {
+				continue
+			}
+			return nil, "", err
+		}
+
+		id64, _ := res.LastInsertId()
+		created, err := d.GetAPIToken(int(id64))
+		if err != nil {
+			return nil, "", err
+		}
+		if created == nil {
+			return nil, "", fmt.Errorf("api token created but not found")
+		}
+		return created, token, nil
+	}
+	return nil, "", fmt.Errorf("failed to create unique api token")
+}
+
+func (d *Database) ListAPITokens() ([]APIToken, error) {
+	rows, err := d.read.Query("SELECT " + apiTokenColumns + " FROM api_tokens ORDER BY created_at DESC, id DESC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]APIToken, 0)
+	for rows.Next() {
+		t, err := scanAPIToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *t)
+	}
+	return out, rows.Err()
+}
+
+func (d *Database) RevokeAPIToken(id int) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	res, err := d.conn.Exec(
+		"UPDATE api_tokens SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL",
+		float64(time.Now().UnixMilli())/1000.0, id,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// GetActiveAPITokenByToken returns the unrevoked, unexpired token matching the plain value.
+func (d *Database) GetActiveAPITokenByToken(token string, now float64) (*APIToken, error) {
+	t, err := scanAPIToken(d.read.QueryRow(
+		"SELECT "+apiTokenColumns+" FROM api_tokens WHERE token_hash = ? AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?) LIMIT 1",
+		proxyKeyHash(token), now,
+	))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return t, err
+}
+
+func (d *Database) TouchAPIToken(id int, now float64) error {
+	d.mu.Lock()
+	_, err := d.conn.Exec("UPDATE api_tokens SET last_used_at = ? WHERE id = ?", now, id)
+	d.mu.Unlock()
+	return err
+}
+
+func normalizeAPITokenEndpoints(endpoints []string) []string {
+	out := normalizeProxyAllowedModels(endpoints)
+	for i := range out {
+		if len(out[i]) > 1 {
+			out[i] = strings.TrimRight(out[i], "/")
+		}
+	}
+	return out
+}
+
+// ---------------------------------------------------------------------------
+// Scope checks
+// ---------------------------------------------------------------------------
+
+func (t *APIToken) allowsTarget(id int) bool {
+	if len(t.TargetIDs) == 0 {
+		return true
+	}
+	for _, allowed := range t.TargetIDs {
+		if allowed == id {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *APIToken) allowsPath(path string) bool {
+	if len(t.Endpoints) == 0 {
+		return true
+	}
+	for _, prefix := range t.Endpoints {
+		if path == prefix || strings.HasPrefix(path, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// scopeError returns why the token may not make this request, or "" when it may.
+func (t *APIToken) scopeError(r *http.Request) string {
+	if t.ReadOnly && r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return "api token is read-only"
+	}
+	if !t.allowsPath(r.URL.Path) {
+		return "api token is not allowed to access this endpoint"
+	}
+	if len(t.TargetIDs) == 0 {
+		return ""
+	}
+
+	checked := false
+	if strings.HasPrefix(r.URL.Path, "/api/targets/") {
+		if id, err := strconv.Atoi(r.PathValue("id")); err == nil {
+			if !t.allowsTarget(id) {
+				return "api token is not allowed to access this target"
+			}
+			checked = true
+		}
+	}
+	if s := r.URL.Query().Get("target_id"); s != "" {
+		id, err := strconv.Atoi(s)
+		if err != nil || !t.allowsTarget(id) {
+			return "api token is not allowed to access this target"
+		}
+		checked = true
+	}
+	listPath := apiTokenTargetListPaths[r.URL.Path] && (r.Method == http.MethodGet || r.Method == http.MethodHead)
+	if !checked && !listPath &&
+		!strings.HasPrefix(r.URL.Path, "/api/incidents/") && !strings.HasPrefix(r.URL.Path, "/api/analytics/models/") {
+		return "api token is limited to specific targets; pass target_id"
+	}
+	return ""
+}
+
+// principalAllowsTarget reports whether the request's credentials may see targetID.
+// Only scoped API tokens carry a target limit.
+func principalAllowsTarget(r *http.Request, targetID int) bool {
+	p := principalFromRequest(r)
+	return p == nil || p.APIToken == nil || p.APIToken.allowsTarget(targetID)
+}
+
+// principalTargetLimited reports whether the request's credentials are limited to
+// specific targets.
+func principalTargetLimited(r *http.Request) bool {
+	p := principalFromRequest(r)
+	return p != nil && p.APIToken != nil && len(p.APIToken.TargetIDs) > 0
+}
+
+// filterTargetsForPrincipal drops the targets a scoped API token may not see.
+func filterTargetsForPrincipal(r *http.Request, targets []Target) []Target {
+	p := principalFromRequest(r)
+	if p == nil || p.APIToken == nil || len(p.APIToken.TargetIDs) == 0 {
+		return targets
+	}
+	out := make([]Target, 0, len(targets))
+	for _, t := range targets {
+		if p.APIToken.allowsTarget(t.ID) {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// ---------------------------------------------------------------------------
+// Authentication
+// ---------------------------------------------------------------------------
+
+var (
+	apiTokenStore     atomic.Pointer[Database]
+	apiTokenTouchMu   sync.Mutex
+	apiTokenLastTouch = map[int]time.Time{}
+)
+
+// setAPITokenStore enables scoped API tokens on the API auth chain.
+func setAPITokenStore(db *Database) {
+	apiTokenStore.Store(db)
+}
+
+// apiTokenAuthenticator accepts scoped API tokens issued through the admin API.
+type apiTokenAuthenticator struct{}
+
+func (apiTokenAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	db := apiTokenStore.Load()
+	if db == nil {
+		return nil, nil
+	}
+	token, err := parseProxyBearerToken(r)
+	if err != nil || !strings.HasPrefix(token, apiTokenPrefix) {
+		return nil, nil
+	}
+	now := time.Now()
+	t, err := db.GetActiveAPITokenByToken(token, float64(now.UnixMilli())/1000.0)
+	if err != nil {
+		return nil, err
+	}
+	if t == nil {
+		return nil, errAPITokenInvalid
+	}
+
+	apiTokenTouchMu.Lock()
+	touch := now.Sub(apiTokenLastTouch[t.ID]) >= apiTokenTouchInterval
+	if touch {
+		apiTokenLastTouch[t.ID] = now
+	}
+	apiTokenTouchMu.Unlock()
+	if touch {
+		if err := db.TouchAPIToken(t.ID, float64(now.UnixMilli())/1000.0); err != nil {
+			componentLogger(nil, "auth").Error("api token touch failed", "token_id", t.ID, "error", err)
+		}
+	}
+
+	role := authRoleAdmin
+	if t.ReadOnly {
+		role = authRoleVisitor
+	}
+	return &Principal{Role: role, Method: "api_token", APIToken: t}, nil
+}
+
+// ---------------------------------------------------------------------------
+// Admin API
+// ---------------------------------------------------------------------------
+
+// AdminListAPITokens handles GET /api/admin/api-tokens
+func (h *Handlers) AdminListAPITokens(w http.ResponseWriter, r *http.Request) {
+	items, err := h.db.ListAPITokens()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"items": items})
+}
+
+// AdminCreateAPIToken handles POST /api/admin/api-tokens
+func (h *Handlers) AdminCreateAPIToken(w http.ResponseWriter, r *http.Request) {
+	var req createAPITokenRequest
+	if err := readJSON(r, &req); err != nil {
+		writeRequestBodyError(w, err)
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > 128 {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "name must be 1-128 chars"})
+		return
+	}
+	if len(req.Description) > 512 {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "description must be <= 512 chars"})
+		return
+	}
+	if req.ExpiresInDays < 0 || req.ExpiresInDays > 3650 {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "expires_in_days must be 0-3650"})
+		return
+	}
+	endpoints := normalizeAPITokenEndpoints(req.Endpoints)
+	if len(endpoints) > 50 {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "endpoints must have <= 50 entries"})
+		return
+	}
+	for _, e := range endpoints {
+		if !strings.HasPrefix(e, "/api/") || strings.HasPrefix(e, "/api/admin") || len(e) > 256 {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "endpoints must be /api/ path prefixes outside /api/admin"})
+			return
+		}
+	}
+	targetIDs := normalizeProxyAllowedTargets(req.TargetIDs)
+	for _, id := range targetIDs {
+		t, err := h.db.GetTarget(id)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+			return
+		}
+		if t == nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"detail": fmt.Sprintf("target id %d not found", id)})
+			return
+		}
+	}
+
+	spec := APIToken{
+		Name:        req.Name,
+		ReadOnly:    req.ReadOnly == nil || *req.ReadOnly,
+		TargetIDs:   targetIDs,
+		Endpoints:   endpoints,
+		Description: strings.TrimSpace(req.Description),
+	}
+	if req.ExpiresInDays > 0 {
+		expiresAt := float64(time.Now().Add(time.Duration(req.ExpiresInDays)*24*time.Hour).UnixMilli()) / 1000.0
+		spec.ExpiresAt = &expiresAt
+	}
+	item, token, err := h.db.CreateAPIToken(spec)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": err.Error()})
+		return
+	}
+	h.audit(r, "api_token.create", "api_token", item.ID, auditDiff(nil, item, nil, nil))
+	writeJSON(w, http.StatusOK, map[string]any{
+		"item":      item,
+		"api_token": token, // only returned once at creation
+	})
+}
+
+// AdminRevokeAPIToken handles DELETE /api/admin/api-tokens/{id}
+func (h *Handlers) AdminRevokeAPIToken(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(r)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "invalid id"})
+		return
+	}
+	revoked, err := h.db.RevokeAPIToken(id)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return
+	}
+	if !revoked {
+		writeJSON(w, http.StatusNotFound, map[string]any{"detail": "api token not found or already revoked"})
+		return
+	}
+	h.audit(r, "api_token.revoke", "api_token", id, nil)
+	writeJSON(w, http.StatusOK, map[string]any{"ok": true})
+}
@@ -0,0 +1,170 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAPIToken_EnforcesScopes(t *testing.T) {
+	db, err := NewDatabase(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDatabase failed: %v", err)
+	}
+	t.Cleanup(func() { _ = db.conn.Close() })
+	if err := db.EnsureAPITokenSchema(); err != nil {
+		t.Fatalf("EnsureAPITokenSchema failed: %v", err)
+	}
+	setAuthTokens("admin-token", "visitor-token")
+	setAPITokenStore(db)
+	t.Cleanup(func() { setAPITokenStore(nil) })
+
+	allowed, err := db.CreateTarget(map[string]any{"name": "t1", "base_url": "https://example.com", "api_key": "k"})
+	if err != nil {
+		t.Fatalf("CreateTarget failed: %v", err)
+	}
+	other, err := db.CreateTarget(map[string]any{"name": "t2", "base_url": "https://example.org", "api_key": "k"})
+	if err != nil {
+		t.Fatalf("CreateTarget failed: %v", err)
+	}
+	item, token, err := db.CreateAPIToken(APIToken{Name: "ci", ReadOnly: true, TargetIDs: []int{allowed.ID}})
+	if err != nil {
+		t.Fatalf("CreateAPIToken failed: %v", err)
+	}
+
+	h := &Handlers{db: db, monitor: NewMonitorService(MonitorConfig{DB: db, LogDir: t.TempDir()})}
+	mux := http.NewServeMux()
+	mux.Handle("GET /api/targets", authAnyMiddleware(http.HandlerFunc(h.ListTargets)))
+	mux.Handle("GET /api/targets/{id}", authAnyMiddleware(http.HandlerFunc(h.GetTarget)))
+	mux.Handle("PATCH /api/targets/{id}", authAnyMiddleware(http.HandlerFunc(h.PatchTarget)))
+	mux.Handle("GET /api/analytics/timeseries", authAnyMiddleware(http.HandlerFunc(h.Timeseries)))
+	do := func(method, url string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, url, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do(http.MethodGet, "/api/targets")
+	var list struct {
+		Items []map[string]any `json:"items"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("list should succeed, got=%d body=%s", rec.Code, rec.Body.String())
+	}
+	if len(list.Items) != 1 || int(list.Items[0]["id"].(float64)) != allowed.ID {
+		t.Fatalf("list should only contain the token's target, got=%v", list.Items)
+	}
+	if rec := do(http.MethodGet, fmt.Sprintf("/api/targets/%d", allowed.ID)); rec.Code != http.StatusOK {
+		t.Fatalf("allowed target should be readable, got=%d", rec.Code)
+	}
+	if rec := do(http.MethodGet, fmt.Sprintf("/api/targets/%d", other.ID)); rec.Code != http.StatusForbidden {
+		t.Fatalf("other target should be forbidden, got=%d", rec.Code)
+	}
+	if rec := do(http.MethodPatch, fmt.Sprintf("/api/targets/%d", allowed.ID)); rec.Code != http.StatusForbidden {
+		t.Fatalf("read-only token should not write, got=%d", rec.Code)
+	}
+	if rec := do(http.MethodGet, "/api/analytics/timeseries"); rec.Code != http.StatusForbidden {
+		t.Fatalf("target-limited token should need target_id on aggregate endpoints, got=%d", rec.Code)
+	}
+	if rec := do(http.MethodGet, fmt.Sprintf("/api/analytics/timeseries?target_id=%d", allowed.ID)); rec.Code != http.StatusOK {
+		t.Fatalf("aggregate endpoint with allowed target_id should succeed, got=%d", rec.Code)
+	}
+
+	if _, err := db.RevokeAPIToken(item.ID); err != nil {
+		t.Fatalf("RevokeAPIToken failed: %v", err)
+	}
+	if rec := do(http.MethodGet, "/api/targets"); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("revoked token should be rejected, got=%d", rec.Code)
+	}
+}
+
+func TestAPIToken_EndpointScope(t *testing.T) {
+	tok := &APIToken{Endpoints: []string{"/api/dashboard", "/api/targets"}}
+	cases := map[string]bool{
+		"/api/dashboard": true,
+		"/api/targets/3": true,
+		"/api/targetsx":  false,
+		"/api/incidents": false,
+	}
+	for path, want := range cases {
+		if got := tok.allowsPath(path); got != want {
+			t.Fatalf("allowsPath(%q) should be %v, got=%v", path, want, got)
+		}
+	}
+}
+
+func TestAPIToken_TargetLimitCoversBodyIDs(t *testing.T) {
+	db, err := NewDatabase(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDatabase failed: %v", err)
+	}
+	t.Cleanup(func() { _ = db.conn.Close() })
+	if err := db.EnsureAPITokenSchema(); err != nil {
+		t.Fatalf("EnsureAPITokenSchema failed: %v", err)
+	}
+	setAuthTokens("admin-token", "visitor-token")
+	setAPITokenStore(db)
+	t.Cleanup(func() { setAPITokenStore(nil) })
+
+	allowed, err := db.CreateTarget(map[string]any{"name": "t1", "base_url": "https://example.com", "api_key": "k"})
+	if err != nil {
+		t.Fatalf("CreateTarget failed: %v", err)
+	}
+	other, err := db.CreateTarget(map[string]any{"name": "t2", "base_url": "https://example.org", "api_key": "k"})
+	if err != nil {
+		t.Fatalf("CreateTarget failed: %v", err)
+	}
+	_, token, err := db.CreateAPIToken(APIToken{Name: "ops", TargetIDs: []int{allowed.ID}})
+	if err != nil {
+		t.Fatalf("CreateAPIToken failed: %v", err)
+	}
+
+	h := &Handlers{db: db, monitor: NewMonitorService(MonitorConfig{DB: db, LogDir: t.TempDir()})}
+	mux := http.NewServeMux()
+	mux.Handle("POST /api/targets", authAnyMiddleware(http.HandlerFunc(h.CreateTarget)))
+	mux.Handle("POST /api/targets/bulk", authAnyMiddleware(http.HandlerFunc(h.BulkTargets)))
+	mux.Handle("PATCH /api/targets/reorder", authAnyMiddleware(http.HandlerFunc(h.ReorderTargets)))
+	do := func(method, url, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, url, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do(http.MethodPost, fmt.Sprintf("/api/targets/bulk?target_id=%d", allowed.ID),
+		fmt.Sprintf(`{"ids":[%d,%d],"action":"disable"}`, allowed.ID, other.ID))
+	var bulk struct {
+		Items []bulkTargetResult `json:"items"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &bulk); err != nil || rec.Code != http.StatusOK || len(bulk.Items) != 2 {
+		t.Fatalf("bulk should answer per id, got=%d body=%s", rec.Code, rec.Body.String())
+	}
+	if !bulk.Items[0].OK || bulk.Items[1].OK || !strings.Contains(bulk.Items[1].Detail, "not allowed") {
+		t.Fatalf("bulk should only act on the token's target, got=%+v", bulk.Items)
+	}
+	if got, _ := db.GetTarget(other.ID); got == nil || !got.Enabled {
+		t.Fatalf("the other target should stay enabled, got=%+v", got)
+	}
+
+	rec = do(http.MethodPatch, fmt.Sprintf("/api/targets/reorder?target_id=%d", allowed.ID),
+		fmt.Sprintf(`{"ids":[%d,%d]}`, other.ID, allowed.ID))
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("target-limited token should not reorder targets, got=%d body=%s", rec.Code, rec.Body.String())
+	}
+
+	body := `{"name":"t3","base_url":"https://example.net","api_key":"k"}`
+	if rec := do(http.MethodPost, "/api/targets", body); rec.Code != http.StatusForbidden {
+		t.Fatalf("target-limited token should not create targets, got=%d body=%s", rec.Code, rec.Body.String())
+	}
+	if items, _ := db.ListTargets(); len(items) != 2 {
+		t.Fatalf("no target should have been created, got %d", len(items))
+	}
+}
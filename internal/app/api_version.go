@@ -0,0 +1,49 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// API versioning. /api/v1/* is the stable API surface; the handlers are registered
+// under /api/* and apiVersionMiddleware maps a /api/v1 request onto them. The
+// unversioned /api/* paths keep working as a deprecated alias for one release and
+// answer with Deprecation and a Link to the /api/v1 successor. A handler that must
+// change its response shape can check isLegacyAPIRequest to keep the old shape on
+// the alias.
+
+const apiV1Prefix = "/api/v1"
+
+type legacyAPIContextKey struct{}
+
+// apiVersionMiddleware rewrites /api/v1/... to the registered /api/... route and marks
+// unversioned /api/... requests as deprecated.
+func apiVersionMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.Path
+		switch {
+		case path == apiV1Prefix || strings.HasPrefix(path, apiV1Prefix+"/"):
+			u := *r.URL
+			u.Path = "/api" + strings.TrimPrefix(path, apiV1Prefix)
+			if u.RawPath != "" {
+				u.RawPath = "/api" + strings.TrimPrefix(u.RawPath, apiV1Prefix)
+			}
+			r2 := r.WithContext(r.Context())
+			r2.URL = &u
+			next.ServeHTTP(w, r2)
+		case strings.HasPrefix(path, "/api/"):
+			w.Header().Set("Deprecation", "true")
+			w.Header().Set("Link", "<"+apiV1Prefix+strings.TrimPrefix(r.URL.EscapedPath(), "/api")+`>; rel="successor-version"`)
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), legacyAPIContextKey{}, true)))
+		default:
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// isLegacyAPIRequest reports whether r came in on the deprecated unversioned /api/* alias.
+func isLegacyAPIRequest(r *http.Request) bool {
+	legacy, _ := r.Context().Value(legacyAPIContextKey{}).(bool)
+	return legacy
+}
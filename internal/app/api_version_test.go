@@ -0,0 +1,43 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAPIVersionPrefixServesRegisteredRoutes(t *testing.T) {
+	mux := newRouteMux()
+	mux.HandleFunc("GET /api/targets/{id}", func(w http.ResponseWriter, r *http.Request) {
+		legacy := "no"
+		if isLegacyAPIRequest(r) {
+			legacy = "yes"
+		}
+		_, _ = w.Write([]byte(r.URL.Path + " " + r.PathValue("id") + " " + legacy))
+	})
+	handler := apiVersionMiddleware(mux)
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/v1/targets/5", nil))
+	if rr.Code != http.StatusOK || rr.Body.String() != "/api/targets/5 5 no" {
+		t.Fatalf("/api/v1 should reach the registered handler, got status=%d body=%q", rr.Code, rr.Body.String())
+	}
+	if rr.Header().Get("Deprecation") != "" {
+		t.Fatalf("/api/v1 responses should not be marked deprecated")
+	}
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/targets/5", nil))
+	if rr.Body.String() != "/api/targets/5 5 yes" {
+		t.Fatalf("unversioned alias should still be served and marked legacy, got=%q", rr.Body.String())
+	}
+	if rr.Header().Get("Deprecation") != "true" || rr.Header().Get("Link") != `</api/v1/targets/5>; rel="successor-version"` {
+		t.Fatalf("unversioned alias should point at its successor, got headers=%v", rr.Header())
+	}
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1/models", nil))
+	if rr.Header().Get("Deprecation") != "" {
+		t.Fatalf("proxy endpoints are not part of the versioned API")
+	}
+}
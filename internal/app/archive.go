@@ -0,0 +1,390 @@
+package app
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Run archival. With run_archive_days set, the scheduler exports runs older than that
+// many days, with their run_models rows and debug captures, to gzip-compressed JSONL
+// files in the archive directory (DATA_DIR/archives) and then deletes the rows. Each
+// line of an archive is one run: {"run": {...}, "models": [...], "captures": [...]},
+// with every column of the table as stored. A target's latest full run and the partial
+// runs after it are always kept, as are runs with notes, so current statuses and
+// annotations survive. Runs of days that are not rolled up into model_daily_stats yet
+// are kept too.
+
+// maxRunArchiveDays caps the run_archive_days setting (ten years).
+const maxRunArchiveDays = 3650
+
+// runArchiveInterval is how often the scheduler looks for runs to archive.
+const runArchiveInterval = time.Hour
+
+// runArchiveBatch is the most runs written to one archive file.
+const runArchiveBatch = 2000
+
+// ArchiveFile describes one archive in the archive directory.
+type ArchiveFile struct {
+	Name      string  `json:"name"`
+	SizeBytes int64   `json:"size_bytes"`
+	CreatedAt float64 `json:"created_at"`
+}
+
+// ArchiveResult summarises one archival pass.
+type ArchiveResult struct {
+	Cutoff float64  `json:"cutoff"`
+	Files  []string `json:"files"`
+	Runs   int      `json:"runs"`
+	Models int      `json:"models"`
+}
+
+// archivableRunIDs returns up to limit ids of finished runs started before cutoff that
+// may be archived, oldest first.
+func (d *Database) archivableRunIDs(cutoff float64, limit int) ([]int, error) {
+	rows, err := d.read.Query(`
+		SELECT r.id FROM runs r
+		WHERE r.started_at < ? AND r.status != 'running'
+		  AND r.id < (SELECT MAX(f.id) FROM runs f WHERE f.target_id = r.target_id AND f.mode = ?)
+		  AND NOT EXISTS (SELECT 1 FROM notes n WHERE n.run_id = r.id)
+		ORDER BY r.id
+		LIMIT ?
+	`, cutoff, runModeFull, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// dumpRows returns every row of a query as column-name maps.
+func (d *Database) dumpRows(query string, args ...any) ([]map[string]any, error) {
+	rows, err := d.read.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	out := []map[string]any{}
+	for rows.Next() {
+		vals := make([]any, len(cols))
+		ptrs := make([]any, len(cols))
+		for i := range vals {
+			ptrs[i] = &vals[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, err
+		}
+		row := make(map[string]any, len(cols))
+		for i, col := range cols {
+			row[col] = vals[i]
+		}
+		out = append(out, row)
+	}
+	return out, rows.Err()
+}
+
+// deleteRuns removes runs by id; their run_models and captures go with them.
+func (d *Database) deleteRuns(ids []int) error {
+	defer d.touchTargets()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	tx, err := d.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for start := 0; start < len(ids); start += 500 {
+		chunk := ids[start:min(start+500, len(ids))]
+		args := make([]any, len(chunk))
+		for i, id := range chunk {
+			args[i] = id
+		}
+		if _, err := tx.Exec("DELETE FROM runs WHERE id IN (?"+strings.Repeat(", ?", len(chunk)-1)+")", args...); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// UpdateRunArchiveDays changes the age after which runs are archived; 0 disables archival.
+func (ms *MonitorService) UpdateRunArchiveDays(days int) {
+	ms.mu.Lock()
+	ms.runArchiveDays = min(max(days, 0), maxRunArchiveDays)
+	ms.mu.Unlock()
+}
+
+// RunArchiveDays returns the age in days after which runs are archived; 0 means never.
+func (ms *MonitorService) RunArchiveDays() int {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	return ms.runArchiveDays
+}
+
+// maybeArchiveRuns starts an archival pass in the background when archival is enabled
+// and the last pass is at least runArchiveInterval old.
+func (ms *MonitorService) maybeArchiveRuns() {
+	ms.mu.Lock()
+	days := ms.runArchiveDays
+	due := days > 0 && time.Since(ms.lastArchiveAt) >= runArchiveInterval
+	if due {
+		ms.lastArchiveAt = time.Now()
+	}
+	ms.mu.Unlock()
+	if !due {
+		return
+	}
+	go func() {
+		if _, err := ms.ArchiveRuns(days); err != nil {
+			ms.logger().Error("run archival failed", "error", err)
+		}
+	}()
+}
+
+// ArchiveRuns archives the runs older than days and deletes them from the database.
+// Rows are only deleted once the file holding them is written and synced.
+func (ms *MonitorService) ArchiveRuns(days int) (ArchiveResult, error) {
+	ms.archiveMu.Lock()
+	defer ms.archiveMu.Unlock()
+
+	cutoff := float64(time.Now().Add(-time.Duration(days)*24*time.Hour).UnixMilli()) / 1000.0
+	result := ArchiveResult{Cutoff: cutoff, Files: []string{}}
+	// Only days that are rolled up may lose their raw rows.
+	if _, err := ms.db.RollupFinishedDays(time.Now()); err != nil {
+		return result, err
+	}
+	pending, err := ms.db.firstPendingDay()
+	if err != nil {
+		return result, err
+	}
+	cutoff = min(cutoff, pending)
+	result.Cutoff = cutoff
+	if err := os.MkdirAll(ms.archiveDir, 0o755); err != nil {
+		return result, err
+	}
+	for {
+		ids, err := ms.db.archivableRunIDs(cutoff, runArchiveBatch)
+		if err != nil {
+			return result, err
+		}
+		if len(ids) == 0 {
+			break
+		}
+		name, models, err := ms.writeRunArchive(ids)
+		if err != nil {
+			return result, err
+		}
+		if err := ms.db.deleteRuns(ids); err != nil {
+			return result, fmt.Errorf("archive %s written but rows not deleted: %w", name, err)
+		}
+		result.Files = append(result.Files, name)
+		result.Runs += len(ids)
+		result.Models += models
+		if len(ids) < runArchiveBatch {
+			break
+		}
+	}
+	if result.Runs > 0 {
+		ms.logger().Info("runs archived", "runs", result.Runs, "models", result.Models, "files", len(result.Files))
+	}
+	return result, nil
+}
+
+// writeRunArchive writes the runs to a new archive file and returns its name and the
+// number of run_models rows it holds.
+func (ms *MonitorService) writeRunArchive(ids []int) (string, int, error) {
+	name := "runs-" + time.Now().UTC().Format("20060102T150405Z")
+	for i := 2; ; i++ {
+		if _, err := os.Stat(filepath.Join(ms.archiveDir, name+".jsonl.gz")); os.IsNotExist(err) {
+			break
+		}
+		name = fmt.Sprintf("runs-%s-%d", time.Now().UTC().Format("20060102T150405Z"), i)
+	}
+	name += ".jsonl.gz"
+	tmp, err := os.CreateTemp(ms.archiveDir, ".runs-*.tmp")
+	if err != nil {
+		return "", 0, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	gz, _ := gzip.NewWriterLevel(tmp, gzip.BestCompression)
+	enc := json.NewEncoder(gz)
+	models := 0
+	for start := 0; start < len(ids); start += 200 {
+		chunk := ids[start:min(start+200, len(ids))]
+		n, err := ms.encodeRuns(enc, chunk)
+		if err != nil {
+			return "", 0, err
+		}
+		models += n
+	}
+	if err := gz.Close(); err != nil {
+		return "", 0, err
+	}
+	if err := tmp.Sync(); err != nil {
+		return "", 0, err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", 0, err
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(ms.archiveDir, name)); err != nil {
+		return "", 0, err
+	}
+	return name, models, nil
+}
+
+// encodeRuns writes one archive line per run in ids.
+func (ms *MonitorService) encodeRuns(enc *json.Encoder, ids []int) (int, error) {
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	in := "(?" + strings.Repeat(", ?", len(ids)-1) + ")"
+	runs, err := ms.db.dumpRows("SELECT * FROM runs WHERE id IN "+in+" ORDER BY id", args...)
+	if err != nil {
+		return 0, err
+	}
+	models, err := ms.db.dumpRows("SELECT * FROM run_models WHERE run_id IN "+in+" ORDER BY id", args...)
+	if err != nil {
+		return 0, err
+	}
+	captures, err := ms.db.dumpRows("SELECT * FROM run_model_captures WHERE run_id IN "+in+" ORDER BY run_model_id", args...)
+	if err != nil {
+		return 0, err
+	}
+	byRun := func(rows []map[string]any) map[int][]map[string]any {
+		out := map[int][]map[string]any{}
+		for _, row := range rows {
+			id, _ := anyInt(row["run_id"])
+			out[id] = append(out[id], row)
+		}
+		return out
+	}
+	modelsByRun, capturesByRun := byRun(models), byRun(captures)
+	for _, run := range runs {
+		id, _ := anyInt(run["id"])
+		line := map[string]any{"run": run, "models": modelsByRun[id], "captures": capturesByRun[id]}
+		if line["models"] == nil {
+			line["models"] = []map[string]any{}
+		}
+		if line["captures"] == nil {
+			line["captures"] = []map[string]any{}
+		}
+		if err := enc.Encode(line); err != nil {
+			return 0, err
+		}
+	}
+	return len(models), nil
+}
+
+// ListArchives returns the archive files, newest first.
+func (ms *MonitorService) ListArchives() ([]ArchiveFile, error) {
+	entries, err := os.ReadDir(ms.archiveDir)
+	if os.IsNotExist(err) {
+		return []ArchiveFile{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	files := []ArchiveFile{}
+	for _, e := range entries {
+		if e.IsDir() || !validArchiveName(e.Name()) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, ArchiveFile{
+			Name:      e.Name(),
+			SizeBytes: info.Size(),
+			CreatedAt: float64(info.ModTime().UnixMilli()) / 1000.0,
+		})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Name > files[j].Name })
+	return files, nil
+}
+
+// validArchiveName reports whether name is a plain archive file name, so it cannot
+// reach outside the archive directory.
+func validArchiveName(name string) bool {
+	return strings.HasPrefix(name, "runs-") && strings.HasSuffix(name, ".jsonl.gz") &&
+		filepath.Base(name) == name && !strings.ContainsAny(name, `/\`)
+}
+
+// AdminListArchives -- GET /api/admin/archives
+func (h *Handlers) AdminListArchives(w http.ResponseWriter, r *http.Request) {
+	files, err := h.monitor.ListArchives()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"items": files, "run_archive_days": h.monitor.RunArchiveDays()})
+}
+
+// AdminDownloadArchive -- GET /api/admin/archives/{name}
+func (h *Handlers) AdminDownloadArchive(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if !validArchiveName(name) {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "invalid archive name"})
+		return
+	}
+	f, err := os.Open(filepath.Join(h.monitor.archiveDir, name))
+	if os.IsNotExist(err) {
+		writeJSON(w, http.StatusNotFound, map[string]any{"detail": "archive not found"})
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return
+	}
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+name+`"`)
+	http.ServeContent(w, r, name, info.ModTime(), f)
+}
+
+// AdminRunArchive -- POST /api/admin/archives/run
+// Runs an archival pass now with the current run_archive_days.
+func (h *Handlers) AdminRunArchive(w http.ResponseWriter, r *http.Request) {
+	days := h.monitor.RunArchiveDays()
+	if days <= 0 {
+		writeJSON(w, http.StatusConflict, map[string]any{"detail": "run archival is disabled (set run_archive_days)"})
+		return
+	}
+	result, err := h.monitor.ArchiveRuns(days)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return
+	}
+	if result.Runs > 0 {
+		h.audit(r, "runs.archive", "settings", 0, nil)
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"item": result})
+}
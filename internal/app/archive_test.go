@@ -0,0 +1,119 @@
+package app
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestArchiveRunsExportsAndDeletesOldRuns(t *testing.T) {
+	db, err := NewDatabase(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDatabase failed: %v", err)
+	}
+	t.Cleanup(func() { _ = db.conn.Close() })
+	if err := db.EnsureNoteSchema(); err != nil {
+		t.Fatalf("EnsureNoteSchema failed: %v", err)
+	}
+	archiveDir := t.TempDir()
+	h := &Handlers{db: db, monitor: NewMonitorService(MonitorConfig{DB: db, LogDir: t.TempDir(), ArchiveDir: archiveDir, RunArchiveDays: 30})}
+
+	target, err := db.CreateTarget(map[string]any{"name": "a", "base_url": "https://example.com", "api_key": "k"})
+	if err != nil {
+		t.Fatalf("CreateTarget failed: %v", err)
+	}
+	old := float64(time.Now().Add(-60 * 24 * time.Hour).Unix())
+	addRun := func(startedAt float64, mode string) int {
+		runID, err := db.CreateRun(target.ID, startedAt, "", nil, mode)
+		if err != nil {
+			t.Fatalf("CreateRun failed: %v", err)
+		}
+		if err := db.FinishRun(runID, "completed", startedAt+1, 1, 1, 0, nil); err != nil {
+			t.Fatalf("FinishRun failed: %v", err)
+		}
+		rows := []DetectionResult{{Protocol: "openai", Model: "m", Success: true, Timestamp: startedAt}}
+		if err := db.InsertModelRows(runID, target.ID, rows); err != nil {
+			t.Fatalf("InsertModelRows failed: %v", err)
+		}
+		return runID
+	}
+	archived := addRun(old, runModeFull)
+	noted := addRun(old+10, runModeFull)
+	latestFull := addRun(old+20, runModeFull)
+	partial := addRun(old+30, runModeSubset)
+	if _, err := db.CreateNote(target.ID, &noted, "keep me", "admin"); err != nil {
+		t.Fatalf("CreateNote failed: %v", err)
+	}
+
+	req := withAuthRole(httptest.NewRequest(http.MethodPost, "/api/admin/archives/run", nil), authRoleAdmin)
+	rr := httptest.NewRecorder()
+	h.AdminRunArchive(rr, req)
+	var resp struct {
+		Item ArchiveResult `json:"item"`
+	}
+	_ = json.Unmarshal(rr.Body.Bytes(), &resp)
+	if rr.Code != http.StatusOK || resp.Item.Runs != 1 || resp.Item.Models != 1 || len(resp.Item.Files) != 1 {
+		t.Fatalf("archive should take only the old unprotected run, got status=%d body=%s", rr.Code, rr.Body.String())
+	}
+	if run, _ := db.GetRun(target.ID, archived); run != nil {
+		t.Fatalf("archived run should be deleted")
+	}
+	for _, id := range []int{noted, latestFull, partial} {
+		if run, _ := db.GetRun(target.ID, id); run == nil {
+			t.Fatalf("run %d should be kept", id)
+		}
+	}
+
+	f, err := os.Open(filepath.Join(archiveDir, resp.Item.Files[0]))
+	if err != nil {
+		t.Fatalf("archive file should exist: %v", err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("archive should be gzip: %v", err)
+	}
+	sc := bufio.NewScanner(gz)
+	var lines []map[string]any
+	for sc.Scan() {
+		var line map[string]any
+		if err := json.Unmarshal(sc.Bytes(), &line); err != nil {
+			t.Fatalf("archive line should be JSON: %v", err)
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) != 1 {
+		t.Fatalf("archive should hold one run, got=%d", len(lines))
+	}
+	run, _ := lines[0]["run"].(map[string]any)
+	models, _ := lines[0]["models"].([]any)
+	if id, _ := anyInt(run["id"]); id != archived || len(models) != 1 {
+		t.Fatalf("archive line should carry the run and its models, got=%v", lines[0])
+	}
+
+	rr = httptest.NewRecorder()
+	h.AdminListArchives(rr, withAuthRole(httptest.NewRequest(http.MethodGet, "/api/admin/archives", nil), authRoleAdmin))
+	var list struct {
+		Items []ArchiveFile `json:"items"`
+	}
+	_ = json.Unmarshal(rr.Body.Bytes(), &list)
+	if len(list.Items) != 1 || list.Items[0].Name != resp.Item.Files[0] || list.Items[0].SizeBytes == 0 {
+		t.Fatalf("archive list should show the file, got=%s", rr.Body.String())
+	}
+
+	for name, want := range map[string]int{resp.Item.Files[0]: http.StatusOK, "..%2Fregistry.db": http.StatusBadRequest, "runs-missing.jsonl.gz": http.StatusNotFound} {
+		req := withAuthRole(httptest.NewRequest(http.MethodGet, "/api/admin/archives/x", nil), authRoleAdmin)
+		req.SetPathValue("name", name)
+		rr := httptest.NewRecorder()
+		h.AdminDownloadArchive(rr, req)
+		if rr.Code != want {
+			t.Fatalf("download %q should return %d, got=%d", name, want, rr.Code)
+		}
+	}
+}
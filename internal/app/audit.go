@@ -0,0 +1,252 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// auditRedacted replaces secret values in audit diffs; the entry still shows that they changed.
+const auditRedacted = "[redacted]"
+
+// AuditEntry records one mutating API call.
+type AuditEntry struct {
+	ID           int            `json:"id"`
+	Timestamp    float64        `json:"timestamp"`
+	ActorRole    string         `json:"actor_role"`
+	ActorMethod  string         `json:"actor_method"`
+	ClientIP     string         `json:"client_ip"`
+	Action       string         `json:"action"`
+	ResourceType string         `json:"resource_type"`
+	ResourceID   *int           `json:"resource_id"`
+	Diff         map[string]any `json:"diff"`
+}
+
+// AuditFilter narrows ListAudit; zero values match everything.
+type AuditFilter struct {
+	Action       string
+	ResourceType string
+	ResourceID   *int
+	Since        float64
+	Limit        int
+}
+
+// Target fields that change on every run and are not configuration.
+var auditTargetSkipFields = map[string]bool{
+	"created_at": true, "updated_at": true,
+	"last_run_at": true, "last_status": true, "last_total": true, "last_success": true,
+	"last_fail": true, "last_log_file": true, "last_error": true,
+	"tls_cert_not_after": true, "tls_cert_chain": true, "tls_cert_checked_at": true,
+	"retry_interval_min": true, "balance_remaining": true, "balance_checked_at": true, "balance_error": true,
+}
+
+var auditTargetSecretFields = map[string]bool{"api_key": true, "api_keys": true}
+
+var auditSettingsSecretFields = map[string]bool{
+	"api_monitor_token_admin":   true,
+	"api_monitor_token_visitor": true,
+	"proxy_master_token":        true,
+	"oidc_client_secret":        true,
+	"s3_secret_access_key":      true,
+	"pagerduty_routing_key":     true,
+}
+
+// EnsureAuditSchema creates the audit_log table.
+func (d *Database) EnsureAuditSchema() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	_, err := d.conn.Exec(`
+		CREATE TABLE IF NOT EXISTS audit_log (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			timestamp REAL NOT NULL,
+			actor_role TEXT NOT NULL DEFAULT '',
+			actor_method TEXT NOT NULL DEFAULT '',
+			client_ip TEXT NOT NULL DEFAULT '',
+			action TEXT NOT NULL,
+			resource_type TEXT NOT NULL DEFAULT '',
+			resource_id INTEGER,
+			diff TEXT NOT NULL DEFAULT '{}'
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_audit_log_time
+		ON audit_log(timestamp DESC);
+
+		CREATE INDEX IF NOT EXISTS idx_audit_log_resource
+		ON audit_log(resource_type, resource_id);
+	`)
+	if err != nil {
+		return fmt.Errorf("init audit schema: %w", err)
+	}
+	return nil
+}
+
+// InsertAudit stores an audit entry.
+func (d *Database) InsertAudit(e AuditEntry) error {
+	if e.Diff == nil {
+		e.Diff = map[string]any{}
+	}
+	diffJSON, err := json.Marshal(e.Diff)
+	if err != nil {
+		return err
+	}
+	d.mu.Lock()
+	_, err = d.conn.Exec(`
+		INSERT INTO audit_log (timestamp, actor_role, actor_method, client_ip, action, resource_type, resource_id, diff)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		e.Timestamp, e.ActorRole, e.ActorMethod, e.ClientIP, e.Action, e.ResourceType, e.ResourceID, string(diffJSON),
+	)
+	d.mu.Unlock()
+	return err
+}
+
+// ListAudit returns audit entries newest first.
+func (d *Database) ListAudit(f AuditFilter) ([]AuditEntry, error) {
+	query := `SELECT id, timestamp, actor_role, actor_method, client_ip, action, resource_type, resource_id, diff
+		FROM audit_log WHERE timestamp >= ?`
+	args := []any{f.Since}
+	if f.Action != "" {
+		query += " AND action = ?"
+		args = append(args, f.Action)
+	}
+	if f.ResourceType != "" {
+		query += " AND resource_type = ?"
+		args = append(args, f.ResourceType)
+	}
+	if f.ResourceID != nil {
+		query += " AND resource_id = ?"
+		args = append(args, *f.ResourceID)
+	}
+	query += " ORDER BY timestamp DESC, id DESC LIMIT ?"
+	args = append(args, f.Limit)
+
+	rows, err := d.read.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []AuditEntry{}
+	for rows.Next() {
+		var e AuditEntry
+		var diffRaw string
+		if err := rows.Scan(&e.ID, &e.Timestamp, &e.ActorRole, &e.ActorMethod, &e.ClientIP,
+			&e.Action, &e.ResourceType, &e.ResourceID, &diffRaw); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(diffRaw), &e.Diff); err != nil || e.Diff == nil {
+			e.Diff = map[string]any{}
+		}
+		items = append(items, e)
+	}
+	return items, rows.Err()
+}
+
+// auditFields flattens v into its JSON object fields; nil yields an empty map.
+func auditFields(v any) map[string]any {
+	fields := map[string]any{}
+	if v == nil || (reflect.ValueOf(v).Kind() == reflect.Pointer && reflect.ValueOf(v).IsNil()) {
+		return fields
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return fields
+	}
+	_ = json.Unmarshal(raw, &fields)
+	return fields
+}
+
+// auditDiff returns {"field": {"from": old, "to": new}} for every top-level field that
+// differs between before and after. Either side may be nil for creations and deletions.
+func auditDiff(before, after any, skip, secret map[string]bool) map[string]any {
+	from, to := auditFields(before), auditFields(after)
+	diff := map[string]any{}
+	keys := map[string]bool{}
+	for k := range from {
+		keys[k] = true
+	}
+	for k := range to {
+		keys[k] = true
+	}
+	for k := range keys {
+		if skip[k] {
+			continue
+		}
+		oldVal, hadOld := from[k]
+		newVal, hadNew := to[k]
+		if hadOld && hadNew && reflect.DeepEqual(oldVal, newVal) {
+			continue
+		}
+		if secret[k] {
+			if hadOld {
+				oldVal = auditRedacted
+			}
+			if hadNew {
+				newVal = auditRedacted
+			}
+		}
+		diff[k] = map[string]any{"from": oldVal, "to": newVal}
+	}
+	return diff
+}
+
+func auditTargetDiff(before, after *Target) map[string]any {
+	return auditDiff(before, after, auditTargetSkipFields, auditTargetSecretFields)
+}
+
+// audit records a mutating call made by the request's principal. A failure to store
+// the entry is logged and does not fail the request.
+func (h *Handlers) audit(r *http.Request, action, resourceType string, resourceID int, diff map[string]any) {
+	entry := AuditEntry{
+		Timestamp:    float64(time.Now().UnixMilli()) / 1000.0,
+		ActorRole:    string(authRoleFromRequest(r)),
+		ClientIP:     clientIPFromRequest(r),
+		Action:       action,
+		ResourceType: resourceType,
+		Diff:         diff,
+	}
+	if p := principalFromRequest(r); p != nil {
+		entry.ActorMethod = p.Method
+	}
+	if resourceID > 0 {
+		entry.ResourceID = &resourceID
+	}
+	if err := h.db.InsertAudit(entry); err != nil {
+		h.logger("audit").Error("record audit entry failed", "action", action, "error", err)
+	}
+}
+
+// AdminListAudit handles GET /api/admin/audit?action=&resource_type=&resource_id=&since=&limit=
+func (h *Handlers) AdminListAudit(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	f := AuditFilter{
+		Action:       q.Get("action"),
+		ResourceType: q.Get("resource_type"),
+		Limit:        queryInt(r, "limit", 200, 1, 2000),
+	}
+	if s := q.Get("resource_id"); s != "" {
+		id, err := strconv.Atoi(s)
+		if err != nil || id < 1 {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "invalid resource_id"})
+			return
+		}
+		f.ResourceID = &id
+	}
+	if s := q.Get("since"); s != "" {
+		since, err := strconv.ParseFloat(s, 64)
+		if err != nil || since < 0 {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "since must be a unix timestamp"})
+			return
+		}
+		f.Since = since
+	}
+	items, err := h.db.ListAudit(f)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"items": items})
+}
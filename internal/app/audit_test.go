@@ -0,0 +1,68 @@
+package app
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestAuditDiff_RedactsSecrets(t *testing.T) {
+	before := &Target{Name: "a", APIKey: "sk-old", IntervalMin: 30}
+	after := &Target{Name: "a", APIKey: "sk-new", IntervalMin: 10, LastRunAt: floatPtr(1)}
+	diff := auditTargetDiff(before, after)
+	if len(diff) != 2 {
+		t.Fatalf("diff should only hold changed config fields, got=%v", diff)
+	}
+	key, _ := diff["api_key"].(map[string]any)
+	if key["from"] != auditRedacted || key["to"] != auditRedacted {
+		t.Fatalf("api_key should be redacted, got=%v", key)
+	}
+	interval, _ := diff["interval_min"].(map[string]any)
+	if interval["from"] != float64(30) || interval["to"] != float64(10) {
+		t.Fatalf("interval_min change should be recorded, got=%v", interval)
+	}
+}
+
+func floatPtr(v float64) *float64 { return &v }
+
+func TestPatchTarget_WritesAuditEntry(t *testing.T) {
+	db, err := NewDatabase(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDatabase failed: %v", err)
+	}
+	t.Cleanup(func() { _ = db.conn.Close() })
+	if err := db.EnsureAuditSchema(); err != nil {
+		t.Fatalf("EnsureAuditSchema failed: %v", err)
+	}
+	h := &Handlers{db: db, monitor: NewMonitorService(MonitorConfig{DB: db, LogDir: t.TempDir()})}
+	target, err := db.CreateTarget(map[string]any{"name": "t1", "base_url": "https://example.com", "api_key": "k"})
+	if err != nil {
+		t.Fatalf("CreateTarget failed: %v", err)
+	}
+	id := strconv.Itoa(target.ID)
+
+	req := httptest.NewRequest(http.MethodPatch, "/api/targets/"+id, bytes.NewBufferString(`{"name":"renamed"}`))
+	req.SetPathValue("id", id)
+	req.RemoteAddr = "203.0.113.7:5555"
+	rr := httptest.NewRecorder()
+	h.PatchTarget(rr, withAuthRole(req, authRoleAdmin))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("PatchTarget failed, got=%d body=%s", rr.Code, rr.Body.String())
+	}
+
+	items, err := db.ListAudit(AuditFilter{ResourceType: "target", ResourceID: &target.ID, Limit: 10})
+	if err != nil || len(items) != 1 {
+		t.Fatalf("expected one audit entry, got=%v err=%v", items, err)
+	}
+	e := items[0]
+	if e.Action != "target.update" || e.ActorRole != "admin" || e.ClientIP != "203.0.113.7" {
+		t.Fatalf("unexpected audit entry, got=%+v", e)
+	}
+	name, _ := e.Diff["name"].(map[string]any)
+	if len(e.Diff) != 1 || name["from"] != "t1" || name["to"] != "renamed" {
+		t.Fatalf("audit diff should hold the renamed field, got=%v", e.Diff)
+	}
+}
@@ -0,0 +1,389 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// ---------------------------------------------------------------------------
+// Roles and principals
+// ---------------------------------------------------------------------------
+
+type authRole string
+
+const (
+	authRoleUnknown authRole = "unknown"
+	authRoleVisitor authRole = "visitor"
+	authRoleAdmin   authRole = "admin"
+	authRoleProxy   authRole = "proxy"
+	authRoleAgent   authRole = "agent"
+)
+
+// Principal is the identity an Authenticator attached to a request.
+type Principal struct {
+	Role authRole
+	// Method names the mechanism that accepted the request (token, session, proxy_key, ...).
+	Method   string
+	ProxyKey *ProxyKey
+	Agent    *Agent
+	// APIToken is set for scoped API tokens; its scopes are enforced by authMiddleware.
+	APIToken *APIToken
+}
+
+type principalContextKey struct{}
+
+var principalKey = principalContextKey{}
+
+func withPrincipal(r *http.Request, p *Principal) *http.Request {
+	ctx := context.WithValue(r.Context(), principalKey, p)
+	return r.WithContext(ctx)
+}
+
+func principalFromRequest(r *http.Request) *Principal {
+	if r == nil {
+		return nil
+	}
+	p, _ := r.Context().Value(principalKey).(*Principal)
+	return p
+}
+
+func withAuthRole(r *http.Request, role authRole) *http.Request {
+	return withPrincipal(r, &Principal{Role: role})
+}
+
+func authRoleFromRequest(r *http.Request) authRole {
+	p := principalFromRequest(r)
+	if p == nil || p.Role == "" {
+		return authRoleUnknown
+	}
+	return p.Role
+}
+
+// ---------------------------------------------------------------------------
+// Authenticator interface and chain
+// ---------------------------------------------------------------------------
+
+// Authenticator inspects a request and returns the principal it carries.
+// It returns (nil, nil) when the request has no credentials it understands so the
+// next authenticator in a chain can try; a non-nil error rejects the request outright.
+type Authenticator interface {
+	Authenticate(r *http.Request) (*Principal, error)
+}
+
+// AuthenticatorFunc adapts a plain function to the Authenticator interface.
+type AuthenticatorFunc func(r *http.Request) (*Principal, error)
+
+func (f AuthenticatorFunc) Authenticate(r *http.Request) (*Principal, error) {
+	return f(r)
+}
+
+// AuthChain tries authenticators in order and returns the first principal found.
+type AuthChain []Authenticator
+
+func (c AuthChain) Authenticate(r *http.Request) (*Principal, error) {
+	for _, a := range c {
+		if a == nil {
+			continue
+		}
+		p, err := a.Authenticate(r)
+		if err != nil {
+			return nil, err
+		}
+		if p != nil {
+			return p, nil
+		}
+	}
+	return nil, nil
+}
+
+var (
+	customAuthMu         sync.RWMutex
+	customAuthenticators []Authenticator
+)
+
+// RegisterAuthenticator adds a deployment-specific authenticator (mTLS, header SSO, ...).
+// Custom authenticators run after the built-in credentials and before anonymous visitor
+// access on API routes, and after the admin session cookie on admin routes.
+func RegisterAuthenticator(a Authenticator) {
+	if a == nil {
+		return
+	}
+	customAuthMu.Lock()
+	customAuthenticators = append(customAuthenticators, a)
+	customAuthMu.Unlock()
+}
+
+func registeredAuthenticators() []Authenticator {
+	customAuthMu.RLock()
+	defer customAuthMu.RUnlock()
+	out := make([]Authenticator, len(customAuthenticators))
+	copy(out, customAuthenticators)
+	return out
+}
+
+// apiAuthChain is consulted by /api/* routes.
+func apiAuthChain() AuthChain {
+	chain := AuthChain{bearerTokenAuthenticator{}, apiTokenAuthenticator{}}
+	chain = append(chain, registeredAuthenticators()...)
+	return append(chain, anonymousVisitorAuthenticator{})
+}
+
+// adminAuthChain is consulted by admin pages and /api/admin/* routes.
+func adminAuthChain(admin *AdminSessionManager) AuthChain {
+	chain := AuthChain{adminSessionAuthenticator{admin: admin}}
+	return append(chain, registeredAuthenticators()...)
+}
+
+// ---------------------------------------------------------------------------
+// Built-in authenticators
+// ---------------------------------------------------------------------------
+
+var authAdminToken string
+var authVisitorToken string
+var authVisitorModeEnabled bool
+var authTokenMu sync.RWMutex
+
+func setAuthTokens(adminToken, visitorToken string) {
+	authTokenMu.Lock()
+	authAdminToken = strings.TrimSpace(adminToken)
+	authVisitorToken = strings.TrimSpace(visitorToken)
+	authTokenMu.Unlock()
+}
+
+func setVisitorModeEnabled(enabled bool) {
+	authTokenMu.Lock()
+	authVisitorModeEnabled = enabled
+	authTokenMu.Unlock()
+}
+
+func isVisitorModeEnabled() bool {
+	authTokenMu.RLock()
+	defer authTokenMu.RUnlock()
+	return authVisitorModeEnabled
+}
+
+func getAdminAuthToken() string {
+	authTokenMu.RLock()
+	defer authTokenMu.RUnlock()
+	return authAdminToken
+}
+
+func getVisitorAuthToken() string {
+	authTokenMu.RLock()
+	defer authTokenMu.RUnlock()
+	return authVisitorToken
+}
+
+func setAuthToken(token string) {
+	setAuthTokens(token, getVisitorAuthToken())
+}
+
+func getAuthToken() string {
+	return getAdminAuthToken()
+}
+
+// bearerTokenAuthenticator accepts the admin and visitor API tokens.
+type bearerTokenAuthenticator struct{}
+
+func (bearerTokenAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	adminToken := getAdminAuthToken()
+	visitorToken := getVisitorAuthToken()
+	if adminToken == "" {
+		return nil, nil
+	}
+
+	auth := strings.TrimSpace(r.Header.Get("Authorization"))
+	if auth == "Bearer "+adminToken {
+		return &Principal{Role: authRoleAdmin, Method: "token"}, nil
+	}
+	if visitorToken != "" && auth == "Bearer "+visitorToken {
+		return &Principal{Role: authRoleVisitor, Method: "token"}, nil
+	}
+
+	// Live event transports cannot always set headers (EventSource, browser WebSocket).
+	if r.Method == http.MethodGet && (r.URL.Path == "/api/events" || r.URL.Path == "/api/ws") {
+		queryToken := strings.TrimSpace(r.URL.Query().Get("token"))
+		if queryToken == adminToken {
+			return &Principal{Role: authRoleAdmin, Method: "token"}, nil
+		}
+		if visitorToken != "" && queryToken == visitorToken {
+			return &Principal{Role: authRoleVisitor, Method: "token"}, nil
+		}
+	}
+	return nil, nil
+}
+
+// anonymousVisitorAuthenticator grants visitor access when visitor mode is enabled
+// with no visitor token configured and the request carries no credentials.
+type anonymousVisitorAuthenticator struct{}
+
+func (anonymousVisitorAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	if getAdminAuthToken() == "" || getVisitorAuthToken() != "" || !isVisitorModeEnabled() {
+		return nil, nil
+	}
+	auth := strings.TrimSpace(r.Header.Get("Authorization"))
+	if auth == "" || auth == "Bearer " {
+		return &Principal{Role: authRoleVisitor, Method: "anonymous"}, nil
+	}
+	return nil, nil
+}
+
+// adminSessionAuthenticator accepts the admin panel session cookie.
+type adminSessionAuthenticator struct {
+	admin *AdminSessionManager
+}
+
+func (a adminSessionAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	if a.admin == nil {
+		return nil, nil
+	}
+	s, ok := a.admin.lookup(adminSessionTokenFromRequest(r))
+	if !ok || s.role != authRoleAdmin {
+		return nil, nil
+	}
+	return &Principal{Role: authRoleAdmin, Method: s.method}, nil
+}
+
+func authenticateRequestRole(r *http.Request) (authRole, bool) {
+	p, err := apiAuthChain().Authenticate(r)
+	if err != nil || p == nil {
+		return authRoleUnknown, false
+	}
+	return p.Role, true
+}
+
+// ---------------------------------------------------------------------------
+// Middleware
+// ---------------------------------------------------------------------------
+
+// authPolicy describes what a protected route accepts and how it rejects.
+type authPolicy struct {
+	roles []authRole
+	// failureScope enables brute-force tracking per client IP when non-empty.
+	failureScope authFailureScope
+	deny         func(w http.ResponseWriter, r *http.Request)
+}
+
+func (p authPolicy) allows(role authRole) bool {
+	for _, allowed := range p.roles {
+		if allowed == role {
+			return true
+		}
+	}
+	return false
+}
+
+// authMiddleware authenticates a request against chain and enforces policy.
+func authMiddleware(chain Authenticator, policy authPolicy, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clientIP := ""
+		if policy.failureScope != "" {
+			clientIP = clientIPFromRequest(r)
+			if blocked, retryAfter := globalAuthFailureProtector.IsBlocked(policy.failureScope, clientIP); blocked {
+				writeBlockedAuthResponse(w, retryAfter)
+				return
+			}
+		}
+		p, err := chain.Authenticate(r)
+		if err == nil && p != nil && policy.allows(p.Role) {
+			if policy.failureScope != "" {
+				globalAuthFailureProtector.Clear(policy.failureScope, clientIP)
+			}
+			if p.APIToken != nil {
+				if detail := p.APIToken.scopeError(r); detail != "" {
+					writeJSON(w, http.StatusForbidden, map[string]any{"detail": detail})
+					return
+				}
+			}
+			next.ServeHTTP(w, withPrincipal(r, p))
+			return
+		}
+		if policy.failureScope != "" {
+			globalAuthFailureProtector.RecordFailure(policy.failureScope, clientIP)
+		}
+		policy.deny(w, r)
+	})
+}
+
+func denyJSON(status int, detail string) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, status, map[string]any{"detail": detail})
+	}
+}
+
+func requireAdminToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if getAdminAuthToken() == "" {
+			writeJSON(w, http.StatusServiceUnavailable, map[string]any{"detail": "auth token not initialized"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// authAnyMiddleware allows both admin token and visitor token. Writes are limited by
+// the write IP allowlist.
+func authAnyMiddleware(next http.Handler) http.Handler {
+	return ipAllowlistMiddleware(ipAllowlistWrite, requireAdminToken(authMiddleware(
+		AuthenticatorFunc(func(r *http.Request) (*Principal, error) { return apiAuthChain().Authenticate(r) }),
+		authPolicy{
+			roles:        []authRole{authRoleAdmin, authRoleVisitor},
+			failureScope: authFailureScopeToken,
+			deny:         denyJSON(http.StatusUnauthorized, "unauthorized"),
+		},
+		visitorRateLimitMiddleware(next),
+	)))
+}
+
+// authAdminTokenMiddleware allows admin token only, from the admin IP allowlist.
+func authAdminTokenMiddleware(next http.Handler) http.Handler {
+	return ipAllowlistMiddleware(ipAllowlistAdmin, requireAdminToken(authMiddleware(
+		AuthenticatorFunc(func(r *http.Request) (*Principal, error) { return apiAuthChain().Authenticate(r) }),
+		authPolicy{
+			roles:        []authRole{authRoleAdmin},
+			failureScope: authFailureScopeToken,
+			deny:         denyJSON(http.StatusUnauthorized, "admin token required"),
+		},
+		next,
+	)))
+}
+
+func adminPageMiddleware(admin *AdminSessionManager, next http.Handler) http.Handler {
+	protected := authMiddleware(
+		AuthenticatorFunc(func(r *http.Request) (*Principal, error) { return adminAuthChain(admin).Authenticate(r) }),
+		authPolicy{
+			roles: []authRole{authRoleAdmin},
+			deny: func(w http.ResponseWriter, r *http.Request) {
+				http.Redirect(w, r, "/admin/login", http.StatusFound)
+			},
+		},
+		next,
+	)
+	return ipAllowlistMiddleware(ipAllowlistAdmin, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if admin == nil || !admin.Enabled() {
+			http.Error(w, "admin panel is disabled: set API_MONITOR_TOKEN_ADMIN", http.StatusServiceUnavailable)
+			return
+		}
+		protected.ServeHTTP(w, r)
+	}))
+}
+
+func adminAPIMiddleware(admin *AdminSessionManager, next http.Handler) http.Handler {
+	protected := authMiddleware(
+		AuthenticatorFunc(func(r *http.Request) (*Principal, error) { return adminAuthChain(admin).Authenticate(r) }),
+		authPolicy{
+			roles: []authRole{authRoleAdmin},
+			deny:  denyJSON(http.StatusUnauthorized, "admin login required"),
+		},
+		next,
+	)
+	return ipAllowlistMiddleware(ipAllowlistAdmin, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if admin == nil || !admin.Enabled() {
+			writeJSON(w, http.StatusServiceUnavailable, map[string]any{"detail": "admin panel is disabled"})
+			return
+		}
+		protected.ServeHTTP(w, r)
+	}))
+}
@@ -79,3 +79,58 @@ func TestVisitorChannelOperationSwitch(t *testing.T) {
 		t.Fatalf("admin should always operate")
 	}
 }
+
+func TestAuthChain_FirstPrincipalWins(t *testing.T) {
+	calls := 0
+	skip := AuthenticatorFunc(func(r *http.Request) (*Principal, error) {
+		calls++
+		return nil, nil
+	})
+	accept := AuthenticatorFunc(func(r *http.Request) (*Principal, error) {
+		calls++
+		return &Principal{Role: authRoleAdmin, Method: "custom"}, nil
+	})
+	never := AuthenticatorFunc(func(r *http.Request) (*Principal, error) {
+		t.Fatalf("authenticator after an accepting one should not run")
+		return nil, nil
+	})
+
+	p, err := AuthChain{skip, nil, accept, never}.Authenticate(httptest.NewRequest(http.MethodGet, "/", nil))
+	if err != nil || p == nil || p.Method != "custom" {
+		t.Fatalf("unexpected chain result: p=%v err=%v", p, err)
+	}
+	if calls != 2 {
+		t.Fatalf("unexpected authenticator calls: got=%d want=2", calls)
+	}
+}
+
+func TestRegisterAuthenticator_AppliesToAPIRoutes(t *testing.T) {
+	setAuthTokens("admin-token", "visitor-token")
+	customAuthMu.Lock()
+	saved := customAuthenticators
+	customAuthenticators = nil
+	customAuthMu.Unlock()
+	t.Cleanup(func() {
+		customAuthMu.Lock()
+		customAuthenticators = saved
+		customAuthMu.Unlock()
+	})
+
+	RegisterAuthenticator(AuthenticatorFunc(func(r *http.Request) (*Principal, error) {
+		if r.Header.Get("X-Forwarded-User") == "ops" {
+			return &Principal{Role: authRoleAdmin, Method: "header"}, nil
+		}
+		return nil, nil
+	}))
+
+	handler := authAdminTokenMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	req := httptest.NewRequest(http.MethodPost, "/api/targets/1/run", nil)
+	req.Header.Set("X-Forwarded-User", "ops")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("custom authenticator should grant access, got status=%d", rr.Code)
+	}
+}
@@ -0,0 +1,159 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Balance probing. Gateways that expose an account endpoint can report the credit left
+// on the key; with balance_probe set, every central run queries it after the models
+// are checked, stores the result on the target (balance_remaining, balance_checked_at,
+// balance_error) and emits balance_low while the remaining credit is below
+// balance_alert_below. A failed probe never fails the run.
+const (
+	// balanceProbeOneAPI reads GET /api/user/self of one-api / new-api; the api_key (or
+	// an Authorization extra header) must be the account's access token.
+	balanceProbeOneAPI = "oneapi"
+	// balanceProbeOpenAI reads the OpenAI-compatible GET /v1/dashboard/billing/subscription
+	// and /v1/dashboard/billing/usage, which one-api and new-api also serve per token.
+	balanceProbeOpenAI = "openai"
+)
+
+var balanceProbeKinds = []string{balanceProbeOneAPI, balanceProbeOpenAI}
+
+// oneAPIQuotaPerUSD is one-api's default QuotaPerUnit: quota units per US dollar.
+const oneAPIQuotaPerUSD = 500000.0
+
+func validBalanceProbe(kind string) bool {
+	if kind == "" {
+		return true
+	}
+	for _, k := range balanceProbeKinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// fetchBalance returns the remaining credit of target in USD.
+func fetchBalance(ctx context.Context, target *Target, client *http.Client, now time.Time) (float64, error) {
+	baseURL := normalizeBaseURL(target.BaseURL)
+	headers := targetHeaders(target)
+	switch target.BalanceProbe {
+	case balanceProbeOneAPI:
+		body, err := getBalanceJSON(ctx, client, baseURL+"/api/user/self", headers)
+		if err != nil {
+			return 0, err
+		}
+		if ok, present := body["success"].(bool); present && !ok {
+			msg, _ := body["message"].(string)
+			return 0, fmt.Errorf("GET /api/user/self failed: %s", msg)
+		}
+		data, _ := body["data"].(map[string]any)
+		quota, ok := anyFloat(data["quota"])
+		if !ok {
+			return 0, fmt.Errorf("GET /api/user/self response missing data.quota")
+		}
+		return quota / oneAPIQuotaPerUSD, nil
+	case balanceProbeOpenAI:
+		sub, err := getBalanceJSON(ctx, client, baseURL+"/v1/dashboard/billing/subscription", headers)
+		if err != nil {
+			return 0, err
+		}
+		limit, ok := anyFloat(sub["hard_limit_usd"])
+		if !ok {
+			return 0, fmt.Errorf("billing subscription response missing hard_limit_usd")
+		}
+		// OpenAI limits the usage window to 100 days.
+		q := url.Values{}
+		q.Set("start_date", now.UTC().AddDate(0, 0, -99).Format("2006-01-02"))
+		q.Set("end_date", now.UTC().AddDate(0, 0, 1).Format("2006-01-02"))
+		usage, err := getBalanceJSON(ctx, client, baseURL+"/v1/dashboard/billing/usage?"+q.Encode(), headers)
+		if err != nil {
+			return 0, err
+		}
+		cents, ok := anyFloat(usage["total_usage"])
+		if !ok {
+			return 0, fmt.Errorf("billing usage response missing total_usage")
+		}
+		return limit - cents/100.0, nil
+	}
+	return 0, fmt.Errorf("unknown balance_probe %q", target.BalanceProbe)
+}
+
+// getBalanceJSON GETs reqURL and returns its JSON object body.
+func getBalanceJSON(ctx context.Context, client *http.Client, reqURL string, headers map[string]string) (map[string]any, error) {
+	path := reqURL
+	if u, err := url.Parse(reqURL); err == nil {
+		path = u.Path
+	}
+	res, err := httpJSON(ctx, client, "GET", reqURL, headers, nil)
+	if err != nil {
+		return nil, fmt.Errorf("GET %s failed: %w", path, err)
+	}
+	if res.StatusCode != 200 {
+		msg := checkResponseBodyForError(res.JSONBody)
+		if msg == "" {
+			msg = truncStr(res.Text, 200)
+		}
+		return nil, fmt.Errorf("GET %s failed: HTTP %d - %s", path, res.StatusCode, msg)
+	}
+	body, ok := res.JSONBody.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("GET %s response must be a JSON object", path)
+	}
+	return body, nil
+}
+
+// probeBalance queries and stores the remaining credit of target when it has a
+// balance_probe, and emits balance_low when it is below balance_alert_below.
+func (ms *MonitorService) probeBalance(ctx context.Context, target *Target, client *http.Client) {
+	if target.BalanceProbe == "" {
+		return
+	}
+	now := time.Now()
+	checkedAt := float64(now.UnixMilli()) / 1000.0
+	remaining, err := fetchBalance(ctx, target, client, now)
+	if err != nil {
+		msg := truncStr(err.Error(), 500)
+		ms.logger().Warn("balance probe failed", "target", target.Name, "target_id", target.ID, "error", err)
+		if err := ms.db.UpdateTargetBalance(target.ID, nil, &msg, checkedAt); err != nil {
+			ms.logger().Error("update balance failed", "target", target.Name, "target_id", target.ID, "error", err)
+		}
+		return
+	}
+	remaining = math.Round(remaining*10000) / 10000
+	if err := ms.db.UpdateTargetBalance(target.ID, &remaining, nil, checkedAt); err != nil {
+		ms.logger().Error("update balance failed", "target", target.Name, "target_id", target.ID, "error", err)
+	}
+	if target.BalanceAlertBelow > 0 && remaining < target.BalanceAlertBelow {
+		ms.logger().Warn("balance low", "target", target.Name, "target_id", target.ID,
+			"remaining", remaining, "threshold", target.BalanceAlertBelow)
+		ms.emitJSON("balance_low", map[string]any{
+			"target_id":   target.ID,
+			"target_name": target.Name,
+			"remaining":   remaining,
+			"threshold":   target.BalanceAlertBelow,
+			"message":     fmt.Sprintf("remaining balance %.2f USD is below %.2f USD", remaining, target.BalanceAlertBelow),
+		})
+	}
+}
+
+// UpdateTargetBalance stores the result of a balance probe. A failed probe (remaining
+// nil) keeps the last known balance and records the error.
+func (d *Database) UpdateTargetBalance(targetID int, remaining *float64, errMsg *string, checkedAt float64) error {
+	defer d.touchTargets()
+	d.mu.Lock()
+	_, err := d.conn.Exec(`
+		UPDATE targets SET balance_remaining = COALESCE(?, balance_remaining), balance_checked_at = ?, balance_error = ?
+		WHERE id = ?`,
+		remaining, checkedAt, errMsg, targetID,
+	)
+	d.mu.Unlock()
+	return err
+}
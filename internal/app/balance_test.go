@@ -0,0 +1,113 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestBalanceProbeStoresRemainingAndAlerts(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/models":
+			_, _ = w.Write([]byte(`{"data":[{"id":"gpt-ok"}]}`))
+		case "/api/user/self":
+			if r.Header.Get("Authorization") != "Bearer access-token" {
+				w.WriteHeader(http.StatusUnauthorized)
+				_, _ = w.Write([]byte(`{"success":false,"message":"invalid access token"}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"success":true,"data":{"quota":250000,"used_quota":750000}}`))
+		case "/v1/dashboard/billing/subscription":
+			_, _ = w.Write([]byte(`{"object":"billing_subscription","hard_limit_usd":20}`))
+		case "/v1/dashboard/billing/usage":
+			if r.URL.Query().Get("start_date") == "" || r.URL.Query().Get("end_date") == "" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			_, _ = w.Write([]byte(`{"object":"list","total_usage":1250}`))
+		default:
+			_, _ = w.Write([]byte(`{"choices":[{"message":{"content":"ok"}}]}`))
+		}
+	}))
+	defer srv.Close()
+
+	db, err := NewDatabase(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDatabase failed: %v", err)
+	}
+	t.Cleanup(func() { _ = db.conn.Close() })
+	ms := NewMonitorService(MonitorConfig{DB: db, LogDir: t.TempDir()})
+	var mu sync.Mutex
+	alerts := 0
+	ms.SetEventCallback(func(eventType, data string) {
+		if eventType == "balance_low" {
+			mu.Lock()
+			alerts++
+			mu.Unlock()
+		}
+	})
+
+	run := func(payload map[string]any) *Target {
+		t.Helper()
+		payload["base_url"] = srv.URL
+		target, err := db.CreateTarget(payload)
+		if err != nil {
+			t.Fatalf("CreateTarget failed: %v", err)
+		}
+		if ok, msg := ms.TriggerTarget(target.ID, true); !ok {
+			t.Fatalf("TriggerTarget failed: %s", msg)
+		}
+		ms.WaitDetections()
+		got, err := db.GetTarget(target.ID)
+		if err != nil {
+			t.Fatalf("GetTarget failed: %v", err)
+		}
+		return got
+	}
+
+	oneapi := run(map[string]any{"name": "oneapi", "api_key": "access-token", "balance_probe": "oneapi", "balance_alert_below": 1})
+	if oneapi.BalanceRemaining == nil || *oneapi.BalanceRemaining != 0.5 || oneapi.BalanceError != nil || oneapi.BalanceCheckedAt == nil {
+		t.Fatalf("oneapi probe should store 0.5 USD, got remaining=%v error=%v", oneapi.BalanceRemaining, oneapi.BalanceError)
+	}
+	if oneapi.LastStatus == nil || *oneapi.LastStatus != "healthy" {
+		t.Fatalf("a low balance should not change the run status, got=%v", oneapi.LastStatus)
+	}
+
+	openai := run(map[string]any{"name": "openai", "api_key": "sk-1", "balance_probe": "openai", "balance_alert_below": 5})
+	if openai.BalanceRemaining == nil || *openai.BalanceRemaining != 7.5 {
+		t.Fatalf("openai probe should store 20 - 12.50 USD, got=%v", openai.BalanceRemaining)
+	}
+
+	failing := run(map[string]any{"name": "bad-token", "api_key": "sk-2", "balance_probe": "oneapi"})
+	if failing.BalanceRemaining != nil || failing.BalanceError == nil {
+		t.Fatalf("failed probe should record its error, got remaining=%v error=%v", failing.BalanceRemaining, failing.BalanceError)
+	}
+	if failing.LastStatus == nil || *failing.LastStatus != "healthy" {
+		t.Fatalf("a failed balance probe should not fail the run, got=%v", failing.LastStatus)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if alerts != 1 {
+		t.Fatalf("only the oneapi target is below its threshold, got %d balance_low events", alerts)
+	}
+}
+
+func TestValidateBalanceProbe(t *testing.T) {
+	for _, payload := range []map[string]any{
+		{"balance_probe": "stripe"},
+		{"balance_probe": 1},
+		{"balance_alert_below": -1},
+		{"balance_alert_below": "5"},
+	} {
+		if err := validateTargetPayload(payload); err == nil {
+			t.Fatalf("payload %v should be rejected", payload)
+		}
+	}
+	if err := validateTargetPayload(map[string]any{"balance_probe": "openai", "balance_alert_below": 2.5}); err != nil {
+		t.Fatalf("valid balance settings should pass, got=%v", err)
+	}
+}
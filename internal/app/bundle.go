@@ -0,0 +1,253 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// configBundleVersion is bumped when the bundle layout changes incompatibly.
+const configBundleVersion = 1
+
+// configBundle is a portable snapshot of a deployment's configuration: targets,
+// route rules and admin settings. Run history, logs and tokens are not included.
+type configBundle struct {
+	Version    int               `json:"version"`
+	ExportedAt float64           `json:"exported_at"`
+	Targets    []map[string]any  `json:"targets"`
+	RouteRules []RouteRule       `json:"route_rules"`
+	Settings   map[string]string `json:"settings"`
+}
+
+// bundleSettingKeys are the settings carried by a bundle. Generated runtime tokens
+// and transient state such as monitor_paused stay with the original deployment.
+var bundleSettingKeys = []string{
+	settingProxyMasterToken,
+	settingDefaultIntervalMin,
+	settingLogCleanupEnabled,
+	settingLogMaxSizeMB,
+	settingLogMaxAgeDays,
+	settingVisitorModeEnabled,
+	settingCertExpiryWarnDays,
+	settingLatencyAnomalySigma,
+	settingLatencyAnomalyPct,
+	settingNotifyFlapThreshold,
+	settingDetectConcurrency,
+	settingMaxParallelTargets,
+	settingMaxRunDurationS,
+	settingRunArchiveDays,
+	settingVisitorRateLimitPerMin,
+	settingVisitorRateLimitBurst,
+	settingOIDCIssuer,
+	settingOIDCClientID,
+	settingOIDCClientSecret,
+	settingOIDCRedirectURL,
+	settingOIDCScopes,
+	settingOIDCGroupsClaim,
+	settingOIDCAdminGroups,
+	settingOIDCVisitorGroups,
+	settingAdminIPAllowlist,
+	settingWriteIPAllowlist,
+	settingTrustedProxies,
+	settingAPIKeyRedaction,
+	settingS3Endpoint,
+	settingS3Region,
+	settingS3Bucket,
+	settingS3AccessKeyID,
+	settingS3SecretAccessKey,
+	settingS3Prefix,
+	settingS3DeleteLocal,
+	settingPagerDutyRoutingKey,
+}
+
+// bundleSecretSettings are dropped from bundles exported without secrets.
+var bundleSecretSettings = map[string]bool{
+	settingProxyMasterToken:    true,
+	settingOIDCClientSecret:    true,
+	settingS3SecretAccessKey:   true,
+	settingPagerDutyRoutingKey: true,
+}
+
+// targetBundleFields returns the configurable fields of t in CreateTarget payload form.
+func targetBundleFields(t Target, withSecrets bool) map[string]any {
+	out := map[string]any{
+		"name":                            t.Name,
+		"base_url":                        t.BaseURL,
+		"enabled":                         t.Enabled,
+		"interval_min":                    t.IntervalMin,
+		"timeout_s":                       t.TimeoutS,
+		"verify_ssl":                      t.VerifySSL,
+		"prompt":                          t.Prompt,
+		"anthropic_version":               t.AnthropicVersion,
+		"max_models":                      t.MaxModels,
+		"sort_order":                      t.SortOrder,
+		"visitor_channel_actions_enabled": t.VisitorChannelActionsEnabled,
+		"selected_models":                 t.SelectedModels,
+		"extra_headers":                   t.ExtraHeaders,
+		"proxy_url":                       t.ProxyURL,
+		"tls_fingerprint":                 t.TLSFingerprint,
+		"model_overrides":                 t.ModelOverrides,
+		"include_patterns":                t.IncludePatterns,
+		"exclude_patterns":                t.ExcludePatterns,
+		"probe_endpoints":                 t.ProbeEndpoints,
+		"stream_probe":                    t.StreamProbe,
+		"tool_probe":                      t.ToolProbe,
+		"capability_probes":               t.CapabilityProbes,
+		"fast_retry_min":                  t.FastRetryMin,
+		"detect_concurrency":              t.DetectConcurrency,
+		"max_run_duration_s":              t.MaxRunDurationS,
+		"debug_capture":                   t.DebugCapture,
+		"log_max_age_days":                t.LogMaxAgeDays,
+		"balance_probe":                   t.BalanceProbe,
+		"balance_alert_below":             t.BalanceAlertBelow,
+		"tags":                            t.Tags,
+		"severity":                        t.Severity,
+		"proxy_transform":                 t.ProxyTransform,
+	}
+	if t.SourceURL != nil {
+		out["source_url"] = *t.SourceURL
+	}
+	if withSecrets {
+		out["api_key"] = t.APIKey
+		out["api_keys"] = t.APIKeys
+	}
+	return out
+}
+
+// ExportConfigBundle snapshots the configuration stored in db. Without secrets,
+// target API keys and secret settings are left out.
+func (d *Database) ExportConfigBundle(withSecrets bool) (*configBundle, error) {
+	targets, err := d.ListTargets()
+	if err != nil {
+		return nil, err
+	}
+	rules, err := d.ListRouteRules()
+	if err != nil {
+		return nil, err
+	}
+	values, err := d.GetSettings(bundleSettingKeys)
+	if err != nil {
+		return nil, err
+	}
+	bundle := &configBundle{
+		Version:    configBundleVersion,
+		ExportedAt: float64(time.Now().UnixMilli()) / 1000.0,
+		Targets:    make([]map[string]any, 0, len(targets)),
+		RouteRules: rules,
+		Settings:   map[string]string{},
+	}
+	for _, t := range targets {
+		bundle.Targets = append(bundle.Targets, targetBundleFields(t, withSecrets))
+	}
+	for _, key := range bundleSettingKeys {
+		value, ok := values[key]
+		if !ok || (!withSecrets && bundleSecretSettings[key]) {
+			continue
+		}
+		bundle.Settings[key] = value
+	}
+	return bundle, nil
+}
+
+// bundleImportResult counts what an import changed.
+type bundleImportResult struct {
+	TargetsCreated int `json:"targets_created"`
+	TargetsUpdated int `json:"targets_updated"`
+	RulesCreated   int `json:"route_rules_created"`
+	RulesUpdated   int `json:"route_rules_updated"`
+	Settings       int `json:"settings"`
+}
+
+// ImportConfigBundle merges bundle into db. Targets are matched by name and base_url
+// and route rules by pattern; matches are updated in place, the rest are created.
+// Everything is validated before the first write.
+func (d *Database) ImportConfigBundle(bundle *configBundle) (bundleImportResult, error) {
+	var res bundleImportResult
+	if bundle.Version != configBundleVersion {
+		return res, fmt.Errorf("unsupported bundle version %d", bundle.Version)
+	}
+	existingTargets, err := d.ListTargets()
+	if err != nil {
+		return res, err
+	}
+	targetIDs := make(map[string]int, len(existingTargets))
+	for _, t := range existingTargets {
+		targetIDs[t.Name+"\x00"+normalizeBaseURL(t.BaseURL)] = t.ID
+	}
+	for i, payload := range bundle.Targets {
+		name, _ := payload["name"].(string)
+		baseURL, _ := payload["base_url"].(string)
+		if strings.TrimSpace(name) == "" || len(baseURL) < 3 {
+			return res, fmt.Errorf("targets[%d]: name and base_url are required", i)
+		}
+		if err := validateTargetPayload(payload); err != nil {
+			return res, fmt.Errorf("targets[%d]: %w", i, err)
+		}
+		if _, ok := targetIDs[name+"\x00"+normalizeBaseURL(baseURL)]; !ok {
+			if key, _ := payload["api_key"].(string); key == "" {
+				return res, fmt.Errorf("targets[%d]: api_key is required for new target %q", i, name)
+			}
+		}
+	}
+	for i := range bundle.RouteRules {
+		if err := validateRouteRule(&bundle.RouteRules[i]); err != nil {
+			return res, fmt.Errorf("route_rules[%d]: %w", i, err)
+		}
+	}
+	allowedSettings := make(map[string]bool, len(bundleSettingKeys))
+	for _, key := range bundleSettingKeys {
+		allowedSettings[key] = true
+	}
+	for key := range bundle.Settings {
+		if !allowedSettings[key] {
+			return res, fmt.Errorf("settings: unknown key %q", key)
+		}
+	}
+
+	for _, payload := range bundle.Targets {
+		name, _ := payload["name"].(string)
+		baseURL, _ := payload["base_url"].(string)
+		if id, ok := targetIDs[name+"\x00"+normalizeBaseURL(baseURL)]; ok {
+			if _, err := d.UpdateTarget(id, payload); err != nil {
+				return res, fmt.Errorf("update target %q: %w", name, err)
+			}
+			res.TargetsUpdated++
+			continue
+		}
+		if _, err := d.CreateTarget(payload); err != nil {
+			return res, fmt.Errorf("create target %q: %w", name, err)
+		}
+		res.TargetsCreated++
+	}
+
+	existingRules, err := d.ListRouteRules()
+	if err != nil {
+		return res, err
+	}
+	ruleByPattern := make(map[string]RouteRule, len(existingRules))
+	for _, rule := range existingRules {
+		ruleByPattern[rule.Pattern] = rule
+	}
+	for _, rule := range bundle.RouteRules {
+		if existing, ok := ruleByPattern[rule.Pattern]; ok {
+			rule.ID = existing.ID
+			if _, err := d.UpdateRouteRule(&rule); err != nil {
+				return res, fmt.Errorf("update route rule %q: %w", rule.Pattern, err)
+			}
+			res.RulesUpdated++
+			continue
+		}
+		if _, err := d.CreateRouteRule(rule.Pattern, rule.Route, rule.Priority, rule.Enabled, rule.Description); err != nil {
+			return res, fmt.Errorf("create route rule %q: %w", rule.Pattern, err)
+		}
+		res.RulesCreated++
+	}
+
+	for key, value := range bundle.Settings {
+		if err := d.SetSetting(key, value); err != nil {
+			return res, err
+		}
+		res.Settings++
+	}
+	return res, nil
+}
@@ -0,0 +1,78 @@
+package app
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCancelTarget_StopsRunAndMarksCancelled(t *testing.T) {
+	started := make(chan struct{}, 8)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/models" {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"data":[{"id":"gpt-a"},{"id":"gpt-b"},{"id":"gpt-c"}]}`))
+			return
+		}
+		// The request context is only cancelled on disconnect once the body has been read.
+		_, _ = io.Copy(io.Discard, r.Body)
+		started <- struct{}{}
+		// Hang until the client gives up.
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	db, err := NewDatabase(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDatabase failed: %v", err)
+	}
+	t.Cleanup(func() { _ = db.conn.Close() })
+	ms := NewMonitorService(MonitorConfig{DB: db, LogDir: t.TempDir(), DetectConcurrency: 1})
+
+	target, err := db.CreateTarget(map[string]any{"name": "slow", "base_url": srv.URL, "api_key": "k", "timeout_s": 30})
+	if err != nil {
+		t.Fatalf("CreateTarget failed: %v", err)
+	}
+	if ok, msg := ms.CancelTarget(target.ID); ok {
+		t.Fatalf("idle target should not be cancellable, got msg=%s", msg)
+	}
+	if ok, msg := ms.TriggerTarget(target.ID, true); !ok {
+		t.Fatalf("TriggerTarget failed: %s", msg)
+	}
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("detection request was not sent")
+	}
+
+	if ok, msg := ms.CancelTarget(target.ID); !ok {
+		t.Fatalf("running target should be cancellable, got msg=%s", msg)
+	}
+	waited := make(chan struct{})
+	go func() {
+		ms.WaitDetections()
+		close(waited)
+	}()
+	select {
+	case <-waited:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("cancelled run should stop promptly")
+	}
+
+	if ms.IsTargetRunning(target.ID) {
+		t.Fatalf("cancelled target should release its running slot")
+	}
+	if n := len(started); n != 0 {
+		t.Fatalf("remaining models should not be dispatched after cancel, got=%d", n)
+	}
+	runs, err := db.ListRuns(target.ID, 10)
+	if err != nil || len(runs) != 1 {
+		t.Fatalf("expected one run, got=%v err=%v", runs, err)
+	}
+	if runs[0].Status != "cancelled" || runs[0].Total != 0 {
+		t.Fatalf("run should be cancelled without rows, got status=%s total=%d", runs[0].Status, runs[0].Total)
+	}
+}
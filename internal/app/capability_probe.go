@@ -0,0 +1,487 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"regexp"
+	"slices"
+	"time"
+)
+
+// Capability probes. capability_probes lists extra checks sent on a model's primary
+// route after its regular probe passes. They never fail the check: each one records in
+// model_capabilities whether the model supports the capability, and model status
+// exposes the latest verdict (supports_json_mode, ...). A probe that gets no usable
+// answer (transport error, 429 or 5xx) records nothing and keeps the previous verdict.
+//
+//   - json_mode asks for a structured reply (response_format json_schema on chat,
+//     text.format on responses, responseSchema on gemini) and requires the reply to
+//     parse against the schema. The anthropic route has no equivalent and is skipped.
+//   - vision sends a small solid red PNG as a base64 image part with a "describe this"
+//     prompt, for models matching visionModelPatterns only. It passes when the reply
+//     names the colour, so gateways that strip the image part fail it as well as those
+//     that reject it.
+//   - long_context sends a large filler prompt with a needle; see long_context.go.
+//   - multi_turn checks that the conversation history reaches the model; see
+//     multi_turn.go.
+
+const (
+	capabilityJSONMode    = "json_mode"
+	capabilityVision      = "vision"
+	capabilityLongContext = "long_context"
+	capabilityMultiTurn   = "multi_turn"
+)
+
+// capabilityProbeNames is the set of accepted capability_probes items.
+var capabilityProbeNames = map[string]bool{
+	capabilityJSONMode:    true,
+	capabilityVision:      true,
+	capabilityLongContext: true,
+	capabilityMultiTurn:   true,
+}
+
+// capabilityProbeList returns the accepted capability_probes items, sorted.
+func capabilityProbeList() []string {
+	names := make([]string, 0, len(capabilityProbeNames))
+	for name := range capabilityProbeNames {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// CapabilityResult is the verdict of one capability probe.
+type CapabilityResult struct {
+	Capability string  `json:"capability"`
+	Supported  bool    `json:"supported"`
+	Detail     string  `json:"detail,omitempty"`
+	Duration   float64 `json:"duration"`
+	CheckedAt  float64 `json:"checked_at"`
+}
+
+// probeCapabilities runs the target's capability probes for a model on route.
+func (ms *MonitorService) probeCapabilities(ctx context.Context, target *Target, modelID, route string, client *http.Client) []CapabilityResult {
+	var out []CapabilityResult
+	for _, name := range target.CapabilityProbes {
+		if ctx.Err() != nil {
+			break
+		}
+		var res *CapabilityResult
+		switch name {
+		case capabilityJSONMode:
+			res = probeJSONMode(ctx, target, modelID, route, client)
+		case capabilityVision:
+			if matchesAnyPattern(visionModelPatterns, modelID) {
+				res = probeVision(ctx, target, modelID, route, client)
+			}
+		case capabilityLongContext:
+			res = probeLongContext(ctx, target, modelID, route, client)
+		case capabilityMultiTurn:
+			res = probeMultiTurn(ctx, target, modelID, route, client)
+		}
+		if res != nil {
+			out = append(out, *res)
+		}
+	}
+	return out
+}
+
+// capabilityOutcome turns the answer to a capability probe into a verdict; check
+// validates a 200 reply. It returns nil when the answer is inconclusive.
+func capabilityOutcome(name string, res *HttpResult, err error, check func(body any) error) *CapabilityResult {
+	if err != nil || res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= 500 {
+		return nil
+	}
+	out := &CapabilityResult{
+		Capability: name,
+		Duration:   math.Max(0, float64(res.ElapsedMs)/1000.0),
+		CheckedAt:  float64(time.Now().UnixMilli()) / 1000.0,
+	}
+	msg := checkResponseBodyForError(res.JSONBody)
+	switch {
+	case res.StatusCode != http.StatusOK:
+		if msg == "" {
+			msg = truncStr(res.Text, 500)
+		}
+		out.Detail = fmt.Sprintf("HTTP %d: %s", res.StatusCode, msg)
+	case msg != "":
+		out.Detail = "response error: " + msg
+	default:
+		if err := check(res.JSONBody); err != nil {
+			out.Detail = err.Error()
+		} else {
+			out.Supported = true
+		}
+	}
+	return out
+}
+
+// probeTurn is one message of a plain-text capability probe conversation.
+type probeTurn struct {
+	Role string // "user" or "assistant"
+	Text string
+}
+
+// capabilityRequest is a capability probe request ready to send.
+type capabilityRequest struct {
+	URL     string
+	Headers map[string]string
+	Body    map[string]any
+	Extract func(any) string
+}
+
+// textCapabilityRequest builds a plain-text request of turns for route, or returns nil
+// for an unknown route.
+func textCapabilityRequest(target *Target, modelID, route string, turns []probeTurn, maxTokens int) *capabilityRequest {
+	baseURL := normalizeBaseURL(target.BaseURL)
+	headers := targetHeaders(target)
+	messages := make([]map[string]any, 0, len(turns))
+	switch route {
+	case "chat":
+		for _, t := range turns {
+			messages = append(messages, map[string]any{"role": t.Role, "content": t.Text})
+		}
+		return &capabilityRequest{
+			URL:     baseURL + "/v1/chat/completions",
+			Headers: headers,
+			Body:    map[string]any{"model": modelID, "max_tokens": maxTokens, "messages": messages},
+			Extract: extractTextFromChat,
+		}
+	case "responses":
+		for _, t := range turns {
+			part := "input_text"
+			if t.Role == "assistant" {
+				part = "output_text"
+			}
+			messages = append(messages, map[string]any{"role": t.Role, "content": []map[string]any{{"type": part, "text": t.Text}}})
+		}
+		return &capabilityRequest{
+			URL:     baseURL + "/v1/responses",
+			Headers: headers,
+			Body:    map[string]any{"model": modelID, "max_output_tokens": maxTokens, "input": messages},
+			Extract: extractTextFromResponses,
+		}
+	case "anthropic":
+		for _, t := range turns {
+			messages = append(messages, map[string]any{"role": t.Role, "content": t.Text})
+		}
+		extHeaders := make(map[string]string, len(headers)+1)
+		for k, v := range headers {
+			extHeaders[k] = v
+		}
+		extHeaders["anthropic-version"] = target.AnthropicVersion
+		return &capabilityRequest{
+			URL:     baseURL + "/v1/messages",
+			Headers: extHeaders,
+			Body:    map[string]any{"model": modelID, "max_tokens": maxTokens, "messages": messages},
+			Extract: extractTextFromAnthropic,
+		}
+	case "gemini":
+		for _, t := range turns {
+			role := t.Role
+			if role == "assistant" {
+				role = "model"
+			}
+			messages = append(messages, map[string]any{"role": role, "parts": []map[string]any{{"text": t.Text}}})
+		}
+		return &capabilityRequest{
+			URL:     geminiModelURL(baseURL, modelID, ":generateContent"),
+			Headers: headers,
+			Body:    map[string]any{"contents": messages, "generationConfig": map[string]any{"maxOutputTokens": maxTokens}},
+			Extract: extractTextFromGemini,
+		}
+	}
+	return nil
+}
+
+// send posts the request and judges the reply text with check.
+func (c *capabilityRequest) send(ctx context.Context, client *http.Client, name string, check func(text string) error) *CapabilityResult {
+	res, err := httpJSON(ctx, client, "POST", c.URL, c.Headers, c.Body)
+	return capabilityOutcome(name, res, err, func(body any) error {
+		return check(c.Extract(body))
+	})
+}
+
+// jsonModeSchema is the schema of the json_mode probe; the reply must be an object
+// with exactly these two string fields.
+var jsonModeSchema = map[string]any{
+	"type": "object",
+	"properties": map[string]any{
+		"name":    map[string]any{"type": "string"},
+		"country": map[string]any{"type": "string"},
+	},
+	"required":             []string{"name", "country"},
+	"additionalProperties": false,
+}
+
+const jsonModePrompt = "Name the capital of France as a JSON object with the fields name and country."
+
+// probeJSONMode sends the json_mode probe. It returns nil for routes without a
+// structured output mode.
+func probeJSONMode(ctx context.Context, target *Target, modelID, route string, client *http.Client) *CapabilityResult {
+	baseURL := normalizeBaseURL(target.BaseURL)
+	var reqURL string
+	var body map[string]any
+	var extractor func(any) string
+	switch route {
+	case "chat":
+		reqURL = baseURL + "/v1/chat/completions"
+		body = map[string]any{
+			"model":      modelID,
+			"max_tokens": 100,
+			"messages":   []map[string]any{{"role": "user", "content": jsonModePrompt}},
+			"response_format": map[string]any{
+				"type":        "json_schema",
+				"json_schema": map[string]any{"name": "city", "strict": true, "schema": jsonModeSchema},
+			},
+		}
+		extractor = extractTextFromChat
+	case "responses":
+		reqURL = baseURL + "/v1/responses"
+		body = map[string]any{
+			"model":             modelID,
+			"max_output_tokens": 100,
+			"input":             []map[string]any{{"role": "user", "content": []map[string]any{{"type": "input_text", "text": jsonModePrompt}}}},
+			"text": map[string]any{
+				"format": map[string]any{"type": "json_schema", "name": "city", "strict": true, "schema": jsonModeSchema},
+			},
+		}
+		extractor = extractTextFromResponses
+	case "gemini":
+		reqURL = geminiModelURL(baseURL, modelID, ":generateContent")
+		body = map[string]any{
+			"contents": []map[string]any{{"parts": []map[string]any{{"text": jsonModePrompt}}}},
+			"generationConfig": map[string]any{
+				"maxOutputTokens":  100,
+				"responseMimeType": "application/json",
+				"responseSchema": map[string]any{
+					"type": "OBJECT",
+					"properties": map[string]any{
+						"name":    map[string]any{"type": "STRING"},
+						"country": map[string]any{"type": "STRING"},
+					},
+					"required": []string{"name", "country"},
+				},
+			},
+		}
+		extractor = extractTextFromGemini
+	default:
+		return nil
+	}
+	res, err := httpJSON(ctx, client, "POST", reqURL, targetHeaders(target), body)
+	return capabilityOutcome(capabilityJSONMode, res, err, func(body any) error {
+		return checkJSONModeReply(extractor(body))
+	})
+}
+
+// checkJSONModeReply validates the text of a json_mode reply against jsonModeSchema.
+// Markdown fences or prose around the object mean the mode was ignored.
+func checkJSONModeReply(text string) error {
+	if text == "" {
+		return fmt.Errorf("json_mode: no readable text")
+	}
+	var obj map[string]any
+	if err := json.Unmarshal([]byte(text), &obj); err != nil {
+		return fmt.Errorf("json_mode: reply is not a JSON object: %s", truncStr(text, 120))
+	}
+	for _, key := range []string{"name", "country"} {
+		if _, ok := obj[key].(string); !ok {
+			return fmt.Errorf("json_mode: reply misses the string field %q", key)
+		}
+	}
+	if len(obj) != 2 {
+		return fmt.Errorf("json_mode: reply has fields outside the schema")
+	}
+	return nil
+}
+
+// visionModelPatterns selects the models the vision probe is sent to.
+var visionModelPatterns, _ = compileModelPatterns([]string{"*gpt-4o*", "*gemini*", "*claude*"})
+
+// visionProbeImage is an 8x8 solid red PNG.
+const visionProbeImage = "iVBORw0KGgoAAAANSUhEUgAAAAgAAAAICAIAAABLbSncAAAAEUlEQVR42mP4z8CAFTEMLQkAKP8/wc53yE8AAAAASUVORK5CYII="
+
+const visionProbePrompt = "Describe this image in a few words. What colour is it?"
+
+// probeVision sends the vision probe on route.
+func probeVision(ctx context.Context, target *Target, modelID, route string, client *http.Client) *CapabilityResult {
+	baseURL := normalizeBaseURL(target.BaseURL)
+	headers := targetHeaders(target)
+	dataURL := "data:image/png;base64," + visionProbeImage
+	var reqURL string
+	var body map[string]any
+	var extractor func(any) string
+	switch route {
+	case "chat":
+		reqURL = baseURL + "/v1/chat/completions"
+		body = map[string]any{
+			"model":      modelID,
+			"max_tokens": 100,
+			"messages": []map[string]any{{"role": "user", "content": []map[string]any{
+				{"type": "text", "text": visionProbePrompt},
+				{"type": "image_url", "image_url": map[string]any{"url": dataURL}},
+			}}},
+		}
+		extractor = extractTextFromChat
+	case "responses":
+		reqURL = baseURL + "/v1/responses"
+		body = map[string]any{
+			"model":             modelID,
+			"max_output_tokens": 100,
+			"input": []map[string]any{{"role": "user", "content": []map[string]any{
+				{"type": "input_text", "text": visionProbePrompt},
+				{"type": "input_image", "image_url": dataURL},
+			}}},
+		}
+		extractor = extractTextFromResponses
+	case "anthropic":
+		reqURL = baseURL + "/v1/messages"
+		extHeaders := make(map[string]string, len(headers)+1)
+		for k, v := range headers {
+			extHeaders[k] = v
+		}
+		extHeaders["anthropic-version"] = target.AnthropicVersion
+		headers = extHeaders
+		body = map[string]any{
+			"model":      modelID,
+			"max_tokens": 100,
+			"messages": []map[string]any{{"role": "user", "content": []map[string]any{
+				{"type": "image", "source": map[string]any{"type": "base64", "media_type": "image/png", "data": visionProbeImage}},
+				{"type": "text", "text": visionProbePrompt},
+			}}},
+		}
+		extractor = extractTextFromAnthropic
+	case "gemini":
+		reqURL = geminiModelURL(baseURL, modelID, ":generateContent")
+		body = map[string]any{
+			"contents": []map[string]any{{"parts": []map[string]any{
+				{"text": visionProbePrompt},
+				{"inline_data": map[string]any{"mime_type": "image/png", "data": visionProbeImage}},
+			}}},
+			"generationConfig": map[string]any{"maxOutputTokens": 100},
+		}
+		extractor = extractTextFromGemini
+	default:
+		return nil
+	}
+	res, err := httpJSON(ctx, client, "POST", reqURL, headers, body)
+	return capabilityOutcome(capabilityVision, res, err, func(body any) error {
+		return checkVisionReply(extractor(body))
+	})
+}
+
+var visionReplyColour = regexp.MustCompile(`(?i)\bred(dish)?\b`)
+
+// checkVisionReply requires the reply to the vision probe to name the image's colour.
+func checkVisionReply(text string) error {
+	if text == "" {
+		return fmt.Errorf("vision: no readable text")
+	}
+	if !visionReplyColour.MatchString(text) {
+		return fmt.Errorf("vision: reply does not describe the image (image part dropped?): %s", truncStr(text, 120))
+	}
+	return nil
+}
+
+// upsertModelCapabilities stores the capability verdicts of a detection result.
+func upsertModelCapabilities(tx *sql.Tx, targetID int, model string, results []CapabilityResult) error {
+	for _, c := range results {
+		_, err := tx.Exec(`
+			INSERT INTO model_capabilities (target_id, model, capability, supported, detail, duration, checked_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT(target_id, model, capability) DO UPDATE SET
+				supported = excluded.supported, detail = excluded.detail,
+				duration = excluded.duration, checked_at = excluded.checked_at`,
+			targetID, model, c.Capability, boolToInt(c.Supported), c.Detail, c.Duration, c.CheckedAt)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// attachModelCapabilities sets the capability flags of the statuses in result from
+// model_capabilities; placeholders and args select the same targets.
+func (d *Database) attachModelCapabilities(result map[int][]ModelStatus, placeholders []string, args []any) error {
+	rows, err := d.read.Query(`
+		SELECT target_id, model, capability, supported FROM model_capabilities
+		WHERE target_id IN (`+joinStrings(placeholders, ",")+`)`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var targetID, supported int
+		var model, capability string
+		if err := rows.Scan(&targetID, &model, &capability, &supported); err != nil {
+			return err
+		}
+		statuses := result[targetID]
+		for i := range statuses {
+			if statuses[i].Model == model {
+				statuses[i].setCapability(capability, supported != 0)
+			}
+		}
+	}
+	return rows.Err()
+}
+
+func (s *ModelStatus) setCapability(capability string, supported bool) {
+	switch capability {
+	case capabilityJSONMode:
+		s.SupportsJSONMode = &supported
+	case capabilityVision:
+		s.SupportsVision = &supported
+	case capabilityLongContext:
+		s.SupportsLongContext = &supported
+	case capabilityMultiTurn:
+		s.SupportsMultiTurn = &supported
+	}
+}
+
+// ModelCapability is a stored capability verdict of one model.
+type ModelCapability struct {
+	Model string `json:"model"`
+	CapabilityResult
+}
+
+// ListModelCapabilities returns the capability verdicts of a target's models.
+func (d *Database) ListModelCapabilities(targetID int) ([]ModelCapability, error) {
+	rows, err := d.read.Query(`
+		SELECT model, capability, supported, detail, duration, checked_at FROM model_capabilities
+		WHERE target_id = ? ORDER BY model, capability`, targetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []ModelCapability{}
+	for rows.Next() {
+		var c ModelCapability
+		var supported int
+		if err := rows.Scan(&c.Model, &c.Capability, &supported, &c.Detail, &c.Duration, &c.CheckedAt); err != nil {
+			return nil, err
+		}
+		c.Supported = supported != 0
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+// ListTargetCapabilities -- GET /api/targets/{id}/capabilities
+// Returns the latest capability probe verdicts of the target's models.
+func (h *Handlers) ListTargetCapabilities(w http.ResponseWriter, r *http.Request) {
+	target, ok := h.loadNoteTarget(w, r)
+	if !ok {
+		return
+	}
+	items, err := h.db.ListModelCapabilities(target.ID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"items": items})
+}
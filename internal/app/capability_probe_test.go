@@ -0,0 +1,129 @@
+package app
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestJSONModeCapabilityIsStoredOnModelStatus(t *testing.T) {
+	reply := `{"name":"Paris","country":"France"}`
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		format, _ := body["response_format"].(map[string]any)
+		if format["type"] != "json_schema" {
+			t.Errorf("json_mode probe should request a json_schema response_format, got %v", body)
+		}
+		w.WriteHeader(status)
+		content, _ := json.Marshal(reply)
+		_, _ = w.Write([]byte(`{"choices":[{"message":{"content":` + string(content) + `}}]}`))
+	}))
+	defer srv.Close()
+
+	db, target := newAnalyticsTestDB(t)
+	target.BaseURL = srv.URL
+	target.CapabilityProbes = []string{capabilityJSONMode}
+	ms := NewMonitorService(MonitorConfig{DB: db, LogDir: t.TempDir()})
+	client := httpClient(5, false, nil, tlsFingerprintNone)
+
+	caps := ms.probeCapabilities(context.Background(), target, "gpt-x", "chat", client)
+	if len(caps) != 1 || !caps[0].Supported {
+		t.Fatalf("a schema-conforming reply should pass, got %+v", caps)
+	}
+	if got := ms.probeCapabilities(context.Background(), target, "claude-x", "anthropic", client); len(got) != 0 {
+		t.Fatalf("the anthropic route has no json_mode probe, got %+v", got)
+	}
+	insertAnalyticsRows(t, db, target.ID, []DetectionResult{{Protocol: "openai", Model: "gpt-x", Success: true, Duration: 1, Timestamp: 1_700_000_000, Capabilities: caps}})
+
+	// A 5xx is inconclusive and keeps the stored verdict; a fenced reply does not.
+	status = http.StatusBadGateway
+	if got := ms.probeCapabilities(context.Background(), target, "gpt-x", "chat", client); len(got) != 0 {
+		t.Fatalf("a 5xx should record nothing, got %+v", got)
+	}
+	status = http.StatusOK
+	reply = "```json\n{\"name\":\"Paris\",\"country\":\"France\"}\n```"
+	caps = ms.probeCapabilities(context.Background(), target, "gpt-x", "chat", client)
+	if len(caps) != 1 || caps[0].Supported || caps[0].Detail == "" {
+		t.Fatalf("a fenced reply should fail the json_mode probe, got %+v", caps)
+	}
+
+	statuses, err := db.GetLatestModelStatuses(target.ID)
+	if err != nil || len(statuses) != 1 || statuses[0].SupportsJSONMode == nil || !*statuses[0].SupportsJSONMode {
+		t.Fatalf("model status should report supports_json_mode, got %+v err=%v", statuses, err)
+	}
+	insertAnalyticsRows(t, db, target.ID, []DetectionResult{{Protocol: "openai", Model: "gpt-x", Success: true, Duration: 1, Timestamp: 1_700_000_100, Capabilities: caps}})
+	items, err := db.ListModelCapabilities(target.ID)
+	if err != nil || len(items) != 1 || items[0].Model != "gpt-x" || items[0].Supported {
+		t.Fatalf("the newer verdict should replace the stored one, got %+v err=%v", items, err)
+	}
+}
+
+func TestCheckJSONModeReply(t *testing.T) {
+	for _, text := range []string{``, `Paris, France`, `{"name":"Paris"}`, `{"name":"Paris","country":1}`, `{"name":"Paris","country":"France","extra":true}`} {
+		if err := checkJSONModeReply(text); err == nil {
+			t.Fatalf("%q should not pass the schema", text)
+		}
+	}
+	if err := checkJSONModeReply(`{"country":"France","name":"Paris"}`); err != nil {
+		t.Fatalf("a conforming reply should pass: %v", err)
+	}
+}
+
+func TestVisionCapabilityProbe(t *testing.T) {
+	img, err := png.Decode(base64.NewDecoder(base64.StdEncoding, strings.NewReader(visionProbeImage)))
+	if err != nil || img.Bounds().Dx() != 8 {
+		t.Fatalf("visionProbeImage should be a valid PNG: %v", err)
+	}
+	dropImage := false
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		var body struct {
+			Messages []struct {
+				Content []map[string]any `json:"content"`
+			} `json:"messages"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if len(body.Messages) != 1 || len(body.Messages[0].Content) != 2 || body.Messages[0].Content[0]["type"] != "image" || r.Header.Get("anthropic-version") == "" {
+			t.Errorf("vision probe should send an image part on the messages route, got %+v", body)
+		}
+		text := "A small red square."
+		if dropImage {
+			text = "I cannot see any image, but I'd be glad to help incredibly."
+		}
+		_, _ = w.Write([]byte(`{"type":"message","content":[{"type":"text","text":"` + text + `"}]}`))
+	}))
+	defer srv.Close()
+
+	db, target := newAnalyticsTestDB(t)
+	target.BaseURL = srv.URL
+	target.CapabilityProbes = []string{capabilityVision}
+	ms := NewMonitorService(MonitorConfig{DB: db, LogDir: t.TempDir()})
+	client := httpClient(5, false, nil, tlsFingerprintNone)
+
+	caps := ms.probeCapabilities(context.Background(), target, "claude-3-5-sonnet", "anthropic", client)
+	if len(caps) != 1 || caps[0].Capability != capabilityVision || !caps[0].Supported {
+		t.Fatalf("a reply naming the colour should pass, got %+v", caps)
+	}
+	dropImage = true
+	caps = ms.probeCapabilities(context.Background(), target, "claude-3-5-sonnet", "anthropic", client)
+	if len(caps) != 1 || caps[0].Supported {
+		t.Fatalf("a reply that ignores the image should fail, got %+v", caps)
+	}
+	if got := ms.probeCapabilities(context.Background(), target, "deepseek-chat", "anthropic", client); len(got) != 0 || requests != 2 {
+		t.Fatalf("models outside the vision patterns should not be probed, got %+v after %d requests", got, requests)
+	}
+
+	insertAnalyticsRows(t, db, target.ID, []DetectionResult{{Protocol: "anthropic", Model: "claude-3-5-sonnet", Success: true, Duration: 1, Timestamp: 1_700_000_000, Capabilities: caps}})
+	statuses, err := db.GetLatestModelStatuses(target.ID)
+	if err != nil || len(statuses) != 1 || statuses[0].SupportsVision == nil || *statuses[0].SupportsVision || statuses[0].SupportsJSONMode != nil {
+		t.Fatalf("model status should report supports_vision=false only, got %+v err=%v", statuses, err)
+	}
+}
@@ -0,0 +1,129 @@
+package app
+
+import (
+	"bytes"
+	"compress/gzip"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"net/url"
+	"strings"
+)
+
+// Debug capture. With debug_capture on, a failed detection keeps the request body it
+// sent and the raw upstream response, each capped at captureMaxBytes and stored
+// gzip-compressed in run_model_captures. Headers and query strings are left out so
+// credentials never end up in the database.
+
+// captureMaxBytes caps each captured body.
+const captureMaxBytes = 64 << 10
+
+// probeCapture is the stored request/response of one detection.
+type probeCapture struct {
+	Method   string `json:"method"`
+	URL      string `json:"url"`
+	Request  string `json:"request"`
+	Response string `json:"response"`
+	// Truncated is set when either body was cut at captureMaxBytes.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// newProbeCapture records a request body and the response text received for it.
+func newProbeCapture(method, reqURL string, body any, response string) *probeCapture {
+	c := &probeCapture{Method: method, URL: reqURL}
+	if u, err := url.Parse(reqURL); err == nil {
+		u.RawQuery, u.Fragment, u.User = "", "", nil
+		c.URL = u.String()
+	}
+	if body != nil {
+		raw, _ := json.MarshalIndent(body, "", "  ")
+		c.Request = string(raw)
+	}
+	c.Response = response
+	for _, s := range []*string{&c.Request, &c.Response} {
+		if len(*s) > captureMaxBytes {
+			*s = strings.ToValidUTF8((*s)[:captureMaxBytes], "")
+			c.Truncated = true
+		}
+	}
+	return c
+}
+
+// insertProbeCapture stores c for the run_models row just inserted by res.
+func insertProbeCapture(tx *sql.Tx, res sql.Result, runID, targetID int, c *probeCapture) error {
+	rowID, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	raw, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(raw); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	_, err = tx.Exec("INSERT INTO run_model_captures (run_model_id, run_id, target_id, data) VALUES (?, ?, ?, ?)",
+		rowID, runID, targetID, buf.Bytes())
+	return err
+}
+
+func decodeProbeCapture(data []byte) (*probeCapture, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	raw, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, err
+	}
+	var c probeCapture
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// attachCaptures fills Capture on the rows that have one. runID narrows the lookup to
+// one run; nil loads the target's captures.
+func (d *Database) attachCaptures(targetID int, runID *int, rows []ModelRow) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	query := "SELECT run_model_id, data FROM run_model_captures WHERE target_id = ?"
+	args := []any{targetID}
+	if runID != nil {
+		query += " AND run_id = ?"
+		args = append(args, *runID)
+	}
+	result, err := d.read.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer result.Close()
+
+	index := make(map[int]int, len(rows))
+	for i, row := range rows {
+		index[row.ID] = i
+	}
+	for result.Next() {
+		var rowID int
+		var data []byte
+		if err := result.Scan(&rowID, &data); err != nil {
+			return err
+		}
+		i, ok := index[rowID]
+		if !ok {
+			continue
+		}
+		if rows[i].Capture, err = decodeProbeCapture(data); err != nil {
+			return err
+		}
+	}
+	return result.Err()
+}
@@ -0,0 +1,89 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestDebugCaptureStoresFailedProbes(t *testing.T) {
+	huge := strings.Repeat("x", captureMaxBytes+100)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/models" {
+			_, _ = w.Write([]byte(`{"data":[{"id":"gpt-ok"},{"id":"gpt-bad"}]}`))
+			return
+		}
+		var body struct {
+			Model string `json:"model"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if body.Model == "gpt-bad" {
+			w.WriteHeader(http.StatusBadGateway)
+			_, _ = w.Write([]byte(`{"error":{"message":"` + huge + `"}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"choices":[{"message":{"content":"ok"}}]}`))
+	}))
+	defer srv.Close()
+
+	db, err := NewDatabase(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDatabase failed: %v", err)
+	}
+	t.Cleanup(func() { _ = db.conn.Close() })
+	ms := NewMonitorService(MonitorConfig{DB: db, LogDir: t.TempDir()})
+	h := &Handlers{db: db, monitor: ms}
+
+	logs := func(targetID int) []ModelRow {
+		req := httptest.NewRequest(http.MethodGet, "/api/targets/1/logs?captures=1", nil)
+		req.SetPathValue("id", strconv.Itoa(targetID))
+		rr := httptest.NewRecorder()
+		h.GetLogs(rr, withAuthRole(req, authRoleAdmin))
+		var out struct {
+			Items []ModelRow `json:"items"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &out); err != nil || rr.Code != http.StatusOK {
+			t.Fatalf("GetLogs failed: code=%d err=%v", rr.Code, err)
+		}
+		return out.Items
+	}
+
+	for _, capture := range []bool{true, false} {
+		target, err := db.CreateTarget(map[string]any{
+			"name": "relay-" + strconv.FormatBool(capture), "base_url": srv.URL, "api_key": "k", "debug_capture": capture,
+		})
+		if err != nil {
+			t.Fatalf("CreateTarget failed: %v", err)
+		}
+		if ok, msg := ms.TriggerTarget(target.ID, true); !ok {
+			t.Fatalf("TriggerTarget failed: %s", msg)
+		}
+		ms.WaitDetections()
+
+		rows := logs(target.ID)
+		if len(rows) != 2 {
+			t.Fatalf("run should have 2 rows, got=%d", len(rows))
+		}
+		for _, row := range rows {
+			switch {
+			case !capture || row.Success:
+				if row.Capture != nil {
+					t.Fatalf("only failed rows of debug targets should be captured, got=%+v", row)
+				}
+			case row.Capture == nil:
+				t.Fatalf("failed row should carry its capture")
+			default:
+				c := row.Capture
+				if !strings.Contains(c.Request, `"gpt-bad"`) || !strings.HasPrefix(c.Response, `{"error"`) || !c.Truncated ||
+					len(c.Response) != captureMaxBytes || c.URL != srv.URL+"/v1/chat/completions" {
+					t.Fatalf("capture should hold the request and capped response and URL, got url=%s truncated=%v len=%d",
+						c.URL, c.Truncated, len(c.Response))
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package app
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Group robot channels for Feishu (Lark), DingTalk and WeCom. Each takes the robot's
+// webhook_url; Feishu and DingTalk robots with "signature" security also take the
+// signing secret. Messages are cards (Feishu) or markdown (DingTalk, WeCom) with the
+// severity, the message and, for run_completed, the run counts.
+
+// notificationMarkdown renders the body of n as markdown lines.
+func notificationMarkdown(n Notification) string {
+	lines := []string{"**Severity:** " + n.Severity}
+	if n.Event == "run_completed" {
+		status, _ := n.Data["status"].(string)
+		total, _ := anyFloat(n.Data["total"])
+		success, _ := anyFloat(n.Data["success"])
+		fail, _ := anyFloat(n.Data["fail"])
+		lines = append(lines, fmt.Sprintf("**Status:** %s", status),
+			fmt.Sprintf("**Models:** %d checked, %d ok, %d failed", int(total), int(success), int(fail)))
+	}
+	if n.Message != "" {
+		lines = append(lines, n.Message)
+	}
+	if len(n.Tags) > 0 {
+		lines = append(lines, "**Tags:** "+strings.Join(n.Tags, ", "))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// checkRobotResponse fails on the error code some robots return with HTTP 200.
+func checkRobotResponse(body []byte) error {
+	var out struct {
+		Code    *int   `json:"code"`
+		ErrCode *int   `json:"errcode"`
+		Msg     string `json:"msg"`
+		ErrMsg  string `json:"errmsg"`
+	}
+	if json.Unmarshal(body, &out) != nil {
+		return nil
+	}
+	if out.Code != nil && *out.Code != 0 {
+		return fmt.Errorf("code %d - %s", *out.Code, out.Msg)
+	}
+	if out.ErrCode != nil && *out.ErrCode != 0 {
+		return fmt.Errorf("errcode %d - %s", *out.ErrCode, out.ErrMsg)
+	}
+	return nil
+}
+
+func hmacBase64(key, message string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(message))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+type robotNotifier struct {
+	kind       string
+	webhookURL string
+	secret     string
+}
+
+func newRobotNotifier(kind string) func(cfg map[string]any) (notifier, error) {
+	return func(cfg map[string]any) (notifier, error) {
+		webhookURL, err := configURL(cfg, "webhook_url", "")
+		if err != nil {
+			return nil, err
+		}
+		return &robotNotifier{kind: kind, webhookURL: webhookURL, secret: stringFromAny(cfg["secret"], "")}, nil
+	}
+}
+
+var feishuCardColor = map[string]string{
+	severityInfo:     "green",
+	severityWarning:  "yellow",
+	severityError:    "orange",
+	severityCritical: "red",
+}
+
+func (r *robotNotifier) Notify(ctx context.Context, n Notification) error {
+	reqURL := r.webhookURL
+	var payload map[string]any
+	switch r.kind {
+	case "feishu":
+		payload = map[string]any{
+			"msg_type": "interactive",
+			"card": map[string]any{
+				"header": map[string]any{
+					"title":    map[string]any{"tag": "plain_text", "content": n.Title},
+					"template": feishuCardColor[n.Severity],
+				},
+				"elements": []any{
+					map[string]any{"tag": "div", "text": map[string]any{"tag": "lark_md", "content": notificationMarkdown(n)}},
+				},
+			},
+		}
+		if r.secret != "" {
+			// Feishu signs with the timestamp and secret as the HMAC key and an empty message.
+			ts := strconv.FormatInt(time.Now().Unix(), 10)
+			payload["timestamp"] = ts
+			payload["sign"] = hmacBase64(ts+"\n"+r.secret, "")
+		}
+	case "dingtalk":
+		payload = map[string]any{
+			"msgtype":  "markdown",
+			"markdown": map[string]any{"title": n.Title, "text": "### " + n.Title + "\n\n" + strings.ReplaceAll(notificationMarkdown(n), "\n", "\n\n")},
+		}
+		if r.secret != "" {
+			ts := strconv.FormatInt(time.Now().UnixMilli(), 10)
+			sep := "?"
+			if strings.Contains(reqURL, "?") {
+				sep = "&"
+			}
+			reqURL += sep + "timestamp=" + ts + "&sign=" + url.QueryEscape(hmacBase64(r.secret, ts+"\n"+r.secret))
+		}
+	case "wecom":
+		payload = map[string]any{
+			"msgtype":  "markdown",
+			"markdown": map[string]any{"content": "### " + n.Title + "\n" + notificationMarkdown(n)},
+		}
+	}
+	body, err := postNotificationJSON(ctx, reqURL, nil, payload)
+	if err == nil {
+		err = checkRobotResponse(body)
+	}
+	if err != nil {
+		// The webhook URL carries the robot's access token.
+		return fmt.Errorf("%s robot failed: %s", r.kind, strings.ReplaceAll(err.Error(), r.webhookURL, "****"))
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRobotNotifiersSignAndFormat(t *testing.T) {
+	got := map[string]map[string]any{}
+	queries := map[string]string{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		got[r.URL.Path] = body
+		queries[r.URL.Path] = r.URL.RawQuery
+		if r.URL.Path == "/wecom-bad" {
+			_, _ = w.Write([]byte(`{"errcode":93000,"errmsg":"invalid webhook url"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"code":0,"errcode":0}`))
+	}))
+	defer srv.Close()
+
+	n := Notification{Event: "run_completed", Severity: severityWarning, Title: "prod run degraded", Message: "3/4 models ok",
+		Data: map[string]any{"status": "degraded", "total": 4.0, "success": 3.0, "fail": 1.0}}
+	for kind, cfg := range map[string]map[string]any{
+		"feishu":   {"webhook_url": srv.URL + "/feishu", "secret": "fs-secret"},
+		"dingtalk": {"webhook_url": srv.URL + "/dingtalk?access_token=abc", "secret": "SEC123"},
+		"wecom":    {"webhook_url": srv.URL + "/wecom?key=k"},
+	} {
+		notifier, err := buildNotifier(kind, cfg)
+		if err != nil {
+			t.Fatalf("%s: buildNotifier failed: %v", kind, err)
+		}
+		if err := notifier.Notify(context.Background(), n); err != nil {
+			t.Fatalf("%s: Notify failed: %v", kind, err)
+		}
+	}
+
+	feishu := got["/feishu"]
+	ts, _ := feishu["timestamp"].(string)
+	if feishu["msg_type"] != "interactive" || feishu["sign"] != hmacBase64(ts+"\n"+"fs-secret", "") {
+		t.Fatalf("feishu card should be signed, got=%v", feishu)
+	}
+	card, _ := feishu["card"].(map[string]any)
+	header, _ := card["header"].(map[string]any)
+	if header["template"] != "yellow" || !strings.Contains(jsonText(card), "4 checked, 3 ok, 1 failed") {
+		t.Fatalf("feishu card should carry the run counts, got=%v", card)
+	}
+	if q := queries["/dingtalk"]; !strings.Contains(q, "access_token=abc&timestamp=") || !strings.Contains(q, "&sign=") {
+		t.Fatalf("dingtalk webhook should be signed in the query, got=%q", q)
+	}
+	if md, _ := got["/wecom"]["markdown"].(map[string]any); !strings.HasPrefix(md["content"].(string), "### prod run degraded") {
+		t.Fatalf("unexpected wecom markdown: %v", got["/wecom"])
+	}
+
+	bad, _ := buildNotifier("wecom", map[string]any{"webhook_url": srv.URL + "/wecom-bad?key=secret-key"})
+	err := bad.Notify(context.Background(), n)
+	if err == nil || !strings.Contains(err.Error(), "93000") || strings.Contains(err.Error(), "secret-key") {
+		t.Fatalf("robot error codes should fail without leaking the webhook, got=%v", err)
+	}
+}
+
+func TestRunCompletedIsOptIn(t *testing.T) {
+	routes := []NotificationRoute{
+		{MinSeverity: severityInfo, ChannelIDs: []int{1}, Enabled: true},
+		{EventTypes: []string{"run_completed"}, MinSeverity: severityInfo, ChannelIDs: []int{2}, Enabled: true},
+	}
+	if got := routeNotification(routes, "run_completed", severityInfo, nil); len(got) != 1 || got[0] != 2 {
+		t.Fatalf("run_completed should only match routes that list it, got=%v", got)
+	}
+}
+
+func jsonText(v any) string {
+	raw, _ := json.Marshal(v)
+	return string(raw)
+}
@@ -0,0 +1,423 @@
+package app
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+)
+
+const cliUsage = `usage: api-monitor [command] [flags]
+
+commands:
+  serve        run the web server and scheduler (default)
+  agent        run as a probe agent (same as serve --agent)
+  check        run a one-shot detection for a target and print the results
+  export       write targets, route rules and settings as a JSON bundle
+  import       merge a JSON bundle into the database
+  migrate      create or upgrade the database schema and exit
+               (--encrypt-secrets also encrypts stored API keys)
+  healthcheck  probe the local /api/health and exit non-zero on failure
+
+Run "api-monitor <command> -h" for the flags of a command.
+`
+
+// RunCLI dispatches the command line and returns the process exit code.
+// Without a command it behaves like "serve", so existing invocations keep working.
+func RunCLI(webFS fs.FS, args []string) int {
+	cmd := "serve"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		cmd, args = args[0], args[1:]
+	}
+	switch cmd {
+	case "serve", "agent":
+		fset := newCLIFlagSet(cmd)
+		configPath := fset.String("config", "", "path to a YAML/TOML/JSON config file (defaults to CONFIG_FILE); env vars override file values")
+		agentMode := fset.Bool("agent", cmd == "agent", "run as a probe agent (requires AGENT_SERVER_URL and AGENT_TOKEN)")
+		if !parseCLIFlags(fset, args, configPath) {
+			return 2
+		}
+		if *agentMode {
+			StartAgent()
+		} else {
+			Start(webFS)
+		}
+		return 0
+	case "check":
+		return runCheckCommand(args, os.Stdout)
+	case "export":
+		return runExportCommand(args, os.Stdout)
+	case "import":
+		return runImportCommand(args, os.Stdout)
+	case "migrate":
+		return runMigrateCommand(args, os.Stdout)
+	case "healthcheck":
+		return runHealthcheckCommand(args, os.Stdout)
+	case "help":
+		fmt.Fprint(os.Stdout, cliUsage)
+		return 0
+	}
+	fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", cmd, cliUsage)
+	return 2
+}
+
+func newCLIFlagSet(name string) *flag.FlagSet {
+	fset := flag.NewFlagSet(name, flag.ContinueOnError)
+	fset.SetOutput(os.Stderr)
+	return fset
+}
+
+// parseCLIFlags parses args and applies the config file; it reports false after
+// printing the problem.
+func parseCLIFlags(fset *flag.FlagSet, args []string, configPath *string) bool {
+	if err := fset.Parse(args); err != nil {
+		return false
+	}
+	if err := ApplyConfigFile(*configPath); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return false
+	}
+	configureLogging()
+	return true
+}
+
+// openCLIDatabase opens DATA_DIR/registry.db with all migrations applied.
+func openCLIDatabase() (*Database, error) {
+	return openDatabase(filepath.Join(dataDirFromEnv(), "registry.db"))
+}
+
+func runMigrateCommand(args []string, out io.Writer) int {
+	fset := newCLIFlagSet("migrate")
+	configPath := fset.String("config", "", "path to a YAML/TOML/JSON config file")
+	encrypt := fset.Bool("encrypt-secrets", false, "encrypt plaintext target API keys and secret settings with API_MONITOR_ENCRYPTION_KEY")
+	if !parseCLIFlags(fset, args, configPath) {
+		return 2
+	}
+	db, err := openCLIDatabase()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "migrate failed: %v\n", err)
+		return 1
+	}
+	defer db.Close()
+	if *encrypt {
+		n, err := db.EncryptStoredSecrets()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "encrypt secrets failed: %v\n", err)
+			return 1
+		}
+		fmt.Fprintf(out, "encrypted %d stored secrets\n", n)
+	}
+	fmt.Fprintf(out, "database schema is up to date: %s\n", filepath.Join(dataDirFromEnv(), "registry.db"))
+	return 0
+}
+
+func runExportCommand(args []string, out io.Writer) int {
+	fset := newCLIFlagSet("export")
+	configPath := fset.String("config", "", "path to a YAML/TOML/JSON config file")
+	output := fset.String("o", "", "write the bundle to this file instead of stdout")
+	noSecrets := fset.Bool("no-secrets", false, "omit target API keys, the proxy master token and the OIDC client secret")
+	if !parseCLIFlags(fset, args, configPath) {
+		return 2
+	}
+	db, err := openCLIDatabase()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "export failed: %v\n", err)
+		return 1
+	}
+	defer db.Close()
+	bundle, err := db.ExportConfigBundle(!*noSecrets)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "export failed: %v\n", err)
+		return 1
+	}
+	data, err := json.MarshalIndent(bundle, "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "export failed: %v\n", err)
+		return 1
+	}
+	data = append(data, '\n')
+	if *output == "" {
+		_, _ = out.Write(data)
+		return 0
+	}
+	// Bundles usually contain API keys, so they are not world readable.
+	if err := os.WriteFile(*output, data, 0o600); err != nil {
+		fmt.Fprintf(os.Stderr, "export failed: %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(out, "exported %d targets, %d route rules, %d settings to %s\n", len(bundle.Targets), len(bundle.RouteRules), len(bundle.Settings), *output)
+	return 0
+}
+
+func runImportCommand(args []string, out io.Writer) int {
+	fset := newCLIFlagSet("import")
+	configPath := fset.String("config", "", "path to a YAML/TOML/JSON config file")
+	input := fset.String("i", "", "read the bundle from this file instead of stdin")
+	if !parseCLIFlags(fset, args, configPath) {
+		return 2
+	}
+	var r io.Reader = os.Stdin
+	if *input != "" {
+		f, err := os.Open(*input)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "import failed: %v\n", err)
+			return 1
+		}
+		defer f.Close()
+		r = f
+	}
+	var bundle configBundle
+	if err := json.NewDecoder(r).Decode(&bundle); err != nil {
+		fmt.Fprintf(os.Stderr, "import failed: invalid bundle: %v\n", err)
+		return 1
+	}
+	db, err := openCLIDatabase()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "import failed: %v\n", err)
+		return 1
+	}
+	defer db.Close()
+	res, err := db.ImportConfigBundle(&bundle)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "import failed: %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(out, "imported targets created=%d updated=%d, route rules created=%d updated=%d, settings=%d\n",
+		res.TargetsCreated, res.TargetsUpdated, res.RulesCreated, res.RulesUpdated, res.Settings)
+	fmt.Fprintln(out, "restart a running server to apply imported settings and route rules")
+	return 0
+}
+
+// runHealthcheckCommand is meant for container HEALTHCHECK probes, so the image
+// does not need curl. It derives the local URL from PORT and the TLS settings.
+func runHealthcheckCommand(args []string, out io.Writer) int {
+	fset := newCLIFlagSet("healthcheck")
+	configPath := fset.String("config", "", "path to a YAML/TOML/JSON config file")
+	rawURL := fset.String("url", "", "health endpoint to probe (defaults to the local server)")
+	timeout := fset.Duration("timeout", 5*time.Second, "request timeout")
+	if !parseCLIFlags(fset, args, configPath) {
+		return 2
+	}
+	target := *rawURL
+	if target == "" {
+		scheme := "http"
+		if tlsServeConfigFromEnv(dataDirFromEnv()).Enabled() {
+			scheme = "https"
+		}
+		target = fmt.Sprintf("%s://127.0.0.1:%d/api/health", scheme, envInt("PORT", 8081))
+	}
+	if err := probeHealth(target, *timeout); err != nil {
+		fmt.Fprintf(os.Stderr, "unhealthy: %v\n", err)
+		return 1
+	}
+	fmt.Fprintln(out, "ok")
+	return 0
+}
+
+func probeHealth(target string, timeout time.Duration) error {
+	client := &http.Client{
+		Timeout: timeout,
+		// The certificate names the public host, not the loopback address being probed.
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}},
+	}
+	resp, err := client.Get(target)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var body struct {
+		OK bool `json:"ok"`
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: HTTP %d", target, resp.StatusCode)
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&body); err != nil || !body.OK {
+		return fmt.Errorf("%s: unexpected response", target)
+	}
+	return nil
+}
+
+// checkReport is the JSON output of the check command.
+type checkReport struct {
+	Target  string            `json:"target"`
+	BaseURL string            `json:"base_url"`
+	Total   int               `json:"total"`
+	Success int               `json:"success"`
+	Fail    int               `json:"fail"`
+	Error   string            `json:"error,omitempty"`
+	Rows    []DetectionResult `json:"rows"`
+}
+
+func runCheckCommand(args []string, out io.Writer) int {
+	fset := newCLIFlagSet("check")
+	configPath := fset.String("config", "", "path to a YAML/TOML/JSON config file")
+	targetRef := fset.String("target", "", "target name or base URL; an unknown URL is probed ad hoc")
+	apiKey := fset.String("api-key", "", "API key for an ad-hoc URL target (defaults to CHECK_API_KEY)")
+	jsonOut := fset.Bool("json", false, "print the results as JSON")
+	timeout := fset.Duration("timeout", 10*time.Minute, "abort the whole check after this long")
+	if !parseCLIFlags(fset, args, configPath) {
+		return 2
+	}
+	if strings.TrimSpace(*targetRef) == "" {
+		fmt.Fprintln(os.Stderr, "check: --target is required")
+		return 2
+	}
+	if *apiKey == "" {
+		*apiKey = os.Getenv("CHECK_API_KEY")
+	}
+
+	db, err := openCLIDatabase()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "check failed: %v\n", err)
+		return 1
+	}
+	defer db.Close()
+	target, err := resolveCheckTarget(db, *targetRef, *apiKey)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "check failed: %v\n", err)
+		return 2
+	}
+
+	concurrency := envInt("MONITOR_DETECT_CONCURRENCY", 3)
+	if concurrency < 1 {
+		concurrency = 3
+	}
+	// Like the agent, a one-shot check keeps no run history or log files.
+	ms := &MonitorService{detectConcurrency: concurrency}
+	rules, err := db.ListRouteRules()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "check failed: %v\n", err)
+		return 1
+	}
+	ms.applyRouteRules(rules)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, *timeout)
+	defer cancel()
+
+	report := runCheck(ctx, ms, target)
+	if *jsonOut {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(report)
+	} else {
+		printCheckReport(out, report)
+	}
+	if report.Error != "" || report.Fail > 0 || report.Total == 0 {
+		return 1
+	}
+	return 0
+}
+
+// resolveCheckTarget finds a stored target by name or base URL. A URL that matches
+// no target becomes an ad-hoc target with the default detection settings.
+func resolveCheckTarget(db *Database, ref, apiKey string) (*Target, error) {
+	ref = strings.TrimSpace(ref)
+	targets, err := db.ListTargets()
+	if err != nil {
+		return nil, err
+	}
+	for i := range targets {
+		if targets[i].Name == ref {
+			return &targets[i], nil
+		}
+	}
+	isURL := strings.HasPrefix(ref, "http://") || strings.HasPrefix(ref, "https://")
+	if isURL {
+		for i := range targets {
+			if normalizeBaseURL(targets[i].BaseURL) == normalizeBaseURL(ref) {
+				return &targets[i], nil
+			}
+		}
+	}
+	if !isURL {
+		return nil, fmt.Errorf("target %q not found", ref)
+	}
+	if apiKey == "" {
+		return nil, errors.New("--api-key (or CHECK_API_KEY) is required for a target that is not stored")
+	}
+	return &Target{
+		Name:             ref,
+		BaseURL:          ref,
+		APIKey:           apiKey,
+		Enabled:          true,
+		TimeoutS:         30,
+		Prompt:           defaultTargetPrompt,
+		AnthropicVersion: defaultAnthropicVersion,
+		TLSFingerprint:   tlsFingerprintChrome,
+		SelectedModels:   []string{},
+		ExtraHeaders:     map[string]string{},
+		ModelOverrides:   map[string]ModelOverride{},
+	}, nil
+}
+
+func runCheck(ctx context.Context, ms *MonitorService, target *Target) checkReport {
+	report := checkReport{Target: target.Name, BaseURL: target.BaseURL, Rows: []DetectionResult{}}
+	client, err := targetHTTPClient(target)
+	if err != nil {
+		report.Error = err.Error()
+		return report
+	}
+	resultCh, _, err := ms.detectModels(ctx, target, client)
+	if err != nil {
+		report.Error = err.Error()
+		return report
+	}
+	for row := range resultCh {
+		report.Rows = append(report.Rows, row)
+	}
+	if ctx.Err() != nil {
+		report.Error = fmt.Sprintf("check aborted: %v", ctx.Err())
+	}
+	sort.Slice(report.Rows, func(i, j int) bool {
+		if report.Rows[i].Model != report.Rows[j].Model {
+			return report.Rows[i].Model < report.Rows[j].Model
+		}
+		return report.Rows[i].Endpoint < report.Rows[j].Endpoint
+	})
+	for _, row := range report.Rows {
+		report.Total++
+		if row.Success {
+			report.Success++
+		} else {
+			report.Fail++
+		}
+	}
+	return report
+}
+
+func printCheckReport(out io.Writer, report checkReport) {
+	fmt.Fprintf(out, "target: %s (%s)\n", report.Target, report.BaseURL)
+	if report.Error != "" {
+		fmt.Fprintf(out, "error: %s\n", report.Error)
+	}
+	tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "MODEL\tPROTOCOL\tENDPOINT\tRESULT\tDURATION\tERROR")
+	for _, row := range report.Rows {
+		result := "ok"
+		if !row.Success {
+			result = "fail"
+		}
+		errText := ""
+		if row.Error != nil {
+			errText = truncStr(*row.Error, 120)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%.2fs\t%s\n", row.Model, row.Protocol, row.Endpoint, result, row.Duration, errText)
+	}
+	_ = tw.Flush()
+	fmt.Fprintf(out, "total=%d success=%d fail=%d\n", report.Total, report.Success, report.Fail)
+}
@@ -0,0 +1,143 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newBundleTestDB(t *testing.T) *Database {
+	t.Helper()
+	db, err := openDatabase(filepath.Join(t.TempDir(), "registry.db"))
+	if err != nil {
+		t.Fatalf("openDatabase failed: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	return db
+}
+
+// viaJSON mirrors the export/import commands, which pass bundles through a JSON file.
+func viaJSON(t *testing.T, b *configBundle) *configBundle {
+	t.Helper()
+	data, err := json.Marshal(b)
+	if err != nil {
+		t.Fatalf("marshal bundle failed: %v", err)
+	}
+	var out configBundle
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatalf("unmarshal bundle failed: %v", err)
+	}
+	return &out
+}
+
+func TestConfigBundle_RoundTrip(t *testing.T) {
+	src := newBundleTestDB(t)
+	if _, err := src.CreateTarget(map[string]any{"name": "t1", "base_url": "https://example.com", "api_key": "k1", "interval_min": 15, "selected_models": []any{"gpt-4o"}}); err != nil {
+		t.Fatalf("CreateTarget failed: %v", err)
+	}
+	if _, err := src.CreateRouteRule("claude-*", "anthropic", 10, true, "claude"); err != nil {
+		t.Fatalf("CreateRouteRule failed: %v", err)
+	}
+	if err := src.SetSetting(settingLogMaxSizeMB, "42"); err != nil {
+		t.Fatalf("SetSetting failed: %v", err)
+	}
+	if err := src.SetSetting(settingProxyMasterToken, "secret"); err != nil {
+		t.Fatalf("SetSetting failed: %v", err)
+	}
+
+	redacted, err := src.ExportConfigBundle(false)
+	if err != nil {
+		t.Fatalf("ExportConfigBundle failed: %v", err)
+	}
+	redacted = viaJSON(t, redacted)
+	if _, ok := redacted.Targets[0]["api_key"]; ok {
+		t.Fatalf("export without secrets should omit api_key, got=%v", redacted.Targets[0])
+	}
+	if _, ok := redacted.Settings[settingProxyMasterToken]; ok {
+		t.Fatalf("export without secrets should omit proxy master token")
+	}
+
+	bundle, err := src.ExportConfigBundle(true)
+	if err != nil {
+		t.Fatalf("ExportConfigBundle failed: %v", err)
+	}
+	bundle = viaJSON(t, bundle)
+	dst := newBundleTestDB(t)
+	if _, err := dst.ImportConfigBundle(redacted); err == nil {
+		t.Fatalf("importing a new target without api_key should fail")
+	}
+	res, err := dst.ImportConfigBundle(bundle)
+	if err != nil {
+		t.Fatalf("ImportConfigBundle failed: %v", err)
+	}
+	if res.TargetsCreated != 1 || res.RulesCreated != 1 {
+		t.Fatalf("first import should create target and rule, got=%+v", res)
+	}
+	targets, _ := dst.ListTargets()
+	if len(targets) != 1 || targets[0].APIKey != "k1" || targets[0].IntervalMin != 15 || len(targets[0].SelectedModels) != 1 {
+		t.Fatalf("imported target should match the source, got=%+v", targets)
+	}
+	if v, _, _ := dst.GetSetting(settingLogMaxSizeMB); v != "42" {
+		t.Fatalf("imported setting should match, got=%q", v)
+	}
+
+	res, err = dst.ImportConfigBundle(redacted)
+	if err != nil {
+		t.Fatalf("re-import failed: %v", err)
+	}
+	if res.TargetsUpdated != 1 || res.TargetsCreated != 0 || res.RulesUpdated != 1 {
+		t.Fatalf("re-import should update in place, got=%+v", res)
+	}
+	targets, _ = dst.ListTargets()
+	if len(targets) != 1 || targets[0].APIKey != "k1" {
+		t.Fatalf("re-import without secrets should keep the stored api key, got=%+v", targets)
+	}
+}
+
+func TestResolveCheckTarget(t *testing.T) {
+	db := newBundleTestDB(t)
+	stored, err := db.CreateTarget(map[string]any{"name": "main", "base_url": "https://api.example.com/", "api_key": "k"})
+	if err != nil {
+		t.Fatalf("CreateTarget failed: %v", err)
+	}
+	for _, ref := range []string{"main", "https://api.example.com"} {
+		got, err := resolveCheckTarget(db, ref, "")
+		if err != nil || got.ID != stored.ID {
+			t.Fatalf("%q should resolve to the stored target, got=%v err=%v", ref, got, err)
+		}
+	}
+	if _, err := resolveCheckTarget(db, "missing", ""); err == nil {
+		t.Fatalf("unknown name should fail")
+	}
+	if _, err := resolveCheckTarget(db, "https://other.example.com", ""); err == nil {
+		t.Fatalf("ad-hoc url without api key should fail")
+	}
+	adhoc, err := resolveCheckTarget(db, "https://other.example.com", "k2")
+	if err != nil || adhoc.ID != 0 || adhoc.APIKey != "k2" || adhoc.TimeoutS != 30 {
+		t.Fatalf("ad-hoc url should build a default target, got=%+v err=%v", adhoc, err)
+	}
+}
+
+func TestProbeHealth(t *testing.T) {
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]any{"ok": true})
+	}))
+	defer healthy.Close()
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]any{"ok": false})
+	}))
+	defer broken.Close()
+
+	if err := probeHealth(healthy.URL, time.Second); err != nil {
+		t.Fatalf("healthy server should pass, got=%v", err)
+	}
+	if err := probeHealth(broken.URL, time.Second); err == nil {
+		t.Fatalf("503 should fail the healthcheck")
+	}
+	if err := probeHealth("http://127.0.0.1:1/api/health", time.Second); err == nil {
+		t.Fatalf("unreachable server should fail the healthcheck")
+	}
+}
@@ -0,0 +1,435 @@
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// configFileKeys maps "section.key" paths of the config file to the environment
+// variables they provide defaults for. Real environment variables always win.
+var configFileKeys = map[string]string{
+	"port":     "PORT",
+	"data_dir": "DATA_DIR",
+
+	"auth.admin_token":                "API_MONITOR_TOKEN_ADMIN",
+	"auth.visitor_token":              "API_MONITOR_TOKEN_VISITOR",
+	"auth.admin_ip_allowlist":         "ADMIN_IP_ALLOWLIST",
+	"auth.write_ip_allowlist":         "WRITE_IP_ALLOWLIST",
+	"auth.trusted_proxies":            "TRUSTED_PROXIES",
+	"auth.api_key_redaction":          "API_KEY_REDACTION",
+	"auth.visitor_rate_limit_per_min": "VISITOR_RATE_LIMIT_PER_MIN",
+	"auth.visitor_rate_limit_burst":   "VISITOR_RATE_LIMIT_BURST",
+
+	"monitor.default_interval_min":  "DEFAULT_INTERVAL_MIN",
+	"monitor.detect_concurrency":    "MONITOR_DETECT_CONCURRENCY",
+	"monitor.max_parallel_targets":  "MONITOR_MAX_PARALLEL_TARGETS",
+	"monitor.max_run_duration_s":    "MONITOR_MAX_RUN_DURATION_S",
+	"monitor.run_archive_days":      "MONITOR_RUN_ARCHIVE_DAYS",
+	"monitor.cert_expiry_warn_days": "CERT_EXPIRY_WARN_DAYS",
+	"monitor.latency_anomaly_sigma": "LATENCY_ANOMALY_SIGMA",
+	"monitor.latency_anomaly_pct":   "LATENCY_ANOMALY_PCT",
+	"monitor.notify_flap_threshold": "NOTIFY_FLAP_THRESHOLD",
+
+	"database.read_conns":      "MONITOR_DB_READ_CONNS",
+	"database.busy_timeout_ms": "MONITOR_DB_BUSY_TIMEOUT_MS",
+
+	"logs.cleanup_enabled": "LOG_CLEANUP_ENABLED",
+	"logs.max_size_mb":     "LOG_MAX_SIZE_MB",
+	"logs.max_age_days":    "LOG_MAX_AGE_DAYS",
+	"logs.level":           "LOG_LEVEL",
+	"logs.format":          "LOG_FORMAT",
+
+	"proxy.master_token": "PROXY_MASTER_TOKEN",
+
+	"encryption.key":      "API_MONITOR_ENCRYPTION_KEY",
+	"encryption.key_file": "API_MONITOR_ENCRYPTION_KEY_FILE",
+
+	"sync.url":             "TARGETS_SYNC_URL",
+	"sync.token":           "TARGETS_SYNC_TOKEN",
+	"sync.interval_s":      "TARGETS_SYNC_INTERVAL_S",
+	"sync.mode":            "TARGETS_SYNC_MODE",
+	"sync.disable_missing": "TARGETS_SYNC_DISABLE_MISSING",
+
+	"tracing.endpoint":        "OTEL_EXPORTER_OTLP_ENDPOINT",
+	"tracing.traces_endpoint": "OTEL_EXPORTER_OTLP_TRACES_ENDPOINT",
+	"tracing.headers":         "OTEL_EXPORTER_OTLP_HEADERS",
+	"tracing.service_name":    "OTEL_SERVICE_NAME",
+
+	"result_sink.type":     "RESULT_SINK",
+	"result_sink.url":      "RESULT_SINK_URL",
+	"result_sink.headers":  "RESULT_SINK_HEADERS",
+	"result_sink.username": "RESULT_SINK_USERNAME",
+	"result_sink.password": "RESULT_SINK_PASSWORD",
+	"result_sink.index":    "RESULT_SINK_INDEX",
+	"result_sink.labels":   "RESULT_SINK_LABELS",
+
+	"mqtt.broker":                "MQTT_BROKER",
+	"mqtt.username":              "MQTT_USERNAME",
+	"mqtt.password":              "MQTT_PASSWORD",
+	"mqtt.topic_prefix":          "MQTT_TOPIC_PREFIX",
+	"mqtt.client_id":             "MQTT_CLIENT_ID",
+	"event_fanout.url":           "EVENT_FANOUT_URL",
+	"event_fanout.subject":       "EVENT_FANOUT_SUBJECT",
+	"event_fanout.subscribe":     "EVENT_FANOUT_SUBSCRIBE",
+	"metrics_push.format":        "METRICS_PUSH",
+	"metrics_push.url":           "METRICS_PUSH_URL",
+	"metrics_push.token":         "METRICS_PUSH_TOKEN",
+	"metrics_push.interval":      "METRICS_PUSH_INTERVAL",
+	"metrics_push.prefix":        "METRICS_PUSH_PREFIX",
+	"metrics_push.tags":          "METRICS_PUSH_TAGS",
+	"heartbeat.url":              "HEARTBEAT_URL",
+	"heartbeat.fail_url":         "HEARTBEAT_FAIL_URL",
+	"proxy_audit.mode":           "PROXY_AUDIT",
+	"proxy_audit.sample_rate":    "PROXY_AUDIT_SAMPLE_RATE",
+	"proxy_audit.max_bytes":      "PROXY_AUDIT_MAX_BYTES",
+	"proxy_audit.retention_days": "PROXY_AUDIT_RETENTION_DAYS",
+
+	"report.schedule":      "REPORT_SCHEDULE",
+	"report.webhook_url":   "REPORT_WEBHOOK_URL",
+	"report.smtp_addr":     "REPORT_SMTP_ADDR",
+	"report.smtp_username": "REPORT_SMTP_USERNAME",
+	"report.smtp_password": "REPORT_SMTP_PASSWORD",
+	"report.email_from":    "REPORT_EMAIL_FROM",
+	"report.email_to":      "REPORT_EMAIL_TO",
+
+	"tls.cert":               "TLS_CERT",
+	"tls.key":                "TLS_KEY",
+	"tls.autocert_domains":   "AUTOCERT_DOMAINS",
+	"tls.autocert_email":     "AUTOCERT_EMAIL",
+	"tls.autocert_cache_dir": "AUTOCERT_CACHE_DIR",
+	"tls.autocert_http_addr": "AUTOCERT_HTTP_ADDR",
+
+	"agent.server_url":      "AGENT_SERVER_URL",
+	"agent.token":           "AGENT_TOKEN",
+	"agent.region":          "AGENT_REGION",
+	"agent.poll_interval_s": "AGENT_POLL_INTERVAL_S",
+}
+
+// ApplyConfigFile loads path (or CONFIG_FILE when path is empty) and exports every
+// value whose environment variable is not already set. The format follows the file
+// extension: .yaml/.yml, .toml or .json. An empty path with no CONFIG_FILE is a no-op.
+func ApplyConfigFile(path string) error {
+	if path == "" {
+		path = strings.TrimSpace(os.Getenv("CONFIG_FILE"))
+	}
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read config file: %w", err)
+	}
+	values, err := parseConfigFile(path, data)
+	if err != nil {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+	env, err := configFileEnv(values)
+	if err != nil {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+
+	configFileMu.Lock()
+	defer configFileMu.Unlock()
+	configFilePath = path
+	applied, overridden := 0, 0
+	for name, value := range env {
+		if _, ok := os.LookupEnv(name); ok && !configFileApplied[name] {
+			overridden++
+			continue
+		}
+		if err := os.Setenv(name, value); err != nil {
+			return err
+		}
+		configFileApplied[name] = true
+		applied++
+	}
+	// Values removed from the file since the last load fall back to their defaults.
+	for name := range configFileApplied {
+		if _, ok := env[name]; !ok {
+			_ = os.Unsetenv(name)
+			delete(configFileApplied, name)
+		}
+	}
+	componentLogger(nil, "main").Info("config file loaded", "path", path, "applied", applied, "overridden_by_env", overridden)
+	return nil
+}
+
+var (
+	configFileMu sync.Mutex
+	// configFilePath is the file loaded at startup, re-read on reload.
+	configFilePath string
+	// configFileApplied lists the variables set from the file rather than the real
+	// environment, so a reload may replace them.
+	configFileApplied = map[string]bool{}
+)
+
+// reloadConfigFile re-applies the config file loaded at startup, if any.
+func reloadConfigFile() error {
+	configFileMu.Lock()
+	path := configFilePath
+	configFileMu.Unlock()
+	if path == "" {
+		return nil
+	}
+	return ApplyConfigFile(path)
+}
+
+// configFileEnv maps flattened config values to environment variable names and
+// rejects unknown keys so typos do not go unnoticed.
+func configFileEnv(values map[string]string) (map[string]string, error) {
+	env := make(map[string]string, len(values))
+	var unknown []string
+	for key, value := range values {
+		name, ok := configFileKeys[key]
+		if !ok {
+			unknown = append(unknown, key)
+			continue
+		}
+		env[name] = value
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("unknown keys: %s", strings.Join(unknown, ", "))
+	}
+	return env, nil
+}
+
+// parseConfigFile flattens a config file into "section.key" => value. Lists become
+// comma-separated values, matching the list-valued environment variables.
+func parseConfigFile(path string, data []byte) (map[string]string, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return parseYAMLConfig(data)
+	case ".toml":
+		return parseTOMLConfig(data)
+	case ".json":
+		return parseJSONConfig(data)
+	}
+	return nil, fmt.Errorf("unsupported config format %q (use .yaml, .yml, .toml or .json)", filepath.Ext(path))
+}
+
+func parseJSONConfig(data []byte) (map[string]string, error) {
+	var root map[string]any
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&root); err != nil {
+		return nil, err
+	}
+	out := map[string]string{}
+	var walk func(prefix string, v any) error
+	walk = func(prefix string, v any) error {
+		switch val := v.(type) {
+		case map[string]any:
+			for k, child := range val {
+				key := k
+				if prefix != "" {
+					key = prefix + "." + k
+				}
+				if err := walk(key, child); err != nil {
+					return err
+				}
+			}
+		case []any:
+			items := make([]string, 0, len(val))
+			for _, item := range val {
+				items = append(items, fmt.Sprint(item))
+			}
+			out[prefix] = strings.Join(items, ",")
+		case nil:
+			out[prefix] = ""
+		default:
+			out[prefix] = fmt.Sprint(val)
+		}
+		return nil
+	}
+	if err := walk("", root); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// parseYAMLConfig understands the YAML subset used by config files: nested mappings
+// by indentation, scalars, "- item" lists and inline [a, b] lists.
+func parseYAMLConfig(data []byte) (map[string]string, error) {
+	type frame struct {
+		indent int
+		path   string
+	}
+	out := map[string]string{}
+	var stack []frame
+	listKey, listIndent := "", -1
+
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	lineNo := 0
+	for sc.Scan() {
+		lineNo++
+		raw := stripConfigComment(sc.Text())
+		if strings.TrimSpace(raw) == "" || strings.TrimSpace(raw) == "---" {
+			continue
+		}
+		indent := len(raw) - len(strings.TrimLeft(raw, " "))
+		if strings.HasPrefix(strings.TrimLeft(raw, " "), "\t") {
+			return nil, fmt.Errorf("line %d: tabs are not allowed for indentation", lineNo)
+		}
+		line := strings.TrimSpace(raw)
+
+		if strings.HasPrefix(line, "- ") || line == "-" {
+			if listKey == "" || indent < listIndent {
+				return nil, fmt.Errorf("line %d: list item without a key", lineNo)
+			}
+			item := unquoteConfigScalar(strings.TrimSpace(strings.TrimPrefix(line, "-")))
+			if out[listKey] != "" {
+				out[listKey] += ","
+			}
+			out[listKey] += item
+			continue
+		}
+		listKey, listIndent = "", -1
+
+		key, value, ok := strings.Cut(line, ":")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("line %d: expected key: value", lineNo)
+		}
+		for len(stack) > 0 && stack[len(stack)-1].indent >= indent {
+			stack = stack[:len(stack)-1]
+		}
+		path := key
+		if len(stack) > 0 {
+			path = stack[len(stack)-1].path + "." + key
+		}
+		value = strings.TrimSpace(value)
+		if value == "" {
+			// Either a nested mapping or a block list follows.
+			stack = append(stack, frame{indent: indent, path: path})
+			listKey, listIndent = path, indent
+			continue
+		}
+		if strings.HasPrefix(value, "[") {
+			items, err := parseInlineConfigList(value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			out[path] = items
+			continue
+		}
+		out[path] = unquoteConfigScalar(value)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// parseTOMLConfig understands the TOML subset used by config files: [section] tables,
+// key = value pairs with strings, numbers, booleans and single-line arrays.
+func parseTOMLConfig(data []byte) (map[string]string, error) {
+	out := map[string]string{}
+	section := ""
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	lineNo := 0
+	for sc.Scan() {
+		lineNo++
+		line := strings.TrimSpace(stripConfigComment(sc.Text()))
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") || strings.HasPrefix(line, "[[") {
+				return nil, fmt.Errorf("line %d: invalid table header", lineNo)
+			}
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+		if !ok || key == "" || value == "" {
+			return nil, fmt.Errorf("line %d: expected key = value", lineNo)
+		}
+		if section != "" {
+			key = section + "." + key
+		}
+		if strings.HasPrefix(value, "[") {
+			items, err := parseInlineConfigList(value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			out[key] = items
+			continue
+		}
+		if !isQuotedConfigScalar(value) && value != "true" && value != "false" {
+			if _, err := strconv.ParseFloat(value, 64); err != nil {
+				return nil, fmt.Errorf("line %d: strings must be quoted", lineNo)
+			}
+		}
+		out[key] = unquoteConfigScalar(value)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func parseInlineConfigList(value string) (string, error) {
+	if !strings.HasSuffix(value, "]") {
+		return "", fmt.Errorf("unterminated list")
+	}
+	inner := strings.TrimSpace(value[1 : len(value)-1])
+	if inner == "" {
+		return "", nil
+	}
+	parts := strings.Split(inner, ",")
+	items := make([]string, 0, len(parts))
+	for _, p := range parts {
+		if p = strings.TrimSpace(p); p != "" {
+			items = append(items, unquoteConfigScalar(p))
+		}
+	}
+	return strings.Join(items, ","), nil
+}
+
+func isQuotedConfigScalar(v string) bool {
+	return len(v) >= 2 && ((v[0] == '"' && v[len(v)-1] == '"') || (v[0] == '\'' && v[len(v)-1] == '\''))
+}
+
+func unquoteConfigScalar(v string) string {
+	if !isQuotedConfigScalar(v) {
+		return v
+	}
+	if v[0] == '"' {
+		if s, err := strconv.Unquote(v); err == nil {
+			return s
+		}
+	}
+	return v[1 : len(v)-1]
+}
+
+// stripConfigComment removes a trailing # comment that is not inside quotes.
+func stripConfigComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
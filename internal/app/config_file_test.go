@@ -0,0 +1,89 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseConfigFile_YAMLAndTOML(t *testing.T) {
+	yamlSrc := `
+port: 9090 # comment
+auth:
+  admin_token: "tok#1"
+  trusted_proxies: ["10.0.0.1", 10.0.0.2]
+tls:
+  autocert_domains:
+    - a.example.com
+    - b.example.com
+agent:
+  server_url: https://monitor.example.com
+`
+	tomlSrc := `
+port = 9090
+[auth]
+admin_token = "tok#1" # comment
+trusted_proxies = ["10.0.0.1", "10.0.0.2"]
+[tls]
+autocert_domains = ["a.example.com", "b.example.com"]
+[agent]
+server_url = 'https://monitor.example.com'
+`
+	want := map[string]string{
+		"port":                 "9090",
+		"auth.admin_token":     "tok#1",
+		"auth.trusted_proxies": "10.0.0.1,10.0.0.2",
+		"tls.autocert_domains": "a.example.com,b.example.com",
+		"agent.server_url":     "https://monitor.example.com",
+	}
+	for name, src := range map[string]string{"cfg.yaml": yamlSrc, "cfg.toml": tomlSrc} {
+		got, err := parseConfigFile(name, []byte(src))
+		if err != nil {
+			t.Fatalf("%s: parseConfigFile failed: %v", name, err)
+		}
+		if len(got) != len(want) {
+			t.Fatalf("%s: should parse %d keys, got=%v", name, len(want), got)
+		}
+		for k, v := range want {
+			if got[k] != v {
+				t.Fatalf("%s: %s should be %q, got=%q", name, k, v, got[k])
+			}
+		}
+	}
+	if _, err := parseConfigFile("cfg.toml", []byte("[auth]\nadmin_token = bare\n")); err == nil {
+		t.Fatalf("unquoted toml string should be rejected")
+	}
+}
+
+func TestApplyConfigFile_EnvOverridesFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	src := "monitor:\n  detect_concurrency: 7\n  max_parallel_targets: 5\n"
+	if err := os.WriteFile(path, []byte(src), 0o600); err != nil {
+		t.Fatalf("write config failed: %v", err)
+	}
+	t.Cleanup(func() {
+		configFileMu.Lock()
+		configFilePath, configFileApplied = "", map[string]bool{}
+		configFileMu.Unlock()
+	})
+	t.Setenv("MONITOR_MAX_PARALLEL_TARGETS", "1")
+	t.Setenv("MONITOR_DETECT_CONCURRENCY", "")
+	os.Unsetenv("MONITOR_DETECT_CONCURRENCY")
+
+	if err := ApplyConfigFile(path); err != nil {
+		t.Fatalf("ApplyConfigFile failed: %v", err)
+	}
+	if got := os.Getenv("MONITOR_DETECT_CONCURRENCY"); got != "7" {
+		t.Fatalf("file value should apply when env is unset, got=%q", got)
+	}
+	if got := os.Getenv("MONITOR_MAX_PARALLEL_TARGETS"); got != "1" {
+		t.Fatalf("env should override file value, got=%q", got)
+	}
+
+	if err := os.WriteFile(path, []byte("monitor:\n  detect_concurency: 7\n"), 0o600); err != nil {
+		t.Fatalf("write config failed: %v", err)
+	}
+	if err := ApplyConfigFile(path); err == nil {
+		t.Fatalf("unknown keys should be rejected")
+	}
+}
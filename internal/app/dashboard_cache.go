@@ -0,0 +1,62 @@
+package app
+
+import "sync"
+
+// Dashboard cache. ListTargets and Dashboard are polled by every open dashboard and
+// refetched on each SSE event; the target list with latest model statuses and history
+// is rebuilt only after the database reports a change to targets or run_models (a run
+// completing, a target edited). Per-request parts such as principal filtering, key
+// redaction and running flags are still computed on every call.
+
+// dashboardSnapshot is the cached data. It is shared between requests and must not be
+// modified.
+type dashboardSnapshot struct {
+	gen     uint64
+	targets []Target
+	// models holds the latest model statuses of each target with history attached.
+	models map[int][]ModelStatus
+}
+
+type dashboardCache struct {
+	mu   sync.Mutex
+	snap *dashboardSnapshot
+}
+
+// touchTargets invalidates cached dashboard data. Writers defer it so it runs after the
+// write is visible.
+func (d *Database) touchTargets() {
+	d.targetsGen.Add(1)
+}
+
+// dashboardSnapshot returns the cached dashboard data, rebuilding it when stale.
+// Concurrent callers wait for a single rebuild.
+func (h *Handlers) dashboardSnapshot() (*dashboardSnapshot, error) {
+	h.dash.mu.Lock()
+	defer h.dash.mu.Unlock()
+	gen := h.db.targetsGen.Load()
+	if h.dash.snap != nil && h.dash.snap.gen == gen {
+		return h.dash.snap, nil
+	}
+
+	targets, err := h.db.ListTargets()
+	if err != nil {
+		return nil, err
+	}
+	targetIDs := make([]int, 0, len(targets))
+	for i := range targets {
+		targetIDs = append(targetIDs, targets[i].ID)
+	}
+	models, err := h.db.GetLatestModelStatusesBatch(targetIDs)
+	if err != nil {
+		return nil, err
+	}
+	historyByTarget, err := h.db.GetModelHistoriesBatch(targetIDs, modelHistoryPoints)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range targetIDs {
+		attachModelHistory(models[id], historyByTarget[id])
+	}
+	h.dash.snap = &dashboardSnapshot{gen: gen, targets: targets, models: models}
+	return h.dash.snap, nil
+}
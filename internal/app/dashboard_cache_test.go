@@ -0,0 +1,65 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDashboardCacheInvalidatesOnWrites(t *testing.T) {
+	db, err := NewDatabase(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDatabase failed: %v", err)
+	}
+	t.Cleanup(func() { _ = db.conn.Close() })
+	h := &Handlers{db: db, monitor: NewMonitorService(MonitorConfig{DB: db, LogDir: t.TempDir()})}
+
+	target, err := db.CreateTarget(map[string]any{"name": "a", "base_url": "https://example.com", "api_key": "k"})
+	if err != nil {
+		t.Fatalf("CreateTarget failed: %v", err)
+	}
+	list := func() string {
+		req := withAuthRole(httptest.NewRequest(http.MethodGet, "/api/targets", nil), authRoleAdmin)
+		rr := httptest.NewRecorder()
+		h.ListTargets(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("ListTargets should succeed, got status=%d", rr.Code)
+		}
+		return rr.Body.String()
+	}
+
+	list()
+	first, _ := h.dashboardSnapshot()
+	second, _ := h.dashboardSnapshot()
+	if first != second {
+		t.Fatalf("snapshot should be reused while nothing changed")
+	}
+
+	if _, err := db.UpdateTarget(target.ID, map[string]any{"name": "renamed"}); err != nil {
+		t.Fatalf("UpdateTarget failed: %v", err)
+	}
+	if body := list(); !strings.Contains(body, `"name":"renamed"`) {
+		t.Fatalf("target edit should invalidate the cache, got=%s", body)
+	}
+
+	runID, err := db.CreateRun(target.ID, float64(time.Now().Unix()), "", nil, runModeFull)
+	if err != nil {
+		t.Fatalf("CreateRun failed: %v", err)
+	}
+	if err := db.InsertModelRows(runID, target.ID, []DetectionResult{{Protocol: "openai", Model: "gpt-a", Success: true, Timestamp: float64(time.Now().Unix())}}); err != nil {
+		t.Fatalf("InsertModelRows failed: %v", err)
+	}
+	if body := list(); !strings.Contains(body, `"gpt-a"`) {
+		t.Fatalf("new results should invalidate the cache, got=%s", body)
+	}
+
+	if _, err := db.DeleteTarget(target.ID); err != nil {
+		t.Fatalf("DeleteTarget failed: %v", err)
+	}
+	if body := list(); strings.Contains(body, `"renamed"`) {
+		t.Fatalf("deleted target should leave the cache, got=%s", body)
+	}
+}
@@ -8,48 +8,109 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	_ "modernc.org/sqlite"
 )
 
-// Database wraps SQLite operations with a write mutex.
+// Database wraps SQLite operations with a write mutex. Writes and transactions go
+// through conn, a single connection; plain reads go through read, a pool of read-only
+// connections that WAL lets run alongside the writer.
 type Database struct {
 	conn *sql.DB
+	read *sql.DB
 	mu   sync.Mutex
+	path string
+	// targetsGen is bumped after every write to targets or run_models; see dashboardCache.
+	targetsGen atomic.Uint64
 }
 
-// NewDatabase creates (or opens) an SQLite database at path.
+// Defaults applied to new targets when the payload leaves them empty.
+const (
+	defaultTargetPrompt     = "What is the exact model identifier (model string) you are using for this chat/session?"
+	defaultAnthropicVersion = "2025-09-29"
+)
+
+// Connection defaults; see DatabaseOptions.
+const (
+	defaultDBReadConns   = 4
+	defaultDBBusyTimeout = 5 * time.Second
+	maxDBReadConns       = 64
+)
+
+// DatabaseOptions tunes the SQLite connection pools.
+type DatabaseOptions struct {
+	// ReadConns is the size of the read pool; 0 sends reads through the writer
+	// connection, serializing them behind writes.
+	ReadConns int
+	// BusyTimeout is how long a statement waits for a lock held by another
+	// connection or process before failing with SQLITE_BUSY.
+	BusyTimeout time.Duration
+}
+
+// NewDatabase creates (or opens) an SQLite database at path with default options.
 func NewDatabase(path string) (*Database, error) {
+	return NewDatabaseWithOptions(path, DatabaseOptions{ReadConns: defaultDBReadConns, BusyTimeout: defaultDBBusyTimeout})
+}
+
+// NewDatabaseWithOptions creates (or opens) an SQLite database at path.
+func NewDatabaseWithOptions(path string, opts DatabaseOptions) (*Database, error) {
 	dir := filepath.Dir(path)
 	if err := os.MkdirAll(dir, 0o755); err != nil {
 		return nil, fmt.Errorf("create db dir: %w", err)
 	}
-	conn, err := sql.Open("sqlite", path)
+	// Write transactions take the lock up front so they wait out busy_timeout instead
+	// of failing when upgrading from a read.
+	conn, err := sql.Open("sqlite", sqliteDSN(path, opts.BusyTimeout, "_pragma=foreign_keys(1)", "_pragma=journal_mode(WAL)", "_txlock=immediate"))
 	if err != nil {
 		return nil, err
 	}
 	conn.SetMaxOpenConns(1)
-	if _, err := conn.Exec("PRAGMA foreign_keys = ON"); err != nil {
-		conn.Close()
-		return nil, err
-	}
-	if _, err := conn.Exec("PRAGMA journal_mode = WAL"); err != nil {
+	if err := conn.Ping(); err != nil {
 		conn.Close()
 		return nil, err
 	}
-	db := &Database{conn: conn}
+	db := &Database{conn: conn, read: conn, path: path}
 	if err := db.InitDB(); err != nil {
 		conn.Close()
 		return nil, err
 	}
+	if opts.ReadConns > 0 {
+		read, err := sql.Open("sqlite", sqliteDSN(path, opts.BusyTimeout, "_pragma=foreign_keys(1)", "_pragma=query_only(1)"))
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		read.SetMaxOpenConns(min(opts.ReadConns, maxDBReadConns))
+		read.SetMaxIdleConns(min(opts.ReadConns, maxDBReadConns))
+		if err := read.Ping(); err != nil {
+			read.Close()
+			conn.Close()
+			return nil, err
+		}
+		db.read = read
+	}
 	return db, nil
 }
 
+// sqliteDSN appends busy_timeout and params to path. Pragmas in the DSN apply to every
+// connection the pool opens, unlike a one-off PRAGMA statement.
+func sqliteDSN(path string, busyTimeout time.Duration, params ...string) string {
+	query := fmt.Sprintf("_pragma=busy_timeout(%d)", busyTimeout.Milliseconds())
+	for _, p := range params {
+		query += "&" + p
+	}
+	return path + "?" + query
+}
+
 // Close closes the underlying database connection.
 func (d *Database) Close() error {
 	d.mu.Lock()
 	defer d.mu.Unlock()
+	if d.read != d.conn {
+		_ = d.read.Close()
+	}
 	return d.conn.Close()
 }
 
@@ -82,7 +143,39 @@ func (d *Database) InitDB() error {
 			source_url TEXT,
 			sort_order INTEGER NOT NULL DEFAULT 0,
 			visitor_channel_actions_enabled INTEGER NOT NULL DEFAULT 0,
-			selected_models TEXT NOT NULL DEFAULT '[]'
+			selected_models TEXT NOT NULL DEFAULT '[]',
+			extra_headers TEXT NOT NULL DEFAULT '{}',
+			proxy_url TEXT NOT NULL DEFAULT '',
+			tls_fingerprint TEXT NOT NULL DEFAULT 'chrome',
+			tls_cert_not_after REAL,
+			tls_cert_chain TEXT NOT NULL DEFAULT '[]',
+			tls_cert_checked_at REAL,
+			model_overrides TEXT NOT NULL DEFAULT '{}',
+			include_patterns TEXT NOT NULL DEFAULT '[]',
+			exclude_patterns TEXT NOT NULL DEFAULT '[]',
+			probe_endpoints TEXT NOT NULL DEFAULT '[]',
+			stream_probe INTEGER NOT NULL DEFAULT 0,
+			tool_probe INTEGER NOT NULL DEFAULT 0,
+			capability_probes TEXT NOT NULL DEFAULT '[]',
+			agent_id INTEGER,
+			snoozed_until REAL,
+			fast_retry_min INTEGER NOT NULL DEFAULT 0,
+			retry_interval_min INTEGER,
+			template_id INTEGER,
+			detect_concurrency INTEGER NOT NULL DEFAULT 0,
+			max_run_duration_s INTEGER NOT NULL DEFAULT 0,
+			debug_capture INTEGER NOT NULL DEFAULT 0,
+			deleted_at REAL,
+			log_max_age_days INTEGER NOT NULL DEFAULT 0,
+			balance_probe TEXT NOT NULL DEFAULT '',
+			balance_alert_below REAL NOT NULL DEFAULT 0,
+			balance_remaining REAL,
+			balance_checked_at REAL,
+			balance_error TEXT,
+			api_keys TEXT NOT NULL DEFAULT '[]',
+			tags TEXT NOT NULL DEFAULT '[]',
+			severity TEXT NOT NULL DEFAULT 'critical',
+			proxy_transform TEXT NOT NULL DEFAULT '{}'
 		);
 
 		CREATE TABLE IF NOT EXISTS runs (
@@ -96,6 +189,9 @@ func (d *Database) InitDB() error {
 			fail INTEGER NOT NULL DEFAULT 0,
 			log_file TEXT,
 			error TEXT,
+			agent_id INTEGER,
+			mode TEXT NOT NULL DEFAULT 'full',
+			log_object_key TEXT,
 			FOREIGN KEY(target_id) REFERENCES targets(id) ON DELETE CASCADE
 		);
 
@@ -117,10 +213,74 @@ func (d *Database) InitDB() error {
 			status_code INTEGER,
 			route TEXT,
 			endpoint TEXT,
+			dns_ms REAL,
+			connect_ms REAL,
+			tls_ms REAL,
+			ttfb_ms REAL,
+			body_ms REAL,
+			ttft_ms REAL,
+			tokens_per_sec REAL,
+			output_tokens INTEGER,
+			slow INTEGER NOT NULL DEFAULT 0,
+			error_category TEXT,
+			served_model TEXT,
+			mismatch INTEGER NOT NULL DEFAULT 0,
+			prompt_tokens INTEGER,
+			completion_tokens INTEGER,
+			reasoning_tokens INTEGER,
 			FOREIGN KEY(run_id) REFERENCES runs(id) ON DELETE CASCADE,
 			FOREIGN KEY(target_id) REFERENCES targets(id) ON DELETE CASCADE
 		);
 
+		CREATE TABLE IF NOT EXISTS run_model_captures (
+			run_model_id INTEGER PRIMARY KEY,
+			run_id INTEGER NOT NULL,
+			target_id INTEGER NOT NULL,
+			data BLOB NOT NULL,
+			FOREIGN KEY(run_model_id) REFERENCES run_models(id) ON DELETE CASCADE
+		);
+
+		CREATE TABLE IF NOT EXISTS model_capabilities (
+			target_id INTEGER NOT NULL,
+			model TEXT NOT NULL,
+			capability TEXT NOT NULL,
+			supported INTEGER NOT NULL,
+			detail TEXT NOT NULL DEFAULT '',
+			duration REAL NOT NULL DEFAULT 0,
+			checked_at REAL NOT NULL,
+			PRIMARY KEY(target_id, model, capability),
+			FOREIGN KEY(target_id) REFERENCES targets(id) ON DELETE CASCADE
+		);
+
+		CREATE TABLE IF NOT EXISTS model_daily_stats (
+			target_id INTEGER NOT NULL,
+			model TEXT NOT NULL,
+			day REAL NOT NULL,
+			total INTEGER NOT NULL,
+			success INTEGER NOT NULL,
+			avg_duration REAL,
+			p95_duration REAL,
+			PRIMARY KEY(target_id, model, day),
+			FOREIGN KEY(target_id) REFERENCES targets(id) ON DELETE CASCADE
+		);
+
+		CREATE TABLE IF NOT EXISTS target_key_status (
+			target_id INTEGER NOT NULL,
+			fingerprint TEXT NOT NULL,
+			healthy INTEGER NOT NULL,
+			checked_at REAL NOT NULL,
+			error TEXT,
+			state TEXT NOT NULL DEFAULT 'unknown',
+			last_ok_at REAL,
+			status_code INTEGER,
+			error_category TEXT,
+			auth_failures INTEGER NOT NULL DEFAULT 0,
+			rate_limits INTEGER NOT NULL DEFAULT 0,
+			consecutive_failures INTEGER NOT NULL DEFAULT 0,
+			PRIMARY KEY(target_id, fingerprint),
+			FOREIGN KEY(target_id) REFERENCES targets(id) ON DELETE CASCADE
+		);
+
 		CREATE TABLE IF NOT EXISTS app_settings (
 			key TEXT PRIMARY KEY,
 			value TEXT NOT NULL,
@@ -138,6 +298,9 @@ func (d *Database) InitDB() error {
 
 		CREATE INDEX IF NOT EXISTS idx_run_models_run
 		ON run_models(run_id);
+
+		CREATE INDEX IF NOT EXISTS idx_run_models_time
+		ON run_models(timestamp);
 	`)
 	if err != nil {
 		return fmt.Errorf("init schema: %w", err)
@@ -149,8 +312,12 @@ func (d *Database) InitDB() error {
 // EnsureSettingDefault inserts a setting only when it does not exist.
 func (d *Database) EnsureSettingDefault(key, value string) error {
 	now := float64(time.Now().UnixMilli()) / 1000.0
+	value, err := encryptSetting(key, value)
+	if err != nil {
+		return err
+	}
 	d.mu.Lock()
-	_, err := d.conn.Exec(`
+	_, err = d.conn.Exec(`
 		INSERT INTO app_settings (key, value, updated_at)
 		VALUES (?, ?, ?)
 		ON CONFLICT(key) DO NOTHING
@@ -162,8 +329,12 @@ func (d *Database) EnsureSettingDefault(key, value string) error {
 // SetSetting upserts one app setting.
 func (d *Database) SetSetting(key, value string) error {
 	now := float64(time.Now().UnixMilli()) / 1000.0
+	value, err := encryptSetting(key, value)
+	if err != nil {
+		return err
+	}
 	d.mu.Lock()
-	_, err := d.conn.Exec(`
+	_, err = d.conn.Exec(`
 		INSERT INTO app_settings (key, value, updated_at)
 		VALUES (?, ?, ?)
 		ON CONFLICT(key) DO UPDATE SET
@@ -177,16 +348,52 @@ func (d *Database) SetSetting(key, value string) error {
 // GetSetting returns (value, found, error).
 func (d *Database) GetSetting(key string) (string, bool, error) {
 	var value string
-	err := d.conn.QueryRow("SELECT value FROM app_settings WHERE key = ?", key).Scan(&value)
+	err := d.read.QueryRow("SELECT value FROM app_settings WHERE key = ?", key).Scan(&value)
 	if err == sql.ErrNoRows {
 		return "", false, nil
 	}
 	if err != nil {
 		return "", false, err
 	}
+	value, err = decryptSetting(key, value)
+	if err != nil {
+		return "", false, err
+	}
 	return value, true, nil
 }
 
+// TableRowCounts returns the number of rows in every application table.
+func (d *Database) TableRowCounts() (map[string]int64, error) {
+	rows, err := d.read.Query(`SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	var tables []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		tables = append(tables, name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int64, len(tables))
+	for _, name := range tables {
+		var n int64
+		quoted := `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+		if err := d.read.QueryRow("SELECT COUNT(*) FROM " + quoted).Scan(&n); err != nil {
+			return nil, fmt.Errorf("count %s: %w", name, err)
+		}
+		counts[name] = n
+	}
+	return counts, nil
+}
+
 // GetSettings returns key->value for the provided keys.
 func (d *Database) GetSettings(keys []string) (map[string]string, error) {
 	out := make(map[string]string, len(keys))
@@ -201,7 +408,7 @@ func (d *Database) GetSettings(keys []string) (map[string]string, error) {
 		args = append(args, k)
 	}
 
-	rows, err := d.conn.Query(`
+	rows, err := d.read.Query(`
 		SELECT key, value
 		FROM app_settings
 		WHERE key IN (`+joinStrings(placeholders, ",")+`)
@@ -216,6 +423,9 @@ func (d *Database) GetSettings(keys []string) (map[string]string, error) {
 		if err := rows.Scan(&k, &v); err != nil {
 			return nil, err
 		}
+		if v, err = decryptSetting(k, v); err != nil {
+			return nil, fmt.Errorf("setting %s: %w", k, err)
+		}
 		out[k] = v
 	}
 	return out, rows.Err()
@@ -224,6 +434,7 @@ func (d *Database) GetSettings(keys []string) (map[string]string, error) {
 // UpdateAllTargetIntervals sets interval_min for all targets. Returns affected count.
 func (d *Database) UpdateAllTargetIntervals(intervalMin int) (int64, error) {
 	now := float64(time.Now().UnixMilli()) / 1000.0
+	defer d.touchTargets()
 	d.mu.Lock()
 	res, err := d.conn.Exec(`
 		UPDATE targets
@@ -236,17 +447,15 @@ func (d *Database) UpdateAllTargetIntervals(intervalMin int) (int64, error) {
 	return res.RowsAffected()
 }
 
-func (d *Database) migrateDB() error {
-	rows, err := d.conn.Query("PRAGMA table_info(targets)")
+// tableColumns returns the set of column names of a table.
+func (d *Database) tableColumns(table string) (map[string]bool, error) {
+	rows, err := d.conn.Query("PRAGMA table_info(" + table + ")")
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer rows.Close()
 
-	hasSourceURL := false
-	hasSortOrder := false
-	hasVisitorChannelActionsEnabled := false
-	hasSelectedModels := false
+	existing := make(map[string]bool)
 	for rows.Next() {
 		var cid int
 		var name, ctype string
@@ -254,32 +463,65 @@ func (d *Database) migrateDB() error {
 		var dfltValue sql.NullString
 		var pk int
 		if err := rows.Scan(&cid, &name, &ctype, &notnull, &dfltValue, &pk); err != nil {
-			return err
-		}
-		if name == "source_url" {
-			hasSourceURL = true
-		}
-		if name == "sort_order" {
-			hasSortOrder = true
-		}
-		if name == "visitor_channel_actions_enabled" {
-			hasVisitorChannelActionsEnabled = true
-		}
-		if name == "selected_models" {
-			hasSelectedModels = true
+			return nil, err
 		}
+		existing[name] = true
 	}
-	if !hasSourceURL {
-		_, _ = d.conn.Exec("ALTER TABLE targets ADD COLUMN source_url TEXT")
-	}
-	if !hasSortOrder {
-		_, _ = d.conn.Exec("ALTER TABLE targets ADD COLUMN sort_order INTEGER NOT NULL DEFAULT 0")
-	}
-	if !hasVisitorChannelActionsEnabled {
-		_, _ = d.conn.Exec("ALTER TABLE targets ADD COLUMN visitor_channel_actions_enabled INTEGER NOT NULL DEFAULT 0")
+	return existing, rows.Err()
+}
+
+func (d *Database) migrateDB() error {
+	existing, err := d.tableColumns("targets")
+	if err != nil {
+		return err
 	}
-	if !hasSelectedModels {
-		_, _ = d.conn.Exec("ALTER TABLE targets ADD COLUMN selected_models TEXT NOT NULL DEFAULT '[]'")
+
+	// Columns added after the initial schema, in the order they were introduced.
+	targetMigrations := []struct {
+		column string
+		ddl    string
+	}{
+		{"source_url", "ALTER TABLE targets ADD COLUMN source_url TEXT"},
+		{"sort_order", "ALTER TABLE targets ADD COLUMN sort_order INTEGER NOT NULL DEFAULT 0"},
+		{"visitor_channel_actions_enabled", "ALTER TABLE targets ADD COLUMN visitor_channel_actions_enabled INTEGER NOT NULL DEFAULT 0"},
+		{"selected_models", "ALTER TABLE targets ADD COLUMN selected_models TEXT NOT NULL DEFAULT '[]'"},
+		{"extra_headers", "ALTER TABLE targets ADD COLUMN extra_headers TEXT NOT NULL DEFAULT '{}'"},
+		{"proxy_url", "ALTER TABLE targets ADD COLUMN proxy_url TEXT NOT NULL DEFAULT ''"},
+		{"tls_fingerprint", "ALTER TABLE targets ADD COLUMN tls_fingerprint TEXT NOT NULL DEFAULT 'chrome'"},
+		{"tls_cert_not_after", "ALTER TABLE targets ADD COLUMN tls_cert_not_after REAL"},
+		{"tls_cert_chain", "ALTER TABLE targets ADD COLUMN tls_cert_chain TEXT NOT NULL DEFAULT '[]'"},
+		{"tls_cert_checked_at", "ALTER TABLE targets ADD COLUMN tls_cert_checked_at REAL"},
+		{"model_overrides", "ALTER TABLE targets ADD COLUMN model_overrides TEXT NOT NULL DEFAULT '{}'"},
+		{"include_patterns", "ALTER TABLE targets ADD COLUMN include_patterns TEXT NOT NULL DEFAULT '[]'"},
+		{"exclude_patterns", "ALTER TABLE targets ADD COLUMN exclude_patterns TEXT NOT NULL DEFAULT '[]'"},
+		{"probe_endpoints", "ALTER TABLE targets ADD COLUMN probe_endpoints TEXT NOT NULL DEFAULT '[]'"},
+		{"stream_probe", "ALTER TABLE targets ADD COLUMN stream_probe INTEGER NOT NULL DEFAULT 0"},
+		{"agent_id", "ALTER TABLE targets ADD COLUMN agent_id INTEGER"},
+		{"snoozed_until", "ALTER TABLE targets ADD COLUMN snoozed_until REAL"},
+		{"fast_retry_min", "ALTER TABLE targets ADD COLUMN fast_retry_min INTEGER NOT NULL DEFAULT 0"},
+		{"retry_interval_min", "ALTER TABLE targets ADD COLUMN retry_interval_min INTEGER"},
+		{"template_id", "ALTER TABLE targets ADD COLUMN template_id INTEGER"},
+		{"detect_concurrency", "ALTER TABLE targets ADD COLUMN detect_concurrency INTEGER NOT NULL DEFAULT 0"},
+		{"max_run_duration_s", "ALTER TABLE targets ADD COLUMN max_run_duration_s INTEGER NOT NULL DEFAULT 0"},
+		{"debug_capture", "ALTER TABLE targets ADD COLUMN debug_capture INTEGER NOT NULL DEFAULT 0"},
+		{"deleted_at", "ALTER TABLE targets ADD COLUMN deleted_at REAL"},
+		{"log_max_age_days", "ALTER TABLE targets ADD COLUMN log_max_age_days INTEGER NOT NULL DEFAULT 0"},
+		{"balance_probe", "ALTER TABLE targets ADD COLUMN balance_probe TEXT NOT NULL DEFAULT ''"},
+		{"balance_alert_below", "ALTER TABLE targets ADD COLUMN balance_alert_below REAL NOT NULL DEFAULT 0"},
+		{"balance_remaining", "ALTER TABLE targets ADD COLUMN balance_remaining REAL"},
+		{"balance_checked_at", "ALTER TABLE targets ADD COLUMN balance_checked_at REAL"},
+		{"balance_error", "ALTER TABLE targets ADD COLUMN balance_error TEXT"},
+		{"api_keys", "ALTER TABLE targets ADD COLUMN api_keys TEXT NOT NULL DEFAULT '[]'"},
+		{"tags", "ALTER TABLE targets ADD COLUMN tags TEXT NOT NULL DEFAULT '[]'"},
+		{"severity", "ALTER TABLE targets ADD COLUMN severity TEXT NOT NULL DEFAULT 'critical'"},
+		{"proxy_transform", "ALTER TABLE targets ADD COLUMN proxy_transform TEXT NOT NULL DEFAULT '{}'"},
+		{"tool_probe", "ALTER TABLE targets ADD COLUMN tool_probe INTEGER NOT NULL DEFAULT 0"},
+		{"capability_probes", "ALTER TABLE targets ADD COLUMN capability_probes TEXT NOT NULL DEFAULT '[]'"},
+	}
+	for _, m := range targetMigrations {
+		if !existing[m.column] {
+			_, _ = d.conn.Exec(m.ddl)
+		}
 	}
 	_, _ = d.conn.Exec(`
 		WITH ordered AS (
@@ -293,6 +535,80 @@ func (d *Database) migrateDB() error {
 		WHERE sort_order IS NULL OR sort_order <= 0
 	`)
 	_, _ = d.conn.Exec("CREATE INDEX IF NOT EXISTS idx_targets_sort_order ON targets(sort_order, id)")
+
+	runExisting, err := d.tableColumns("runs")
+	if err != nil {
+		return err
+	}
+	if !runExisting["agent_id"] {
+		_, _ = d.conn.Exec("ALTER TABLE runs ADD COLUMN agent_id INTEGER")
+	}
+	if !runExisting["mode"] {
+		_, _ = d.conn.Exec("ALTER TABLE runs ADD COLUMN mode TEXT NOT NULL DEFAULT 'full'")
+	}
+	if !runExisting["log_object_key"] {
+		_, _ = d.conn.Exec("ALTER TABLE runs ADD COLUMN log_object_key TEXT")
+	}
+
+	runModelExisting, err := d.tableColumns("run_models")
+	if err != nil {
+		return err
+	}
+	runModelMigrations := []struct {
+		column string
+		ddl    string
+	}{
+		{"dns_ms", "ALTER TABLE run_models ADD COLUMN dns_ms REAL"},
+		{"connect_ms", "ALTER TABLE run_models ADD COLUMN connect_ms REAL"},
+		{"tls_ms", "ALTER TABLE run_models ADD COLUMN tls_ms REAL"},
+		{"ttfb_ms", "ALTER TABLE run_models ADD COLUMN ttfb_ms REAL"},
+		{"body_ms", "ALTER TABLE run_models ADD COLUMN body_ms REAL"},
+		{"ttft_ms", "ALTER TABLE run_models ADD COLUMN ttft_ms REAL"},
+		{"tokens_per_sec", "ALTER TABLE run_models ADD COLUMN tokens_per_sec REAL"},
+		{"output_tokens", "ALTER TABLE run_models ADD COLUMN output_tokens INTEGER"},
+		{"slow", "ALTER TABLE run_models ADD COLUMN slow INTEGER NOT NULL DEFAULT 0"},
+		{"error_category", "ALTER TABLE run_models ADD COLUMN error_category TEXT"},
+		{"served_model", "ALTER TABLE run_models ADD COLUMN served_model TEXT"},
+		{"mismatch", "ALTER TABLE run_models ADD COLUMN mismatch INTEGER NOT NULL DEFAULT 0"},
+		{"prompt_tokens", "ALTER TABLE run_models ADD COLUMN prompt_tokens INTEGER"},
+		{"completion_tokens", "ALTER TABLE run_models ADD COLUMN completion_tokens INTEGER"},
+		{"reasoning_tokens", "ALTER TABLE run_models ADD COLUMN reasoning_tokens INTEGER"},
+	}
+	for _, m := range runModelMigrations {
+		if !runModelExisting[m.column] {
+			_, _ = d.conn.Exec(m.ddl)
+		}
+	}
+	if !runModelExisting["error_category"] {
+		if err := d.backfillErrorCategories(); err != nil {
+			return fmt.Errorf("backfill error categories: %w", err)
+		}
+	}
+
+	keyStatusExisting, err := d.tableColumns("target_key_status")
+	if err != nil {
+		return err
+	}
+	keyStatusMigrations := []struct {
+		column string
+		ddl    string
+	}{
+		{"state", "ALTER TABLE target_key_status ADD COLUMN state TEXT NOT NULL DEFAULT 'unknown'"},
+		{"last_ok_at", "ALTER TABLE target_key_status ADD COLUMN last_ok_at REAL"},
+		{"status_code", "ALTER TABLE target_key_status ADD COLUMN status_code INTEGER"},
+		{"error_category", "ALTER TABLE target_key_status ADD COLUMN error_category TEXT"},
+		{"auth_failures", "ALTER TABLE target_key_status ADD COLUMN auth_failures INTEGER NOT NULL DEFAULT 0"},
+		{"rate_limits", "ALTER TABLE target_key_status ADD COLUMN rate_limits INTEGER NOT NULL DEFAULT 0"},
+		{"consecutive_failures", "ALTER TABLE target_key_status ADD COLUMN consecutive_failures INTEGER NOT NULL DEFAULT 0"},
+	}
+	for _, m := range keyStatusMigrations {
+		if !keyStatusExisting[m.column] {
+			_, _ = d.conn.Exec(m.ddl)
+		}
+	}
+	if !keyStatusExisting["state"] {
+		_, _ = d.conn.Exec("UPDATE target_key_status SET state = CASE WHEN healthy = 1 THEN 'alive' ELSE 'unknown' END")
+	}
 	return nil
 }
 
@@ -302,30 +618,98 @@ func (d *Database) migrateDB() error {
 
 // Target represents a monitoring target (channel).
 type Target struct {
-	ID                           int      `json:"id"`
-	Name                         string   `json:"name"`
-	BaseURL                      string   `json:"base_url"`
-	APIKey                       string   `json:"api_key"`
-	Enabled                      bool     `json:"enabled"`
-	IntervalMin                  int      `json:"interval_min"`
-	TimeoutS                     float64  `json:"timeout_s"`
-	VerifySSL                    bool     `json:"verify_ssl"`
-	Prompt                       string   `json:"prompt"`
-	AnthropicVersion             string   `json:"anthropic_version"`
-	MaxModels                    int      `json:"max_models"`
-	CreatedAt                    float64  `json:"created_at"`
-	UpdatedAt                    float64  `json:"updated_at"`
-	LastRunAt                    *float64 `json:"last_run_at"`
-	LastStatus                   *string  `json:"last_status"`
-	LastTotal                    *int     `json:"last_total"`
-	LastSuccess                  *int     `json:"last_success"`
-	LastFail                     *int     `json:"last_fail"`
-	LastLogFile                  *string  `json:"last_log_file"`
-	LastError                    *string  `json:"last_error"`
-	SourceURL                    *string  `json:"source_url"`
-	SortOrder                    int      `json:"sort_order"`
-	VisitorChannelActionsEnabled bool     `json:"visitor_channel_actions_enabled"`
-	SelectedModels               []string `json:"selected_models"`
+	ID                           int                      `json:"id"`
+	Name                         string                   `json:"name"`
+	BaseURL                      string                   `json:"base_url"`
+	APIKey                       string                   `json:"api_key"`
+	Enabled                      bool                     `json:"enabled"`
+	IntervalMin                  int                      `json:"interval_min"`
+	TimeoutS                     float64                  `json:"timeout_s"`
+	VerifySSL                    bool                     `json:"verify_ssl"`
+	Prompt                       string                   `json:"prompt"`
+	AnthropicVersion             string                   `json:"anthropic_version"`
+	MaxModels                    int                      `json:"max_models"`
+	CreatedAt                    float64                  `json:"created_at"`
+	UpdatedAt                    float64                  `json:"updated_at"`
+	LastRunAt                    *float64                 `json:"last_run_at"`
+	LastStatus                   *string                  `json:"last_status"`
+	LastTotal                    *int                     `json:"last_total"`
+	LastSuccess                  *int                     `json:"last_success"`
+	LastFail                     *int                     `json:"last_fail"`
+	LastLogFile                  *string                  `json:"last_log_file"`
+	LastError                    *string                  `json:"last_error"`
+	SourceURL                    *string                  `json:"source_url"`
+	SortOrder                    int                      `json:"sort_order"`
+	VisitorChannelActionsEnabled bool                     `json:"visitor_channel_actions_enabled"`
+	SelectedModels               []string                 `json:"selected_models"`
+	ExtraHeaders                 map[string]string        `json:"extra_headers"`
+	ProxyURL                     string                   `json:"proxy_url"`
+	TLSFingerprint               string                   `json:"tls_fingerprint"`
+	TLSCertNotAfter              *float64                 `json:"tls_cert_not_after"`
+	TLSCertChain                 []tlsCertInfo            `json:"tls_cert_chain"`
+	TLSCertCheckedAt             *float64                 `json:"tls_cert_checked_at"`
+	ModelOverrides               map[string]ModelOverride `json:"model_overrides"`
+	IncludePatterns              []string                 `json:"include_patterns"`
+	ExcludePatterns              []string                 `json:"exclude_patterns"`
+	// ProbeEndpoints lists extra OpenAI endpoints (chat, responses) probed for OpenAI-protocol models.
+	ProbeEndpoints []string `json:"probe_endpoints"`
+	// StreamProbe sends detection requests with stream=true to measure first-token latency and throughput.
+	StreamProbe bool `json:"stream_probe"`
+	// ToolProbe makes the anthropic route probe tool use; see tool_probe.go.
+	ToolProbe bool `json:"tool_probe"`
+	// CapabilityProbes lists the capability checks run after a model's probe passes; see
+	// capability_probe.go.
+	CapabilityProbes []string `json:"capability_probes"`
+	// AgentID assigns the target to a remote probe agent; nil means the central scheduler runs it.
+	AgentID *int `json:"agent_id"`
+	// SnoozedUntil mutes scheduled checks until this unix timestamp; history and manual runs are kept.
+	SnoozedUntil *float64 `json:"snoozed_until"`
+	// FastRetryMin is the first re-check delay after a down/degraded/error run; 0 disables fast retry.
+	FastRetryMin int `json:"fast_retry_min"`
+	// RetryIntervalMin is the current backed-off re-check delay; nil means interval_min applies.
+	RetryIntervalMin *int `json:"retry_interval_min"`
+	// TemplateID links the target to the template it was created from, for propagating edits.
+	TemplateID *int `json:"template_id"`
+	// DetectConcurrency overrides the global per-run model concurrency; 0 uses the global value.
+	DetectConcurrency int `json:"detect_concurrency"`
+	// MaxRunDurationS overrides the global run deadline in seconds; 0 uses the global value.
+	MaxRunDurationS int `json:"max_run_duration_s"`
+	// DebugCapture stores the full request and raw response of failed detections.
+	DebugCapture bool `json:"debug_capture"`
+	// DeletedAt is set while the target is in the trash; see trash.go.
+	DeletedAt *float64 `json:"deleted_at,omitempty"`
+	// LogMaxAgeDays overrides the global log age limit; 0 uses the global value.
+	LogMaxAgeDays int `json:"log_max_age_days"`
+	// BalanceProbe selects the account endpoint queried for the remaining credit after
+	// each run (oneapi, openai); empty disables it. See balance.go.
+	BalanceProbe string `json:"balance_probe"`
+	// BalanceAlertBelow raises balance_low when the remaining credit (USD) drops below it; 0 disables the alert.
+	BalanceAlertBelow float64 `json:"balance_alert_below"`
+	// BalanceRemaining, BalanceCheckedAt and BalanceError hold the result of the last balance probe.
+	BalanceRemaining *float64 `json:"balance_remaining"`
+	BalanceCheckedAt *float64 `json:"balance_checked_at"`
+	BalanceError     *string  `json:"balance_error"`
+	// APIKeys are extra keys pooled with api_key; see key_pool.go.
+	APIKeys []string `json:"api_keys"`
+	// Tags label the target for notification routing (e.g. production, test).
+	Tags []string `json:"tags"`
+	// Severity is the severity of the target's outage notifications; see notifications.go.
+	Severity string `json:"severity"`
+	// ProxyTransform rewrites proxied requests before forwarding; see proxy_transform.go.
+	ProxyTransform ProxyTransform `json:"proxy_transform"`
+}
+
+// Snoozed reports whether scheduled checks are muted at now (unix seconds).
+func (t *Target) Snoozed(now float64) bool {
+	return t.SnoozedUntil != nil && *t.SnoozedUntil > now
+}
+
+// ModelOverride replaces target-wide detection parameters for a single model.
+type ModelOverride struct {
+	TimeoutS  *float64 `json:"timeout_s,omitempty"`
+	MaxTokens *int     `json:"max_tokens,omitempty"`
+	// ContextTokens sizes the prompt of the long_context capability probe.
+	ContextTokens *int `json:"context_tokens,omitempty"`
 }
 
 // Run represents a detection run.
@@ -340,6 +724,14 @@ type Run struct {
 	Fail       int      `json:"fail"`
 	LogFile    *string  `json:"log_file"`
 	Error      *string  `json:"error"`
+	AgentID    *int     `json:"agent_id"`
+	// Mode is "full" for a run over every selected model, or the kind of partial run
+	// (see runModeFailedOnly) that re-probed only some of them.
+	Mode string `json:"mode"`
+	// LogObjectKey is the object storage key of the uploaded log; see log_upload.go.
+	LogObjectKey *string `json:"log_object_key"`
+	// Notes are the annotations on the run; only filled in by the runs list.
+	Notes []Note `json:"notes,omitempty"`
 }
 
 // ModelRow represents a single model detection result.
@@ -361,16 +753,50 @@ type ModelRow struct {
 	StatusCode       *int            `json:"status_code"`
 	Route            *string         `json:"route"`
 	Endpoint         *string         `json:"endpoint"`
+	DNSMs            *float64        `json:"dns_ms"`
+	ConnectMs        *float64        `json:"connect_ms"`
+	TLSMs            *float64        `json:"tls_ms"`
+	TTFBMs           *float64        `json:"ttfb_ms"`
+	BodyMs           *float64        `json:"body_ms"`
+	TTFTMs           *float64        `json:"ttft_ms"`
+	TokensPerSec     *float64        `json:"tokens_per_sec"`
+	OutputTokens     *int            `json:"output_tokens"`
+	Slow             bool            `json:"slow"`
+	ErrorCategory    *string         `json:"error_category"`
+	ServedModel      *string         `json:"served_model"`
+	Mismatch         bool            `json:"mismatch"`
+	PromptTokens     *int            `json:"prompt_tokens"`
+	CompletionTokens *int            `json:"completion_tokens"`
+	ReasoningTokens  *int            `json:"reasoning_tokens"`
+	// Capture is the stored request/response of a failed detection with debug_capture
+	// on; only filled in when the logs API is asked for captures.
+	Capture *probeCapture `json:"capture,omitempty"`
 }
 
 // ModelStatus is a summary of a model's latest detection result.
 type ModelStatus struct {
-	Protocol *string             `json:"protocol"`
-	Model    string              `json:"model"`
-	Success  bool                `json:"success"`
-	Duration *float64            `json:"duration"`
-	Error    *string             `json:"error"`
-	History  []ModelHistoryPoint `json:"history"`
+	Protocol *string  `json:"protocol"`
+	Model    string   `json:"model"`
+	Endpoint *string  `json:"endpoint"`
+	Success  bool     `json:"success"`
+	Duration *float64 `json:"duration"`
+	Error    *string  `json:"error"`
+	Slow     bool     `json:"slow"`
+	// ErrorCategory is the category of a failed latest result.
+	ErrorCategory *string `json:"error_category"`
+	// Mismatch is set when the upstream echoed a different model than requested
+	// (ServedModel); see model_identity.go.
+	Mismatch    bool    `json:"mismatch"`
+	ServedModel *string `json:"served_model"`
+	// SupportsJSONMode is the latest json_mode capability verdict; nil until probed.
+	SupportsJSONMode *bool `json:"supports_json_mode"`
+	// SupportsVision is the latest vision capability verdict; nil until probed.
+	SupportsVision *bool `json:"supports_vision"`
+	// SupportsLongContext is the latest long_context capability verdict; nil until probed.
+	SupportsLongContext *bool `json:"supports_long_context"`
+	// SupportsMultiTurn is the latest multi_turn capability verdict; nil until probed.
+	SupportsMultiTurn *bool               `json:"supports_multi_turn"`
+	History           []ModelHistoryPoint `json:"history"`
 }
 
 // ModelHistoryPoint is one historical point for a model.
@@ -389,12 +815,19 @@ type ModelHistoryPoint struct {
 const targetColumns = `id, name, base_url, api_key, enabled, interval_min, timeout_s, verify_ssl,
 	prompt, anthropic_version, max_models, created_at, updated_at,
 	last_run_at, last_status, last_total, last_success, last_fail, last_log_file, last_error,
-	source_url, sort_order, visitor_channel_actions_enabled, selected_models`
+	source_url, sort_order, visitor_channel_actions_enabled, selected_models, extra_headers, proxy_url, tls_fingerprint,
+	tls_cert_not_after, tls_cert_chain, tls_cert_checked_at, model_overrides,
+	include_patterns, exclude_patterns, probe_endpoints, stream_probe, agent_id, snoozed_until,
+	fast_retry_min, retry_interval_min, template_id, detect_concurrency, max_run_duration_s, debug_capture, deleted_at,
+	log_max_age_days, balance_probe, balance_alert_below, balance_remaining, balance_checked_at, balance_error, api_keys,
+	tags, severity, proxy_transform, tool_probe, capability_probes`
 
-const runColumns = `id, target_id, started_at, finished_at, status, total, success, fail, log_file, error`
+const runColumns = `id, target_id, started_at, finished_at, status, total, success, fail, log_file, error, agent_id, mode, log_object_key`
 
 const runModelColumns = `id, run_id, target_id, protocol, model, stream, duration, success, transport_success,
-	tool_calls_count, tool_calls, content, timestamp, error, status_code, route, endpoint`
+	tool_calls_count, tool_calls, content, timestamp, error, status_code, route, endpoint,
+	dns_ms, connect_ms, tls_ms, ttfb_ms, body_ms, ttft_ms, tokens_per_sec, output_tokens, slow, error_category,
+	served_model, mismatch, prompt_tokens, completion_tokens, reasoning_tokens`
 
 // ---------------------------------------------------------------------------
 // Scan helpers
@@ -402,8 +835,9 @@ const runModelColumns = `id, run_id, target_id, protocol, model, stream, duratio
 
 func scanTarget(r interface{ Scan(dest ...any) error }) (*Target, error) {
 	var t Target
-	var enabled, verifySSL, visitorChannelActionsEnabled int
-	var selectedModelsRaw string
+	var enabled, verifySSL, visitorChannelActionsEnabled, streamProbe, toolProbe, debugCapture int
+	var selectedModelsRaw, extraHeadersRaw, certChainRaw, modelOverridesRaw string
+	var includePatternsRaw, excludePatternsRaw, probeEndpointsRaw, apiKeysRaw, tagsRaw, proxyTransformRaw, capabilityProbesRaw string
 	err := r.Scan(
 		&t.ID, &t.Name, &t.BaseURL, &t.APIKey,
 		&enabled, &t.IntervalMin, &t.TimeoutS, &verifySSL,
@@ -411,13 +845,35 @@ func scanTarget(r interface{ Scan(dest ...any) error }) (*Target, error) {
 		&t.CreatedAt, &t.UpdatedAt,
 		&t.LastRunAt, &t.LastStatus, &t.LastTotal, &t.LastSuccess,
 		&t.LastFail, &t.LastLogFile, &t.LastError, &t.SourceURL, &t.SortOrder, &visitorChannelActionsEnabled, &selectedModelsRaw,
+		&extraHeadersRaw, &t.ProxyURL, &t.TLSFingerprint,
+		&t.TLSCertNotAfter, &certChainRaw, &t.TLSCertCheckedAt, &modelOverridesRaw,
+		&includePatternsRaw, &excludePatternsRaw, &probeEndpointsRaw, &streamProbe, &t.AgentID,
+		&t.SnoozedUntil, &t.FastRetryMin, &t.RetryIntervalMin, &t.TemplateID, &t.DetectConcurrency, &t.MaxRunDurationS, &debugCapture,
+		&t.DeletedAt, &t.LogMaxAgeDays,
+		&t.BalanceProbe, &t.BalanceAlertBelow, &t.BalanceRemaining, &t.BalanceCheckedAt, &t.BalanceError,
+		&apiKeysRaw, &tagsRaw, &t.Severity, &proxyTransformRaw, &toolProbe, &capabilityProbesRaw,
 	)
 	if err != nil {
 		return nil, err
 	}
+	if t.APIKey, err = decryptSecret(t.APIKey); err != nil {
+		return nil, fmt.Errorf("target %d api_key: %w", t.ID, err)
+	}
+	if apiKeysRaw, err = decryptSecret(apiKeysRaw); err != nil {
+		return nil, fmt.Errorf("target %d api_keys: %w", t.ID, err)
+	}
+	if err := json.Unmarshal([]byte(apiKeysRaw), &t.APIKeys); err != nil || t.APIKeys == nil {
+		t.APIKeys = []string{}
+	}
+	if err := json.Unmarshal([]byte(tagsRaw), &t.Tags); err != nil || t.Tags == nil {
+		t.Tags = []string{}
+	}
 	t.Enabled = enabled != 0
 	t.VerifySSL = verifySSL != 0
 	t.VisitorChannelActionsEnabled = visitorChannelActionsEnabled != 0
+	t.StreamProbe = streamProbe != 0
+	t.ToolProbe = toolProbe != 0
+	t.DebugCapture = debugCapture != 0
 	if err := json.Unmarshal([]byte(selectedModelsRaw), &t.SelectedModels); err != nil {
 		t.SelectedModels = []string{}
 	} else {
@@ -426,6 +882,22 @@ func scanTarget(r interface{ Scan(dest ...any) error }) (*Target, error) {
 	if t.SelectedModels == nil {
 		t.SelectedModels = []string{}
 	}
+	if err := json.Unmarshal([]byte(extraHeadersRaw), &t.ExtraHeaders); err != nil || t.ExtraHeaders == nil {
+		t.ExtraHeaders = map[string]string{}
+	}
+	if err := json.Unmarshal([]byte(certChainRaw), &t.TLSCertChain); err != nil || t.TLSCertChain == nil {
+		t.TLSCertChain = []tlsCertInfo{}
+	}
+	if err := json.Unmarshal([]byte(modelOverridesRaw), &t.ModelOverrides); err != nil || t.ModelOverrides == nil {
+		t.ModelOverrides = map[string]ModelOverride{}
+	}
+	if err := json.Unmarshal([]byte(proxyTransformRaw), &t.ProxyTransform); err != nil {
+		t.ProxyTransform = ProxyTransform{}
+	}
+	t.IncludePatterns = decodeStringSlice(includePatternsRaw)
+	t.ExcludePatterns = decodeStringSlice(excludePatternsRaw)
+	t.ProbeEndpoints = decodeStringSlice(probeEndpointsRaw)
+	t.CapabilityProbes = decodeStringSlice(capabilityProbesRaw)
 	return &t, nil
 }
 
@@ -434,7 +906,7 @@ func scanRun(r interface{ Scan(dest ...any) error }) (*Run, error) {
 	err := r.Scan(
 		&run.ID, &run.TargetID, &run.StartedAt, &run.FinishedAt,
 		&run.Status, &run.Total, &run.Success, &run.Fail,
-		&run.LogFile, &run.Error,
+		&run.LogFile, &run.Error, &run.AgentID, &run.Mode, &run.LogObjectKey,
 	)
 	if err != nil {
 		return nil, err
@@ -444,17 +916,22 @@ func scanRun(r interface{ Scan(dest ...any) error }) (*Run, error) {
 
 func scanModelRow(r interface{ Scan(dest ...any) error }) (*ModelRow, error) {
 	var m ModelRow
-	var stream, success, transportSuccess int
+	var stream, success, transportSuccess, slow, mismatch int
 	var toolCallsRaw sql.NullString
 	err := r.Scan(
 		&m.ID, &m.RunID, &m.TargetID, &m.Protocol, &m.Model,
 		&stream, &m.Duration, &success, &transportSuccess,
 		&m.ToolCallsCount, &toolCallsRaw, &m.Content, &m.Timestamp,
 		&m.Error, &m.StatusCode, &m.Route, &m.Endpoint,
+		&m.DNSMs, &m.ConnectMs, &m.TLSMs, &m.TTFBMs, &m.BodyMs,
+		&m.TTFTMs, &m.TokensPerSec, &m.OutputTokens, &slow, &m.ErrorCategory,
+		&m.ServedModel, &mismatch, &m.PromptTokens, &m.CompletionTokens, &m.ReasoningTokens,
 	)
 	if err != nil {
 		return nil, err
 	}
+	m.Slow = slow != 0
+	m.Mismatch = mismatch != 0
 	m.Stream = stream != 0
 	m.Success = success != 0
 	m.TransportSuccess = transportSuccess != 0
@@ -472,13 +949,14 @@ func scanModelRow(r interface{ Scan(dest ...any) error }) (*ModelRow, error) {
 // CRUD -- Targets
 // ---------------------------------------------------------------------------
 
-// ListTargets returns all targets ordered by creation time (newest first).
+// ListTargets returns all targets in dashboard order (sort_order, then id).
 func (d *Database) ListTargets() ([]Target, error) {
-	conn := d.conn
+	conn := d.read
 
 	rows, err := conn.Query(`
 		SELECT ` + targetColumns + ` FROM targets
-		ORDER BY created_at DESC, id DESC
+		WHERE deleted_at IS NULL
+		ORDER BY sort_order ASC, id ASC
 	`)
 	if err != nil {
 		return nil, err
@@ -499,11 +977,11 @@ func (d *Database) ListTargets() ([]Target, error) {
 	return targets, rows.Err()
 }
 
-// GetTarget returns a single target by id, or nil if not found.
+// GetTarget returns a single target by id, or nil if not found or in the trash.
 func (d *Database) GetTarget(targetID int) (*Target, error) {
-	conn := d.conn
+	conn := d.read
 
-	row := conn.QueryRow("SELECT "+targetColumns+" FROM targets WHERE id = ?", targetID)
+	row := conn.QueryRow("SELECT "+targetColumns+" FROM targets WHERE id = ? AND deleted_at IS NULL", targetID)
 	t, err := scanTarget(row)
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -511,131 +989,188 @@ func (d *Database) GetTarget(targetID int) (*Target, error) {
 	return t, err
 }
 
-// CreateTarget inserts a new target and returns it.
+// CreateTarget inserts a new target from a JSON payload and returns it. Missing or
+// null fields get the column defaults; see CreateTargetPatch for the typed form.
 func (d *Database) CreateTarget(payload map[string]any) (*Target, error) {
-	now := float64(time.Now().UnixMilli()) / 1000.0
+	set := make(map[string]any, len(payload))
+	for key, val := range payload {
+		if val != nil {
+			set[key] = val
+		}
+	}
+	return d.CreateTargetPatch(targetPatchFromPayload(set))
+}
 
-	name, _ := payload["name"].(string)
-	baseURL, _ := payload["base_url"].(string)
-	apiKey, _ := payload["api_key"].(string)
-	enabled := boolFromAny(payload["enabled"], true)
-	intervalMin := intFromAny(payload["interval_min"], 30)
-	timeoutS := floatFromAny(payload["timeout_s"], 30.0)
-	verifySSL := boolFromAny(payload["verify_ssl"], false)
-	prompt := stringFromAny(payload["prompt"], "What is the exact model identifier (model string) you are using for this chat/session?")
-	anthropicVersion := stringFromAny(payload["anthropic_version"], "2025-09-29")
-	maxModels := intFromAny(payload["max_models"], 0)
-	sourceURL := nullStringFromAny(payload["source_url"])
-	sortOrder := intFromAny(payload["sort_order"], 0)
-	visitorChannelActionsEnabled := boolFromAny(payload["visitor_channel_actions_enabled"], false)
-	selectedModels := stringSliceFromAny(payload["selected_models"])
-	selectedModelsJSON, _ := json.Marshal(selectedModels)
+// UpdateTarget patches a target from a JSON payload; see UpdateTargetPatch for the
+// typed form.
+func (d *Database) UpdateTarget(targetID int, updates map[string]any) (*Target, error) {
+	return d.UpdateTargetPatch(targetID, targetPatchFromPayload(updates))
+}
 
+// ReorderItems updates the sort_order for multiple targets in a single transaction.
+// ids come first in the given order; targets not listed keep their relative order after them.
+// It fails without changes if an id does not exist.
+func (d *Database) ReorderItems(ids []int) error {
+	defer d.touchTargets()
 	d.mu.Lock()
-	if sortOrder <= 0 {
-		if err := d.conn.QueryRow("SELECT COALESCE(MAX(sort_order), 0) + 1 FROM targets").Scan(&sortOrder); err != nil {
-			d.mu.Unlock()
-			return nil, err
-		}
+	defer d.mu.Unlock()
+	tx, err := d.conn.Begin()
+	if err != nil {
+		return err
 	}
-	res, err := d.conn.Exec(`
-		INSERT INTO targets (
-			name, base_url, api_key, enabled, interval_min, timeout_s, verify_ssl,
-			prompt, anthropic_version, max_models, source_url, sort_order, visitor_channel_actions_enabled, selected_models, created_at, updated_at
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
-		name, baseURL, apiKey, boolToInt(enabled), intervalMin, timeoutS, boolToInt(verifySSL),
-		prompt, anthropicVersion, maxModels, sourceURL, sortOrder, boolToInt(visitorChannelActionsEnabled), string(selectedModelsJSON), now, now,
-	)
-	d.mu.Unlock()
+	defer tx.Rollback()
 
+	rows, err := tx.Query("SELECT id FROM targets WHERE deleted_at IS NULL ORDER BY sort_order ASC, id ASC")
 	if err != nil {
-		return nil, err
+		return err
 	}
-	id, _ := res.LastInsertId()
-	return d.GetTarget(int(id))
-}
-
-// UpdateTarget patches fields on a target by id.
-func (d *Database) UpdateTarget(targetID int, updates map[string]any) (*Target, error) {
-	if len(updates) == 0 {
-		return d.GetTarget(targetID)
+	var current []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		current = append(current, id)
 	}
-
-	allowed := map[string]bool{
-		"name": true, "base_url": true, "api_key": true,
-		"enabled": true, "interval_min": true, "timeout_s": true,
-		"verify_ssl": true, "prompt": true, "anthropic_version": true,
-		"max_models": true, "source_url": true, "sort_order": true, "visitor_channel_actions_enabled": true, "selected_models": true,
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
 	}
 
-	var setClauses []string
-	var args []any
-	for key, val := range updates {
-		if !allowed[key] {
-			continue
+	known := make(map[int]bool, len(current))
+	for _, id := range current {
+		known[id] = true
+	}
+	listed := make(map[int]bool, len(ids))
+	order := make([]int, 0, len(current))
+	for _, id := range ids {
+		if !known[id] {
+			return fmt.Errorf("target not found: %d", id)
 		}
-		switch key {
-		case "enabled", "verify_ssl", "visitor_channel_actions_enabled":
-			args = append(args, boolToInt(boolFromAny(val, false)))
-		case "interval_min", "max_models", "sort_order":
-			args = append(args, intFromAny(val, 0))
-		case "selected_models":
-			modelsJSON, _ := json.Marshal(stringSliceFromAny(val))
-			args = append(args, string(modelsJSON))
-		case "timeout_s":
-			args = append(args, floatFromAny(val, 30.0))
-		default:
-			args = append(args, val)
+		if listed[id] {
+			return fmt.Errorf("duplicate target id: %d", id)
 		}
-		setClauses = append(setClauses, key+" = ?")
+		listed[id] = true
+		order = append(order, id)
 	}
-	if len(setClauses) == 0 {
-		return d.GetTarget(targetID)
+	for _, id := range current {
+		if !listed[id] {
+			order = append(order, id)
+		}
 	}
 
-	setClauses = append(setClauses, "updated_at = ?")
-	args = append(args, float64(time.Now().UnixMilli())/1000.0)
-	args = append(args, targetID)
-
-	query := "UPDATE targets SET " + joinStrings(setClauses, ", ") + " WHERE id = ?"
+	now := float64(time.Now().UnixMilli()) / 1000.0
+	for i, id := range order {
+		if _, err := tx.Exec("UPDATE targets SET sort_order = ?, updated_at = ? WHERE id = ?", i+1, now, id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
 
+// DeleteTarget moves a target to the trash. Its runs and history are kept until it is
+// purged; see PurgeTarget.
+func (d *Database) DeleteTarget(targetID int) (bool, error) {
+	defer d.touchTargets()
 	d.mu.Lock()
-	_, err := d.conn.Exec(query, args...)
+	res, err := d.conn.Exec("UPDATE targets SET deleted_at = ? WHERE id = ? AND deleted_at IS NULL",
+		float64(time.Now().UnixMilli())/1000.0, targetID)
 	d.mu.Unlock()
 
 	if err != nil {
-		return nil, err
+		return false, err
 	}
-	return d.GetTarget(targetID)
+	n, _ := res.RowsAffected()
+	return n > 0, nil
 }
 
-// ReorderItems updates the sort_order for multiple targets in a single transaction.
+// BulkSetTargetField sets one column on several targets in a single transaction and returns
+// the ids that existed. Only enabled and interval_min are supported.
+func (d *Database) BulkSetTargetField(ids []int, column string, value any) ([]int, error) {
+	switch column {
+	case "enabled":
+		value = boolToInt(boolFromAny(value, false))
+	case "interval_min":
+		value = intFromAny(value, 30)
+	default:
+		return nil, fmt.Errorf("unsupported bulk column: %s", column)
+	}
+	now := float64(time.Now().UnixMilli()) / 1000.0
 
-// DeleteTarget removes a target by id.
-func (d *Database) DeleteTarget(targetID int) (bool, error) {
+	defer d.touchTargets()
 	d.mu.Lock()
-	res, err := d.conn.Exec("DELETE FROM targets WHERE id = ?", targetID)
-	d.mu.Unlock()
+	defer d.mu.Unlock()
+	tx, err := d.conn.Begin()
+	if err != nil {
+		return nil, err
+	}
+	updated := make([]int, 0, len(ids))
+	for _, id := range ids {
+		res, err := tx.Exec("UPDATE targets SET "+column+" = ?, updated_at = ? WHERE id = ? AND deleted_at IS NULL", value, now, id)
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			updated = append(updated, id)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
 
+// BulkDeleteTargets moves several targets to the trash in a single transaction and
+// returns the ids that existed.
+func (d *Database) BulkDeleteTargets(ids []int) ([]int, error) {
+	now := float64(time.Now().UnixMilli()) / 1000.0
+	defer d.touchTargets()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	tx, err := d.conn.Begin()
 	if err != nil {
-		return false, err
+		return nil, err
 	}
-	n, _ := res.RowsAffected()
-	return n > 0, nil
+	deleted := make([]int, 0, len(ids))
+	for _, id := range ids {
+		res, err := tx.Exec("UPDATE targets SET deleted_at = ? WHERE id = ? AND deleted_at IS NULL", now, id)
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			deleted = append(deleted, id)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return deleted, nil
 }
 
-// ListDueTargets returns enabled targets due for a check.
-func (d *Database) ListDueTargets(nowTS float64) ([]Target, error) {
-	conn := d.conn
+// ListDueTargets returns enabled, non-snoozed targets due for a check.
+// A nil agentID selects targets run by the central scheduler, otherwise those assigned to that agent.
+func (d *Database) ListDueTargets(nowTS float64, agentID *int) ([]Target, error) {
+	conn := d.read
 
+	agentFilter := "agent_id IS NULL"
+	args := []any{nowTS, nowTS}
+	if agentID != nil {
+		agentFilter = "agent_id = ?"
+		args = append(args, *agentID)
+	}
 	rows, err := conn.Query(`
 		SELECT `+targetColumns+` FROM targets
-		WHERE enabled = 1
+		WHERE enabled = 1 AND deleted_at IS NULL
 		AND (
 			last_run_at IS NULL
-			OR (? - last_run_at) >= (interval_min * 60)
+			OR (? - last_run_at) >= (COALESCE(retry_interval_min, interval_min) * 60)
 		)
-		ORDER BY COALESCE(last_run_at, 0) ASC, id ASC`, nowTS)
+		AND (snoozed_until IS NULL OR snoozed_until <= ?)
+		AND `+agentFilter+`
+		ORDER BY COALESCE(last_run_at, 0) ASC, id ASC`, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -655,45 +1190,14 @@ func (d *Database) ListDueTargets(nowTS float64) ([]Target, error) {
 	return targets, rows.Err()
 }
 
-// GetLatestModelStatuses returns model statuses from the latest run.
+// GetLatestModelStatuses returns model statuses from the latest run, with any later
+// partial runs overlaid (see GetLatestModelStatusesBatch).
 func (d *Database) GetLatestModelStatuses(targetID int) ([]ModelStatus, error) {
-	conn := d.conn
-
-	var runID int
-	err := conn.QueryRow(
-		"SELECT id FROM runs WHERE target_id = ? ORDER BY started_at DESC LIMIT 1",
-		targetID,
-	).Scan(&runID)
-	if err == sql.ErrNoRows {
-		return []ModelStatus{}, nil
-	}
-	if err != nil {
-		return nil, err
-	}
-
-	rows, err := conn.Query(`
-		SELECT protocol, model, success, duration, error
-		FROM run_models WHERE run_id = ? ORDER BY model ASC`, runID)
+	statuses, err := d.GetLatestModelStatusesBatch([]int{targetID})
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-
-	var statuses []ModelStatus
-	for rows.Next() {
-		var ms ModelStatus
-		var success int
-		if err := rows.Scan(&ms.Protocol, &ms.Model, &success, &ms.Duration, &ms.Error); err != nil {
-			return nil, err
-		}
-		ms.Success = success != 0
-		ms.History = []ModelHistoryPoint{}
-		statuses = append(statuses, ms)
-	}
-	if statuses == nil {
-		statuses = []ModelStatus{}
-	}
-	return statuses, rows.Err()
+	return statuses[targetID], nil
 }
 
 // GetLatestModelStatusesBatch returns latest model statuses for multiple targets.
@@ -713,21 +1217,32 @@ func (d *Database) GetLatestModelStatusesBatch(targetIDs []int) (map[int][]Model
 		args = append(args, id)
 	}
 
+	// The base is the latest full run (or the first run when there is none yet); rows
+	// from later partial runs replace the base row of the same model and endpoint.
 	query := `
-		WITH latest_runs AS (
-			SELECT target_id, MAX(id) AS run_id
+		WITH base_runs AS (
+			SELECT target_id, COALESCE(MAX(CASE WHEN mode = 'full' THEN id END), MIN(id)) AS run_id
 			FROM runs
 			WHERE target_id IN (` + joinStrings(placeholders, ",") + `)
 			GROUP BY target_id
+		), ranked AS (
+			SELECT rm.target_id, rm.protocol, rm.model, rm.endpoint, rm.success, rm.duration, rm.error, rm.slow, rm.error_category,
+				rm.mismatch, rm.served_model,
+				ROW_NUMBER() OVER (
+					PARTITION BY rm.target_id, rm.model, rm.endpoint
+					ORDER BY rm.run_id DESC, rm.id DESC
+				) AS rn
+			FROM run_models rm
+			JOIN base_runs br
+			  ON rm.target_id = br.target_id AND rm.run_id >= br.run_id
 		)
-		SELECT rm.target_id, rm.protocol, rm.model, rm.success, rm.duration, rm.error
-		FROM run_models rm
-		JOIN latest_runs lr
-		  ON rm.run_id = lr.run_id AND rm.target_id = lr.target_id
-		ORDER BY rm.target_id ASC, rm.model ASC
+		SELECT target_id, protocol, model, endpoint, success, duration, error, slow, error_category, mismatch, served_model
+		FROM ranked
+		WHERE rn = 1
+		ORDER BY target_id ASC, model ASC, endpoint ASC
 	`
 
-	rows, err := d.conn.Query(query, args...)
+	rows, err := d.read.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -736,17 +1251,22 @@ func (d *Database) GetLatestModelStatusesBatch(targetIDs []int) (map[int][]Model
 	for rows.Next() {
 		var targetID int
 		var ms ModelStatus
-		var success int
-		if err := rows.Scan(&targetID, &ms.Protocol, &ms.Model, &success, &ms.Duration, &ms.Error); err != nil {
+		var success, slow, mismatch int
+		if err := rows.Scan(&targetID, &ms.Protocol, &ms.Model, &ms.Endpoint, &success, &ms.Duration, &ms.Error, &slow, &ms.ErrorCategory, &mismatch, &ms.ServedModel); err != nil {
 			return nil, err
 		}
 		ms.Success = success != 0
+		ms.Slow = slow != 0
+		ms.Mismatch = mismatch != 0
 		ms.History = []ModelHistoryPoint{}
 		result[targetID] = append(result[targetID], ms)
 	}
 	if err := rows.Err(); err != nil {
 		return nil, err
 	}
+	if err := d.attachModelCapabilities(result, placeholders, args); err != nil {
+		return nil, err
+	}
 	return result, nil
 }
 
@@ -798,7 +1318,7 @@ func (d *Database) GetModelHistoriesBatch(targetIDs []int, points int) (map[int]
 		ORDER BY target_id ASC, model ASC, rn DESC
 	`
 
-	rows, err := d.conn.Query(query, args...)
+	rows, err := d.read.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -835,11 +1355,12 @@ func (d *Database) GetModelHistoriesBatch(targetIDs []int, points int) (map[int]
 // ---------------------------------------------------------------------------
 
 // CreateRun inserts a new "running" run.
-func (d *Database) CreateRun(targetID int, startedAt float64, logFile string) (int, error) {
+// agentID is nil for runs executed by the central scheduler.
+func (d *Database) CreateRun(targetID int, startedAt float64, logFile string, agentID *int, mode string) (int, error) {
 	d.mu.Lock()
 	res, err := d.conn.Exec(
-		"INSERT INTO runs (target_id, started_at, status, log_file) VALUES (?, ?, 'running', ?)",
-		targetID, startedAt, logFile,
+		"INSERT INTO runs (target_id, started_at, status, log_file, agent_id, mode) VALUES (?, ?, 'running', ?, ?, ?)",
+		targetID, startedAt, logFile, agentID, mode,
 	)
 	d.mu.Unlock()
 
@@ -850,12 +1371,13 @@ func (d *Database) CreateRun(targetID int, startedAt float64, logFile string) (i
 	return int(id), nil
 }
 
-// FinishRun updates a run with final results.
+// FinishRun updates a run with final results. A run that is no longer running (e.g.
+// already timed out by the watchdog) is left unchanged.
 func (d *Database) FinishRun(runID int, status string, finishedAt float64, total, success, fail int, runError *string) error {
 	d.mu.Lock()
 	_, err := d.conn.Exec(`
 		UPDATE runs SET status = ?, finished_at = ?, total = ?, success = ?, fail = ?, error = ?
-		WHERE id = ?`,
+		WHERE id = ? AND status = 'running'`,
 		status, finishedAt, total, success, fail, runError, runID,
 	)
 	d.mu.Unlock()
@@ -864,6 +1386,7 @@ func (d *Database) FinishRun(runID int, status string, finishedAt float64, total
 
 // UpdateTargetAfterRun updates cached run stats on the target row.
 func (d *Database) UpdateTargetAfterRun(targetID int, lastRunAt float64, lastStatus string, lastTotal, lastSuccess, lastFail int, lastLogFile string, lastError *string) error {
+	defer d.touchTargets()
 	d.mu.Lock()
 	_, err := d.conn.Exec(`
 		UPDATE targets SET
@@ -878,12 +1401,87 @@ func (d *Database) UpdateTargetAfterRun(targetID int, lastRunAt float64, lastSta
 	return err
 }
 
+// LogMaxAgeOverrides returns the log_max_age_days of targets that set one, trashed
+// targets included, keyed by target id.
+func (d *Database) LogMaxAgeOverrides() (map[int]int, error) {
+	rows, err := d.read.Query("SELECT id, log_max_age_days FROM targets WHERE log_max_age_days > 0")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := map[int]int{}
+	for rows.Next() {
+		var id, days int
+		if err := rows.Scan(&id, &days); err != nil {
+			return nil, err
+		}
+		out[id] = days
+	}
+	return out, rows.Err()
+}
+
+// SetLogObjectKey records the object storage key of the runs logging to logFile.
+func (d *Database) SetLogObjectKey(logFile, key string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	_, err := d.conn.Exec("UPDATE runs SET log_object_key = ? WHERE log_file = ?", key, logFile)
+	return err
+}
+
+// RenameLogFile points runs and targets that reference log file from at to.
+func (d *Database) RenameLogFile(from, to string) error {
+	defer d.touchTargets()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	tx, err := d.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("UPDATE runs SET log_file = ? WHERE log_file = ?", to, from); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("UPDATE targets SET last_log_file = ? WHERE last_log_file = ?", to, from); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// SetTargetRetryInterval stores the backed-off re-check delay; nil restores interval_min.
+func (d *Database) SetTargetRetryInterval(targetID int, minutes *int) error {
+	defer d.touchTargets()
+	d.mu.Lock()
+	_, err := d.conn.Exec("UPDATE targets SET retry_interval_min = ? WHERE id = ?", minutes, targetID)
+	d.mu.Unlock()
+	return err
+}
+
+// UpdateTargetCertInfo stores the upstream certificate chain observed during a run.
+func (d *Database) UpdateTargetCertInfo(targetID int, notAfter float64, chain []tlsCertInfo, checkedAt float64) error {
+	if chain == nil {
+		chain = []tlsCertInfo{}
+	}
+	chainJSON, _ := json.Marshal(chain)
+	defer d.touchTargets()
+	d.mu.Lock()
+	_, err := d.conn.Exec(`
+		UPDATE targets SET tls_cert_not_after = ?, tls_cert_chain = ?, tls_cert_checked_at = ?
+		WHERE id = ?`,
+		notAfter, string(chainJSON), checkedAt, targetID,
+	)
+	d.mu.Unlock()
+	return err
+}
+
 // InsertModelRows bulk-inserts detection results.
 func (d *Database) InsertModelRows(runID, targetID int, rows []DetectionResult) error {
 	if len(rows) == 0 {
 		return nil
 	}
 
+	defer d.touchTargets()
 	d.mu.Lock()
 	tx, err := d.conn.Begin()
 	if err != nil {
@@ -895,8 +1493,11 @@ func (d *Database) InsertModelRows(runID, targetID int, rows []DetectionResult)
 		INSERT INTO run_models (
 			run_id, target_id, protocol, model, stream, duration, success,
 			transport_success, tool_calls_count, tool_calls, content, timestamp,
-			error, status_code, route, endpoint
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+			error, status_code, route, endpoint,
+			dns_ms, connect_ms, tls_ms, ttfb_ms, body_ms,
+			ttft_ms, tokens_per_sec, output_tokens, slow, error_category,
+			served_model, mismatch, prompt_tokens, completion_tokens, reasoning_tokens
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
 	if err != nil {
 		tx.Rollback()
 		d.mu.Unlock()
@@ -905,7 +1506,7 @@ func (d *Database) InsertModelRows(runID, targetID int, rows []DetectionResult)
 	defer stmt.Close()
 
 	for _, row := range rows {
-		_, err = stmt.Exec(
+		res, err := stmt.Exec(
 			runID, targetID,
 			row.Protocol, row.Model,
 			boolToInt(row.Stream),
@@ -920,7 +1521,20 @@ func (d *Database) InsertModelRows(runID, targetID int, rows []DetectionResult)
 			row.StatusCode,
 			row.Route,
 			row.Endpoint,
+			row.DNSMs, row.ConnectMs, row.TLSMs, row.TTFBMs, row.BodyMs,
+			row.TTFTMs, row.TokensPerSec, row.OutputTokens,
+			boolToInt(row.Slow),
+			sql.NullString{String: row.ErrorCategory, Valid: row.ErrorCategory != ""},
+			sql.NullString{String: row.ServedModel, Valid: row.ServedModel != ""},
+			boolToInt(row.Mismatch),
+			row.PromptTokens, row.CompletionTokens, row.ReasoningTokens,
 		)
+		if err == nil && row.Capture != nil {
+			err = insertProbeCapture(tx, res, runID, targetID, row.Capture)
+		}
+		if err == nil && len(row.Capabilities) > 0 {
+			err = upsertModelCapabilities(tx, targetID, row.Model, row.Capabilities)
+		}
 		if err != nil {
 			tx.Rollback()
 			d.mu.Unlock()
@@ -935,7 +1549,7 @@ func (d *Database) InsertModelRows(runID, targetID int, rows []DetectionResult)
 
 // ListRuns returns recent runs for a target.
 func (d *Database) ListRuns(targetID, limit int) ([]Run, error) {
-	conn := d.conn
+	conn := d.read
 
 	rows, err := conn.Query(`
 		SELECT `+runColumns+` FROM runs WHERE target_id = ?
@@ -959,9 +1573,28 @@ func (d *Database) ListRuns(targetID, limit int) ([]Run, error) {
 	return runs, rows.Err()
 }
 
+// ListRunningRuns returns every run still marked running, oldest first.
+func (d *Database) ListRunningRuns() ([]Run, error) {
+	rows, err := d.read.Query(`SELECT ` + runColumns + ` FROM runs WHERE status = 'running' ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var runs []Run
+	for rows.Next() {
+		r, err := scanRun(rows)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, *r)
+	}
+	return runs, rows.Err()
+}
+
 // GetLatestRun returns the most recent run for a target.
 func (d *Database) GetLatestRun(targetID int) (*Run, error) {
-	conn := d.conn
+	conn := d.read
 
 	row := conn.QueryRow(`
 		SELECT `+runColumns+` FROM runs WHERE target_id = ?
@@ -973,9 +1606,21 @@ func (d *Database) GetLatestRun(targetID int) (*Run, error) {
 	return r, err
 }
 
+// GetPreviousRun returns the run of a target started right before runID.
+func (d *Database) GetPreviousRun(targetID, runID int) (*Run, error) {
+	row := d.read.QueryRow(`
+		SELECT `+runColumns+` FROM runs WHERE target_id = ? AND id < ?
+		ORDER BY id DESC LIMIT 1`, targetID, runID)
+	r, err := scanRun(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return r, err
+}
+
 // GetRun returns a specific run by target and run id.
 func (d *Database) GetRun(targetID, runID int) (*Run, error) {
-	row := d.conn.QueryRow(
+	row := d.read.QueryRow(
 		"SELECT "+runColumns+" FROM runs WHERE target_id = ? AND id = ?",
 		targetID, runID,
 	)
@@ -988,7 +1633,7 @@ func (d *Database) GetRun(targetID, runID int) (*Run, error) {
 
 // ListLogs returns model detection results (logs) for a target.
 func (d *Database) ListLogs(targetID int, runID *int, limit int) ([]ModelRow, error) {
-	conn := d.conn
+	conn := d.read
 
 	query := "SELECT " + runModelColumns + " FROM run_models WHERE target_id = ?"
 	args := []any{targetID}
@@ -1117,6 +1762,15 @@ func normalizeStringSlice(items []string) []string {
 	return out
 }
 
+// decodeStringSlice parses a JSON string array column, falling back to an empty slice.
+func decodeStringSlice(raw string) []string {
+	var items []string
+	if err := json.Unmarshal([]byte(raw), &items); err != nil {
+		return []string{}
+	}
+	return normalizeStringSlice(items)
+}
+
 func stringSliceFromAny(v any) []string {
 	if v == nil {
 		return []string{}
@@ -1139,6 +1793,53 @@ func stringSliceFromAny(v any) []string {
 	}
 }
 
+func stringMapFromAny(v any) map[string]string {
+	out := map[string]string{}
+	switch vv := v.(type) {
+	case map[string]string:
+		for k, val := range vv {
+			if k = strings.TrimSpace(k); k != "" {
+				out[k] = val
+			}
+		}
+	case map[string]any:
+		for k, item := range vv {
+			s, ok := item.(string)
+			if !ok {
+				continue
+			}
+			if k = strings.TrimSpace(k); k != "" {
+				out[k] = s
+			}
+		}
+	}
+	return out
+}
+
+// modelOverridesFromAny converts a decoded JSON object into per-model overrides, dropping empty entries.
+func modelOverridesFromAny(v any) map[string]ModelOverride {
+	out := map[string]ModelOverride{}
+	if v == nil {
+		return out
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return out
+	}
+	var parsed map[string]ModelOverride
+	if err := json.Unmarshal(raw, &parsed); err != nil {
+		return out
+	}
+	for model, o := range parsed {
+		model = strings.TrimSpace(model)
+		if model == "" || (o.TimeoutS == nil && o.MaxTokens == nil && o.ContextTokens == nil) {
+			continue
+		}
+		out[model] = o
+	}
+	return out
+}
+
 func joinStrings(ss []string, sep string) string {
 	if len(ss) == 0 {
 		return ""
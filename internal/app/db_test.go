@@ -0,0 +1,61 @@
+package app
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReadPoolDoesNotWaitForWriter(t *testing.T) {
+	db, err := NewDatabase(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDatabase failed: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	if _, err := db.CreateTarget(map[string]any{"name": "a", "base_url": "https://example.com", "api_key": "k"}); err != nil {
+		t.Fatalf("CreateTarget failed: %v", err)
+	}
+
+	// Hold the writer connection in an open write transaction.
+	tx, err := db.conn.Begin()
+	if err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("UPDATE targets SET name = 'pending'"); err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+
+	done := make(chan []Target, 1)
+	go func() {
+		targets, _ := db.ListTargets()
+		done <- targets
+	}()
+	select {
+	case targets := <-done:
+		if len(targets) != 1 || targets[0].Name != "a" {
+			t.Fatalf("reader should see the last committed state, got=%+v", targets)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("read should not wait for the open write transaction")
+	}
+
+	if _, err := db.read.Exec("UPDATE targets SET name = 'b'"); err == nil {
+		t.Fatalf("read pool should reject writes")
+	}
+}
+
+func TestDatabaseWithoutReadPoolSharesWriter(t *testing.T) {
+	db, err := NewDatabaseWithOptions(filepath.Join(t.TempDir(), "test.db"), DatabaseOptions{BusyTimeout: time.Second})
+	if err != nil {
+		t.Fatalf("NewDatabaseWithOptions failed: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	if db.read != db.conn {
+		t.Fatalf("ReadConns=0 should send reads through the writer connection")
+	}
+	var fk int
+	if err := db.conn.QueryRow("PRAGMA foreign_keys").Scan(&fk); err != nil || fk != 1 {
+		t.Fatalf("foreign keys should be enabled from the DSN, got=%d err=%v", fk, err)
+	}
+}
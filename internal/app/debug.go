@@ -0,0 +1,18 @@
+package app
+
+import (
+	"net/http"
+	"net/http/pprof"
+)
+
+// adminPprofHandler serves the net/http/pprof endpoints under /api/admin/debug/pprof/.
+// The prefix is stripped so pprof.Index can resolve named profiles such as heap.
+func adminPprofHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	return http.StripPrefix("/api/admin", mux)
+}
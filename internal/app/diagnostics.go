@@ -0,0 +1,239 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type diagnosticSeverity string
+
+const (
+	diagnosticInfo    diagnosticSeverity = "info"
+	diagnosticWarning diagnosticSeverity = "warning"
+	diagnosticFatal   diagnosticSeverity = "fatal"
+)
+
+// diagnosticItem is one finding of the configuration validation.
+type diagnosticItem struct {
+	Severity diagnosticSeverity `json:"severity"`
+	Code     string             `json:"code"`
+	Message  string             `json:"message"`
+}
+
+// diagnosticsReport is the structured result of configuration validation.
+type diagnosticsReport struct {
+	GeneratedAtMs int64            `json:"generated_at_ms"`
+	OK            bool             `json:"ok"`
+	Fatal         int              `json:"fatal"`
+	Warnings      int              `json:"warnings"`
+	Items         []diagnosticItem `json:"items"`
+}
+
+func newDiagnosticsReport(now time.Time) *diagnosticsReport {
+	return &diagnosticsReport{GeneratedAtMs: now.UnixMilli(), OK: true, Items: []diagnosticItem{}}
+}
+
+func (r *diagnosticsReport) add(severity diagnosticSeverity, code, format string, args ...any) {
+	r.Items = append(r.Items, diagnosticItem{Severity: severity, Code: code, Message: fmt.Sprintf(format, args...)})
+	switch severity {
+	case diagnosticFatal:
+		r.Fatal++
+		r.OK = false
+	case diagnosticWarning:
+		r.Warnings++
+	}
+}
+
+func (r *diagnosticsReport) merge(other *diagnosticsReport) {
+	for _, item := range other.Items {
+		r.add(item.Severity, item.Code, "%s", item.Message)
+	}
+}
+
+// HasFatal reports whether the service must refuse to start.
+func (r *diagnosticsReport) HasFatal() bool {
+	return r.Fatal > 0
+}
+
+// Log writes every finding to the default logger, at a level matching its severity.
+func (r *diagnosticsReport) Log() {
+	logger := componentLogger(nil, "diagnostics")
+	for _, item := range r.Items {
+		level := slog.LevelInfo
+		switch item.Severity {
+		case diagnosticWarning:
+			level = slog.LevelWarn
+		case diagnosticFatal:
+			level = slog.LevelError
+		}
+		logger.Log(context.Background(), level, item.Message, "code", item.Code, "severity", item.Severity)
+	}
+	logger.Info("configuration checked", "fatal", r.Fatal, "warnings", r.Warnings)
+}
+
+// checkEnvInt reports env values that envInt would silently replace with a default.
+func checkEnvInt(r *diagnosticsReport, name string, min, max int, severity diagnosticSeverity) {
+	raw := strings.TrimSpace(os.Getenv(name))
+	if raw == "" {
+		return
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil {
+		r.add(severity, "env_invalid_int", "%s=%q is not an integer", name, raw)
+		return
+	}
+	if n < min || n > max {
+		r.add(severity, "env_out_of_range", "%s=%d must be between %d and %d", name, n, min, max)
+	}
+}
+
+// requiredWebAssets are the embedded files the router serves directly.
+var requiredWebAssets = []string{
+	"web/index.html",
+	"web/log_viewer.html",
+	"web/analysis.html",
+	"web/admin_login.html",
+	"web/admin.html",
+	"web/proxy_docs.html",
+}
+
+// validateStartupEnvironment checks environment variables, the data directory and
+// embedded assets before any state is opened.
+func validateStartupEnvironment(webFS fs.FS, dataDir string) *diagnosticsReport {
+	r := newDiagnosticsReport(time.Now())
+
+	checkEnvInt(r, "PORT", 1, 65535, diagnosticFatal)
+	checkEnvInt(r, "DEFAULT_INTERVAL_MIN", 1, 1440, diagnosticWarning)
+	checkEnvInt(r, "LOG_MAX_SIZE_MB", 0, 102400, diagnosticWarning)
+	checkEnvInt(r, "LOG_MAX_AGE_DAYS", 0, maxLogAgeDays, diagnosticWarning)
+	checkEnvInt(r, "MONITOR_DETECT_CONCURRENCY", 1, 256, diagnosticWarning)
+	checkEnvInt(r, "MONITOR_MAX_PARALLEL_TARGETS", 1, 256, diagnosticWarning)
+	checkEnvInt(r, "MONITOR_MAX_RUN_DURATION_S", 0, 86400, diagnosticWarning)
+	checkEnvInt(r, "MONITOR_RUN_ARCHIVE_DAYS", 0, maxRunArchiveDays, diagnosticWarning)
+	checkEnvInt(r, "CERT_EXPIRY_WARN_DAYS", 0, 365, diagnosticWarning)
+	checkEnvInt(r, "VISITOR_RATE_LIMIT_PER_MIN", 0, maxVisitorRateLimit, diagnosticWarning)
+	checkEnvInt(r, "VISITOR_RATE_LIMIT_BURST", 0, maxVisitorRateLimit, diagnosticWarning)
+	checkEnvInt(r, "MONITOR_DB_READ_CONNS", 0, maxDBReadConns, diagnosticWarning)
+	checkEnvInt(r, "MONITOR_DB_BUSY_TIMEOUT_MS", 0, 600000, diagnosticWarning)
+
+	if err := checkDirWritable(dataDir); err != nil {
+		r.add(diagnosticFatal, "data_dir_unwritable", "DATA_DIR %q is not usable: %v", dataDir, err)
+	}
+	validateTLSServeConfig(r, tlsServeConfigFromEnv(dataDir))
+
+	if webFS == nil {
+		r.add(diagnosticFatal, "web_assets_missing", "embedded web filesystem is not available")
+	} else {
+		for _, name := range requiredWebAssets {
+			if _, err := fs.Stat(webFS, name); err != nil {
+				r.add(diagnosticFatal, "web_assets_missing", "embedded asset %s not found", name)
+			}
+		}
+	}
+	return r
+}
+
+func checkDirWritable(dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(dir, ".write-check-*")
+	if err != nil {
+		return err
+	}
+	name := f.Name()
+	f.Close()
+	return os.Remove(filepath.Clean(name))
+}
+
+// runtimeDiagnosticsInput is the effective configuration after env and persisted settings are merged.
+type runtimeDiagnosticsInput struct {
+	AdminToken        string
+	VisitorToken      string
+	ProxyMasterToken  string
+	VisitorMode       bool
+	LogCleanupEnabled bool
+	LogMaxSizeMB      int
+	LogMaxAgeDays     int
+}
+
+// validateRuntimeConfig checks combinations of effective settings.
+func validateRuntimeConfig(in runtimeDiagnosticsInput) *diagnosticsReport {
+	r := newDiagnosticsReport(time.Now())
+
+	if in.AdminToken == "" {
+		r.add(diagnosticFatal, "admin_token_empty", "admin token is empty")
+	} else if len(in.AdminToken) < 16 {
+		r.add(diagnosticWarning, "admin_token_weak", "admin token is shorter than 16 chars")
+	}
+	if in.VisitorToken != "" && in.VisitorToken == in.AdminToken {
+		r.add(diagnosticFatal, "token_conflict", "visitor token equals admin token; visitors would get admin access")
+	}
+	if in.ProxyMasterToken != "" {
+		if in.ProxyMasterToken == in.AdminToken {
+			r.add(diagnosticWarning, "token_conflict", "proxy master token equals admin token")
+		}
+		if in.VisitorToken != "" && in.ProxyMasterToken == in.VisitorToken {
+			r.add(diagnosticWarning, "token_conflict", "proxy master token equals visitor token")
+		}
+	}
+	if in.VisitorToken == "" && in.VisitorMode {
+		r.add(diagnosticInfo, "visitor_anonymous", "visitor mode is enabled without a token; read APIs are public")
+	}
+	if in.VisitorToken != "" && !in.VisitorMode {
+		r.add(diagnosticInfo, "visitor_token_unused", "visitor token is set but only applies when visitor mode is enabled")
+	}
+	if in.LogCleanupEnabled && in.LogMaxSizeMB == 0 && in.LogMaxAgeDays == 0 {
+		r.add(diagnosticWarning, "log_cleanup_noop", "log cleanup is enabled but log_max_size_mb and log_max_age_days are 0; only per-channel age limits apply")
+	}
+	return r
+}
+
+var (
+	startupDiagnosticsMu sync.RWMutex
+	startupDiagnostics   = newDiagnosticsReport(time.Now())
+)
+
+func setStartupDiagnostics(r *diagnosticsReport) {
+	startupDiagnosticsMu.Lock()
+	startupDiagnostics = r
+	startupDiagnosticsMu.Unlock()
+}
+
+func getStartupDiagnostics() *diagnosticsReport {
+	startupDiagnosticsMu.RLock()
+	defer startupDiagnosticsMu.RUnlock()
+	return startupDiagnostics
+}
+
+// AdminGetDiagnostics handles GET /api/admin/diagnostics
+func (h *Handlers) AdminGetDiagnostics(w http.ResponseWriter, r *http.Request) {
+	settings, err := h.db.GetSettings([]string{settingProxyMasterToken})
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return
+	}
+	cleanupEnabled, cleanupMaxMB := h.monitor.LogCleanupConfig()
+	current := validateRuntimeConfig(runtimeDiagnosticsInput{
+		AdminToken:        getAdminAuthToken(),
+		VisitorToken:      getVisitorAuthToken(),
+		ProxyMasterToken:  strings.TrimSpace(settings[settingProxyMasterToken]),
+		VisitorMode:       isVisitorModeEnabled(),
+		LogCleanupEnabled: cleanupEnabled,
+		LogMaxSizeMB:      cleanupMaxMB,
+		LogMaxAgeDays:     h.monitor.LogMaxAgeDays(),
+	})
+	writeJSON(w, http.StatusOK, map[string]any{
+		"startup": getStartupDiagnostics(),
+		"current": current,
+	})
+}
@@ -0,0 +1,78 @@
+package app
+
+import (
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestValidateStartupEnvironment(t *testing.T) {
+	webFS := fstest.MapFS{}
+	for _, name := range requiredWebAssets {
+		webFS[name] = &fstest.MapFile{Data: []byte("<html></html>")}
+	}
+
+	ok := validateStartupEnvironment(webFS, t.TempDir())
+	if ok.HasFatal() {
+		t.Fatalf("valid environment should not be fatal: %+v", ok.Items)
+	}
+
+	t.Setenv("PORT", "99999")
+	t.Setenv("DEFAULT_INTERVAL_MIN", "abc")
+	delete(webFS, "web/admin.html")
+	bad := validateStartupEnvironment(webFS, t.TempDir())
+	if bad.Fatal != 2 {
+		t.Fatalf("expected fatal port and missing asset, got fatal=%d items=%+v", bad.Fatal, bad.Items)
+	}
+	if bad.Warnings != 1 {
+		t.Fatalf("expected invalid interval warning, got warnings=%d items=%+v", bad.Warnings, bad.Items)
+	}
+}
+
+func TestValidateRuntimeConfig_TokenConflict(t *testing.T) {
+	r := validateRuntimeConfig(runtimeDiagnosticsInput{
+		AdminToken:   "amtk-0123456789abcdef",
+		VisitorToken: "amtk-0123456789abcdef",
+		VisitorMode:  true,
+	})
+	if !r.HasFatal() {
+		t.Fatalf("visitor token equal to admin token should be fatal")
+	}
+
+	r = validateRuntimeConfig(runtimeDiagnosticsInput{
+		AdminToken:        "amtk-0123456789abcdef",
+		VisitorMode:       true,
+		LogCleanupEnabled: true,
+		LogMaxSizeMB:      0,
+	})
+	if r.HasFatal() || r.Warnings != 1 {
+		t.Fatalf("expected one cleanup warning, got fatal=%d warnings=%d", r.Fatal, r.Warnings)
+	}
+}
+
+func TestValidateTLSServeConfig(t *testing.T) {
+	cases := []struct {
+		name string
+		cfg  tlsServeConfig
+		code string
+	}{
+		{"plain http", tlsServeConfig{}, ""},
+		{"cert without key", tlsServeConfig{CertFile: "cert.pem"}, "tls_incomplete"},
+		{"missing files", tlsServeConfig{CertFile: "missing.pem", KeyFile: "missing.key"}, "tls_keypair_invalid"},
+		{"both modes", tlsServeConfig{CertFile: "c", KeyFile: "k", AutocertDomains: []string{"example.com"}}, "tls_conflict"},
+		{"autocert", tlsServeConfig{AutocertDomains: []string{"example.com"}, AutocertCacheDir: t.TempDir()}, ""},
+	}
+	for _, c := range cases {
+		r := newDiagnosticsReport(time.Now())
+		validateTLSServeConfig(r, c.cfg)
+		if c.code == "" {
+			if r.HasFatal() {
+				t.Fatalf("%s should be valid, got=%+v", c.name, r.Items)
+			}
+			continue
+		}
+		if len(r.Items) != 1 || r.Items[0].Code != c.code {
+			t.Fatalf("%s should report %s, got=%+v", c.name, c.code, r.Items)
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package app
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Error categories. Every failed detection is put in one category from its HTTP status
+// and error text, stored in run_models.error_category and counted by
+// GET /api/analytics/errors, so a dead key (auth_failed, quota_exceeded) can be told
+// apart from a provider outage (upstream_5xx, timeout, network_error).
+const (
+	errorCategoryAuthFailed    = "auth_failed"
+	errorCategoryQuotaExceeded = "quota_exceeded"
+	errorCategoryRateLimited   = "rate_limited"
+	errorCategoryTimeout       = "timeout"
+	errorCategoryUpstream5xx   = "upstream_5xx"
+	errorCategoryParseError    = "parse_error"
+	errorCategoryModelNotFound = "model_not_found"
+	errorCategoryNetworkError  = "network_error"
+	errorCategoryOther         = "other"
+)
+
+// Lower-cased text markers; providers often answer quota and model errors with a
+// generic 400/403/429, so the text is checked before the status.
+var (
+	quotaErrorMarkers = []string{
+		"insufficient_quota", "quota", "insufficient balance", "insufficient_balance",
+		"credit balance", "billing", "余额", "额度",
+	}
+	modelErrorMarkers = []string{
+		"model_not_found", "model not found", "no such model", "unknown model", "invalid model",
+		"model does not exist", "does not exist", "not supported model", "无可用渠道",
+	}
+	authErrorMarkers = []string{
+		"invalid_api_key", "invalid api key", "incorrect api key", "invalid token", "unauthorized",
+		"authentication", "permission denied", "令牌",
+	}
+	rateLimitErrorMarkers = []string{"rate limit", "rate_limit", "too many requests", "ratelimit"}
+	timeoutErrorMarkers   = []string{"timeout", "deadline exceeded", "timed out"}
+	parseErrorMarkers     = []string{
+		"parse failed", "invalid character", "unexpected end of json", "cannot unmarshal",
+	}
+)
+
+// classifyDetectionError returns the category of a failed detection. statusCode is nil
+// when no HTTP response was received.
+func classifyDetectionError(statusCode *int, message string) string {
+	msg := strings.ToLower(message)
+	hasAny := func(markers []string) bool {
+		for _, m := range markers {
+			if strings.Contains(msg, m) {
+				return true
+			}
+		}
+		return false
+	}
+	if statusCode == nil {
+		if hasAny(timeoutErrorMarkers) {
+			return errorCategoryTimeout
+		}
+		return errorCategoryNetworkError
+	}
+	code := *statusCode
+	switch {
+	case code == http.StatusPaymentRequired || hasAny(quotaErrorMarkers):
+		return errorCategoryQuotaExceeded
+	case hasAny(modelErrorMarkers) || (code == http.StatusNotFound && strings.Contains(msg, "model")):
+		return errorCategoryModelNotFound
+	case code == http.StatusUnauthorized || code == http.StatusForbidden || hasAny(authErrorMarkers):
+		return errorCategoryAuthFailed
+	case code == http.StatusTooManyRequests || hasAny(rateLimitErrorMarkers):
+		return errorCategoryRateLimited
+	case code == http.StatusRequestTimeout || code == http.StatusGatewayTimeout || hasAny(timeoutErrorMarkers):
+		return errorCategoryTimeout
+	case code >= 500:
+		return errorCategoryUpstream5xx
+	case hasAny(parseErrorMarkers):
+		return errorCategoryParseError
+	}
+	return errorCategoryOther
+}
+
+// backfillErrorCategories classifies the failed rows stored before error_category
+// existed.
+func (d *Database) backfillErrorCategories() error {
+	rows, err := d.conn.Query(`SELECT id, status_code, error FROM run_models WHERE success = 0 AND error_category IS NULL`)
+	if err != nil {
+		return err
+	}
+	type pending struct {
+		id       int64
+		category string
+	}
+	var updates []pending
+	for rows.Next() {
+		var id int64
+		var statusCode *int
+		var message sql.NullString
+		if err := rows.Scan(&id, &statusCode, &message); err != nil {
+			rows.Close()
+			return err
+		}
+		updates = append(updates, pending{id, classifyDetectionError(statusCode, message.String)})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil || len(updates) == 0 {
+		return err
+	}
+
+	tx, err := d.conn.Begin()
+	if err != nil {
+		return err
+	}
+	stmt, err := tx.Prepare(`UPDATE run_models SET error_category = ? WHERE id = ?`)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+	for _, u := range updates {
+		if _, err := stmt.Exec(u.category, u.id); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// ErrorCategoryCount is the number of failed detections of one target in one category.
+type ErrorCategoryCount struct {
+	TargetID   int     `json:"target_id"`
+	TargetName string  `json:"target_name"`
+	Category   string  `json:"category"`
+	Count      int     `json:"count"`
+	LastAt     float64 `json:"last_at"`
+}
+
+// GetErrorCategories counts the failed detections within [since, until) by target and
+// category, largest first.
+func (d *Database) GetErrorCategories(since, until float64, targetID *int) ([]ErrorCategoryCount, error) {
+	args := []any{errorCategoryOther, since, until}
+	targetFilter := ""
+	if targetID != nil {
+		targetFilter = "AND rm.target_id = ?"
+		args = append(args, *targetID)
+	}
+	rows, err := d.read.Query(`
+		SELECT rm.target_id, t.name, COALESCE(rm.error_category, ?) AS category,
+			COUNT(*), MAX(rm.timestamp)
+		FROM run_models rm
+		JOIN targets t ON t.id = rm.target_id AND t.deleted_at IS NULL
+		WHERE rm.success = 0 AND rm.timestamp >= ? AND rm.timestamp < ? `+targetFilter+`
+		GROUP BY rm.target_id, category
+		ORDER BY COUNT(*) DESC, rm.target_id ASC, category ASC`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []ErrorCategoryCount{}
+	for rows.Next() {
+		var c ErrorCategoryCount
+		if err := rows.Scan(&c.TargetID, &c.TargetName, &c.Category, &c.Count, &c.LastAt); err != nil {
+			return nil, err
+		}
+		items = append(items, c)
+	}
+	return items, rows.Err()
+}
+
+// ErrorCategories -- GET /api/analytics/errors?since=&until=&target_id=
+// Counts failed detections by category per target (default last 24 hours); totals sums
+// them over all visible targets.
+func (h *Handlers) ErrorCategories(w http.ResponseWriter, r *http.Request) {
+	since, until, ok := queryTimeRange(r, 24)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "since/until must be unix timestamps with since < until"})
+		return
+	}
+	var targetID *int
+	if s := r.URL.Query().Get("target_id"); s != "" {
+		id, err := strconv.Atoi(s)
+		if err != nil || id < 1 {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "invalid target_id"})
+			return
+		}
+		targetID = &id
+	}
+	items, err := h.db.GetErrorCategories(since, until, targetID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return
+	}
+	visible := items[:0]
+	totals := map[string]int{}
+	for _, item := range items {
+		if principalAllowsTarget(r, item.TargetID) {
+			visible = append(visible, item)
+			totals[item.Category] += item.Count
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"since":  since,
+		"until":  until,
+		"totals": totals,
+		"items":  visible,
+	})
+}
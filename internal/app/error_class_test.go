@@ -0,0 +1,86 @@
+package app
+
+import (
+	"testing"
+)
+
+func TestClassifyDetectionError(t *testing.T) {
+	code := func(c int) *int { return &c }
+	cases := []struct {
+		status  *int
+		message string
+		want    string
+	}{
+		{code(401), "HTTP 401: Incorrect API key provided", errorCategoryAuthFailed},
+		{code(403), "HTTP 403: forbidden", errorCategoryAuthFailed},
+		{code(429), "HTTP 429: You exceeded your current quota", errorCategoryQuotaExceeded},
+		{code(429), "HTTP 429: Rate limit reached for requests", errorCategoryRateLimited},
+		{code(402), "HTTP 402: payment required", errorCategoryQuotaExceeded},
+		{code(404), "HTTP 404: The model `gpt-9` does not exist", errorCategoryModelNotFound},
+		{code(503), "HTTP 503: 当前分组 default 下对于模型 x 无可用渠道", errorCategoryModelNotFound},
+		{code(502), "HTTP 502: bad gateway", errorCategoryUpstream5xx},
+		{code(504), "HTTP 504: gateway timeout", errorCategoryTimeout},
+		{code(200), "response parse failed: no readable text", errorCategoryParseError},
+		{nil, "Post \"https://x/v1/chat/completions\": context deadline exceeded (Client.Timeout exceeded while awaiting headers)", errorCategoryTimeout},
+		{nil, "dial tcp: lookup x: no such host", errorCategoryNetworkError},
+		{code(400), "HTTP 400: bad request", errorCategoryOther},
+	}
+	for _, tc := range cases {
+		if got := classifyDetectionError(tc.status, tc.message); got != tc.want {
+			t.Fatalf("%q should be %s, got=%s", tc.message, tc.want, got)
+		}
+	}
+}
+
+func TestGetErrorCategoriesCountsFailures(t *testing.T) {
+	db, target := newAnalyticsTestDB(t)
+	msg := "HTTP 401: invalid api key"
+	status := 401
+	insertAnalyticsRows(t, db, target.ID, []DetectionResult{
+		{Model: "a", Success: false, Timestamp: 1000, Error: &msg, StatusCode: &status, ErrorCategory: errorCategoryAuthFailed},
+		{Model: "b", Success: false, Timestamp: 1001, Error: &msg, StatusCode: &status, ErrorCategory: errorCategoryAuthFailed},
+		{Model: "c", Success: false, Timestamp: 1002, Error: &msg},
+		{Model: "d", Success: true, Timestamp: 1003},
+	})
+
+	items, err := db.GetErrorCategories(0, 2000, nil)
+	if err != nil {
+		t.Fatalf("GetErrorCategories failed: %v", err)
+	}
+	if len(items) != 2 || items[0].Category != errorCategoryAuthFailed || items[0].Count != 2 || items[0].LastAt != 1001 {
+		t.Fatalf("auth failures should be counted first, got=%+v", items)
+	}
+	if items[1].Category != errorCategoryOther || items[1].Count != 1 {
+		t.Fatalf("rows without a category should count as other, got=%+v", items[1])
+	}
+
+	logs, err := db.ListLogs(target.ID, nil, 10)
+	if err != nil {
+		t.Fatalf("ListLogs failed: %v", err)
+	}
+	stored := 0
+	for _, row := range logs {
+		if row.ErrorCategory != nil && *row.ErrorCategory == errorCategoryAuthFailed {
+			stored++
+		}
+	}
+	if stored != 2 {
+		t.Fatalf("error_category should be stored with the row, got=%d", stored)
+	}
+}
+
+func TestBackfillErrorCategories(t *testing.T) {
+	db, target := newAnalyticsTestDB(t)
+	msg := "HTTP 429: Rate limit reached"
+	status := 429
+	insertAnalyticsRows(t, db, target.ID, []DetectionResult{
+		{Model: "a", Success: false, Timestamp: 1000, Error: &msg, StatusCode: &status},
+	})
+	if err := db.backfillErrorCategories(); err != nil {
+		t.Fatalf("backfillErrorCategories failed: %v", err)
+	}
+	items, err := db.GetErrorCategories(0, 2000, &target.ID)
+	if err != nil || len(items) != 1 || items[0].Category != errorCategoryRateLimited {
+		t.Fatalf("stored failures should be classified, got=%+v err=%v", items, err)
+	}
+}
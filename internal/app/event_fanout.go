@@ -0,0 +1,498 @@
+package app
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Event fan-out. With EVENT_FANOUT_URL set to a NATS (nats://, tls://) or Redis
+// (redis://, rediss://) server, every SSE bus event is published to the subject or
+// channel EVENT_FANOUT_SUBJECT (default "api_monitor.events") as
+// {"instance":"...","event":"...","data":{...}}. With EVENT_FANOUT_SUBSCRIBE=true the
+// instance also subscribes and relays events published by other instances to its own
+// SSE and WebSocket clients, so several instances behind a load balancer share one
+// stream; relayed events are not published again. Publishing never blocks: while the
+// server is unreachable events are dropped and counted, and the connection is retried.
+
+const (
+	eventFanoutNATS  = "nats"
+	eventFanoutRedis = "redis"
+
+	eventFanoutQueueSize    = 4096
+	eventFanoutDialTimeout  = 10 * time.Second
+	eventFanoutRetryBackoff = 15 * time.Second
+
+	defaultEventFanoutSubject = "api_monitor.events"
+)
+
+type fanoutEnvelope struct {
+	Instance string          `json:"instance"`
+	Event    string          `json:"event"`
+	Data     json.RawMessage `json:"data"`
+}
+
+type eventFanout struct {
+	kind      string
+	addr      string
+	useTLS    bool
+	username  string
+	password  string
+	db        int
+	subject   string
+	instance  string
+	subscribe bool
+	log       *slog.Logger
+	// relay delivers events from other instances to local clients.
+	relay func(event, data string)
+
+	queue   chan busEvent
+	stop    chan struct{}
+	done    sync.WaitGroup
+	dropped atomic.Int64
+
+	// conn, reader and nextDial are used by the publish loop; NATS PONGs are written
+	// from the reader goroutine, so writes hold writeMu.
+	writeMu  sync.Mutex
+	conn     net.Conn
+	reader   *bufio.Reader
+	nextDial time.Time
+	subConn  atomic.Pointer[net.Conn]
+}
+
+var eventFanoutInstance atomic.Pointer[eventFanout]
+
+// eventFanoutFromEnv builds the fan-out configured by the EVENT_FANOUT_* environment, or
+// returns nil when EVENT_FANOUT_URL is unset.
+func eventFanoutFromEnv() (*eventFanout, error) {
+	raw := strings.TrimSpace(os.Getenv("EVENT_FANOUT_URL"))
+	if raw == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Hostname() == "" {
+		return nil, fmt.Errorf("EVENT_FANOUT_URL must be a nats:// or redis:// URL, got %q", raw)
+	}
+	f := &eventFanout{
+		subject:   strings.TrimSpace(os.Getenv("EVENT_FANOUT_SUBJECT")),
+		subscribe: parseBoolString(os.Getenv("EVENT_FANOUT_SUBSCRIBE"), false),
+	}
+	port := ""
+	switch u.Scheme {
+	case "nats", "tls":
+		f.kind, port, f.useTLS = eventFanoutNATS, "4222", u.Scheme == "tls"
+	case "redis", "rediss":
+		f.kind, port, f.useTLS = eventFanoutRedis, "6379", u.Scheme == "rediss"
+		if db := strings.Trim(u.Path, "/"); db != "" {
+			if f.db, err = strconv.Atoi(db); err != nil || f.db < 0 {
+				return nil, fmt.Errorf("EVENT_FANOUT_URL redis database must be a number, got %q", db)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("EVENT_FANOUT_URL scheme must be nats, tls, redis or rediss, got %q", u.Scheme)
+	}
+	if u.Port() != "" {
+		port = u.Port()
+	}
+	f.addr = net.JoinHostPort(u.Hostname(), port)
+	if u.User != nil {
+		f.username = u.User.Username()
+		f.password, _ = u.User.Password()
+	}
+	if f.subject == "" {
+		f.subject = defaultEventFanoutSubject
+	}
+	if strings.ContainsAny(f.subject, " \t\r\n") {
+		return nil, fmt.Errorf("EVENT_FANOUT_SUBJECT must not contain whitespace, got %q", f.subject)
+	}
+	host, _ := os.Hostname()
+	suffix := make([]byte, 4)
+	_, _ = rand.Read(suffix)
+	f.instance = host + "-" + hex.EncodeToString(suffix)
+	return f, nil
+}
+
+// startEventFanout installs the fan-out configured by the environment; relay receives
+// the events of other instances. The returned function stops it after sending pending
+// events. It is a no-op when EVENT_FANOUT_URL is unset.
+func startEventFanout(logger *slog.Logger, relay func(event, data string)) (func(context.Context), error) {
+	f, err := eventFanoutFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	if f == nil {
+		return func(context.Context) {}, nil
+	}
+	f.start(logger, relay)
+	f.log.Info("event fan-out enabled", "server", f.kind, "addr", f.addr, "subject", f.subject, "subscribe", f.subscribe, "instance", f.instance)
+	return f.shutdown, nil
+}
+
+func (f *eventFanout) start(logger *slog.Logger, relay func(event, data string)) {
+	f.log = componentLogger(logger, "event_fanout")
+	f.relay = relay
+	f.queue = make(chan busEvent, eventFanoutQueueSize)
+	f.stop = make(chan struct{})
+	f.done.Add(1)
+	go f.publishLoop()
+	if f.subscribe {
+		f.done.Add(1)
+		go f.subscribeLoop()
+	}
+	eventFanoutInstance.Store(f)
+}
+
+// publishFanoutEvent queues a bus event for the fan-out server, if one is configured.
+func publishFanoutEvent(event, data string) {
+	if f := eventFanoutInstance.Load(); f != nil {
+		select {
+		case f.queue <- busEvent{Event: event, Data: data}:
+		default:
+			f.dropped.Add(1)
+		}
+	}
+}
+
+func (f *eventFanout) shutdown(ctx context.Context) {
+	eventFanoutInstance.CompareAndSwap(f, nil)
+	close(f.stop)
+	if c := f.subConn.Load(); c != nil {
+		_ = (*c).Close()
+	}
+	done := make(chan struct{})
+	go func() {
+		f.done.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+	if n := f.dropped.Load(); n > 0 {
+		f.log.Warn("fan-out events dropped", "dropped", n)
+	}
+}
+
+func (f *eventFanout) publishLoop() {
+	defer f.done.Done()
+	for {
+		select {
+		case ev := <-f.queue:
+			f.send(ev)
+		case <-f.stop:
+			for {
+				select {
+				case ev := <-f.queue:
+					f.send(ev)
+				default:
+					if f.conn != nil {
+						_ = f.conn.Close()
+					}
+					return
+				}
+			}
+		}
+	}
+}
+
+func (f *eventFanout) send(ev busEvent) {
+	data := json.RawMessage(ev.Data)
+	if !json.Valid(data) {
+		data, _ = json.Marshal(ev.Data)
+	}
+	payload, _ := json.Marshal(fanoutEnvelope{Instance: f.instance, Event: ev.Event, Data: data})
+	if f.conn == nil {
+		if time.Now().Before(f.nextDial) {
+			f.dropped.Add(1)
+			return
+		}
+		conn, reader, err := f.dial()
+		if err != nil {
+			f.nextDial = time.Now().Add(eventFanoutRetryBackoff)
+			f.log.Warn("fan-out connect failed", "addr", f.addr, "error", err)
+			f.dropped.Add(1)
+			return
+		}
+		f.conn, f.reader = conn, reader
+		if f.kind == eventFanoutNATS {
+			go f.answerPings(conn, reader)
+		}
+	}
+	if err := f.publish(payload); err != nil {
+		f.log.Warn("fan-out publish failed", "addr", f.addr, "error", err)
+		_ = f.conn.Close()
+		f.conn = nil
+		f.dropped.Add(1)
+	}
+}
+
+func (f *eventFanout) publish(payload []byte) error {
+	f.writeMu.Lock()
+	defer f.writeMu.Unlock()
+	if f.kind == eventFanoutNATS {
+		_ = f.conn.SetWriteDeadline(time.Now().Add(eventFanoutDialTimeout))
+		_, err := fmt.Fprintf(f.conn, "PUB %s %d\r\n%s\r\n", f.subject, len(payload), payload)
+		return err
+	}
+	_ = f.conn.SetDeadline(time.Now().Add(eventFanoutDialTimeout))
+	if _, err := f.conn.Write(redisCommand("PUBLISH", f.subject, string(payload))); err != nil {
+		return err
+	}
+	reply, err := readRESP(f.reader)
+	if err != nil {
+		return err
+	}
+	if e, ok := reply.(error); ok {
+		return e
+	}
+	return nil
+}
+
+// answerPings reads the NATS publish connection, answering server PINGs, until it closes.
+func (f *eventFanout) answerPings(conn net.Conn, r *bufio.Reader) {
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		if strings.HasPrefix(line, "PING") {
+			f.writeMu.Lock()
+			_, _ = conn.Write([]byte("PONG\r\n"))
+			f.writeMu.Unlock()
+		}
+	}
+}
+
+// dial connects and authenticates to the fan-out server.
+func (f *eventFanout) dial() (net.Conn, *bufio.Reader, error) {
+	conn, err := net.DialTimeout("tcp", f.addr, eventFanoutDialTimeout)
+	if err != nil {
+		return nil, nil, err
+	}
+	if f.useTLS {
+		host, _, _ := net.SplitHostPort(f.addr)
+		conn = tls.Client(conn, &tls.Config{ServerName: host})
+	}
+	_ = conn.SetDeadline(time.Now().Add(eventFanoutDialTimeout))
+	r := bufio.NewReader(conn)
+	if err := f.handshake(conn, r); err != nil {
+		_ = conn.Close()
+		return nil, nil, err
+	}
+	_ = conn.SetDeadline(time.Time{})
+	return conn, r, nil
+}
+
+func (f *eventFanout) handshake(conn net.Conn, r *bufio.Reader) error {
+	if f.kind == eventFanoutNATS {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		if !strings.HasPrefix(line, "INFO") {
+			return fmt.Errorf("unexpected NATS greeting %q", strings.TrimSpace(line))
+		}
+		opts := map[string]any{"verbose": false, "pedantic": false, "name": "api_monitor", "lang": "go"}
+		if f.username != "" && f.password != "" {
+			opts["user"], opts["pass"] = f.username, f.password
+		} else if f.username != "" {
+			opts["auth_token"] = f.username
+		}
+		raw, _ := json.Marshal(opts)
+		if _, err := fmt.Fprintf(conn, "CONNECT %s\r\nPING\r\n", raw); err != nil {
+			return err
+		}
+		line, err = r.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		if !strings.HasPrefix(line, "PONG") {
+			return fmt.Errorf("NATS connect failed: %s", strings.TrimSpace(line))
+		}
+		return nil
+	}
+	var cmds [][]byte
+	if f.password != "" {
+		if f.username != "" {
+			cmds = append(cmds, redisCommand("AUTH", f.username, f.password))
+		} else {
+			cmds = append(cmds, redisCommand("AUTH", f.password))
+		}
+	}
+	if f.db > 0 {
+		cmds = append(cmds, redisCommand("SELECT", strconv.Itoa(f.db)))
+	}
+	for _, cmd := range cmds {
+		if _, err := conn.Write(cmd); err != nil {
+			return err
+		}
+		reply, err := readRESP(r)
+		if err != nil {
+			return err
+		}
+		if e, ok := reply.(error); ok {
+			return e
+		}
+	}
+	return nil
+}
+
+// subscribeLoop relays events of other instances until shutdown, reconnecting on errors.
+func (f *eventFanout) subscribeLoop() {
+	defer f.done.Done()
+	for {
+		err := f.subscribeOnce()
+		select {
+		case <-f.stop:
+			return
+		default:
+		}
+		f.log.Warn("fan-out subscription lost", "addr", f.addr, "error", err)
+		select {
+		case <-f.stop:
+			return
+		case <-time.After(eventFanoutRetryBackoff):
+		}
+	}
+}
+
+func (f *eventFanout) subscribeOnce() error {
+	conn, r, err := f.dial()
+	if err != nil {
+		return err
+	}
+	f.subConn.Store(&conn)
+	defer conn.Close()
+	select {
+	case <-f.stop:
+		return nil
+	default:
+	}
+	if f.kind == eventFanoutNATS {
+		if _, err := fmt.Fprintf(conn, "SUB %s 1\r\n", f.subject); err != nil {
+			return err
+		}
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return err
+			}
+			switch {
+			case strings.HasPrefix(line, "PING"):
+				if _, err := conn.Write([]byte("PONG\r\n")); err != nil {
+					return err
+				}
+			case strings.HasPrefix(line, "MSG "):
+				fields := strings.Fields(line)
+				size, err := strconv.Atoi(fields[len(fields)-1])
+				if err != nil || size < 0 {
+					return fmt.Errorf("malformed NATS message header %q", strings.TrimSpace(line))
+				}
+				payload := make([]byte, size+2)
+				if _, err := io.ReadFull(r, payload); err != nil {
+					return err
+				}
+				f.deliver(payload[:size])
+			case strings.HasPrefix(line, "-ERR"):
+				return fmt.Errorf("NATS error: %s", strings.TrimSpace(line))
+			}
+		}
+	}
+	if _, err := conn.Write(redisCommand("SUBSCRIBE", f.subject)); err != nil {
+		return err
+	}
+	for {
+		reply, err := readRESP(r)
+		if err != nil {
+			return err
+		}
+		if e, ok := reply.(error); ok {
+			return e
+		}
+		if parts, ok := reply.([]any); ok && len(parts) == 3 && parts[0] == "message" {
+			if payload, ok := parts[2].(string); ok {
+				f.deliver([]byte(payload))
+			}
+		}
+	}
+}
+
+// deliver relays an envelope published by another instance to local clients.
+func (f *eventFanout) deliver(payload []byte) {
+	var env fanoutEnvelope
+	if json.Unmarshal(payload, &env) != nil || env.Event == "" || env.Instance == f.instance {
+		return
+	}
+	if f.relay != nil {
+		f.relay(env.Event, string(env.Data))
+	}
+}
+
+// redisCommand encodes a command as a RESP array of bulk strings.
+func redisCommand(args ...string) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(a), a)
+	}
+	return []byte(b.String())
+}
+
+// readRESP reads one RESP reply: strings, integers, nil, arrays, or an error value.
+func readRESP(r *bufio.Reader) (any, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if line == "" {
+		return nil, fmt.Errorf("empty RESP reply")
+	}
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return fmt.Errorf("redis: %s", line[1:]), nil
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		out := make([]any, 0, n)
+		for i := 0; i < n; i++ {
+			v, err := readRESP(r)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, v)
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("unexpected RESP reply %q", line)
+}
@@ -0,0 +1,163 @@
+package app
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEventFanoutPublishesToRedis(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	got := make(chan []any, 16)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		for {
+			cmd, err := readRESP(r)
+			if err != nil {
+				close(got)
+				return
+			}
+			args, _ := cmd.([]any)
+			got <- args
+			if len(args) > 0 && args[0] == "PUBLISH" {
+				_, _ = conn.Write([]byte(":0\r\n"))
+			} else {
+				_, _ = conn.Write([]byte("+OK\r\n"))
+			}
+		}
+	}()
+
+	t.Setenv("EVENT_FANOUT_URL", "redis://:pw@"+ln.Addr().String()+"/2")
+	t.Setenv("EVENT_FANOUT_SUBJECT", "monitor.events")
+	f, err := eventFanoutFromEnv()
+	if err != nil {
+		t.Fatalf("eventFanoutFromEnv failed: %v", err)
+	}
+	f.start(nil, nil)
+	publishFanoutEvent("model_checked", `{"target_id":3,"model":"m"}`)
+	publishFanoutEvent("run_completed", `{"target_id":3,"status":"down"}`)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	f.shutdown(ctx)
+
+	var cmds [][]any
+	for cmd := range got {
+		cmds = append(cmds, cmd)
+	}
+	if len(cmds) != 4 || fmt.Sprint(cmds[0]) != "[AUTH pw]" || fmt.Sprint(cmds[1]) != "[SELECT 2]" {
+		t.Fatalf("expected AUTH, SELECT and two PUBLISH commands, got=%v", cmds)
+	}
+	var env fanoutEnvelope
+	if cmds[3][1] != "monitor.events" || json.Unmarshal([]byte(cmds[3][2].(string)), &env) != nil {
+		t.Fatalf("unexpected PUBLISH command: %v", cmds[3])
+	}
+	if env.Instance != f.instance || env.Event != "run_completed" || string(env.Data) != `{"target_id":3,"status":"down"}` {
+		t.Fatalf("unexpected envelope: %+v", env)
+	}
+}
+
+func TestEventFanoutRelaysOtherInstancesFromNATS(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	t.Setenv("EVENT_FANOUT_URL", "nats://"+ln.Addr().String())
+	t.Setenv("EVENT_FANOUT_SUBSCRIBE", "true")
+	f, err := eventFanoutFromEnv()
+	if err != nil {
+		t.Fatalf("eventFanoutFromEnv failed: %v", err)
+	}
+	published := make(chan string, 4)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				_, _ = conn.Write([]byte("INFO {\"server_id\":\"test\"}\r\n"))
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					switch {
+					case strings.HasPrefix(line, "PING"):
+						_, _ = conn.Write([]byte("PONG\r\n"))
+					case strings.HasPrefix(line, "SUB "):
+						for _, env := range []string{
+							`{"instance":"` + f.instance + `","event":"run_completed","data":{"target_id":1}}`,
+							`{"instance":"other","event":"run_completed","data":{"target_id":2}}`,
+						} {
+							fmt.Fprintf(conn, "MSG api_monitor.events 1 %d\r\n%s\r\n", len(env), env)
+						}
+					case strings.HasPrefix(line, "PUB "):
+						fields := strings.Fields(line)
+						n, _ := strconv.Atoi(fields[2])
+						payload := make([]byte, n+2)
+						if _, err := io.ReadFull(r, payload); err != nil {
+							return
+						}
+						published <- fields[1] + " " + string(payload[:n])
+					}
+				}
+			}(conn)
+		}
+	}()
+
+	relayed := make(chan string, 4)
+	f.start(nil, func(event, data string) { relayed <- event + " " + data })
+	publishFanoutEvent("incident_opened", `{"target_id":5}`)
+	select {
+	case msg := <-relayed:
+		if msg != `run_completed {"target_id":2}` {
+			t.Fatalf("only events of other instances should be relayed, got %q", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected a relayed event")
+	}
+	select {
+	case msg := <-published:
+		if !strings.HasPrefix(msg, "api_monitor.events ") || !strings.Contains(msg, `"event":"incident_opened"`) {
+			t.Fatalf("unexpected published message: %q", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected a published event")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	f.shutdown(ctx)
+	select {
+	case msg := <-relayed:
+		t.Fatalf("own events must not be relayed, got %q", msg)
+	default:
+	}
+}
+
+func TestEventFanoutURLValidation(t *testing.T) {
+	for _, raw := range []string{"http://bus:4222", "nats://", "redis://bus/x"} {
+		t.Setenv("EVENT_FANOUT_URL", raw)
+		if _, err := eventFanoutFromEnv(); err == nil {
+			t.Fatalf("EVENT_FANOUT_URL %q should be rejected", raw)
+		}
+	}
+}
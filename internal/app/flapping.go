@@ -0,0 +1,107 @@
+package app
+
+import "fmt"
+
+// Flap suppression. Every incident opened or closed is a status transition; a target
+// with more than notifyFlapThreshold transitions within flapWindowSeconds is flapping.
+// Its incident events still reach SSE clients but carry "flapping": true and are not
+// notified; instead one target_flapping alert is sent when it starts and one
+// flapping_ended alert (with the status it settled on) once the window holds no more
+// than the threshold again.
+const flapWindowSeconds = 3600.0
+
+// UpdateNotifyFlapThreshold sets the transitions per hour above which a target is
+// flapping; 0 disables suppression.
+func (ms *MonitorService) UpdateNotifyFlapThreshold(n int) {
+	ms.flapMu.Lock()
+	ms.flapThreshold = max(n, 0)
+	ms.flapMu.Unlock()
+}
+
+// NotifyFlapThreshold returns the current flapping threshold.
+func (ms *MonitorService) NotifyFlapThreshold() int {
+	ms.flapMu.Lock()
+	defer ms.flapMu.Unlock()
+	return ms.flapThreshold
+}
+
+// recentTransitions drops the transitions of times that left the window ending at now.
+func recentTransitions(times []float64, now float64) []float64 {
+	i := 0
+	for i < len(times) && times[i] <= now-flapWindowSeconds {
+		i++
+	}
+	return times[i:]
+}
+
+// noteTransition records an incident transition of target at now and reports whether
+// the target is flapping, emitting target_flapping when it starts to.
+func (ms *MonitorService) noteTransition(target *Target, now float64) bool {
+	ms.flapMu.Lock()
+	if ms.flapThreshold <= 0 {
+		ms.flapMu.Unlock()
+		return false
+	}
+	if ms.flapTransitions == nil {
+		ms.flapTransitions = map[int][]float64{}
+		ms.flapping = map[int]bool{}
+	}
+	times := append(recentTransitions(ms.flapTransitions[target.ID], now), now)
+	ms.flapTransitions[target.ID] = times
+	started := len(times) > ms.flapThreshold && !ms.flapping[target.ID]
+	if started {
+		ms.flapping[target.ID] = true
+	}
+	flapping, threshold := ms.flapping[target.ID], ms.flapThreshold
+	ms.flapMu.Unlock()
+
+	if started {
+		ms.logger().Warn("target flapping", "target", target.Name, "target_id", target.ID, "transitions", len(times))
+		ms.emitJSON("target_flapping", map[string]any{
+			"target_id":   target.ID,
+			"target_name": target.Name,
+			"transitions": len(times),
+			"window_s":    flapWindowSeconds,
+			"message":     fmt.Sprintf("%d status changes in the last hour (threshold %d); alerts are suppressed until it is stable", len(times), threshold),
+		})
+	}
+	return flapping
+}
+
+// sweepFlapping ends flapping for targets whose window dropped back to the threshold
+// and forgets transitions that left the window.
+func (ms *MonitorService) sweepFlapping(now float64) {
+	ms.flapMu.Lock()
+	var ended []int
+	for id, times := range ms.flapTransitions {
+		times = recentTransitions(times, now)
+		if ms.flapping[id] && len(times) <= ms.flapThreshold {
+			delete(ms.flapping, id)
+			ended = append(ended, id)
+		}
+		if len(times) == 0 && !ms.flapping[id] {
+			delete(ms.flapTransitions, id)
+		} else {
+			ms.flapTransitions[id] = times
+		}
+	}
+	ms.flapMu.Unlock()
+
+	for _, id := range ended {
+		target, err := ms.db.GetTarget(id)
+		if err != nil || target == nil {
+			continue
+		}
+		status := "unknown"
+		if target.LastStatus != nil {
+			status = *target.LastStatus
+		}
+		ms.logger().Info("target stable", "target", target.Name, "target_id", target.ID, "status", status)
+		ms.emitJSON("flapping_ended", map[string]any{
+			"target_id":   target.ID,
+			"target_name": target.Name,
+			"status":      status,
+			"message":     "stopped flapping; current status " + status,
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestFlappingSuppressesIncidentNotifications(t *testing.T) {
+	var mu sync.Mutex
+	var delivered []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var n Notification
+		_ = json.NewDecoder(r.Body).Decode(&n)
+		mu.Lock()
+		delivered = append(delivered, n.Event)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	db, err := NewDatabase(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDatabase failed: %v", err)
+	}
+	t.Cleanup(func() { _ = db.conn.Close() })
+	if err := db.EnsureNotificationSchema(); err != nil {
+		t.Fatal(err)
+	}
+	ms := NewMonitorService(MonitorConfig{DB: db, LogDir: t.TempDir(), NotifyFlapThreshold: 2})
+	var events []string
+	ms.SetEventCallback(func(eventType, data string) {
+		mu.Lock()
+		events = append(events, eventType)
+		mu.Unlock()
+	})
+	target, err := db.CreateTarget(map[string]any{"name": "flappy", "base_url": "https://a.example.com", "api_key": "sk-1"})
+	if err != nil {
+		t.Fatalf("CreateTarget failed: %v", err)
+	}
+	hook, err := db.CreateNotificationChannel(&NotificationChannel{Name: "hook", Type: "webhook", Enabled: true, Config: map[string]any{"url": srv.URL}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.CreateNotificationRoute(&NotificationRoute{MinSeverity: severityInfo, ChannelIDs: []int{hook.ID}, Enabled: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ms.ReloadNotifications(); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, want := range []bool{false, false, true, true} {
+		now := 1000.0 + float64(i)
+		flapping := ms.noteTransition(target, now)
+		if flapping != want {
+			t.Fatalf("transition %d: flapping=%v, want %v", i+1, flapping, want)
+		}
+		ms.emitJSON("incident_opened", map[string]any{"target_id": target.ID, "target_name": target.Name, "status": "down", "flapping": flapping})
+	}
+	ms.sweepFlapping(1002 + flapWindowSeconds)
+	if ms.noteTransition(target, 1003+flapWindowSeconds) {
+		t.Fatalf("target should be stable once its window has drained")
+	}
+	ms.notifyWG.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	count := map[string]int{}
+	for _, ev := range delivered {
+		count[ev]++
+	}
+	if count["incident_opened"] != 2 || count["target_flapping"] != 1 || count["flapping_ended"] != 1 {
+		t.Fatalf("flapping incidents should be replaced by one flapping alert, got=%v", delivered)
+	}
+	sse := 0
+	for _, ev := range events {
+		if ev == "incident_opened" {
+			sse++
+		}
+	}
+	if sse != 4 {
+		t.Fatalf("SSE clients should still see every incident event, got=%v", events)
+	}
+}
+
+func TestRecoveryNotificationReportsDowntime(t *testing.T) {
+	n := Notification{Event: "incident_closed", TargetName: "prod", Data: map[string]any{"status": "healthy", "duration_s": 754.3}}
+	describeNotification(&n)
+	if n.Title != "prod recovered" || n.Message != "Back to healthy after 12m34s of downtime" {
+		t.Fatalf("unexpected recovery text: %q / %q", n.Title, n.Message)
+	}
+}
@@ -2,11 +2,16 @@ package app
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
 	"math"
 	"net/http"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 )
 
 const modelHistoryPoints = 30
@@ -18,9 +23,9 @@ func writeJSON(w http.ResponseWriter, status int, data any) {
 	json.NewEncoder(w).Encode(data)
 }
 
-// readJSON decodes a JSON request body into target.
+// readJSON decodes a JSON request body into target; see decodeJSONBody.
 func readJSON(r *http.Request, target any) error {
-	return json.NewDecoder(r.Body).Decode(target)
+	return decodeJSONBody(r.Body, target)
 }
 
 // pathID extracts the integer id from the URL path variable.
@@ -85,104 +90,401 @@ func validateTargetPayload(payload map[string]any) error {
 	if v, ok := payload["name"]; ok {
 		s := strings.TrimSpace(stringFromAny(v, ""))
 		if s == "" || len(s) > 128 {
-			return fmt.Errorf("name must be 1-128 chars")
+			return fieldErrorf("name", "name must be 1-128 chars")
 		}
 	}
 	if v, ok := payload["base_url"]; ok {
 		s := strings.TrimSpace(stringFromAny(v, ""))
 		if len(s) < 3 || len(s) > 512 {
-			return fmt.Errorf("base_url must be 3-512 chars")
+			return fieldErrorf("base_url", "base_url must be 3-512 chars")
+		}
+		if err := validateHTTPURL("base_url", s); err != nil {
+			return err
 		}
 	}
 	if v, ok := payload["api_key"]; ok {
 		s := stringFromAny(v, "")
 		if len(s) < 1 || len(s) > 2048 {
-			return fmt.Errorf("api_key must be 1-2048 chars")
+			return fieldErrorf("api_key", "api_key must be 1-2048 chars")
+		}
+	}
+	if v, ok := payload["api_keys"]; ok && v != nil {
+		items, ok := v.([]any)
+		if !ok {
+			return fieldErrorf("api_keys", "api_keys must be an array of strings")
+		}
+		if len(items) > maxPooledAPIKeys {
+			return fieldErrorf("api_keys", "api_keys must contain <= %d items", maxPooledAPIKeys)
+		}
+		for _, item := range items {
+			s, ok := item.(string)
+			if !ok || len(s) < 1 || len(s) > 2048 {
+				return fieldErrorf("api_keys", "each api_keys item must be a string of 1-2048 chars")
+			}
 		}
 	}
 	if v, ok := payload["interval_min"]; ok {
 		n, ok := anyInt(v)
 		if !ok || n < 1 || n > 1440 {
-			return fmt.Errorf("interval_min must be an integer between 1 and 1440")
+			return fieldErrorf("interval_min", "interval_min must be an integer between 1 and 1440")
+		}
+	}
+	if v, ok := payload["fast_retry_min"]; ok {
+		n, ok := anyInt(v)
+		if !ok || n < 0 || n > 1440 {
+			return fieldErrorf("fast_retry_min", "fast_retry_min must be an integer between 0 and 1440")
+		}
+	}
+	if v, ok := payload["detect_concurrency"]; ok {
+		n, ok := anyInt(v)
+		if !ok || n < 0 || n > maxDetectConcurrency {
+			return fieldErrorf("detect_concurrency", "detect_concurrency must be an integer between 0 and %d", maxDetectConcurrency)
+		}
+	}
+	if v, ok := payload["max_run_duration_s"]; ok {
+		n, ok := anyInt(v)
+		if !ok || n < 0 || n > maxRunDurationS {
+			return fieldErrorf("max_run_duration_s", "max_run_duration_s must be an integer between 0 and %d", maxRunDurationS)
+		}
+	}
+	if v, ok := payload["log_max_age_days"]; ok {
+		n, ok := anyInt(v)
+		if !ok || n < 0 || n > maxLogAgeDays {
+			return fieldErrorf("log_max_age_days", "log_max_age_days must be an integer between 0 and %d", maxLogAgeDays)
+		}
+	}
+	if v, ok := payload["balance_probe"]; ok {
+		s, ok := v.(string)
+		if !ok || !validBalanceProbe(strings.TrimSpace(s)) {
+			return fieldErrorf("balance_probe", "balance_probe must be one of: %s", joinStrings(balanceProbeKinds, ", "))
+		}
+	}
+	if v, ok := payload["tags"]; ok && v != nil {
+		items, ok := v.([]any)
+		if !ok {
+			return fieldErrorf("tags", "tags must be an array of strings")
+		}
+		if len(items) > maxTargetTags {
+			return fieldErrorf("tags", "tags must contain <= %d items", maxTargetTags)
+		}
+		for _, item := range items {
+			s, ok := item.(string)
+			if !ok || len(strings.TrimSpace(s)) < 1 || len(s) > 64 {
+				return fieldErrorf("tags", "each tags item must be a string of 1-64 chars")
+			}
+		}
+	}
+	if v, ok := payload["severity"]; ok {
+		s, ok := v.(string)
+		if !ok || severityRank[strings.ToLower(strings.TrimSpace(s))] == 0 {
+			return fieldErrorf("severity", "severity must be one of: %s", joinStrings(notificationSeverities, ", "))
+		}
+	}
+	if v, ok := payload["balance_alert_below"]; ok {
+		f, ok := anyFloat(v)
+		if !ok || f < 0 {
+			return fieldErrorf("balance_alert_below", "balance_alert_below must be a non-negative number")
 		}
 	}
 	if v, ok := payload["timeout_s"]; ok {
 		f, ok := anyFloat(v)
 		if !ok || f < 3.0 || f > 300.0 {
-			return fmt.Errorf("timeout_s must be between 3.0 and 300.0")
+			return fieldErrorf("timeout_s", "timeout_s must be between 3.0 and 300.0")
 		}
 	}
 	if v, ok := payload["max_models"]; ok {
 		n, ok := anyInt(v)
 		if !ok || n < 0 || n > 5000 {
-			return fmt.Errorf("max_models must be an integer between 0 and 5000")
+			return fieldErrorf("max_models", "max_models must be an integer between 0 and 5000")
 		}
 	}
 	if v, ok := payload["sort_order"]; ok {
 		n, ok := anyInt(v)
 		if !ok || n < 1 || n > 1000000 {
-			return fmt.Errorf("sort_order must be an integer between 1 and 1000000")
+			return fieldErrorf("sort_order", "sort_order must be an integer between 1 and 1000000")
 		}
 	}
 	if v, ok := payload["prompt"]; ok {
 		s := strings.TrimSpace(stringFromAny(v, ""))
 		if s == "" || len(s) > 4000 {
-			return fmt.Errorf("prompt must be 1-4000 chars")
+			return fieldErrorf("prompt", "prompt must be 1-4000 chars")
 		}
 	}
 	if v, ok := payload["anthropic_version"]; ok {
 		s := strings.TrimSpace(stringFromAny(v, ""))
 		if len(s) < 4 || len(s) > 64 {
-			return fmt.Errorf("anthropic_version must be 4-64 chars")
+			return fieldErrorf("anthropic_version", "anthropic_version must be 4-64 chars")
 		}
 	}
 	if v, ok := payload["source_url"]; ok && v != nil {
 		s, ok := v.(string)
 		if !ok {
-			return fmt.Errorf("source_url must be a string or null")
+			return fieldErrorf("source_url", "source_url must be a string or null")
 		}
 		if len(strings.TrimSpace(s)) > 1024 {
-			return fmt.Errorf("source_url must be <= 1024 chars")
+			return fieldErrorf("source_url", "source_url must be <= 1024 chars")
+		}
+		if s = strings.TrimSpace(s); s != "" {
+			if err := validateHTTPURL("source_url", s); err != nil {
+				return err
+			}
 		}
 	}
 	if _, ok := payload["visitor_channel_actions_enabled"]; ok {
 		if _, ok := payload["visitor_channel_actions_enabled"].(bool); !ok {
-			return fmt.Errorf("visitor_channel_actions_enabled must be a boolean")
+			return fieldErrorf("visitor_channel_actions_enabled", "visitor_channel_actions_enabled must be a boolean")
 		}
 	}
 	if v, ok := payload["selected_models"]; ok {
 		switch arr := v.(type) {
 		case []any:
 			if len(arr) > 5000 {
-				return fmt.Errorf("selected_models must contain <= 5000 items")
+				return fieldErrorf("selected_models", "selected_models must contain <= 5000 items")
 			}
 			for _, item := range arr {
 				s, ok := item.(string)
 				if !ok {
-					return fmt.Errorf("selected_models must be an array of strings")
+					return fieldErrorf("selected_models", "selected_models must be an array of strings")
 				}
 				s = strings.TrimSpace(s)
 				if s == "" || len(s) > 256 {
-					return fmt.Errorf("each selected_models item must be 1-256 chars")
+					return fieldErrorf("selected_models", "each selected_models item must be 1-256 chars")
 				}
 			}
 		case []string:
 			if len(arr) > 5000 {
-				return fmt.Errorf("selected_models must contain <= 5000 items")
+				return fieldErrorf("selected_models", "selected_models must contain <= 5000 items")
 			}
 			for _, item := range arr {
 				s := strings.TrimSpace(item)
 				if s == "" || len(s) > 256 {
-					return fmt.Errorf("each selected_models item must be 1-256 chars")
+					return fieldErrorf("selected_models", "each selected_models item must be 1-256 chars")
 				}
 			}
 		default:
-			return fmt.Errorf("selected_models must be an array of strings")
+			return fieldErrorf("selected_models", "selected_models must be an array of strings")
+		}
+	}
+	if v, ok := payload["snoozed_until"]; ok && v != nil {
+		f, ok := anyFloat(v)
+		if !ok || f < 0 || math.IsInf(f, 0) || math.IsNaN(f) {
+			return fieldErrorf("snoozed_until", "snoozed_until must be a unix timestamp in seconds or null")
+		}
+	}
+	if v, ok := payload["template_id"]; ok && v != nil {
+		if n, ok := anyInt(v); !ok || n < 1 {
+			return fieldErrorf("template_id", "template_id must be a template id or null")
+		}
+	}
+	if v, ok := payload["stream_probe"]; ok {
+		if _, ok := v.(bool); !ok {
+			return fieldErrorf("stream_probe", "stream_probe must be a boolean")
+		}
+	}
+	if v, ok := payload["tool_probe"]; ok {
+		if _, ok := v.(bool); !ok {
+			return fieldErrorf("tool_probe", "tool_probe must be a boolean")
+		}
+	}
+	if v, ok := payload["debug_capture"]; ok {
+		if _, ok := v.(bool); !ok {
+			return fieldErrorf("debug_capture", "debug_capture must be a boolean")
+		}
+	}
+	if v, ok := payload["probe_endpoints"]; ok && v != nil {
+		items, ok := v.([]any)
+		if !ok {
+			return fieldErrorf("probe_endpoints", "probe_endpoints must be an array of strings")
+		}
+		for _, item := range items {
+			s, ok := item.(string)
+			if !ok || (s != "chat" && s != "responses") {
+				return fieldErrorf("probe_endpoints", "probe_endpoints items must be chat or responses")
+			}
+		}
+	}
+	if v, ok := payload["capability_probes"]; ok && v != nil {
+		items, ok := v.([]any)
+		if !ok {
+			return fieldErrorf("capability_probes", "capability_probes must be an array of strings")
+		}
+		for _, item := range items {
+			s, ok := item.(string)
+			if !ok || !capabilityProbeNames[s] {
+				return fieldErrorf("capability_probes", "capability_probes items must be one of: %s", strings.Join(capabilityProbeList(), ", "))
+			}
+		}
+	}
+	for _, key := range []string{"include_patterns", "exclude_patterns"} {
+		v, ok := payload[key]
+		if !ok || v == nil {
+			continue
+		}
+		if err := validateModelPatterns(key, v); err != nil {
+			return asFieldError(key, err)
+		}
+	}
+	if v, ok := payload["extra_headers"]; ok && v != nil {
+		if err := validateExtraHeaders(v); err != nil {
+			return asFieldError("extra_headers", err)
+		}
+	}
+	if v, ok := payload["proxy_url"]; ok && v != nil {
+		s, ok := v.(string)
+		if !ok {
+			return fieldErrorf("proxy_url", "proxy_url must be a string")
+		}
+		if len(s) > 1024 {
+			return fieldErrorf("proxy_url", "proxy_url must be <= 1024 chars")
+		}
+		if _, err := parseOutboundProxyURL(s); err != nil {
+			return asFieldError("proxy_url", err)
+		}
+	}
+	if v, ok := payload["model_overrides"]; ok && v != nil {
+		if err := validateModelOverrides(v); err != nil {
+			return asFieldError("model_overrides", err)
+		}
+	}
+	if v, ok := payload["proxy_transform"]; ok && v != nil {
+		if err := validateProxyTransform(v); err != nil {
+			return asFieldError("proxy_transform", err)
+		}
+	}
+	if v, ok := payload["tls_fingerprint"]; ok {
+		s, ok := v.(string)
+		if !ok || !validTLSFingerprint(strings.TrimSpace(s)) {
+			return fieldErrorf("tls_fingerprint", "tls_fingerprint must be one of chrome, firefox, safari, golang, randomized, none")
+		}
+	}
+	return nil
+}
+
+// validateModelPatterns checks that an include/exclude list is an array of compilable patterns.
+func validateModelPatterns(key string, v any) error {
+	var items []string
+	switch arr := v.(type) {
+	case []string:
+		items = arr
+	case []any:
+		for _, item := range arr {
+			s, ok := item.(string)
+			if !ok {
+				return fmt.Errorf("%s must be an array of strings", key)
+			}
+			items = append(items, s)
+		}
+	default:
+		return fmt.Errorf("%s must be an array of strings", key)
+	}
+	if len(items) > 200 {
+		return fmt.Errorf("%s must contain <= 200 items", key)
+	}
+	for _, item := range items {
+		s := strings.TrimSpace(item)
+		if s == "" || len(s) > 256 {
+			return fmt.Errorf("each %s item must be 1-256 chars", key)
+		}
+	}
+	if _, err := compileModelPatterns(items); err != nil {
+		return fmt.Errorf("%s: %v", key, err)
+	}
+	return nil
+}
+
+// validateModelOverrides checks that model_overrides maps model names to {timeout_s, max_tokens, context_tokens}.
+func validateModelOverrides(v any) error {
+	entries, ok := v.(map[string]any)
+	if !ok {
+		return fmt.Errorf("model_overrides must be an object of model -> {timeout_s, max_tokens, context_tokens}")
+	}
+	if len(entries) > 5000 {
+		return fmt.Errorf("model_overrides must contain <= 5000 models")
+	}
+	for model, raw := range entries {
+		model = strings.TrimSpace(model)
+		if model == "" || len(model) > 256 {
+			return fmt.Errorf("model_overrides keys must be 1-256 chars")
+		}
+		item, ok := raw.(map[string]any)
+		if !ok {
+			return fmt.Errorf("model_overrides[%s] must be an object", model)
+		}
+		for key, val := range item {
+			switch key {
+			case "timeout_s":
+				f, ok := anyFloat(val)
+				if !ok || f < 3.0 || f > 300.0 {
+					return fmt.Errorf("model_overrides[%s].timeout_s must be between 3.0 and 300.0", model)
+				}
+			case "max_tokens":
+				n, ok := anyInt(val)
+				if !ok || n < 1 || n > 131072 {
+					return fmt.Errorf("model_overrides[%s].max_tokens must be an integer between 1 and 131072", model)
+				}
+			case "context_tokens":
+				n, ok := anyInt(val)
+				if !ok || n < minLongContextTokens || n > maxLongContextTokens {
+					return fmt.Errorf("model_overrides[%s].context_tokens must be an integer between %d and %d", model, minLongContextTokens, maxLongContextTokens)
+				}
+			default:
+				return fmt.Errorf("model_overrides[%s] has unknown field %s", model, key)
+			}
 		}
 	}
 	return nil
 }
 
+// validateExtraHeaders checks that extra_headers is an object of header name -> string value.
+func validateExtraHeaders(v any) error {
+	var headers map[string]string
+	switch m := v.(type) {
+	case map[string]string:
+		headers = m
+	case map[string]any:
+		headers = make(map[string]string, len(m))
+		for k, item := range m {
+			s, ok := item.(string)
+			if !ok {
+				return fmt.Errorf("extra_headers values must be strings")
+			}
+			headers[k] = s
+		}
+	default:
+		return fmt.Errorf("extra_headers must be an object of header name to string value")
+	}
+	if len(headers) > 32 {
+		return fmt.Errorf("extra_headers must contain <= 32 entries")
+	}
+	for k, val := range headers {
+		name := strings.TrimSpace(k)
+		if name == "" || len(name) > 128 || !validHeaderName(name) {
+			return fmt.Errorf("invalid extra_headers name: %q", k)
+		}
+		if hopByHopHeader(name) || strings.EqualFold(name, "Host") || strings.EqualFold(name, "Content-Length") {
+			return fmt.Errorf("extra_headers cannot override %s", name)
+		}
+		if len(val) > 4096 || strings.ContainsAny(val, "\r\n") {
+			return fmt.Errorf("invalid extra_headers value for %s", name)
+		}
+	}
+	return nil
+}
+
+// validHeaderName reports whether name only contains RFC 7230 token characters.
+func validHeaderName(name string) bool {
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
 // ---------------------------------------------------------------------------
 // Handlers
 // ---------------------------------------------------------------------------
@@ -193,6 +495,22 @@ type Handlers struct {
 	monitor *MonitorService
 	bus     *SSEBus
 	admin   *AdminSessionManager
+	oidc    *OIDCClient
+	// sync is the GitOps target syncer; nil when TARGETS_SYNC_URL is not set.
+	sync *targetSyncer
+	// log receives handler logs; nil uses slog.Default().
+	log *slog.Logger
+	// dash caches the data behind ListTargets and Dashboard.
+	dash dashboardCache
+	// keys rotates the proxy among the healthy keys of pooled targets.
+	keys keyRotation
+	// proxyEvents streams proxy_request activity to admins; nil disables publishing.
+	proxyEvents *SSEBus
+}
+
+// logger returns the handler logger tagged with component.
+func (h *Handlers) logger(component string) *slog.Logger {
+	return componentLogger(h.log, component)
 }
 
 func (h *Handlers) canOperateChannels(r *http.Request, target *Target) bool {
@@ -215,7 +533,8 @@ func (h *Handlers) requireChannelOperationPermission(w http.ResponseWriter, r *h
 }
 
 // targetRuntimeFields enriches a Target with computed fields for the API response.
-func (h *Handlers) targetRuntimeFieldsWithData(t *Target, running bool, models []ModelStatus) map[string]any {
+// The api_key is masked according to the api_key_redaction setting for the caller of r.
+func (h *Handlers) targetRuntimeFieldsWithData(r *http.Request, t *Target, running bool, models []ModelStatus) map[string]any {
 	total := 0
 	if t.LastTotal != nil {
 		total = *t.LastTotal
@@ -255,19 +574,57 @@ func (h *Handlers) targetRuntimeFieldsWithData(t *Target, running bool, models [
 		"sort_order":                      t.SortOrder,
 		"visitor_channel_actions_enabled": t.VisitorChannelActionsEnabled,
 		"selected_models":                 t.SelectedModels,
+		"extra_headers":                   t.ExtraHeaders,
+		"proxy_url":                       t.ProxyURL,
+		"tls_fingerprint":                 t.TLSFingerprint,
+		"tls_cert_not_after":              t.TLSCertNotAfter,
+		"tls_cert_chain":                  t.TLSCertChain,
+		"tls_cert_checked_at":             t.TLSCertCheckedAt,
+		"model_overrides":                 t.ModelOverrides,
+		"include_patterns":                t.IncludePatterns,
+		"exclude_patterns":                t.ExcludePatterns,
+		"probe_endpoints":                 t.ProbeEndpoints,
+		"stream_probe":                    t.StreamProbe,
+		"tool_probe":                      t.ToolProbe,
+		"capability_probes":               t.CapabilityProbes,
+		"agent_id":                        t.AgentID,
+		"snoozed_until":                   t.SnoozedUntil,
+		"snoozed":                         t.Snoozed(float64(time.Now().UnixMilli()) / 1000.0),
+		"fast_retry_min":                  t.FastRetryMin,
+		"retry_interval_min":              t.RetryIntervalMin,
+		"detect_concurrency":              t.DetectConcurrency,
+		"max_run_duration_s":              t.MaxRunDurationS,
+		"debug_capture":                   t.DebugCapture,
+		"log_max_age_days":                t.LogMaxAgeDays,
+		"balance_probe":                   t.BalanceProbe,
+		"balance_alert_below":             t.BalanceAlertBelow,
+		"balance_remaining":               t.BalanceRemaining,
+		"balance_checked_at":              t.BalanceCheckedAt,
+		"balance_error":                   t.BalanceError,
+		"tags":                            t.Tags,
+		"severity":                        t.Severity,
+		"proxy_transform":                 t.ProxyTransform,
+		"template_id":                     t.TemplateID,
 		"last_success_rate":               successRate,
 		"running":                         running,
 		"latest_models":                   models,
+		"api_key_masked":                  false,
+	}
+	result["api_keys"] = t.APIKeys
+	if apiKeyRedactedFor(r) {
+		result["api_key"] = maskAPIKey(t.APIKey)
+		result["api_keys"] = maskAPIKeys(t.APIKeys)
+		result["api_key_masked"] = true
 	}
 	return result
 }
 
-func (h *Handlers) targetRuntimeFields(t *Target) map[string]any {
+func (h *Handlers) targetRuntimeFields(r *http.Request, t *Target) map[string]any {
 	running := h.monitor.IsTargetRunning(t.ID)
 	models, _ := h.db.GetLatestModelStatuses(t.ID)
 	historyByTarget, _ := h.db.GetModelHistoriesBatch([]int{t.ID}, modelHistoryPoints)
 	attachModelHistory(models, historyByTarget[t.ID])
-	return h.targetRuntimeFieldsWithData(t, running, models)
+	return h.targetRuntimeFieldsWithData(r, t, running, models)
 }
 
 func attachModelHistory(models []ModelStatus, historyByModel map[string][]ModelHistoryPoint) {
@@ -291,17 +648,30 @@ func (h *Handlers) Health(w http.ResponseWriter, r *http.Request) {
 
 // Dashboard -- GET /api/dashboard
 func (h *Handlers) Dashboard(w http.ResponseWriter, r *http.Request) {
-	targets, err := h.db.ListTargets()
+	snap, err := h.dashboardSnapshot()
 	if err != nil {
 		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
 		return
 	}
+	targets := filterTargetsForPrincipal(r, snap.targets)
 	total := len(targets)
-	enabled, healthy, degraded, down := 0, 0, 0, 0
+	runningSet := make(map[int]bool)
+	for _, id := range h.monitor.RunningTargetIDs() {
+		runningSet[id] = true
+	}
+	enabled, running, healthy, degraded, down, mismatches := 0, 0, 0, 0, 0, 0
 	for _, t := range targets {
+		for _, m := range snap.models[t.ID] {
+			if m.Mismatch {
+				mismatches++
+			}
+		}
 		if t.Enabled {
 			enabled++
 		}
+		if runningSet[t.ID] {
+			running++
+		}
 		if t.LastStatus != nil {
 			switch *t.LastStatus {
 			case "healthy":
@@ -314,37 +684,24 @@ func (h *Handlers) Dashboard(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 	writeJSON(w, http.StatusOK, map[string]any{
-		"total_targets":   total,
-		"enabled_targets": enabled,
-		"running_targets": len(h.monitor.RunningTargetIDs()),
-		"healthy":         healthy,
-		"degraded":        degraded,
-		"down_or_error":   down,
+		"total_targets":    total,
+		"enabled_targets":  enabled,
+		"running_targets":  running,
+		"healthy":          healthy,
+		"degraded":         degraded,
+		"down_or_error":    down,
+		"model_mismatches": mismatches,
 	})
 }
 
 // ListTargets -- GET /api/targets
 func (h *Handlers) ListTargets(w http.ResponseWriter, r *http.Request) {
-	targets, err := h.db.ListTargets()
-	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
-		return
-	}
-
-	targetIDs := make([]int, 0, len(targets))
-	for i := range targets {
-		targetIDs = append(targetIDs, targets[i].ID)
-	}
-	modelsByTarget, err := h.db.GetLatestModelStatusesBatch(targetIDs)
-	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
-		return
-	}
-	historyByTarget, err := h.db.GetModelHistoriesBatch(targetIDs, modelHistoryPoints)
+	snap, err := h.dashboardSnapshot()
 	if err != nil {
 		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
 		return
 	}
+	targets := filterTargetsForPrincipal(r, snap.targets)
 
 	runningSet := make(map[int]bool)
 	for _, id := range h.monitor.RunningTargetIDs() {
@@ -360,15 +717,14 @@ func (h *Handlers) ListTargets(w http.ResponseWriter, r *http.Request) {
 
 	for i := range targets {
 		t := &targets[i]
-		models := modelsByTarget[t.ID]
-		attachModelHistory(models, historyByTarget[t.ID])
-		item := h.targetRuntimeFieldsWithData(t, runningSet[t.ID], models)
+		item := h.targetRuntimeFieldsWithData(r, t, runningSet[t.ID], snap.models[t.ID])
 		item["can_operate"] = h.canOperateChannels(r, t)
 		items = append(items, item)
 	}
 
 	writeJSON(w, http.StatusOK, map[string]any{
-		"items": items,
+		"items":            items,
+		"scheduler_paused": h.monitor.SchedulerPaused(),
 		"permissions": map[string]any{
 			"role": roleStr,
 		},
@@ -391,7 +747,7 @@ func (h *Handlers) GetTarget(w http.ResponseWriter, r *http.Request) {
 		writeJSON(w, http.StatusNotFound, map[string]any{"detail": "target not found"})
 		return
 	}
-	writeJSON(w, http.StatusOK, map[string]any{"item": h.targetRuntimeFields(target)})
+	writeJSON(w, http.StatusOK, map[string]any{"item": h.targetRuntimeFields(r, target)})
 }
 
 // GetTargetModels -- GET /api/targets/{id}/models (admin Bearer token)
@@ -413,10 +769,17 @@ func (h *Handlers) PatchTargetModels(w http.ResponseWriter, r *http.Request) {
 }
 
 // CreateTarget -- POST /api/targets
+// With ?validate=1 the target is saved only if GET /v1/models succeeds with its
+// credentials, and the discovered models are returned alongside the item; with
+// ?dry_run=1 the check runs and nothing is saved (same as POST /api/targets/validate).
 func (h *Handlers) CreateTarget(w http.ResponseWriter, r *http.Request) {
 	var payload map[string]any
 	if err := readJSON(r, &payload); err != nil {
-		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "invalid JSON"})
+		writeRequestBodyError(w, err)
+		return
+	}
+	if err := h.applyTemplate(payload); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": err.Error()})
 		return
 	}
 	// Validate required fields
@@ -428,16 +791,38 @@ func (h *Handlers) CreateTarget(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if err := validateTargetPayload(payload); err != nil {
-		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": err.Error()})
+		writeValidationError(w, err)
 		return
 	}
+	if h.rejectDuplicateTarget(w, r, payload) {
+		return
+	}
+	dryRun := queryFlag(r, "dry_run")
+	var validation *targetValidation
+	if dryRun || queryFlag(r, "validate") {
+		result := h.validateTargetConnectivity(r.Context(), previewTarget(payload))
+		if !result.OK {
+			writeJSON(w, http.StatusUnprocessableEntity, result)
+			return
+		}
+		if dryRun {
+			writeJSON(w, http.StatusOK, result)
+			return
+		}
+		validation = &result
+	}
 
 	target, err := h.db.CreateTarget(payload)
 	if err != nil {
 		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": err.Error()})
 		return
 	}
-	writeJSON(w, http.StatusOK, map[string]any{"item": h.targetRuntimeFields(target)})
+	h.audit(r, "target.create", "target", target.ID, auditTargetDiff(nil, target))
+	if validation != nil {
+		writeJSON(w, http.StatusOK, map[string]any{"item": h.targetRuntimeFields(r, target), "validation": validation})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"item": h.targetRuntimeFields(r, target)})
 }
 
 // PatchTarget -- PATCH /api/targets/{id}
@@ -462,13 +847,31 @@ func (h *Handlers) PatchTarget(w http.ResponseWriter, r *http.Request) {
 
 	var updates map[string]any
 	if err := readJSON(r, &updates); err != nil {
-		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "invalid JSON"})
+		writeRequestBodyError(w, err)
 		return
 	}
+	// A masked key echoed back from an edit form means "unchanged".
+	if key, ok := updates["api_key"].(string); ok && key == maskAPIKey(existing.APIKey) {
+		delete(updates, "api_key")
+	}
+	if keys, ok := updates["api_keys"].([]any); ok {
+		updates["api_keys"] = unmaskAPIKeys(keys, existing.APIKeys)
+	}
 	if err := validateTargetPayload(updates); err != nil {
-		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": err.Error()})
+		writeValidationError(w, err)
 		return
 	}
+	if v, ok := updates["template_id"]; ok && v != nil {
+		tpl, err := h.db.FindTemplate(v)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+			return
+		}
+		if tpl == nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "template not found"})
+			return
+		}
+	}
 
 	updated, err := h.db.UpdateTarget(id, updates)
 	if err != nil {
@@ -479,7 +882,129 @@ func (h *Handlers) PatchTarget(w http.ResponseWriter, r *http.Request) {
 		writeJSON(w, http.StatusNotFound, map[string]any{"detail": "target not found"})
 		return
 	}
-	writeJSON(w, http.StatusOK, map[string]any{"item": h.targetRuntimeFields(updated)})
+	h.audit(r, "target.update", "target", id, auditTargetDiff(existing, updated))
+	writeJSON(w, http.StatusOK, map[string]any{"item": h.targetRuntimeFields(r, updated)})
+}
+
+// targetClonePayload copies a target's configuration (not its run state) into a CreateTarget payload.
+func targetClonePayload(t *Target) map[string]any {
+	payload := map[string]any{
+		"name":                            t.Name,
+		"base_url":                        t.BaseURL,
+		"api_key":                         t.APIKey,
+		"enabled":                         t.Enabled,
+		"interval_min":                    t.IntervalMin,
+		"timeout_s":                       t.TimeoutS,
+		"verify_ssl":                      t.VerifySSL,
+		"prompt":                          t.Prompt,
+		"anthropic_version":               t.AnthropicVersion,
+		"max_models":                      t.MaxModels,
+		"visitor_channel_actions_enabled": t.VisitorChannelActionsEnabled,
+		"selected_models":                 t.SelectedModels,
+		"extra_headers":                   t.ExtraHeaders,
+		"proxy_url":                       t.ProxyURL,
+		"tls_fingerprint":                 t.TLSFingerprint,
+		"model_overrides":                 t.ModelOverrides,
+		"include_patterns":                t.IncludePatterns,
+		"exclude_patterns":                t.ExcludePatterns,
+		"probe_endpoints":                 t.ProbeEndpoints,
+		"stream_probe":                    t.StreamProbe,
+		"tool_probe":                      t.ToolProbe,
+		"capability_probes":               t.CapabilityProbes,
+		"fast_retry_min":                  t.FastRetryMin,
+		"detect_concurrency":              t.DetectConcurrency,
+		"max_run_duration_s":              t.MaxRunDurationS,
+		"debug_capture":                   t.DebugCapture,
+		"log_max_age_days":                t.LogMaxAgeDays,
+		"balance_probe":                   t.BalanceProbe,
+		"balance_alert_below":             t.BalanceAlertBelow,
+		"api_keys":                        t.APIKeys,
+		"tags":                            t.Tags,
+		"severity":                        t.Severity,
+		"proxy_transform":                 t.ProxyTransform,
+	}
+	if t.SourceURL != nil {
+		payload["source_url"] = *t.SourceURL
+	}
+	if t.TemplateID != nil {
+		payload["template_id"] = *t.TemplateID
+	}
+	return payload
+}
+
+// CloneTarget -- POST /api/targets/{id}/clone
+// Body (optional): {"name": "...", "api_key": "...", "enabled": true}; name defaults to "<name> (copy)".
+func (h *Handlers) CloneTarget(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(r)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "invalid id"})
+		return
+	}
+	existing, err := h.db.GetTarget(id)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return
+	}
+	if existing == nil {
+		writeJSON(w, http.StatusNotFound, map[string]any{"detail": "target not found"})
+		return
+	}
+	if !h.requireChannelOperationPermission(w, r, existing) {
+		return
+	}
+
+	overrides := map[string]any{}
+	// An empty body clones as-is.
+	if err := readJSON(r, &overrides); err != nil && !errors.Is(err, io.EOF) {
+		writeRequestBodyError(w, err)
+		return
+	}
+	for key := range overrides {
+		switch key {
+		case "name", "api_key", "enabled":
+		default:
+			writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "only name, api_key and enabled can be overridden when cloning"})
+			return
+		}
+	}
+	if err := validateTargetPayload(overrides); err != nil {
+		writeValidationError(w, err)
+		return
+	}
+
+	payload := targetClonePayload(existing)
+	payload["name"] = existing.Name + " (copy)"
+	for key, val := range overrides {
+		if s, ok := val.(string); ok {
+			val = strings.TrimSpace(s)
+		}
+		payload[key] = val
+	}
+	if len(stringFromAny(payload["name"], "")) > 128 {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "name must be 1-128 chars"})
+		return
+	}
+
+	clone, err := h.db.CreateTarget(payload)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": err.Error()})
+		return
+	}
+	// Agent assignment is an admin-only setting, so visitors get a centrally scheduled copy.
+	if existing.AgentID != nil && authRoleFromRequest(r) == authRoleAdmin {
+		if err := h.db.AssignTargetAgent(clone.ID, existing.AgentID); err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+			return
+		}
+		if clone, err = h.db.GetTarget(clone.ID); err != nil || clone == nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": "reload cloned target failed"})
+			return
+		}
+	}
+	diff := auditTargetDiff(nil, clone)
+	diff["source_id"] = map[string]any{"from": nil, "to": existing.ID}
+	h.audit(r, "target.clone", "target", clone.ID, diff)
+	writeJSON(w, http.StatusOK, map[string]any{"item": h.targetRuntimeFields(r, clone)})
 }
 
 // DeleteTarget -- DELETE /api/targets/{id}
@@ -510,10 +1035,166 @@ func (h *Handlers) DeleteTarget(w http.ResponseWriter, r *http.Request) {
 		writeJSON(w, http.StatusNotFound, map[string]any{"detail": "target not found"})
 		return
 	}
+	h.audit(r, "target.delete", "target", id, auditTargetDiff(existing, nil))
 	writeJSON(w, http.StatusOK, map[string]any{"ok": true})
 }
 
+// bulkTargetsMaxIDs caps how many targets one bulk request may touch.
+const bulkTargetsMaxIDs = 500
+
+type bulkTargetsRequest struct {
+	IDs    []int  `json:"ids"`
+	Action string `json:"action"`
+	// IntervalMin is required for set_interval.
+	IntervalMin *int `json:"interval_min"`
+}
+
+type bulkTargetResult struct {
+	ID     int    `json:"id"`
+	OK     bool   `json:"ok"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// ReorderTargets -- PATCH /api/targets/reorder
+// Body: {"ids":[3,1,2]} in the desired dashboard order. The order spans every target,
+// so target-limited API tokens may not change it.
+func (h *Handlers) ReorderTargets(w http.ResponseWriter, r *http.Request) {
+	if authRoleFromRequest(r) != authRoleAdmin {
+		writeJSON(w, http.StatusForbidden, map[string]any{"detail": "admin token required"})
+		return
+	}
+	if principalTargetLimited(r) {
+		writeJSON(w, http.StatusForbidden, map[string]any{"detail": "api token is limited to specific targets"})
+		return
+	}
+	var req struct {
+		IDs []int `json:"ids"`
+	}
+	if err := readJSON(r, &req); err != nil {
+		writeRequestBodyError(w, err)
+		return
+	}
+	if len(req.IDs) == 0 || len(req.IDs) > 5000 {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "ids must contain 1-5000 items"})
+		return
+	}
+	if err := h.db.ReorderItems(req.IDs); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": err.Error()})
+		return
+	}
+	h.audit(r, "target.reorder", "target", 0, map[string]any{"ids": map[string]any{"from": nil, "to": req.IDs}})
+	h.monitor.emitJSON("targets_reordered", map[string]any{"ids": req.IDs})
+	writeJSON(w, http.StatusOK, map[string]any{"ok": true})
+}
+
+// BulkTargets -- POST /api/targets/bulk
+// Body: {"ids":[1,2], "action":"enable|disable|run|delete|set_interval", "interval_min":10}.
+// Database changes for all permitted ids are applied in one transaction; every id gets its own result.
+func (h *Handlers) BulkTargets(w http.ResponseWriter, r *http.Request) {
+	var req bulkTargetsRequest
+	if err := readJSON(r, &req); err != nil {
+		writeRequestBodyError(w, err)
+		return
+	}
+	switch req.Action {
+	case "enable", "disable", "run", "delete":
+	case "set_interval":
+		if req.IntervalMin == nil || *req.IntervalMin < 1 || *req.IntervalMin > 1440 {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "interval_min must be an integer between 1 and 1440"})
+			return
+		}
+	default:
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "action must be one of enable, disable, run, delete, set_interval"})
+		return
+	}
+	if len(req.IDs) == 0 || len(req.IDs) > bulkTargetsMaxIDs {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": fmt.Sprintf("ids must contain 1-%d items", bulkTargetsMaxIDs)})
+		return
+	}
+
+	results := make([]bulkTargetResult, 0, len(req.IDs))
+	index := make(map[int]int, len(req.IDs))
+	var permitted []int
+	for _, id := range req.IDs {
+		if _, dup := index[id]; dup {
+			continue
+		}
+		index[id] = len(results)
+		res := bulkTargetResult{ID: id}
+		target, err := h.db.GetTarget(id)
+		switch {
+		case err != nil:
+			res.Detail = err.Error()
+		case target == nil:
+			res.Detail = "target not found"
+		case !principalAllowsTarget(r, id):
+			res.Detail = "api token is not allowed to access this target"
+		case !h.canOperateChannels(r, target):
+			res.Detail = "channel operations are disabled for visitor token"
+		default:
+			permitted = append(permitted, id)
+		}
+		results = append(results, res)
+	}
+
+	var done []int
+	var err error
+	switch req.Action {
+	case "enable", "disable":
+		done, err = h.db.BulkSetTargetField(permitted, "enabled", req.Action == "enable")
+	case "set_interval":
+		done, err = h.db.BulkSetTargetField(permitted, "interval_min", *req.IntervalMin)
+	case "delete":
+		done, err = h.db.BulkDeleteTargets(permitted)
+	case "run":
+		for _, id := range permitted {
+			triggered, msg := h.monitor.TriggerTarget(id, true)
+			results[index[id]].OK = triggered
+			if !triggered {
+				results[index[id]].Detail = msg
+			} else {
+				done = append(done, id)
+			}
+		}
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return
+	}
+	if len(done) > 0 {
+		diff := map[string]any{"ids": map[string]any{"from": nil, "to": done}}
+		if req.Action == "set_interval" {
+			diff["interval_min"] = map[string]any{"from": nil, "to": *req.IntervalMin}
+		}
+		h.audit(r, "target.bulk_"+req.Action, "target", 0, diff)
+	}
+	if req.Action != "run" {
+		for _, id := range done {
+			results[index[id]].OK = true
+		}
+		for _, id := range permitted {
+			if !results[index[id]].OK {
+				results[index[id]].Detail = "target not found"
+			}
+		}
+		if len(done) > 0 {
+			h.monitor.emitJSON("target_updated", map[string]any{"ids": done, "action": req.Action})
+		}
+	}
+
+	allOK := true
+	for _, res := range results {
+		allOK = allOK && res.OK
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"ok": allOK, "action": req.Action, "items": results})
+}
+
 // RunTarget -- POST /api/targets/{id}/run
+// ?mode=failed_only re-probes just the models failing in the latest results as a
+// partial run; an optional body {"models":["gpt-4o",...]} probes exactly those models,
+// bypassing selected_models. The default full run probes every selected model.
+// When max_parallel_targets runs are in flight the run is queued (?priority=-10..10,
+// higher first) and the response carries its queue position.
 func (h *Handlers) RunTarget(w http.ResponseWriter, r *http.Request) {
 	id, ok := pathID(r)
 	if !ok {
@@ -532,18 +1213,161 @@ func (h *Handlers) RunTarget(w http.ResponseWriter, r *http.Request) {
 	if !h.requireChannelOperationPermission(w, r, existing) {
 		return
 	}
-	triggered, msg := h.monitor.TriggerTarget(id, true)
+	var body struct {
+		Models []string `json:"models"`
+	}
+	if err := readJSON(r, &body); err != nil && !errors.Is(err, io.EOF) {
+		writeRequestBodyError(w, err)
+		return
+	}
+	mode := r.URL.Query().Get("mode")
+	if body.Models != nil {
+		if mode != "" && mode != runModeSubset {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "models cannot be combined with mode=" + mode})
+			return
+		}
+		mode = runModeSubset
+	}
+	switch mode {
+	case "":
+		mode = runModeFull
+	case runModeFull, runModeFailedOnly:
+	case runModeSubset:
+		if body.Models == nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "mode=subset requires models"})
+			return
+		}
+	default:
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "mode must be full, failed_only or subset"})
+		return
+	}
+	priority := queryInt(r, "priority", 0, -10, 10)
+	triggered, msg := h.monitor.TriggerManual(id, runPlan{mode: mode, models: body.Models, priority: priority})
 	if !triggered {
 		switch msg {
 		case "target not found":
 			writeJSON(w, http.StatusNotFound, map[string]any{"detail": msg})
-		case "target already running":
+		case "target already running", "target already queued":
 			writeJSON(w, http.StatusConflict, map[string]any{"detail": msg})
 		default:
 			writeJSON(w, http.StatusBadRequest, map[string]any{"detail": msg})
 		}
 		return
 	}
+	var diff map[string]any
+	if mode != runModeFull {
+		diff = map[string]any{"mode": map[string]any{"from": nil, "to": mode}}
+	}
+	if mode == runModeSubset {
+		diff["models"] = map[string]any{"from": nil, "to": body.Models}
+	}
+	h.audit(r, "target.run", "target", id, diff)
+	resp := map[string]any{"ok": true, "message": msg, "mode": mode}
+	if pos := h.monitor.QueuePosition(id); pos > 0 {
+		resp["queued"] = true
+		resp["position"] = pos
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// TestTarget -- POST /api/targets/{id}/test
+// Body: {"model":"gpt-4o","prompt":"...","stream":true,"route":"responses"}; prompt,
+// stream and route are optional and default to the target's settings and the model's
+// primary route. Probes the one model immediately and returns the DetectionResult
+// without creating a run or storing anything.
+func (h *Handlers) TestTarget(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(r)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "invalid id"})
+		return
+	}
+	existing, err := h.db.GetTarget(id)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return
+	}
+	if existing == nil {
+		writeJSON(w, http.StatusNotFound, map[string]any{"detail": "target not found"})
+		return
+	}
+	if !h.requireChannelOperationPermission(w, r, existing) {
+		return
+	}
+	var req struct {
+		Model  string  `json:"model"`
+		Prompt *string `json:"prompt"`
+		Stream *bool   `json:"stream"`
+		Route  string  `json:"route"`
+	}
+	if err := readJSON(r, &req); err != nil {
+		writeRequestBodyError(w, err)
+		return
+	}
+	req.Model = strings.TrimSpace(req.Model)
+	if req.Model == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "model is required"})
+		return
+	}
+	target := *existing
+	if req.Prompt != nil {
+		if strings.TrimSpace(*req.Prompt) == "" {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "prompt must not be empty"})
+			return
+		}
+		target.Prompt = *req.Prompt
+	}
+	if req.Stream != nil {
+		target.StreamProbe = *req.Stream
+	}
+	routes := h.monitor.detectionRoutes(&target, req.Model)
+	route := routes[0]
+	if req.Route != "" {
+		if !slices.Contains(routes, req.Route) {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "route must be one of: " + strings.Join(routes, ", ")})
+			return
+		}
+		route = req.Route
+	}
+	client, err := targetHTTPClient(&target)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": err.Error()})
+		return
+	}
+	row := h.monitor.detectOne(r.Context(), &target, req.Model, route, client)
+	h.audit(r, "target.test", "target", id, map[string]any{"model": map[string]any{"from": nil, "to": req.Model}})
+	writeJSON(w, http.StatusOK, map[string]any{"item": row})
+}
+
+// CancelTarget -- POST /api/targets/{id}/cancel
+func (h *Handlers) CancelTarget(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(r)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "invalid id"})
+		return
+	}
+	existing, err := h.db.GetTarget(id)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return
+	}
+	if existing == nil {
+		writeJSON(w, http.StatusNotFound, map[string]any{"detail": "target not found"})
+		return
+	}
+	if !h.requireChannelOperationPermission(w, r, existing) {
+		return
+	}
+	cancelled, msg := h.monitor.CancelTarget(id)
+	if !cancelled {
+		switch msg {
+		case "target not found":
+			writeJSON(w, http.StatusNotFound, map[string]any{"detail": msg})
+		default:
+			writeJSON(w, http.StatusConflict, map[string]any{"detail": msg})
+		}
+		return
+	}
+	h.audit(r, "target.cancel", "target", id, nil)
 	writeJSON(w, http.StatusOK, map[string]any{"ok": true, "message": msg})
 }
 
@@ -569,9 +1393,19 @@ func (h *Handlers) ListRuns(w http.ResponseWriter, r *http.Request) {
 		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
 		return
 	}
+	if err := h.db.attachRunNotes(id, runs); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return
+	}
+	notes, err := h.db.ListNotes(id, nil, true)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return
+	}
 	writeJSON(w, http.StatusOK, map[string]any{
-		"target": h.targetRuntimeFields(target),
+		"target": h.targetRuntimeFields(r, target),
 		"items":  runs,
+		"notes":  notes,
 	})
 }
 
@@ -639,9 +1473,18 @@ func (h *Handlers) GetLogs(w http.ResponseWriter, r *http.Request) {
 		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
 		return
 	}
+	if queryFlag(r, "captures") {
+		if !h.requireChannelOperationPermission(w, r, target) {
+			return
+		}
+		if err := h.db.attachCaptures(id, chosenRunID, logs); err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+			return
+		}
+	}
 
 	writeJSON(w, http.StatusOK, map[string]any{
-		"target": h.targetRuntimeFields(target),
+		"target": h.targetRuntimeFields(r, target),
 		"run":    chosenRun,
 		"count":  len(logs),
 		"items":  logs,
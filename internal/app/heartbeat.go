@@ -0,0 +1,123 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Dead-man heartbeat. With HEARTBEAT_URL set (a healthchecks.io ping URL, an Uptime Kuma
+// push URL, ...), the URL is requested with GET after every successful scheduler scan,
+// which runs once a minute, so the monitoring service raises an alert when the process
+// dies or its scheduler stalls. A paused scheduler still pings: pausing is deliberate.
+// When a scan fails (the database cannot list due targets), HEARTBEAT_FAIL_URL is
+// pinged instead if set, e.g. the healthchecks.io <ping URL>/fail. Pings never block
+// the scheduler; a ping still in flight when the next scan ends is not doubled up.
+
+const heartbeatTimeout = 10 * time.Second
+
+type heartbeat struct {
+	url     string
+	failURL string
+	client  *http.Client
+	log     *slog.Logger
+	busy    atomic.Bool
+}
+
+var heartbeatInstance atomic.Pointer[heartbeat]
+
+func validHeartbeatURL(name, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%s must be an http(s) URL, got %q", name, raw)
+	}
+	return nil
+}
+
+// heartbeatFromEnv builds the heartbeat configured by HEARTBEAT_URL and
+// HEARTBEAT_FAIL_URL, or returns nil when HEARTBEAT_URL is unset.
+func heartbeatFromEnv() (*heartbeat, error) {
+	h := &heartbeat{
+		url:     strings.TrimSpace(os.Getenv("HEARTBEAT_URL")),
+		failURL: strings.TrimSpace(os.Getenv("HEARTBEAT_FAIL_URL")),
+		client:  &http.Client{Timeout: heartbeatTimeout},
+	}
+	if h.url == "" {
+		if h.failURL != "" {
+			return nil, fmt.Errorf("HEARTBEAT_FAIL_URL requires HEARTBEAT_URL")
+		}
+		return nil, nil
+	}
+	if err := validHeartbeatURL("HEARTBEAT_URL", h.url); err != nil {
+		return nil, err
+	}
+	if h.failURL != "" {
+		if err := validHeartbeatURL("HEARTBEAT_FAIL_URL", h.failURL); err != nil {
+			return nil, err
+		}
+	}
+	return h, nil
+}
+
+// startHeartbeat installs the heartbeat configured by the environment. It is a no-op
+// when HEARTBEAT_URL is unset.
+func startHeartbeat(logger *slog.Logger) error {
+	h, err := heartbeatFromEnv()
+	if err != nil || h == nil {
+		return err
+	}
+	h.log = componentLogger(logger, "heartbeat")
+	heartbeatInstance.Store(h)
+	h.log.Info("heartbeat enabled", "fail_url", h.failURL != "")
+	return nil
+}
+
+// sendHeartbeat pings the heartbeat URL for a successful scheduler scan, or the fail
+// URL for a failed one, in the background.
+func sendHeartbeat(ok bool) {
+	h := heartbeatInstance.Load()
+	if h == nil {
+		return
+	}
+	target := h.url
+	if !ok {
+		target = h.failURL
+	}
+	if target == "" || !h.busy.CompareAndSwap(false, true) {
+		return
+	}
+	go func() {
+		defer h.busy.Store(false)
+		if err := h.ping(target); err != nil {
+			// The URL is the check's credential; keep it out of the log.
+			h.log.Warn("heartbeat ping failed", "success", ok, "error", strings.ReplaceAll(err.Error(), target, "****"))
+		}
+	}()
+}
+
+func (h *heartbeat) ping(target string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), heartbeatTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", "api_monitor-heartbeat")
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("heartbeat returned HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
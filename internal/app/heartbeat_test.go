@@ -0,0 +1,61 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestScanDueTargetsSendsHeartbeat(t *testing.T) {
+	pings := make(chan string, 4)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pings <- r.URL.Path
+	}))
+	defer srv.Close()
+	t.Setenv("HEARTBEAT_URL", srv.URL+"/ping/abc")
+	t.Setenv("HEARTBEAT_FAIL_URL", srv.URL+"/ping/abc/fail")
+	if err := startHeartbeat(nil); err != nil {
+		t.Fatalf("startHeartbeat failed: %v", err)
+	}
+	t.Cleanup(func() { heartbeatInstance.Store(nil) })
+
+	db, err := NewDatabase(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDatabase failed: %v", err)
+	}
+	ms := NewMonitorService(MonitorConfig{DB: db, LogDir: t.TempDir()})
+	wait := func() string {
+		select {
+		case path := <-pings:
+			return path
+		case <-time.After(5 * time.Second):
+			t.Fatal("expected a heartbeat ping")
+			return ""
+		}
+	}
+	ms.ScanDueTargets()
+	if path := wait(); path != "/ping/abc" {
+		t.Fatalf("successful scan should ping HEARTBEAT_URL, got %q", path)
+	}
+
+	for heartbeatInstance.Load().busy.Load() {
+		time.Sleep(10 * time.Millisecond)
+	}
+	_ = db.Close()
+	ms.ScanDueTargets()
+	if path := wait(); path != "/ping/abc/fail" {
+		t.Fatalf("failed scan should ping HEARTBEAT_FAIL_URL, got %q", path)
+	}
+}
+
+func TestHeartbeatValidation(t *testing.T) {
+	for _, env := range [][2]string{{"ftp://hc/ping", ""}, {"", "https://hc/fail"}, {"https://hc/ping", "hc/fail"}} {
+		t.Setenv("HEARTBEAT_URL", env[0])
+		t.Setenv("HEARTBEAT_FAIL_URL", env[1])
+		if _, err := heartbeatFromEnv(); err == nil {
+			t.Fatalf("HEARTBEAT_URL=%q HEARTBEAT_FAIL_URL=%q should be rejected", env[0], env[1])
+		}
+	}
+}
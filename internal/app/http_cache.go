@@ -0,0 +1,124 @@
+package app
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Response compression and conditional GETs for the heavy read endpoints (target list,
+// logs, analytics). The dashboard refetches the target list on every SSE event, so an
+// unchanged payload is answered with 304 and a changed one is sent gzip-compressed.
+// Only routes that answer with a single buffered body may be wrapped; SSE, WebSocket
+// and proxy streaming routes are left alone.
+
+// gzipMinBytes is the smallest body worth compressing.
+const gzipMinBytes = 1024
+
+// bufferedResponse holds a handler's response until it is complete.
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (b *bufferedResponse) Header() http.Header { return b.header }
+
+func (b *bufferedResponse) Write(p []byte) (int, error) {
+	if b.status == 0 {
+		b.status = http.StatusOK
+	}
+	return b.body.Write(p)
+}
+
+func (b *bufferedResponse) WriteHeader(status int) {
+	if b.status == 0 {
+		b.status = status
+	}
+}
+
+// compressMiddleware buffers the response of next, tags a 200 response with a strong
+// ETag over the uncompressed body, answers a matching If-None-Match with 304 and gzips
+// the body when the client accepts it.
+func compressMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+		buf := &bufferedResponse{header: w.Header()}
+		next.ServeHTTP(buf, r)
+		if buf.status == 0 {
+			buf.status = http.StatusOK
+		}
+		header := w.Header()
+		header.Add("Vary", "Accept-Encoding")
+		if buf.status != http.StatusOK {
+			w.WriteHeader(buf.status)
+			_, _ = w.Write(buf.body.Bytes())
+			return
+		}
+
+		sum := sha256.Sum256(buf.body.Bytes())
+		etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+		header.Set("ETag", etag)
+		// Let browsers keep the body but revalidate it on every request.
+		header.Set("Cache-Control", "private, no-cache")
+		if etagMatches(r.Header.Get("If-None-Match"), etag) {
+			header.Del("Content-Type")
+			header.Del("Content-Length")
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		body := buf.body.Bytes()
+		if len(body) >= gzipMinBytes && acceptsGzip(r.Header.Get("Accept-Encoding")) {
+			var zbuf bytes.Buffer
+			zw, _ := gzip.NewWriterLevel(&zbuf, gzip.BestSpeed)
+			if _, err := zw.Write(body); err == nil && zw.Close() == nil {
+				body = zbuf.Bytes()
+				header.Set("Content-Encoding", "gzip")
+				// A compressed representation needs its own validator.
+				header.Set("ETag", strings.TrimSuffix(etag, `"`)+`-gzip"`)
+			}
+		}
+		header.Set("Content-Length", strconv.Itoa(len(body)))
+		w.WriteHeader(http.StatusOK)
+		if r.Method != http.MethodHead {
+			_, _ = w.Write(body)
+		}
+	})
+}
+
+// etagMatches reports whether an If-None-Match header matches etag. The "-gzip" form
+// sent back by clients that received the compressed body matches too.
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	gz := strings.TrimSuffix(etag, `"`) + `-gzip"`
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag || candidate == gz {
+			return true
+		}
+	}
+	return false
+}
+
+// acceptsGzip reports whether an Accept-Encoding header allows gzip.
+func acceptsGzip(acceptEncoding string) bool {
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(strings.TrimSpace(coding), "gzip") {
+			continue
+		}
+		q := strings.ReplaceAll(strings.TrimSpace(params), " ", "")
+		return q != "q=0" && q != "q=0.0" && q != "q=0.00" && q != "q=0.000"
+	}
+	return false
+}
@@ -0,0 +1,86 @@
+package app
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCompressMiddleware_GzipAndETag(t *testing.T) {
+	payload := map[string]any{"items": strings.Repeat("model-", 1000)}
+	handler := compressMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("missing") != "" {
+			writeJSON(w, http.StatusNotFound, map[string]any{"detail": "target not found"})
+			return
+		}
+		writeJSON(w, http.StatusOK, payload)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/targets", nil)
+	req.Header.Set("Accept-Encoding", "gzip, deflate")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("large response should be gzipped, got status=%d encoding=%q", rr.Code, rr.Header().Get("Content-Encoding"))
+	}
+	zr, err := gzip.NewReader(rr.Body)
+	if err != nil {
+		t.Fatalf("gzip reader failed: %v", err)
+	}
+	raw, _ := io.ReadAll(zr)
+	if !strings.Contains(string(raw), `"items":"model-model-`) {
+		t.Fatalf("decompressed body should be the JSON payload, got=%.60s", raw)
+	}
+	etag := rr.Header().Get("ETag")
+	if etag == "" || rr.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("response should carry an ETag and keep its content type, got etag=%q type=%q", etag, rr.Header().Get("Content-Type"))
+	}
+
+	// Revalidation with the compressed and the plain validator both hit.
+	plain := httptest.NewRecorder()
+	handler.ServeHTTP(plain, httptest.NewRequest(http.MethodGet, "/api/targets", nil))
+	if plain.Header().Get("Content-Encoding") != "" || !strings.HasPrefix(plain.Body.String(), `{"items"`) {
+		t.Fatalf("client without gzip should get the plain body")
+	}
+	for _, tag := range []string{etag, plain.Header().Get("ETag"), `W/` + etag} {
+		req := httptest.NewRequest(http.MethodGet, "/api/targets", nil)
+		req.Header.Set("If-None-Match", tag)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != http.StatusNotModified || rr.Body.Len() != 0 {
+			t.Fatalf("matching If-None-Match %s should give 304, got status=%d", tag, rr.Code)
+		}
+	}
+
+	payload["items"] = "changed"
+	req = httptest.NewRequest(http.MethodGet, "/api/targets", nil)
+	req.Header.Set("If-None-Match", etag)
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Encoding") != "" || rr.Header().Get("ETag") == etag {
+		t.Fatalf("changed small body should be sent plain with a new ETag, got status=%d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/targets?missing=1", nil))
+	if rr.Code != http.StatusNotFound || rr.Header().Get("ETag") != "" {
+		t.Fatalf("error responses should pass through untagged, got status=%d", rr.Code)
+	}
+}
+
+func TestAcceptsGzip(t *testing.T) {
+	for raw, want := range map[string]bool{
+		"":                   false,
+		"gzip":               true,
+		"deflate, GZIP;q=.5": true,
+		"gzip;q=0":           false,
+		"br, identity":       false,
+	} {
+		if got := acceptsGzip(raw); got != want {
+			t.Fatalf("acceptsGzip(%q) should be %v, got=%v", raw, want, got)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package app
+
+import (
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// httpTiming is the phase breakdown of one outbound request in milliseconds.
+// A nil phase did not happen, e.g. DNS and connect are skipped on a reused connection.
+type httpTiming struct {
+	DNSMs     *float64 `json:"dns_ms"`
+	ConnectMs *float64 `json:"connect_ms"`
+	TLSMs     *float64 `json:"tls_ms"`
+	// TTFBMs runs from the connection being ready to the first response byte.
+	TTFBMs *float64 `json:"ttfb_ms"`
+	BodyMs *float64 `json:"body_ms"`
+}
+
+// requestTimer collects httptrace events for a single request.
+// utlsTransport reports its own TLS handshake through the same hooks.
+type requestTimer struct {
+	mu           sync.Mutex
+	start        time.Time
+	dnsStart     time.Time
+	connectStart time.Time
+	tlsStart     time.Time
+	connReady    time.Time
+	timing       httpTiming
+}
+
+func newRequestTimer() *requestTimer {
+	return &requestTimer{start: time.Now()}
+}
+
+func msSince(t time.Time) *float64 {
+	ms := float64(time.Since(t).Microseconds()) / 1000.0
+	return &ms
+}
+
+func (rt *requestTimer) trace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			rt.mu.Lock()
+			rt.dnsStart = time.Now()
+			rt.mu.Unlock()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			rt.mu.Lock()
+			if !rt.dnsStart.IsZero() {
+				rt.timing.DNSMs = msSince(rt.dnsStart)
+			}
+			rt.mu.Unlock()
+		},
+		ConnectStart: func(string, string) {
+			rt.mu.Lock()
+			// Parallel dials (happy eyeballs) keep the earliest start.
+			if rt.connectStart.IsZero() {
+				rt.connectStart = time.Now()
+			}
+			rt.mu.Unlock()
+		},
+		ConnectDone: func(_, _ string, err error) {
+			rt.mu.Lock()
+			if err == nil && !rt.connectStart.IsZero() && rt.timing.ConnectMs == nil {
+				rt.timing.ConnectMs = msSince(rt.connectStart)
+				rt.connReady = time.Now()
+			}
+			rt.mu.Unlock()
+		},
+		TLSHandshakeStart: func() {
+			rt.mu.Lock()
+			rt.tlsStart = time.Now()
+			rt.mu.Unlock()
+		},
+		TLSHandshakeDone: func(_ tls.ConnectionState, err error) {
+			rt.mu.Lock()
+			if err == nil && !rt.tlsStart.IsZero() {
+				rt.timing.TLSMs = msSince(rt.tlsStart)
+				rt.connReady = time.Now()
+			}
+			rt.mu.Unlock()
+		},
+		GotConn: func(httptrace.GotConnInfo) {
+			rt.mu.Lock()
+			rt.connReady = time.Now()
+			rt.mu.Unlock()
+		},
+		GotFirstResponseByte: func() {
+			rt.mu.Lock()
+			from := rt.connReady
+			if from.IsZero() {
+				from = rt.start
+			}
+			rt.timing.TTFBMs = msSince(from)
+			rt.mu.Unlock()
+		},
+	}
+}
+
+func (rt *requestTimer) bodyRead(d time.Duration) {
+	ms := float64(d.Microseconds()) / 1000.0
+	rt.mu.Lock()
+	rt.timing.BodyMs = &ms
+	rt.mu.Unlock()
+}
+
+func (rt *requestTimer) result() httpTiming {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	return rt.timing
+}
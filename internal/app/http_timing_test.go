@@ -0,0 +1,30 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHTTPJSON_RecordsTimingBreakdown(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	}))
+	defer srv.Close()
+
+	for _, fp := range []string{tlsFingerprintNone, tlsFingerprintGolang} {
+		res, err := httpJSON(context.Background(), httpClient(5, false, nil, fp), "GET", srv.URL, nil, nil)
+		if err != nil {
+			t.Fatalf("fingerprint %s: request failed: %v", fp, err)
+		}
+		tm := res.Timing
+		if tm.ConnectMs == nil || tm.TLSMs == nil || tm.TTFBMs == nil || tm.BodyMs == nil {
+			t.Fatalf("fingerprint %s: connect/tls/ttfb/body should be recorded, got=%+v", fp, tm)
+		}
+		if tm.DNSMs != nil {
+			t.Fatalf("fingerprint %s: literal IP should skip DNS, got=%v", fp, *tm.DNSMs)
+		}
+	}
+}
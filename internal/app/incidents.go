@@ -0,0 +1,438 @@
+package app
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// incidentMaxRunIDs bounds the run ids kept on one incident; the oldest are dropped
+// first, start_run_id is always kept.
+const incidentMaxRunIDs = 1000
+
+// Incident is an outage of one target: opened by the first down/error run and
+// closed by the next healthy or degraded run.
+type Incident struct {
+	ID             int            `json:"id"`
+	TargetID       int            `json:"target_id"`
+	TargetName     string         `json:"target_name"`
+	Status         string         `json:"status"`
+	StartedAt      float64        `json:"started_at"`
+	EndedAt        *float64       `json:"ended_at"`
+	StartRunID     int            `json:"start_run_id"`
+	EndRunID       *int           `json:"end_run_id"`
+	RunIDs         []int          `json:"run_ids"`
+	AffectedModels []string       `json:"affected_models"`
+	LastStatus     string         `json:"last_status"`
+	LastError      *string        `json:"last_error"`
+	AcknowledgedAt *float64       `json:"acknowledged_at"`
+	AcknowledgedBy *string        `json:"acknowledged_by"`
+	Notes          []IncidentNote `json:"notes"`
+}
+
+// IncidentNote is a free-form comment attached to an incident.
+type IncidentNote struct {
+	At   float64 `json:"at"`
+	Role string  `json:"role"`
+	Text string  `json:"text"`
+}
+
+type incidentNoteRequest struct {
+	Text string `json:"text"`
+}
+
+// EnsureIncidentSchema creates the incidents table.
+func (d *Database) EnsureIncidentSchema() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	_, err := d.conn.Exec(`
+		CREATE TABLE IF NOT EXISTS incidents (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			target_id INTEGER NOT NULL,
+			target_name TEXT NOT NULL DEFAULT '',
+			status TEXT NOT NULL DEFAULT 'open',
+			started_at REAL NOT NULL,
+			ended_at REAL,
+			start_run_id INTEGER NOT NULL,
+			end_run_id INTEGER,
+			run_ids TEXT NOT NULL DEFAULT '[]',
+			affected_models TEXT NOT NULL DEFAULT '[]',
+			last_status TEXT NOT NULL DEFAULT '',
+			last_error TEXT,
+			acknowledged_at REAL,
+			acknowledged_by TEXT,
+			notes TEXT NOT NULL DEFAULT '[]'
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_incidents_target_status
+		ON incidents(target_id, status);
+
+		CREATE INDEX IF NOT EXISTS idx_incidents_started
+		ON incidents(started_at DESC);
+	`)
+	if err != nil {
+		return fmt.Errorf("init incident schema: %w", err)
+	}
+	return nil
+}
+
+const incidentColumns = `id, target_id, target_name, status, started_at, ended_at, start_run_id, end_run_id,
+	run_ids, affected_models, last_status, last_error, acknowledged_at, acknowledged_by, notes`
+
+func scanIncident(r interface{ Scan(dest ...any) error }) (*Incident, error) {
+	var inc Incident
+	var runIDsRaw, modelsRaw, notesRaw string
+	if err := r.Scan(
+		&inc.ID, &inc.TargetID, &inc.TargetName, &inc.Status, &inc.StartedAt, &inc.EndedAt,
+		&inc.StartRunID, &inc.EndRunID, &runIDsRaw, &modelsRaw, &inc.LastStatus, &inc.LastError,
+		&inc.AcknowledgedAt, &inc.AcknowledgedBy, &notesRaw,
+	); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(runIDsRaw), &inc.RunIDs); err != nil || inc.RunIDs == nil {
+		inc.RunIDs = []int{}
+	}
+	if err := json.Unmarshal([]byte(modelsRaw), &inc.AffectedModels); err != nil || inc.AffectedModels == nil {
+		inc.AffectedModels = []string{}
+	}
+	if err := json.Unmarshal([]byte(notesRaw), &inc.Notes); err != nil || inc.Notes == nil {
+		inc.Notes = []IncidentNote{}
+	}
+	return &inc, nil
+}
+
+// GetIncident returns an incident by id, or nil when it does not exist.
+func (d *Database) GetIncident(id int) (*Incident, error) {
+	row := d.read.QueryRow("SELECT "+incidentColumns+" FROM incidents WHERE id = ?", id)
+	inc, err := scanIncident(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return inc, err
+}
+
+// GetOpenIncident returns the open incident of a target, or nil.
+func (d *Database) GetOpenIncident(targetID int) (*Incident, error) {
+	row := d.read.QueryRow(
+		"SELECT "+incidentColumns+" FROM incidents WHERE target_id = ? AND status = 'open' ORDER BY id DESC LIMIT 1",
+		targetID,
+	)
+	inc, err := scanIncident(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return inc, err
+}
+
+// ListIncidents returns incidents newest first. An empty status matches open and closed.
+func (d *Database) ListIncidents(status string, targetID *int, limit int) ([]Incident, error) {
+	query := "SELECT " + incidentColumns + " FROM incidents WHERE 1 = 1"
+	var args []any
+	if status != "" {
+		query += " AND status = ?"
+		args = append(args, status)
+	}
+	if targetID != nil {
+		query += " AND target_id = ?"
+		args = append(args, *targetID)
+	}
+	query += " ORDER BY started_at DESC, id DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := d.read.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []Incident{}
+	for rows.Next() {
+		inc, err := scanIncident(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, *inc)
+	}
+	return items, rows.Err()
+}
+
+// OpenIncident records a new open incident for a target.
+func (d *Database) OpenIncident(target *Target, runID int, startedAt float64, status string, lastError *string, models []string) (*Incident, error) {
+	runIDsJSON, _ := json.Marshal([]int{runID})
+	modelsJSON, _ := json.Marshal(normalizeStringSlice(models))
+	d.mu.Lock()
+	res, err := d.conn.Exec(`
+		INSERT INTO incidents (target_id, target_name, status, started_at, start_run_id, run_ids, affected_models, last_status, last_error)
+		VALUES (?, ?, 'open', ?, ?, ?, ?, ?, ?)`,
+		target.ID, target.Name, startedAt, runID, string(runIDsJSON), string(modelsJSON), status, lastError,
+	)
+	d.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	id, _ := res.LastInsertId()
+	return d.GetIncident(int(id))
+}
+
+// UpdateOpenIncident appends a further failing run to an open incident.
+func (d *Database) UpdateOpenIncident(inc *Incident, runID int, status string, lastError *string, models []string) error {
+	runIDs := append(inc.RunIDs, runID)
+	if len(runIDs) > incidentMaxRunIDs {
+		runIDs = runIDs[len(runIDs)-incidentMaxRunIDs:]
+	}
+	runIDsJSON, _ := json.Marshal(runIDs)
+	modelsJSON, _ := json.Marshal(normalizeStringSlice(append(inc.AffectedModels, models...)))
+	d.mu.Lock()
+	_, err := d.conn.Exec(`
+		UPDATE incidents SET run_ids = ?, affected_models = ?, last_status = ?, last_error = ?
+		WHERE id = ? AND status = 'open'`,
+		string(runIDsJSON), string(modelsJSON), status, lastError, inc.ID,
+	)
+	d.mu.Unlock()
+	return err
+}
+
+// CloseIncident marks an open incident resolved by runID.
+func (d *Database) CloseIncident(id, runID int, endedAt float64) error {
+	d.mu.Lock()
+	_, err := d.conn.Exec(
+		"UPDATE incidents SET status = 'closed', ended_at = ?, end_run_id = ? WHERE id = ? AND status = 'open'",
+		endedAt, runID, id,
+	)
+	d.mu.Unlock()
+	return err
+}
+
+// AcknowledgeIncident records who acknowledged an incident; repeated calls keep the first acknowledgement.
+func (d *Database) AcknowledgeIncident(id int, role string, at float64) (*Incident, error) {
+	d.mu.Lock()
+	_, err := d.conn.Exec(
+		"UPDATE incidents SET acknowledged_at = ?, acknowledged_by = ? WHERE id = ? AND acknowledged_at IS NULL",
+		at, role, id,
+	)
+	d.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return d.GetIncident(id)
+}
+
+// AddIncidentNote appends a note to an incident.
+func (d *Database) AddIncidentNote(id int, note IncidentNote) (*Incident, error) {
+	d.mu.Lock()
+	var notesRaw string
+	if err := d.read.QueryRow("SELECT notes FROM incidents WHERE id = ?", id).Scan(&notesRaw); err != nil {
+		d.mu.Unlock()
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var notes []IncidentNote
+	_ = json.Unmarshal([]byte(notesRaw), &notes)
+	notesJSON, _ := json.Marshal(append(notes, note))
+	_, err := d.conn.Exec("UPDATE incidents SET notes = ? WHERE id = ?", string(notesJSON), id)
+	d.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return d.GetIncident(id)
+}
+
+// failedModels returns the models with at least one failed row and the first failure's error.
+func failedModels(rows []DetectionResult) ([]string, *string) {
+	var models []string
+	var firstErr *string
+	for _, r := range rows {
+		if !r.Success {
+			models = append(models, r.Model)
+			if firstErr == nil {
+				firstErr = r.Error
+			}
+		}
+	}
+	return models, firstErr
+}
+
+// trackIncident opens, extends or closes the target's incident after a run.
+// down and error runs are outages; healthy and degraded runs recover; other
+// statuses (no_models, cancelled, timeout) leave the incident unchanged. lastError defaults to
+// the first failed row's error.
+func (ms *MonitorService) trackIncident(target *Target, runID int, status string, lastError *string, rows []DetectionResult) {
+	outage := status == "down" || status == "error"
+	recovered := status == "healthy" || status == "degraded"
+	if !outage && !recovered {
+		return
+	}
+	open, err := ms.db.GetOpenIncident(target.ID)
+	if err != nil {
+		ms.logger().Error("load incident failed", "target", target.Name, "target_id", target.ID, "run_id", runID, "error", err)
+		return
+	}
+	now := float64(time.Now().UnixMilli()) / 1000.0
+	models, rowErr := failedModels(rows)
+	if lastError == nil {
+		lastError = rowErr
+	}
+
+	switch {
+	case outage && open == nil:
+		inc, err := ms.db.OpenIncident(target, runID, now, status, lastError, models)
+		if err != nil {
+			ms.logger().Error("open incident failed", "target", target.Name, "target_id", target.ID, "run_id", runID, "error", err)
+			return
+		}
+		ms.logger().Info("incident opened", "target", target.Name, "target_id", target.ID, "run_id", runID, "incident_id", inc.ID)
+		ms.emitJSON("incident_opened", map[string]any{
+			"incident_id":     inc.ID,
+			"target_id":       target.ID,
+			"target_name":     target.Name,
+			"status":          status,
+			"affected_models": inc.AffectedModels,
+			"flapping":        ms.noteTransition(target, now),
+		})
+	case outage:
+		if err := ms.db.UpdateOpenIncident(open, runID, status, lastError, models); err != nil {
+			ms.logger().Error("update incident failed", "target", target.Name, "target_id", target.ID, "run_id", runID, "incident_id", open.ID, "error", err)
+		}
+	case open != nil:
+		if err := ms.db.CloseIncident(open.ID, runID, now); err != nil {
+			ms.logger().Error("close incident failed", "target", target.Name, "target_id", target.ID, "run_id", runID, "incident_id", open.ID, "error", err)
+			return
+		}
+		ms.logger().Info("incident closed", "target", target.Name, "target_id", target.ID, "run_id", runID, "incident_id", open.ID)
+		ms.emitJSON("incident_closed", map[string]any{
+			"incident_id": open.ID,
+			"target_id":   target.ID,
+			"target_name": target.Name,
+			"status":      status,
+			"started_at":  open.StartedAt,
+			"duration_s":  now - open.StartedAt,
+			"flapping":    ms.noteTransition(target, now),
+		})
+	}
+}
+
+// ListIncidents -- GET /api/incidents?status=open|closed&target_id=1&limit=100
+func (h *Handlers) ListIncidents(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	if status != "" && status != "open" && status != "closed" {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "status must be open or closed"})
+		return
+	}
+	var targetID *int
+	if s := r.URL.Query().Get("target_id"); s != "" {
+		id, err := strconv.Atoi(s)
+		if err != nil || id < 1 {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "invalid target_id"})
+			return
+		}
+		targetID = &id
+	}
+	limit := queryInt(r, "limit", 100, 1, 1000)
+	items, err := h.db.ListIncidents(status, targetID, limit)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return
+	}
+	visible := items[:0]
+	for _, inc := range items {
+		if principalAllowsTarget(r, inc.TargetID) {
+			visible = append(visible, inc)
+		}
+	}
+	items = visible
+	writeJSON(w, http.StatusOK, map[string]any{"items": items})
+}
+
+// GetIncident -- GET /api/incidents/{id}
+func (h *Handlers) GetIncident(w http.ResponseWriter, r *http.Request) {
+	inc, ok := h.loadIncident(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"item": inc})
+}
+
+// AcknowledgeIncident -- POST /api/incidents/{id}/ack
+func (h *Handlers) AcknowledgeIncident(w http.ResponseWriter, r *http.Request) {
+	inc, ok := h.loadIncident(w, r)
+	if !ok || !h.requireIncidentPermission(w, r, inc) {
+		return
+	}
+	now := float64(time.Now().UnixMilli()) / 1000.0
+	item, err := h.db.AcknowledgeIncident(inc.ID, string(authRoleFromRequest(r)), now)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return
+	}
+	h.audit(r, "incident.ack", "incident", inc.ID, nil)
+	h.monitor.emitJSON("incident_updated", map[string]any{"incident_id": inc.ID, "target_id": inc.TargetID})
+	writeJSON(w, http.StatusOK, map[string]any{"ok": true, "item": item})
+}
+
+// AddIncidentNote -- POST /api/incidents/{id}/notes
+// Body: {"text": "..."}
+func (h *Handlers) AddIncidentNote(w http.ResponseWriter, r *http.Request) {
+	inc, ok := h.loadIncident(w, r)
+	if !ok || !h.requireIncidentPermission(w, r, inc) {
+		return
+	}
+	var req incidentNoteRequest
+	if err := readJSON(r, &req); err != nil {
+		writeRequestBodyError(w, err)
+		return
+	}
+	text := strings.TrimSpace(req.Text)
+	if text == "" || len(text) > 4000 {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "text must be 1-4000 chars"})
+		return
+	}
+	note := IncidentNote{
+		At:   float64(time.Now().UnixMilli()) / 1000.0,
+		Role: string(authRoleFromRequest(r)),
+		Text: text,
+	}
+	item, err := h.db.AddIncidentNote(inc.ID, note)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return
+	}
+	h.audit(r, "incident.note", "incident", inc.ID, map[string]any{"note": map[string]any{"from": nil, "to": text}})
+	h.monitor.emitJSON("incident_updated", map[string]any{"incident_id": inc.ID, "target_id": inc.TargetID})
+	writeJSON(w, http.StatusOK, map[string]any{"ok": true, "item": item})
+}
+
+func (h *Handlers) loadIncident(w http.ResponseWriter, r *http.Request) (*Incident, bool) {
+	id, ok := pathID(r)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "invalid id"})
+		return nil, false
+	}
+	inc, err := h.db.GetIncident(id)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return nil, false
+	}
+	if inc == nil || !principalAllowsTarget(r, inc.TargetID) {
+		writeJSON(w, http.StatusNotFound, map[string]any{"detail": "incident not found"})
+		return nil, false
+	}
+	return inc, true
+}
+
+// requireIncidentPermission applies the channel operation rules of the incident's target;
+// incidents of deleted targets are admin-only.
+func (h *Handlers) requireIncidentPermission(w http.ResponseWriter, r *http.Request, inc *Incident) bool {
+	target, err := h.db.GetTarget(inc.TargetID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return false
+	}
+	return h.requireChannelOperationPermission(w, r, target)
+}
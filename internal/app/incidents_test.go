@@ -0,0 +1,102 @@
+package app
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestTrackIncident_OpensExtendsAndCloses(t *testing.T) {
+	db, err := NewDatabase(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDatabase failed: %v", err)
+	}
+	t.Cleanup(func() { _ = db.conn.Close() })
+	if err := db.EnsureIncidentSchema(); err != nil {
+		t.Fatalf("EnsureIncidentSchema failed: %v", err)
+	}
+	ms := NewMonitorService(MonitorConfig{DB: db, LogDir: t.TempDir()})
+	target, err := db.CreateTarget(map[string]any{"name": "t1", "base_url": "https://example.com", "api_key": "k"})
+	if err != nil {
+		t.Fatalf("CreateTarget failed: %v", err)
+	}
+	var events []string
+	ms.SetEventCallback(func(eventType, data string) { events = append(events, eventType) })
+
+	errText := "timeout"
+	ms.trackIncident(target, 1, "down", nil, []DetectionResult{{Model: "gpt-a", Error: &errText}, {Model: "gpt-b", Success: true}})
+	ms.trackIncident(target, 2, "error", nil, nil)
+	ms.trackIncident(target, 3, "down", nil, []DetectionResult{{Model: "gpt-c"}})
+	open, err := db.GetOpenIncident(target.ID)
+	if err != nil || open == nil {
+		t.Fatalf("outage should open an incident, got=%v err=%v", open, err)
+	}
+	if len(open.RunIDs) != 3 || open.StartRunID != 1 {
+		t.Fatalf("later failing runs should extend the incident, got=%+v", open)
+	}
+	if len(open.AffectedModels) != 2 || open.AffectedModels[0] != "gpt-a" || open.AffectedModels[1] != "gpt-c" {
+		t.Fatalf("affected models should collect failed models, got=%v", open.AffectedModels)
+	}
+
+	ms.trackIncident(target, 4, "no_models", nil, nil)
+	if still, _ := db.GetOpenIncident(target.ID); still == nil {
+		t.Fatalf("no_models should not close the incident")
+	}
+	ms.trackIncident(target, 5, "healthy", nil, nil)
+	closed, err := db.GetIncident(open.ID)
+	if err != nil || closed.Status != "closed" || closed.EndRunID == nil || *closed.EndRunID != 5 {
+		t.Fatalf("recovery should close the incident, got=%+v err=%v", closed, err)
+	}
+	if len(events) != 2 || events[0] != "incident_opened" || events[1] != "incident_closed" {
+		t.Fatalf("unexpected incident events, got=%v", events)
+	}
+}
+
+func TestIncidentHandlers_AckAndNotes(t *testing.T) {
+	db, err := NewDatabase(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDatabase failed: %v", err)
+	}
+	t.Cleanup(func() { _ = db.conn.Close() })
+	if err := db.EnsureIncidentSchema(); err != nil {
+		t.Fatalf("EnsureIncidentSchema failed: %v", err)
+	}
+	h := &Handlers{db: db, monitor: NewMonitorService(MonitorConfig{DB: db, LogDir: t.TempDir()})}
+	target, err := db.CreateTarget(map[string]any{"name": "t1", "base_url": "https://example.com", "api_key": "k"})
+	if err != nil {
+		t.Fatalf("CreateTarget failed: %v", err)
+	}
+	inc, err := db.OpenIncident(target, 1, 1000, "down", nil, []string{"gpt-a"})
+	if err != nil {
+		t.Fatalf("OpenIncident failed: %v", err)
+	}
+	id := strconv.Itoa(inc.ID)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/incidents/"+id+"/ack", nil)
+	req.SetPathValue("id", id)
+	rr := httptest.NewRecorder()
+	h.AcknowledgeIncident(rr, withAuthRole(req, authRoleVisitor))
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("visitor without channel actions should be rejected, got=%d", rr.Code)
+	}
+	rr = httptest.NewRecorder()
+	h.AcknowledgeIncident(rr, withAuthRole(req, authRoleAdmin))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("admin ack should succeed, got=%d body=%s", rr.Code, rr.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/incidents/"+id+"/notes", bytes.NewBufferString(`{"text":"upstream maintenance"}`))
+	req.SetPathValue("id", id)
+	rr = httptest.NewRecorder()
+	h.AddIncidentNote(rr, withAuthRole(req, authRoleAdmin))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("add note should succeed, got=%d body=%s", rr.Code, rr.Body.String())
+	}
+	got, err := db.GetIncident(inc.ID)
+	if err != nil || got.AcknowledgedAt == nil || len(got.Notes) != 1 || got.Notes[0].Text != "upstream maintenance" {
+		t.Fatalf("incident should be acknowledged with one note, got=%+v err=%v", got, err)
+	}
+}
@@ -0,0 +1,205 @@
+package app
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+type ipAllowlistScope string
+
+const (
+	// ipAllowlistAdmin covers the admin panel, admin login and admin-token-only routes.
+	ipAllowlistAdmin ipAllowlistScope = "admin"
+	// ipAllowlistWrite covers non-GET requests on the shared API routes.
+	ipAllowlistWrite ipAllowlistScope = "write"
+)
+
+// ipNetList is a parsed list of CIDRs and bare addresses. "*" matches every address;
+// an empty list matches none.
+type ipNetList struct {
+	all  bool
+	nets []*net.IPNet
+}
+
+// parseIPNetList accepts comma, space or newline separated IPs and CIDRs.
+func parseIPNetList(raw string) (ipNetList, error) {
+	var out ipNetList
+	fields := strings.FieldsFunc(raw, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\n' || r == '\r' || r == '\t'
+	})
+	for _, f := range fields {
+		if f == "*" {
+			out.all = true
+			continue
+		}
+		if !strings.Contains(f, "/") {
+			ip := net.ParseIP(f)
+			if ip == nil {
+				return ipNetList{}, fmt.Errorf("invalid ip %q", f)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			out.nets = append(out.nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(f)
+		if err != nil {
+			return ipNetList{}, fmt.Errorf("invalid cidr %q", f)
+		}
+		out.nets = append(out.nets, n)
+	}
+	return out, nil
+}
+
+func (l ipNetList) empty() bool {
+	return !l.all && len(l.nets) == 0
+}
+
+func (l ipNetList) contains(raw string) bool {
+	if l.all {
+		return true
+	}
+	ip := net.ParseIP(raw)
+	if ip == nil {
+		return false
+	}
+	for _, n := range l.nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	networkPolicyMu  sync.RWMutex
+	adminIPAllowlist ipNetList
+	writeIPAllowlist ipNetList
+	// trustedProxies lists the hops whose forwarding headers are believed. The default
+	// trusts every hop, which keeps the behaviour of deployments without configuration.
+	trustedProxies = ipNetList{all: true}
+)
+
+// setIPAllowlists replaces both allowlists; an empty value allows every client.
+func setIPAllowlists(admin, write string) error {
+	adminList, err := parseIPNetList(admin)
+	if err != nil {
+		return fmt.Errorf("admin_ip_allowlist: %w", err)
+	}
+	writeList, err := parseIPNetList(write)
+	if err != nil {
+		return fmt.Errorf("write_ip_allowlist: %w", err)
+	}
+	networkPolicyMu.Lock()
+	adminIPAllowlist, writeIPAllowlist = adminList, writeList
+	networkPolicyMu.Unlock()
+	return nil
+}
+
+// setTrustedProxies replaces the trusted proxy list; empty trusts no forwarding headers.
+func setTrustedProxies(raw string) error {
+	list, err := parseIPNetList(raw)
+	if err != nil {
+		return fmt.Errorf("trusted_proxies: %w", err)
+	}
+	networkPolicyMu.Lock()
+	trustedProxies = list
+	networkPolicyMu.Unlock()
+	return nil
+}
+
+func getTrustedProxies() ipNetList {
+	networkPolicyMu.RLock()
+	defer networkPolicyMu.RUnlock()
+	return trustedProxies
+}
+
+func ipAllowlistFor(scope ipAllowlistScope) ipNetList {
+	networkPolicyMu.RLock()
+	defer networkPolicyMu.RUnlock()
+	if scope == ipAllowlistAdmin {
+		return adminIPAllowlist
+	}
+	return writeIPAllowlist
+}
+
+// ipAllowed reports whether the request's client may use routes of scope. Write scope
+// only applies to methods that can change state.
+func ipAllowed(scope ipAllowlistScope, r *http.Request) bool {
+	if scope == ipAllowlistWrite {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			return true
+		}
+	}
+	list := ipAllowlistFor(scope)
+	return list.empty() || list.contains(clientIPFromRequest(r))
+}
+
+func ipAllowlistMiddleware(scope ipAllowlistScope, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !ipAllowed(scope, r) {
+			writeJSON(w, http.StatusForbidden, map[string]any{"detail": "client ip is not allowed"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// applyNetworkPolicyPatch validates, stores and applies allowlist and trusted proxy
+// changes. It refuses an admin allowlist that would lock out the calling client.
+func (h *Handlers) applyNetworkPolicyPatch(r *http.Request, req adminSettingsPatchRequest, current map[string]any) (int, string) {
+	adminRaw, _ := current["admin_ip_allowlist"].(string)
+	writeRaw, _ := current["write_ip_allowlist"].(string)
+	trustedRaw, _ := current["trusted_proxies"].(string)
+	if req.AdminIPAllowlist != nil {
+		adminRaw = strings.TrimSpace(*req.AdminIPAllowlist)
+	}
+	if req.WriteIPAllowlist != nil {
+		writeRaw = strings.TrimSpace(*req.WriteIPAllowlist)
+	}
+	if req.TrustedProxies != nil {
+		trustedRaw = strings.TrimSpace(*req.TrustedProxies)
+	}
+	for key, raw := range map[string]string{
+		settingAdminIPAllowlist: adminRaw,
+		settingWriteIPAllowlist: writeRaw,
+		settingTrustedProxies:   trustedRaw,
+	} {
+		if len(raw) > 4096 {
+			return http.StatusBadRequest, key + " must be <= 4096 chars"
+		}
+		if _, err := parseIPNetList(raw); err != nil {
+			return http.StatusBadRequest, fmt.Sprintf("%s: %v", key, err)
+		}
+	}
+
+	oldAdmin, oldWrite, oldTrusted := ipAllowlistFor(ipAllowlistAdmin), ipAllowlistFor(ipAllowlistWrite), getTrustedProxies()
+	restore := func() {
+		networkPolicyMu.Lock()
+		adminIPAllowlist, writeIPAllowlist, trustedProxies = oldAdmin, oldWrite, oldTrusted
+		networkPolicyMu.Unlock()
+	}
+	_ = setTrustedProxies(trustedRaw)
+	_ = setIPAllowlists(adminRaw, writeRaw)
+	if !ipAllowed(ipAllowlistAdmin, r) {
+		restore()
+		return http.StatusBadRequest, "admin_ip_allowlist must include your current ip " + clientIPFromRequest(r)
+	}
+	for key, raw := range map[string]string{
+		settingAdminIPAllowlist: adminRaw,
+		settingWriteIPAllowlist: writeRaw,
+		settingTrustedProxies:   trustedRaw,
+	} {
+		if err := h.db.SetSetting(key, raw); err != nil {
+			restore()
+			return http.StatusInternalServerError, err.Error()
+		}
+	}
+	return 0, ""
+}
@@ -0,0 +1,445 @@
+package app
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// API key pools. A target may hold extra keys in api_keys next to its api_key. Every
+// central run first verifies the pooled keys with GET /v1/models, stores the outcome in
+// target_key_status and runs the detection with the first alive key; a key checked less
+// than keyRecheckInterval ago keeps its stored status instead of being requested again; the proxy rotates
+// among the usable keys and feeds auth and rate-limit answers back into their status.
+// A key that turns exhausted or revoked is flagged with an api_key_dead event, and
+// GET /api/targets/{id}/keys lists the pool. Keys are identified by a fingerprint (a
+// SHA-256 prefix), so the status table never holds a key.
+
+// maxPooledAPIKeys caps api_keys.
+const maxPooledAPIKeys = 50
+
+// keyRecheckInterval is how long a key's status is trusted before checkKeyPool requests
+// /v1/models with it again, so a large pool costs at most one request per key per
+// interval rather than per run. Proxied 401/402/403/429 answers update it in between.
+const keyRecheckInterval = 30 * time.Minute
+
+// targetKeyPool returns api_key followed by the distinct extra keys of t.
+func targetKeyPool(t *Target) []string {
+	keys := make([]string, 0, 1+len(t.APIKeys))
+	seen := map[string]bool{}
+	for _, k := range append([]string{t.APIKey}, t.APIKeys...) {
+		if k == "" || seen[k] {
+			continue
+		}
+		seen[k] = true
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// apiKeyFingerprint identifies key without revealing it.
+func apiKeyFingerprint(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:6])
+}
+
+func maskAPIKeys(keys []string) []string {
+	out := make([]string, len(keys))
+	for i, k := range keys {
+		out[i] = maskAPIKey(k)
+	}
+	return out
+}
+
+// unmaskAPIKeys replaces the masked keys an edit form echoes back with the stored keys
+// they stand for; a mask shared by several stored keys is left as is.
+func unmaskAPIKeys(items []any, existing []string) []any {
+	byMask := map[string]string{}
+	for _, k := range existing {
+		m := maskAPIKey(k)
+		if _, dup := byMask[m]; dup {
+			byMask[m] = ""
+			continue
+		}
+		byMask[m] = k
+	}
+	out := make([]any, len(items))
+	for i, item := range items {
+		out[i] = item
+		if s, ok := item.(string); ok && byMask[s] != "" {
+			out[i] = byMask[s]
+		}
+	}
+	return out
+}
+
+// Key states. A check that fails for a reason that says nothing about the key (network
+// error, timeout, upstream 5xx) keeps the previous state.
+const (
+	keyStateAlive     = "alive"
+	keyStateExhausted = "exhausted"
+	keyStateRevoked   = "revoked"
+	// keyStateUnknown is a key that was checked but never answered conclusively.
+	keyStateUnknown = "unknown"
+	// keyStateUnverified is a pooled key that has not been checked yet; it is never stored.
+	keyStateUnverified = "unverified"
+)
+
+// keyStateForCategory maps the error category of a failed key check to a key state;
+// it returns "" when the failure is not the key's fault.
+func keyStateForCategory(category string) string {
+	switch category {
+	case errorCategoryAuthFailed:
+		return keyStateRevoked
+	case errorCategoryQuotaExceeded, errorCategoryRateLimited:
+		return keyStateExhausted
+	}
+	return ""
+}
+
+// keyUsable reports whether the proxy may route to a key in state.
+func keyUsable(state string) bool {
+	return state == keyStateAlive || state == keyStateUnknown || state == keyStateUnverified
+}
+
+// TargetKeyStatus is the health of one pooled key.
+type TargetKeyStatus struct {
+	Fingerprint string  `json:"fingerprint"`
+	State       string  `json:"state"`
+	CheckedAt   float64 `json:"checked_at"`
+	// LastOKAt is when the key last answered successfully.
+	LastOKAt      *float64 `json:"last_ok_at"`
+	StatusCode    *int     `json:"status_code"`
+	ErrorCategory *string  `json:"error_category"`
+	Error         *string  `json:"error"`
+	// AuthFailures and RateLimits count the failed checks and proxied requests the key
+	// was rejected for or throttled in; ConsecutiveFailures resets on success.
+	AuthFailures        int `json:"auth_failures"`
+	RateLimits          int `json:"rate_limits"`
+	ConsecutiveFailures int `json:"consecutive_failures"`
+}
+
+// nextKeyStatus folds one outcome into prev (nil for a key without a status) and
+// returns the new status. category is empty on success.
+func nextKeyStatus(prev *TargetKeyStatus, fingerprint string, statusCode *int, category, message string, at float64) TargetKeyStatus {
+	s := TargetKeyStatus{Fingerprint: fingerprint, State: keyStateUnknown}
+	if prev != nil {
+		s = *prev
+	}
+	s.CheckedAt = at
+	s.StatusCode = statusCode
+	if category == "" {
+		s.State = keyStateAlive
+		s.LastOKAt = &at
+		s.ErrorCategory, s.Error = nil, nil
+		s.ConsecutiveFailures = 0
+		return s
+	}
+	msg := truncStr(message, 500)
+	s.ErrorCategory, s.Error = &category, &msg
+	s.ConsecutiveFailures++
+	switch category {
+	case errorCategoryAuthFailed:
+		s.AuthFailures++
+	case errorCategoryQuotaExceeded, errorCategoryRateLimited:
+		s.RateLimits++
+	}
+	if state := keyStateForCategory(category); state != "" {
+		s.State = state
+	}
+	return s
+}
+
+const keyStatusColumns = `fingerprint, state, checked_at, last_ok_at, status_code, error_category, error,
+	auth_failures, rate_limits, consecutive_failures`
+
+// GetTargetKeyStatuses returns the key statuses of a target by fingerprint.
+func (d *Database) GetTargetKeyStatuses(targetID int) (map[string]TargetKeyStatus, error) {
+	rows, err := d.read.Query(`SELECT `+keyStatusColumns+` FROM target_key_status WHERE target_id = ?`, targetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := map[string]TargetKeyStatus{}
+	for rows.Next() {
+		var s TargetKeyStatus
+		if err := rows.Scan(&s.Fingerprint, &s.State, &s.CheckedAt, &s.LastOKAt, &s.StatusCode, &s.ErrorCategory, &s.Error,
+			&s.AuthFailures, &s.RateLimits, &s.ConsecutiveFailures); err != nil {
+			return nil, err
+		}
+		out[s.Fingerprint] = s
+	}
+	return out, rows.Err()
+}
+
+func upsertKeyStatus(exec interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}, targetID int, s TargetKeyStatus) error {
+	_, err := exec.Exec(`
+		INSERT OR REPLACE INTO target_key_status (target_id, healthy, `+keyStatusColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		targetID, boolToInt(s.State == keyStateAlive), s.Fingerprint, s.State, s.CheckedAt, s.LastOKAt, s.StatusCode,
+		s.ErrorCategory, s.Error, s.AuthFailures, s.RateLimits, s.ConsecutiveFailures)
+	return err
+}
+
+// SetTargetKeyStatuses replaces the key statuses of a target, dropping keys that left
+// the pool.
+func (d *Database) SetTargetKeyStatuses(targetID int, statuses []TargetKeyStatus) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	tx, err := d.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`DELETE FROM target_key_status WHERE target_id = ?`, targetID); err != nil {
+		return err
+	}
+	for _, s := range statuses {
+		if err := upsertKeyStatus(tx, targetID, s); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// RecordKeyOutcome folds one proxied request outcome into the status of a pooled key.
+func (d *Database) RecordKeyOutcome(targetID int, fingerprint string, statusCode *int, category, message string, at float64) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	tx, err := d.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	var prev *TargetKeyStatus
+	var s TargetKeyStatus
+	err = tx.QueryRow(`SELECT `+keyStatusColumns+` FROM target_key_status WHERE target_id = ? AND fingerprint = ?`, targetID, fingerprint).
+		Scan(&s.Fingerprint, &s.State, &s.CheckedAt, &s.LastOKAt, &s.StatusCode, &s.ErrorCategory, &s.Error,
+			&s.AuthFailures, &s.RateLimits, &s.ConsecutiveFailures)
+	switch {
+	case err == nil:
+		prev = &s
+	case err != sql.ErrNoRows:
+		return err
+	}
+	if err := upsertKeyStatus(tx, targetID, nextKeyStatus(prev, fingerprint, statusCode, category, message, at)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// checkAPIKey verifies one key with GET /v1/models and returns the HTTP status (nil
+// without a response) and the error, if any.
+func (ms *MonitorService) checkAPIKey(ctx context.Context, target *Target, client *http.Client, key string) (*int, error) {
+	probe := *target
+	probe.APIKey = key
+	res, err := httpJSON(ctx, client, "GET", normalizeBaseURL(target.BaseURL)+"/v1/models", targetHeaders(&probe), nil)
+	if err != nil {
+		return nil, fmt.Errorf("GET /v1/models failed: %w", err)
+	}
+	code := res.StatusCode
+	if code != 200 {
+		msg := checkResponseBodyForError(res.JSONBody)
+		if msg == "" {
+			msg = truncStr(res.Text, 500)
+		}
+		return &code, fmt.Errorf("GET /v1/models failed: HTTP %d - %s", code, msg)
+	}
+	return &code, nil
+}
+
+// checkKeyPool verifies the pooled keys of target whose status is missing or older than
+// keyRecheckInterval and returns the target to detect with: a copy using the first
+// alive key, or target itself when it has a single key or no key is alive.
+func (ms *MonitorService) checkKeyPool(ctx context.Context, target *Target, client *http.Client) *Target {
+	keys := targetKeyPool(target)
+	if len(keys) < 2 {
+		return target
+	}
+	previous, err := ms.db.GetTargetKeyStatuses(target.ID)
+	if err != nil {
+		ms.logger().Error("load key statuses failed", "target", target.Name, "target_id", target.ID, "error", err)
+		previous = map[string]TargetKeyStatus{}
+	}
+
+	statuses := make([]TargetKeyStatus, 0, len(keys))
+	aliveKey := ""
+	checked := 0
+	staleBefore := float64(time.Now().Add(-keyRecheckInterval).UnixMilli()) / 1000.0
+	for _, key := range keys {
+		fp := apiKeyFingerprint(key)
+		var prev *TargetKeyStatus
+		if p, ok := previous[fp]; ok {
+			prev = &p
+			if p.CheckedAt > staleBefore {
+				if p.State == keyStateAlive && aliveKey == "" {
+					aliveKey = key
+				}
+				statuses = append(statuses, p)
+				continue
+			}
+		}
+		statusCode, err := ms.checkAPIKey(ctx, target, client, key)
+		if ctx.Err() != nil {
+			return target
+		}
+		checked++
+		category, message := "", ""
+		if err != nil {
+			message = err.Error()
+			category = classifyDetectionError(statusCode, message)
+		}
+		s := nextKeyStatus(prev, fp, statusCode, category, message, float64(time.Now().UnixMilli())/1000.0)
+		if s.State == keyStateAlive && aliveKey == "" {
+			aliveKey = key
+		}
+		if !keyUsable(s.State) && (prev == nil || keyUsable(prev.State)) {
+			ms.logger().Warn("api key dead", "target", target.Name, "target_id", target.ID, "fingerprint", fp, "state", s.State, "error", err)
+			ms.emitJSON("api_key_dead", map[string]any{
+				"target_id":   target.ID,
+				"target_name": target.Name,
+				"fingerprint": fp,
+				"api_key":     maskAPIKey(key),
+				"state":       s.State,
+				"message":     truncStr(message, 500),
+			})
+		}
+		statuses = append(statuses, s)
+	}
+	if checked > 0 || len(statuses) != len(previous) {
+		if err := ms.db.SetTargetKeyStatuses(target.ID, statuses); err != nil {
+			ms.logger().Error("store key statuses failed", "target", target.Name, "target_id", target.ID, "error", err)
+		}
+	}
+	if aliveKey == "" || aliveKey == target.APIKey {
+		return target
+	}
+	detect := *target
+	detect.APIKey = aliveKey
+	return &detect
+}
+
+// keyRotation hands out the healthy keys of each target in turn.
+type keyRotation struct {
+	mu   sync.Mutex
+	next map[int]int
+}
+
+func (k *keyRotation) pick(targetID int, keys []string) string {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.next == nil {
+		k.next = map[int]int{}
+	}
+	i := k.next[targetID] % len(keys)
+	k.next[targetID] = i + 1
+	return keys[i]
+}
+
+// proxyAPIKey returns the key a proxied request to target uses: the next healthy key of
+// its pool. Keys that were never verified count as healthy; with no healthy key left
+// the whole pool is rotated.
+func (h *Handlers) proxyAPIKey(target *Target) string {
+	keys := targetKeyPool(target)
+	if len(keys) < 2 {
+		return target.APIKey
+	}
+	statuses, err := h.db.GetTargetKeyStatuses(target.ID)
+	if err != nil {
+		h.logger("proxy").Warn("load key statuses failed", "target_id", target.ID, "error", err)
+	}
+	usable := make([]string, 0, len(keys))
+	for _, k := range keys {
+		if s, ok := statuses[apiKeyFingerprint(k)]; !ok || keyUsable(s.State) {
+			usable = append(usable, k)
+		}
+	}
+	if len(usable) == 0 {
+		usable = keys
+	}
+	return h.keys.pick(target.ID, usable)
+}
+
+// recordProxyKeyOutcome updates the status of a pooled key from the upstream answer to
+// a proxied request: 401/403 revoke it and 402/429 mark it exhausted until the next
+// successful check. Other answers leave the status alone.
+func (h *Handlers) recordProxyKeyOutcome(target *Target, key string, statusCode int) {
+	if len(targetKeyPool(target)) < 2 {
+		return
+	}
+	var category string
+	switch statusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		category = errorCategoryAuthFailed
+	case http.StatusPaymentRequired:
+		category = errorCategoryQuotaExceeded
+	case http.StatusTooManyRequests:
+		category = errorCategoryRateLimited
+	default:
+		return
+	}
+	at := float64(time.Now().UnixMilli()) / 1000.0
+	msg := "proxied request answered HTTP " + strconv.Itoa(statusCode)
+	if err := h.db.RecordKeyOutcome(target.ID, apiKeyFingerprint(key), &statusCode, category, msg, at); err != nil {
+		h.logger("proxy").Warn("record key outcome failed", "target_id", target.ID, "error", err)
+	}
+}
+
+// TargetKeyItem is one key of a target's pool as listed by GET /api/targets/{id}/keys.
+type TargetKeyItem struct {
+	TargetKeyStatus
+	Index int `json:"index"`
+	// Primary is the target's api_key; the others come from api_keys.
+	Primary bool   `json:"primary"`
+	APIKey  string `json:"api_key"`
+}
+
+// ListTargetKeys -- GET /api/targets/{id}/keys
+// Lists the key pool of a target in order with each key's state (alive, exhausted,
+// revoked, unknown, unverified); keys are always masked.
+func (h *Handlers) ListTargetKeys(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(r)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "invalid id"})
+		return
+	}
+	target, err := h.db.GetTarget(id)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return
+	}
+	if target == nil {
+		writeJSON(w, http.StatusNotFound, map[string]any{"detail": "target not found"})
+		return
+	}
+	statuses, err := h.db.GetTargetKeyStatuses(id)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return
+	}
+	keys := targetKeyPool(target)
+	items := make([]TargetKeyItem, 0, len(keys))
+	totals := map[string]int{}
+	for i, key := range keys {
+		fp := apiKeyFingerprint(key)
+		s, ok := statuses[fp]
+		if !ok {
+			s = TargetKeyStatus{Fingerprint: fp, State: keyStateUnverified}
+		}
+		items = append(items, TargetKeyItem{TargetKeyStatus: s, Index: i, Primary: key == target.APIKey, APIKey: maskAPIKey(key)})
+		totals[s.State]++
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"target_id": id,
+		"totals":    totals,
+		"items":     items,
+	})
+}
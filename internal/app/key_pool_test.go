@@ -0,0 +1,228 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestKeyPoolVerifiesKeysAndRotatesProxy(t *testing.T) {
+	withEncryptionKey(t, "master")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "Bearer sk-dead-0000" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error":{"message":"invalid api key"}}`))
+			return
+		}
+		if r.URL.Path == "/v1/models" {
+			_, _ = w.Write([]byte(`{"data":[{"id":"gpt-ok"}]}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"choices":[{"message":{"content":"ok"}}]}`))
+	}))
+	defer srv.Close()
+
+	db, err := NewDatabase(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDatabase failed: %v", err)
+	}
+	t.Cleanup(func() { _ = db.conn.Close() })
+	ms := NewMonitorService(MonitorConfig{DB: db, LogDir: t.TempDir()})
+	var mu sync.Mutex
+	dead := 0
+	ms.SetEventCallback(func(eventType, data string) {
+		if eventType == "api_key_dead" {
+			mu.Lock()
+			dead++
+			mu.Unlock()
+		}
+	})
+
+	target, err := db.CreateTarget(map[string]any{
+		"name": "pool", "base_url": srv.URL, "api_key": "sk-dead-0000",
+		"api_keys": []any{"sk-good-1111", "sk-good-2222", "sk-dead-0000"},
+	})
+	if err != nil {
+		t.Fatalf("CreateTarget failed: %v", err)
+	}
+	if len(target.APIKeys) != 3 || len(targetKeyPool(target)) != 3 {
+		t.Fatalf("pool should hold the primary and two distinct extra keys, got=%v", target.APIKeys)
+	}
+	var raw string
+	if err := db.conn.QueryRow("SELECT api_keys FROM targets WHERE id = ?", target.ID).Scan(&raw); err != nil {
+		t.Fatal(err)
+	}
+	if !isEncryptedSecret(raw) || strings.Contains(raw, "sk-good") {
+		t.Fatalf("api_keys should be stored encrypted, got=%q", raw)
+	}
+
+	for i := 0; i < 2; i++ {
+		if ok, msg := ms.TriggerTarget(target.ID, true); !ok {
+			t.Fatalf("TriggerTarget failed: %s", msg)
+		}
+		ms.WaitDetections()
+		ageKeyStatuses(t, db, target.ID)
+	}
+	got, err := db.GetTarget(target.ID)
+	if err != nil {
+		t.Fatalf("GetTarget failed: %v", err)
+	}
+	if got.LastStatus == nil || *got.LastStatus != "healthy" {
+		t.Fatalf("detection should use a healthy pooled key, got status=%v", got.LastStatus)
+	}
+	statuses, err := db.GetTargetKeyStatuses(target.ID)
+	if err != nil {
+		t.Fatalf("GetTargetKeyStatuses failed: %v", err)
+	}
+	if len(statuses) != 3 || statuses[apiKeyFingerprint("sk-dead-0000")].State != keyStateRevoked || statuses[apiKeyFingerprint("sk-good-2222")].State != keyStateAlive {
+		t.Fatalf("dead key should be revoked and good keys alive, got=%+v", statuses)
+	}
+	if s := statuses[apiKeyFingerprint("sk-dead-0000")]; s.AuthFailures != 2 || s.ConsecutiveFailures != 2 || s.LastOKAt != nil {
+		t.Fatalf("revoked key should count both failed checks, got=%+v", s)
+	}
+	mu.Lock()
+	if dead != 1 {
+		t.Fatalf("api_key_dead should fire once per key going dead, got=%d", dead)
+	}
+	mu.Unlock()
+
+	h := &Handlers{db: db, monitor: ms}
+	seen := map[string]int{}
+	for i := 0; i < 4; i++ {
+		seen[h.proxyAPIKey(got)]++
+	}
+	if seen["sk-good-1111"] != 2 || seen["sk-good-2222"] != 2 {
+		t.Fatalf("proxy should rotate among the healthy keys, got=%v", seen)
+	}
+}
+
+// ageKeyStatuses makes the stored key statuses of a target due for a recheck.
+func ageKeyStatuses(t *testing.T, db *Database, targetID int) {
+	t.Helper()
+	if _, err := db.conn.Exec("UPDATE target_key_status SET checked_at = checked_at - ? WHERE target_id = ?", keyRecheckInterval.Seconds(), targetID); err != nil {
+		t.Fatalf("age key statuses failed: %v", err)
+	}
+}
+
+func TestKeyPoolRechecksKeysOnlyWhenStale(t *testing.T) {
+	var mu sync.Mutex
+	checks := map[string]int{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/models" {
+			mu.Lock()
+			checks[r.Header.Get("Authorization")]++
+			mu.Unlock()
+			_, _ = w.Write([]byte(`{"data":[{"id":"gpt-ok"}]}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"choices":[{"message":{"content":"ok"}}]}`))
+	}))
+	defer srv.Close()
+
+	db, err := NewDatabase(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDatabase failed: %v", err)
+	}
+	t.Cleanup(func() { _ = db.conn.Close() })
+	ms := NewMonitorService(MonitorConfig{DB: db, LogDir: t.TempDir()})
+	extra := make([]any, 0, 9)
+	for i := 1; i <= 9; i++ {
+		extra = append(extra, "sk-pool-"+strconv.Itoa(i))
+	}
+	target, err := db.CreateTarget(map[string]any{"name": "pool", "base_url": srv.URL, "api_key": "sk-pool-0", "api_keys": extra})
+	if err != nil {
+		t.Fatalf("CreateTarget failed: %v", err)
+	}
+	run := func() map[string]int {
+		mu.Lock()
+		checks = map[string]int{}
+		mu.Unlock()
+		if ok, msg := ms.TriggerTarget(target.ID, true); !ok {
+			t.Fatalf("TriggerTarget failed: %s", msg)
+		}
+		ms.WaitDetections()
+		mu.Lock()
+		defer mu.Unlock()
+		return checks
+	}
+
+	// The first run verifies all ten keys; the detection lists models with the first.
+	if got := run(); len(got) != 10 || got["Bearer sk-pool-0"] != 2 || got["Bearer sk-pool-9"] != 1 {
+		t.Fatalf("first run should verify every pooled key once, got=%v", got)
+	}
+	if got := run(); len(got) != 1 || got["Bearer sk-pool-0"] != 1 {
+		t.Fatalf("a run within the recheck interval should only list models, got=%v", got)
+	}
+	statuses, _ := db.GetTargetKeyStatuses(target.ID)
+	if len(statuses) != 10 || statuses[apiKeyFingerprint("sk-pool-5")].State != keyStateAlive {
+		t.Fatalf("cached statuses should be kept, got=%+v", statuses)
+	}
+	ageKeyStatuses(t, db, target.ID)
+	if got := run(); len(got) != 10 {
+		t.Fatalf("stale statuses should be verified again, got=%v", got)
+	}
+}
+
+func TestUnmaskAPIKeys(t *testing.T) {
+	existing := []string{"sk-abcdef111111", "sk-zzzzzz222222"}
+	got := unmaskAPIKeys([]any{maskAPIKeys(existing)[1], "sk-new-key-3333"}, existing)
+	if got[0] != "sk-zzzzzz222222" || got[1] != "sk-new-key-3333" {
+		t.Fatalf("masked keys should map back to stored keys, got=%v", got)
+	}
+}
+
+func TestListTargetKeysReportsStates(t *testing.T) {
+	db, err := NewDatabase(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDatabase failed: %v", err)
+	}
+	t.Cleanup(func() { _ = db.conn.Close() })
+	target, err := db.CreateTarget(map[string]any{
+		"name": "pool", "base_url": "https://relay.example.com", "api_key": "sk-primary-0000",
+		"api_keys": []any{"sk-limited-1111", "sk-new-2222"},
+	})
+	if err != nil {
+		t.Fatalf("CreateTarget failed: %v", err)
+	}
+	h := &Handlers{db: db, monitor: NewMonitorService(MonitorConfig{DB: db, LogDir: t.TempDir()})}
+	code := http.StatusOK
+	if err := db.SetTargetKeyStatuses(target.ID, []TargetKeyStatus{
+		nextKeyStatus(nil, apiKeyFingerprint("sk-primary-0000"), &code, "", "", 100),
+		nextKeyStatus(nil, apiKeyFingerprint("sk-limited-1111"), &code, "", "", 100),
+	}); err != nil {
+		t.Fatalf("SetTargetKeyStatuses failed: %v", err)
+	}
+	h.recordProxyKeyOutcome(target, "sk-limited-1111", http.StatusTooManyRequests)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/targets/1/keys", nil)
+	req.SetPathValue("id", strconv.Itoa(target.ID))
+	rr := httptest.NewRecorder()
+	h.ListTargetKeys(rr, withAuthRole(req, authRoleAdmin))
+	var out struct {
+		Totals map[string]int  `json:"totals"`
+		Items  []TargetKeyItem `json:"items"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &out); err != nil || rr.Code != http.StatusOK {
+		t.Fatalf("ListTargetKeys failed: code=%d err=%v", rr.Code, err)
+	}
+	if len(out.Items) != 3 || !out.Items[0].Primary || out.Items[0].State != keyStateAlive {
+		t.Fatalf("primary key should be listed first and alive, got=%+v", out.Items)
+	}
+	if s := out.Items[1]; s.State != keyStateExhausted || s.RateLimits != 1 || s.StatusCode == nil || *s.StatusCode != 429 || s.LastOKAt == nil {
+		t.Fatalf("a proxied 429 should mark the key exhausted, got=%+v", s)
+	}
+	if out.Items[2].State != keyStateUnverified || strings.Contains(rr.Body.String(), "sk-new-2222") {
+		t.Fatalf("unchecked key should be unverified and masked, got=%+v", out.Items[2])
+	}
+	if out.Totals[keyStateAlive] != 1 || out.Totals[keyStateExhausted] != 1 || out.Totals[keyStateUnverified] != 1 {
+		t.Fatalf("totals should count keys by state, got=%v", out.Totals)
+	}
+	if key := h.proxyAPIKey(target); key == "sk-limited-1111" {
+		t.Fatalf("proxy should skip the exhausted key")
+	}
+}
@@ -0,0 +1,99 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// API key redaction modes for target responses:
+//   - off: every authenticated client sees the full api_key (legacy behaviour)
+//   - visitors: visitors get a masked key, admins the full key (default)
+//   - all: everyone gets a masked key; admins reveal it via the api-key endpoint
+const (
+	apiKeyRedactionOff      = "off"
+	apiKeyRedactionVisitors = "visitors"
+	apiKeyRedactionAll      = "all"
+)
+
+var (
+	apiKeyRedactionMu   sync.RWMutex
+	apiKeyRedactionMode = apiKeyRedactionVisitors
+)
+
+func normalizeAPIKeyRedaction(mode string) (string, error) {
+	switch m := strings.ToLower(strings.TrimSpace(mode)); m {
+	case "":
+		return apiKeyRedactionVisitors, nil
+	case apiKeyRedactionOff, apiKeyRedactionVisitors, apiKeyRedactionAll:
+		return m, nil
+	}
+	return "", fmt.Errorf("api_key_redaction must be off, visitors or all")
+}
+
+func setAPIKeyRedaction(mode string) error {
+	m, err := normalizeAPIKeyRedaction(mode)
+	if err != nil {
+		return err
+	}
+	apiKeyRedactionMu.Lock()
+	apiKeyRedactionMode = m
+	apiKeyRedactionMu.Unlock()
+	return nil
+}
+
+func getAPIKeyRedaction() string {
+	apiKeyRedactionMu.RLock()
+	defer apiKeyRedactionMu.RUnlock()
+	return apiKeyRedactionMode
+}
+
+// maskAPIKey keeps a short vendor prefix such as "sk-" and the last four characters:
+// "sk-abcdef123456" becomes "sk-****3456". Keys too short to hide anything become "****".
+func maskAPIKey(key string) string {
+	if len(key) <= 8 {
+		return "****"
+	}
+	prefix := ""
+	if i := strings.IndexByte(key, '-'); i > 0 && i <= 4 {
+		prefix = key[:i+1]
+	}
+	return prefix + "****" + key[len(key)-4:]
+}
+
+// apiKeyRedactedFor reports whether target keys are masked for the caller of r.
+func apiKeyRedactedFor(r *http.Request) bool {
+	switch getAPIKeyRedaction() {
+	case apiKeyRedactionOff:
+		return false
+	case apiKeyRedactionAll:
+		return true
+	}
+	return authRoleFromRequest(r) != authRoleAdmin
+}
+
+// RevealTargetAPIKey handles GET /api/targets/{id}/api-key (admin Bearer token) and
+// GET /api/admin/channels/{id}/api-key. Every reveal is audited.
+func (h *Handlers) RevealTargetAPIKey(w http.ResponseWriter, r *http.Request) {
+	if authRoleFromRequest(r) != authRoleAdmin {
+		writeJSON(w, http.StatusForbidden, map[string]any{"detail": "admin token required"})
+		return
+	}
+	id, ok := pathID(r)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "invalid id"})
+		return
+	}
+	target, err := h.db.GetTarget(id)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return
+	}
+	if target == nil {
+		writeJSON(w, http.StatusNotFound, map[string]any{"detail": "target not found"})
+		return
+	}
+	h.audit(r, "target.reveal_api_key", "target", id, nil)
+	writeJSON(w, http.StatusOK, map[string]any{"item": map[string]any{"id": target.ID, "api_key": target.APIKey}})
+}
@@ -0,0 +1,107 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestMaskAPIKey(t *testing.T) {
+	cases := map[string]string{
+		"sk-abcdef123456":     "sk-****3456",
+		"sk-proj-abcdefghijk": "sk-****hijk",
+		"abcdefghijkl":        "****ijkl",
+		"short":               "****",
+		"":                    "****",
+	}
+	for key, want := range cases {
+		if got := maskAPIKey(key); got != want {
+			t.Fatalf("maskAPIKey(%q) should be %q, got=%q", key, want, got)
+		}
+	}
+}
+
+func TestTargetResponsesRedactAPIKey(t *testing.T) {
+	db, err := NewDatabase(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDatabase failed: %v", err)
+	}
+	t.Cleanup(func() { _ = db.conn.Close() })
+	if err := db.EnsureAuditSchema(); err != nil {
+		t.Fatalf("EnsureAuditSchema failed: %v", err)
+	}
+	t.Cleanup(func() { _ = setAPIKeyRedaction(apiKeyRedactionVisitors) })
+	h := &Handlers{db: db, monitor: NewMonitorService(MonitorConfig{DB: db, LogDir: t.TempDir()})}
+	target, err := db.CreateTarget(map[string]any{"name": "t1", "base_url": "https://example.com", "api_key": "sk-secret-value-9876"})
+	if err != nil {
+		t.Fatalf("CreateTarget failed: %v", err)
+	}
+	id := strconv.Itoa(target.ID)
+
+	getKey := func(role authRole) (string, bool) {
+		req := httptest.NewRequest(http.MethodGet, "/api/targets/"+id, nil)
+		req.SetPathValue("id", id)
+		rr := httptest.NewRecorder()
+		h.GetTarget(rr, withAuthRole(req, role))
+		var body struct {
+			Item map[string]any `json:"item"`
+		}
+		_ = json.Unmarshal(rr.Body.Bytes(), &body)
+		key, _ := body.Item["api_key"].(string)
+		masked, _ := body.Item["api_key_masked"].(bool)
+		return key, masked
+	}
+
+	_ = setAPIKeyRedaction(apiKeyRedactionVisitors)
+	if key, masked := getKey(authRoleVisitor); key != "sk-****9876" || !masked {
+		t.Fatalf("visitors should get a masked key, got=%q masked=%v", key, masked)
+	}
+	if key, masked := getKey(authRoleAdmin); key != "sk-secret-value-9876" || masked {
+		t.Fatalf("admins should get the full key in visitors mode, got=%q masked=%v", key, masked)
+	}
+	_ = setAPIKeyRedaction(apiKeyRedactionAll)
+	if key, _ := getKey(authRoleAdmin); key != "sk-****9876" {
+		t.Fatalf("admins should get a masked key in all mode, got=%q", key)
+	}
+	_ = setAPIKeyRedaction(apiKeyRedactionOff)
+	if key, _ := getKey(authRoleVisitor); key != "sk-secret-value-9876" {
+		t.Fatalf("off mode should return the full key, got=%q", key)
+	}
+
+	// Saving an edit form that still holds the masked key leaves the key alone.
+	_ = setAPIKeyRedaction(apiKeyRedactionAll)
+	req := httptest.NewRequest(http.MethodPatch, "/api/targets/"+id, strings.NewReader(`{"name":"renamed","api_key":"sk-****9876"}`))
+	req.SetPathValue("id", id)
+	rr := httptest.NewRecorder()
+	h.PatchTarget(rr, withAuthRole(req, authRoleAdmin))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("update should succeed, got=%d body=%s", rr.Code, rr.Body.String())
+	}
+	stored, _ := db.GetTarget(target.ID)
+	if stored.APIKey != "sk-secret-value-9876" || stored.Name != "renamed" {
+		t.Fatalf("masked key should not overwrite the stored key, got=%q name=%q", stored.APIKey, stored.Name)
+	}
+
+	reveal := func(role authRole) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/targets/"+id+"/api-key", nil)
+		req.SetPathValue("id", id)
+		rr := httptest.NewRecorder()
+		h.RevealTargetAPIKey(rr, withAuthRole(req, role))
+		return rr
+	}
+	if rr := reveal(authRoleVisitor); rr.Code != http.StatusForbidden {
+		t.Fatalf("visitors should not reveal keys, got=%d", rr.Code)
+	}
+	rr = reveal(authRoleAdmin)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "sk-secret-value-9876") {
+		t.Fatalf("admins should reveal the full key, got=%d body=%s", rr.Code, rr.Body.String())
+	}
+	entries, err := db.ListAudit(AuditFilter{Action: "target.reveal_api_key", Limit: 10})
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("reveal should be audited, got=%v err=%v", entries, err)
+	}
+}
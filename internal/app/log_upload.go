@@ -0,0 +1,233 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Log shipping. With object storage configured in the admin settings (s3_endpoint,
+// s3_bucket, s3_access_key_id, s3_secret_access_key), every finished run log is
+// uploaded to <s3_prefix><file name> with a path-style, SigV4-signed PUT, which works
+// with AWS S3, MinIO and other S3-compatible stores. The key is recorded on the run as
+// log_object_key, and with s3_delete_local the local copy is removed once uploaded. A
+// failed upload keeps the local file and is only logged.
+
+const (
+	settingS3Endpoint        = "s3_endpoint"
+	settingS3Region          = "s3_region"
+	settingS3Bucket          = "s3_bucket"
+	settingS3AccessKeyID     = "s3_access_key_id"
+	settingS3SecretAccessKey = "s3_secret_access_key"
+	settingS3Prefix          = "s3_prefix"
+	settingS3DeleteLocal     = "s3_delete_local"
+)
+
+// s3SettingKeys lists the admin settings that configure log shipping.
+var s3SettingKeys = []string{
+	settingS3Endpoint,
+	settingS3Region,
+	settingS3Bucket,
+	settingS3AccessKeyID,
+	settingS3SecretAccessKey,
+	settingS3Prefix,
+	settingS3DeleteLocal,
+}
+
+const (
+	s3DefaultRegion = "us-east-1"
+	s3UploadTimeout = 2 * time.Minute
+)
+
+var s3BucketPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9.-]{1,61}[a-z0-9]$`)
+
+// s3Config is the log shipping configuration read from admin settings.
+type s3Config struct {
+	Endpoint        string
+	Region          string
+	Bucket          string
+	AccessKeyID     string
+	SecretAccessKey string
+	Prefix          string
+	DeleteLocal     bool
+}
+
+func (c s3Config) Enabled() bool {
+	return c.Endpoint != "" && c.Bucket != "" && c.AccessKeyID != "" && c.SecretAccessKey != ""
+}
+
+func (d *Database) loadS3Config() (s3Config, error) {
+	settings, err := d.GetSettings(s3SettingKeys)
+	if err != nil {
+		return s3Config{}, err
+	}
+	cfg := s3Config{
+		Endpoint:        strings.TrimRight(strings.TrimSpace(settings[settingS3Endpoint]), "/"),
+		Region:          strings.TrimSpace(settings[settingS3Region]),
+		Bucket:          strings.TrimSpace(settings[settingS3Bucket]),
+		AccessKeyID:     strings.TrimSpace(settings[settingS3AccessKeyID]),
+		SecretAccessKey: strings.TrimSpace(settings[settingS3SecretAccessKey]),
+		Prefix:          strings.TrimSpace(settings[settingS3Prefix]),
+		DeleteLocal:     parseBoolString(settings[settingS3DeleteLocal], false),
+	}
+	if cfg.Region == "" {
+		cfg.Region = s3DefaultRegion
+	}
+	return cfg, nil
+}
+
+// validateS3Setting checks one log shipping admin setting and returns an error detail, or "".
+func validateS3Setting(key, value string) string {
+	if len(value) > 2048 {
+		return key + " must be <= 2048 chars"
+	}
+	if value == "" {
+		return ""
+	}
+	switch key {
+	case settingS3Endpoint:
+		u, err := url.Parse(value)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return "s3_endpoint must be an http(s) URL"
+		}
+	case settingS3Bucket:
+		if !s3BucketPattern.MatchString(value) {
+			return "s3_bucket must be 3-63 lowercase letters, digits, dots or hyphens"
+		}
+	case settingS3Prefix:
+		if strings.HasPrefix(value, "/") {
+			return "s3_prefix must not start with /"
+		}
+	}
+	return ""
+}
+
+// shipRunLog uploads a finished run log when log shipping is configured.
+func (ms *MonitorService) shipRunLog(path string) {
+	if path == "" {
+		return
+	}
+	cfg, err := ms.db.loadS3Config()
+	if err != nil || !cfg.Enabled() {
+		return
+	}
+	body, err := os.ReadFile(path)
+	if err != nil {
+		ms.logger().Warn("run log upload failed", "file", path, "error", err)
+		return
+	}
+	key := cfg.Prefix + filepath.Base(path)
+	contentType := "application/x-ndjson"
+	if strings.HasSuffix(path, ".gz") {
+		contentType = "application/gzip"
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), s3UploadTimeout)
+	defer cancel()
+	if err := s3PutObject(ctx, cfg, key, body, contentType, time.Now()); err != nil {
+		ms.logger().Warn("run log upload failed", "file", path, "key", key, "error", err)
+		return
+	}
+	if err := ms.db.SetLogObjectKey(path, key); err != nil {
+		ms.logger().Warn("run log upload not recorded", "file", path, "key", key, "error", err)
+		return
+	}
+	if cfg.DeleteLocal {
+		_ = os.Remove(path)
+	}
+}
+
+// s3PutObject stores body at key in the configured bucket.
+func s3PutObject(ctx context.Context, cfg s3Config, key string, body []byte, contentType string, now time.Time) error {
+	segments := strings.Split(key, "/")
+	for i, s := range segments {
+		segments[i] = s3URIEscape(s)
+	}
+	target := cfg.Endpoint + "/" + s3URIEscape(cfg.Bucket) + "/" + strings.Join(segments, "/")
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	sum := sha256.Sum256(body)
+	req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(sum[:]))
+	req.Header.Set("X-Amz-Date", now.UTC().Format("20060102T150405Z"))
+	s3SignRequest(req, cfg, []string{"host", "x-amz-content-sha256", "x-amz-date"}, now)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("object storage returned %d: %s", resp.StatusCode, strings.TrimSpace(string(detail)))
+	}
+	return nil
+}
+
+// s3SignRequest sets the AWS Signature Version 4 Authorization header of req over the
+// given lower-case header names. The payload hash is read from X-Amz-Content-Sha256.
+func s3SignRequest(req *http.Request, cfg s3Config, signed []string, now time.Time) {
+	sort.Strings(signed)
+	var headers strings.Builder
+	for _, name := range signed {
+		value := req.Header.Get(name)
+		if name == "host" {
+			value = req.URL.Host
+		}
+		headers.WriteString(name + ":" + strings.TrimSpace(value) + "\n")
+	}
+	signedHeaders := strings.Join(signed, ";")
+	canonical := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		headers.String(),
+		signedHeaders,
+		req.Header.Get("X-Amz-Content-Sha256"),
+	}, "\n")
+
+	date := now.UTC().Format("20060102")
+	scope := date + "/" + cfg.Region + "/s3/aws4_request"
+	canonicalSum := sha256.Sum256([]byte(canonical))
+	toSign := "AWS4-HMAC-SHA256\n" + now.UTC().Format("20060102T150405Z") + "\n" + scope + "\n" + hex.EncodeToString(canonicalSum[:])
+
+	key := []byte("AWS4" + cfg.SecretAccessKey)
+	for _, part := range []string{date, cfg.Region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+cfg.AccessKeyID+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+hex.EncodeToString(hmacSHA256(key, toSign)))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// s3URIEscape percent-encodes s as SigV4 expects: everything but unreserved characters.
+func s3URIEscape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '.' || c == '_' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,102 @@
+package app
+
+import (
+	"net/http"
+	"sort"
+)
+
+// LogUsage is the disk usage of the run logs of one target.
+type LogUsage struct {
+	TargetID   int     `json:"target_id"`
+	TargetName string  `json:"target_name"`
+	Deleted    bool    `json:"deleted"`
+	Files      int     `json:"files"`
+	Bytes      int64   `json:"bytes"`
+	OldestAt   float64 `json:"oldest_at"`
+	NewestAt   float64 `json:"newest_at"`
+	// MaxAgeDays is the age limit applied to these logs; 0 means none.
+	MaxAgeDays int `json:"max_age_days"`
+}
+
+// logUsageByTarget groups the finished run logs by target, largest first. Logs whose
+// target no longer exists are reported under their target id with an empty name.
+func (h *Handlers) logUsageByTarget() ([]LogUsage, error) {
+	logs, err := h.monitor.listRunLogs()
+	if err != nil {
+		return nil, err
+	}
+	targets, err := h.db.ListTargets()
+	if err != nil {
+		return nil, err
+	}
+	trashed, err := h.db.ListDeletedTargets()
+	if err != nil {
+		return nil, err
+	}
+	globalDays := h.monitor.LogMaxAgeDays()
+
+	byID := map[int]*LogUsage{}
+	for _, list := range [][]Target{targets, trashed} {
+		for _, t := range list {
+			days := globalDays
+			if t.LogMaxAgeDays > 0 {
+				days = t.LogMaxAgeDays
+			}
+			byID[t.ID] = &LogUsage{TargetID: t.ID, TargetName: t.Name, Deleted: t.DeletedAt != nil, MaxAgeDays: days}
+		}
+	}
+	for _, l := range logs {
+		u := byID[l.targetID]
+		if u == nil {
+			u = &LogUsage{TargetID: l.targetID, MaxAgeDays: globalDays}
+			byID[l.targetID] = u
+		}
+		at := float64(l.mtime.UnixMilli()) / 1000.0
+		if u.Files == 0 || at < u.OldestAt {
+			u.OldestAt = at
+		}
+		if at > u.NewestAt {
+			u.NewestAt = at
+		}
+		u.Files++
+		u.Bytes += l.size
+	}
+
+	items := []LogUsage{}
+	for _, u := range byID {
+		if u.Files > 0 {
+			items = append(items, *u)
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Bytes != items[j].Bytes {
+			return items[i].Bytes > items[j].Bytes
+		}
+		return items[i].TargetID < items[j].TargetID
+	})
+	return items, nil
+}
+
+// AdminGetLogUsage -- GET /api/admin/logs/usage
+func (h *Handlers) AdminGetLogUsage(w http.ResponseWriter, r *http.Request) {
+	items, err := h.logUsageByTarget()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return
+	}
+	var files int
+	var bytes int64
+	for _, u := range items {
+		files += u.Files
+		bytes += u.Bytes
+	}
+	enabled, maxMB := h.monitor.LogCleanupConfig()
+	writeJSON(w, http.StatusOK, map[string]any{
+		"items":               items,
+		"files":               files,
+		"bytes":               bytes,
+		"log_cleanup_enabled": enabled,
+		"log_max_size_mb":     maxMB,
+		"log_max_age_days":    h.monitor.LogMaxAgeDays(),
+	})
+}
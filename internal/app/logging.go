@@ -0,0 +1,61 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// newLogger builds a logger writing to w. level is debug, info, warn or error
+// (default info); format is text or json (default text).
+func newLogger(w io.Writer, level, format string) (*slog.Logger, error) {
+	var lvl slog.Level
+	switch strings.ToLower(strings.TrimSpace(level)) {
+	case "", "info":
+		lvl = slog.LevelInfo
+	case "debug":
+		lvl = slog.LevelDebug
+	case "warn", "warning":
+		lvl = slog.LevelWarn
+	case "error":
+		lvl = slog.LevelError
+	default:
+		return nil, fmt.Errorf("LOG_LEVEL must be one of debug, info, warn, error")
+	}
+	opts := &slog.HandlerOptions{Level: lvl}
+	switch strings.ToLower(strings.TrimSpace(format)) {
+	case "", "text":
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	}
+	return nil, fmt.Errorf("LOG_FORMAT must be text or json")
+}
+
+// configureLogging installs the process-wide logger from LOG_LEVEL and LOG_FORMAT.
+// The standard log package is routed through it as well. Invalid values fall back
+// to the defaults with a warning.
+func configureLogging() {
+	logger, err := newLogger(os.Stderr, os.Getenv("LOG_LEVEL"), os.Getenv("LOG_FORMAT"))
+	if err != nil {
+		logger, _ = newLogger(os.Stderr, "", "")
+		logger.Warn("invalid logging configuration, using defaults", "component", "main", "error", err)
+	}
+	slog.SetDefault(logger)
+}
+
+// componentLogger returns l (or the default logger) tagged with component.
+func componentLogger(l *slog.Logger, component string) *slog.Logger {
+	if l == nil {
+		l = slog.Default()
+	}
+	return l.With("component", component)
+}
+
+// fatal logs msg at error level and exits the process.
+func fatal(l *slog.Logger, msg string, args ...any) {
+	l.Error(msg, args...)
+	os.Exit(1)
+}
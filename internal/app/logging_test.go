@@ -0,0 +1,57 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNewLogger_LevelAndFormat(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := newLogger(&buf, "warn", "json")
+	if err != nil {
+		t.Fatalf("newLogger failed: %v", err)
+	}
+	logger.Info("hidden")
+	logger.Warn("shown", "target_id", 7)
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("info should be filtered at warn level, got=%q", buf.String())
+	}
+	var entry map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatalf("json format should emit JSON, got=%q", lines[0])
+	}
+	if entry["msg"] != "shown" || entry["target_id"] != float64(7) {
+		t.Fatalf("entry should carry msg and fields, got=%v", entry)
+	}
+	if _, err := newLogger(&buf, "loud", ""); err == nil {
+		t.Fatalf("unknown level should be rejected")
+	}
+	if _, err := newLogger(&buf, "", "xml"); err == nil {
+		t.Fatalf("unknown format should be rejected")
+	}
+}
+
+func TestMonitorService_UsesInjectedLogger(t *testing.T) {
+	db, err := openDatabase(filepath.Join(t.TempDir(), "registry.db"))
+	if err != nil {
+		t.Fatalf("openDatabase failed: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	var buf bytes.Buffer
+	logger, _ := newLogger(&buf, "debug", "json")
+	ms := NewMonitorService(MonitorConfig{DB: db, LogDir: t.TempDir(), Logger: logger})
+	if err := ms.ReloadRouteRules(); err != nil {
+		t.Fatalf("ReloadRouteRules failed: %v", err)
+	}
+	var entry map[string]any
+	if err := json.Unmarshal(bytes.TrimSpace(buf.Bytes()), &entry); err != nil {
+		t.Fatalf("injected logger should capture the entry, got=%q", buf.String())
+	}
+	if entry["component"] != "monitor" || entry["msg"] != "route rules loaded" {
+		t.Fatalf("entry should be tagged component=monitor, got=%v", entry)
+	}
+}
@@ -0,0 +1,76 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Long-context probe. The long_context capability sends a filler prompt of about
+// context_tokens tokens (model_overrides, default defaultLongContextTokens) with a random
+// passcode in the middle and asks for it back, which verifies the advertised context
+// window end to end. Its latency is kept with the verdict in model_capabilities, never in
+// run_models, so the large request does not skew the regular duration statistics.
+// Sizes are estimated at about 4 characters per token.
+
+const (
+	defaultLongContextTokens = 32000
+	minLongContextTokens     = 1000
+	maxLongContextTokens     = 1000000
+	// longContextTimeout is the minimum timeout of the probe; model_overrides timeout_s
+	// still takes precedence.
+	longContextTimeout = 180 * time.Second
+)
+
+const longContextFiller = "The grass is green. The sky is blue. The sun is yellow. Here we go. There and back again. "
+
+// longContextPrompt builds a prompt of about tokens tokens hiding needle halfway through.
+func longContextPrompt(tokens int, needle string) string {
+	question := "\n\nWhat is the secret passcode mentioned in the text above? Reply with the passcode only."
+	fact := " The secret passcode is " + needle + ". Remember it. "
+	size := tokens*4 - len(question) - len(fact)
+	var b strings.Builder
+	b.Grow(tokens*4 + len(longContextFiller))
+	for b.Len() < size/2 {
+		b.WriteString(longContextFiller)
+	}
+	b.WriteString(fact)
+	for b.Len() < size+len(fact) {
+		b.WriteString(longContextFiller)
+	}
+	b.WriteString(question)
+	return b.String()
+}
+
+// probeLongContext sends the long_context probe on route.
+func probeLongContext(ctx context.Context, target *Target, modelID, route string, client *http.Client) *CapabilityResult {
+	tokens := defaultLongContextTokens
+	override := target.ModelOverrides[modelID]
+	if override.ContextTokens != nil {
+		tokens = *override.ContextTokens
+	}
+	needle := fmt.Sprintf("%06d", rand.IntN(1000000))
+	req := textCapabilityRequest(target, modelID, route, []probeTurn{{Role: "user", Text: longContextPrompt(tokens, needle)}}, 20)
+	if req == nil {
+		return nil
+	}
+	c := *client
+	if override.TimeoutS != nil {
+		c.Timeout = time.Duration(*override.TimeoutS * float64(time.Second))
+	} else if c.Timeout < longContextTimeout {
+		c.Timeout = longContextTimeout
+	}
+	res := req.send(ctx, &c, capabilityLongContext, func(text string) error {
+		if !strings.Contains(text, needle) {
+			return fmt.Errorf("long_context: passcode not found in the reply at ~%d tokens: %s", tokens, truncStr(text, 120))
+		}
+		return nil
+	})
+	if res != nil && res.Supported {
+		res.Detail = fmt.Sprintf("~%d tokens", tokens)
+	}
+	return res
+}
@@ -0,0 +1,69 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestLongContextProbeFindsTheNeedle(t *testing.T) {
+	limit := 1 << 20
+	var promptLen int
+	passcode := regexp.MustCompile(`passcode is (\d{6})\.`)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Messages []struct {
+				Content string `json:"content"`
+			} `json:"messages"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		prompt := body.Messages[0].Content
+		promptLen = len(prompt)
+		if promptLen > limit {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":{"message":"context length exceeded"}}`))
+			return
+		}
+		m := passcode.FindStringSubmatch(prompt)
+		if m == nil {
+			t.Errorf("prompt has no passcode")
+			return
+		}
+		_, _ = w.Write([]byte(`{"choices":[{"message":{"content":"` + m[1] + `"}}]}`))
+	}))
+	defer srv.Close()
+
+	tokens := 2000
+	target := &Target{ID: 1, BaseURL: srv.URL, APIKey: "k", ModelOverrides: map[string]ModelOverride{"gpt-x": {ContextTokens: &tokens}}}
+	client := httpClient(5, false, nil, tlsFingerprintNone)
+
+	res := probeLongContext(context.Background(), target, "gpt-x", "chat", client)
+	if res == nil || !res.Supported || res.Detail != "~2000 tokens" {
+		t.Fatalf("the needle should be found, got %+v", res)
+	}
+	if promptLen < tokens*4-100 || promptLen > tokens*4+100 {
+		t.Fatalf("prompt should be about %d chars, got %d", tokens*4, promptLen)
+	}
+
+	limit = 4000
+	res = probeLongContext(context.Background(), target, "gpt-x", "chat", client)
+	if res == nil || res.Supported || !strings.Contains(res.Detail, "context length exceeded") {
+		t.Fatalf("a rejected prompt should fail the probe, got %+v", res)
+	}
+}
+
+func TestValidateModelOverridesContextTokens(t *testing.T) {
+	if err := validateModelOverrides(map[string]any{"m": map[string]any{"context_tokens": float64(128000)}}); err != nil {
+		t.Fatalf("context_tokens should be accepted: %v", err)
+	}
+	if err := validateModelOverrides(map[string]any{"m": map[string]any{"context_tokens": float64(10)}}); err == nil {
+		t.Fatalf("context_tokens below %d should be rejected", minLongContextTokens)
+	}
+	if got := modelOverridesFromAny(map[string]any{"m": map[string]any{"context_tokens": 64000}}); got["m"].ContextTokens == nil {
+		t.Fatalf("context_tokens-only overrides should be kept, got %+v", got)
+	}
+}
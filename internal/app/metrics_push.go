@@ -0,0 +1,434 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Metrics push. With METRICS_PUSH set to "influxdb", "statsd" or "dogstatsd", a point is
+// recorded for every detection result (<prefix>_probe: count, success, duration_ms and
+// the streaming ttft_ms / tokens_per_sec / output_tokens, tagged with target, protocol,
+// model and route) and every finished run (<prefix>_run: count, models, success, fail,
+// tagged with target and status), and the buffered points are pushed every
+// METRICS_PUSH_INTERVAL seconds (default 10). InfluxDB points are written as line
+// protocol to METRICS_PUSH_URL, which is the full write URL (InfluxDB 2 /api/v2/write,
+// InfluxDB 1 /write or a Telegraf http_listener_v2); METRICS_PUSH_TOKEN is sent as
+// "Authorization: Token". StatsD points go over UDP to the host:port in
+// METRICS_PUSH_URL; plain statsd folds the tags into the metric name while dogstatsd
+// sends them as DogStatsD tags. METRICS_PUSH_PREFIX defaults to "api_monitor" and
+// METRICS_PUSH_TAGS (k1=v1,k2=v2) adds tags to every point. Pushing never blocks a run:
+// when the buffer is full, points are dropped and counted.
+
+const (
+	metricsPushInfluxDB  = "influxdb"
+	metricsPushStatsD    = "statsd"
+	metricsPushDogStatsD = "dogstatsd"
+
+	metricsPushQueueSize       = 8192
+	metricsPushMaxBuffered     = 50000
+	metricsPushDefaultInterval = 10
+	metricsPushStatsDPacket    = 1432
+
+	defaultMetricsPushPrefix = "api_monitor"
+)
+
+type metricField struct {
+	key   string
+	value float64
+	// statsdType is the StatsD metric type: "c", "g" or "ms".
+	statsdType string
+}
+
+type metricPoint struct {
+	name   string
+	tags   map[string]string
+	fields []metricField
+	at     time.Time
+}
+
+type metricsPusher struct {
+	kind     string
+	endpoint string
+	token    string
+	prefix   string
+	tags     map[string]string
+	interval time.Duration
+	client   *http.Client
+	conn     net.Conn
+	log      *slog.Logger
+
+	queue   chan metricPoint
+	stop    chan struct{}
+	done    chan struct{}
+	dropped atomic.Int64
+}
+
+var metricsPusherInstance atomic.Pointer[metricsPusher]
+
+// metricsPusherFromEnv builds the pusher configured by the METRICS_PUSH_* environment, or
+// returns nil when METRICS_PUSH is unset.
+func metricsPusherFromEnv() (*metricsPusher, error) {
+	kind := strings.ToLower(strings.TrimSpace(os.Getenv("METRICS_PUSH")))
+	if kind == "" {
+		return nil, nil
+	}
+	if kind != metricsPushInfluxDB && kind != metricsPushStatsD && kind != metricsPushDogStatsD {
+		return nil, fmt.Errorf("METRICS_PUSH must be influxdb, statsd or dogstatsd, got %q", kind)
+	}
+	tags, err := parseKeyValueList("METRICS_PUSH_TAGS", os.Getenv("METRICS_PUSH_TAGS"))
+	if err != nil {
+		return nil, err
+	}
+	interval := parseIntString(os.Getenv("METRICS_PUSH_INTERVAL"), metricsPushDefaultInterval)
+	if interval < 1 || interval > 3600 {
+		return nil, fmt.Errorf("METRICS_PUSH_INTERVAL must be 1-3600 seconds, got %d", interval)
+	}
+	p := &metricsPusher{
+		kind:     kind,
+		token:    strings.TrimSpace(os.Getenv("METRICS_PUSH_TOKEN")),
+		prefix:   strings.TrimSpace(os.Getenv("METRICS_PUSH_PREFIX")),
+		tags:     tags,
+		interval: time.Duration(interval) * time.Second,
+	}
+	if p.prefix == "" {
+		p.prefix = defaultMetricsPushPrefix
+	}
+	if strings.ContainsAny(p.prefix, " ,=:|#\t\r\n") {
+		return nil, fmt.Errorf("METRICS_PUSH_PREFIX must be a plain metric name, got %q", p.prefix)
+	}
+	raw := strings.TrimSpace(os.Getenv("METRICS_PUSH_URL"))
+	if kind == metricsPushInfluxDB {
+		u, err := url.Parse(raw)
+		if raw == "" || err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("METRICS_PUSH_URL must be the http(s) write URL of InfluxDB, got %q", raw)
+		}
+		p.endpoint = raw
+		p.client = &http.Client{Timeout: 10 * time.Second}
+		return p, nil
+	}
+	if raw == "" {
+		raw = "127.0.0.1:8125"
+	}
+	raw = strings.TrimPrefix(raw, "udp://")
+	if _, port, err := net.SplitHostPort(raw); err != nil || port == "" {
+		return nil, fmt.Errorf("METRICS_PUSH_URL must be the host:port of the StatsD server, got %q", raw)
+	}
+	p.endpoint = raw
+	return p, nil
+}
+
+// startMetricsPusher installs the pusher configured by the environment and returns a
+// shutdown function that pushes pending points. It is a no-op when METRICS_PUSH is unset.
+func startMetricsPusher(logger *slog.Logger) (func(context.Context), error) {
+	p, err := metricsPusherFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return func(context.Context) {}, nil
+	}
+	if err := p.start(logger); err != nil {
+		return nil, err
+	}
+	p.log.Info("metrics push enabled", "format", p.kind, "endpoint", p.endpoint, "interval", p.interval)
+	return p.shutdown, nil
+}
+
+func (p *metricsPusher) start(logger *slog.Logger) error {
+	p.log = componentLogger(logger, "metrics_push")
+	if p.kind != metricsPushInfluxDB {
+		// UDP "connections" only resolve the address; an unreachable server is not an error.
+		conn, err := net.Dial("udp", p.endpoint)
+		if err != nil {
+			return fmt.Errorf("METRICS_PUSH_URL: %w", err)
+		}
+		p.conn = conn
+	}
+	p.queue = make(chan metricPoint, metricsPushQueueSize)
+	p.stop = make(chan struct{})
+	p.done = make(chan struct{})
+	go p.loop()
+	metricsPusherInstance.Store(p)
+	return nil
+}
+
+func recordMetricPoint(pt metricPoint) {
+	if p := metricsPusherInstance.Load(); p != nil {
+		select {
+		case p.queue <- pt:
+		default:
+			p.dropped.Add(1)
+		}
+	}
+}
+
+// pushProbeMetrics records the metrics of one detection result.
+func pushProbeMetrics(row runLogRow) {
+	if metricsPusherInstance.Load() == nil {
+		return
+	}
+	success := 0.0
+	if row.Success {
+		success = 1
+	}
+	fields := []metricField{
+		{key: "count", value: 1, statsdType: "c"},
+		{key: "success", value: success, statsdType: "c"},
+		{key: "duration_ms", value: row.Duration * 1000, statsdType: "ms"},
+	}
+	if row.TTFTMs != nil {
+		fields = append(fields, metricField{key: "ttft_ms", value: *row.TTFTMs, statsdType: "ms"})
+	}
+	if row.TokensPerSec != nil {
+		fields = append(fields, metricField{key: "tokens_per_sec", value: *row.TokensPerSec, statsdType: "g"})
+	}
+	if row.OutputTokens != nil {
+		fields = append(fields, metricField{key: "output_tokens", value: float64(*row.OutputTokens), statsdType: "g"})
+	}
+	recordMetricPoint(metricPoint{
+		name: "probe",
+		tags: map[string]string{
+			"target":   row.TargetName,
+			"protocol": row.Protocol,
+			"model":    row.Model,
+			"route":    row.Route,
+		},
+		fields: fields,
+		at:     resultTime(row),
+	})
+}
+
+// pushRunMetrics records the metrics of a run from its run_completed event.
+func pushRunMetrics(event, data string) {
+	if event != "run_completed" || metricsPusherInstance.Load() == nil {
+		return
+	}
+	var payload map[string]any
+	if json.Unmarshal([]byte(data), &payload) != nil {
+		return
+	}
+	total, _ := anyFloat(payload["total"])
+	success, _ := anyFloat(payload["success"])
+	fail, _ := anyFloat(payload["fail"])
+	recordMetricPoint(metricPoint{
+		name: "run",
+		tags: map[string]string{
+			"target": stringFromAny(payload["target_name"], ""),
+			"status": stringFromAny(payload["status"], ""),
+		},
+		fields: []metricField{
+			{key: "count", value: 1, statsdType: "c"},
+			{key: "models", value: total, statsdType: "g"},
+			{key: "success", value: success, statsdType: "g"},
+			{key: "fail", value: fail, statsdType: "g"},
+		},
+		at: time.Now(),
+	})
+}
+
+func (p *metricsPusher) loop() {
+	defer close(p.done)
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	var buffered []metricPoint
+	flush := func() {
+		if len(buffered) == 0 {
+			return
+		}
+		if err := p.push(buffered); err != nil {
+			p.log.Warn("push metrics failed", "format", p.kind, "points", len(buffered), "error", err)
+		}
+		buffered = nil
+	}
+	add := func(pt metricPoint) {
+		if len(buffered) >= metricsPushMaxBuffered {
+			p.dropped.Add(1)
+			return
+		}
+		buffered = append(buffered, pt)
+	}
+	for {
+		select {
+		case pt := <-p.queue:
+			add(pt)
+		case <-ticker.C:
+			flush()
+		case <-p.stop:
+			for {
+				select {
+				case pt := <-p.queue:
+					add(pt)
+				default:
+					flush()
+					if p.conn != nil {
+						_ = p.conn.Close()
+					}
+					return
+				}
+			}
+		}
+	}
+}
+
+func (p *metricsPusher) shutdown(ctx context.Context) {
+	metricsPusherInstance.CompareAndSwap(p, nil)
+	close(p.stop)
+	select {
+	case <-p.done:
+	case <-ctx.Done():
+	}
+	if n := p.dropped.Load(); n > 0 {
+		p.log.Warn("metric points dropped because the buffer was full", "dropped", n)
+	}
+}
+
+// pointTags merges the global tags into pt's, dropping empty values, sorted by key.
+func (p *metricsPusher) pointTags(pt metricPoint) [][2]string {
+	merged := map[string]string{}
+	for k, v := range p.tags {
+		merged[k] = v
+	}
+	for k, v := range pt.tags {
+		merged[k] = v
+	}
+	out := make([][2]string, 0, len(merged))
+	for k, v := range merged {
+		if v != "" {
+			out = append(out, [2]string{k, v})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i][0] < out[j][0] })
+	return out
+}
+
+func (p *metricsPusher) push(points []metricPoint) error {
+	if p.kind == metricsPushInfluxDB {
+		return p.pushInflux(points)
+	}
+	var packet []byte
+	for _, pt := range points {
+		for _, line := range p.statsdLines(pt) {
+			if len(packet) > 0 && len(packet)+1+len(line) > metricsPushStatsDPacket {
+				if _, err := p.conn.Write(packet); err != nil {
+					return err
+				}
+				packet = packet[:0]
+			}
+			if len(packet) > 0 {
+				packet = append(packet, '\n')
+			}
+			packet = append(packet, line...)
+		}
+	}
+	if len(packet) > 0 {
+		_, err := p.conn.Write(packet)
+		return err
+	}
+	return nil
+}
+
+var (
+	influxMeasurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `, "\n", `\n`)
+	influxTagEscaper         = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `, "\n", `\n`)
+)
+
+// influxLines renders points as InfluxDB line protocol with nanosecond timestamps.
+func (p *metricsPusher) influxLines(points []metricPoint) []byte {
+	var buf bytes.Buffer
+	for _, pt := range points {
+		buf.WriteString(influxMeasurementEscaper.Replace(p.prefix + "_" + pt.name))
+		for _, tag := range p.pointTags(pt) {
+			buf.WriteByte(',')
+			buf.WriteString(influxTagEscaper.Replace(tag[0]))
+			buf.WriteByte('=')
+			buf.WriteString(influxTagEscaper.Replace(tag[1]))
+		}
+		for i, f := range pt.fields {
+			if i == 0 {
+				buf.WriteByte(' ')
+			} else {
+				buf.WriteByte(',')
+			}
+			buf.WriteString(influxTagEscaper.Replace(f.key))
+			buf.WriteByte('=')
+			buf.WriteString(strconv.FormatFloat(f.value, 'f', -1, 64))
+		}
+		buf.WriteByte(' ')
+		buf.WriteString(strconv.FormatInt(pt.at.UnixNano(), 10))
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
+
+func (p *metricsPusher) pushInflux(points []metricPoint) error {
+	req, err := http.NewRequest(http.MethodPost, p.endpoint, bytes.NewReader(p.influxLines(points)))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if p.token != "" {
+		req.Header.Set("Authorization", "Token "+p.token)
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("influxdb returned HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody[:min(len(respBody), 512)])))
+	}
+	return nil
+}
+
+// statsdName makes s safe as a StatsD metric name segment or DogStatsD tag value.
+func statsdName(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ':', '|', '@', '#', ',', ' ', '\t', '\r', '\n':
+			return '_'
+		}
+		return r
+	}, s)
+}
+
+// statsdLines renders pt as StatsD lines, one per field.
+func (p *metricsPusher) statsdLines(pt metricPoint) []string {
+	tags := p.pointTags(pt)
+	name := p.prefix + "." + pt.name
+	suffix := ""
+	if p.kind == metricsPushDogStatsD {
+		parts := make([]string, 0, len(tags))
+		for _, tag := range tags {
+			parts = append(parts, statsdName(tag[0])+":"+statsdName(tag[1]))
+		}
+		if len(parts) > 0 {
+			suffix = "|#" + strings.Join(parts, ",")
+		}
+	} else {
+		for _, tag := range tags {
+			name += "." + strings.ReplaceAll(statsdName(tag[1]), ".", "_")
+		}
+	}
+	lines := make([]string, 0, len(pt.fields))
+	for _, f := range pt.fields {
+		if f.statsdType == "c" && f.value == 0 {
+			continue
+		}
+		lines = append(lines, name+"."+f.key+":"+strconv.FormatFloat(f.value, 'f', -1, 64)+"|"+f.statsdType+suffix)
+	}
+	return lines
+}
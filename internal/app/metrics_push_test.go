@@ -0,0 +1,102 @@
+package app
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetricsPushWritesInfluxLineProtocol(t *testing.T) {
+	got := make(chan string, 4)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got <- r.Header.Get("Authorization") + "\n" + string(body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+	t.Setenv("METRICS_PUSH", "influxdb")
+	t.Setenv("METRICS_PUSH_URL", srv.URL+"/api/v2/write?org=o&bucket=b")
+	t.Setenv("METRICS_PUSH_TOKEN", "tok")
+	t.Setenv("METRICS_PUSH_TAGS", "env=lab")
+	p, err := metricsPusherFromEnv()
+	if err != nil {
+		t.Fatalf("metricsPusherFromEnv failed: %v", err)
+	}
+	if err := p.start(nil); err != nil {
+		t.Fatal(err)
+	}
+	ttft := 120.5
+	pushProbeMetrics(runLogRow{
+		DetectionResult: DetectionResult{Protocol: "openai", Model: "gpt 4o", Duration: 1.5, Success: true, Timestamp: 1700000000, TTFTMs: &ttft},
+		TargetName:      "relay,a",
+	})
+	pushRunMetrics("model_checked", `{"target_name":"relay"}`)
+	pushRunMetrics("run_completed", `{"target_name":"relay","status":"degraded","total":3,"success":2,"fail":1}`)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	p.shutdown(ctx)
+
+	body := <-got
+	lines := strings.Split(strings.TrimSpace(body), "\n")
+	if len(lines) != 3 || lines[0] != "Token tok" {
+		t.Fatalf("expected the token and two points, got:\n%s", body)
+	}
+	if lines[1] != `api_monitor_probe,env=lab,model=gpt\ 4o,protocol=openai,target=relay\,a count=1,success=1,duration_ms=1500,ttft_ms=120.5 1700000000000000000` {
+		t.Fatalf("unexpected probe line: %s", lines[1])
+	}
+	if !strings.HasPrefix(lines[2], "api_monitor_run,env=lab,status=degraded,target=relay count=1,models=3,success=2,fail=1 ") {
+		t.Fatalf("unexpected run line: %s", lines[2])
+	}
+}
+
+func TestMetricsPushSendsStatsD(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	for _, tc := range []struct{ kind, want string }{
+		{"statsd", "mon.run.degraded.relay.count:1|c\nmon.run.degraded.relay.models:3|g\nmon.run.degraded.relay.success:2|g\nmon.run.degraded.relay.fail:1|g"},
+		{"dogstatsd", "mon.run.count:1|c|#status:degraded,target:relay\nmon.run.models:3|g|#status:degraded,target:relay\nmon.run.success:2|g|#status:degraded,target:relay\nmon.run.fail:1|g|#status:degraded,target:relay"},
+	} {
+		t.Setenv("METRICS_PUSH", tc.kind)
+		t.Setenv("METRICS_PUSH_URL", "udp://"+conn.LocalAddr().String())
+		t.Setenv("METRICS_PUSH_PREFIX", "mon")
+		p, err := metricsPusherFromEnv()
+		if err != nil {
+			t.Fatalf("metricsPusherFromEnv failed: %v", err)
+		}
+		if err := p.start(nil); err != nil {
+			t.Fatal(err)
+		}
+		pushRunMetrics("run_completed", `{"target_name":"relay","status":"degraded","total":3,"success":2,"fail":1}`)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		p.shutdown(ctx)
+		cancel()
+
+		buf := make([]byte, 2048)
+		_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("%s: read packet: %v", tc.kind, err)
+		}
+		if string(buf[:n]) != tc.want {
+			t.Fatalf("%s: unexpected packet:\n%s", tc.kind, buf[:n])
+		}
+	}
+}
+
+func TestMetricsPushValidation(t *testing.T) {
+	for _, env := range [][2]string{{"graphite", ""}, {"influxdb", "influx:8086"}, {"statsd", "no-port"}} {
+		t.Setenv("METRICS_PUSH", env[0])
+		t.Setenv("METRICS_PUSH_URL", env[1])
+		if _, err := metricsPusherFromEnv(); err == nil {
+			t.Fatalf("METRICS_PUSH=%s METRICS_PUSH_URL=%s should be rejected", env[0], env[1])
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package app
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Model identity check. Resellers commonly answer requests for a premium model with a
+// cheaper one (gpt-4o served by gpt-4o-mini). Every successful probe compares the model
+// id echoed in the response (model, or modelVersion on Gemini; the first chunk naming
+// one when streaming) with the requested id and flags a mismatch. A mismatched row still
+// counts as a success for the run; it is reported as its own status in model status and
+// on the dashboard. Responses that echo no model id are not judged.
+
+// modelVersionSuffix matches the version or snapshot suffixes providers append to the
+// requested id (-2024-08-06, -20241022, -002, @001).
+var modelVersionSuffix = regexp.MustCompile(`^[-@:]\d[\d.-]*$`)
+
+// servedModel returns the model id echoed by a response body or stream chunk.
+func servedModel(body any) string {
+	m, ok := body.(map[string]any)
+	if !ok {
+		return ""
+	}
+	for _, obj := range []map[string]any{m, nestedMap(m, "message"), nestedMap(m, "response")} {
+		for _, key := range []string{"model", "modelVersion"} {
+			if s, ok := obj[key].(string); ok && strings.TrimSpace(s) != "" {
+				return strings.TrimSpace(s)
+			}
+		}
+	}
+	return ""
+}
+
+// normalizeModelID lowercases id and drops a provider or "models/" prefix and a
+// "-latest" alias suffix.
+func normalizeModelID(id string) string {
+	id = strings.ToLower(strings.TrimSpace(id))
+	if i := strings.LastIndex(id, "/"); i >= 0 {
+		id = id[i+1:]
+	}
+	return strings.TrimSuffix(id, "-latest")
+}
+
+// modelIdentityMatches reports whether served is the requested model, allowing either
+// side to carry a version suffix the other lacks.
+func modelIdentityMatches(requested, served string) bool {
+	a, b := normalizeModelID(requested), normalizeModelID(served)
+	if a == b {
+		return true
+	}
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	return a != "" && strings.HasPrefix(b, a) && modelVersionSuffix.MatchString(b[len(a):])
+}
+
+// checkServedModel records the served model on a detection row and flags a mismatch
+// on successful rows.
+func checkServedModel(row *DetectionResult, requested, served string) {
+	row.ServedModel = served
+	row.Mismatch = row.Success && served != "" && !modelIdentityMatches(requested, served)
+}
@@ -0,0 +1,71 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestModelIdentityMatches(t *testing.T) {
+	for _, c := range []struct {
+		requested, served string
+		want              bool
+	}{
+		{"gpt-4o", "gpt-4o", true},
+		{"gpt-4o", "gpt-4o-2024-08-06", true},
+		{"gpt-4o-2024-08-06", "gpt-4o", true},
+		{"claude-3-5-sonnet-latest", "claude-3-5-sonnet-20241022", true},
+		{"gemini-1.5-flash", "models/gemini-1.5-flash-002", true},
+		{"openai/gpt-4o", "GPT-4o", true},
+		{"gpt-4o", "gpt-4o-mini", false},
+		{"gpt-4o", "gpt-4", false},
+		{"gpt-4", "gpt-4o", false},
+		{"claude-3-opus", "claude-3-haiku-20240307", false},
+	} {
+		if got := modelIdentityMatches(c.requested, c.served); got != c.want {
+			t.Errorf("modelIdentityMatches(%q, %q) = %v, want %v", c.requested, c.served, got, c.want)
+		}
+	}
+}
+
+func TestDetectOneFlagsServedModelMismatch(t *testing.T) {
+	served := "gpt-4o-mini"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept") == "text/event-stream" {
+			w.Header().Set("Content-Type", "text/event-stream")
+			_, _ = w.Write([]byte("data: {\"model\":\"" + served + "\",\"choices\":[{\"delta\":{\"content\":\"hi\"}}]}\n\ndata: [DONE]\n\n"))
+			return
+		}
+		_, _ = w.Write([]byte(`{"model":"` + served + `","choices":[{"message":{"content":"hi"}}]}`))
+	}))
+	defer srv.Close()
+
+	db, target := newAnalyticsTestDB(t)
+	target.BaseURL = srv.URL
+	ms := NewMonitorService(MonitorConfig{DB: db, LogDir: t.TempDir()})
+	client := httpClient(5, false, nil, tlsFingerprintNone)
+
+	row := ms.detectOne(context.Background(), target, "gpt-4o", "chat", client)
+	if !row.Success || !row.Mismatch || row.ServedModel != served {
+		t.Fatalf("gpt-4o served by gpt-4o-mini should be a successful mismatch, got %+v", row)
+	}
+	insertAnalyticsRows(t, db, target.ID, []DetectionResult{row})
+	statuses, err := db.GetLatestModelStatuses(target.ID)
+	if err != nil || len(statuses) != 1 || !statuses[0].Mismatch || statuses[0].ServedModel == nil || *statuses[0].ServedModel != served {
+		t.Fatalf("model status should report the mismatch, got %+v err=%v", statuses, err)
+	}
+
+	served = "gpt-4o-2024-08-06"
+	row = ms.detectOne(context.Background(), target, "gpt-4o", "chat", client)
+	if !row.Success || row.Mismatch {
+		t.Fatalf("a dated snapshot of the requested model is not a mismatch, got %+v", row)
+	}
+
+	served = "gpt-3.5-turbo"
+	target.StreamProbe = true
+	row = ms.detectOne(context.Background(), target, "gpt-4o", "chat", client)
+	if !row.Stream || !row.Mismatch || row.ServedModel != served {
+		t.Fatalf("streamed replies should be checked too, got %+v", row)
+	}
+}
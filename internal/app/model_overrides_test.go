@@ -0,0 +1,95 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestModelOverridesApplyPerModel(t *testing.T) {
+	var mu sync.Mutex
+	bodies := map[string]map[string]any{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		model, _ := body["model"].(string)
+		mu.Lock()
+		bodies[model+" "+r.URL.Path] = body
+		mu.Unlock()
+		if strings.HasPrefix(model, "gpt-slow") {
+			time.Sleep(300 * time.Millisecond)
+		}
+		if r.URL.Path == "/v1/responses" {
+			_, _ = w.Write([]byte(`{"output":[{"type":"message","content":[{"type":"output_text","text":"ok"}]}]}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"choices":[{"message":{"content":"ok"}}]}`))
+	}))
+	defer srv.Close()
+
+	timeout, maxTokens := 0.05, 123
+	target := &Target{ID: 1, BaseURL: srv.URL, APIKey: "k", Prompt: "hi", ModelOverrides: map[string]ModelOverride{
+		"gpt-big":  {MaxTokens: &maxTokens},
+		"gpt-slow": {TimeoutS: &timeout},
+	}}
+	ms := &MonitorService{}
+	client := httpClient(5, false, nil, tlsFingerprintNone)
+	ctx := context.Background()
+
+	for _, route := range []string{"chat", "responses"} {
+		if row := ms.detectOne(ctx, target, "gpt-big", route, client); !row.Success {
+			t.Fatalf("gpt-big on %s should succeed, got=%+v", route, row)
+		}
+		if row := ms.detectOne(ctx, target, "gpt-plain", route, client); !row.Success {
+			t.Fatalf("gpt-plain on %s should succeed, got=%+v", route, row)
+		}
+	}
+	if got := bodies["gpt-big /v1/chat/completions"]["max_tokens"]; got != float64(123) {
+		t.Fatalf("chat max_tokens should come from the override, got=%v", got)
+	}
+	if got := bodies["gpt-big /v1/responses"]["max_output_tokens"]; got != float64(123) {
+		t.Fatalf("responses max_output_tokens should come from the override, got=%v", got)
+	}
+	if got := bodies["gpt-plain /v1/chat/completions"]["max_tokens"]; got != float64(50) {
+		t.Fatalf("models without an override should keep the default max_tokens, got=%v", got)
+	}
+	if _, ok := bodies["gpt-plain /v1/responses"]["max_output_tokens"]; ok {
+		t.Fatalf("models without an override should not send max_output_tokens, got=%v", bodies["gpt-plain /v1/responses"])
+	}
+
+	if row := ms.detectOne(ctx, target, "gpt-slow", "chat", client); row.Success || row.TransportSuccess {
+		t.Fatalf("the override timeout should abort the slow model, got=%+v", row)
+	}
+	if row := ms.detectOne(ctx, target, "gpt-slow-2", "chat", client); !row.Success {
+		t.Fatalf("a slow model without an override should keep the target timeout, got=%+v", row)
+	}
+	if client.Timeout != 5*time.Second {
+		t.Fatalf("the override should not change the shared client, got=%v", client.Timeout)
+	}
+}
+
+func TestValidateModelOverrides(t *testing.T) {
+	ok := map[string]any{"gpt-4o": map[string]any{"timeout_s": float64(30), "max_tokens": float64(256)}}
+	if err := validateModelOverrides(ok); err != nil {
+		t.Fatalf("valid overrides should be accepted: %v", err)
+	}
+	for _, bad := range []any{
+		[]any{"gpt-4o"},
+		map[string]any{"": map[string]any{"timeout_s": float64(30)}},
+		map[string]any{"gpt-4o": "fast"},
+		map[string]any{"gpt-4o": map[string]any{"timeout_s": float64(1)}},
+		map[string]any{"gpt-4o": map[string]any{"timeout_s": float64(301)}},
+		map[string]any{"gpt-4o": map[string]any{"max_tokens": float64(0)}},
+		map[string]any{"gpt-4o": map[string]any{"max_tokens": float64(1.5)}},
+		map[string]any{"gpt-4o": map[string]any{"temperature": float64(1)}},
+	} {
+		if err := validateModelOverrides(bad); err == nil {
+			t.Fatalf("model_overrides %v should be rejected", bad)
+		}
+	}
+}
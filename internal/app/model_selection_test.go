@@ -6,13 +6,13 @@ func TestFilterModelsBySelection(t *testing.T) {
 	all := []string{"gpt-4o", "gpt-4.1", "claude-3-7", "gemini-2.5-pro"}
 
 	// empty selection means keep all
-	gotAll := filterModelsBySelection(all, nil)
+	gotAll := filterModelsBySelection(all, nil, nil, nil)
 	if len(gotAll) != len(all) {
 		t.Fatalf("empty selection should keep all models, got=%d want=%d", len(gotAll), len(all))
 	}
 
 	// keep upstream order, only selected members
-	got := filterModelsBySelection(all, []string{"gemini-2.5-pro", "gpt-4o"})
+	got := filterModelsBySelection(all, []string{"gemini-2.5-pro", "gpt-4o"}, nil, nil)
 	want := []string{"gpt-4o", "gemini-2.5-pro"}
 	if len(got) != len(want) {
 		t.Fatalf("unexpected filtered length: got=%d want=%d", len(got), len(want))
@@ -24,6 +24,39 @@ func TestFilterModelsBySelection(t *testing.T) {
 	}
 }
 
+func TestFilterModelsBySelection_Patterns(t *testing.T) {
+	all := []string{"gpt-4o", "gpt-4o-preview", "GPT-5", "claude-3-7", "org/gemini-2.5-pro"}
+
+	cases := []struct {
+		name     string
+		selected []string
+		include  []string
+		exclude  []string
+		want     []string
+	}{
+		{"exclude only keeps the rest", nil, nil, []string{"*-preview"}, []string{"gpt-4o", "GPT-5", "claude-3-7", "org/gemini-2.5-pro"}},
+		{"include glob is case-insensitive", nil, []string{"gpt-*"}, nil, []string{"gpt-4o", "gpt-4o-preview", "GPT-5"}},
+		{"include and exclude", nil, []string{"gpt-*"}, []string{"*-preview"}, []string{"gpt-4o", "GPT-5"}},
+		{"selected plus include", []string{"claude-3-7"}, []string{"*gemini*"}, nil, []string{"claude-3-7", "org/gemini-2.5-pro"}},
+		{"regex pattern", nil, []string{`re:^gpt-\d`}, nil, []string{"gpt-4o", "gpt-4o-preview"}},
+	}
+	for _, tc := range cases {
+		got := filterModelsBySelection(all, tc.selected, tc.include, tc.exclude)
+		if len(got) != len(tc.want) {
+			t.Fatalf("%s: got=%v want=%v", tc.name, got, tc.want)
+		}
+		for i := range tc.want {
+			if got[i] != tc.want[i] {
+				t.Fatalf("%s: got=%v want=%v", tc.name, got, tc.want)
+			}
+		}
+	}
+
+	if err := validateTargetPayload(map[string]any{"exclude_patterns": []any{"re:("}}); err == nil {
+		t.Fatalf("invalid regex pattern should fail validation")
+	}
+}
+
 func TestValidateTargetPayload_SelectedModels(t *testing.T) {
 	valid := map[string]any{
 		"selected_models": []any{"gpt-4o", "gemini-2.5-pro"},
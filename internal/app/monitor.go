@@ -2,19 +2,24 @@ package app
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"math"
 	"net"
 	"net/http"
+	"net/http/httptrace"
 	"net/url"
 	"os"
 	"path/filepath"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -27,6 +32,7 @@ import (
 // Route rules
 // ---------------------------------------------------------------------------
 
+// routeRules are the built-in fallbacks applied after admin-defined rules (see route_rules.go).
 var routeRules = []struct {
 	pattern *regexp.Regexp
 	route   string
@@ -47,6 +53,7 @@ type HttpResult struct {
 	Text       string
 	JSONBody   any
 	ElapsedMs  int
+	Timing     httpTiming
 }
 
 // ---------------------------------------------------------------------------
@@ -80,23 +87,69 @@ func authHeaders(apiKey string) map[string]string {
 	}
 }
 
-// utlsTransport wraps http.Transport to use uTLS for Chrome-like TLS fingerprinting.
+// targetHeaders returns the default detection headers merged with the target's extra_headers.
+// Extra headers win over defaults so gateways that expect e.g. a custom Authorization can be served.
+func targetHeaders(target *Target) map[string]string {
+	headers := authHeaders(target.APIKey)
+	for k, v := range target.ExtraHeaders {
+		headers[http.CanonicalHeaderKey(k)] = v
+	}
+	return headers
+}
+
+// TLS fingerprints selectable per target via tls_fingerprint.
+const (
+	tlsFingerprintChrome     = "chrome"
+	tlsFingerprintFirefox    = "firefox"
+	tlsFingerprintSafari     = "safari"
+	tlsFingerprintGolang     = "golang"
+	tlsFingerprintRandomized = "randomized"
+	// tlsFingerprintNone disables uTLS and uses the standard crypto/tls transport.
+	tlsFingerprintNone = "none"
+)
+
+var tlsFingerprintHellos = map[string]utls.ClientHelloID{
+	tlsFingerprintChrome:     utls.HelloChrome_Auto,
+	tlsFingerprintFirefox:    utls.HelloFirefox_Auto,
+	tlsFingerprintSafari:     utls.HelloSafari_Auto,
+	tlsFingerprintGolang:     utls.HelloGolang,
+	tlsFingerprintRandomized: utls.HelloRandomized,
+}
+
+func validTLSFingerprint(name string) bool {
+	if name == tlsFingerprintNone {
+		return true
+	}
+	_, ok := tlsFingerprintHellos[name]
+	return ok
+}
+
+// utlsTransport wraps http.Transport to use uTLS for browser-like TLS fingerprinting.
 type utlsTransport struct {
 	insecureSkipVerify bool
+	dialer             *outboundDialer
+	helloID            utls.ClientHelloID
+	certObserver       *tlsCertObserver
 }
 
 func (t *utlsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
 	host := req.URL.Hostname()
 	port := req.URL.Port()
-	if port == "" {
-		if req.URL.Scheme == "https" {
-			port = "443"
-		} else {
+	dialer := t.dialer
+	if dialer == nil {
+		dialer = newOutboundDialer(nil)
+	}
+	if req.URL.Scheme != "https" {
+		if dialer.proxyURL == nil {
 			return http.DefaultTransport.RoundTrip(req)
 		}
+		tr := &http.Transport{DialContext: dialer.DialContext, DisableKeepAlives: true}
+		return tr.RoundTrip(req)
+	}
+	if port == "" {
+		port = "443"
 	}
 
-	dialer := &net.Dialer{Timeout: 10 * time.Second}
 	conn, err := dialer.DialContext(req.Context(), "tcp", net.JoinHostPort(host, port))
 	if err != nil {
 		return nil, fmt.Errorf("dial: %w", err)
@@ -105,14 +158,30 @@ func (t *utlsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
 	tlsCfg := &utls.Config{
 		ServerName:         host,
 		InsecureSkipVerify: t.insecureSkipVerify,
+		NextProtos:         []string{"h2", "http/1.1"},
+	}
+	helloID := t.helloID
+	if helloID.Client == "" {
+		helloID = utls.HelloChrome_Auto
+	}
+	uConn := utls.UClient(conn, tlsCfg, helloID)
+	trace := httptrace.ContextClientTrace(req.Context())
+	if trace != nil && trace.TLSHandshakeStart != nil {
+		trace.TLSHandshakeStart()
 	}
-	uConn := utls.UClient(conn, tlsCfg, utls.HelloChrome_Auto)
-	if err := uConn.HandshakeContext(req.Context()); err != nil {
+	err = uConn.HandshakeContext(req.Context())
+	if trace != nil && trace.TLSHandshakeDone != nil {
+		// The uTLS state is a different type; hooks only rely on the timing and error.
+		trace.TLSHandshakeDone(tls.ConnectionState{}, err)
+	}
+	if err != nil {
 		conn.Close()
 		return nil, fmt.Errorf("tls handshake: %w", err)
 	}
 
-	alpn := uConn.ConnectionState().NegotiatedProtocol
+	state := uConn.ConnectionState()
+	t.certObserver.observe(state.PeerCertificates)
+	alpn := state.NegotiatedProtocol
 
 	if alpn == "h2" {
 		// Server negotiated HTTP/2, use h2 transport.
@@ -140,15 +209,44 @@ func (t *utlsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
 	return resp, nil
 }
 
-func httpClient(timeoutS float64, verifySSL bool) *http.Client {
+func httpClient(timeoutS float64, verifySSL bool, proxyURL *url.URL, fingerprint string) *http.Client {
+	dialer := newOutboundDialer(proxyURL)
+	var transport http.RoundTripper
+	if fingerprint == tlsFingerprintNone {
+		transport = &http.Transport{
+			DialContext:       dialer.DialContext,
+			TLSClientConfig:   &tls.Config{InsecureSkipVerify: !verifySSL},
+			ForceAttemptHTTP2: true,
+			DisableKeepAlives: true,
+		}
+	} else {
+		helloID, ok := tlsFingerprintHellos[fingerprint]
+		if !ok {
+			helloID = utls.HelloChrome_Auto
+		}
+		transport = &utlsTransport{
+			insecureSkipVerify: !verifySSL,
+			dialer:             dialer,
+			helloID:            helloID,
+		}
+	}
 	return &http.Client{
 		Timeout:   time.Duration(timeoutS * float64(time.Second)),
-		Transport: &utlsTransport{insecureSkipVerify: !verifySSL},
+		Transport: transport,
+	}
+}
+
+// targetHTTPClient builds the outbound client for a target, honoring its proxy_url.
+func targetHTTPClient(target *Target) (*http.Client, error) {
+	proxyURL, err := parseOutboundProxyURL(target.ProxyURL)
+	if err != nil {
+		return nil, err
 	}
+	return httpClient(target.TimeoutS, target.VerifySSL, proxyURL, target.TLSFingerprint), nil
 }
 
 // httpJSON performs an HTTP request and returns structured result.
-func httpJSON(client *http.Client, method, reqURL string, headers map[string]string, body any) (*HttpResult, error) {
+func httpJSON(ctx context.Context, client *http.Client, method, reqURL string, headers map[string]string, body any) (*HttpResult, error) {
 	var bodyReader io.Reader
 	if body != nil {
 		data, err := json.Marshal(body)
@@ -158,7 +256,7 @@ func httpJSON(client *http.Client, method, reqURL string, headers map[string]str
 		bodyReader = bytes.NewReader(data)
 	}
 
-	req, err := http.NewRequest(method, reqURL, bodyReader)
+	req, err := http.NewRequestWithContext(ctx, method, reqURL, bodyReader)
 	if err != nil {
 		return nil, fmt.Errorf("create request: %w", err)
 	}
@@ -169,15 +267,21 @@ func httpJSON(client *http.Client, method, reqURL string, headers map[string]str
 		req.Header.Set("Content-Type", "application/json")
 	}
 
+	timer := newRequestTimer()
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), timer.trace()))
+
 	start := time.Now()
 	resp, err := client.Do(req)
-	elapsedMs := int(time.Since(start).Milliseconds())
 	if err != nil {
+		elapsedMs := int(time.Since(start).Milliseconds())
 		return nil, fmt.Errorf("HTTP %s %s failed (%dms): %w", method, reqURL, elapsedMs, err)
 	}
 	defer resp.Body.Close()
 
+	bodyStart := time.Now()
 	raw, _ := io.ReadAll(resp.Body)
+	timer.bodyRead(time.Since(bodyStart))
+	elapsedMs := int(time.Since(start).Milliseconds())
 	text := string(raw)
 
 	var parsed any
@@ -190,6 +294,7 @@ func httpJSON(client *http.Client, method, reqURL string, headers map[string]str
 		Text:       text,
 		JSONBody:   parsed,
 		ElapsedMs:  elapsedMs,
+		Timing:     timer.result(),
 	}, nil
 }
 
@@ -389,6 +494,29 @@ type DetectionResult struct {
 	StatusCode       *int    `json:"status_code"`
 	Route            string  `json:"route"`
 	Endpoint         string  `json:"endpoint"`
+	httpTiming
+	// Streaming-only metrics; nil for non-streaming probes.
+	TTFTMs       *float64 `json:"ttft_ms"`
+	TokensPerSec *float64 `json:"tokens_per_sec"`
+	OutputTokens *int     `json:"output_tokens"`
+	// Slow is set by the latency anomaly analyzer before the row is stored.
+	Slow bool `json:"slow,omitempty"`
+	// ErrorCategory classifies a failed detection (see classifyDetectionError).
+	ErrorCategory string `json:"error_category,omitempty"`
+	// ServedModel is the model id echoed by the upstream; Mismatch is set when it is a
+	// different model than the requested one (see model_identity.go).
+	ServedModel string `json:"served_model,omitempty"`
+	Mismatch    bool   `json:"mismatch,omitempty"`
+	// Token usage reported by the upstream; nil when the response carries none.
+	PromptTokens     *int `json:"prompt_tokens,omitempty"`
+	CompletionTokens *int `json:"completion_tokens,omitempty"`
+	ReasoningTokens  *int `json:"reasoning_tokens,omitempty"`
+	// Capture holds the full request and raw response of a failed detection when the
+	// target has debug_capture on. It is stored apart from the row and not written to
+	// the run's JSONL log.
+	Capture *probeCapture `json:"-"`
+	// Capabilities holds the capability probe verdicts of the model's primary route.
+	Capabilities []CapabilityResult `json:"capabilities,omitempty"`
 }
 
 // ---------------------------------------------------------------------------
@@ -404,11 +532,61 @@ type MonitorService struct {
 	logDir             string
 	detectConcurrency  int
 	maxParallelTargets int
-	enableLogCleanup   bool
-	logMaxBytes        int64
+	// maxRunDuration is the global run deadline; 0 disables it.
+	maxRunDuration   time.Duration
+	enableLogCleanup bool
+	logMaxBytes      int64
+	// logMaxAgeDays removes logs older than this many days; 0 disables it. Targets may
+	// override it with log_max_age_days.
+	logMaxAgeDays      int
+	certExpiryWarnDays int
+	// schedulerPaused stops scheduled runs and agent leases; manual runs still work.
+	schedulerPaused bool
+	// latencyAnomalySigma/Pct are the slow-check thresholds; 0 disables each.
+	latencyAnomalySigma int
+	latencyAnomalyPct   int
+	// runArchiveDays archives runs older than this many days into archiveDir; 0 disables it.
+	runArchiveDays int
+	archiveDir     string
+	lastArchiveAt  time.Time
+	archiveMu      sync.Mutex
+	lastRollupAt   time.Time
+	// lastProxyPruneAt is when proxy_requests were last pruned.
+	lastProxyPruneAt time.Time
+
+	// log is tagged component=monitor; use logger() so hand-built services still log.
+	log *slog.Logger
+
+	routeMu      sync.RWMutex
+	customRoutes []compiledRouteRule
+
+	notifyMu       sync.RWMutex
+	notifyChannels map[int]activeNotifier
+	notifyRoutes   []NotificationRoute
+	// notifyWG tracks notification deliveries still in flight.
+	notifyWG sync.WaitGroup
+
+	flapMu sync.Mutex
+	// flapThreshold is the transitions per hour above which a target is flapping; 0 disables.
+	flapThreshold int
+	// flapTransitions holds each target's incident transition times within the window.
+	flapTransitions map[int][]float64
+	flapping        map[int]bool
 
 	mu             sync.Mutex
 	runningTargets map[int]bool
+	// runCancels cancels the in-flight local run of a target, keyed by target id.
+	runCancels map[int]context.CancelFunc
+	// activeRuns records the mode and start time of local runs for the queue view.
+	activeRuns map[int]activeRun
+	// runSeq numbers local runs so a run reclaimed by the watchdog cannot free its successor's slot.
+	runSeq uint64
+	// runQueue holds forced runs waiting for a free slot, in start order.
+	runQueue []*queuedRun
+	// queueStopped stops queued runs from starting once shutdown has begun.
+	queueStopped bool
+	// agentLeases tracks targets handed to remote agents, keyed by target id.
+	agentLeases    map[int]*agentLease
 	activeLogFiles map[string]bool
 	cleanupMu      sync.Mutex
 	eventCallback  EventCallback
@@ -423,8 +601,30 @@ type MonitorConfig struct {
 	LogDir             string
 	DetectConcurrency  int
 	MaxParallelTargets int
-	EnableLogCleanup   bool
-	LogMaxBytes        int64
+	// MaxRunDuration cancels runs still going after this long; 0 disables it. Targets
+	// may override it with max_run_duration_s.
+	MaxRunDuration   time.Duration
+	EnableLogCleanup bool
+	LogMaxBytes      int64
+	// LogMaxAgeDays removes logs older than this many days; 0 disables it.
+	LogMaxAgeDays int
+	// CertExpiryWarnDays marks a target degraded when its certificate expires within this many days; 0 disables.
+	CertExpiryWarnDays int
+	// SchedulerPaused starts the service with scheduled runs paused.
+	SchedulerPaused bool
+	// LatencyAnomalySigma and LatencyAnomalyPct flag successful checks slower than the
+	// model's baseline by that many standard deviations or percent; 0 disables each.
+	LatencyAnomalySigma int
+	LatencyAnomalyPct   int
+	// RunArchiveDays archives runs older than this many days; 0 disables archival.
+	RunArchiveDays int
+	// ArchiveDir holds run archives; empty uses "archives" next to LogDir.
+	ArchiveDir string
+	// NotifyFlapThreshold suppresses incident notifications of targets with more status
+	// transitions per hour than this; 0 disables.
+	NotifyFlapThreshold int
+	// Logger receives the service's logs; nil uses slog.Default().
+	Logger *slog.Logger
 }
 
 // NewMonitorService creates a new monitor.
@@ -436,17 +636,40 @@ func NewMonitorService(cfg MonitorConfig) *MonitorService {
 		cfg.MaxParallelTargets = 2
 	}
 	_ = os.MkdirAll(cfg.LogDir, 0o755)
+	if cfg.ArchiveDir == "" {
+		cfg.ArchiveDir = filepath.Join(filepath.Dir(cfg.LogDir), "archives")
+	}
 	return &MonitorService{
-		db:                 cfg.DB,
-		logDir:             cfg.LogDir,
-		detectConcurrency:  cfg.DetectConcurrency,
-		maxParallelTargets: cfg.MaxParallelTargets,
-		enableLogCleanup:   cfg.EnableLogCleanup,
-		logMaxBytes:        cfg.LogMaxBytes,
-		runningTargets:     make(map[int]bool),
-		activeLogFiles:     make(map[string]bool),
-		stopCh:             make(chan struct{}),
+		db:                  cfg.DB,
+		logDir:              cfg.LogDir,
+		detectConcurrency:   cfg.DetectConcurrency,
+		maxParallelTargets:  cfg.MaxParallelTargets,
+		maxRunDuration:      max(cfg.MaxRunDuration, 0),
+		enableLogCleanup:    cfg.EnableLogCleanup,
+		logMaxBytes:         cfg.LogMaxBytes,
+		logMaxAgeDays:       min(max(cfg.LogMaxAgeDays, 0), maxLogAgeDays),
+		certExpiryWarnDays:  cfg.CertExpiryWarnDays,
+		schedulerPaused:     cfg.SchedulerPaused,
+		latencyAnomalySigma: cfg.LatencyAnomalySigma,
+		latencyAnomalyPct:   cfg.LatencyAnomalyPct,
+		runArchiveDays:      min(max(cfg.RunArchiveDays, 0), maxRunArchiveDays),
+		archiveDir:          cfg.ArchiveDir,
+		flapThreshold:       max(cfg.NotifyFlapThreshold, 0),
+		log:                 componentLogger(cfg.Logger, "monitor"),
+		runningTargets:      make(map[int]bool),
+		runCancels:          make(map[int]context.CancelFunc),
+		activeRuns:          make(map[int]activeRun),
+		agentLeases:         make(map[int]*agentLease),
+		activeLogFiles:      make(map[string]bool),
+		stopCh:              make(chan struct{}),
+	}
+}
+
+func (ms *MonitorService) logger() *slog.Logger {
+	if ms.log == nil {
+		return componentLogger(nil, "monitor")
 	}
+	return ms.log
 }
 
 // SetEventCallback registers a callback for SSE events.
@@ -454,10 +677,18 @@ func (ms *MonitorService) SetEventCallback(cb EventCallback) {
 	ms.eventCallback = cb
 }
 
+// emitEvent publishes an event to the SSE callback and routes alert events to the
+// notification channels.
 func (ms *MonitorService) emitEvent(eventType, data string) {
 	if ms.eventCallback != nil {
 		ms.eventCallback(eventType, data)
 	}
+	ms.notifyEvent(eventType, data)
+}
+
+func (ms *MonitorService) emitJSON(eventType string, payload map[string]any) {
+	data, _ := json.Marshal(payload)
+	ms.emitEvent(eventType, string(data))
 }
 
 // Start begins the periodic scan ticker (1 minute interval).
@@ -484,7 +715,7 @@ func (ms *MonitorService) Start() {
 			}
 		}
 	}()
-	log.Println("[monitor] scheduler started")
+	ms.logger().Info("scheduler started")
 }
 
 // StopScheduler stops the periodic scan ticker without waiting for running detections.
@@ -496,19 +727,24 @@ func (ms *MonitorService) StopScheduler() {
 	}
 	close(ms.stopCh)
 	ms.started = false
-	log.Println("[monitor] scheduler stopped")
+	ms.logger().Info("scheduler stopped")
 }
 
 // WaitDetections blocks until all running detection goroutines have finished.
 func (ms *MonitorService) WaitDetections() {
 	ms.wg.Wait()
-	log.Println("[monitor] all detections finished")
+	ms.logger().Info("all detections finished")
 }
 
 // StopAndWait stops the scheduler and waits for all running detections to finish.
+// Queued runs are not started.
 func (ms *MonitorService) StopAndWait() {
+	ms.mu.Lock()
+	ms.queueStopped = true
+	ms.mu.Unlock()
 	ms.StopScheduler()
 	ms.WaitDetections()
+	ms.notifyWG.Wait()
 }
 
 // RunningTargetIDs returns IDs of targets currently being checked.
@@ -541,6 +777,20 @@ func (ms *MonitorService) UpdateLogCleanupConfig(enabled bool, maxSizeMB int) {
 	ms.mu.Unlock()
 }
 
+// UpdateLogMaxAgeDays changes the global log age limit at runtime; 0 disables it.
+func (ms *MonitorService) UpdateLogMaxAgeDays(days int) {
+	ms.mu.Lock()
+	ms.logMaxAgeDays = min(max(days, 0), maxLogAgeDays)
+	ms.mu.Unlock()
+}
+
+// LogMaxAgeDays returns the global log age limit in days.
+func (ms *MonitorService) LogMaxAgeDays() int {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	return ms.logMaxAgeDays
+}
+
 // LogCleanupConfig returns current cleanup settings.
 func (ms *MonitorService) LogCleanupConfig() (bool, int) {
 	ms.mu.Lock()
@@ -548,21 +798,185 @@ func (ms *MonitorService) LogCleanupConfig() (bool, int) {
 	return ms.enableLogCleanup, int(ms.logMaxBytes / 1024 / 1024)
 }
 
+// Upper bounds accepted for the concurrency settings and the per-target override.
+const (
+	maxDetectConcurrency = 64
+	maxParallelTargets   = 64
+)
+
+// UpdateConcurrency changes the per-run model concurrency and the parallel target
+// limit. Runs already in progress keep the limits they started with.
+func (ms *MonitorService) UpdateConcurrency(detectConcurrency, maxParallelTargets int) {
+	if detectConcurrency < 1 {
+		detectConcurrency = 3
+	}
+	if maxParallelTargets < 1 {
+		maxParallelTargets = 2
+	}
+	ms.mu.Lock()
+	ms.detectConcurrency = detectConcurrency
+	ms.maxParallelTargets = maxParallelTargets
+	ms.mu.Unlock()
+	ms.startQueuedRuns()
+}
+
+// Concurrency returns the per-run model concurrency and the parallel target limit.
+func (ms *MonitorService) Concurrency() (int, int) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	return ms.detectConcurrency, ms.maxParallelTargets
+}
+
+// UpdateMaxRunDuration changes the global run deadline; 0 disables it. Runs already in
+// progress keep the deadline they started with.
+func (ms *MonitorService) UpdateMaxRunDuration(d time.Duration) {
+	ms.mu.Lock()
+	ms.maxRunDuration = max(d, 0)
+	ms.mu.Unlock()
+}
+
+// MaxRunDuration returns the global run deadline.
+func (ms *MonitorService) MaxRunDuration() time.Duration {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	return ms.maxRunDuration
+}
+
+// UpdateCertExpiryWarnDays updates the certificate expiry threshold at runtime.
+func (ms *MonitorService) UpdateCertExpiryWarnDays(days int) {
+	if days < 0 {
+		days = 0
+	}
+	ms.mu.Lock()
+	ms.certExpiryWarnDays = days
+	ms.mu.Unlock()
+}
+
+// CertExpiryWarnDays returns the current certificate expiry threshold in days.
+func (ms *MonitorService) CertExpiryWarnDays() int {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	return ms.certExpiryWarnDays
+}
+
+// SetSchedulerPaused pauses or resumes scheduled runs at runtime. Runs already in
+// progress are not affected.
+func (ms *MonitorService) SetSchedulerPaused(paused bool) {
+	ms.mu.Lock()
+	changed := ms.schedulerPaused != paused
+	ms.schedulerPaused = paused
+	ms.mu.Unlock()
+	if !changed {
+		return
+	}
+	if paused {
+		ms.logger().Info("scheduler paused")
+	} else {
+		ms.logger().Info("scheduler resumed")
+	}
+	ms.emitJSON("monitor_paused", map[string]any{"paused": paused})
+}
+
+// SchedulerPaused reports whether scheduled runs are paused.
+func (ms *MonitorService) SchedulerPaused() bool {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	return ms.schedulerPaused
+}
+
 // ScanDueTargets checks and triggers all due targets.
 func (ms *MonitorService) ScanDueTargets() {
+	ms.expireAgentLeases()
+	ms.reclaimStuckRuns()
+	ms.maybeRollupDays()
+	ms.maybePruneProxyRequests()
+	ms.maybeArchiveRuns()
+	ms.sweepFlapping(float64(time.Now().UnixMilli()) / 1000.0)
+	ms.cleanupDataLogs()
+	ms.startQueuedRuns()
+	if ms.SchedulerPaused() {
+		sendHeartbeat(true)
+		return
+	}
 	nowTS := float64(time.Now().UnixMilli()) / 1000.0
-	targets, err := ms.db.ListDueTargets(nowTS)
+	targets, err := ms.db.ListDueTargets(nowTS, nil)
 	if err != nil {
-		log.Printf("[monitor] scan error: %v", err)
+		ms.logger().Error("scan due targets failed", "error", err)
+		sendHeartbeat(false)
 		return
 	}
 	for _, t := range targets {
 		ms.TriggerTarget(t.ID, false)
 	}
+	sendHeartbeat(true)
+}
+
+// Run modes stored in runs.mode. Partial runs probe an explicit model list; their rows
+// are overlaid on the latest full run when reporting the target's current state.
+const (
+	runModeFull       = "full"
+	runModeFailedOnly = "failed_only"
+	runModeSubset     = "subset"
+)
+
+// runPlan describes what a run probes. models is nil for a full run, which lists the
+// target's models and applies its selection. priority orders the run in the run queue;
+// higher starts first.
+type runPlan struct {
+	mode     string
+	models   []string
+	priority int
 }
 
 // TriggerTarget starts a detection run for a target in a goroutine.
 func (ms *MonitorService) TriggerTarget(targetID int, force bool) (bool, string) {
+	return ms.triggerTarget(targetID, force, runPlan{mode: runModeFull})
+}
+
+// TriggerManual starts (or queues) a forced run. For failed_only the models are taken
+// from the latest results, so a few failures do not cost a full run of upstream quota;
+// subset models are trimmed and deduplicated and bypass the target's model selection.
+func (ms *MonitorService) TriggerManual(targetID int, plan runPlan) (bool, string) {
+	switch plan.mode {
+	case runModeFailedOnly:
+		statuses, err := ms.db.GetLatestModelStatuses(targetID)
+		if err != nil {
+			return false, err.Error()
+		}
+		plan.models = nil
+		seen := map[string]bool{}
+		for _, s := range statuses {
+			if s.Success || s.Model == "" || seen[s.Model] {
+				continue
+			}
+			seen[s.Model] = true
+			plan.models = append(plan.models, s.Model)
+		}
+		if len(plan.models) == 0 {
+			return false, "no failed models in the latest run"
+		}
+	case runModeSubset:
+		var subset []string
+		seen := map[string]bool{}
+		for _, m := range plan.models {
+			m = strings.TrimSpace(m)
+			if m == "" || seen[m] {
+				continue
+			}
+			seen[m] = true
+			subset = append(subset, m)
+		}
+		if len(subset) == 0 {
+			return false, "models must not be empty"
+		}
+		plan.models = subset
+	default:
+		plan.mode, plan.models = runModeFull, nil
+	}
+	return ms.triggerTarget(targetID, true, plan)
+}
+
+func (ms *MonitorService) triggerTarget(targetID int, force bool, plan runPlan) (bool, string) {
 	target, err := ms.db.GetTarget(targetID)
 	if err != nil || target == nil {
 		return false, "target not found"
@@ -576,32 +990,99 @@ func (ms *MonitorService) TriggerTarget(targetID int, force bool) (bool, string)
 		ms.mu.Unlock()
 		return false, "target already running"
 	}
-	if !force && len(ms.runningTargets) >= ms.maxParallelTargets {
+	if ms.queuePositionLocked(targetID) > 0 {
+		ms.mu.Unlock()
+		return false, "target already queued"
+	}
+	if len(ms.runningTargets) >= ms.maxParallelTargets || (!force && len(ms.runQueue) > 0) {
+		if !force {
+			ms.mu.Unlock()
+			return false, "max parallel targets reached"
+		}
+		pos := ms.enqueueRunLocked(targetID, plan)
 		ms.mu.Unlock()
-		return false, "max parallel targets reached"
+		ms.emitJSON("run_queued", map[string]any{"target_id": targetID, "mode": plan.mode, "position": pos})
+		return true, "target queued"
 	}
 	ms.runningTargets[targetID] = true
+	ms.activeRuns[targetID] = ms.newActiveRunLocked(plan)
 	ms.mu.Unlock()
 
 	ms.wg.Add(1)
-	go ms.runTargetSafe(target)
+	go ms.runTargetSafe(target, plan)
 	return true, "target started"
 }
 
-func (ms *MonitorService) runTargetSafe(target *Target) {
+func (ms *MonitorService) runTargetSafe(target *Target, plan runPlan) {
 	defer ms.wg.Done()
+	ctx, cancel := context.WithCancel(context.Background())
+	deadline := ms.runDeadline(target)
+	ms.mu.Lock()
+	run := ms.activeRuns[target.ID]
+	run.deadline = deadline
+	ms.activeRuns[target.ID] = run
+	ms.runCancels[target.ID] = cancel
+	ms.mu.Unlock()
 	defer func() {
 		ms.mu.Lock()
-		delete(ms.runningTargets, target.ID)
+		// The watchdog may already have reclaimed the slot and handed it to a new run.
+		if ms.activeRuns[target.ID].seq == run.seq {
+			delete(ms.runningTargets, target.ID)
+			delete(ms.runCancels, target.ID)
+			delete(ms.activeRuns, target.ID)
+		}
 		ms.mu.Unlock()
+		cancel()
+		ms.startQueuedRuns()
 	}()
-	ms.runTarget(target)
+	if deadline > 0 {
+		var stop context.CancelFunc
+		ctx, stop = context.WithTimeoutCause(ctx, deadline, fmt.Errorf("%w of %s", errRunDeadline, deadline))
+		defer stop()
+	}
+	ms.runTarget(ctx, target, plan)
 }
 
-func (ms *MonitorService) runTarget(target *Target) {
+// CancelTarget stops the in-flight run of a target. Local runs stop dispatching models and
+// abort pending requests; runs leased to an agent are closed immediately and the late
+// report is rejected. A queued run is simply dropped from the run queue.
+func (ms *MonitorService) CancelTarget(targetID int) (bool, string) {
+	if ms.dequeueRun(targetID) {
+		ms.emitJSON("run_dequeued", map[string]any{"target_id": targetID})
+		return true, "queued run removed"
+	}
+	ms.mu.Lock()
+	if cancel, ok := ms.runCancels[targetID]; ok {
+		ms.mu.Unlock()
+		cancel()
+		return true, "target cancelling"
+	}
+	lease, leased := ms.agentLeases[targetID]
+	if leased {
+		delete(ms.agentLeases, targetID)
+		delete(ms.runningTargets, targetID)
+	}
+	ms.mu.Unlock()
+	if !leased {
+		return false, "target not running"
+	}
+
+	target, err := ms.db.GetTarget(targetID)
+	if err != nil || target == nil {
+		return false, "target not found"
+	}
+	ms.finishCancelledRun(target, lease.runID, lease.logFile, nil)
+	ms.startQueuedRuns()
+	return true, "target cancelled"
+}
+
+func (ms *MonitorService) runTarget(ctx context.Context, target *Target, plan runPlan) {
 	startedAt := float64(time.Now().UnixMilli()) / 1000.0
-	ts := time.Now().Format("20060102_150405")
-	logFile, _ := filepath.Abs(filepath.Join(ms.logDir, fmt.Sprintf("target_%d_%s.jsonl", target.ID, ts)))
+	logFile := ms.newRunLogFile(target)
+
+	ctx, span := startSpan(ctx, "detection.run", "target.id", target.ID, "target.name", target.Name)
+	var runErr error
+	defer func() { span.End(runErr) }()
 
 	ms.mu.Lock()
 	ms.activeLogFiles[logFile] = true
@@ -610,85 +1091,326 @@ func (ms *MonitorService) runTarget(target *Target) {
 		ms.mu.Lock()
 		delete(ms.activeLogFiles, logFile)
 		ms.mu.Unlock()
+		ms.shipRunLog(ms.compressRunLog(logFile))
 		ms.cleanupDataLogs()
 	}()
 
-	runID, err := ms.db.CreateRun(target.ID, startedAt, logFile)
+	var runID int
+	err := traceDB(ctx, "CreateRun", func() (err error) {
+		runID, err = ms.db.CreateRun(target.ID, startedAt, logFile, nil, plan.mode)
+		return err
+	})
+	if err != nil {
+		runErr = err
+		ms.logger().Error("create run failed", "target", target.Name, "target_id", target.ID, "error", err)
+		return
+	}
+	span.SetAttrs("run.id", runID)
+
+	ms.logger().Info("run start", "target", target.Name, "target_id", target.ID, "run_id", runID, "mode", plan.mode)
+
+	client, err := targetHTTPClient(target)
+	if err != nil {
+		runErr = err
+		ms.markRunError(target, runID, logFile, err)
+		ms.logger().Warn("run failed", "target", target.Name, "target_id", target.ID, "run_id", runID, "error", err)
+		return
+	}
+	certObserver := &tlsCertObserver{}
+	observeClientCerts(client, certObserver)
+	target = ms.checkKeyPool(ctx, target, client)
+
+	var resultCh <-chan DetectionResult
+	var planned int
+	if plan.models != nil {
+		resultCh, planned = ms.probeModels(ctx, target, client, plan.models)
+	} else {
+		resultCh, planned, err = ms.detectModels(ctx, target, client)
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			span.SetAttrs("run.status", ms.finishInterruptedRun(ctx, target, runID, logFile, nil))
+			return
+		}
+		runErr = err
+		ms.markRunError(target, runID, logFile, err)
+		ms.logger().Warn("run failed", "target", target.Name, "target_id", target.ID, "run_id", runID, "error", err)
+		return
+	}
+	ms.emitJSON("run_started", map[string]any{
+		"target_id":   target.ID,
+		"target_name": target.Name,
+		"run_id":      runID,
+		"mode":        plan.mode,
+		"total":       planned,
+	})
+
+	done := 0
+	rows, err := ms.writeRunLog(target, runID, logFile, resultCh, func(row DetectionResult) {
+		done++
+		ms.emitJSON("model_checked", map[string]any{
+			"target_id": target.ID,
+			"run_id":    runID,
+			"model":     row.Model,
+			"route":     row.Route,
+			"endpoint":  row.Endpoint,
+			"success":   row.Success,
+			"duration":  row.Duration,
+		})
+		ms.emitJSON("run_progress", map[string]any{
+			"target_id": target.ID,
+			"run_id":    runID,
+			"done":      done,
+			"total":     planned,
+		})
+	})
 	if err != nil {
-		log.Printf("[monitor] create run failed target=%s: %v", target.Name, err)
+		runErr = err
+		ms.markRunError(target, runID, logFile, err)
+		ms.logger().Warn("run failed", "target", target.Name, "target_id", target.ID, "run_id", runID, "error", err)
+		return
+	}
+	if ctx.Err() != nil {
+		span.SetAttrs("run.status", ms.finishInterruptedRun(ctx, target, runID, logFile, rows))
 		return
 	}
-	markRunError := func(lastStatus string, total, success, fail int, runErr error) {
-		endedAt := float64(time.Now().UnixMilli()) / 1000.0
-		errStr := runErr.Error()
-		if err := ms.db.FinishRun(runID, "error", endedAt, total, success, fail, &errStr); err != nil {
-			log.Printf("[monitor] finish run(error) failed target=%s run_id=%d: %v", target.Name, runID, err)
+	ms.probeBalance(ctx, target, client)
+	chain, notAfter, _ := certObserver.Result()
+	span.SetAttrs("run.status", ms.completeRun(ctx, target, runID, logFile, rows, chain, notAfter, plan.mode))
+}
+
+// finishInterruptedRun closes a run whose context ended before it completed: as
+// "timeout" when the run deadline passed, as "cancelled" otherwise. It returns the status.
+func (ms *MonitorService) finishInterruptedRun(ctx context.Context, target *Target, runID int, logFile string, rows []DetectionResult) string {
+	if cause := context.Cause(ctx); errors.Is(cause, errRunDeadline) {
+		ms.finishStoppedRun(target, runID, logFile, rows, "timeout", cause.Error())
+		return "timeout"
+	}
+	ms.finishCancelledRun(target, runID, logFile, rows)
+	return "cancelled"
+}
+
+// finishCancelledRun keeps the rows finished before the cancel and closes the run as cancelled.
+func (ms *MonitorService) finishCancelledRun(target *Target, runID int, logFile string, rows []DetectionResult) {
+	ms.finishStoppedRun(target, runID, logFile, rows, "cancelled", "run cancelled")
+}
+
+// finishStoppedRun keeps the rows finished before the run was stopped and closes it
+// with status and msg.
+func (ms *MonitorService) finishStoppedRun(target *Target, runID int, logFile string, rows []DetectionResult, status, msg string) {
+	total := len(rows)
+	successCount := 0
+	for _, r := range rows {
+		if r.Success {
+			successCount++
 		}
-		if err := ms.db.UpdateTargetAfterRun(target.ID, endedAt, lastStatus, total, success, fail, logFile, &errStr); err != nil {
-			log.Printf("[monitor] update target(error) failed target=%s run_id=%d: %v", target.Name, runID, err)
+	}
+	failCount := total - successCount
+
+	if total > 0 {
+		if err := ms.db.InsertModelRows(runID, target.ID, rows); err != nil {
+			ms.logger().Error("insert model rows failed", "target", target.Name, "target_id", target.ID, "run_id", runID, "error", err)
 		}
 	}
+	endedAt := float64(time.Now().UnixMilli()) / 1000.0
+	if err := ms.db.FinishRun(runID, status, endedAt, total, successCount, failCount, &msg); err != nil {
+		ms.logger().Error("finish run failed", "target", target.Name, "target_id", target.ID, "run_id", runID, "status", status, "error", err)
+	}
+	if err := ms.db.UpdateTargetAfterRun(target.ID, endedAt, status, total, successCount, failCount, logFile, &msg); err != nil {
+		ms.logger().Error("update target after run failed", "target", target.Name, "target_id", target.ID, "run_id", runID, "status", status, "error", err)
+	}
 
-	log.Printf("[monitor] run start target=%s id=%d", target.Name, target.ID)
+	if status == "timeout" {
+		ms.logger().Warn("run timed out", "target", target.Name, "target_id", target.ID, "run_id", runID, "done", total, "detail", msg)
+	} else {
+		ms.logger().Info("run cancelled", "target", target.Name, "target_id", target.ID, "run_id", runID, "done", total)
+	}
+	ms.emitJSON("run_completed", map[string]any{
+		"target_id":   target.ID,
+		"target_name": target.Name,
+		"status":      status,
+		"total":       total,
+		"success":     successCount,
+		"fail":        failCount,
+	})
+}
 
-	client := httpClient(target.TimeoutS, target.VerifySSL)
+func (ms *MonitorService) newRunLogFile(target *Target) string {
+	ts := time.Now().Format("20060102_150405")
+	logFile, _ := filepath.Abs(filepath.Join(ms.logDir, fmt.Sprintf("target_%d_%s.jsonl", target.ID, ts)))
+	return logFile
+}
 
-	models, err := ms.getModels(target, client)
-	if err != nil {
-		markRunError("error", 0, 0, 0, err)
-		log.Printf("[monitor] run failed target=%s: %v", target.Name, err)
+// markRunError closes a run that failed before any model rows were recorded.
+func (ms *MonitorService) markRunError(target *Target, runID int, logFile string, runErr error) {
+	ms.markRunErrorCounts(target, runID, logFile, 0, 0, 0, runErr)
+}
+
+func (ms *MonitorService) markRunErrorCounts(target *Target, runID int, logFile string, total, success, fail int, runErr error) {
+	endedAt := float64(time.Now().UnixMilli()) / 1000.0
+	errStr := runErr.Error()
+	if err := ms.db.FinishRun(runID, "error", endedAt, total, success, fail, &errStr); err != nil {
+		ms.logger().Error("finish run failed", "target", target.Name, "target_id", target.ID, "run_id", runID, "status", "error", "error", err)
+	}
+	if err := ms.db.UpdateTargetAfterRun(target.ID, endedAt, "error", total, success, fail, logFile, &errStr); err != nil {
+		ms.logger().Error("update target after run failed", "target", target.Name, "target_id", target.ID, "run_id", runID, "status", "error", "error", err)
+	}
+	ms.updateRetryInterval(target, "error")
+	ms.trackIncident(target, runID, "error", &errStr, nil)
+}
+
+// nextRetryInterval returns the re-check delay after a run ending in status.
+// Unhealthy runs start at fast_retry_min and double on every further failure until
+// they reach interval_min; any other status (or fast retry being off) returns nil.
+func nextRetryInterval(target *Target, status string) *int {
+	if target.FastRetryMin <= 0 || target.FastRetryMin >= target.IntervalMin {
+		return nil
+	}
+	if status != "down" && status != "degraded" && status != "error" {
+		return nil
+	}
+	next := target.FastRetryMin
+	if target.RetryIntervalMin != nil {
+		next = *target.RetryIntervalMin * 2
+	}
+	if next >= target.IntervalMin {
+		next = target.IntervalMin
+	}
+	return &next
+}
+
+func (ms *MonitorService) updateRetryInterval(target *Target, status string) {
+	next := nextRetryInterval(target, status)
+	if next == nil && target.RetryIntervalMin == nil {
 		return
 	}
-	models = filterModelsBySelection(models, target.SelectedModels)
+	if err := ms.db.SetTargetRetryInterval(target.ID, next); err != nil {
+		ms.logger().Error("update retry interval failed", "target", target.Name, "target_id", target.ID, "error", err)
+		return
+	}
+	if next != nil {
+		ms.logger().Info("fast retry scheduled", "target", target.Name, "target_id", target.ID, "status", status, "next_check_min", *next)
+	}
+}
+
+// detectModels lists and filters the target's models and probes them concurrently.
+// It returns the result channel, closed once every detection has finished, and the
+// number of results that will be sent (a model may be probed on several routes).
+// Once ctx is cancelled no further models are dispatched and aborted requests are not sent,
+// so the channel may close early.
+func (ms *MonitorService) detectModels(ctx context.Context, target *Target, client *http.Client) (<-chan DetectionResult, int, error) {
+	listCtx, listSpan := startSpanKind(ctx, "detection.list_models", spanKindClient, "target.id", target.ID)
+	models, err := ms.getModels(listCtx, target, client)
+	listSpan.SetAttrs("models", len(models))
+	listSpan.End(err)
+	if err != nil {
+		return nil, 0, err
+	}
+	models = filterModelsBySelection(models, target.SelectedModels, target.IncludePatterns, target.ExcludePatterns)
 
 	if target.MaxModels > 0 && len(models) > target.MaxModels {
 		models = models[:target.MaxModels]
 	}
+	resultCh, planned := ms.probeModels(ctx, target, client, models)
+	return resultCh, planned, nil
+}
+
+// probeModels probes the given models concurrently on each of their routes. It returns
+// the result channel, closed once every probe has finished, and the number of results
+// that will be sent. Once ctx is cancelled no further models are dispatched.
+func (ms *MonitorService) probeModels(ctx context.Context, target *Target, client *http.Client, models []string) (<-chan DetectionResult, int) {
+	routes := make([][]string, len(models))
+	planned := 0
+	for i, mid := range models {
+		routes[i] = ms.detectionRoutes(target, mid)
+		planned += len(routes[i])
+	}
 
 	// Concurrent detection with semaphore
-	resultCh := make(chan DetectionResult, len(models))
-	sem := make(chan struct{}, ms.detectConcurrency)
+	resultCh := make(chan DetectionResult, planned)
+	concurrency, _ := ms.Concurrency()
+	if target.DetectConcurrency > 0 {
+		concurrency = target.DetectConcurrency
+	}
+	sem := make(chan struct{}, concurrency)
 
 	var wg sync.WaitGroup
-	for _, modelID := range models {
+	for i, modelID := range models {
 		wg.Add(1)
-		go func(mid string) {
+		go func(mid string, modelRoutes []string) {
 			defer wg.Done()
-			sem <- struct{}{}
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
 			defer func() { <-sem }()
-			row := ms.detectOne(target, mid, client)
-			resultCh <- row
-		}(modelID)
+			for i, route := range modelRoutes {
+				if ctx.Err() != nil {
+					return
+				}
+				probeCtx, probeSpan := startSpanKind(ctx, "detection.probe", spanKindClient,
+					"target.id", target.ID, "model", mid, "route", route)
+				row := ms.detectOne(probeCtx, target, mid, route, client)
+				probeSpan.SetAttrs("endpoint", row.Endpoint, "success", row.Success, "duration_s", row.Duration)
+				if row.StatusCode != nil {
+					probeSpan.SetAttrs("http.status_code", *row.StatusCode)
+				}
+				var probeErr error
+				if row.Error != nil && !row.Success {
+					probeErr = errors.New(*row.Error)
+				}
+				probeSpan.End(probeErr)
+				if i == 0 && row.Success && len(target.CapabilityProbes) > 0 {
+					row.Capabilities = ms.probeCapabilities(ctx, target, mid, route, client)
+				}
+				if ctx.Err() != nil {
+					// The request was aborted by the cancel, not by the upstream.
+					return
+				}
+				resultCh <- row
+			}
+		}(modelID, routes[i])
 	}
 
 	go func() {
 		wg.Wait()
 		close(resultCh)
 	}()
+	return resultCh, planned
+}
 
-	// Collect results and write log file
+// runLogRow is one line of a run's JSONL log: a detection result with its context.
+type runLogRow struct {
+	DetectionResult
+	TargetID   int    `json:"target_id"`
+	RunID      int    `json:"run_id"`
+	TargetName string `json:"target_name"`
+}
+
+// writeRunLog drains resultCh into the run's JSONL log file and returns the collected rows.
+// Only failing to open the file is fatal; later write errors are logged and collection continues.
+// onRow, when set, is called for every row as it arrives.
+func (ms *MonitorService) writeRunLog(target *Target, runID int, logFile string, resultCh <-chan DetectionResult, onRow func(DetectionResult)) ([]DetectionResult, error) {
 	var rows []DetectionResult
 	f, err := os.OpenFile(logFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
 	if err != nil {
-		markRunError("error", 0, 0, 0, fmt.Errorf("open log file failed: %w", err))
-		log.Printf("[monitor] run failed target=%s: open log file failed: %v", target.Name, err)
-		return
+		for range resultCh {
+		}
+		return nil, fmt.Errorf("open log file failed: %w", err)
 	}
 	var writeErr error
 	for row := range resultCh {
 		// Write JSONL log with context fields
+		logEntry := runLogRow{
+			DetectionResult: row,
+			TargetID:        target.ID,
+			RunID:           runID,
+			TargetName:      target.Name,
+		}
+		publishResult(logEntry)
+		pushProbeMetrics(logEntry)
 		if writeErr == nil {
-			logEntry := struct {
-				DetectionResult
-				TargetID   int    `json:"target_id"`
-				RunID      int    `json:"run_id"`
-				TargetName string `json:"target_name"`
-			}{
-				DetectionResult: row,
-				TargetID:        target.ID,
-				RunID:           runID,
-				TargetName:      target.Name,
-			}
 			line, err := json.Marshal(logEntry)
 			if err != nil {
 				writeErr = fmt.Errorf("marshal log row failed: %w", err)
@@ -701,14 +1423,24 @@ func (ms *MonitorService) runTarget(target *Target) {
 			}
 		}
 		rows = append(rows, row)
+		if onRow != nil {
+			onRow(row)
+		}
 	}
 	if err := f.Close(); err != nil && writeErr == nil {
 		writeErr = fmt.Errorf("close log file failed: %w", err)
 	}
 	if writeErr != nil {
-		log.Printf("[monitor] target=%s log file write issue: %v", target.Name, writeErr)
+		ms.logger().Warn("run log write failed", "target", target.Name, "target_id", target.ID, "run_id", runID, "error", writeErr)
 	}
+	return rows, nil
+}
 
+// completeRun stores rows, derives the target status and finishes the run.
+// A zero certNotAfter means no TLS certificate was observed. It returns the target
+// status, or "error" when the results could not be stored. For a partial run the
+// target status is derived from its rows overlaid on the latest full run.
+func (ms *MonitorService) completeRun(ctx context.Context, target *Target, runID int, logFile string, rows []DetectionResult, certChain []tlsCertInfo, certNotAfter time.Time, mode string) string {
 	total := len(rows)
 	successCount := 0
 	for _, r := range rows {
@@ -718,37 +1450,80 @@ func (ms *MonitorService) runTarget(target *Target) {
 	}
 	failCount := total - successCount
 
+	ms.flagLatencyAnomalies(target, rows)
+
 	// Insert into DB
-	if err := ms.db.InsertModelRows(runID, target.ID, rows); err != nil {
-		markRunError("error", total, successCount, failCount, fmt.Errorf("insert model rows failed: %w", err))
-		log.Printf("[monitor] run failed target=%s: insert model rows failed: %v", target.Name, err)
-		return
+	if err := traceDB(ctx, "InsertModelRows", func() error { return ms.db.InsertModelRows(runID, target.ID, rows) }); err != nil {
+		ms.markRunErrorCounts(target, runID, logFile, total, successCount, failCount, fmt.Errorf("insert model rows failed: %w", err))
+		ms.logger().Error("insert model rows failed", "target", target.Name, "target_id", target.ID, "run_id", runID, "error", err)
+		return "error"
+	}
+
+	statusTotal, statusSuccess := total, successCount
+	if mode != runModeFull {
+		if statuses, err := ms.db.GetLatestModelStatuses(target.ID); err == nil {
+			statusTotal, statusSuccess = len(statuses), 0
+			for _, s := range statuses {
+				if s.Success {
+					statusSuccess++
+				}
+			}
+		}
 	}
+	statusFail := statusTotal - statusSuccess
 
 	var targetStatus string
 	switch {
-	case total == 0:
+	case statusTotal == 0:
 		targetStatus = "no_models"
-	case failCount == 0:
+	case statusFail == 0:
 		targetStatus = "healthy"
-	case successCount == 0:
+	case statusSuccess == 0:
 		targetStatus = "down"
 	default:
 		targetStatus = "degraded"
 	}
 
+	var certWarning *string
+	if !certNotAfter.IsZero() {
+		checkedAt := float64(time.Now().UnixMilli()) / 1000.0
+		if err := ms.db.UpdateTargetCertInfo(target.ID, float64(certNotAfter.UnixMilli())/1000.0, certChain, checkedAt); err != nil {
+			ms.logger().Error("update cert info failed", "target", target.Name, "target_id", target.ID, "error", err)
+		}
+		if msg := certExpiryWarning(certNotAfter, time.Now(), ms.CertExpiryWarnDays()); msg != "" {
+			certWarning = &msg
+			if targetStatus == "healthy" {
+				targetStatus = "degraded"
+			}
+			ms.logger().Warn("certificate expiring", "target", target.Name, "target_id", target.ID, "detail", msg)
+			certEvent, _ := json.Marshal(map[string]any{
+				"target_id":   target.ID,
+				"target_name": target.Name,
+				"not_after":   float64(certNotAfter.UnixMilli()) / 1000.0,
+				"message":     msg,
+			})
+			ms.emitEvent("cert_expiring", string(certEvent))
+		}
+	}
+
 	endedAt := float64(time.Now().UnixMilli()) / 1000.0
-	if err := ms.db.FinishRun(runID, "completed", endedAt, total, successCount, failCount, nil); err != nil {
-		log.Printf("[monitor] finish run(completed) failed target=%s run_id=%d: %v", target.Name, runID, err)
-		return
+	if err := traceDB(ctx, "FinishRun", func() error {
+		return ms.db.FinishRun(runID, "completed", endedAt, total, successCount, failCount, nil)
+	}); err != nil {
+		ms.logger().Error("finish run failed", "target", target.Name, "target_id", target.ID, "run_id", runID, "status", "completed", "error", err)
+		return "error"
 	}
-	if err := ms.db.UpdateTargetAfterRun(target.ID, endedAt, targetStatus, total, successCount, failCount, logFile, nil); err != nil {
-		log.Printf("[monitor] update target(completed) failed target=%s run_id=%d: %v", target.Name, runID, err)
-		return
+	if err := traceDB(ctx, "UpdateTargetAfterRun", func() error {
+		return ms.db.UpdateTargetAfterRun(target.ID, endedAt, targetStatus, statusTotal, statusSuccess, statusFail, logFile, certWarning)
+	}); err != nil {
+		ms.logger().Error("update target after run failed", "target", target.Name, "target_id", target.ID, "run_id", runID, "status", "completed", "error", err)
+		return "error"
 	}
+	ms.updateRetryInterval(target, targetStatus)
+	ms.trackIncident(target, runID, targetStatus, nil, rows)
 
-	log.Printf("[monitor] run finished target=%s id=%d status=%s total=%d success=%d fail=%d",
-		target.Name, target.ID, targetStatus, total, successCount, failCount)
+	ms.logger().Info("run finished", "target", target.Name, "target_id", target.ID, "run_id", runID,
+		"mode", mode, "status", targetStatus, "total", total, "success", successCount, "fail", failCount)
 
 	eventData, _ := json.Marshal(map[string]any{
 		"target_id":   target.ID,
@@ -759,18 +1534,19 @@ func (ms *MonitorService) runTarget(target *Target) {
 		"fail":        failCount,
 	})
 	ms.emitEvent("run_completed", string(eventData))
+	return targetStatus
 }
 
 // ---------------------------------------------------------------------------
 // Model discovery + detection
 // ---------------------------------------------------------------------------
 
-func (ms *MonitorService) getModels(target *Target, client *http.Client) ([]string, error) {
+func (ms *MonitorService) getModels(ctx context.Context, target *Target, client *http.Client) ([]string, error) {
 	baseURL := normalizeBaseURL(target.BaseURL)
 	modelsURL := baseURL + "/v1/models"
-	headers := authHeaders(target.APIKey)
+	headers := targetHeaders(target)
 
-	res, err := httpJSON(client, "GET", modelsURL, headers, nil)
+	res, err := httpJSON(ctx, client, "GET", modelsURL, headers, nil)
 	if err != nil {
 		return nil, fmt.Errorf("GET /v1/models failed: %w", err)
 	}
@@ -807,8 +1583,11 @@ func (ms *MonitorService) getModels(target *Target, client *http.Client) ([]stri
 	return models, nil
 }
 
-func filterModelsBySelection(models []string, selectedModels []string) []string {
-	if len(models) == 0 || len(selectedModels) == 0 {
+// filterModelsBySelection keeps models that are explicitly selected or match an include pattern,
+// then drops those matching an exclude pattern. With no selection and no include patterns every
+// model is kept, so excludes alone act as a blocklist. Upstream order is preserved.
+func filterModelsBySelection(models []string, selectedModels, includePatterns, excludePatterns []string) []string {
+	if len(models) == 0 {
 		return models
 	}
 	allowed := make(map[string]struct{}, len(selectedModels))
@@ -819,28 +1598,66 @@ func filterModelsBySelection(models []string, selectedModels []string) []string
 		}
 		allowed[s] = struct{}{}
 	}
-	if len(allowed) == 0 {
+	include, _ := compileModelPatterns(includePatterns)
+	exclude, _ := compileModelPatterns(excludePatterns)
+	if len(allowed) == 0 && len(include) == 0 && len(exclude) == 0 {
 		return models
 	}
+	keepAll := len(allowed) == 0 && len(include) == 0
 
 	filtered := make([]string, 0, len(models))
 	for _, model := range models {
-		if _, ok := allowed[model]; ok {
-			filtered = append(filtered, model)
+		_, selected := allowed[model]
+		if !keepAll && !selected && !matchesAnyPattern(include, model) {
+			continue
 		}
+		if matchesAnyPattern(exclude, model) {
+			continue
+		}
+		filtered = append(filtered, model)
 	}
 	return filtered
 }
 
-func (ms *MonitorService) chooseRoute(modelID string) string {
-	parts := strings.SplitN(modelID, "/", 2)
-	actual := strings.ToLower(parts[len(parts)-1])
-	for _, rule := range routeRules {
-		if rule.pattern.MatchString(actual) {
-			return rule.route
+// compileModelPatterns compiles include/exclude patterns. A pattern prefixed with "re:" is a
+// regular expression; anything else is a case-insensitive glob where * and ? match any characters.
+func compileModelPatterns(patterns []string) ([]*regexp.Regexp, error) {
+	out := make([]*regexp.Regexp, 0, len(patterns))
+	for _, p := range patterns {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		var expr string
+		if strings.HasPrefix(p, "re:") {
+			expr = strings.TrimPrefix(p, "re:")
+		} else {
+			quoted := regexp.QuoteMeta(p)
+			quoted = strings.ReplaceAll(quoted, `\*`, ".*")
+			quoted = strings.ReplaceAll(quoted, `\?`, ".")
+			expr = "(?i)^" + quoted + "$"
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", p, err)
+		}
+		out = append(out, re)
+	}
+	return out, nil
+}
+
+func matchesAnyPattern(patterns []*regexp.Regexp, model string) bool {
+	for _, re := range patterns {
+		if re.MatchString(model) {
+			return true
 		}
 	}
-	return "chat"
+	return false
+}
+
+func (ms *MonitorService) chooseRoute(modelID string) string {
+	route, _ := ms.resolveRoute(modelID)
+	return route
 }
 
 func routeToProtocol(route string) string {
@@ -850,12 +1667,39 @@ func routeToProtocol(route string) string {
 	return route
 }
 
-func (ms *MonitorService) detectOne(target *Target, modelID string, client *http.Client) DetectionResult {
-	route := ms.chooseRoute(modelID)
+// detectionRoutes returns the routes to probe for a model: the rule-chosen route first, then any
+// additional probe_endpoints when the model speaks the OpenAI protocol.
+func (ms *MonitorService) detectionRoutes(target *Target, modelID string) []string {
+	primary := ms.chooseRoute(modelID)
+	routes := []string{primary}
+	if routeToProtocol(primary) != "openai" {
+		return routes
+	}
+	for _, ep := range target.ProbeEndpoints {
+		if (ep == "chat" || ep == "responses") && ep != primary {
+			routes = append(routes, ep)
+		}
+	}
+	return routes
+}
+
+func (ms *MonitorService) detectOne(ctx context.Context, target *Target, modelID, route string, client *http.Client) DetectionResult {
 	baseURL := normalizeBaseURL(target.BaseURL)
-	headers := authHeaders(target.APIKey)
+	headers := targetHeaders(target)
 	prompt := target.Prompt
 	anthropicVersion := target.AnthropicVersion
+	override := target.ModelOverrides[modelID]
+	if override.TimeoutS != nil {
+		c := *client
+		c.Timeout = time.Duration(*override.TimeoutS * float64(time.Second))
+		client = &c
+	}
+	maxTokens := func(def int) int {
+		if override.MaxTokens != nil {
+			return *override.MaxTokens
+		}
+		return def
+	}
 
 	buildFail := func(endpoint, message string, durationS float64, statusCode *int, transportSuccess bool) DetectionResult {
 		return DetectionResult{
@@ -873,10 +1717,11 @@ func (ms *MonitorService) detectOne(target *Target, modelID string, client *http
 			StatusCode:       statusCode,
 			Route:            route,
 			Endpoint:         endpoint,
+			ErrorCategory:    classifyDetectionError(statusCode, message),
 		}
 	}
 
-	validate := func(endpoint string, res *HttpResult, extractor func(any) string) DetectionResult {
+	validateResponse := func(endpoint string, res *HttpResult, extractor func(any) string) DetectionResult {
 		durationS := math.Max(0, float64(res.ElapsedMs)/1000.0)
 		if res.StatusCode != 200 {
 			msg := checkResponseBodyForError(res.JSONBody)
@@ -916,34 +1761,131 @@ func (ms *MonitorService) detectOne(target *Target, modelID string, client *http
 			Endpoint:         endpoint,
 		}
 	}
+	validate := func(endpoint string, res *HttpResult, extractor func(any) string) DetectionResult {
+		row := validateResponse(endpoint, res, extractor)
+		row.httpTiming = res.Timing
+		checkServedModel(&row, modelID, servedModel(res.JSONBody))
+		setUsage(&row, parseUsage(res.JSONBody))
+		return row
+	}
+	validateStream := func(endpoint string, res *StreamResult) DetectionResult {
+		durationS := math.Max(0, float64(res.ElapsedMs)/1000.0)
+		sc := res.StatusCode
+		var row DetectionResult
+		switch {
+		case res.StatusCode != 200:
+			msg := checkResponseBodyForError(res.JSONBody)
+			if msg == "" {
+				msg = truncStr(res.Text, 500)
+			}
+			if msg == "" {
+				msg = "unknown error"
+			}
+			row = buildFail(endpoint, fmt.Sprintf("HTTP %d: %s", res.StatusCode, msg), durationS, &sc, true)
+		case res.StreamError != "":
+			row = buildFail(endpoint, "stream error: "+res.StreamError, durationS, &sc, true)
+		case res.Content == "":
+			row = buildFail(endpoint, "stream parse failed: no readable text", durationS, &sc, true)
+		default:
+			row = validateResponse(endpoint, &HttpResult{StatusCode: 200, ElapsedMs: res.ElapsedMs}, func(any) string { return res.Content })
+			row.TTFTMs = res.FirstTokenMs
+			row.TokensPerSec = streamThroughput(res)
+			if res.OutputTokens > 0 {
+				n := res.OutputTokens
+				row.OutputTokens = &n
+			}
+		}
+		row.Stream = true
+		row.httpTiming = res.Timing
+		checkServedModel(&row, modelID, res.Model)
+		setUsage(&row, res.Usage)
+		return row
+	}
+	// probe sends one detection request, streaming when the target enables stream_probe.
+	// With debug_capture on, a failed probe keeps its request body and raw response.
+	probe := func(endpoint, reqURL string, hdrs map[string]string, body map[string]any, extractor func(any) string, parser streamChunkParser) DetectionResult {
+		var row DetectionResult
+		var response string
+		if target.StreamProbe {
+			res, err := httpStream(ctx, client, "POST", reqURL, hdrs, body, parser)
+			if err != nil {
+				row = buildFail(endpoint, err.Error(), 0, nil, false)
+				row.Stream = true
+			} else {
+				row = validateStream(endpoint, res)
+				response = res.Text
+				if response == "" {
+					response = res.Content
+				}
+			}
+		} else {
+			res, err := httpJSON(ctx, client, "POST", reqURL, hdrs, body)
+			if err != nil {
+				row = buildFail(endpoint, err.Error(), 0, nil, false)
+			} else {
+				row = validate(endpoint, res, extractor)
+				response = res.Text
+			}
+		}
+		if target.DebugCapture && !row.Success {
+			row.Capture = newProbeCapture("POST", reqURL, body, response)
+		}
+		return row
+	}
+	// toolProbe sends the non-streaming tool-use probe of tool_probe.go and requires a
+	// tool_use item in the reply.
+	toolProbe := func(endpoint, reqURL string, hdrs map[string]string, body map[string]any) DetectionResult {
+		var row DetectionResult
+		var response string
+		res, err := httpJSON(ctx, client, "POST", reqURL, hdrs, body)
+		if err != nil {
+			row = buildFail(endpoint, err.Error(), 0, nil, false)
+		} else {
+			response = res.Text
+			items := anthropicToolUses(res.JSONBody)
+			summary, toolErr := checkToolUses(items)
+			row = validate(endpoint, res, func(any) string { return summary })
+			if toolErr != nil && res.StatusCode == 200 && checkResponseBodyForError(res.JSONBody) == "" {
+				sc := res.StatusCode
+				row = buildFail(endpoint, toolErr.Error(), float64(res.ElapsedMs)/1000.0, &sc, true)
+				row.httpTiming = res.Timing
+			}
+			if row.Success {
+				row.ToolCallsCount = len(items)
+				row.ToolCalls = encodeToolCalls(items)
+			}
+		}
+		if target.DebugCapture && !row.Success {
+			row.Capture = newProbeCapture("POST", reqURL, body, response)
+		}
+		return row
+	}
 
 	switch route {
 	case "chat":
 		reqURL := baseURL + "/v1/chat/completions"
 		body := map[string]any{
 			"model":      modelID,
-			"stream":     false,
-			"max_tokens": 50,
+			"stream":     target.StreamProbe,
+			"max_tokens": maxTokens(50),
 			"messages":   []map[string]any{{"role": "user", "content": prompt}},
 		}
-		res, err := httpJSON(client, "POST", reqURL, headers, body)
-		if err != nil {
-			return buildFail("chat", err.Error(), 0, nil, false)
+		if target.StreamProbe {
+			body["stream_options"] = map[string]any{"include_usage": true}
 		}
-		return validate("chat", res, extractTextFromChat)
+		return probe("chat", reqURL, headers, body, extractTextFromChat, parseChatStreamChunk)
 
 	case "responses":
 		reqURL := baseURL + "/v1/responses"
 		body := map[string]any{
 			"model":  modelID,
-			"stream": false,
+			"stream": target.StreamProbe,
 			"input":  []map[string]any{{"role": "user", "content": []map[string]any{{"type": "input_text", "text": prompt}}}},
 		}
-		res, err := httpJSON(client, "POST", reqURL, headers, body)
-		if err != nil {
-			return buildFail("responses", err.Error(), 0, nil, false)
+		if override.MaxTokens != nil {
+			body["max_output_tokens"] = *override.MaxTokens
 		}
-		return validate("responses", res, extractTextFromResponses)
+		return probe("responses", reqURL, headers, body, extractTextFromResponses, parseResponsesStreamChunk)
 
 	case "anthropic":
 		reqURL := baseURL + "/v1/messages"
@@ -952,61 +1894,128 @@ func (ms *MonitorService) detectOne(target *Target, modelID string, client *http
 			extHeaders[k] = v
 		}
 		extHeaders["anthropic-version"] = anthropicVersion
+		if target.ToolProbe {
+			return toolProbe("messages", reqURL, extHeaders, anthropicToolProbeBody(modelID, maxTokens(toolProbeMaxTokens)))
+		}
 		body := map[string]any{
 			"model":      modelID,
-			"stream":     false,
-			"max_tokens": 50,
+			"stream":     target.StreamProbe,
+			"max_tokens": maxTokens(50),
 			"messages":   []map[string]any{{"role": "user", "content": prompt}},
 		}
-		res, err := httpJSON(client, "POST", reqURL, extHeaders, body)
-		if err != nil {
-			return buildFail("messages", err.Error(), 0, nil, false)
-		}
-		return validate("messages", res, extractTextFromAnthropic)
+		return probe("messages", reqURL, extHeaders, body, extractTextFromAnthropic, parseAnthropicStreamChunk)
 
 	case "gemini":
-		segments := strings.Split(modelID, "/")
-		quotedParts := make([]string, 0, len(segments))
-		for i, seg := range segments {
-			if i == len(segments)-1 {
-				quotedParts = append(quotedParts, url.PathEscape(seg)+":generateContent")
-			} else {
-				quotedParts = append(quotedParts, url.PathEscape(seg))
-			}
+		method := ":generateContent"
+		if target.StreamProbe {
+			method = ":streamGenerateContent"
+		}
+		reqURL := geminiModelURL(baseURL, modelID, method)
+		if target.StreamProbe {
+			reqURL += "?alt=sse"
 		}
-		path := strings.Join(quotedParts, "/")
-		reqURL := baseURL + "/v1beta/models/" + path
 		body := map[string]any{
 			"contents":         []map[string]any{{"parts": []map[string]any{{"text": prompt}}}},
-			"generationConfig": map[string]any{"maxOutputTokens": 10},
-		}
-		res, err := httpJSON(client, "POST", reqURL, headers, body)
-		if err != nil {
-			return buildFail("gemini", err.Error(), 0, nil, false)
+			"generationConfig": map[string]any{"maxOutputTokens": maxTokens(10)},
 		}
-		return validate("gemini", res, extractTextFromGemini)
+		return probe("gemini", reqURL, headers, body, extractTextFromGemini, parseGeminiStreamChunk)
 
 	default:
 		return buildFail("unknown", "unknown route: "+route, 0, nil, false)
 	}
 }
 
+// geminiModelURL is the v1beta URL of a model method (e.g. ":generateContent"), with each
+// segment of modelID path-escaped.
+func geminiModelURL(baseURL, modelID, method string) string {
+	segments := strings.Split(modelID, "/")
+	quotedParts := make([]string, 0, len(segments))
+	for i, seg := range segments {
+		if i == len(segments)-1 {
+			quotedParts = append(quotedParts, url.PathEscape(seg)+method)
+		} else {
+			quotedParts = append(quotedParts, url.PathEscape(seg))
+		}
+	}
+	return baseURL + "/v1beta/models/" + strings.Join(quotedParts, "/")
+}
+
 // ---------------------------------------------------------------------------
 // Log cleanup
 // ---------------------------------------------------------------------------
 
-func (ms *MonitorService) cleanupDataLogs() {
-	ms.mu.Lock()
-	enabled := ms.enableLogCleanup
-	maxBytes := ms.logMaxBytes
-	ms.mu.Unlock()
+// maxLogAgeDays caps log_max_age_days, globally and per target (ten years).
+const maxLogAgeDays = 3650
 
-	if !enabled || maxBytes <= 0 {
-		return
+// isRunLogName reports whether name is a run log, plain or compressed.
+func isRunLogName(name string) bool {
+	return strings.HasSuffix(name, ".jsonl") || strings.HasSuffix(name, ".jsonl.gz")
+}
+
+// compressRunLog replaces a finished run log with a gzip copy at logFile+".gz" and
+// points the run and target at it. On any failure the plain file is kept. It returns
+// the path of the log as it is now, or "" when there is no log file.
+func (ms *MonitorService) compressRunLog(logFile string) string {
+	src, err := os.Open(logFile)
+	if err != nil {
+		return ""
 	}
-	ms.cleanupMu.Lock()
-	defer ms.cleanupMu.Unlock()
+	defer src.Close()
+	gzPath := logFile + ".gz"
+	tmp, err := os.CreateTemp(filepath.Dir(logFile), ".log-*.tmp")
+	if err != nil {
+		ms.logger().Warn("run log compression failed", "file", logFile, "error", err)
+		return logFile
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	gz := gzip.NewWriter(tmp)
+	_, err = io.Copy(gz, src)
+	_ = src.Close()
+	if err == nil {
+		err = gz.Close()
+	}
+	if err == nil {
+		err = tmp.Close()
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), gzPath)
+	}
+	if err == nil {
+		err = ms.db.RenameLogFile(logFile, gzPath)
+	}
+	if err != nil {
+		_ = os.Remove(gzPath)
+		ms.logger().Warn("run log compression failed", "file", logFile, "error", err)
+		return logFile
+	}
+	_ = os.Remove(logFile)
+	return gzPath
+}
+
+// runLogEntry is one run log file in the log directory.
+type runLogEntry struct {
+	path     string
+	targetID int
+	mtime    time.Time
+	size     int64
+}
 
+// runLogTargetID returns the target id in a run log name (target_<id>_<ts>.jsonl), or 0.
+func runLogTargetID(name string) int {
+	rest, ok := strings.CutPrefix(name, "target_")
+	if !ok {
+		return 0
+	}
+	idStr, _, _ := strings.Cut(rest, "_")
+	id, _ := strconv.Atoi(idStr)
+	return id
+}
+
+// listRunLogs returns the run logs in the log directory, newest first. Logs of runs in
+// progress are left out.
+func (ms *MonitorService) listRunLogs() ([]runLogEntry, error) {
 	ms.mu.Lock()
 	activeFiles := make(map[string]bool)
 	for f := range ms.activeLogFiles {
@@ -1014,20 +2023,14 @@ func (ms *MonitorService) cleanupDataLogs() {
 	}
 	ms.mu.Unlock()
 
-	type logEntry struct {
-		path  string
-		mtime time.Time
-		size  int64
-	}
-
 	entries, err := os.ReadDir(ms.logDir)
 	if err != nil {
-		return
+		return nil, err
 	}
 
-	var logs []logEntry
+	var logs []runLogEntry
 	for _, e := range entries {
-		if e.IsDir() || !strings.HasSuffix(e.Name(), ".jsonl") {
+		if e.IsDir() || !isRunLogName(e.Name()) {
 			continue
 		}
 		fullPath, _ := filepath.Abs(filepath.Join(ms.logDir, e.Name()))
@@ -1038,24 +2041,69 @@ func (ms *MonitorService) cleanupDataLogs() {
 		if err != nil {
 			continue
 		}
-		logs = append(logs, logEntry{path: fullPath, mtime: info.ModTime(), size: info.Size()})
+		logs = append(logs, runLogEntry{path: fullPath, targetID: runLogTargetID(e.Name()), mtime: info.ModTime(), size: info.Size()})
 	}
 
 	// Sort newest first
 	sort.Slice(logs, func(i, j int) bool { return logs[i].mtime.After(logs[j].mtime) })
+	return logs, nil
+}
 
-	var totalBytes int64
-	for _, l := range logs {
-		totalBytes += l.size
+// cleanupDataLogs removes logs older than their target's log_max_age_days (or the
+// global log_max_age_days), then the oldest logs until the directory fits log_max_size_mb.
+func (ms *MonitorService) cleanupDataLogs() {
+	ms.mu.Lock()
+	enabled := ms.enableLogCleanup
+	maxBytes := ms.logMaxBytes
+	maxAgeDays := ms.logMaxAgeDays
+	ms.mu.Unlock()
+
+	if !enabled {
+		return
+	}
+	overrides, err := ms.db.LogMaxAgeOverrides()
+	if err != nil {
+		ms.logger().Warn("log cleanup skipped", "error", err)
+		return
+	}
+	if maxBytes <= 0 && maxAgeDays <= 0 && len(overrides) == 0 {
+		return
 	}
-	if totalBytes <= maxBytes {
+	ms.cleanupMu.Lock()
+	defer ms.cleanupMu.Unlock()
+
+	logs, err := ms.listRunLogs()
+	if err != nil {
 		return
 	}
 
-	// Delete oldest files until under limit
 	var deletedFiles int
 	var deletedBytes int64
-	for i := len(logs) - 1; i >= 0; i-- {
+	now := time.Now()
+	kept := logs[:0]
+	for _, l := range logs {
+		days := maxAgeDays
+		if n := overrides[l.targetID]; n > 0 {
+			days = n
+		}
+		if days > 0 && now.Sub(l.mtime) > time.Duration(days)*24*time.Hour {
+			if err := os.Remove(l.path); err == nil {
+				deletedFiles++
+				deletedBytes += l.size
+				continue
+			}
+		}
+		kept = append(kept, l)
+	}
+	logs = kept
+
+	var totalBytes int64
+	for _, l := range logs {
+		totalBytes += l.size
+	}
+
+	// Delete oldest files until under limit
+	for i := len(logs) - 1; maxBytes > 0 && i >= 0; i-- {
 		if totalBytes <= maxBytes {
 			break
 		}
@@ -1068,10 +2116,11 @@ func (ms *MonitorService) cleanupDataLogs() {
 	}
 
 	if deletedFiles > 0 {
-		log.Printf("[monitor] cleanup data/logs removed files=%d reclaimed=%.2fMB (max_mb=%d)",
-			deletedFiles,
-			float64(deletedBytes)/1024.0/1024.0,
-			maxBytes/1024/1024,
+		ms.logger().Info("log cleanup removed files",
+			"files", deletedFiles,
+			"reclaimed_mb", float64(deletedBytes)/1024.0/1024.0,
+			"max_mb", maxBytes/1024/1024,
+			"max_age_days", maxAgeDays,
 		)
 	}
 }
@@ -0,0 +1,352 @@
+package app
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// MQTT event publishing. With MQTT_BROKER set (tcp://host:1883 or mqtt://, and
+// mqtts:// / ssl:// / tls:// for TLS on 8883), run_completed and the incident and
+// flapping events are published with QoS 0 to <prefix>/events/<event>, and each
+// target's status is kept as a retained message on <prefix>/targets/<id>/status that
+// is republished whenever it changes, so Home Assistant or Node-RED can react to
+// outages and see the current state after subscribing. MQTT_TOPIC_PREFIX defaults to
+// "api_monitor". Publishing never blocks a run: while the broker is unreachable
+// messages are dropped and counted, and the connection is retried.
+
+const (
+	mqttQueueSize    = 1024
+	mqttKeepAlive    = 60 * time.Second
+	mqttDialTimeout  = 10 * time.Second
+	mqttRetryBackoff = 15 * time.Second
+
+	defaultMQTTTopicPrefix = "api_monitor"
+)
+
+// mqttEvents are the bus events published to MQTT.
+var mqttEvents = map[string]bool{
+	"run_completed":   true,
+	"incident_opened": true,
+	"incident_closed": true,
+	"target_flapping": true,
+	"flapping_ended":  true,
+}
+
+type mqttMessage struct {
+	topic   string
+	payload []byte
+	retain  bool
+}
+
+type mqttPublisher struct {
+	addr     string
+	useTLS   bool
+	clientID string
+	username string
+	password string
+	prefix   string
+	log      *slog.Logger
+
+	queue   chan busEvent
+	stop    chan struct{}
+	done    chan struct{}
+	dropped atomic.Int64
+
+	// The fields below are only used by the loop goroutine.
+	conn      net.Conn
+	nextDial  time.Time
+	lastWrite time.Time
+	statuses  map[int]string
+}
+
+var mqttPublisherInstance atomic.Pointer[mqttPublisher]
+
+// mqttPublisherFromEnv builds the publisher configured by the MQTT_* environment, or
+// returns nil when MQTT_BROKER is unset.
+func mqttPublisherFromEnv() (*mqttPublisher, error) {
+	broker := strings.TrimSpace(os.Getenv("MQTT_BROKER"))
+	if broker == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(broker)
+	if err != nil || u.Hostname() == "" {
+		return nil, fmt.Errorf("MQTT_BROKER must be a URL such as tcp://host:1883, got %q", broker)
+	}
+	p := &mqttPublisher{
+		clientID: strings.TrimSpace(os.Getenv("MQTT_CLIENT_ID")),
+		username: strings.TrimSpace(os.Getenv("MQTT_USERNAME")),
+		password: os.Getenv("MQTT_PASSWORD"),
+		prefix:   strings.Trim(strings.TrimSpace(os.Getenv("MQTT_TOPIC_PREFIX")), "/"),
+	}
+	port := "1883"
+	switch u.Scheme {
+	case "tcp", "mqtt":
+	case "ssl", "tls", "mqtts":
+		p.useTLS = true
+		port = "8883"
+	default:
+		return nil, fmt.Errorf("MQTT_BROKER scheme must be tcp, mqtt, ssl, tls or mqtts, got %q", u.Scheme)
+	}
+	if u.Port() != "" {
+		port = u.Port()
+	}
+	p.addr = net.JoinHostPort(u.Hostname(), port)
+	if u.User != nil && p.username == "" {
+		p.username = u.User.Username()
+		p.password, _ = u.User.Password()
+	}
+	if p.prefix == "" {
+		p.prefix = defaultMQTTTopicPrefix
+	}
+	if strings.ContainsAny(p.prefix, "#+") {
+		return nil, fmt.Errorf("MQTT_TOPIC_PREFIX must not contain wildcards, got %q", p.prefix)
+	}
+	if p.clientID == "" {
+		host, _ := os.Hostname()
+		p.clientID = "api_monitor-" + host
+	}
+	return p, nil
+}
+
+// startMQTTPublisher installs the publisher configured by the environment and returns a
+// shutdown function that sends pending messages. It is a no-op when MQTT_BROKER is unset.
+func startMQTTPublisher(logger *slog.Logger) (func(context.Context), error) {
+	p, err := mqttPublisherFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return func(context.Context) {}, nil
+	}
+	p.start(logger)
+	p.log.Info("mqtt publishing enabled", "broker", p.addr, "tls", p.useTLS, "prefix", p.prefix)
+	return p.shutdown, nil
+}
+
+func (p *mqttPublisher) start(logger *slog.Logger) {
+	p.log = componentLogger(logger, "mqtt")
+	p.queue = make(chan busEvent, mqttQueueSize)
+	p.stop = make(chan struct{})
+	p.done = make(chan struct{})
+	p.statuses = map[int]string{}
+	go p.loop()
+	mqttPublisherInstance.Store(p)
+}
+
+// publishMQTTEvent queues a bus event for MQTT, if a broker is configured.
+func publishMQTTEvent(event, data string) {
+	p := mqttPublisherInstance.Load()
+	if p == nil || !mqttEvents[event] {
+		return
+	}
+	select {
+	case p.queue <- busEvent{Event: event, Data: data}:
+	default:
+		p.dropped.Add(1)
+	}
+}
+
+func (p *mqttPublisher) loop() {
+	defer close(p.done)
+	ticker := time.NewTicker(mqttKeepAlive / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case ev := <-p.queue:
+			p.handle(ev)
+		case <-ticker.C:
+			if p.conn != nil && time.Since(p.lastWrite) >= mqttKeepAlive/2 {
+				p.write([]byte{0xC0, 0x00}) // PINGREQ
+			}
+		case <-p.stop:
+			for {
+				select {
+				case ev := <-p.queue:
+					p.handle(ev)
+				default:
+					if p.conn != nil {
+						p.write([]byte{0xE0, 0x00}) // DISCONNECT
+						_ = p.conn.Close()
+					}
+					return
+				}
+			}
+		}
+	}
+}
+
+func (p *mqttPublisher) shutdown(ctx context.Context) {
+	mqttPublisherInstance.CompareAndSwap(p, nil)
+	close(p.stop)
+	select {
+	case <-p.done:
+	case <-ctx.Done():
+	}
+	if n := p.dropped.Load(); n > 0 {
+		p.log.Warn("mqtt messages dropped", "dropped", n)
+	}
+}
+
+// messages turns a bus event into the MQTT messages to publish, tracking target
+// statuses to publish the retained status topic on changes.
+func (p *mqttPublisher) messages(ev busEvent) []mqttMessage {
+	out := []mqttMessage{{topic: p.prefix + "/events/" + ev.Event, payload: []byte(ev.Data)}}
+	if ev.Event != "run_completed" {
+		return out
+	}
+	var data map[string]any
+	if json.Unmarshal([]byte(ev.Data), &data) != nil {
+		return out
+	}
+	id, ok := anyFloat(data["target_id"])
+	status, _ := data["status"].(string)
+	if !ok || status == "" || p.statuses[int(id)] == status {
+		return out
+	}
+	var previous any
+	if prev := p.statuses[int(id)]; prev != "" {
+		previous = prev
+	}
+	p.statuses[int(id)] = status
+	payload, _ := json.Marshal(map[string]any{
+		"target_id":   int(id),
+		"target_name": data["target_name"],
+		"status":      status,
+		"previous":    previous,
+		"changed_at":  float64(time.Now().UnixMilli()) / 1000.0,
+	})
+	return append(out, mqttMessage{topic: p.prefix + "/targets/" + strconv.Itoa(int(id)) + "/status", payload: payload, retain: true})
+}
+
+func (p *mqttPublisher) handle(ev busEvent) {
+	for _, msg := range p.messages(ev) {
+		if !p.connect() {
+			p.dropped.Add(1)
+			continue
+		}
+		if !p.write(mqttPublishPacket(msg)) {
+			p.dropped.Add(1)
+		}
+	}
+}
+
+// connect ensures a broker connection, waiting mqttRetryBackoff between failed attempts.
+func (p *mqttPublisher) connect() bool {
+	if p.conn != nil {
+		return true
+	}
+	if time.Now().Before(p.nextDial) {
+		return false
+	}
+	conn, err := p.dial()
+	if err != nil {
+		p.nextDial = time.Now().Add(mqttRetryBackoff)
+		p.log.Warn("mqtt connect failed", "broker", p.addr, "error", err)
+		return false
+	}
+	p.conn = conn
+	p.lastWrite = time.Now()
+	// Only PINGRESP arrives on a QoS 0 publish-only session; drain it.
+	go func() { _, _ = io.Copy(io.Discard, conn) }()
+	p.log.Info("mqtt connected", "broker", p.addr)
+	return true
+}
+
+func (p *mqttPublisher) dial() (net.Conn, error) {
+	conn, err := net.DialTimeout("tcp", p.addr, mqttDialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	if p.useTLS {
+		host, _, _ := net.SplitHostPort(p.addr)
+		conn = tls.Client(conn, &tls.Config{ServerName: host})
+	}
+	_ = conn.SetDeadline(time.Now().Add(mqttDialTimeout))
+	if _, err := conn.Write(mqttConnectPacket(p.clientID, p.username, p.password)); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	var ack [4]byte
+	if _, err := io.ReadFull(conn, ack[:]); err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("read CONNACK: %w", err)
+	}
+	if ack[0] != 0x20 || ack[3] != 0 {
+		_ = conn.Close()
+		return nil, fmt.Errorf("broker refused connection (return code %d)", ack[3])
+	}
+	_ = conn.SetDeadline(time.Time{})
+	return conn, nil
+}
+
+func (p *mqttPublisher) write(packet []byte) bool {
+	_ = p.conn.SetWriteDeadline(time.Now().Add(mqttDialTimeout))
+	if _, err := p.conn.Write(packet); err != nil {
+		p.log.Warn("mqtt write failed", "broker", p.addr, "error", err)
+		_ = p.conn.Close()
+		p.conn = nil
+		return false
+	}
+	p.lastWrite = time.Now()
+	return true
+}
+
+// mqttPacket frames body as an MQTT 3.1.1 control packet.
+func mqttPacket(header byte, body []byte) []byte {
+	out := []byte{header}
+	n := len(body)
+	for {
+		b := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			b |= 0x80
+		}
+		out = append(out, b)
+		if n == 0 {
+			break
+		}
+	}
+	return append(out, body...)
+}
+
+func mqttString(s string) []byte {
+	out := binary.BigEndian.AppendUint16(nil, uint16(len(s)))
+	return append(out, s...)
+}
+
+func mqttConnectPacket(clientID, username, password string) []byte {
+	flags := byte(0x02) // clean session
+	body := append(mqttString("MQTT"), 4)
+	payload := mqttString(clientID)
+	if username != "" {
+		flags |= 0x80
+		payload = append(payload, mqttString(username)...)
+		if password != "" {
+			flags |= 0x40
+			payload = append(payload, mqttString(password)...)
+		}
+	}
+	body = append(body, flags)
+	body = binary.BigEndian.AppendUint16(body, uint16(mqttKeepAlive/time.Second))
+	return mqttPacket(0x10, append(body, payload...))
+}
+
+func mqttPublishPacket(msg mqttMessage) []byte {
+	header := byte(0x30)
+	if msg.retain {
+		header |= 0x01
+	}
+	return mqttPacket(header, append(mqttString(msg.topic), msg.payload...))
+}
@@ -0,0 +1,113 @@
+package app
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// readMQTTPacket reads one control packet and returns its header byte and body.
+func readMQTTPacket(r *bufio.Reader) (byte, []byte, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	n, mult := 0, 1
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		n += int(b&0x7F) * mult
+		mult *= 128
+		if b&0x80 == 0 {
+			break
+		}
+	}
+	body := make([]byte, n)
+	_, err = io.ReadFull(r, body)
+	return header, body, err
+}
+
+func TestMQTTPublisherSendsEventsAndRetainedStatus(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	type publish struct {
+		topic, payload string
+		retain         bool
+	}
+	got := make(chan publish, 16)
+	connect := make(chan []byte, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		_, body, err := readMQTTPacket(r)
+		if err != nil {
+			return
+		}
+		connect <- body
+		_, _ = conn.Write([]byte{0x20, 0x02, 0x00, 0x00})
+		for {
+			header, body, err := readMQTTPacket(r)
+			if err != nil {
+				close(got)
+				return
+			}
+			if header&0xF0 == 0x30 {
+				n := int(body[0])<<8 | int(body[1])
+				got <- publish{topic: string(body[2 : 2+n]), payload: string(body[2+n:]), retain: header&0x01 != 0}
+			}
+		}
+	}()
+
+	t.Setenv("MQTT_BROKER", "tcp://user:pw@"+ln.Addr().String())
+	t.Setenv("MQTT_TOPIC_PREFIX", "lab/monitor/")
+	p, err := mqttPublisherFromEnv()
+	if err != nil {
+		t.Fatalf("mqttPublisherFromEnv failed: %v", err)
+	}
+	p.start(nil)
+	publishMQTTEvent("run_completed", `{"target_id":3,"target_name":"relay","status":"down"}`)
+	publishMQTTEvent("model_checked", `{"target_id":3}`)
+	publishMQTTEvent("run_completed", `{"target_id":3,"target_name":"relay","status":"down"}`)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	p.shutdown(ctx)
+
+	if body := <-connect; body[7]&0xC2 != 0xC2 {
+		t.Fatalf("CONNECT should carry credentials and clean session, flags=%#x", body[7])
+	}
+	var msgs []publish
+	for msg := range got {
+		msgs = append(msgs, msg)
+	}
+	if len(msgs) != 3 {
+		t.Fatalf("expected two events and one status change, got=%+v", msgs)
+	}
+	if msgs[0].topic != "lab/monitor/events/run_completed" || msgs[0].retain {
+		t.Fatalf("unexpected event message: %+v", msgs[0])
+	}
+	if msgs[1].topic != "lab/monitor/targets/3/status" || !msgs[1].retain || msgs[2].topic != "lab/monitor/events/run_completed" {
+		t.Fatalf("status should be retained and only republished on change, got=%+v", msgs)
+	}
+}
+
+func TestMQTTBrokerValidation(t *testing.T) {
+	for _, broker := range []string{"http://broker:1883", "tcp://", "tcp://b:1883"} {
+		t.Setenv("MQTT_BROKER", broker)
+		t.Setenv("MQTT_TOPIC_PREFIX", "a/#")
+		if _, err := mqttPublisherFromEnv(); err == nil {
+			t.Fatalf("broker %q with a wildcard prefix should be rejected", broker)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Multi-turn probe. The multi_turn capability asks for a four-digit number, then sends
+// the exchange back with a follow-up asking for that number plus one. Channels that
+// drop the history or answer from a stateless cache cannot produce the sum. The verdict
+// duration covers both requests.
+
+const (
+	multiTurnFirstPrompt = "Pick a random four-digit number and reply with the number only."
+	multiTurnFollowUp    = "Add 1 to the number you just gave me and reply with the result only."
+)
+
+var multiTurnNumber = regexp.MustCompile(`\b\d{4}\b`)
+
+// probeMultiTurn sends the two-turn multi_turn probe on route.
+func probeMultiTurn(ctx context.Context, target *Target, modelID, route string, client *http.Client) *CapabilityResult {
+	turns := []probeTurn{{Role: "user", Text: multiTurnFirstPrompt}}
+	req := textCapabilityRequest(target, modelID, route, turns, 20)
+	if req == nil {
+		return nil
+	}
+	var answer, number string
+	first := req.send(ctx, client, capabilityMultiTurn, func(text string) error {
+		answer = text
+		number = multiTurnNumber.FindString(text)
+		if number == "" {
+			return fmt.Errorf("multi_turn: first reply has no four-digit number: %s", truncStr(text, 120))
+		}
+		return nil
+	})
+	if first == nil || !first.Supported {
+		return first
+	}
+	n, _ := strconv.Atoi(number)
+	want := strconv.Itoa(n + 1)
+	turns = append(turns, probeTurn{Role: "assistant", Text: answer}, probeTurn{Role: "user", Text: multiTurnFollowUp})
+	second := textCapabilityRequest(target, modelID, route, turns, 20).send(ctx, client, capabilityMultiTurn, func(text string) error {
+		if !strings.Contains(text, want) {
+			return fmt.Errorf("multi_turn: follow-up reply lost the context (expected %s after %s): %s", want, number, truncStr(text, 120))
+		}
+		return nil
+	})
+	if second != nil {
+		second.Duration += first.Duration
+	}
+	return second
+}
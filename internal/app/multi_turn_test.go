@@ -0,0 +1,45 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMultiTurnProbeNeedsTheHistory(t *testing.T) {
+	stateless := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Contents []struct {
+				Role  string `json:"role"`
+				Parts []struct {
+					Text string `json:"text"`
+				} `json:"parts"`
+			} `json:"contents"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		text := "4711"
+		if len(body.Contents) == 3 && !stateless {
+			if body.Contents[1].Role != "model" || !strings.Contains(body.Contents[1].Parts[0].Text, "4711") {
+				t.Errorf("follow-up should carry the first answer as a model turn, got %+v", body.Contents)
+			}
+			text = "4712"
+		}
+		_, _ = w.Write([]byte(`{"candidates":[{"content":{"parts":[{"text":"` + text + `"}]}}]}`))
+	}))
+	defer srv.Close()
+
+	target := &Target{ID: 1, BaseURL: srv.URL, APIKey: "k"}
+	client := httpClient(5, false, nil, tlsFingerprintNone)
+	if res := probeMultiTurn(context.Background(), target, "gemini-x", "gemini", client); res == nil || !res.Supported {
+		t.Fatalf("a channel keeping the context should pass, got %+v", res)
+	}
+	stateless = true
+	res := probeMultiTurn(context.Background(), target, "gemini-x", "gemini", client)
+	if res == nil || res.Supported || !strings.Contains(res.Detail, "expected 4712") {
+		t.Fatalf("a stateless reply should fail, got %+v", res)
+	}
+}
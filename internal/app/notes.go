@@ -0,0 +1,261 @@
+package app
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Note is a free-text annotation on a target, or on one of its runs when RunID is set,
+// e.g. "provider acknowledged outage" or "key rotated".
+type Note struct {
+	ID        int     `json:"id"`
+	TargetID  int     `json:"target_id"`
+	RunID     *int    `json:"run_id"`
+	Text      string  `json:"text"`
+	Role      string  `json:"role"`
+	CreatedAt float64 `json:"created_at"`
+}
+
+type noteRequest struct {
+	Text  string `json:"text"`
+	RunID *int   `json:"run_id"`
+}
+
+// EnsureNoteSchema creates the notes table.
+func (d *Database) EnsureNoteSchema() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	_, err := d.conn.Exec(`
+		CREATE TABLE IF NOT EXISTS notes (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			target_id INTEGER NOT NULL,
+			run_id INTEGER,
+			text TEXT NOT NULL,
+			role TEXT NOT NULL DEFAULT '',
+			created_at REAL NOT NULL,
+			FOREIGN KEY(target_id) REFERENCES targets(id) ON DELETE CASCADE,
+			FOREIGN KEY(run_id) REFERENCES runs(id) ON DELETE CASCADE
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_notes_target_run
+		ON notes(target_id, run_id);
+	`)
+	if err != nil {
+		return fmt.Errorf("init note schema: %w", err)
+	}
+	return nil
+}
+
+const noteColumns = `id, target_id, run_id, text, role, created_at`
+
+func scanNote(r interface{ Scan(dest ...any) error }) (*Note, error) {
+	var n Note
+	if err := r.Scan(&n.ID, &n.TargetID, &n.RunID, &n.Text, &n.Role, &n.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
+// ListNotes returns a target's notes, newest first. With runIDs set only notes on those
+// runs are returned; with targetOnly only notes on the target itself.
+func (d *Database) ListNotes(targetID int, runIDs []int, targetOnly bool) ([]Note, error) {
+	query := "SELECT " + noteColumns + " FROM notes WHERE target_id = ?"
+	args := []any{targetID}
+	switch {
+	case targetOnly:
+		query += " AND run_id IS NULL"
+	case len(runIDs) > 0:
+		query += " AND run_id IN (?" + strings.Repeat(", ?", len(runIDs)-1) + ")"
+		for _, id := range runIDs {
+			args = append(args, id)
+		}
+	}
+	query += " ORDER BY created_at DESC, id DESC"
+
+	rows, err := d.read.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]Note, 0)
+	for rows.Next() {
+		n, err := scanNote(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *n)
+	}
+	return out, rows.Err()
+}
+
+// GetNote returns a note of a target by id, or nil when it does not exist.
+func (d *Database) GetNote(targetID, noteID int) (*Note, error) {
+	n, err := scanNote(d.read.QueryRow("SELECT "+noteColumns+" FROM notes WHERE target_id = ? AND id = ?", targetID, noteID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return n, err
+}
+
+// CreateNote inserts a note.
+func (d *Database) CreateNote(targetID int, runID *int, text, role string) (*Note, error) {
+	d.mu.Lock()
+	res, err := d.conn.Exec(
+		"INSERT INTO notes (target_id, run_id, text, role, created_at) VALUES (?, ?, ?, ?, ?)",
+		targetID, runID, text, role, float64(time.Now().UnixMilli())/1000.0,
+	)
+	d.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	id, _ := res.LastInsertId()
+	return d.GetNote(targetID, int(id))
+}
+
+// DeleteNote removes a note of a target.
+func (d *Database) DeleteNote(targetID, noteID int) (bool, error) {
+	d.mu.Lock()
+	res, err := d.conn.Exec("DELETE FROM notes WHERE target_id = ? AND id = ?", targetID, noteID)
+	d.mu.Unlock()
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// attachRunNotes fills Notes on each run from one query.
+func (d *Database) attachRunNotes(targetID int, runs []Run) error {
+	if len(runs) == 0 {
+		return nil
+	}
+	ids := make([]int, len(runs))
+	for i, run := range runs {
+		ids[i] = run.ID
+	}
+	notes, err := d.ListNotes(targetID, ids, false)
+	if err != nil {
+		return err
+	}
+	byRun := map[int][]Note{}
+	for _, n := range notes {
+		byRun[*n.RunID] = append(byRun[*n.RunID], n)
+	}
+	for i := range runs {
+		runs[i].Notes = byRun[runs[i].ID]
+	}
+	return nil
+}
+
+// ListTargetNotes -- GET /api/targets/{id}/notes?run_id=
+// Without run_id every note of the target is returned, including those on runs.
+func (h *Handlers) ListTargetNotes(w http.ResponseWriter, r *http.Request) {
+	target, ok := h.loadNoteTarget(w, r)
+	if !ok {
+		return
+	}
+	var runIDs []int
+	if s := r.URL.Query().Get("run_id"); s != "" {
+		runID, err := strconv.Atoi(s)
+		if err != nil || runID < 1 {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "invalid run_id"})
+			return
+		}
+		runIDs = []int{runID}
+	}
+	items, err := h.db.ListNotes(target.ID, runIDs, false)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"items": items})
+}
+
+// CreateTargetNote -- POST /api/targets/{id}/notes
+// Body: {"text": "...", "run_id": 12}; run_id is optional and must be a run of the target.
+func (h *Handlers) CreateTargetNote(w http.ResponseWriter, r *http.Request) {
+	target, ok := h.loadNoteTarget(w, r)
+	if !ok || !h.requireChannelOperationPermission(w, r, target) {
+		return
+	}
+	var req noteRequest
+	if err := readJSON(r, &req); err != nil {
+		writeRequestBodyError(w, err)
+		return
+	}
+	text := strings.TrimSpace(req.Text)
+	if text == "" || len(text) > 4000 {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "text must be 1-4000 chars"})
+		return
+	}
+	if req.RunID != nil {
+		run, err := h.db.GetRun(target.ID, *req.RunID)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+			return
+		}
+		if run == nil {
+			writeJSON(w, http.StatusNotFound, map[string]any{"detail": "run not found"})
+			return
+		}
+	}
+	item, err := h.db.CreateNote(target.ID, req.RunID, text, string(authRoleFromRequest(r)))
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return
+	}
+	h.audit(r, "target.note", "target", target.ID, map[string]any{"note": map[string]any{"from": nil, "to": text}})
+	writeJSON(w, http.StatusOK, map[string]any{"ok": true, "item": item})
+}
+
+// DeleteTargetNote -- DELETE /api/targets/{id}/notes/{note_id}
+func (h *Handlers) DeleteTargetNote(w http.ResponseWriter, r *http.Request) {
+	target, ok := h.loadNoteTarget(w, r)
+	if !ok || !h.requireChannelOperationPermission(w, r, target) {
+		return
+	}
+	noteID, err := strconv.Atoi(r.PathValue("note_id"))
+	if err != nil || noteID < 1 {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "invalid note_id"})
+		return
+	}
+	note, err := h.db.GetNote(target.ID, noteID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return
+	}
+	if note == nil {
+		writeJSON(w, http.StatusNotFound, map[string]any{"detail": "note not found"})
+		return
+	}
+	if _, err := h.db.DeleteNote(target.ID, noteID); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return
+	}
+	h.audit(r, "target.note_delete", "target", target.ID, map[string]any{"note": map[string]any{"from": note.Text, "to": nil}})
+	writeJSON(w, http.StatusOK, map[string]any{"ok": true})
+}
+
+func (h *Handlers) loadNoteTarget(w http.ResponseWriter, r *http.Request) (*Target, bool) {
+	id, ok := pathID(r)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "invalid id"})
+		return nil, false
+	}
+	target, err := h.db.GetTarget(id)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return nil, false
+	}
+	if target == nil {
+		writeJSON(w, http.StatusNotFound, map[string]any{"detail": "target not found"})
+		return nil, false
+	}
+	return target, true
+}
@@ -0,0 +1,86 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestTargetAndRunNotes(t *testing.T) {
+	db, err := NewDatabase(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDatabase failed: %v", err)
+	}
+	t.Cleanup(func() { _ = db.conn.Close() })
+	for _, fn := range []func() error{db.EnsureAuditSchema, db.EnsureNoteSchema} {
+		if err := fn(); err != nil {
+			t.Fatalf("schema init failed: %v", err)
+		}
+	}
+	h := &Handlers{db: db, monitor: NewMonitorService(MonitorConfig{DB: db, LogDir: t.TempDir()})}
+	target, err := db.CreateTarget(map[string]any{"name": "relay", "base_url": "https://relay.example.com", "api_key": "k"})
+	if err != nil {
+		t.Fatalf("CreateTarget failed: %v", err)
+	}
+	runID, err := db.CreateRun(target.ID, 1, "", nil, runModeFull)
+	if err != nil {
+		t.Fatalf("CreateRun failed: %v", err)
+	}
+
+	call := func(handler http.HandlerFunc, method, path, body string, noteID int) (int, map[string]any) {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.SetPathValue("id", strconv.Itoa(target.ID))
+		if noteID > 0 {
+			req.SetPathValue("note_id", strconv.Itoa(noteID))
+		}
+		rr := httptest.NewRecorder()
+		handler(rr, withAuthRole(req, authRoleAdmin))
+		var out map[string]any
+		_ = json.Unmarshal(rr.Body.Bytes(), &out)
+		return rr.Code, out
+	}
+
+	if code, _ := call(h.CreateTargetNote, http.MethodPost, "/api/targets/1/notes", `{"text":"  "}`, 0); code != http.StatusBadRequest {
+		t.Fatalf("empty note should be rejected, got=%d", code)
+	}
+	if code, _ := call(h.CreateTargetNote, http.MethodPost, "/api/targets/1/notes", `{"text":"x","run_id":999}`, 0); code != http.StatusNotFound {
+		t.Fatalf("note on an unknown run should be 404, got=%d", code)
+	}
+	code, out := call(h.CreateTargetNote, http.MethodPost, "/api/targets/1/notes", `{"text":"key rotated"}`, 0)
+	if code != http.StatusOK {
+		t.Fatalf("target note should be created, got=%d body=%v", code, out)
+	}
+	targetNoteID := int(out["item"].(map[string]any)["id"].(float64))
+	code, _ = call(h.CreateTargetNote, http.MethodPost, "/api/targets/1/notes",
+		`{"text":"provider acknowledged outage","run_id":`+strconv.Itoa(runID)+`}`, 0)
+	if code != http.StatusOK {
+		t.Fatalf("run note should be created, got=%d", code)
+	}
+
+	code, out = call(h.ListRuns, http.MethodGet, "/api/targets/1/runs", "", 0)
+	items, _ := out["items"].([]any)
+	if code != http.StatusOK || len(items) != 1 {
+		t.Fatalf("ListRuns failed, got=%d body=%v", code, out)
+	}
+	runNotes, _ := items[0].(map[string]any)["notes"].([]any)
+	if len(runNotes) != 1 || runNotes[0].(map[string]any)["text"] != "provider acknowledged outage" {
+		t.Fatalf("runs list should carry the run's notes, got=%v", items[0])
+	}
+	if notes, _ := out["notes"].([]any); len(notes) != 1 || notes[0].(map[string]any)["text"] != "key rotated" {
+		t.Fatalf("runs list should carry the target's own notes, got=%v", out["notes"])
+	}
+
+	if _, out = call(h.ListTargetNotes, http.MethodGet, "/api/targets/1/notes", "", 0); len(out["items"].([]any)) != 2 {
+		t.Fatalf("target notes should include run notes, got=%v", out["items"])
+	}
+	if code, _ = call(h.DeleteTargetNote, http.MethodDelete, "/api/targets/1/notes/x", "", targetNoteID); code != http.StatusOK {
+		t.Fatalf("DeleteTargetNote failed, got=%d", code)
+	}
+	if code, _ = call(h.DeleteTargetNote, http.MethodDelete, "/api/targets/1/notes/x", "", targetNoteID); code != http.StatusNotFound {
+		t.Fatalf("deleting a missing note should be 404, got=%d", code)
+	}
+}
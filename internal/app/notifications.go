@@ -0,0 +1,998 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Notification routing. Alert events (incident_opened, cert_expiring, ...) are sent to
+// the notification channels of every enabled route that matches them: a route names
+// event types, target tags and a minimum severity, each of which matches anything when
+// left empty. An event's severity is its default severity capped by the target's
+// severity, so a target marked "info" never pages anyone. Without routes nothing is sent.
+
+// Notification severities in ascending order.
+const (
+	severityInfo     = "info"
+	severityWarning  = "warning"
+	severityError    = "error"
+	severityCritical = "critical"
+)
+
+var notificationSeverities = []string{severityInfo, severityWarning, severityError, severityCritical}
+
+var severityRank = map[string]int{
+	severityInfo:     1,
+	severityWarning:  2,
+	severityError:    3,
+	severityCritical: 4,
+}
+
+// notificationEvents maps the events that can be routed to their default severity.
+// Recoveries share the severity of the outage they end so they reach the same channels.
+var notificationEvents = map[string]string{
+	"incident_opened": severityCritical,
+	"incident_closed": severityCritical,
+	"target_flapping": severityCritical,
+	"flapping_ended":  severityCritical,
+	"cert_expiring":   severityWarning,
+	"balance_low":     severityWarning,
+	"api_key_dead":    severityWarning,
+	"latency_anomaly": severityWarning,
+	"run_completed":   severityInfo,
+}
+
+// optInNotificationEvents only match routes that list them in event_types.
+var optInNotificationEvents = map[string]bool{"run_completed": true}
+
+// runStatusSeverity is the severity of a run_completed event by run status.
+var runStatusSeverity = map[string]string{
+	"healthy":  severityInfo,
+	"degraded": severityWarning,
+	"down":     severityError,
+	"error":    severityError,
+}
+
+// maxTargetTags caps the tags of one target.
+const maxTargetTags = 20
+
+// normalizeTargetTags lowercases and trims tags, dropping empty and duplicate ones.
+func normalizeTargetTags(tags []string) []string {
+	out := make([]string, 0, len(tags))
+	seen := map[string]bool{}
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		out = append(out, tag)
+	}
+	return out
+}
+
+// Notification is what a channel delivers; webhook channels receive it as JSON.
+type Notification struct {
+	Event      string         `json:"event"`
+	Severity   string         `json:"severity"`
+	Title      string         `json:"title"`
+	Message    string         `json:"message"`
+	TargetID   int            `json:"target_id,omitempty"`
+	TargetName string         `json:"target_name,omitempty"`
+	Tags       []string       `json:"tags"`
+	At         float64        `json:"at"`
+	Data       map[string]any `json:"data,omitempty"`
+}
+
+// describeNotification fills in the title and message of n from its event data.
+func describeNotification(n *Notification) {
+	name := n.TargetName
+	if name == "" {
+		name = fmt.Sprintf("target %d", n.TargetID)
+	}
+	msg, _ := n.Data["message"].(string)
+	switch n.Event {
+	case "incident_opened":
+		n.Title = name + " is down"
+		status, _ := n.Data["status"].(string)
+		n.Message = "Incident opened with status " + status
+		if models := stringSliceFromAny(n.Data["affected_models"]); len(models) > 0 {
+			n.Message += "; affected models: " + strings.Join(models, ", ")
+		}
+	case "incident_closed":
+		status, _ := n.Data["status"].(string)
+		n.Title = name + " recovered"
+		n.Message = "Back to " + status
+		if d, ok := anyFloat(n.Data["duration_s"]); ok {
+			n.Message += " after " + (time.Duration(d * float64(time.Second))).Round(time.Second).String() + " of downtime"
+		}
+	case "target_flapping":
+		n.Title = name + " is flapping"
+		n.Message = msg
+	case "flapping_ended":
+		n.Title = name + " is stable"
+		n.Message = msg
+	case "cert_expiring":
+		n.Title = name + " certificate expiring"
+		n.Message = msg
+	case "balance_low":
+		n.Title = name + " balance low"
+		n.Message = msg
+	case "api_key_dead":
+		key, _ := n.Data["api_key"].(string)
+		n.Title = name + " API key " + key + " unusable"
+		n.Message = msg
+	case "run_completed":
+		status, _ := n.Data["status"].(string)
+		n.Title = name + " run " + status
+		success, _ := anyFloat(n.Data["success"])
+		total, _ := anyFloat(n.Data["total"])
+		n.Message = fmt.Sprintf("%d/%d models ok", int(success), int(total))
+	case "latency_anomaly":
+		n.Title = name + " latency anomaly"
+		if models, ok := n.Data["models"].([]any); ok {
+			names := make([]string, 0, len(models))
+			for _, m := range models {
+				if row, ok := m.(map[string]any); ok {
+					if model, ok := row["model"].(string); ok {
+						names = append(names, model)
+					}
+				}
+			}
+			n.Message = "Slower than baseline: " + strings.Join(names, ", ")
+		}
+	default:
+		n.Title = name + " " + n.Event
+		n.Message = msg
+	}
+}
+
+// NotificationChannel is a configured delivery target such as a Telegram chat.
+type NotificationChannel struct {
+	ID         int            `json:"id"`
+	Name       string         `json:"name"`
+	Type       string         `json:"type"`
+	Config     map[string]any `json:"config"`
+	Enabled    bool           `json:"enabled"`
+	LastSentAt *float64       `json:"last_sent_at"`
+	LastError  *string        `json:"last_error"`
+	CreatedAt  float64        `json:"created_at"`
+	UpdatedAt  float64        `json:"updated_at"`
+}
+
+// NotificationRoute sends events matching EventTypes, Tags and MinSeverity to ChannelIDs.
+type NotificationRoute struct {
+	ID          int      `json:"id"`
+	Name        string   `json:"name"`
+	EventTypes  []string `json:"event_types"`
+	Tags        []string `json:"tags"`
+	MinSeverity string   `json:"min_severity"`
+	ChannelIDs  []int    `json:"channel_ids"`
+	Enabled     bool     `json:"enabled"`
+	CreatedAt   float64  `json:"created_at"`
+	UpdatedAt   float64  `json:"updated_at"`
+}
+
+type notificationChannelRequest struct {
+	Name    *string         `json:"name"`
+	Type    *string         `json:"type"`
+	Config  *map[string]any `json:"config"`
+	Enabled *bool           `json:"enabled"`
+}
+
+type notificationRouteRequest struct {
+	Name        *string   `json:"name"`
+	EventTypes  *[]string `json:"event_types"`
+	Tags        *[]string `json:"tags"`
+	MinSeverity *string   `json:"min_severity"`
+	ChannelIDs  *[]int    `json:"channel_ids"`
+	Enabled     *bool     `json:"enabled"`
+}
+
+// matches reports whether route r applies to an event of eventType and severity on a
+// target with tags.
+func (r *NotificationRoute) matches(eventType, severity string, tags []string) bool {
+	if !r.Enabled || severityRank[severity] < severityRank[r.MinSeverity] {
+		return false
+	}
+	if (len(r.EventTypes) > 0 || optInNotificationEvents[eventType]) && !containsString(r.EventTypes, eventType) {
+		return false
+	}
+	if len(r.Tags) == 0 {
+		return true
+	}
+	for _, tag := range tags {
+		if containsString(r.Tags, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// routeNotification returns the channel ids of the routes matching the event, in
+// ascending order and without duplicates.
+func routeNotification(routes []NotificationRoute, eventType, severity string, tags []string) []int {
+	seen := map[int]bool{}
+	var out []int
+	for i := range routes {
+		if !routes[i].matches(eventType, severity, tags) {
+			continue
+		}
+		for _, id := range routes[i].ChannelIDs {
+			if !seen[id] {
+				seen[id] = true
+				out = append(out, id)
+			}
+		}
+	}
+	sort.Ints(out)
+	return out
+}
+
+// EnsureNotificationSchema creates the notification channel and route tables.
+func (d *Database) EnsureNotificationSchema() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	_, err := d.conn.Exec(`
+		CREATE TABLE IF NOT EXISTS notification_channels (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL,
+			type TEXT NOT NULL,
+			config TEXT NOT NULL DEFAULT '{}',
+			enabled INTEGER NOT NULL DEFAULT 1,
+			last_sent_at REAL,
+			last_error TEXT,
+			created_at REAL NOT NULL,
+			updated_at REAL NOT NULL
+		);
+
+		CREATE TABLE IF NOT EXISTS notification_routes (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL DEFAULT '',
+			event_types TEXT NOT NULL DEFAULT '[]',
+			tags TEXT NOT NULL DEFAULT '[]',
+			min_severity TEXT NOT NULL DEFAULT 'info',
+			channel_ids TEXT NOT NULL DEFAULT '[]',
+			enabled INTEGER NOT NULL DEFAULT 1,
+			created_at REAL NOT NULL,
+			updated_at REAL NOT NULL
+		);
+	`)
+	if err != nil {
+		return fmt.Errorf("init notification schema: %w", err)
+	}
+	return nil
+}
+
+const notificationChannelColumns = `id, name, type, config, enabled, last_sent_at, last_error, created_at, updated_at`
+
+// scanNotificationChannel reads a channel row; the config is stored encrypted as a whole.
+func scanNotificationChannel(r interface{ Scan(dest ...any) error }) (*NotificationChannel, error) {
+	var ch NotificationChannel
+	var config string
+	var enabled int
+	if err := r.Scan(
+		&ch.ID, &ch.Name, &ch.Type, &config, &enabled,
+		&ch.LastSentAt, &ch.LastError, &ch.CreatedAt, &ch.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+	ch.Enabled = enabled != 0
+	plain, err := decryptSecret(config)
+	if err != nil {
+		return nil, fmt.Errorf("notification channel %d config: %w", ch.ID, err)
+	}
+	if err := json.Unmarshal([]byte(plain), &ch.Config); err != nil || ch.Config == nil {
+		ch.Config = map[string]any{}
+	}
+	return &ch, nil
+}
+
+func encodeChannelConfig(config map[string]any) (string, error) {
+	raw, err := json.Marshal(config)
+	if err != nil {
+		return "", err
+	}
+	return encryptSecret(string(raw))
+}
+
+// ListNotificationChannels returns all channels by id.
+func (d *Database) ListNotificationChannels() ([]NotificationChannel, error) {
+	rows, err := d.read.Query("SELECT " + notificationChannelColumns + " FROM notification_channels ORDER BY id ASC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]NotificationChannel, 0)
+	for rows.Next() {
+		ch, err := scanNotificationChannel(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *ch)
+	}
+	return out, rows.Err()
+}
+
+// GetNotificationChannel returns a channel by id, or nil when it does not exist.
+func (d *Database) GetNotificationChannel(id int) (*NotificationChannel, error) {
+	row := d.read.QueryRow("SELECT "+notificationChannelColumns+" FROM notification_channels WHERE id = ?", id)
+	ch, err := scanNotificationChannel(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return ch, err
+}
+
+// CreateNotificationChannel inserts a channel.
+func (d *Database) CreateNotificationChannel(ch *NotificationChannel) (*NotificationChannel, error) {
+	config, err := encodeChannelConfig(ch.Config)
+	if err != nil {
+		return nil, err
+	}
+	now := float64(time.Now().UnixMilli()) / 1000.0
+	d.mu.Lock()
+	res, err := d.conn.Exec(`
+		INSERT INTO notification_channels (name, type, config, enabled, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		ch.Name, ch.Type, config, boolToInt(ch.Enabled), now, now,
+	)
+	d.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	id, _ := res.LastInsertId()
+	return d.GetNotificationChannel(int(id))
+}
+
+// UpdateNotificationChannel saves all editable fields of ch.
+func (d *Database) UpdateNotificationChannel(ch *NotificationChannel) (*NotificationChannel, error) {
+	config, err := encodeChannelConfig(ch.Config)
+	if err != nil {
+		return nil, err
+	}
+	d.mu.Lock()
+	_, err = d.conn.Exec(`
+		UPDATE notification_channels SET name = ?, type = ?, config = ?, enabled = ?, updated_at = ?
+		WHERE id = ?`,
+		ch.Name, ch.Type, config, boolToInt(ch.Enabled), float64(time.Now().UnixMilli())/1000.0, ch.ID,
+	)
+	d.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return d.GetNotificationChannel(ch.ID)
+}
+
+// RecordNotificationResult stores the outcome of the latest delivery to a channel.
+func (d *Database) RecordNotificationResult(id int, at float64, errMsg *string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if errMsg != nil {
+		_, err := d.conn.Exec("UPDATE notification_channels SET last_error = ? WHERE id = ?", *errMsg, id)
+		return err
+	}
+	_, err := d.conn.Exec("UPDATE notification_channels SET last_sent_at = ?, last_error = NULL WHERE id = ?", at, id)
+	return err
+}
+
+// DeleteNotificationChannel removes a channel and drops it from every route.
+func (d *Database) DeleteNotificationChannel(id int) (bool, error) {
+	routes, err := d.ListNotificationRoutes()
+	if err != nil {
+		return false, err
+	}
+	d.mu.Lock()
+	res, err := d.conn.Exec("DELETE FROM notification_channels WHERE id = ?", id)
+	d.mu.Unlock()
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	for i := range routes {
+		kept := make([]int, 0, len(routes[i].ChannelIDs))
+		for _, cid := range routes[i].ChannelIDs {
+			if cid != id {
+				kept = append(kept, cid)
+			}
+		}
+		if len(kept) == len(routes[i].ChannelIDs) {
+			continue
+		}
+		routes[i].ChannelIDs = kept
+		if _, err := d.UpdateNotificationRoute(&routes[i]); err != nil {
+			return n > 0, err
+		}
+	}
+	return n > 0, nil
+}
+
+const notificationRouteColumns = `id, name, event_types, tags, min_severity, channel_ids, enabled, created_at, updated_at`
+
+func scanNotificationRoute(r interface{ Scan(dest ...any) error }) (*NotificationRoute, error) {
+	var route NotificationRoute
+	var eventTypes, tags, channelIDs string
+	var enabled int
+	if err := r.Scan(
+		&route.ID, &route.Name, &eventTypes, &tags, &route.MinSeverity,
+		&channelIDs, &enabled, &route.CreatedAt, &route.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+	route.Enabled = enabled != 0
+	_ = json.Unmarshal([]byte(eventTypes), &route.EventTypes)
+	_ = json.Unmarshal([]byte(tags), &route.Tags)
+	_ = json.Unmarshal([]byte(channelIDs), &route.ChannelIDs)
+	if route.EventTypes == nil {
+		route.EventTypes = []string{}
+	}
+	if route.Tags == nil {
+		route.Tags = []string{}
+	}
+	if route.ChannelIDs == nil {
+		route.ChannelIDs = []int{}
+	}
+	return &route, nil
+}
+
+// ListNotificationRoutes returns all routes by id.
+func (d *Database) ListNotificationRoutes() ([]NotificationRoute, error) {
+	rows, err := d.read.Query("SELECT " + notificationRouteColumns + " FROM notification_routes ORDER BY id ASC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]NotificationRoute, 0)
+	for rows.Next() {
+		route, err := scanNotificationRoute(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *route)
+	}
+	return out, rows.Err()
+}
+
+// GetNotificationRoute returns a route by id, or nil when it does not exist.
+func (d *Database) GetNotificationRoute(id int) (*NotificationRoute, error) {
+	row := d.read.QueryRow("SELECT "+notificationRouteColumns+" FROM notification_routes WHERE id = ?", id)
+	route, err := scanNotificationRoute(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return route, err
+}
+
+// CreateNotificationRoute inserts a route.
+func (d *Database) CreateNotificationRoute(route *NotificationRoute) (*NotificationRoute, error) {
+	eventTypes, _ := json.Marshal(route.EventTypes)
+	tags, _ := json.Marshal(route.Tags)
+	channelIDs, _ := json.Marshal(route.ChannelIDs)
+	now := float64(time.Now().UnixMilli()) / 1000.0
+	d.mu.Lock()
+	res, err := d.conn.Exec(`
+		INSERT INTO notification_routes (name, event_types, tags, min_severity, channel_ids, enabled, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		route.Name, string(eventTypes), string(tags), route.MinSeverity, string(channelIDs), boolToInt(route.Enabled), now, now,
+	)
+	d.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	id, _ := res.LastInsertId()
+	return d.GetNotificationRoute(int(id))
+}
+
+// UpdateNotificationRoute saves all editable fields of route.
+func (d *Database) UpdateNotificationRoute(route *NotificationRoute) (*NotificationRoute, error) {
+	eventTypes, _ := json.Marshal(route.EventTypes)
+	tags, _ := json.Marshal(route.Tags)
+	channelIDs, _ := json.Marshal(route.ChannelIDs)
+	d.mu.Lock()
+	_, err := d.conn.Exec(`
+		UPDATE notification_routes
+		SET name = ?, event_types = ?, tags = ?, min_severity = ?, channel_ids = ?, enabled = ?, updated_at = ?
+		WHERE id = ?`,
+		route.Name, string(eventTypes), string(tags), route.MinSeverity, string(channelIDs), boolToInt(route.Enabled),
+		float64(time.Now().UnixMilli())/1000.0, route.ID,
+	)
+	d.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return d.GetNotificationRoute(route.ID)
+}
+
+// DeleteNotificationRoute removes a route.
+func (d *Database) DeleteNotificationRoute(id int) (bool, error) {
+	d.mu.Lock()
+	res, err := d.conn.Exec("DELETE FROM notification_routes WHERE id = ?", id)
+	d.mu.Unlock()
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+func validateNotificationChannel(ch *NotificationChannel) error {
+	ch.Name = strings.TrimSpace(ch.Name)
+	ch.Type = strings.ToLower(strings.TrimSpace(ch.Type))
+	if ch.Name == "" || len(ch.Name) > 128 {
+		return fmt.Errorf("name must be 1-128 chars")
+	}
+	if ch.Config == nil {
+		ch.Config = map[string]any{}
+	}
+	_, err := buildNotifier(ch.Type, ch.Config)
+	return err
+}
+
+func (d *Database) validateNotificationRoute(route *NotificationRoute) error {
+	route.Name = strings.TrimSpace(route.Name)
+	route.MinSeverity = strings.ToLower(strings.TrimSpace(route.MinSeverity))
+	route.Tags = normalizeTargetTags(route.Tags)
+	if len(route.Name) > 128 {
+		return fmt.Errorf("name must be <= 128 chars")
+	}
+	if route.MinSeverity == "" {
+		route.MinSeverity = severityInfo
+	}
+	if severityRank[route.MinSeverity] == 0 {
+		return fmt.Errorf("min_severity must be one of: %s", strings.Join(notificationSeverities, ", "))
+	}
+	for _, ev := range route.EventTypes {
+		if _, ok := notificationEvents[ev]; !ok {
+			return fmt.Errorf("unknown event type %q", ev)
+		}
+	}
+	if route.EventTypes == nil {
+		route.EventTypes = []string{}
+	}
+	if len(route.ChannelIDs) == 0 {
+		return fmt.Errorf("channel_ids must list at least one channel")
+	}
+	for _, id := range route.ChannelIDs {
+		ch, err := d.GetNotificationChannel(id)
+		if err != nil {
+			return err
+		}
+		if ch == nil {
+			return fmt.Errorf("notification channel %d not found", id)
+		}
+	}
+	return nil
+}
+
+// activeNotifier is an enabled channel with its built notifier.
+type activeNotifier struct {
+	channel NotificationChannel
+	n       notifier
+}
+
+// ReloadNotifications loads channels and routes from the database into the monitor.
+func (ms *MonitorService) ReloadNotifications() error {
+	channels, err := ms.db.ListNotificationChannels()
+	if err != nil {
+		return err
+	}
+	routes, err := ms.db.ListNotificationRoutes()
+	if err != nil {
+		return err
+	}
+	active := make(map[int]activeNotifier, len(channels))
+	for _, ch := range channels {
+		if !ch.Enabled {
+			continue
+		}
+		n, err := buildNotifier(ch.Type, ch.Config)
+		if err != nil {
+			ms.logger().Warn("skip invalid notification channel", "channel_id", ch.ID, "error", err)
+			continue
+		}
+		active[ch.ID] = activeNotifier{channel: ch, n: n}
+	}
+	ms.notifyMu.Lock()
+	ms.notifyChannels = active
+	ms.notifyRoutes = routes
+	ms.notifyMu.Unlock()
+	ms.logger().Info("notifications loaded", "channels", len(active), "routes", len(routes))
+	return nil
+}
+
+// notifyEvent routes an alert event to its notification channels in the background.
+// Incident events of a flapping target are skipped; target_flapping stands in for them.
+func (ms *MonitorService) notifyEvent(eventType, data string) {
+	defaultSeverity, ok := notificationEvents[eventType]
+	if !ok || ms.db == nil {
+		return
+	}
+	ms.notifyMu.RLock()
+	routes, channels := ms.notifyRoutes, ms.notifyChannels
+	ms.notifyMu.RUnlock()
+	if len(routes) == 0 {
+		return
+	}
+	var payload map[string]any
+	if err := json.Unmarshal([]byte(data), &payload); err != nil {
+		return
+	}
+	if flapping, _ := payload["flapping"].(bool); flapping {
+		return
+	}
+	ms.notifyWG.Add(1)
+	go func() {
+		defer ms.notifyWG.Done()
+		n := Notification{
+			Event:    eventType,
+			Severity: defaultSeverity,
+			Tags:     []string{},
+			At:       float64(time.Now().UnixMilli()) / 1000.0,
+			Data:     payload,
+		}
+		if status, _ := payload["status"].(string); eventType == "run_completed" && runStatusSeverity[status] != "" {
+			n.Severity = runStatusSeverity[status]
+		}
+		n.TargetName, _ = payload["target_name"].(string)
+		if id, ok := anyFloat(payload["target_id"]); ok {
+			n.TargetID = int(id)
+			if target, err := ms.db.GetTarget(n.TargetID); err == nil && target != nil {
+				n.Tags = target.Tags
+				if severityRank[target.Severity] > 0 && severityRank[target.Severity] < severityRank[n.Severity] {
+					n.Severity = target.Severity
+				}
+			}
+		}
+		describeNotification(&n)
+		for _, id := range routeNotification(routes, n.Event, n.Severity, n.Tags) {
+			if active, ok := channels[id]; ok {
+				ms.deliverNotification(active, n)
+			}
+		}
+	}()
+}
+
+// deliverNotification sends n to one channel and records the outcome.
+func (ms *MonitorService) deliverNotification(active activeNotifier, n Notification) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	err := active.n.Notify(ctx, n)
+	var errMsg *string
+	if err != nil {
+		msg := truncStr(err.Error(), 500)
+		errMsg = &msg
+		ms.logger().Warn("notification failed", "channel", active.channel.Name, "channel_id", active.channel.ID, "event", n.Event, "error", err)
+	}
+	if dbErr := ms.db.RecordNotificationResult(active.channel.ID, n.At, errMsg); dbErr != nil {
+		ms.logger().Error("record notification result failed", "channel_id", active.channel.ID, "error", dbErr)
+	}
+	return err
+}
+
+// ----------------------- Admin API -----------------------
+
+func (h *Handlers) reloadNotifications(w http.ResponseWriter) bool {
+	if err := h.monitor.ReloadNotifications(); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return false
+	}
+	return true
+}
+
+// maskChannelConfig returns ch with the secret config values masked.
+func maskChannelConfig(ch *NotificationChannel) *NotificationChannel {
+	out := *ch
+	out.Config = make(map[string]any, len(ch.Config))
+	for k, v := range ch.Config {
+		out.Config[k] = v
+	}
+	for _, key := range notifierKinds[ch.Type].secrets {
+		if s, ok := out.Config[key].(string); ok && s != "" {
+			out.Config[key] = maskAPIKey(s)
+		}
+	}
+	return &out
+}
+
+// AdminListNotificationChannels handles GET /api/admin/notifications/channels
+func (h *Handlers) AdminListNotificationChannels(w http.ResponseWriter, r *http.Request) {
+	channels, err := h.db.ListNotificationChannels()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return
+	}
+	items := make([]*NotificationChannel, 0, len(channels))
+	for i := range channels {
+		items = append(items, maskChannelConfig(&channels[i]))
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"items": items, "types": notifierKindNames()})
+}
+
+// AdminCreateNotificationChannel handles POST /api/admin/notifications/channels
+func (h *Handlers) AdminCreateNotificationChannel(w http.ResponseWriter, r *http.Request) {
+	var req notificationChannelRequest
+	if err := readJSON(r, &req); err != nil {
+		writeRequestBodyError(w, err)
+		return
+	}
+	ch := NotificationChannel{Enabled: true}
+	applyNotificationChannelRequest(&ch, &req)
+	if err := validateNotificationChannel(&ch); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": err.Error()})
+		return
+	}
+	item, err := h.db.CreateNotificationChannel(&ch)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return
+	}
+	if !h.reloadNotifications(w) {
+		return
+	}
+	h.audit(r, "notification_channel.create", "notification_channel", item.ID, auditDiff(nil, item, nil, map[string]bool{"config": true}))
+	writeJSON(w, http.StatusOK, map[string]any{"ok": true, "item": maskChannelConfig(item)})
+}
+
+// AdminPatchNotificationChannel handles PATCH /api/admin/notifications/channels/{id}
+func (h *Handlers) AdminPatchNotificationChannel(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(r)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "invalid id"})
+		return
+	}
+	ch, err := h.db.GetNotificationChannel(id)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return
+	}
+	if ch == nil {
+		writeJSON(w, http.StatusNotFound, map[string]any{"detail": "notification channel not found"})
+		return
+	}
+	var req notificationChannelRequest
+	if err := readJSON(r, &req); err != nil {
+		writeRequestBodyError(w, err)
+		return
+	}
+	before := *ch
+	applyNotificationChannelRequest(ch, &req)
+	if req.Config != nil {
+		// A masked secret echoed back from GET keeps the stored value.
+		for _, key := range notifierKinds[before.Type].secrets {
+			old, _ := before.Config[key].(string)
+			if s, ok := ch.Config[key].(string); ok && old != "" && s == maskAPIKey(old) {
+				ch.Config[key] = old
+			}
+		}
+	}
+	if err := validateNotificationChannel(ch); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": err.Error()})
+		return
+	}
+	item, err := h.db.UpdateNotificationChannel(ch)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return
+	}
+	if !h.reloadNotifications(w) {
+		return
+	}
+	h.audit(r, "notification_channel.update", "notification_channel", id,
+		auditDiff(&before, item, map[string]bool{"updated_at": true, "last_sent_at": true, "last_error": true}, map[string]bool{"config": true}))
+	writeJSON(w, http.StatusOK, map[string]any{"ok": true, "item": maskChannelConfig(item)})
+}
+
+// AdminDeleteNotificationChannel handles DELETE /api/admin/notifications/channels/{id}
+func (h *Handlers) AdminDeleteNotificationChannel(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(r)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "invalid id"})
+		return
+	}
+	deleted, err := h.db.DeleteNotificationChannel(id)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return
+	}
+	if !deleted {
+		writeJSON(w, http.StatusNotFound, map[string]any{"detail": "notification channel not found"})
+		return
+	}
+	if !h.reloadNotifications(w) {
+		return
+	}
+	h.audit(r, "notification_channel.delete", "notification_channel", id, nil)
+	writeJSON(w, http.StatusOK, map[string]any{"ok": true})
+}
+
+// AdminTestNotificationChannel handles POST /api/admin/notifications/channels/{id}/test
+func (h *Handlers) AdminTestNotificationChannel(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(r)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "invalid id"})
+		return
+	}
+	ch, err := h.db.GetNotificationChannel(id)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return
+	}
+	if ch == nil {
+		writeJSON(w, http.StatusNotFound, map[string]any{"detail": "notification channel not found"})
+		return
+	}
+	n, err := buildNotifier(ch.Type, ch.Config)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": err.Error()})
+		return
+	}
+	test := Notification{
+		Event:    "test",
+		Severity: severityInfo,
+		Title:    "Test notification",
+		Message:  "Notification channel " + ch.Name + " works.",
+		Tags:     []string{},
+		At:       float64(time.Now().UnixMilli()) / 1000.0,
+	}
+	if err := h.monitor.deliverNotification(activeNotifier{channel: *ch, n: n}, test); err != nil {
+		writeJSON(w, http.StatusBadGateway, map[string]any{"detail": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"ok": true})
+}
+
+// AdminListNotificationRoutes handles GET /api/admin/notifications/routes
+func (h *Handlers) AdminListNotificationRoutes(w http.ResponseWriter, r *http.Request) {
+	items, err := h.db.ListNotificationRoutes()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return
+	}
+	events := make([]string, 0, len(notificationEvents))
+	for ev := range notificationEvents {
+		events = append(events, ev)
+	}
+	sort.Strings(events)
+	writeJSON(w, http.StatusOK, map[string]any{"items": items, "events": events, "severities": notificationSeverities})
+}
+
+// AdminCreateNotificationRoute handles POST /api/admin/notifications/routes
+func (h *Handlers) AdminCreateNotificationRoute(w http.ResponseWriter, r *http.Request) {
+	var req notificationRouteRequest
+	if err := readJSON(r, &req); err != nil {
+		writeRequestBodyError(w, err)
+		return
+	}
+	route := NotificationRoute{MinSeverity: severityInfo, Enabled: true}
+	applyNotificationRouteRequest(&route, &req)
+	if err := h.db.validateNotificationRoute(&route); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": err.Error()})
+		return
+	}
+	item, err := h.db.CreateNotificationRoute(&route)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return
+	}
+	if !h.reloadNotifications(w) {
+		return
+	}
+	h.audit(r, "notification_route.create", "notification_route", item.ID, auditDiff(nil, item, nil, nil))
+	writeJSON(w, http.StatusOK, map[string]any{"ok": true, "item": item})
+}
+
+// AdminPatchNotificationRoute handles PATCH /api/admin/notifications/routes/{id}
+func (h *Handlers) AdminPatchNotificationRoute(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(r)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "invalid id"})
+		return
+	}
+	route, err := h.db.GetNotificationRoute(id)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return
+	}
+	if route == nil {
+		writeJSON(w, http.StatusNotFound, map[string]any{"detail": "notification route not found"})
+		return
+	}
+	var req notificationRouteRequest
+	if err := readJSON(r, &req); err != nil {
+		writeRequestBodyError(w, err)
+		return
+	}
+	before := *route
+	applyNotificationRouteRequest(route, &req)
+	if err := h.db.validateNotificationRoute(route); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": err.Error()})
+		return
+	}
+	item, err := h.db.UpdateNotificationRoute(route)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return
+	}
+	if !h.reloadNotifications(w) {
+		return
+	}
+	h.audit(r, "notification_route.update", "notification_route", id, auditDiff(&before, item, map[string]bool{"updated_at": true}, nil))
+	writeJSON(w, http.StatusOK, map[string]any{"ok": true, "item": item})
+}
+
+// AdminDeleteNotificationRoute handles DELETE /api/admin/notifications/routes/{id}
+func (h *Handlers) AdminDeleteNotificationRoute(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(r)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "invalid id"})
+		return
+	}
+	deleted, err := h.db.DeleteNotificationRoute(id)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return
+	}
+	if !deleted {
+		writeJSON(w, http.StatusNotFound, map[string]any{"detail": "notification route not found"})
+		return
+	}
+	if !h.reloadNotifications(w) {
+		return
+	}
+	h.audit(r, "notification_route.delete", "notification_route", id, nil)
+	writeJSON(w, http.StatusOK, map[string]any{"ok": true})
+}
+
+func applyNotificationChannelRequest(ch *NotificationChannel, req *notificationChannelRequest) {
+	if req.Name != nil {
+		ch.Name = *req.Name
+	}
+	if req.Type != nil {
+		ch.Type = *req.Type
+	}
+	if req.Config != nil {
+		ch.Config = *req.Config
+	}
+	if req.Enabled != nil {
+		ch.Enabled = *req.Enabled
+	}
+}
+
+func applyNotificationRouteRequest(route *NotificationRoute, req *notificationRouteRequest) {
+	if req.Name != nil {
+		route.Name = *req.Name
+	}
+	if req.EventTypes != nil {
+		route.EventTypes = *req.EventTypes
+	}
+	if req.Tags != nil {
+		route.Tags = *req.Tags
+	}
+	if req.MinSeverity != nil {
+		route.MinSeverity = *req.MinSeverity
+	}
+	if req.ChannelIDs != nil {
+		route.ChannelIDs = *req.ChannelIDs
+	}
+	if req.Enabled != nil {
+		route.Enabled = *req.Enabled
+	}
+}
@@ -0,0 +1,184 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestRouteNotificationMatchesEventTagAndSeverity(t *testing.T) {
+	routes := []NotificationRoute{
+		{ID: 1, Tags: []string{"production"}, MinSeverity: severityCritical, ChannelIDs: []int{1}, Enabled: true},
+		{ID: 2, EventTypes: []string{"cert_expiring"}, MinSeverity: severityInfo, ChannelIDs: []int{2}, Enabled: true},
+		{ID: 3, MinSeverity: severityInfo, ChannelIDs: []int{3}, Enabled: false},
+	}
+	cases := []struct {
+		event, severity string
+		tags            []string
+		want            string
+	}{
+		{"incident_opened", severityCritical, []string{"production"}, "[1]"},
+		{"incident_opened", severityWarning, []string{"production"}, "[]"},
+		{"incident_opened", severityCritical, []string{"test"}, "[]"},
+		{"cert_expiring", severityWarning, nil, "[2]"},
+		{"cert_expiring", severityCritical, []string{"production"}, "[1 2]"},
+	}
+	for _, tc := range cases {
+		got := routeNotification(routes, tc.event, tc.severity, tc.tags)
+		if fmt.Sprint(got) != tc.want {
+			t.Fatalf("%s/%s/%v: got channels %v, want %s", tc.event, tc.severity, tc.tags, got, tc.want)
+		}
+	}
+}
+
+func TestNotifyEventDeliversToRoutedChannels(t *testing.T) {
+	var mu sync.Mutex
+	var webhook []Notification
+	var telegram []map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.URL.Path {
+		case "/hook":
+			var n Notification
+			_ = json.NewDecoder(r.Body).Decode(&n)
+			webhook = append(webhook, n)
+		case "/bot123:secret/sendMessage":
+			var msg map[string]any
+			_ = json.NewDecoder(r.Body).Decode(&msg)
+			telegram = append(telegram, msg)
+			_, _ = w.Write([]byte(`{"ok":true}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	db, err := NewDatabase(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDatabase failed: %v", err)
+	}
+	t.Cleanup(func() { _ = db.conn.Close() })
+	if err := db.EnsureNotificationSchema(); err != nil {
+		t.Fatal(err)
+	}
+	ms := NewMonitorService(MonitorConfig{DB: db, LogDir: t.TempDir()})
+
+	prod, err := db.CreateTarget(map[string]any{"name": "prod", "base_url": "https://a.example.com", "api_key": "sk-1", "tags": []any{"Production"}})
+	if err != nil {
+		t.Fatalf("CreateTarget failed: %v", err)
+	}
+	staging, err := db.CreateTarget(map[string]any{"name": "staging", "base_url": "https://b.example.com", "api_key": "sk-2", "tags": []any{"test"}, "severity": "warning"})
+	if err != nil {
+		t.Fatalf("CreateTarget failed: %v", err)
+	}
+	if len(prod.Tags) != 1 || prod.Tags[0] != "production" || prod.Severity != severityCritical {
+		t.Fatalf("tags should be lowercased and severity default to critical, got tags=%v severity=%q", prod.Tags, prod.Severity)
+	}
+
+	tg, err := db.CreateNotificationChannel(&NotificationChannel{Name: "oncall", Type: "telegram", Enabled: true,
+		Config: map[string]any{"bot_token": "123:secret", "chat_id": "42", "api_url": srv.URL}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hook, err := db.CreateNotificationChannel(&NotificationChannel{Name: "all", Type: "webhook", Enabled: true,
+		Config: map[string]any{"url": srv.URL + "/hook"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, route := range []NotificationRoute{
+		{Tags: []string{"production"}, MinSeverity: severityCritical, ChannelIDs: []int{tg.ID}, Enabled: true},
+		{MinSeverity: severityInfo, ChannelIDs: []int{hook.ID}, Enabled: true},
+	} {
+		if _, err := db.CreateNotificationRoute(&route); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ms.ReloadNotifications(); err != nil {
+		t.Fatalf("ReloadNotifications failed: %v", err)
+	}
+
+	for _, target := range []*Target{prod, staging} {
+		ms.emitJSON("incident_opened", map[string]any{"target_id": target.ID, "target_name": target.Name, "status": "down"})
+	}
+	ms.emitJSON("run_completed", map[string]any{"target_id": prod.ID})
+	ms.notifyWG.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(telegram) != 1 || telegram[0]["chat_id"] != "42" || !strings.Contains(telegram[0]["text"].(string), "prod is down") {
+		t.Fatalf("only the production outage should page telegram, got=%v", telegram)
+	}
+	if len(webhook) != 2 {
+		t.Fatalf("webhook route should receive both outages, got=%v", webhook)
+	}
+	for _, n := range webhook {
+		if n.TargetID == staging.ID && (n.Severity != severityWarning || n.Tags[0] != "test") {
+			t.Fatalf("staging outage should be capped at its warning severity, got=%+v", n)
+		}
+	}
+	got, err := db.GetNotificationChannel(tg.ID)
+	if err != nil || got.LastSentAt == nil || got.LastError != nil {
+		t.Fatalf("delivery should be recorded on the channel, got=%+v err=%v", got, err)
+	}
+}
+
+func TestNotificationChannelSecretsAreMasked(t *testing.T) {
+	withEncryptionKey(t, "master")
+	db, err := NewDatabase(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDatabase failed: %v", err)
+	}
+	t.Cleanup(func() { _ = db.conn.Close() })
+	if err := db.EnsureNotificationSchema(); err != nil {
+		t.Fatal(err)
+	}
+	h := &Handlers{db: db, monitor: NewMonitorService(MonitorConfig{DB: db, LogDir: t.TempDir()})}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/admin/notifications/channels",
+		strings.NewReader(`{"name":"oncall","type":"telegram","config":{"bot_token":"123456:ABCDEFGH","chat_id":"42"}}`))
+	rr := httptest.NewRecorder()
+	h.AdminCreateNotificationChannel(rr, withAuthRole(req, authRoleAdmin))
+	var out struct {
+		Item NotificationChannel `json:"item"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &out); err != nil || rr.Code != http.StatusOK {
+		t.Fatalf("create failed: code=%d body=%s", rr.Code, rr.Body.String())
+	}
+	if strings.Contains(rr.Body.String(), "ABCDEFGH") || out.Item.Config["bot_token"] != maskAPIKey("123456:ABCDEFGH") {
+		t.Fatalf("bot_token should be masked, got=%s", rr.Body.String())
+	}
+	var raw string
+	if err := db.conn.QueryRow("SELECT config FROM notification_channels WHERE id = ?", out.Item.ID).Scan(&raw); err != nil {
+		t.Fatal(err)
+	}
+	if !isEncryptedSecret(raw) {
+		t.Fatalf("channel config should be stored encrypted, got=%q", raw)
+	}
+
+	body, _ := json.Marshal(map[string]any{"config": map[string]any{"bot_token": out.Item.Config["bot_token"], "chat_id": "43"}})
+	req = httptest.NewRequest(http.MethodPatch, "/api/admin/notifications/channels/1", strings.NewReader(string(body)))
+	req.SetPathValue("id", strconv.Itoa(out.Item.ID))
+	rr = httptest.NewRecorder()
+	h.AdminPatchNotificationChannel(rr, withAuthRole(req, authRoleAdmin))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("patch failed: code=%d body=%s", rr.Code, rr.Body.String())
+	}
+	stored, err := db.GetNotificationChannel(out.Item.ID)
+	if err != nil || stored.Config["bot_token"] != "123456:ABCDEFGH" || stored.Config["chat_id"] != "43" {
+		t.Fatalf("masked echo should keep the stored token, got=%+v err=%v", stored, err)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/admin/notifications/routes", strings.NewReader(`{"channel_ids":[99]}`))
+	rr = httptest.NewRecorder()
+	h.AdminCreateNotificationRoute(rr, withAuthRole(req, authRoleAdmin))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("route to a missing channel should be rejected, got=%d", rr.Code)
+	}
+}
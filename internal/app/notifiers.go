@@ -0,0 +1,305 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Notification channel types. Each kind builds a notifier from the channel's config
+// object; required and secret name config keys, and secrets are masked in API
+// responses. Adding a channel type means adding an entry to notifierKinds.
+
+// notifier delivers a notification to one channel.
+type notifier interface {
+	Notify(ctx context.Context, n Notification) error
+}
+
+type notifierKind struct {
+	required []string
+	secrets  []string
+	build    func(cfg map[string]any) (notifier, error)
+}
+
+var notifierKinds = map[string]notifierKind{
+	"webhook": {
+		required: []string{"url"},
+		build:    newWebhookNotifier,
+	},
+	"telegram": {
+		required: []string{"bot_token", "chat_id"},
+		secrets:  []string{"bot_token"},
+		build:    newTelegramNotifier,
+	},
+	"pagerduty": {
+		secrets: []string{"routing_key"},
+		build:   newPagerDutyNotifier,
+	},
+	"ntfy": {
+		required: []string{"topic"},
+		secrets:  []string{"token"},
+		build:    newNtfyNotifier,
+	},
+	"gotify": {
+		required: []string{"server_url", "token"},
+		secrets:  []string{"token"},
+		build:    newGotifyNotifier,
+	},
+	"bark": {
+		required: []string{"device_key"},
+		secrets:  []string{"device_key"},
+		build:    newBarkNotifier,
+	},
+	"feishu": {
+		required: []string{"webhook_url"},
+		secrets:  []string{"webhook_url", "secret"},
+		build:    newRobotNotifier("feishu"),
+	},
+	"dingtalk": {
+		required: []string{"webhook_url"},
+		secrets:  []string{"webhook_url", "secret"},
+		build:    newRobotNotifier("dingtalk"),
+	},
+	"wecom": {
+		required: []string{"webhook_url"},
+		secrets:  []string{"webhook_url"},
+		build:    newRobotNotifier("wecom"),
+	},
+	"email": {
+		required: []string{"smtp_addr", "from", "to"},
+		secrets:  []string{"password"},
+		build:    newEmailNotifier,
+	},
+}
+
+// notifierKindNames returns the channel types in alphabetical order.
+func notifierKindNames() []string {
+	names := make([]string, 0, len(notifierKinds))
+	for name := range notifierKinds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// buildNotifier validates cfg for kind and builds its notifier.
+func buildNotifier(kind string, cfg map[string]any) (notifier, error) {
+	k, ok := notifierKinds[kind]
+	if !ok {
+		return nil, fmt.Errorf("type must be one of: %s", strings.Join(notifierKindNames(), ", "))
+	}
+	for _, key := range k.required {
+		if v, ok := cfg[key]; !ok || v == nil || v == "" {
+			return nil, fmt.Errorf("config.%s is required for %s channels", key, kind)
+		}
+	}
+	return k.build(cfg)
+}
+
+// notifyHTTPClient sends HTTP-based notifications.
+var notifyHTTPClient = &http.Client{Timeout: 15 * time.Second}
+
+func configString(cfg map[string]any, key string) string {
+	switch v := cfg[key].(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case float64:
+		return strings.TrimSuffix(strings.TrimRight(fmt.Sprintf("%f", v), "0"), ".")
+	}
+	return ""
+}
+
+// configURL returns the http(s) URL at cfg[key], or def when it is unset.
+func configURL(cfg map[string]any, key, def string) (string, error) {
+	s := configString(cfg, key)
+	if s == "" {
+		return def, nil
+	}
+	if u, err := url.Parse(s); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("config.%s must be an http(s) URL", key)
+	}
+	return strings.TrimRight(s, "/"), nil
+}
+
+// notificationText is the plain-text form of n used by chat-style channels.
+func notificationText(n Notification) string {
+	text := "[" + strings.ToUpper(n.Severity) + "] " + n.Title
+	if n.Message != "" {
+		text += "\n" + n.Message
+	}
+	return text
+}
+
+// postNotificationJSON POSTs payload as JSON and fails on a non-2xx answer.
+func postNotificationJSON(ctx context.Context, reqURL string, headers map[string]string, payload any) ([]byte, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, reqURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := notifyHTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode/100 != 2 {
+		return respBody, fmt.Errorf("HTTP %d - %s", resp.StatusCode, truncStr(strings.TrimSpace(string(respBody)), 200))
+	}
+	return respBody, nil
+}
+
+// webhookNotifier POSTs the notification as JSON; optional headers are sent with it.
+type webhookNotifier struct {
+	url     string
+	headers map[string]string
+}
+
+func newWebhookNotifier(cfg map[string]any) (notifier, error) {
+	u, err := configURL(cfg, "url", "")
+	if err != nil {
+		return nil, err
+	}
+	n := &webhookNotifier{url: u, headers: map[string]string{}}
+	if raw, ok := cfg["headers"]; ok && raw != nil {
+		headers, ok := raw.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("config.headers must be an object of strings")
+		}
+		for k, v := range headers {
+			s, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("config.headers must be an object of strings")
+			}
+			n.headers[k] = s
+		}
+	}
+	return n, nil
+}
+
+func (w *webhookNotifier) Notify(ctx context.Context, n Notification) error {
+	_, err := postNotificationJSON(ctx, w.url, w.headers, n)
+	return err
+}
+
+// telegramNotifier sends a message through the Telegram Bot API; api_url overrides
+// https://api.telegram.org (e.g. for a self-hosted Bot API server).
+type telegramNotifier struct {
+	apiURL string
+	token  string
+	chatID string
+}
+
+func newTelegramNotifier(cfg map[string]any) (notifier, error) {
+	apiURL, err := configURL(cfg, "api_url", "https://api.telegram.org")
+	if err != nil {
+		return nil, err
+	}
+	return &telegramNotifier{apiURL: apiURL, token: configString(cfg, "bot_token"), chatID: configString(cfg, "chat_id")}, nil
+}
+
+func (t *telegramNotifier) Notify(ctx context.Context, n Notification) error {
+	body, err := postNotificationJSON(ctx, t.apiURL+"/bot"+t.token+"/sendMessage", nil, map[string]any{
+		"chat_id":                  t.chatID,
+		"text":                     notificationText(n),
+		"disable_web_page_preview": true,
+	})
+	if err != nil {
+		// The request URL holds the bot token; never let it into an error.
+		return fmt.Errorf("telegram sendMessage failed: %s", strings.ReplaceAll(err.Error(), t.token, "****"))
+	}
+	var out struct {
+		OK          bool   `json:"ok"`
+		Description string `json:"description"`
+	}
+	if json.Unmarshal(body, &out) == nil && !out.OK {
+		return fmt.Errorf("telegram sendMessage failed: %s", out.Description)
+	}
+	return nil
+}
+
+// emailNotifier sends a plain-text mail over SMTP.
+type emailNotifier struct {
+	addr     string
+	username string
+	password string
+	from     string
+	to       []string
+}
+
+func newEmailNotifier(cfg map[string]any) (notifier, error) {
+	n := &emailNotifier{
+		addr:     configString(cfg, "smtp_addr"),
+		username: configString(cfg, "username"),
+		password: stringFromAny(cfg["password"], ""),
+		from:     configString(cfg, "from"),
+	}
+	switch to := cfg["to"].(type) {
+	case string:
+		n.to = splitAddressList(to)
+	case []any:
+		for _, v := range to {
+			if s, ok := v.(string); ok {
+				n.to = append(n.to, splitAddressList(s)...)
+			}
+		}
+	}
+	if len(n.to) == 0 {
+		return nil, fmt.Errorf("config.to must list at least one address")
+	}
+	if !strings.Contains(n.addr, ":") {
+		return nil, fmt.Errorf("config.smtp_addr must be host:port")
+	}
+	return n, nil
+}
+
+func splitAddressList(s string) []string {
+	var out []string
+	for _, addr := range strings.Split(s, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			out = append(out, addr)
+		}
+	}
+	return out
+}
+
+func (e *emailNotifier) Notify(ctx context.Context, n Notification) error {
+	subject := "[" + strings.ToUpper(n.Severity) + "] " + n.Title
+	return sendSMTPMail(e.addr, e.username, e.password, e.from, e.to, subject, "text/plain", n.Message)
+}
+
+// sendSMTPMail sends one mail; PLAIN auth is used when username is set.
+func sendSMTPMail(addr, username, password, from string, to []string, subject, contentType, body string) error {
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("UTF-8", subject))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&msg, "Content-Type: %s; charset=UTF-8\r\n\r\n", contentType)
+	msg.WriteString(body)
+	var auth smtp.Auth
+	if username != "" {
+		host := addr
+		if i := strings.LastIndex(host, ":"); i >= 0 {
+			host = host[:i]
+		}
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+	return smtp.SendMail(addr, auth, from, to, msg.Bytes())
+}
@@ -0,0 +1,778 @@
+package app
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	oidcStateCookieName = "api_monitor_oidc_state"
+	oidcCallbackPath    = "/api/admin/oidc/callback"
+	// oidcFlowTTL bounds how long a user may take at the identity provider.
+	oidcFlowTTL = 10 * time.Minute
+	// oidcDiscoveryTTL is how long provider metadata and keys are cached.
+	oidcDiscoveryTTL = time.Hour
+	// oidcKeyRefreshMin rate-limits JWKS refetches triggered by unknown key ids.
+	oidcKeyRefreshMin = time.Minute
+	// oidcClockSkew tolerates small clock differences when checking exp and iat.
+	oidcClockSkew = time.Minute
+
+	oidcDefaultScopes      = "openid profile email"
+	oidcDefaultGroupsClaim = "groups"
+)
+
+// oidcSettingKeys lists the admin settings that configure OIDC login.
+var oidcSettingKeys = []string{
+	settingOIDCIssuer,
+	settingOIDCClientID,
+	settingOIDCClientSecret,
+	settingOIDCRedirectURL,
+	settingOIDCScopes,
+	settingOIDCGroupsClaim,
+	settingOIDCAdminGroups,
+	settingOIDCVisitorGroups,
+}
+
+// oidcConfig is the OIDC login configuration read from admin settings.
+type oidcConfig struct {
+	Issuer        string
+	ClientID      string
+	ClientSecret  string
+	RedirectURL   string
+	Scopes        string
+	GroupsClaim   string
+	AdminGroups   []string
+	VisitorGroups []string
+}
+
+func (c oidcConfig) Enabled() bool {
+	return c.Issuer != "" && c.ClientID != ""
+}
+
+func (d *Database) loadOIDCConfig() (oidcConfig, error) {
+	settings, err := d.GetSettings(oidcSettingKeys)
+	if err != nil {
+		return oidcConfig{}, err
+	}
+	cfg := oidcConfig{
+		Issuer:        strings.TrimRight(strings.TrimSpace(settings[settingOIDCIssuer]), "/"),
+		ClientID:      strings.TrimSpace(settings[settingOIDCClientID]),
+		ClientSecret:  strings.TrimSpace(settings[settingOIDCClientSecret]),
+		RedirectURL:   strings.TrimSpace(settings[settingOIDCRedirectURL]),
+		Scopes:        strings.TrimSpace(settings[settingOIDCScopes]),
+		GroupsClaim:   strings.TrimSpace(settings[settingOIDCGroupsClaim]),
+		AdminGroups:   splitCommaList(settings[settingOIDCAdminGroups]),
+		VisitorGroups: splitCommaList(settings[settingOIDCVisitorGroups]),
+	}
+	if cfg.Scopes == "" {
+		cfg.Scopes = oidcDefaultScopes
+	}
+	if cfg.GroupsClaim == "" {
+		cfg.GroupsClaim = oidcDefaultGroupsClaim
+	}
+	return cfg, nil
+}
+
+// splitCommaList splits a comma-separated setting and drops empty items.
+func splitCommaList(raw string) []string {
+	var out []string
+	for _, part := range strings.Split(raw, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+// validateOIDCSetting checks one OIDC admin setting and returns an error detail, or "".
+func validateOIDCSetting(key, value string) string {
+	if len(value) > 2048 {
+		return key + " must be <= 2048 chars"
+	}
+	if value == "" {
+		return ""
+	}
+	switch key {
+	case settingOIDCIssuer, settingOIDCRedirectURL:
+		u, err := url.Parse(value)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return key + " must be an absolute http(s) URL"
+		}
+	case settingOIDCScopes:
+		if !strings.Contains(" "+value+" ", " openid ") {
+			return key + " must include openid"
+		}
+	}
+	return ""
+}
+
+// oidcRoleForClaims maps the configured groups claim to a role. Admin groups win over
+// visitor groups; "*" in either list matches every authenticated user.
+func oidcRoleForClaims(cfg oidcConfig, claims map[string]any) (authRole, bool) {
+	values := oidcClaimValues(claims, cfg.GroupsClaim)
+	matches := func(groups []string) bool {
+		for _, g := range groups {
+			if g == "*" {
+				return true
+			}
+			for _, v := range values {
+				if v == g {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	if matches(cfg.AdminGroups) {
+		return authRoleAdmin, true
+	}
+	if matches(cfg.VisitorGroups) {
+		return authRoleVisitor, true
+	}
+	return authRoleUnknown, false
+}
+
+// oidcClaimValues reads a string or string-array claim. A dotted path such as
+// "realm_access.roles" descends into nested objects.
+func oidcClaimValues(claims map[string]any, path string) []string {
+	var cur any = claims
+	for _, part := range strings.Split(path, ".") {
+		obj, ok := cur.(map[string]any)
+		if !ok {
+			return nil
+		}
+		cur = obj[part]
+	}
+	switch v := cur.(type) {
+	case string:
+		return []string{v}
+	case []any:
+		out := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+// ---------------------------------------------------------------------------
+// Provider discovery, login flow state and ID token verification
+// ---------------------------------------------------------------------------
+
+type oidcProviderMetadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type oidcProvider struct {
+	meta          oidcProviderMetadata
+	keys          map[string]crypto.PublicKey
+	fetchedAt     time.Time
+	keysFetchedAt time.Time
+}
+
+type oidcPendingLogin struct {
+	nonce    string
+	verifier string
+	redirect string
+	expireAt time.Time
+}
+
+// OIDCClient performs the authorization code flow against the configured issuer.
+type OIDCClient struct {
+	httpClient *http.Client
+	mu         sync.Mutex
+	providers  map[string]*oidcProvider
+	pending    map[string]oidcPendingLogin
+}
+
+func NewOIDCClient() *OIDCClient {
+	return &OIDCClient{
+		httpClient: &http.Client{Timeout: 15 * time.Second},
+		providers:  make(map[string]*oidcProvider),
+		pending:    make(map[string]oidcPendingLogin),
+	}
+}
+
+func oidcRandomString() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+func (c *OIDCClient) getJSON(ctx context.Context, rawURL string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: HTTP %d", rawURL, resp.StatusCode)
+	}
+	return json.Unmarshal(body, out)
+}
+
+// provider returns cached metadata and keys for issuer, fetching them when stale.
+func (c *OIDCClient) provider(ctx context.Context, issuer string) (*oidcProvider, error) {
+	c.mu.Lock()
+	p := c.providers[issuer]
+	c.mu.Unlock()
+	if p != nil && time.Since(p.fetchedAt) < oidcDiscoveryTTL {
+		return p, nil
+	}
+
+	var meta oidcProviderMetadata
+	if err := c.getJSON(ctx, issuer+"/.well-known/openid-configuration", &meta); err != nil {
+		return nil, fmt.Errorf("oidc discovery: %w", err)
+	}
+	if strings.TrimRight(meta.Issuer, "/") != issuer {
+		return nil, fmt.Errorf("oidc discovery: issuer mismatch %q", meta.Issuer)
+	}
+	if meta.AuthorizationEndpoint == "" || meta.TokenEndpoint == "" || meta.JWKSURI == "" {
+		return nil, errors.New("oidc discovery: incomplete provider metadata")
+	}
+	keys, err := c.fetchKeys(ctx, meta.JWKSURI)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	p = &oidcProvider{meta: meta, keys: keys, fetchedAt: now, keysFetchedAt: now}
+	c.mu.Lock()
+	c.providers[issuer] = p
+	c.mu.Unlock()
+	return p, nil
+}
+
+type oidcJWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (c *OIDCClient) fetchKeys(ctx context.Context, jwksURI string) (map[string]crypto.PublicKey, error) {
+	var set struct {
+		Keys []oidcJWK `json:"keys"`
+	}
+	if err := c.getJSON(ctx, jwksURI, &set); err != nil {
+		return nil, fmt.Errorf("oidc jwks: %w", err)
+	}
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		if pub, err := k.publicKey(); err == nil {
+			keys[k.Kid] = pub
+		}
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("oidc jwks: no usable signing keys")
+	}
+	return keys, nil
+}
+
+func (k oidcJWK) publicKey() (crypto.PublicKey, error) {
+	decode := func(s string) (*big.Int, error) {
+		raw, err := base64.RawURLEncoding.DecodeString(s)
+		if err != nil || len(raw) == 0 {
+			return nil, errors.New("invalid key component")
+		}
+		return new(big.Int).SetBytes(raw), nil
+	}
+	switch k.Kty {
+	case "RSA":
+		n, err := decode(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode(k.E)
+		if err != nil || !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 {
+			return nil, errors.New("invalid RSA exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decode(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decode(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("EC point is not on curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+// signingKey returns the key for kid, refetching the JWKS once if the provider has
+// rotated keys since the last fetch.
+func (c *OIDCClient) signingKey(ctx context.Context, p *oidcProvider, kid string) (crypto.PublicKey, error) {
+	c.mu.Lock()
+	key, ok := p.keys[kid]
+	if !ok && kid == "" && len(p.keys) == 1 {
+		for _, only := range p.keys {
+			key, ok = only, true
+		}
+	}
+	stale := time.Since(p.keysFetchedAt) >= oidcKeyRefreshMin
+	c.mu.Unlock()
+	if ok {
+		return key, nil
+	}
+	if !stale {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	keys, err := c.fetchKeys(ctx, p.meta.JWKSURI)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	p.keys = keys
+	p.keysFetchedAt = time.Now()
+	key, ok = keys[kid]
+	c.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	return key, nil
+}
+
+// verifyIDToken checks the signature and standard claims of a compact JWS ID token
+// and returns its claims.
+func (c *OIDCClient) verifyIDToken(ctx context.Context, p *oidcProvider, cfg oidcConfig, rawToken, nonce string) (map[string]any, error) {
+	parts := strings.Split(rawToken, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed id_token")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	headerRaw, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil || json.Unmarshal(headerRaw, &header) != nil {
+		return nil, errors.New("malformed id_token header")
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.New("malformed id_token signature")
+	}
+	key, err := c.signingKey(ctx, p, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyJWSSignature(header.Alg, key, []byte(parts[0]+"."+parts[1]), sig); err != nil {
+		return nil, err
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, errors.New("malformed id_token payload")
+	}
+	var claims map[string]any
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, errors.New("malformed id_token payload")
+	}
+
+	if iss, _ := claims["iss"].(string); iss != p.meta.Issuer {
+		return nil, errors.New("id_token issuer mismatch")
+	}
+	if !oidcAudienceContains(claims["aud"], cfg.ClientID) {
+		return nil, errors.New("id_token audience mismatch")
+	}
+	now := time.Now()
+	exp, ok := claims["exp"].(float64)
+	if !ok || now.After(time.Unix(int64(exp), 0).Add(oidcClockSkew)) {
+		return nil, errors.New("id_token expired")
+	}
+	if iat, ok := claims["iat"].(float64); ok && time.Unix(int64(iat), 0).After(now.Add(oidcClockSkew)) {
+		return nil, errors.New("id_token issued in the future")
+	}
+	if got, _ := claims["nonce"].(string); got == "" || got != nonce {
+		return nil, errors.New("id_token nonce mismatch")
+	}
+	return claims, nil
+}
+
+func oidcAudienceContains(aud any, clientID string) bool {
+	switch v := aud.(type) {
+	case string:
+		return v == clientID
+	case []any:
+		for _, item := range v {
+			if s, ok := item.(string); ok && s == clientID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func verifyJWSSignature(alg string, key crypto.PublicKey, signed, sig []byte) error {
+	var hash crypto.Hash
+	switch alg {
+	case "RS256", "ES256":
+		hash = crypto.SHA256
+	case "RS384", "ES384":
+		hash = crypto.SHA384
+	case "RS512", "ES512":
+		hash = crypto.SHA512
+	default:
+		return fmt.Errorf("unsupported id_token alg %q", alg)
+	}
+	var digest []byte
+	switch hash {
+	case crypto.SHA256:
+		sum := sha256.Sum256(signed)
+		digest = sum[:]
+	case crypto.SHA384:
+		sum := sha512.Sum384(signed)
+		digest = sum[:]
+	default:
+		sum := sha512.Sum512(signed)
+		digest = sum[:]
+	}
+
+	switch pub := key.(type) {
+	case *rsa.PublicKey:
+		if !strings.HasPrefix(alg, "RS") {
+			return errors.New("id_token alg does not match key type")
+		}
+		if rsa.VerifyPKCS1v15(pub, hash, digest, sig) != nil {
+			return errors.New("invalid id_token signature")
+		}
+		return nil
+	case *ecdsa.PublicKey:
+		if !strings.HasPrefix(alg, "ES") {
+			return errors.New("id_token alg does not match key type")
+		}
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		if len(sig) != 2*size {
+			return errors.New("invalid id_token signature")
+		}
+		r := new(big.Int).SetBytes(sig[:size])
+		s := new(big.Int).SetBytes(sig[size:])
+		if !ecdsa.Verify(pub, digest, r, s) {
+			return errors.New("invalid id_token signature")
+		}
+		return nil
+	}
+	return errors.New("unsupported signing key")
+}
+
+// exchangeCode redeems an authorization code for the ID token.
+func (c *OIDCClient) exchangeCode(ctx context.Context, p *oidcProvider, cfg oidcConfig, code, redirectURI, verifier string) (string, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {redirectURI},
+		"client_id":     {cfg.ClientID},
+		"code_verifier": {verifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.meta.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if cfg.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(cfg.ClientID), url.QueryEscape(cfg.ClientSecret))
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("oidc token exchange: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", fmt.Errorf("oidc token exchange: %w", err)
+	}
+	var tok struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	_ = json.Unmarshal(body, &tok)
+	if resp.StatusCode != http.StatusOK {
+		if tok.Error != "" {
+			return "", fmt.Errorf("oidc token exchange: %s %s", tok.Error, tok.ErrorDescription)
+		}
+		return "", fmt.Errorf("oidc token exchange: HTTP %d", resp.StatusCode)
+	}
+	if tok.IDToken == "" {
+		return "", errors.New("oidc token exchange: response has no id_token")
+	}
+	return tok.IDToken, nil
+}
+
+func (c *OIDCClient) startLogin(redirect string) (state string, pending oidcPendingLogin, err error) {
+	if state, err = oidcRandomString(); err != nil {
+		return "", pending, err
+	}
+	if pending.nonce, err = oidcRandomString(); err != nil {
+		return "", pending, err
+	}
+	if pending.verifier, err = oidcRandomString(); err != nil {
+		return "", pending, err
+	}
+	pending.redirect = redirect
+	pending.expireAt = time.Now().Add(oidcFlowTTL)
+
+	c.mu.Lock()
+	now := time.Now()
+	for k, v := range c.pending {
+		if now.After(v.expireAt) {
+			delete(c.pending, k)
+		}
+	}
+	c.pending[state] = pending
+	c.mu.Unlock()
+	return state, pending, nil
+}
+
+// finishLogin consumes a pending login; each state can be used once.
+func (c *OIDCClient) finishLogin(state string) (oidcPendingLogin, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	pending, ok := c.pending[state]
+	delete(c.pending, state)
+	if !ok || time.Now().After(pending.expireAt) {
+		return oidcPendingLogin{}, false
+	}
+	return pending, true
+}
+
+// oidcRedirectURI returns the configured callback URL or derives it from the request.
+func oidcRedirectURI(cfg oidcConfig, r *http.Request) string {
+	if cfg.RedirectURL != "" {
+		return cfg.RedirectURL
+	}
+	scheme := "http"
+	if r.TLS != nil || strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https") {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + oidcCallbackPath
+}
+
+func oidcCodeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// oidcSessionAuthenticator lets OIDC browser sessions use the dashboard API with
+// their mapped role. Password sessions stay limited to the admin routes.
+type oidcSessionAuthenticator struct {
+	admin *AdminSessionManager
+}
+
+func (a oidcSessionAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	if a.admin == nil {
+		return nil, nil
+	}
+	s, ok := a.admin.lookup(adminSessionTokenFromRequest(r))
+	if !ok || s.method != "oidc" {
+		return nil, nil
+	}
+	return &Principal{Role: s.role, Method: "oidc"}, nil
+}
+
+// ---------------------------------------------------------------------------
+// Handlers
+// ---------------------------------------------------------------------------
+
+func oidcLoginError(w http.ResponseWriter, r *http.Request, detail string) {
+	http.Redirect(w, r, "/admin/login?oidc_error="+url.QueryEscape(detail), http.StatusFound)
+}
+
+// OIDCStatus handles GET /api/admin/oidc so the login page can offer SSO.
+func (h *Handlers) OIDCStatus(w http.ResponseWriter, r *http.Request) {
+	cfg, err := h.db.loadOIDCConfig()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"enabled": cfg.Enabled() && h.oidc != nil})
+}
+
+// OIDCLogin handles GET /api/admin/oidc/login and redirects to the identity provider.
+func (h *Handlers) OIDCLogin(w http.ResponseWriter, r *http.Request) {
+	if h.admin == nil || !h.admin.Enabled() || h.oidc == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]any{"detail": "admin panel is disabled"})
+		return
+	}
+	cfg, err := h.db.loadOIDCConfig()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return
+	}
+	if !cfg.Enabled() {
+		writeJSON(w, http.StatusNotFound, map[string]any{"detail": "oidc login is not configured"})
+		return
+	}
+	p, err := h.oidc.provider(r.Context(), cfg.Issuer)
+	if err != nil {
+		h.logger("oidc").Error("oidc login failed", "error", err)
+		oidcLoginError(w, r, "identity provider unavailable")
+		return
+	}
+	redirectURI := oidcRedirectURI(cfg, r)
+	state, pending, err := h.oidc.startLogin(redirectURI)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": "failed to start login"})
+		return
+	}
+
+	authURL, err := url.Parse(p.meta.AuthorizationEndpoint)
+	if err != nil {
+		oidcLoginError(w, r, "identity provider unavailable")
+		return
+	}
+	q := authURL.Query()
+	q.Set("response_type", "code")
+	q.Set("client_id", cfg.ClientID)
+	q.Set("redirect_uri", redirectURI)
+	q.Set("scope", cfg.Scopes)
+	q.Set("state", state)
+	q.Set("nonce", pending.nonce)
+	q.Set("code_challenge", oidcCodeChallenge(pending.verifier))
+	q.Set("code_challenge_method", "S256")
+	authURL.RawQuery = q.Encode()
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookieName,
+		Value:    state,
+		Path:     "/api/admin/oidc",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+		Secure:   secureCookies.Load(),
+		MaxAge:   int(oidcFlowTTL.Seconds()),
+	})
+	http.Redirect(w, r, authURL.String(), http.StatusFound)
+}
+
+// OIDCCallback handles GET /api/admin/oidc/callback, verifies the ID token and
+// starts a session with the role mapped from the configured groups claim.
+func (h *Handlers) OIDCCallback(w http.ResponseWriter, r *http.Request) {
+	if h.admin == nil || !h.admin.Enabled() || h.oidc == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]any{"detail": "admin panel is disabled"})
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: oidcStateCookieName, Value: "", Path: "/api/admin/oidc", HttpOnly: true, MaxAge: -1})
+
+	q := r.URL.Query()
+	if e := q.Get("error"); e != "" {
+		oidcLoginError(w, r, "identity provider returned "+e)
+		return
+	}
+	state := q.Get("state")
+	c, err := r.Cookie(oidcStateCookieName)
+	if state == "" || err != nil || c.Value != state {
+		oidcLoginError(w, r, "login state mismatch, please retry")
+		return
+	}
+	pending, ok := h.oidc.finishLogin(state)
+	if !ok {
+		oidcLoginError(w, r, "login expired, please retry")
+		return
+	}
+	code := q.Get("code")
+	if code == "" {
+		oidcLoginError(w, r, "missing authorization code")
+		return
+	}
+
+	cfg, err := h.db.loadOIDCConfig()
+	if err != nil || !cfg.Enabled() {
+		oidcLoginError(w, r, "oidc login is not configured")
+		return
+	}
+	p, err := h.oidc.provider(r.Context(), cfg.Issuer)
+	if err != nil {
+		h.logger("oidc").Error("oidc callback failed", "error", err)
+		oidcLoginError(w, r, "identity provider unavailable")
+		return
+	}
+	rawIDToken, err := h.oidc.exchangeCode(r.Context(), p, cfg, code, pending.redirect, pending.verifier)
+	if err != nil {
+		h.logger("oidc").Warn("oidc token exchange failed", "error", err)
+		oidcLoginError(w, r, "token exchange failed")
+		return
+	}
+	claims, err := h.oidc.verifyIDToken(r.Context(), p, cfg, rawIDToken, pending.nonce)
+	if err != nil {
+		h.logger("oidc").Warn("oidc callback rejected id_token", "error", err)
+		oidcLoginError(w, r, "invalid id_token")
+		return
+	}
+
+	subject, _ := claims["sub"].(string)
+	role, ok := oidcRoleForClaims(cfg, claims)
+	if !ok {
+		h.logger("oidc").Warn("oidc login denied: no matching group", "sub", subject)
+		oidcLoginError(w, r, "your account is not allowed to access this monitor")
+		return
+	}
+	token, err := h.admin.CreateSession(role, "oidc")
+	if err != nil {
+		oidcLoginError(w, r, "failed to create session")
+		return
+	}
+	setAdminSessionCookie(w, token, 24*time.Hour)
+	h.logger("oidc").Info("oidc login", "sub", subject, "role", role)
+	if role == authRoleAdmin {
+		http.Redirect(w, r, "/admin.html", http.StatusFound)
+		return
+	}
+	http.Redirect(w, r, "/", http.StatusFound)
+}
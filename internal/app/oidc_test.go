@@ -0,0 +1,186 @@
+package app
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testOIDCIssuer struct {
+	t      *testing.T
+	server *httptest.Server
+	key    *rsa.PrivateKey
+	claims map[string]any
+}
+
+func newTestOIDCIssuer(t *testing.T) *testOIDCIssuer {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	iss := &testOIDCIssuer{t: t, key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]any{
+			"issuer":                 iss.server.URL,
+			"authorization_endpoint": iss.server.URL + "/authorize",
+			"token_endpoint":         iss.server.URL + "/token",
+			"jwks_uri":               iss.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("GET /jwks", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]any{"keys": []map[string]any{{
+			"kty": "RSA", "kid": "k1", "use": "sig",
+			"n": base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("POST /token", func(w http.ResponseWriter, r *http.Request) {
+		if id, secret, _ := r.BasicAuth(); id != "monitor" || secret != "s3cret" {
+			writeJSON(w, http.StatusUnauthorized, map[string]any{"error": "invalid_client"})
+			return
+		}
+		if r.FormValue("code") != "good-code" || r.FormValue("code_verifier") == "" {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid_grant"})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"id_token": iss.sign(iss.claims)})
+	})
+	iss.server = httptest.NewServer(mux)
+	t.Cleanup(iss.server.Close)
+	return iss
+}
+
+func (iss *testOIDCIssuer) sign(claims map[string]any) string {
+	header, _ := json.Marshal(map[string]any{"alg": "RS256", "kid": "k1", "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	sum := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, iss.key, crypto.SHA256, sum[:])
+	if err != nil {
+		iss.t.Fatalf("SignPKCS1v15 failed: %v", err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func newOIDCTestHandlers(t *testing.T, iss *testOIDCIssuer) *Handlers {
+	t.Helper()
+	db, err := NewDatabase(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDatabase failed: %v", err)
+	}
+	t.Cleanup(func() { _ = db.conn.Close() })
+	for k, v := range map[string]string{
+		settingOIDCIssuer:        iss.server.URL,
+		settingOIDCClientID:      "monitor",
+		settingOIDCClientSecret:  "s3cret",
+		settingOIDCGroupsClaim:   "realm_access.roles",
+		settingOIDCAdminGroups:   "ops",
+		settingOIDCVisitorGroups: "staff",
+	} {
+		if err := db.SetSetting(k, v); err != nil {
+			t.Fatalf("SetSetting failed: %v", err)
+		}
+	}
+	return &Handlers{db: db, admin: NewAdminSessionManager("admin-pass", time.Hour), oidc: NewOIDCClient()}
+}
+
+// runOIDCLogin drives login and callback and returns the callback response.
+func runOIDCLogin(t *testing.T, h *Handlers, iss *testOIDCIssuer, roles []any, nonceOverride string) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.OIDCLogin(rec, httptest.NewRequest(http.MethodGet, "http://monitor.local/api/admin/oidc/login", nil))
+	if rec.Code != http.StatusFound {
+		t.Fatalf("login should redirect to the provider, got=%d body=%s", rec.Code, rec.Body.String())
+	}
+	loc, err := url.Parse(rec.Header().Get("Location"))
+	if err != nil || !strings.HasPrefix(loc.String(), iss.server.URL+"/authorize") {
+		t.Fatalf("unexpected authorize redirect: %s", rec.Header().Get("Location"))
+	}
+	q := loc.Query()
+	if q.Get("redirect_uri") != "http://monitor.local/api/admin/oidc/callback" || q.Get("code_challenge_method") != "S256" {
+		t.Fatalf("authorize request missing redirect or PKCE, got=%v", q)
+	}
+	nonce := q.Get("nonce")
+	if nonceOverride != "" {
+		nonce = nonceOverride
+	}
+	iss.claims = map[string]any{
+		"iss": iss.server.URL, "aud": "monitor", "sub": "user-1", "nonce": nonce,
+		"exp": time.Now().Add(time.Hour).Unix(), "iat": time.Now().Unix(),
+		"realm_access": map[string]any{"roles": roles},
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/admin/oidc/callback?code=good-code&state="+url.QueryEscape(q.Get("state")), nil)
+	for _, c := range rec.Result().Cookies() {
+		req.AddCookie(c)
+	}
+	cb := httptest.NewRecorder()
+	h.OIDCCallback(cb, req)
+	return cb
+}
+
+func sessionCookie(rec *httptest.ResponseRecorder) string {
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == adminSessionCookieName && c.MaxAge > 0 {
+			return c.Value
+		}
+	}
+	return ""
+}
+
+func TestOIDCLogin_MapsGroupsToRoles(t *testing.T) {
+	iss := newTestOIDCIssuer(t)
+	h := newOIDCTestHandlers(t, iss)
+
+	rec := runOIDCLogin(t, h, iss, []any{"ops"}, "")
+	if rec.Code != http.StatusFound || rec.Header().Get("Location") != "/admin.html" {
+		t.Fatalf("admin group should land on the admin panel, got=%d location=%s", rec.Code, rec.Header().Get("Location"))
+	}
+	token := sessionCookie(rec)
+	if !h.admin.Validate(token) {
+		t.Fatalf("admin OIDC login should create an admin session")
+	}
+
+	rec = runOIDCLogin(t, h, iss, []any{"staff"}, "")
+	if rec.Header().Get("Location") != "/" {
+		t.Fatalf("visitor group should land on the dashboard, got=%s", rec.Header().Get("Location"))
+	}
+	token = sessionCookie(rec)
+	if h.admin.Validate(token) {
+		t.Fatalf("visitor OIDC session must not pass admin validation")
+	}
+	req := httptest.NewRequest(http.MethodGet, "/api/targets", nil)
+	req.AddCookie(&http.Cookie{Name: adminSessionCookieName, Value: token})
+	p, _ := oidcSessionAuthenticator{admin: h.admin}.Authenticate(req)
+	if p == nil || p.Role != authRoleVisitor || p.Method != "oidc" {
+		t.Fatalf("visitor OIDC session should authenticate API calls as visitor, got=%+v", p)
+	}
+
+	rec = runOIDCLogin(t, h, iss, []any{"other"}, "")
+	if sessionCookie(rec) != "" || !strings.Contains(rec.Header().Get("Location"), "oidc_error=") {
+		t.Fatalf("unmapped groups should be denied, got location=%s", rec.Header().Get("Location"))
+	}
+}
+
+func TestOIDCCallback_RejectsNonceMismatch(t *testing.T) {
+	iss := newTestOIDCIssuer(t)
+	h := newOIDCTestHandlers(t, iss)
+
+	rec := runOIDCLogin(t, h, iss, []any{"ops"}, "forged")
+	if sessionCookie(rec) != "" || !strings.Contains(rec.Header().Get("Location"), "oidc_error=") {
+		t.Fatalf("id_token with a foreign nonce should be rejected, got location=%s", rec.Header().Get("Location"))
+	}
+}
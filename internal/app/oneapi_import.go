@@ -0,0 +1,351 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// One-API / New-API channel import. The admin API of both projects lists channels at
+// GET /api/channel/?p=N with a system access token; New-API additionally wants the
+// owning user id in the New-Api-User header. Paging is 0-based in One-API and 1-based
+// in New-API, so pages are fetched until one adds no unseen channel.
+
+const (
+	oneAPIPageSize = 100
+	oneAPIMaxPages = 200
+)
+
+// oneAPIDefaultBaseURLs fills in base_url for channel types that leave it empty
+// because the upstream is the vendor's official endpoint.
+var oneAPIDefaultBaseURLs = map[int]string{
+	1:  "https://api.openai.com",
+	14: "https://api.anthropic.com",
+	24: "https://generativelanguage.googleapis.com",
+}
+
+type oneAPIChannel struct {
+	ID           int     `json:"id"`
+	Type         int     `json:"type"`
+	Key          string  `json:"key"`
+	Status       int     `json:"status"`
+	Name         string  `json:"name"`
+	BaseURL      *string `json:"base_url"`
+	Models       string  `json:"models"`
+	ModelMapping *string `json:"model_mapping"`
+}
+
+type oneAPIClient struct {
+	baseURL string
+	token   string
+	userID  int
+	http    *http.Client
+}
+
+func (c *oneAPIClient) get(ctx context.Context, path string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Accept", "application/json")
+	if c.userID > 0 {
+		req.Header.Set("New-Api-User", strconv.Itoa(c.userID))
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 32<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s returned HTTP %d", path, resp.StatusCode)
+	}
+	var envelope struct {
+		Success bool            `json:"success"`
+		Message string          `json:"message"`
+		Data    json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return fmt.Errorf("GET %s: invalid JSON response", path)
+	}
+	if !envelope.Success {
+		msg := envelope.Message
+		if msg == "" {
+			msg = "request rejected"
+		}
+		return fmt.Errorf("GET %s: %s", path, msg)
+	}
+	return json.Unmarshal(envelope.Data, out)
+}
+
+// listChannels pulls every channel. data is either a bare array (One-API) or
+// {"items": [...], "total": n} (New-API).
+func (c *oneAPIClient) listChannels(ctx context.Context) ([]oneAPIChannel, error) {
+	var out []oneAPIChannel
+	seen := map[int]bool{}
+	for page := 0; page < oneAPIMaxPages; page++ {
+		var raw json.RawMessage
+		if err := c.get(ctx, fmt.Sprintf("/api/channel/?p=%d&page_size=%d", page, oneAPIPageSize), &raw); err != nil {
+			return nil, err
+		}
+		var items []oneAPIChannel
+		total := -1
+		if err := json.Unmarshal(raw, &items); err != nil {
+			var paged struct {
+				Items []oneAPIChannel `json:"items"`
+				Total int             `json:"total"`
+			}
+			if err := json.Unmarshal(raw, &paged); err != nil {
+				return nil, fmt.Errorf("unexpected channel list format")
+			}
+			items, total = paged.Items, paged.Total
+		}
+		added := 0
+		for _, ch := range items {
+			if seen[ch.ID] {
+				continue
+			}
+			seen[ch.ID] = true
+			out = append(out, ch)
+			added++
+		}
+		if added == 0 || (total >= 0 && len(out) >= total) {
+			break
+		}
+	}
+	return out, nil
+}
+
+// channelKey returns the channel's key from GET /api/channel/{id}; list responses
+// usually omit it.
+func (c *oneAPIClient) channelKey(ctx context.Context, id int) (string, error) {
+	var ch oneAPIChannel
+	if err := c.get(ctx, fmt.Sprintf("/api/channel/%d", id), &ch); err != nil {
+		return "", err
+	}
+	return ch.Key, nil
+}
+
+// firstChannelKey picks the first key of a multi-key (one per line) channel.
+func firstChannelKey(key string) string {
+	for _, line := range strings.Split(key, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			return line
+		}
+	}
+	return ""
+}
+
+// oneAPIChannelModels returns the upstream model names a channel serves: the listed
+// models with model_mapping applied, since targets are probed directly upstream.
+func oneAPIChannelModels(ch oneAPIChannel) []string {
+	mapping := map[string]string{}
+	if ch.ModelMapping != nil && strings.TrimSpace(*ch.ModelMapping) != "" {
+		_ = json.Unmarshal([]byte(*ch.ModelMapping), &mapping)
+	}
+	var models []string
+	seen := map[string]bool{}
+	for _, m := range strings.Split(ch.Models, ",") {
+		m = strings.TrimSpace(m)
+		if mapped := strings.TrimSpace(mapping[m]); mapped != "" {
+			m = mapped
+		}
+		if m == "" || seen[m] {
+			continue
+		}
+		seen[m] = true
+		models = append(models, m)
+	}
+	if models == nil {
+		models = []string{}
+	}
+	return models
+}
+
+// oneAPIChannelBaseURL returns the channel's upstream base URL, or "" when it has
+// none and its type has no known default.
+func oneAPIChannelBaseURL(ch oneAPIChannel) string {
+	if ch.BaseURL != nil {
+		if u := strings.TrimSpace(*ch.BaseURL); u != "" {
+			return normalizeBaseURL(u)
+		}
+	}
+	return oneAPIDefaultBaseURLs[ch.Type]
+}
+
+type oneAPIImportRequest struct {
+	BaseURL string `json:"base_url"`
+	Token   string `json:"token"`
+	// UserID is sent as New-Api-User; New-API requires it, One-API ignores it.
+	UserID          int  `json:"user_id"`
+	IncludeDisabled bool `json:"include_disabled"`
+	DryRun          bool `json:"dry_run"`
+}
+
+type oneAPIImportItem struct {
+	ChannelID int    `json:"channel_id"`
+	Name      string `json:"name"`
+	Action    string `json:"action"`
+	TargetID  int    `json:"target_id,omitempty"`
+	Models    int    `json:"models"`
+	Detail    string `json:"detail,omitempty"`
+}
+
+// AdminImportOneAPI -- POST /api/admin/import/oneapi
+// Body: {"base_url":"https://oneapi.example.com","token":"<access token>","user_id":1,
+// "include_disabled":false,"dry_run":false}. Channels are matched to targets by
+// source_url (<base_url>/api/channel/<id>) and then by name + base_url; matches are
+// updated, the rest created. Created targets start enabled only if the channel is.
+func (h *Handlers) AdminImportOneAPI(w http.ResponseWriter, r *http.Request) {
+	var req oneAPIImportRequest
+	if err := readJSON(r, &req); err != nil {
+		writeRequestBodyError(w, err)
+		return
+	}
+	source := strings.TrimRight(strings.TrimSpace(req.BaseURL), "/")
+	if u, err := url.Parse(source); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "base_url must be an http(s) URL"})
+		return
+	}
+	req.Token = strings.TrimSpace(req.Token)
+	if req.Token == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "token is required"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Minute)
+	defer cancel()
+	client := &oneAPIClient{baseURL: source, token: req.Token, userID: req.UserID, http: &http.Client{Timeout: 30 * time.Second}}
+	channels, err := client.listChannels(ctx)
+	if err != nil {
+		writeJSON(w, http.StatusBadGateway, map[string]any{"detail": "fetch channels failed: " + err.Error()})
+		return
+	}
+
+	targets, err := h.db.ListTargets()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return
+	}
+	bySource := map[string]*Target{}
+	byName := map[string]*Target{}
+	for i := range targets {
+		t := &targets[i]
+		if t.SourceURL != nil {
+			bySource[*t.SourceURL] = t
+		}
+		byName[t.Name+"\x00"+normalizeBaseURL(t.BaseURL)] = t
+	}
+
+	items := make([]oneAPIImportItem, 0, len(channels))
+	var created, updated []int
+	for _, ch := range channels {
+		item := oneAPIImportItem{ChannelID: ch.ID, Name: strings.TrimSpace(ch.Name), Action: "skip"}
+		if item.Name == "" {
+			item.Name = fmt.Sprintf("channel-%d", ch.ID)
+		}
+		models := oneAPIChannelModels(ch)
+		item.Models = len(models)
+		baseURL := oneAPIChannelBaseURL(ch)
+		sourceURL := fmt.Sprintf("%s/api/channel/%d", source, ch.ID)
+		existing := bySource[sourceURL]
+		if existing == nil {
+			existing = byName[item.Name+"\x00"+baseURL]
+		}
+		switch {
+		case ch.Status != 1 && !req.IncludeDisabled:
+			item.Detail = "channel is disabled"
+			items = append(items, item)
+			continue
+		case baseURL == "":
+			item.Detail = fmt.Sprintf("channel type %d has no base_url", ch.Type)
+			items = append(items, item)
+			continue
+		}
+
+		key := firstChannelKey(ch.Key)
+		if key == "" && existing == nil {
+			if detail, err := client.channelKey(ctx, ch.ID); err == nil {
+				key = firstChannelKey(detail)
+			}
+			if key == "" {
+				item.Detail = "channel key not returned by the source; add the target manually"
+				items = append(items, item)
+				continue
+			}
+		}
+
+		payload := map[string]any{
+			"name":            item.Name,
+			"base_url":        baseURL,
+			"source_url":      sourceURL,
+			"selected_models": models,
+		}
+		if key != "" {
+			payload["api_key"] = key
+		}
+		if existing == nil {
+			payload["enabled"] = ch.Status == 1
+		}
+		if err := validateTargetPayload(payload); err != nil {
+			item.Detail = err.Error()
+			items = append(items, item)
+			continue
+		}
+
+		if existing != nil {
+			item.Action, item.TargetID = "update", existing.ID
+			if !req.DryRun {
+				if _, err := h.db.UpdateTarget(existing.ID, payload); err != nil {
+					item.Action, item.Detail = "skip", err.Error()
+				} else {
+					updated = append(updated, existing.ID)
+				}
+			}
+		} else {
+			item.Action = "create"
+			if !req.DryRun {
+				target, err := h.db.CreateTarget(payload)
+				if err != nil {
+					item.Action, item.Detail = "skip", err.Error()
+				} else {
+					item.TargetID = target.ID
+					created = append(created, target.ID)
+				}
+			}
+		}
+		items = append(items, item)
+	}
+
+	if len(created)+len(updated) > 0 {
+		h.audit(r, "target.import_oneapi", "target", 0, map[string]any{
+			"source":  map[string]any{"from": nil, "to": source},
+			"created": map[string]any{"from": nil, "to": created},
+			"updated": map[string]any{"from": nil, "to": updated},
+		})
+		h.monitor.emitJSON("target_updated", map[string]any{"ids": append(created, updated...), "action": "import"})
+	}
+	counts := map[string]int{"create": 0, "update": 0, "skip": 0}
+	for _, item := range items {
+		counts[item.Action]++
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"ok":       true,
+		"dry_run":  req.DryRun,
+		"channels": len(channels),
+		"created":  counts["create"],
+		"updated":  counts["update"],
+		"skipped":  counts["skip"],
+		"items":    items,
+	})
+}
@@ -0,0 +1,106 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestOneAPIChannelModelsApplyMapping(t *testing.T) {
+	mapping := `{"gpt-4":"gpt-4-0613"}`
+	got := oneAPIChannelModels(oneAPIChannel{Models: "gpt-4, gpt-4o,,gpt-4-0613", ModelMapping: &mapping})
+	if strings.Join(got, ",") != "gpt-4-0613,gpt-4o" {
+		t.Fatalf("models should be mapped and deduplicated, got=%v", got)
+	}
+}
+
+func TestAdminImportOneAPI(t *testing.T) {
+	db, err := NewDatabase(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDatabase failed: %v", err)
+	}
+	t.Cleanup(func() { _ = db.conn.Close() })
+	if err := db.EnsureAuditSchema(); err != nil {
+		t.Fatalf("EnsureAuditSchema failed: %v", err)
+	}
+	h := &Handlers{db: db, monitor: NewMonitorService(MonitorConfig{DB: db, LogDir: t.TempDir()})}
+
+	existing, err := db.CreateTarget(map[string]any{"name": "relay", "base_url": "https://relay.example.com/v1", "api_key": "sk-old-key-0000"})
+	if err != nil {
+		t.Fatalf("CreateTarget failed: %v", err)
+	}
+
+	// New-API style: paged data, keys omitted from the list, New-Api-User required.
+	source := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer admin-token" || r.Header.Get("New-Api-User") != "1" {
+			_ = json.NewEncoder(w).Encode(map[string]any{"success": false, "message": "unauthorized"})
+			return
+		}
+		switch r.URL.Path {
+		case "/api/channel/":
+			items := []map[string]any{
+				{"id": 1, "type": 1, "status": 1, "name": "official", "base_url": "", "models": "gpt-4o,gpt-4", "model_mapping": `{"gpt-4":"gpt-4-turbo"}`},
+				{"id": 2, "type": 8, "status": 1, "name": "relay", "base_url": "https://relay.example.com", "key": "sk-new-key-1111\nsk-second", "models": "claude-3-5-sonnet"},
+				{"id": 3, "type": 8, "status": 2, "name": "off", "base_url": "https://off.example.com", "key": "sk-off-key-2222", "models": "x"},
+				{"id": 4, "type": 40, "status": 1, "name": "nourl", "key": "sk-nourl-3333", "models": "y"},
+			}
+			_ = json.NewEncoder(w).Encode(map[string]any{"success": true, "data": map[string]any{"items": items, "total": len(items)}})
+		case "/api/channel/1":
+			_ = json.NewEncoder(w).Encode(map[string]any{"success": true, "data": map[string]any{"id": 1, "key": "sk-official-4444"}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer source.Close()
+
+	importOnce := func(dryRun bool) map[string]any {
+		body := `{"base_url":"` + source.URL + `/","token":"admin-token","user_id":1,"dry_run":` + map[bool]string{true: "true", false: "false"}[dryRun] + `}`
+		req := httptest.NewRequest(http.MethodPost, "/api/admin/import/oneapi", strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		h.AdminImportOneAPI(rr, withAuthRole(req, authRoleAdmin))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("import should succeed, got=%d body=%s", rr.Code, rr.Body.String())
+		}
+		var out map[string]any
+		_ = json.Unmarshal(rr.Body.Bytes(), &out)
+		return out
+	}
+
+	plan := importOnce(true)
+	if plan["created"].(float64) != 1 || plan["updated"].(float64) != 1 || plan["skipped"].(float64) != 2 {
+		t.Fatalf("dry run should plan 1 create, 1 update, 2 skips, got=%v", plan)
+	}
+	if targets, _ := db.ListTargets(); len(targets) != 1 {
+		t.Fatalf("dry run should not write targets, got=%d", len(targets))
+	}
+
+	importOnce(false)
+	targets, _ := db.ListTargets()
+	if len(targets) != 2 {
+		t.Fatalf("import should create one target, got=%d", len(targets))
+	}
+	relay, _ := db.GetTarget(existing.ID)
+	if relay.APIKey != "sk-new-key-1111" || strings.Join(relay.SelectedModels, ",") != "claude-3-5-sonnet" {
+		t.Fatalf("matched target should get the first channel key and models, got key=%q models=%v", relay.APIKey, relay.SelectedModels)
+	}
+	var official *Target
+	for i := range targets {
+		if targets[i].Name == "official" {
+			official = &targets[i]
+		}
+	}
+	if official == nil || official.BaseURL != "https://api.openai.com" || official.APIKey != "sk-official-4444" || !official.Enabled {
+		t.Fatalf("official channel should be created with the default base_url and detail key, got=%+v", official)
+	}
+	if strings.Join(official.SelectedModels, ",") != "gpt-4o,gpt-4-turbo" {
+		t.Fatalf("created target should probe mapped models, got=%v", official.SelectedModels)
+	}
+
+	again := importOnce(false)
+	if again["created"].(float64) != 0 || again["updated"].(float64) != 2 {
+		t.Fatalf("re-import should match by source_url, got=%v", again)
+	}
+}
@@ -0,0 +1,464 @@
+package app
+
+import (
+	"cmp"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OpenAPI. The router records every pattern it registers, and GET /api/v1/openapi.json
+// renders those routes as an OpenAPI 3.0 document under their /api/v1 paths, so the
+// spec always lists exactly what the server serves. apiOperations adds a summary, tag and, where the handler
+// returns a known type, request and response schemas generated from the Go structs.
+// TestOpenAPIOperationsCoverRoutes fails when a route is added without an entry.
+
+// routeMux is an http.ServeMux that remembers the patterns registered on it.
+type routeMux struct {
+	*http.ServeMux
+	mu       sync.Mutex
+	patterns []string
+}
+
+func newRouteMux() *routeMux {
+	return &routeMux{ServeMux: http.NewServeMux()}
+}
+
+func (m *routeMux) Handle(pattern string, handler http.Handler) {
+	m.record(pattern)
+	m.ServeMux.Handle(pattern, handler)
+}
+
+func (m *routeMux) HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	m.record(pattern)
+	m.ServeMux.HandleFunc(pattern, handler)
+}
+
+func (m *routeMux) record(pattern string) {
+	m.mu.Lock()
+	m.patterns = append(m.patterns, pattern)
+	m.mu.Unlock()
+}
+
+// Patterns returns the registered patterns in registration order.
+func (m *routeMux) Patterns() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string(nil), m.patterns...)
+}
+
+// apiOperation documents one route. Item and Items are sample values whose type is
+// returned under "item" or "items"; Body is a sample of the JSON request body.
+type apiOperation struct {
+	Tag     string
+	Summary string
+	Body    any
+	Item    any
+	Items   any
+	// Path overrides the documented path, for routes registered as a prefix.
+	Path string
+}
+
+var apiOperations = map[string]apiOperation{
+	"GET /api/openapi.json": {Tag: "meta", Summary: "OpenAPI document for this server"},
+	"GET /api/health":       {Tag: "meta", Summary: "Liveness check and running targets"},
+
+	"POST /api/admin/login":          {Tag: "admin-auth", Summary: "Log in to the admin panel with the admin password"},
+	"POST /api/admin/logout":         {Tag: "admin-auth", Summary: "End the admin session"},
+	"GET /api/admin/oidc":            {Tag: "admin-auth", Summary: "Whether OIDC single sign-on is configured"},
+	"GET /api/admin/oidc/login":      {Tag: "admin-auth", Summary: "Start an OIDC login (redirects to the provider)"},
+	"GET /api/admin/oidc/callback":   {Tag: "admin-auth", Summary: "OIDC redirect target; creates the admin session"},
+	"GET /api/events":                {Tag: "events", Summary: "Server-sent event stream of detection updates"},
+	"GET /api/ws":                    {Tag: "events", Summary: "WebSocket stream of detection updates"},
+	"GET /api/dashboard":             {Tag: "monitor", Summary: "Dashboard snapshot of every target"},
+	"GET /api/incidents":             {Tag: "incidents", Summary: "List incidents", Items: Incident{}},
+	"GET /api/incidents/{id}":        {Tag: "incidents", Summary: "Get an incident", Item: Incident{}},
+	"POST /api/incidents/{id}/ack":   {Tag: "incidents", Summary: "Acknowledge an incident", Item: Incident{}},
+	"POST /api/incidents/{id}/notes": {Tag: "incidents", Summary: "Add a note to an incident", Item: IncidentNote{}},
+
+	"GET /api/analytics/timeseries":                {Tag: "analytics", Summary: "Success rate and latency time series", Items: AnalyticsPoint{}},
+	"GET /api/analytics/models/{model}/compare":    {Tag: "analytics", Summary: "Compare one model across targets", Item: ModelComparison{}},
+	"GET /api/analytics/daily":                     {Tag: "analytics", Summary: "Daily per-model rollups", Items: DailyModelStats{}},
+	"GET /api/analytics/errors":                    {Tag: "analytics", Summary: "Failed detections by error category", Items: ErrorCategoryCount{}},
+	"GET /api/monitor/queue":                       {Tag: "monitor", Summary: "Scheduler queue: running and upcoming detections"},
+	"GET /api/targets":                             {Tag: "targets", Summary: "List targets with their latest status", Items: Target{}},
+	"POST /api/targets":                            {Tag: "targets", Summary: "Create a target", Body: Target{}, Item: Target{}},
+	"POST /api/targets/bulk":                       {Tag: "targets", Summary: "Enable, disable, run or delete several targets"},
+	"GET /api/targets/trash":                       {Tag: "targets", Summary: "List deleted targets", Items: Target{}},
+	"POST /api/targets/validate":                   {Tag: "targets", Summary: "Check a target's connection settings without saving", Body: Target{}},
+	"PATCH /api/targets/reorder":                   {Tag: "targets", Summary: "Set the display order of targets"},
+	"GET /api/targets/{id}":                        {Tag: "targets", Summary: "Get a target", Item: Target{}},
+	"PATCH /api/targets/{id}":                      {Tag: "targets", Summary: "Update a target", Body: Target{}, Item: Target{}},
+	"DELETE /api/targets/{id}":                     {Tag: "targets", Summary: "Move a target to the trash"},
+	"POST /api/targets/{id}/run":                   {Tag: "targets", Summary: "Start a detection run now"},
+	"POST /api/targets/{id}/cancel":                {Tag: "targets", Summary: "Cancel the running detection"},
+	"POST /api/targets/{id}/test":                  {Tag: "targets", Summary: "Probe one model of a target", Items: DetectionResult{}},
+	"POST /api/targets/{id}/clone":                 {Tag: "targets", Summary: "Copy a target", Item: Target{}},
+	"POST /api/targets/{id}/restore":               {Tag: "targets", Summary: "Restore a target from the trash", Item: Target{}},
+	"GET /api/targets/{id}/runs":                   {Tag: "runs", Summary: "List a target's runs", Items: Run{}},
+	"GET /api/targets/{id}/runs/compare":           {Tag: "runs", Summary: "Compare two runs model by model"},
+	"GET /api/targets/{id}/notes":                  {Tag: "notes", Summary: "List a target's notes", Items: Note{}},
+	"POST /api/targets/{id}/notes":                 {Tag: "notes", Summary: "Add a note to a target or run", Item: Note{}},
+	"DELETE /api/targets/{id}/notes/{note_id}":     {Tag: "notes", Summary: "Delete a note"},
+	"GET /api/targets/{id}/logs":                   {Tag: "runs", Summary: "Detection results of a run", Items: DetectionResult{}},
+	"GET /api/targets/{id}/models":                 {Tag: "targets", Summary: "Model list and overrides of a target", Items: ModelStatus{}},
+	"PATCH /api/targets/{id}/models":               {Tag: "targets", Summary: "Update model overrides of a target"},
+	"GET /api/targets/{id}/api-key":                {Tag: "targets", Summary: "Reveal a target's API key (audited)"},
+	"GET /api/targets/{id}/usage":                  {Tag: "analytics", Summary: "Token consumption of a target's detections and proxied requests", Item: TargetUsage{}},
+	"GET /api/targets/{id}/capabilities":           {Tag: "runs", Summary: "Capability probe verdicts of a target's models", Items: ModelCapability{}},
+	"GET /api/targets/{id}/models/{model}/history": {Tag: "runs", Summary: "Result history of one model", Items: ModelHistoryPoint{}},
+	"GET /api/targets/{id}/keys":                   {Tag: "targets", Summary: "Health of each key in a target's key pool", Items: TargetKeyItem{}},
+	"GET /api/targets/{id}/analytics/status-codes": {Tag: "analytics", Summary: "HTTP status code distribution of a target", Items: StatusCodeCount{}},
+
+	"GET /api/proxy/keys":            {Tag: "proxy-keys", Summary: "List proxy keys", Items: ProxyKey{}},
+	"POST /api/proxy/keys":           {Tag: "proxy-keys", Summary: "Create a proxy key; the secret is returned once", Item: ProxyKey{}},
+	"PATCH /api/proxy/keys/{id}":     {Tag: "proxy-keys", Summary: "Update a proxy key's concurrency limit, IP allowlist and fallback chain", Item: ProxyKey{}},
+	"DELETE /api/proxy/keys/{id}":    {Tag: "proxy-keys", Summary: "Revoke a proxy key"},
+	"GET /api/proxy/keys/{id}/stats": {Tag: "proxy-keys", Summary: "Request counts, error rate, latency percentiles and top models of a proxy key over ?window=1h|6h|24h|7d|30d", Item: ProxyKeyStats{}},
+	"GET /api/proxy/audit":           {Tag: "proxy-keys", Summary: "List proxy body audit entries (without bodies); ?key_id=&errors=true&limit=", Items: ProxyAuditEntry{}},
+	"GET /api/proxy/audit/{id}":      {Tag: "proxy-keys", Summary: "Get a proxy body audit entry with its decrypted request and response bodies", Item: ProxyAuditEntry{}},
+	"GET /api/proxy/events":          {Tag: "proxy-keys", Summary: "Server-sent event stream of proxy_request activity"},
+
+	"GET /api/admin/settings":                          {Tag: "admin", Summary: "Get runtime settings"},
+	"PATCH /api/admin/settings":                        {Tag: "admin", Summary: "Update runtime settings"},
+	"POST /api/admin/monitor/pause":                    {Tag: "admin", Summary: "Pause scheduled detections"},
+	"POST /api/admin/monitor/resume":                   {Tag: "admin", Summary: "Resume scheduled detections"},
+	"POST /api/admin/reload":                           {Tag: "admin", Summary: "Reload settings from the database"},
+	"GET /api/admin/sync":                              {Tag: "admin", Summary: "Status of the GitOps target sync"},
+	"POST /api/admin/sync":                             {Tag: "admin", Summary: "Run the GitOps target sync now"},
+	"GET /api/admin/audit":                             {Tag: "admin", Summary: "Query the audit log", Items: AuditEntry{}},
+	"GET /api/admin/resources":                         {Tag: "admin", Summary: "Process resource usage"},
+	"GET /api/admin/logs/usage":                        {Tag: "admin", Summary: "Run log disk usage by target", Items: LogUsage{}},
+	"/api/admin/debug/pprof/":                          {Tag: "admin", Summary: "Go pprof profiles", Path: "/api/admin/debug/pprof/{profile}"},
+	"GET /api/admin/diagnostics":                       {Tag: "admin", Summary: "Configuration diagnostics"},
+	"GET /api/admin/route-rules":                       {Tag: "routing", Summary: "List proxy route rules", Items: RouteRule{}},
+	"POST /api/admin/route-rules":                      {Tag: "routing", Summary: "Create a route rule", Body: RouteRule{}, Item: RouteRule{}},
+	"GET /api/admin/route-rules/resolve":               {Tag: "routing", Summary: "Show which targets a model would be routed to"},
+	"PATCH /api/admin/route-rules/{id}":                {Tag: "routing", Summary: "Update a route rule", Body: RouteRule{}, Item: RouteRule{}},
+	"DELETE /api/admin/route-rules/{id}":               {Tag: "routing", Summary: "Delete a route rule"},
+	"GET /api/admin/notifications/channels":            {Tag: "notifications", Summary: "List notification channels (secrets masked)", Items: NotificationChannel{}},
+	"POST /api/admin/notifications/channels":           {Tag: "notifications", Summary: "Create a notification channel", Body: NotificationChannel{}, Item: NotificationChannel{}},
+	"PATCH /api/admin/notifications/channels/{id}":     {Tag: "notifications", Summary: "Update a notification channel", Body: NotificationChannel{}, Item: NotificationChannel{}},
+	"DELETE /api/admin/notifications/channels/{id}":    {Tag: "notifications", Summary: "Delete a notification channel and drop it from routes"},
+	"POST /api/admin/notifications/channels/{id}/test": {Tag: "notifications", Summary: "Send a test notification to a channel"},
+	"GET /api/admin/notifications/routes":              {Tag: "notifications", Summary: "List notification routes", Items: NotificationRoute{}},
+	"POST /api/admin/notifications/routes":             {Tag: "notifications", Summary: "Create a notification route", Body: NotificationRoute{}, Item: NotificationRoute{}},
+	"PATCH /api/admin/notifications/routes/{id}":       {Tag: "notifications", Summary: "Update a notification route", Body: NotificationRoute{}, Item: NotificationRoute{}},
+	"DELETE /api/admin/notifications/routes/{id}":      {Tag: "notifications", Summary: "Delete a notification route"},
+	"GET /api/admin/templates":                         {Tag: "templates", Summary: "List target templates", Items: TargetTemplate{}},
+	"POST /api/admin/templates":                        {Tag: "templates", Summary: "Create a target template", Body: TargetTemplate{}, Item: TargetTemplate{}},
+	"PATCH /api/admin/templates/{id}":                  {Tag: "templates", Summary: "Update a target template", Body: TargetTemplate{}, Item: TargetTemplate{}},
+	"DELETE /api/admin/templates/{id}":                 {Tag: "templates", Summary: "Delete a target template"},
+	"GET /api/admin/agents":                            {Tag: "agents", Summary: "List probe agents", Items: Agent{}},
+	"POST /api/admin/agents":                           {Tag: "agents", Summary: "Create a probe agent; the token is returned once", Item: Agent{}},
+	"DELETE /api/admin/agents/{id}":                    {Tag: "agents", Summary: "Revoke a probe agent"},
+	"GET /api/admin/api-tokens":                        {Tag: "admin", Summary: "List scoped API tokens", Items: APIToken{}},
+	"POST /api/admin/api-tokens":                       {Tag: "admin", Summary: "Create a scoped API token; the secret is returned once", Item: APIToken{}},
+	"DELETE /api/admin/api-tokens/{id}":                {Tag: "admin", Summary: "Revoke a scoped API token"},
+	"DELETE /api/admin/trash/{id}":                     {Tag: "targets", Summary: "Permanently delete a target from the trash"},
+	"GET /api/admin/archives":                          {Tag: "admin", Summary: "List run archives", Items: ArchiveFile{}},
+	"POST /api/admin/archives/run":                     {Tag: "admin", Summary: "Archive old runs now", Item: ArchiveResult{}},
+	"GET /api/admin/archives/{name}":                   {Tag: "admin", Summary: "Download a run archive (gzip JSONL)"},
+	"GET /api/admin/reports/preview":                   {Tag: "admin", Summary: "Render the last summary report (HTML, or JSON with format=json)"},
+	"POST /api/admin/reports/send":                     {Tag: "admin", Summary: "Send the last summary report now", Item: SummaryReport{}},
+	"GET /api/admin/channels":                          {Tag: "channels", Summary: "List channels with admin-only fields", Items: Target{}},
+	"PATCH /api/admin/channels/{id}/advanced":          {Tag: "channels", Summary: "Update advanced channel settings", Item: Target{}},
+	"GET /api/admin/channels/{id}/models":              {Tag: "channels", Summary: "Model list and overrides of a channel", Items: ModelStatus{}},
+	"GET /api/admin/channels/{id}/api-key":             {Tag: "channels", Summary: "Reveal a channel's API key (audited)"},
+	"PATCH /api/admin/channels/{id}/models":            {Tag: "channels", Summary: "Update model overrides of a channel"},
+	"POST /api/admin/import/oneapi":                    {Tag: "channels", Summary: "Import channels from a One API / New API instance"},
+
+	"POST /api/agent/register":   {Tag: "agent", Summary: "Register a probe agent", Item: Agent{}},
+	"GET /api/agent/assignments": {Tag: "agent", Summary: "Lease detection assignments"},
+	"POST /api/agent/results":    {Tag: "agent", Summary: "Report results of an assignment", Body: []DetectionResult{}},
+
+	"GET /v1/models":            {Tag: "proxy", Summary: "OpenAI-compatible model list of healthy targets"},
+	"POST /v1/chat/completions": {Tag: "proxy", Summary: "OpenAI Chat Completions through the healthiest target"},
+	"POST /v1/messages":         {Tag: "proxy", Summary: "Anthropic Messages through the healthiest target"},
+	"POST /v1/responses":        {Tag: "proxy", Summary: "OpenAI Responses through the healthiest target"},
+	"POST /v1beta/models/":      {Tag: "proxy", Summary: "Gemini generateContent / streamGenerateContent", Path: "/v1beta/models/{model_action}"},
+}
+
+var openAPIPathParam = regexp.MustCompile(`\{([A-Za-z_][A-Za-z0-9_]*)(\.\.\.)?\}`)
+
+// openAPIHandler serves the document for the routes registered on mux. It is built
+// on the first request, after every route is in place.
+func openAPIHandler(mux *routeMux) http.Handler {
+	var once sync.Once
+	var body []byte
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		once.Do(func() {
+			body, _ = json.Marshal(buildOpenAPI(mux.Patterns()))
+		})
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-cache")
+		_, _ = w.Write(body)
+	})
+}
+
+// buildOpenAPI renders the API routes among patterns (those under /api/ and the proxy
+// paths) as an OpenAPI 3.0 document.
+func buildOpenAPI(patterns []string) map[string]any {
+	schemas := map[string]any{
+		"Error": map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"detail": map[string]any{"type": "string"},
+				"errors": map[string]any{
+					"type": "array",
+					"items": map[string]any{
+						"type": "object",
+						"properties": map[string]any{
+							"field":   map[string]any{"type": "string"},
+							"message": map[string]any{"type": "string"},
+						},
+					},
+				},
+			},
+		},
+	}
+	paths := map[string]map[string]any{}
+	tags := map[string]bool{}
+	for _, pattern := range patterns {
+		method, path, ok := strings.Cut(pattern, " ")
+		if !ok {
+			method, path = "", pattern
+		}
+		if !strings.HasPrefix(path, "/api/") && !strings.HasPrefix(path, "/v1") {
+			continue
+		}
+		meta, known := apiOperations[pattern]
+		if meta.Path != "" {
+			path = meta.Path
+		}
+		if meta.Tag == "" {
+			meta.Tag = "other"
+		}
+		if !known {
+			meta.Summary = pattern
+		}
+		tags[meta.Tag] = true
+
+		op := map[string]any{
+			"operationId": openAPIOperationID(cmp.Or(method, http.MethodGet), path),
+			"summary":     meta.Summary,
+			"tags":        []string{meta.Tag},
+			"responses":   openAPIResponses(meta, schemas),
+		}
+		if params := openAPIParameters(path); len(params) > 0 {
+			op["parameters"] = params
+		}
+		if meta.Body != nil {
+			op["requestBody"] = map[string]any{
+				"content": map[string]any{"application/json": map[string]any{
+					"schema": jsonSchemaFor(reflect.TypeOf(meta.Body), schemas),
+				}},
+			}
+		}
+		if sec := openAPISecurity(path); sec != nil {
+			op["security"] = sec
+		}
+		path = openAPIPathParam.ReplaceAllString(path, "{$1}")
+		if strings.HasPrefix(path, "/api/") {
+			path = apiV1Prefix + strings.TrimPrefix(path, "/api")
+		}
+		if paths[path] == nil {
+			paths[path] = map[string]any{}
+		}
+		if method == "" {
+			method = http.MethodGet
+		}
+		paths[path][strings.ToLower(method)] = op
+	}
+
+	tagList := make([]map[string]any, 0, len(tags))
+	for _, name := range sortedKeys(tags) {
+		tagList = append(tagList, map[string]any{"name": name})
+	}
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":       "api_monitor",
+			"description": "Monitor, admin and proxy API of api_monitor.",
+			"version":     "1",
+		},
+		"tags":  tagList,
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": schemas,
+			"securitySchemes": map[string]any{
+				"bearerAuth": map[string]any{
+					"type":        "http",
+					"scheme":      "bearer",
+					"description": "Admin or visitor token, scoped API token, proxy key or agent token, depending on the route.",
+				},
+				"adminSession": map[string]any{
+					"type": "apiKey",
+					"in":   "cookie",
+					"name": adminSessionCookieName,
+				},
+			},
+		},
+	}
+}
+
+func openAPIResponses(meta apiOperation, schemas map[string]any) map[string]any {
+	ok := map[string]any{"description": "OK"}
+	var props map[string]any
+	switch {
+	case meta.Item != nil:
+		props = map[string]any{"item": jsonSchemaFor(reflect.TypeOf(meta.Item), schemas)}
+	case meta.Items != nil:
+		props = map[string]any{"items": map[string]any{
+			"type":  "array",
+			"items": jsonSchemaFor(reflect.TypeOf(meta.Items), schemas),
+		}}
+	}
+	if props != nil {
+		ok["content"] = map[string]any{"application/json": map[string]any{
+			"schema": map[string]any{"type": "object", "properties": props},
+		}}
+	}
+	return map[string]any{
+		"200": ok,
+		"default": map[string]any{
+			"description": "Error",
+			"content": map[string]any{"application/json": map[string]any{
+				"schema": map[string]any{"$ref": "#/components/schemas/Error"},
+			}},
+		},
+	}
+}
+
+func openAPIParameters(path string) []map[string]any {
+	var params []map[string]any
+	for _, m := range openAPIPathParam.FindAllStringSubmatch(path, -1) {
+		schema := map[string]any{"type": "string"}
+		if m[1] == "id" || strings.HasSuffix(m[1], "_id") {
+			schema = map[string]any{"type": "integer"}
+		}
+		params = append(params, map[string]any{"name": m[1], "in": "path", "required": true, "schema": schema})
+	}
+	return params
+}
+
+// openAPISecurity returns the accepted credentials of a route, or nil for public routes.
+func openAPISecurity(path string) []map[string][]string {
+	bearer := []map[string][]string{{"bearerAuth": {}}}
+	switch {
+	case path == "/api/health" || path == "/api/openapi.json" || path == "/api/admin/login" ||
+		strings.HasPrefix(path, "/api/admin/oidc"):
+		return nil
+	case strings.HasPrefix(path, "/api/admin/") || strings.HasPrefix(path, "/api/proxy/"):
+		return append(bearer, map[string][]string{"adminSession": {}})
+	default:
+		return bearer
+	}
+}
+
+func openAPIOperationID(method, path string) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(method))
+	for _, part := range strings.FieldsFunc(path, func(r rune) bool { return r == '/' || r == '-' || r == '.' || r == '{' || r == '}' }) {
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return b.String()
+}
+
+// jsonSchemaFor returns the schema of t, registering named struct types in schemas and
+// referencing them by name.
+func jsonSchemaFor(t reflect.Type, schemas map[string]any) map[string]any {
+	nullable := false
+	for t.Kind() == reflect.Pointer {
+		t, nullable = t.Elem(), true
+	}
+	var schema map[string]any
+	switch {
+	case t == reflect.TypeOf(time.Time{}):
+		schema = map[string]any{"type": "string", "format": "date-time"}
+	case t == reflect.TypeOf(json.RawMessage{}):
+		schema = map[string]any{}
+	case t.Kind() == reflect.Struct && t.Name() != "":
+		if _, ok := schemas[t.Name()]; !ok {
+			schemas[t.Name()] = nil // placeholder for recursive types
+			schemas[t.Name()] = jsonStructSchema(t, schemas)
+		}
+		ref := map[string]any{"$ref": "#/components/schemas/" + t.Name()}
+		if nullable {
+			return map[string]any{"allOf": []any{ref}, "nullable": true}
+		}
+		return ref
+	default:
+		switch t.Kind() {
+		case reflect.Struct:
+			schema = jsonStructSchema(t, schemas)
+		case reflect.Bool:
+			schema = map[string]any{"type": "boolean"}
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			schema = map[string]any{"type": "integer"}
+		case reflect.Float32, reflect.Float64:
+			schema = map[string]any{"type": "number"}
+		case reflect.String:
+			schema = map[string]any{"type": "string"}
+		case reflect.Slice, reflect.Array:
+			schema = map[string]any{"type": "array", "items": jsonSchemaFor(t.Elem(), schemas)}
+		case reflect.Map:
+			schema = map[string]any{"type": "object", "additionalProperties": jsonSchemaFor(t.Elem(), schemas)}
+		default:
+			schema = map[string]any{}
+		}
+	}
+	if nullable {
+		schema["nullable"] = true
+	}
+	return schema
+}
+
+func jsonStructSchema(t reflect.Type, schemas map[string]any) map[string]any {
+	props := map[string]any{}
+	var collect func(t reflect.Type)
+	collect = func(t reflect.Type) {
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			tag := f.Tag.Get("json")
+			if tag == "-" {
+				continue
+			}
+			name, _, _ := strings.Cut(tag, ",")
+			ft := f.Type
+			if f.Anonymous && name == "" {
+				for ft.Kind() == reflect.Pointer {
+					ft = ft.Elem()
+				}
+				if ft.Kind() == reflect.Struct {
+					collect(ft)
+					continue
+				}
+			}
+			if !f.IsExported() {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			props[name] = jsonSchemaFor(f.Type, schemas)
+		}
+	}
+	collect(t)
+	return map[string]any{"type": "object", "properties": props}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
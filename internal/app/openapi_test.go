@@ -0,0 +1,91 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// registeredAPIRoutes reads the API patterns registered in Start from run.go.
+func registeredAPIRoutes(t *testing.T) []string {
+	t.Helper()
+	src, err := os.ReadFile("run.go")
+	if err != nil {
+		t.Fatalf("read run.go failed: %v", err)
+	}
+	var out []string
+	for _, m := range regexp.MustCompile(`mux\.Handle(?:Func)?\("([^"]+)"`).FindAllStringSubmatch(string(src), -1) {
+		path := m[1]
+		if _, p, ok := strings.Cut(path, " "); ok {
+			path = p
+		}
+		if strings.HasPrefix(path, "/api/") || strings.HasPrefix(path, "/v1") {
+			out = append(out, m[1])
+		}
+	}
+	return out
+}
+
+func TestOpenAPIOperationsCoverRoutes(t *testing.T) {
+	routes := registeredAPIRoutes(t)
+	seen := map[string]bool{}
+	for _, pattern := range routes {
+		seen[pattern] = true
+		if op, ok := apiOperations[pattern]; !ok || op.Summary == "" || op.Tag == "" {
+			t.Fatalf("route %q should be documented in apiOperations", pattern)
+		}
+	}
+	for pattern := range apiOperations {
+		if !seen[pattern] {
+			t.Fatalf("apiOperations entry %q does not match a registered route", pattern)
+		}
+	}
+}
+
+func TestOpenAPIDocumentDescribesRoutes(t *testing.T) {
+	mux := newRouteMux()
+	for _, pattern := range registeredAPIRoutes(t) {
+		mux.HandleFunc(pattern, func(http.ResponseWriter, *http.Request) {})
+	}
+	mux.HandleFunc("GET /admin", func(http.ResponseWriter, *http.Request) {})
+	rr := httptest.NewRecorder()
+	openAPIHandler(mux).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/openapi.json", nil))
+
+	var doc struct {
+		OpenAPI    string                               `json:"openapi"`
+		Paths      map[string]map[string]map[string]any `json:"paths"`
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]any `json:"properties"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("document should be JSON: %v", err)
+	}
+	if doc.OpenAPI != "3.0.3" || doc.Paths["/admin"] != nil {
+		t.Fatalf("document should be OpenAPI 3 with API routes only, got version=%s", doc.OpenAPI)
+	}
+	get := doc.Paths["/api/v1/targets/{id}"]["get"]
+	params, _ := get["parameters"].([]any)
+	if get == nil || len(params) != 1 || get["security"] == nil {
+		t.Fatalf("GET /api/v1/targets/{id} should have an id parameter and security, got=%v", get)
+	}
+	if doc.Paths["/api/v1/health"]["get"]["security"] != nil {
+		t.Fatalf("health check should be public")
+	}
+	if doc.Paths["/v1/chat/completions"]["post"] == nil || doc.Paths["/v1beta/models/{model_action}"]["post"] == nil {
+		t.Fatalf("proxy endpoints should be documented, got paths=%v", len(doc.Paths))
+	}
+	target := doc.Components.Schemas["Target"].Properties
+	if target["base_url"] == nil || target["api_key"] == nil {
+		t.Fatalf("Target schema should be generated from the struct, got=%v", target)
+	}
+	if result := doc.Components.Schemas["DetectionResult"].Properties; result["model"] == nil || result["Capture"] != nil {
+		t.Fatalf("DetectionResult schema should follow json tags, got=%v", result)
+	}
+}
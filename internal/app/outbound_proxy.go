@@ -0,0 +1,148 @@
+package app
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+// parseOutboundProxyURL parses a per-target proxy_url. An empty value means direct connection.
+func parseOutboundProxyURL(raw string) (*url.URL, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy_url: %w", err)
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("proxy_url scheme must be http, https, socks5 or socks5h")
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("proxy_url host is required")
+	}
+	return u, nil
+}
+
+// outboundDialer dials TCP connections either directly or through an HTTP CONNECT / SOCKS5 proxy.
+type outboundDialer struct {
+	proxyURL *url.URL
+	base     *net.Dialer
+}
+
+func newOutboundDialer(proxyURL *url.URL) *outboundDialer {
+	return &outboundDialer{proxyURL: proxyURL, base: &net.Dialer{Timeout: 10 * time.Second}}
+}
+
+func (d *outboundDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	if d.proxyURL == nil {
+		return d.base.DialContext(ctx, network, addr)
+	}
+	switch strings.ToLower(d.proxyURL.Scheme) {
+	case "socks5", "socks5h":
+		return d.dialSOCKS5(ctx, network, addr)
+	default:
+		return d.dialHTTPConnect(ctx, addr)
+	}
+}
+
+func (d *outboundDialer) dialSOCKS5(ctx context.Context, network, addr string) (net.Conn, error) {
+	var auth *proxy.Auth
+	if d.proxyURL.User != nil {
+		password, _ := d.proxyURL.User.Password()
+		auth = &proxy.Auth{User: d.proxyURL.User.Username(), Password: password}
+	}
+	dialer, err := proxy.SOCKS5("tcp", proxyHostPort(d.proxyURL), auth, d.base)
+	if err != nil {
+		return nil, fmt.Errorf("socks5 proxy: %w", err)
+	}
+	cd, ok := dialer.(proxy.ContextDialer)
+	if !ok {
+		return nil, fmt.Errorf("socks5 proxy: context dial not supported")
+	}
+	conn, err := cd.DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, fmt.Errorf("socks5 proxy: %w", err)
+	}
+	return conn, nil
+}
+
+func (d *outboundDialer) dialHTTPConnect(ctx context.Context, addr string) (net.Conn, error) {
+	conn, err := d.base.DialContext(ctx, "tcp", proxyHostPort(d.proxyURL))
+	if err != nil {
+		return nil, fmt.Errorf("http proxy: %w", err)
+	}
+	if strings.EqualFold(d.proxyURL.Scheme, "https") {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: d.proxyURL.Hostname()})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("http proxy tls handshake: %w", err)
+		}
+		conn = tlsConn
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+		defer conn.SetDeadline(time.Time{})
+	}
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if d.proxyURL.User != nil {
+		password, _ := d.proxyURL.User.Password()
+		cred := base64.StdEncoding.EncodeToString([]byte(d.proxyURL.User.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+cred)
+	}
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("http proxy connect: %w", err)
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("http proxy connect: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("http proxy connect: %s", resp.Status)
+	}
+	if br.Buffered() > 0 {
+		conn.Close()
+		return nil, fmt.Errorf("http proxy connect: unexpected data after response")
+	}
+	return conn, nil
+}
+
+func proxyHostPort(u *url.URL) string {
+	port := u.Port()
+	if port == "" {
+		switch strings.ToLower(u.Scheme) {
+		case "https":
+			port = "443"
+		case "socks5", "socks5h":
+			port = "1080"
+		default:
+			port = "80"
+		}
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
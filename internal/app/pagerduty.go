@@ -0,0 +1,114 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// PagerDuty Events API v2. A pagerduty channel triggers an alert when a target goes down
+// or starts flapping and resolves it when the target recovers; both use the dedup key
+// of the target so PagerDuty folds them into one incident. Other alert events trigger
+// alerts keyed by target and event type that stay open until resolved in PagerDuty.
+// Our severities are PagerDuty's (info, warning, error, critical), so the target's
+// severity is passed through as is. The routing key is the channel's
+// config.routing_key or, when that is empty, the pagerduty_routing_key admin setting.
+
+const settingPagerDutyRoutingKey = "pagerduty_routing_key"
+
+const pagerDutyEventsURL = "https://events.pagerduty.com"
+
+// pagerDutyRoutingKey holds the pagerduty_routing_key setting.
+var pagerDutyRoutingKey atomic.Value
+
+func setPagerDutyRoutingKey(key string) {
+	pagerDutyRoutingKey.Store(strings.TrimSpace(key))
+}
+
+func getPagerDutyRoutingKey() string {
+	key, _ := pagerDutyRoutingKey.Load().(string)
+	return key
+}
+
+// validatePagerDutyRoutingKey checks the integration key in field; empty is allowed.
+func validatePagerDutyRoutingKey(field, key string) string {
+	if len(key) > 128 || strings.ContainsAny(key, " \t\r\n") {
+		return field + " must be <= 128 chars without whitespace"
+	}
+	return ""
+}
+
+type pagerDutyNotifier struct {
+	apiURL     string
+	routingKey string
+}
+
+func newPagerDutyNotifier(cfg map[string]any) (notifier, error) {
+	apiURL, err := configURL(cfg, "api_url", pagerDutyEventsURL)
+	if err != nil {
+		return nil, err
+	}
+	key := configString(cfg, "routing_key")
+	if detail := validatePagerDutyRoutingKey("config.routing_key", key); detail != "" {
+		return nil, errors.New(detail)
+	}
+	return &pagerDutyNotifier{apiURL: apiURL, routingKey: key}, nil
+}
+
+// pagerDutyAction returns the event action and dedup key for n.
+func pagerDutyAction(n Notification) (string, string) {
+	incidentKey := fmt.Sprintf("api-monitor-target-%d", n.TargetID)
+	switch n.Event {
+	case "incident_opened", "target_flapping":
+		return "trigger", incidentKey
+	case "incident_closed":
+		return "resolve", incidentKey
+	case "flapping_ended":
+		if status, _ := n.Data["status"].(string); status == "healthy" || status == "degraded" {
+			return "resolve", incidentKey
+		}
+		return "trigger", incidentKey
+	}
+	return "trigger", fmt.Sprintf("api-monitor-target-%d-%s", n.TargetID, n.Event)
+}
+
+func (p *pagerDutyNotifier) Notify(ctx context.Context, n Notification) error {
+	key := p.routingKey
+	if key == "" {
+		key = getPagerDutyRoutingKey()
+	}
+	if key == "" {
+		return fmt.Errorf("no PagerDuty routing key: set config.routing_key or the pagerduty_routing_key setting")
+	}
+	action, dedupKey := pagerDutyAction(n)
+	event := map[string]any{
+		"routing_key":  key,
+		"event_action": action,
+		"dedup_key":    dedupKey,
+	}
+	if action == "trigger" {
+		source := n.TargetName
+		if source == "" {
+			source = "api_monitor"
+		}
+		details := map[string]any{"message": n.Message, "event": n.Event, "tags": n.Tags}
+		for k, v := range n.Data {
+			details[k] = v
+		}
+		event["payload"] = map[string]any{
+			"summary":        truncStr(n.Title+": "+n.Message, 1024),
+			"source":         source,
+			"severity":       n.Severity,
+			"timestamp":      time.UnixMilli(int64(n.At * 1000)).UTC().Format(time.RFC3339),
+			"component":      n.Event,
+			"custom_details": details,
+		}
+	}
+	if _, err := postNotificationJSON(ctx, p.apiURL+"/v2/enqueue", nil, event); err != nil {
+		return fmt.Errorf("pagerduty enqueue failed: %s", strings.ReplaceAll(err.Error(), key, "****"))
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPagerDutyTriggersAndResolvesByTarget(t *testing.T) {
+	var events []map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v2/enqueue" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		var ev map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&ev)
+		events = append(events, ev)
+		w.WriteHeader(http.StatusAccepted)
+		_, _ = w.Write([]byte(`{"status":"success","dedup_key":"x"}`))
+	}))
+	defer srv.Close()
+
+	setPagerDutyRoutingKey("")
+	t.Cleanup(func() { setPagerDutyRoutingKey("") })
+	n, err := buildNotifier("pagerduty", map[string]any{"api_url": srv.URL})
+	if err != nil {
+		t.Fatalf("buildNotifier failed: %v", err)
+	}
+	down := Notification{Event: "incident_opened", Severity: severityError, Title: "prod is down", TargetID: 7, TargetName: "prod", At: 1700000000}
+	if err := n.Notify(context.Background(), down); err == nil {
+		t.Fatalf("notify without any routing key should fail")
+	}
+	setPagerDutyRoutingKey("settings-key")
+	if err := n.Notify(context.Background(), down); err != nil {
+		t.Fatalf("trigger failed: %v", err)
+	}
+	up := Notification{Event: "incident_closed", Severity: severityError, TargetID: 7, Data: map[string]any{"status": "healthy"}}
+	if err := n.Notify(context.Background(), up); err != nil {
+		t.Fatalf("resolve failed: %v", err)
+	}
+
+	if len(events) != 2 {
+		t.Fatalf("expected a trigger and a resolve, got=%v", events)
+	}
+	trigger, resolve := events[0], events[1]
+	payload, _ := trigger["payload"].(map[string]any)
+	if trigger["event_action"] != "trigger" || trigger["routing_key"] != "settings-key" || payload["severity"] != "error" || payload["source"] != "prod" {
+		t.Fatalf("unexpected trigger event: %v", trigger)
+	}
+	if resolve["event_action"] != "resolve" || resolve["dedup_key"] != trigger["dedup_key"] || resolve["payload"] != nil {
+		t.Fatalf("resolve should reuse the trigger's dedup key, got=%v", resolve)
+	}
+}
@@ -0,0 +1,71 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestProbeEndpointsAddOpenAIRoutes(t *testing.T) {
+	var mu sync.Mutex
+	paths := map[string]int{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths[r.URL.Path]++
+		mu.Unlock()
+		switch r.URL.Path {
+		case "/v1/chat/completions":
+			_, _ = w.Write([]byte(`{"choices":[{"message":{"content":"ok"}}]}`))
+		case "/v1/responses":
+			_, _ = w.Write([]byte(`{"output":[{"type":"message","content":[{"type":"output_text","text":"ok"}]}]}`))
+		case "/v1/messages":
+			_, _ = w.Write([]byte(`{"type":"message","content":[{"type":"text","text":"ok"}]}`))
+		default:
+			_, _ = w.Write([]byte(`{"candidates":[{"content":{"parts":[{"text":"ok"}]}}]}`))
+		}
+	}))
+	defer srv.Close()
+
+	db, target := newAnalyticsTestDB(t)
+	target.BaseURL = srv.URL
+	target.ProbeEndpoints = []string{"chat", "responses"}
+	ms := NewMonitorService(MonitorConfig{DB: db, LogDir: t.TempDir()})
+	client := httpClient(5, false, nil, tlsFingerprintNone)
+
+	if routes := ms.detectionRoutes(target, "gpt-4o"); len(routes) != 2 || routes[0] != "chat" || routes[1] != "responses" {
+		t.Fatalf("openai model should be probed once per endpoint without repeating the primary, got=%v", routes)
+	}
+	for _, model := range []string{"claude-3-5-sonnet", "gemini-1.5-pro"} {
+		if routes := ms.detectionRoutes(target, model); len(routes) != 1 || routeToProtocol(routes[0]) == "openai" {
+			t.Fatalf("%s should ignore probe_endpoints, got=%v", model, routes)
+		}
+	}
+
+	resultCh, planned := ms.probeModels(context.Background(), target, client, []string{"gpt-4o", "claude-3-5-sonnet"})
+	endpoints := map[string]int{}
+	for row := range resultCh {
+		if !row.Success {
+			t.Fatalf("probe should succeed, got=%+v", row)
+		}
+		endpoints[row.Model+" "+row.Endpoint]++
+	}
+	if planned != 3 || len(endpoints) != 3 || endpoints["gpt-4o chat"] != 1 || endpoints["gpt-4o responses"] != 1 || endpoints["claude-3-5-sonnet messages"] != 1 {
+		t.Fatalf("expected one row per model endpoint, planned=%d got=%v", planned, endpoints)
+	}
+	if paths["/v1/chat/completions"] != 1 || paths["/v1/responses"] != 1 || paths["/v1/messages"] != 1 {
+		t.Fatalf("each route should be requested once, got=%v", paths)
+	}
+}
+
+func TestValidateTargetPayload_ProbeEndpoints(t *testing.T) {
+	if err := validateTargetPayload(map[string]any{"probe_endpoints": []any{"chat", "responses"}}); err != nil {
+		t.Fatalf("chat and responses should be accepted: %v", err)
+	}
+	for _, bad := range []any{[]any{"messages"}, []any{"chat", "completions"}, []any{1}, "chat"} {
+		if err := validateTargetPayload(map[string]any{"probe_endpoints": bad}); err == nil {
+			t.Fatalf("probe_endpoints %v should be rejected", bad)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package app
 
 import (
 	"bytes"
+	"context"
 	"crypto/rand"
 	"crypto/sha256"
 	"crypto/subtle"
@@ -11,7 +12,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"log"
+	"math"
 	"net/http"
 	"net/url"
 	"sort"
@@ -30,6 +31,7 @@ var (
 	errProxyMissingModel      = errors.New("model is required for this proxy key")
 	errProxyInvalidAuthHeader = errors.New("missing or invalid Authorization header")
 	errProxyInvalidKey        = errors.New("invalid or revoked proxy key")
+	errProxyIPNotAllowed      = errors.New("client ip is not allowed by proxy key")
 )
 
 // ProxyKey is a proxy credential record.
@@ -45,15 +47,30 @@ type ProxyKey struct {
 	RevokedAt        *float64 `json:"revoked_at"`
 	LastUsedAt       *float64 `json:"last_used_at"`
 	LastUsedTargetID *int     `json:"last_used_target_id"`
+	// MaxConcurrent caps the key's in-flight proxy requests; 0 is unlimited.
+	MaxConcurrent int `json:"max_concurrent"`
+	// IPAllowlist lists the IPs and CIDRs the key may be used from; empty allows any.
+	IPAllowlist []string `json:"ip_allowlist"`
+	// FallbackTargetIDs is the ordered fallback chain; FallbackTimeoutMs bounds each hop.
+	FallbackTargetIDs []int `json:"fallback_target_ids"`
+	FallbackTimeoutMs int   `json:"fallback_timeout_ms"`
 }
 
 type createProxyKeyRequest struct {
-	Name             string   `json:"name"`
-	AllowedTargetIDs []int    `json:"allowed_target_ids"`
-	AllowedModels    []string `json:"allowed_models"`
-	Description      string   `json:"description"`
+	Name              string   `json:"name"`
+	AllowedTargetIDs  []int    `json:"allowed_target_ids"`
+	AllowedModels     []string `json:"allowed_models"`
+	Description       string   `json:"description"`
+	MaxConcurrent     int      `json:"max_concurrent"`
+	IPAllowlist       []string `json:"ip_allowlist"`
+	FallbackTargetIDs []int    `json:"fallback_target_ids"`
+	FallbackTimeoutMs int      `json:"fallback_timeout_ms"`
 }
 
+const proxyKeyColumns = `id, name, key_prefix, allowed_targets, allowed_models, description,
+		       enabled, created_at, revoked_at, last_used_at, last_used_target_id, max_concurrent, ip_allowlist,
+		       fallback_target_ids, fallback_timeout_ms`
+
 func (d *Database) EnsureProxySchema() error {
 	d.mu.Lock()
 	defer d.mu.Unlock()
@@ -71,7 +88,11 @@ func (d *Database) EnsureProxySchema() error {
 			created_at REAL NOT NULL,
 			revoked_at REAL,
 			last_used_at REAL,
-			last_used_target_id INTEGER
+			last_used_target_id INTEGER,
+			max_concurrent INTEGER NOT NULL DEFAULT 0,
+			ip_allowlist TEXT NOT NULL DEFAULT '[]',
+			fallback_target_ids TEXT NOT NULL DEFAULT '[]',
+			fallback_timeout_ms INTEGER NOT NULL DEFAULT 0
 		);
 
 		CREATE INDEX IF NOT EXISTS idx_proxy_keys_enabled
@@ -80,6 +101,22 @@ func (d *Database) EnsureProxySchema() error {
 	if err != nil {
 		return fmt.Errorf("init proxy schema: %w", err)
 	}
+	existing, err := d.tableColumns("proxy_keys")
+	if err != nil {
+		return fmt.Errorf("init proxy schema: %w", err)
+	}
+	for _, m := range []struct{ column, ddl string }{
+		{"max_concurrent", "ALTER TABLE proxy_keys ADD COLUMN max_concurrent INTEGER NOT NULL DEFAULT 0"},
+		{"ip_allowlist", "ALTER TABLE proxy_keys ADD COLUMN ip_allowlist TEXT NOT NULL DEFAULT '[]'"},
+		{"fallback_target_ids", "ALTER TABLE proxy_keys ADD COLUMN fallback_target_ids TEXT NOT NULL DEFAULT '[]'"},
+		{"fallback_timeout_ms", "ALTER TABLE proxy_keys ADD COLUMN fallback_timeout_ms INTEGER NOT NULL DEFAULT 0"},
+	} {
+		if !existing[m.column] {
+			if _, err := d.conn.Exec(m.ddl); err != nil {
+				return fmt.Errorf("init proxy schema: %w", err)
+			}
+		}
+	}
 	return nil
 }
 
@@ -89,10 +126,13 @@ func scanProxyKey(r interface{ Scan(dest ...any) error }) (*ProxyKey, error) {
 		enabledInt         int
 		allowedTargetsJSON string
 		allowedModelsJSON  string
+		ipAllowlistJSON    string
+		fallbackJSON       string
 	)
 	if err := r.Scan(
 		&k.ID, &k.Name, &k.KeyPrefix, &allowedTargetsJSON, &allowedModelsJSON,
 		&k.Description, &enabledInt, &k.CreatedAt, &k.RevokedAt, &k.LastUsedAt, &k.LastUsedTargetID,
+		&k.MaxConcurrent, &ipAllowlistJSON, &fallbackJSON, &k.FallbackTimeoutMs,
 	); err != nil {
 		return nil, err
 	}
@@ -109,13 +149,18 @@ func scanProxyKey(r interface{ Scan(dest ...any) error }) (*ProxyKey, error) {
 	if k.AllowedModels == nil {
 		k.AllowedModels = []string{}
 	}
+	if err := json.Unmarshal([]byte(ipAllowlistJSON), &k.IPAllowlist); err != nil || k.IPAllowlist == nil {
+		k.IPAllowlist = []string{}
+	}
+	if err := json.Unmarshal([]byte(fallbackJSON), &k.FallbackTargetIDs); err != nil || k.FallbackTargetIDs == nil {
+		k.FallbackTargetIDs = []int{}
+	}
 	return &k, nil
 }
 
 func (d *Database) getProxyKeyByID(id int) (*ProxyKey, error) {
-	row := d.conn.QueryRow(`
-		SELECT id, name, key_prefix, allowed_targets, allowed_models, description,
-		       enabled, created_at, revoked_at, last_used_at, last_used_target_id
+	row := d.read.QueryRow(`
+		SELECT `+proxyKeyColumns+`
 		FROM proxy_keys
 		WHERE id = ?`,
 		id,
@@ -180,7 +225,7 @@ func proxyKeyHash(token string) string {
 	return hex.EncodeToString(sum[:])
 }
 
-func (d *Database) CreateProxyKey(name string, allowedTargetIDs []int, allowedModels []string, description string) (*ProxyKey, string, error) {
+func (d *Database) CreateProxyKey(name string, allowedTargetIDs []int, allowedModels []string, description string, maxConcurrent int, ipAllowlist []string) (*ProxyKey, string, error) {
 	name = strings.TrimSpace(name)
 	if name == "" {
 		return nil, "", fmt.Errorf("name is required")
@@ -190,6 +235,10 @@ func (d *Database) CreateProxyKey(name string, allowedTargetIDs []int, allowedMo
 	models := normalizeProxyAllowedModels(allowedModels)
 	targetsJSON, _ := json.Marshal(targets)
 	modelsJSON, _ := json.Marshal(models)
+	if ipAllowlist == nil {
+		ipAllowlist = []string{}
+	}
+	allowlistJSON, _ := json.Marshal(ipAllowlist)
 	now := float64(time.Now().UnixMilli()) / 1000.0
 
 	for i := 0; i < 5; i++ {
@@ -208,9 +257,10 @@ func (d *Database) CreateProxyKey(name string, allowedTargetIDs []int, allowedMo
 		res, err := d.conn.Exec(`
 			INSERT INTO proxy_keys (
 				name, key_hash, key_prefix, allowed_targets, allowed_models,
-				description, enabled, created_at
-			) VALUES (?, ?, ?, ?, ?, ?, 1, ?)`,
+				description, enabled, created_at, max_concurrent, ip_allowlist
+			) VALUES (?, ?, ?, ?, ?, ?, 1, ?, ?, ?)`,
 			name, hash, prefix, string(targetsJSON), string(modelsJSON), description, now,
+			maxConcurrent, string(allowlistJSON),
 		)
 		d.mu.Unlock()
 		if err != nil {
@@ -235,9 +285,8 @@ func (d *Database) CreateProxyKey(name string, allowedTargetIDs []int, allowedMo
 }
 
 func (d *Database) ListProxyKeys() ([]ProxyKey, error) {
-	rows, err := d.conn.Query(`
-		SELECT id, name, key_prefix, allowed_targets, allowed_models, description,
-		       enabled, created_at, revoked_at, last_used_at, last_used_target_id
+	rows, err := d.read.Query(`
+		SELECT ` + proxyKeyColumns + `
 		FROM proxy_keys
 		ORDER BY created_at DESC, id DESC
 	`)
@@ -275,9 +324,8 @@ func (d *Database) RevokeProxyKey(id int) (bool, error) {
 
 func (d *Database) GetActiveProxyKeyByToken(token string) (*ProxyKey, error) {
 	hash := proxyKeyHash(token)
-	row := d.conn.QueryRow(`
-		SELECT id, name, key_prefix, allowed_targets, allowed_models, description,
-		       enabled, created_at, revoked_at, last_used_at, last_used_target_id
+	row := d.read.QueryRow(`
+		SELECT `+proxyKeyColumns+`
 		FROM proxy_keys
 		WHERE key_hash = ? AND enabled = 1 AND revoked_at IS NULL
 		LIMIT 1`,
@@ -318,7 +366,7 @@ func (h *Handlers) ListProxyKeys(w http.ResponseWriter, r *http.Request) {
 func (h *Handlers) CreateProxyKey(w http.ResponseWriter, r *http.Request) {
 	var req createProxyKeyRequest
 	if err := readJSON(r, &req); err != nil {
-		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "invalid JSON"})
+		writeRequestBodyError(w, err)
 		return
 	}
 	req.Name = strings.TrimSpace(req.Name)
@@ -340,6 +388,30 @@ func (h *Handlers) CreateProxyKey(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	if detail := validateProxyKeyMaxConcurrent(req.MaxConcurrent); detail != "" {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": detail})
+		return
+	}
+	allowlist, err := normalizeProxyIPAllowlist(req.IPAllowlist)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": err.Error()})
+		return
+	}
+
+	chain, err := normalizeProxyFallbackChain(req.FallbackTargetIDs)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": err.Error()})
+		return
+	}
+	if detail := validateProxyKeyFallbackTimeout(req.FallbackTimeoutMs); detail != "" {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": detail})
+		return
+	}
+	if detail := h.validateProxyFallbackTargets(chain, req.AllowedTargetIDs); detail != "" {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": detail})
+		return
+	}
+
 	for _, id := range req.AllowedTargetIDs {
 		t, err := h.db.GetTarget(id)
 		if err != nil {
@@ -352,11 +424,19 @@ func (h *Handlers) CreateProxyKey(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	item, plainKey, err := h.db.CreateProxyKey(req.Name, req.AllowedTargetIDs, req.AllowedModels, req.Description)
+	item, plainKey, err := h.db.CreateProxyKey(req.Name, req.AllowedTargetIDs, req.AllowedModels, req.Description, req.MaxConcurrent, allowlist)
 	if err != nil {
 		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": err.Error()})
 		return
 	}
+	if len(chain) > 0 || req.FallbackTimeoutMs > 0 {
+		if err := h.db.UpdateProxyKeyFallback(item.ID, chain, req.FallbackTimeoutMs); err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+			return
+		}
+		item.FallbackTargetIDs, item.FallbackTimeoutMs = chain, req.FallbackTimeoutMs
+	}
+	h.audit(r, "proxy_key.create", "proxy_key", item.ID, auditDiff(nil, item, nil, nil))
 	writeJSON(w, http.StatusOK, map[string]any{
 		"item":      item,
 		"proxy_key": plainKey, // only returned once at creation
@@ -379,6 +459,7 @@ func (h *Handlers) RevokeProxyKey(w http.ResponseWriter, r *http.Request) {
 		writeJSON(w, http.StatusNotFound, map[string]any{"detail": "proxy key not found or already revoked"})
 		return
 	}
+	h.audit(r, "proxy_key.revoke", "proxy_key", id, nil)
 	writeJSON(w, http.StatusOK, map[string]any{"ok": true})
 }
 
@@ -498,6 +579,9 @@ type proxyResolvedModel struct {
 	RequestedModel string
 	Target         Target
 	UpstreamModel  string
+	// Fallbacks are the next hops of the key's fallback chain, each tried within HopTimeout.
+	Fallbacks  []Target
+	HopTimeout time.Duration
 }
 
 func (h *Handlers) resolveProxyModel(key *ProxyKey, requestedModel string, requestTargetID *int) (*proxyResolvedModel, error) {
@@ -558,6 +642,12 @@ func (h *Handlers) resolveProxyModel(key *ProxyKey, requestedModel string, reque
 		}
 		return nil, fmt.Errorf("model not found or not successful in latest run: %s", requestedModel)
 	}
+	chained := false
+	if requestTargetID == nil {
+		if hops := proxyFallbackCandidates(key, candidates, channelName); hops != nil {
+			channelCandidates, chained = hops, true
+		}
+	}
 
 	ids := make([]int, 0, len(channelCandidates))
 	for _, c := range channelCandidates {
@@ -567,18 +657,89 @@ func (h *Handlers) resolveProxyModel(key *ProxyKey, requestedModel string, reque
 	if err != nil {
 		return nil, err
 	}
+	eligible := make([]Target, 0, len(channelCandidates))
 	for _, c := range channelCandidates {
 		for _, ms := range statusByTarget[c.ID] {
 			if ms.Success && ms.Model == dbModel {
-				return &proxyResolvedModel{
-					RequestedModel: requestedModel,
-					Target:         c,
-					UpstreamModel:  dbModel,
-				}, nil
+				eligible = append(eligible, c)
+				break
 			}
 		}
 	}
-	return nil, fmt.Errorf("model not found or not successful in latest run: %s", requestedModel)
+	if len(eligible) == 0 {
+		return nil, fmt.Errorf("model not found or not successful in latest run: %s", requestedModel)
+	}
+	if chained {
+		return &proxyResolvedModel{
+			RequestedModel: requestedModel,
+			Target:         eligible[0],
+			UpstreamModel:  dbModel,
+			Fallbacks:      eligible[1:],
+			HopTimeout:     proxyFallbackTimeout(key),
+		}, nil
+	}
+	if len(eligible) > 1 {
+		eligibleIDs := make([]int, 0, len(eligible))
+		for _, c := range eligible {
+			eligibleIDs = append(eligibleIDs, c.ID)
+		}
+		histories, err := h.db.GetModelHistoriesBatch(eligibleIDs, proxyHealthWindow)
+		if err != nil {
+			return nil, err
+		}
+		rankProxyCandidates(eligible, histories, dbModel)
+	}
+	return &proxyResolvedModel{
+		RequestedModel: requestedModel,
+		Target:         eligible[0],
+		UpstreamModel:  dbModel,
+	}, nil
+}
+
+// proxyHealthWindow is how many recent checks of a model score a target for routing.
+const proxyHealthWindow = 20
+
+// proxyHealth scores a model's recent checks: the success rate, and the mean duration of
+// the successful ones (+Inf when none succeeded).
+func proxyHealth(points []ModelHistoryPoint) (float64, float64) {
+	if len(points) == 0 {
+		return 0, math.Inf(1)
+	}
+	success, timed := 0, 0
+	var total float64
+	for _, p := range points {
+		if !p.Success {
+			continue
+		}
+		success++
+		if p.Duration != nil {
+			timed++
+			total += *p.Duration
+		}
+	}
+	latency := math.Inf(1)
+	if timed > 0 {
+		latency = total / float64(timed)
+	}
+	return float64(success) / float64(len(points)), latency
+}
+
+// rankProxyCandidates orders targets serving model by recent health: best success rate
+// first, then lowest latency; ties keep the target order.
+func rankProxyCandidates(targets []Target, histories map[int]map[string][]ModelHistoryPoint, model string) {
+	type score struct{ rate, latency float64 }
+	scores := make(map[int]score, len(targets))
+	for _, t := range targets {
+		rate, latency := proxyHealth(histories[t.ID][model])
+		scores[t.ID] = score{rate, latency}
+	}
+	sort.SliceStable(targets, func(i, j int) bool {
+		a, b := scores[targets[i].ID], scores[targets[j].ID]
+		if a.rate != b.rate {
+			return a.rate > b.rate
+		}
+		return a.latency < b.latency
+	})
 }
 
 func hopByHopHeader(name string) bool {
@@ -665,14 +826,18 @@ func rewriteBodyModel(body []byte, upstreamModel string) ([]byte, error) {
 	return out, nil
 }
 
-func (h *Handlers) authenticateProxyRequest(r *http.Request) (*ProxyKey, error) {
+// proxyKeyAuthenticator accepts the proxy master token or an active proxy key.
+type proxyKeyAuthenticator struct {
+	db *Database
+}
+
+func (a proxyKeyAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
 	token, err := parseProxyBearerToken(r)
 	if err != nil {
 		return nil, err
 	}
 
-	key := &ProxyKey{ID: 0, AllowedTargetIDs: []int{}, AllowedModels: []string{}}
-	masterToken, found, err := h.db.GetSetting(settingProxyMasterToken)
+	masterToken, found, err := a.db.GetSetting(settingProxyMasterToken)
 	if err != nil {
 		return nil, err
 	}
@@ -680,24 +845,38 @@ func (h *Handlers) authenticateProxyRequest(r *http.Request) (*ProxyKey, error)
 		t1 := []byte(strings.TrimSpace(masterToken))
 		t2 := []byte(strings.TrimSpace(token))
 		if len(t1) == len(t2) && subtle.ConstantTimeCompare(t1, t2) == 1 {
-			return key, nil
+			key := &ProxyKey{ID: 0, AllowedTargetIDs: []int{}, AllowedModels: []string{}}
+			return &Principal{Role: authRoleProxy, Method: "proxy_master", ProxyKey: key}, nil
 		}
 	}
 
-	key, err = h.db.GetActiveProxyKeyByToken(token)
+	key, err := a.db.GetActiveProxyKeyByToken(token)
 	if err != nil {
 		return nil, err
 	}
 	if key == nil {
 		return nil, errProxyInvalidKey
 	}
-	return key, nil
+	return &Principal{Role: authRoleProxy, Method: "proxy_key", ProxyKey: key}, nil
+}
+
+func (h *Handlers) authenticateProxyRequest(r *http.Request) (*ProxyKey, error) {
+	p, err := proxyKeyAuthenticator{db: h.db}.Authenticate(r)
+	if err != nil {
+		return nil, err
+	}
+	if !proxyKeyIPAllowed(p.ProxyKey, clientIPFromRequest(r)) {
+		return nil, errProxyIPNotAllowed
+	}
+	return p.ProxyKey, nil
 }
 
 func writeProxyAuthError(w http.ResponseWriter, err error) {
 	switch err {
 	case errProxyInvalidAuthHeader, errProxyInvalidKey:
 		writeJSON(w, http.StatusUnauthorized, map[string]any{"detail": err.Error()})
+	case errProxyIPNotAllowed:
+		writeJSON(w, http.StatusForbidden, map[string]any{"detail": err.Error()})
 	default:
 		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
 	}
@@ -782,23 +961,58 @@ func (h *Handlers) ProxyModels(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handlers) handleProxyRequest(w http.ResponseWriter, r *http.Request, forcedModel string) {
+	ctx, span := startSpanKind(extractTraceContext(r.Context(), r), "proxy.request", spanKindServer,
+		"http.method", r.Method, "http.route", r.URL.Path)
+	var spanErr error
+	defer func() { span.End(spanErr) }()
+
 	key, err := h.authenticateProxyRequest(r)
 	if err != nil {
 		writeProxyAuthError(w, err)
 		return
 	}
+	span.SetAttrs("proxy_key.id", key.ID)
+	started := time.Now()
+	sw := &proxyStatusWriter{ResponseWriter: w}
+	w = sw
+	activity := proxyActivity{KeyID: key.ID, KeyName: key.Name}
+	upstreamCalled := false
+	audit := proxyAuditActive.Load()
+	if audit.capture() {
+		sw.capture = &cappedBuffer{max: audit.maxBytes}
+	}
+	var auditBody []byte
+	defer func() {
+		if !upstreamCalled {
+			activity.StatusCode = sw.status
+		}
+		activity.DurationMs = time.Since(started).Milliseconds()
+		activity.Usage = sw.usage.result()
+		activity.At = float64(time.Now().UnixMilli()) / 1000.0
+		h.recordProxyActivity(activity)
+		h.recordProxyAudit(audit, activity, r.URL.Path, auditBody, sw)
+	}()
+	release, ok := acquireProxyKeySlot(key)
+	if !ok {
+		w.Header().Set("Retry-After", "1")
+		writeJSON(w, http.StatusTooManyRequests, map[string]any{"detail": fmt.Sprintf("proxy key concurrent request limit (%d) reached", key.MaxConcurrent)})
+		return
+	}
+	defer release()
 
 	body, err := io.ReadAll(io.LimitReader(r.Body, proxyBodyMaxBytes))
 	if err != nil {
 		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "failed to read request body"})
 		return
 	}
+	auditBody = body
 
 	model := strings.TrimSpace(forcedModel)
 	if model == "" {
 		model = extractModelFromPayload(body)
 	}
 	model = strings.TrimSpace(model)
+	activity.Model = model
 	if model == "" {
 		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": errProxyMissingModel.Error()})
 		return
@@ -832,9 +1046,12 @@ func (h *Handlers) handleProxyRequest(w http.ResponseWriter, r *http.Request, fo
 				status = http.StatusBadRequest
 			}
 		}
+		span.SetAttrs("http.status_code", status)
 		writeJSON(w, status, map[string]any{"detail": err.Error()})
 		return
 	}
+	span.SetAttrs("model", model, "target.id", resolved.Target.ID, "upstream.model", resolved.UpstreamModel)
+	activity.TargetID = resolved.Target.ID
 
 	upstreamPath := r.URL.Path
 	upstreamBody := body
@@ -853,18 +1070,111 @@ func (h *Handlers) handleProxyRequest(w http.ResponseWriter, r *http.Request, fo
 		}
 		upstreamBody = rewrittenBody
 	}
+	hops := append([]Target{resolved.Target}, resolved.Fallbacks...)
+	chained := len(resolved.Fallbacks) > 0 || resolved.HopTimeout > 0
+	trail := make([]string, 0, len(hops))
+	var (
+		target Target
+		upResp *http.Response
+	)
+	for i := range hops {
+		target = hops[i]
+		last := i == len(hops)-1
+		activity.TargetID = target.ID
+		hopBody, err := applyProxyTransform(upstreamBody, target.ProxyTransform)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"detail": err.Error()})
+			return
+		}
+		base := strings.TrimRight(normalizeBaseURL(target.BaseURL), "/")
+		hopCtx, cancelHop := context.WithCancel(ctx)
+		defer cancelHop()
+		upCtx, upSpan := startSpanKind(hopCtx, "proxy.upstream", spanKindClient, "target.id", target.ID, "http.url", base+upstreamPath)
+		upReq, hopKey, err := h.newProxyUpstreamRequest(upCtx, r, &target, base+upstreamPath, hopBody)
+		if err != nil {
+			upSpan.End(err)
+			writeJSON(w, http.StatusBadGateway, map[string]any{"detail": err.Error()})
+			return
+		}
+		client, err := targetHTTPClient(&target)
+		if err != nil {
+			upSpan.End(err)
+			writeJSON(w, http.StatusBadGateway, map[string]any{"detail": err.Error()})
+			return
+		}
+		var timer *time.Timer
+		if resolved.HopTimeout > 0 {
+			timer = time.AfterFunc(resolved.HopTimeout, cancelHop)
+		}
+		resp, err := client.Do(upReq)
+		if timer != nil && !timer.Stop() {
+			if resp != nil {
+				resp.Body.Close()
+			}
+			err = fmt.Errorf("upstream did not respond within %dms", resolved.HopTimeout.Milliseconds())
+			trail = append(trail, fmt.Sprintf("%d=timeout", target.ID))
+		} else if err != nil {
+			trail = append(trail, fmt.Sprintf("%d=error", target.ID))
+		}
+		if err != nil {
+			upSpan.End(err)
+			if !last {
+				continue
+			}
+			upstreamCalled = true
+			spanErr = err
+			activity.Error = err.Error()
+			if chained {
+				w.Header().Set("X-Proxy-Fallback-Chain", strings.Join(trail, ", "))
+			}
+			writeJSON(w, http.StatusBadGateway, map[string]any{"detail": err.Error()})
+			return
+		}
+		h.recordProxyKeyOutcome(&target, hopKey, resp.StatusCode)
+		trail = append(trail, fmt.Sprintf("%d=%d", target.ID, resp.StatusCode))
+		upSpan.SetAttrs("http.status_code", resp.StatusCode)
+		if !last && proxyHopRetryable(resp.StatusCode) {
+			resp.Body.Close()
+			upSpan.End(nil)
+			continue
+		}
+		defer func() { upSpan.End(spanErr) }()
+		upResp = resp
+		break
+	}
+	upstreamCalled = true
+	defer upResp.Body.Close()
+	activity.StatusCode = upResp.StatusCode
+	span.SetAttrs("http.status_code", upResp.StatusCode)
+
+	if key.ID > 0 {
+		_ = h.db.TouchProxyKeyUsage(key.ID, target.ID)
+	}
+
+	copyProxyResponseHeaders(w.Header(), upResp.Header)
+	w.Header().Set("X-Proxy-Target-Id", strconv.Itoa(target.ID))
+	w.Header().Set("X-Proxy-Upstream-Model", resolved.UpstreamModel)
+	if chained {
+		w.Header().Set("X-Proxy-Fallback-Chain", strings.Join(trail, ", "))
+	}
+	w.WriteHeader(upResp.StatusCode)
+	sw.usage = newProxyUsageMeter(upResp.Header)
+	if _, err := io.Copy(w, upResp.Body); err != nil {
+		spanErr = err
+		activity.Error = err.Error()
+		h.logger("proxy").Warn("copy response failed", "target_id", target.ID, "error", err)
+	}
+}
 
-	target := resolved.Target
-	base := strings.TrimRight(normalizeBaseURL(target.BaseURL), "/")
-	upstreamURL := base + upstreamPath
+// newProxyUpstreamRequest builds the upstream request of a proxy hop to target, returning
+// the upstream API key it authenticates with.
+func (h *Handlers) newProxyUpstreamRequest(ctx context.Context, r *http.Request, target *Target, upstreamURL string, body []byte) (*http.Request, string, error) {
 	if r.URL.RawQuery != "" {
 		upstreamURL += "?" + r.URL.RawQuery
 	}
-
-	upReq, err := http.NewRequestWithContext(r.Context(), http.MethodPost, upstreamURL, bytes.NewReader(upstreamBody))
+	upReq, err := http.NewRequestWithContext(ctx, http.MethodPost, upstreamURL, bytes.NewReader(body))
 	if err != nil {
-		writeJSON(w, http.StatusBadGateway, map[string]any{"detail": "failed to create upstream request"})
-		return
+		return nil, "", fmt.Errorf("failed to create upstream request")
 	}
 
 	copyRequestHeaderIfPresent(upReq.Header, r.Header, "Content-Type")
@@ -873,36 +1183,24 @@ func (h *Handlers) handleProxyRequest(w http.ResponseWriter, r *http.Request, fo
 	copyRequestHeaderIfPresent(upReq.Header, r.Header, "OpenAI-Beta")
 	copyRequestHeaderIfPresent(upReq.Header, r.Header, "Anthropic-Version")
 	copyRequestHeaderIfPresent(upReq.Header, r.Header, "X-Goog-User-Project")
-	upReq.Header.Set("Authorization", "Bearer "+target.APIKey)
+	copyRequestHeaderIfPresent(upReq.Header, r.Header, "Traceparent")
+	copyRequestHeaderIfPresent(upReq.Header, r.Header, "Tracestate")
+	injectTraceContext(ctx, upReq.Header)
+	apiKey := h.proxyAPIKey(target)
+	upReq.Header.Set("Authorization", "Bearer "+apiKey)
 	if r.URL.Path == "/v1/messages" && strings.TrimSpace(upReq.Header.Get("Anthropic-Version")) == "" {
 		upReq.Header.Set("Anthropic-Version", target.AnthropicVersion)
 	}
 	if r.URL.Path == "/v1/messages" {
-		upReq.Header.Set("X-Api-Key", target.APIKey)
+		upReq.Header.Set("X-Api-Key", apiKey)
 	}
 	if strings.HasPrefix(r.URL.Path, "/v1beta/models/") {
-		upReq.Header.Set("X-Goog-Api-Key", target.APIKey)
-	}
-
-	client := httpClient(target.TimeoutS, target.VerifySSL)
-	upResp, err := client.Do(upReq)
-	if err != nil {
-		writeJSON(w, http.StatusBadGateway, map[string]any{"detail": err.Error()})
-		return
-	}
-	defer upResp.Body.Close()
-
-	if key.ID > 0 {
-		_ = h.db.TouchProxyKeyUsage(key.ID, target.ID)
+		upReq.Header.Set("X-Goog-Api-Key", apiKey)
 	}
-
-	copyProxyResponseHeaders(w.Header(), upResp.Header)
-	w.Header().Set("X-Proxy-Target-Id", strconv.Itoa(target.ID))
-	w.Header().Set("X-Proxy-Upstream-Model", resolved.UpstreamModel)
-	w.WriteHeader(upResp.StatusCode)
-	if _, err := io.Copy(w, upResp.Body); err != nil {
-		log.Printf("[proxy] copy response failed: %v", err)
+	for k, v := range target.ExtraHeaders {
+		upReq.Header.Set(k, v)
 	}
+	return upReq, apiKey, nil
 }
 
 // ProxyChatCompletions handles POST /v1/chat/completions
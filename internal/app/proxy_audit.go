@@ -0,0 +1,332 @@
+package app
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Proxy body audit, for debugging complaints about garbled outputs. PROXY_AUDIT=sample
+// keeps the request and response bodies of PROXY_AUDIT_SAMPLE_RATE percent (default 1)
+// of proxied requests, PROXY_AUDIT=errors keeps those of every failed request (status
+// 0 or >= 400). Each body is capped at PROXY_AUDIT_MAX_BYTES and sealed like secrets at
+// rest, so API_MONITOR_ENCRYPTION_KEY is required. Entries are kept for
+// PROXY_AUDIT_RETENTION_DAYS (default 7) and read back by admins through
+// /api/proxy/audit. Response bodies are stored as sent, so they may be compressed (see
+// response_encoding).
+
+const (
+	defaultProxyAuditMaxBytes = 64 << 10
+	maxProxyAuditMaxBytes     = 1 << 20
+	maxProxyAuditListLimit    = 200
+)
+
+type proxyAuditConfig struct {
+	errorsOnly bool
+	// sampleRate is the kept fraction of requests in sample mode.
+	sampleRate float64
+	maxBytes   int
+	retention  time.Duration
+}
+
+var proxyAuditActive atomic.Pointer[proxyAuditConfig]
+
+// proxyAuditFromEnv reads the PROXY_AUDIT settings, returning nil when the audit is off.
+func proxyAuditFromEnv() (*proxyAuditConfig, error) {
+	mode := strings.ToLower(strings.TrimSpace(os.Getenv("PROXY_AUDIT")))
+	c := &proxyAuditConfig{sampleRate: 0.01, maxBytes: defaultProxyAuditMaxBytes, retention: 7 * 24 * time.Hour}
+	switch mode {
+	case "", "off":
+		return nil, nil
+	case "sample":
+	case "errors":
+		c.errorsOnly = true
+	default:
+		return nil, fmt.Errorf("PROXY_AUDIT must be off, sample or errors, got %q", mode)
+	}
+	if raw := strings.TrimSpace(os.Getenv("PROXY_AUDIT_SAMPLE_RATE")); raw != "" {
+		pct, err := strconv.ParseFloat(raw, 64)
+		if err != nil || pct <= 0 || pct > 100 {
+			return nil, fmt.Errorf("PROXY_AUDIT_SAMPLE_RATE must be a percentage in (0, 100], got %q", raw)
+		}
+		c.sampleRate = pct / 100
+	}
+	if raw := strings.TrimSpace(os.Getenv("PROXY_AUDIT_MAX_BYTES")); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxProxyAuditMaxBytes {
+			return nil, fmt.Errorf("PROXY_AUDIT_MAX_BYTES must be between 1 and %d, got %q", maxProxyAuditMaxBytes, raw)
+		}
+		c.maxBytes = n
+	}
+	if raw := strings.TrimSpace(os.Getenv("PROXY_AUDIT_RETENTION_DAYS")); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > 365 {
+			return nil, fmt.Errorf("PROXY_AUDIT_RETENTION_DAYS must be between 1 and 365, got %q", raw)
+		}
+		c.retention = time.Duration(n) * 24 * time.Hour
+	}
+	if !encryptionEnabled() {
+		return nil, fmt.Errorf("PROXY_AUDIT stores request bodies encrypted and requires API_MONITOR_ENCRYPTION_KEY")
+	}
+	return c, nil
+}
+
+// startProxyAudit installs the proxy audit configured by the environment. It is a no-op
+// when PROXY_AUDIT is unset.
+func startProxyAudit(logger *slog.Logger) error {
+	c, err := proxyAuditFromEnv()
+	if err != nil || c == nil {
+		return err
+	}
+	proxyAuditActive.Store(c)
+	componentLogger(logger, "proxy").Info("proxy body audit enabled",
+		"errors_only", c.errorsOnly, "sample_rate", c.sampleRate, "max_bytes", c.maxBytes)
+	return nil
+}
+
+// capture reports whether to buffer the bodies of a new request. Error-only audits
+// capture every request, since the outcome is not known yet.
+func (c *proxyAuditConfig) capture() bool {
+	return c != nil && (c.errorsOnly || rand.Float64() < c.sampleRate)
+}
+
+// keep reports whether a captured request with status is persisted.
+func (c *proxyAuditConfig) keep(status int) bool {
+	return !c.errorsOnly || status == 0 || status >= 400
+}
+
+// cappedBuffer keeps the first max bytes written to it.
+type cappedBuffer struct {
+	buf       []byte
+	max       int
+	truncated bool
+}
+
+func (b *cappedBuffer) Write(p []byte) {
+	if room := b.max - len(b.buf); room < len(p) {
+		b.truncated = true
+		p = p[:max(room, 0)]
+	}
+	b.buf = append(b.buf, p...)
+}
+
+// ProxyAuditEntry is an audited proxy request. The bodies are only filled by GetProxyAudit.
+type ProxyAuditEntry struct {
+	ID                int     `json:"id"`
+	KeyID             int     `json:"key_id"`
+	TargetID          int     `json:"target_id,omitempty"`
+	Model             string  `json:"model"`
+	Path              string  `json:"path"`
+	StatusCode        int     `json:"status_code"`
+	DurationMs        int64   `json:"duration_ms"`
+	Error             string  `json:"error,omitempty"`
+	RequestBody       *string `json:"request_body,omitempty"`
+	ResponseBody      *string `json:"response_body,omitempty"`
+	RequestTruncated  bool    `json:"request_truncated"`
+	ResponseTruncated bool    `json:"response_truncated"`
+	ResponseEncoding  string  `json:"response_encoding,omitempty"`
+	CreatedAt         float64 `json:"created_at"`
+}
+
+// EnsureProxyAuditSchema creates the proxy body audit table.
+func (d *Database) EnsureProxyAuditSchema() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	_, err := d.conn.Exec(`
+		CREATE TABLE IF NOT EXISTS proxy_audit (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			key_id INTEGER NOT NULL,
+			target_id INTEGER NOT NULL DEFAULT 0,
+			model TEXT NOT NULL DEFAULT '',
+			path TEXT NOT NULL DEFAULT '',
+			status_code INTEGER NOT NULL DEFAULT 0,
+			duration_ms INTEGER NOT NULL DEFAULT 0,
+			error TEXT NOT NULL DEFAULT '',
+			request_body TEXT NOT NULL DEFAULT '',
+			response_body TEXT NOT NULL DEFAULT '',
+			request_truncated INTEGER NOT NULL DEFAULT 0,
+			response_truncated INTEGER NOT NULL DEFAULT 0,
+			response_encoding TEXT NOT NULL DEFAULT '',
+			created_at REAL NOT NULL
+		);
+		CREATE INDEX IF NOT EXISTS idx_proxy_audit_time ON proxy_audit(created_at);
+	`)
+	return err
+}
+
+// InsertProxyAudit stores an audit entry, encrypting request and response bodies.
+func (d *Database) InsertProxyAudit(e ProxyAuditEntry, requestBody, responseBody []byte) error {
+	reqSealed, err := encryptSecret(string(requestBody))
+	if err != nil {
+		return err
+	}
+	respSealed, err := encryptSecret(string(responseBody))
+	if err != nil {
+		return err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	_, err = d.conn.Exec(`
+		INSERT INTO proxy_audit (key_id, target_id, model, path, status_code, duration_ms, error,
+			request_body, response_body, request_truncated, response_truncated, response_encoding, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		e.KeyID, e.TargetID, e.Model, e.Path, e.StatusCode, e.DurationMs, e.Error,
+		reqSealed, respSealed, boolToInt(e.RequestTruncated), boolToInt(e.ResponseTruncated), e.ResponseEncoding, e.CreatedAt)
+	return err
+}
+
+const proxyAuditColumns = `id, key_id, target_id, model, path, status_code, duration_ms, error,
+	request_truncated, response_truncated, response_encoding, created_at`
+
+func scanProxyAudit(r interface{ Scan(dest ...any) error }, extra ...any) (*ProxyAuditEntry, error) {
+	var e ProxyAuditEntry
+	var reqTrunc, respTrunc int
+	dest := []any{&e.ID, &e.KeyID, &e.TargetID, &e.Model, &e.Path, &e.StatusCode, &e.DurationMs, &e.Error,
+		&reqTrunc, &respTrunc, &e.ResponseEncoding, &e.CreatedAt}
+	if err := r.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	e.RequestTruncated, e.ResponseTruncated = reqTrunc != 0, respTrunc != 0
+	return &e, nil
+}
+
+// ListProxyAudit lists audit entries newest first, without bodies. keyID 0 lists every
+// key; errorsOnly keeps failed requests.
+func (d *Database) ListProxyAudit(keyID int, errorsOnly bool, limit int) ([]ProxyAuditEntry, error) {
+	query := `SELECT ` + proxyAuditColumns + ` FROM proxy_audit WHERE 1 = 1`
+	var args []any
+	if keyID > 0 {
+		query += ` AND key_id = ?`
+		args = append(args, keyID)
+	}
+	if errorsOnly {
+		query += ` AND ` + proxyRequestErrorExpr
+	}
+	query += ` ORDER BY id DESC LIMIT ?`
+	args = append(args, limit)
+	rows, err := d.read.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ProxyAuditEntry{}
+	for rows.Next() {
+		e, err := scanProxyAudit(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, *e)
+	}
+	return items, rows.Err()
+}
+
+// GetProxyAudit returns an audit entry with its decrypted bodies, or nil when missing.
+func (d *Database) GetProxyAudit(id int) (*ProxyAuditEntry, error) {
+	var reqSealed, respSealed string
+	row := d.read.QueryRow(`SELECT `+proxyAuditColumns+`, request_body, response_body FROM proxy_audit WHERE id = ?`, id)
+	e, err := scanProxyAudit(row, &reqSealed, &respSealed)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	reqBody, err := decryptSecret(reqSealed)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := decryptSecret(respSealed)
+	if err != nil {
+		return nil, err
+	}
+	e.RequestBody, e.ResponseBody = &reqBody, &respBody
+	return e, nil
+}
+
+// PruneProxyAudit deletes audit entries recorded before cutoff.
+func (d *Database) PruneProxyAudit(cutoff time.Time) (int64, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	res, err := d.conn.Exec(`DELETE FROM proxy_audit WHERE created_at < ?`, float64(cutoff.UnixMilli())/1000.0)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// recordProxyAudit persists the captured bodies of a finished proxy request when the
+// audit keeps it.
+func (h *Handlers) recordProxyAudit(c *proxyAuditConfig, a proxyActivity, path string, requestBody []byte, sw *proxyStatusWriter) {
+	if c == nil || sw.capture == nil || !c.keep(a.StatusCode) {
+		return
+	}
+	reqBody := requestBody
+	reqTruncated := len(reqBody) > c.maxBytes
+	if reqTruncated {
+		reqBody = reqBody[:c.maxBytes]
+	}
+	e := ProxyAuditEntry{
+		KeyID: a.KeyID, TargetID: a.TargetID, Model: a.Model, Path: path,
+		StatusCode: a.StatusCode, DurationMs: a.DurationMs, Error: a.Error,
+		RequestTruncated: reqTruncated, ResponseTruncated: sw.capture.truncated,
+		ResponseEncoding: sw.Header().Get("Content-Encoding"), CreatedAt: a.At,
+	}
+	if err := h.db.InsertProxyAudit(e, reqBody, sw.capture.buf); err != nil {
+		h.logger("proxy").Warn("record proxy audit failed", "key_id", a.KeyID, "error", err)
+	}
+}
+
+// ListProxyAudit handles GET /api/proxy/audit?key_id=&errors=true&limit=50
+func (h *Handlers) ListProxyAudit(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	keyID := 0
+	if raw := q.Get("key_id"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "key_id must be a positive integer"})
+			return
+		}
+		keyID = n
+	}
+	limit := 50
+	if raw := q.Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxProxyAuditListLimit {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"detail": fmt.Sprintf("limit must be between 1 and %d", maxProxyAuditListLimit)})
+			return
+		}
+		limit = n
+	}
+	items, err := h.db.ListProxyAudit(keyID, q.Get("errors") == "true", limit)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"items": items})
+}
+
+// GetProxyAudit handles GET /api/proxy/audit/{id}
+func (h *Handlers) GetProxyAudit(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(r)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "invalid id"})
+		return
+	}
+	item, err := h.db.GetProxyAudit(id)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return
+	}
+	if item == nil {
+		writeJSON(w, http.StatusNotFound, map[string]any{"detail": "proxy audit entry not found"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"item": item})
+}
@@ -0,0 +1,94 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestProxyAuditKeepsEncryptedErrorBodies(t *testing.T) {
+	withEncryptionKey(t, "audit test key")
+	t.Setenv("PROXY_AUDIT", "errors")
+	t.Setenv("PROXY_AUDIT_MAX_BYTES", "16")
+	c, err := proxyAuditFromEnv()
+	if err != nil || c == nil {
+		t.Fatalf("proxyAuditFromEnv failed: %v", err)
+	}
+	proxyAuditActive.Store(c)
+	t.Cleanup(func() { proxyAuditActive.Store(nil) })
+
+	db, _ := newAnalyticsTestDB(t)
+	if err := db.EnsureProxySchema(); err != nil {
+		t.Fatalf("EnsureProxySchema failed: %v", err)
+	}
+	if err := db.EnsureProxyAuditSchema(); err != nil {
+		t.Fatalf("EnsureProxyAuditSchema failed: %v", err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"choices":[]}`))
+	}))
+	defer srv.Close()
+	target, err := db.CreateTarget(map[string]any{"name": "relay", "base_url": srv.URL, "api_key": "k"})
+	if err != nil {
+		t.Fatalf("CreateTarget failed: %v", err)
+	}
+	insertAnalyticsRows(t, db, target.ID, []DetectionResult{{Protocol: "openai", Model: "m", Success: true, Duration: 1, Timestamp: 1_700_000_000}})
+	key, token, err := db.CreateProxyKey("ci", nil, nil, "", 0, nil)
+	if err != nil {
+		t.Fatalf("CreateProxyKey failed: %v", err)
+	}
+
+	h := &Handlers{db: db}
+	for _, body := range []string{`{"model":"relay/m","messages":[]}`, `{"model":"missing/m","messages":["garbled"]}`} {
+		req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewBufferString(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		h.ProxyChatCompletions(httptest.NewRecorder(), req)
+	}
+
+	items, err := db.ListProxyAudit(key.ID, false, 10)
+	if err != nil || len(items) != 1 {
+		t.Fatalf("only the failed request should be audited, got=%+v err=%v", items, err)
+	}
+	if items[0].StatusCode < 400 || items[0].RequestBody != nil || !items[0].RequestTruncated || !items[0].ResponseTruncated {
+		t.Fatalf("unexpected list entry: %+v", items[0])
+	}
+	var sealed string
+	if err := db.conn.QueryRow(`SELECT request_body FROM proxy_audit`).Scan(&sealed); err != nil || !isEncryptedSecret(sealed) {
+		t.Fatalf("request body should be encrypted at rest, got %q err=%v", sealed, err)
+	}
+
+	id := strconv.Itoa(items[0].ID)
+	req := httptest.NewRequest(http.MethodGet, "/api/proxy/audit/"+id, nil)
+	req.SetPathValue("id", id)
+	rr := httptest.NewRecorder()
+	h.GetProxyAudit(rr, withAuthRole(req, authRoleAdmin))
+	var resp struct {
+		Item ProxyAuditEntry `json:"item"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil || rr.Code != http.StatusOK {
+		t.Fatalf("GetProxyAudit failed: %d %s", rr.Code, rr.Body.String())
+	}
+	if resp.Item.RequestBody == nil || *resp.Item.RequestBody != `{"model":"missin` || resp.Item.ResponseBody == nil || !strings.HasPrefix(`{"detail":"model not found`, *resp.Item.ResponseBody) {
+		t.Fatalf("expected capped decrypted bodies, got %+v", resp.Item)
+	}
+}
+
+func TestProxyAuditRequiresEncryptionKey(t *testing.T) {
+	t.Setenv("PROXY_AUDIT", "sample")
+	if _, err := proxyAuditFromEnv(); err == nil {
+		t.Fatalf("PROXY_AUDIT without an encryption key should be rejected")
+	}
+	withEncryptionKey(t, "audit test key")
+	for _, env := range [][2]string{{"PROXY_AUDIT", "all"}, {"PROXY_AUDIT_SAMPLE_RATE", "0"}, {"PROXY_AUDIT_MAX_BYTES", "0"}} {
+		t.Setenv("PROXY_AUDIT", "sample")
+		t.Setenv(env[0], env[1])
+		if _, err := proxyAuditFromEnv(); err == nil {
+			t.Fatalf("%s=%s should be rejected", env[0], env[1])
+		}
+		t.Setenv(env[0], "")
+	}
+}
@@ -0,0 +1,110 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// A proxy key's fallback chain lists target ids to try in order (e.g. [3, 7, 1]) when the
+// requested channel is one of them, replacing the health ranking. Each hop is a chain
+// target whose latest run succeeded for the model; the next hop is tried when a hop
+// fails to connect, misses fallback_timeout_ms while waiting for response headers, or
+// answers 429 or 5xx. X-Proxy-Fallback-Chain records each attempted hop (e.g.
+// "3=502, 7=timeout, 1=200").
+
+const (
+	maxProxyFallbackHops      = 10
+	maxProxyFallbackTimeoutMs = 600000
+)
+
+// normalizeProxyFallbackChain keeps the order of the chain, dropping repeats.
+func normalizeProxyFallbackChain(ids []int) ([]int, error) {
+	out := make([]int, 0, len(ids))
+	seen := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		if id <= 0 {
+			return nil, fmt.Errorf("fallback_target_ids must be positive target ids")
+		}
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		out = append(out, id)
+	}
+	if len(out) > maxProxyFallbackHops {
+		return nil, fmt.Errorf("fallback_target_ids must contain <= %d targets", maxProxyFallbackHops)
+	}
+	return out, nil
+}
+
+func validateProxyKeyFallbackTimeout(ms int) string {
+	if ms < 0 || ms > maxProxyFallbackTimeoutMs {
+		return fmt.Sprintf("fallback_timeout_ms must be between 0 and %d", maxProxyFallbackTimeoutMs)
+	}
+	return ""
+}
+
+// validateProxyFallbackTargets checks that the chain targets exist and are allowed by the key.
+func (h *Handlers) validateProxyFallbackTargets(chain, allowed []int) string {
+	for _, id := range chain {
+		t, err := h.db.GetTarget(id)
+		if err != nil {
+			return err.Error()
+		}
+		if t == nil {
+			return fmt.Sprintf("fallback target id %d not found", id)
+		}
+		if len(allowed) > 0 && !slices.Contains(allowed, id) {
+			return fmt.Sprintf("fallback target id %d is not in allowed_target_ids", id)
+		}
+	}
+	return ""
+}
+
+// UpdateProxyKeyFallback sets a key's fallback chain and per-hop timeout.
+func (d *Database) UpdateProxyKeyFallback(id int, chain []int, timeoutMs int) error {
+	if chain == nil {
+		chain = []int{}
+	}
+	raw, _ := json.Marshal(chain)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	_, err := d.conn.Exec(`UPDATE proxy_keys SET fallback_target_ids = ?, fallback_timeout_ms = ? WHERE id = ?`, string(raw), timeoutMs, id)
+	return err
+}
+
+// proxyFallbackCandidates returns the candidates of key's fallback chain in chain order
+// when the chain includes a target of channel; nil otherwise.
+func proxyFallbackCandidates(key *ProxyKey, candidates []Target, channel string) []Target {
+	if len(key.FallbackTargetIDs) == 0 {
+		return nil
+	}
+	byID := make(map[int]Target, len(candidates))
+	for _, c := range candidates {
+		byID[c.ID] = c
+	}
+	out := make([]Target, 0, len(key.FallbackTargetIDs))
+	inChannel := false
+	for _, id := range key.FallbackTargetIDs {
+		if c, ok := byID[id]; ok {
+			out = append(out, c)
+			inChannel = inChannel || c.Name == channel
+		}
+	}
+	if !inChannel {
+		return nil
+	}
+	return out
+}
+
+// proxyHopRetryable reports whether a hop's response status moves on to the next hop.
+func proxyHopRetryable(status int) bool {
+	return status == 429 || status >= 500
+}
+
+// proxyFallbackTimeout is the per-hop wait for response headers; 0 waits for the client timeout.
+func proxyFallbackTimeout(key *ProxyKey) time.Duration {
+	return time.Duration(key.FallbackTimeoutMs) * time.Millisecond
+}
@@ -0,0 +1,92 @@
+package app
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestProxyFallbackChainTriesHopsInOrder(t *testing.T) {
+	db, _ := newAnalyticsTestDB(t)
+	if err := db.EnsureProxySchema(); err != nil {
+		t.Fatalf("EnsureProxySchema failed: %v", err)
+	}
+	hang := make(chan struct{})
+	upstream := func(status int, slow bool) string {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if slow {
+				<-hang
+				return
+			}
+			w.WriteHeader(status)
+			_, _ = w.Write([]byte(`{"ok":true}`))
+		}))
+		t.Cleanup(srv.Close)
+		return srv.URL
+	}
+	var ids []int
+	for _, u := range []struct {
+		name string
+		url  string
+	}{
+		{"relay-a", upstream(http.StatusServiceUnavailable, false)},
+		{"relay-b", upstream(http.StatusOK, true)},
+		{"relay-c", upstream(http.StatusOK, false)},
+		{"relay-d", upstream(http.StatusOK, false)},
+	} {
+		target, err := db.CreateTarget(map[string]any{"name": u.name, "base_url": u.url, "api_key": "k"})
+		if err != nil {
+			t.Fatalf("CreateTarget failed: %v", err)
+		}
+		insertAnalyticsRows(t, db, target.ID, []DetectionResult{{Protocol: "openai", Model: "m", Success: true, Duration: 1, Timestamp: 1_700_000_000}})
+		ids = append(ids, target.ID)
+	}
+	t.Cleanup(func() { close(hang) })
+	key, token, err := db.CreateProxyKey("ci", nil, nil, "", 0, nil)
+	if err != nil {
+		t.Fatalf("CreateProxyKey failed: %v", err)
+	}
+	// relay-d is not in the chain and must never be tried.
+	if err := db.UpdateProxyKeyFallback(key.ID, []int{ids[0], ids[1], ids[2]}, 200); err != nil {
+		t.Fatalf("UpdateProxyKeyFallback failed: %v", err)
+	}
+
+	h := &Handlers{db: db}
+	send := func(model string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewBufferString(`{"model":"`+model+`","messages":[]}`))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		h.ProxyChatCompletions(rr, req)
+		return rr
+	}
+	rr := send("relay-a/m")
+	want := strconv.Itoa(ids[0]) + "=503, " + strconv.Itoa(ids[1]) + "=timeout, " + strconv.Itoa(ids[2]) + "=200"
+	if rr.Code != http.StatusOK || rr.Header().Get("X-Proxy-Fallback-Chain") != want || rr.Header().Get("X-Proxy-Target-Id") != strconv.Itoa(ids[2]) {
+		t.Fatalf("expected the chain to end at relay-c, got=%d chain=%q target=%q", rr.Code, rr.Header().Get("X-Proxy-Fallback-Chain"), rr.Header().Get("X-Proxy-Target-Id"))
+	}
+
+	// Channels outside the chain keep the implicit routing.
+	rr = send("relay-d/m")
+	if rr.Code != http.StatusOK || rr.Header().Get("X-Proxy-Fallback-Chain") != "" || rr.Header().Get("X-Proxy-Target-Id") != strconv.Itoa(ids[3]) {
+		t.Fatalf("relay-d should be proxied directly, got=%d chain=%q", rr.Code, rr.Header().Get("X-Proxy-Fallback-Chain"))
+	}
+}
+
+func TestNormalizeProxyFallbackChain(t *testing.T) {
+	got, err := normalizeProxyFallbackChain([]int{3, 7, 3, 1})
+	if err != nil || len(got) != 3 || got[0] != 3 || got[1] != 7 || got[2] != 1 {
+		t.Fatalf("chain should keep order and drop repeats, got=%v err=%v", got, err)
+	}
+	if _, err := normalizeProxyFallbackChain([]int{3, 0}); err == nil {
+		t.Fatalf("non-positive ids should be rejected")
+	}
+	long := make([]int, 0, maxProxyFallbackHops+1)
+	for i := 1; i <= maxProxyFallbackHops+1; i++ {
+		long = append(long, i)
+	}
+	if _, err := normalizeProxyFallbackChain(long); err == nil {
+		t.Fatalf("chains over %d hops should be rejected", maxProxyFallbackHops)
+	}
+}
@@ -0,0 +1,178 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Proxy key limits bound what a leaked key can do: max_concurrent caps its in-flight
+// proxy requests (429 beyond it) and ip_allowlist restricts the client IPs, resolved
+// with trusted_proxies like the admin allowlists, it may be used from (403 otherwise).
+
+const (
+	maxProxyKeyConcurrent  = 10000
+	maxProxyKeyIPAllowlist = 100
+)
+
+type patchProxyKeyRequest struct {
+	MaxConcurrent *int      `json:"max_concurrent"`
+	IPAllowlist   *[]string `json:"ip_allowlist"`
+	// FallbackTargetIDs and FallbackTimeoutMs set the fallback chain (proxy_fallback.go).
+	FallbackTargetIDs *[]int `json:"fallback_target_ids"`
+	FallbackTimeoutMs *int   `json:"fallback_timeout_ms"`
+}
+
+func validateProxyKeyMaxConcurrent(n int) string {
+	if n < 0 || n > maxProxyKeyConcurrent {
+		return fmt.Sprintf("max_concurrent must be between 0 and %d", maxProxyKeyConcurrent)
+	}
+	return ""
+}
+
+// normalizeProxyIPAllowlist trims and dedupes the entries, rejecting invalid IPs and CIDRs.
+func normalizeProxyIPAllowlist(items []string) ([]string, error) {
+	out := make([]string, 0, len(items))
+	seen := make(map[string]bool, len(items))
+	for _, item := range items {
+		item = strings.TrimSpace(item)
+		if item == "" || seen[item] {
+			continue
+		}
+		if item == "*" {
+			return nil, fmt.Errorf("ip_allowlist must list IPs or CIDRs; leave it empty to allow any client")
+		}
+		if _, err := parseIPNetList(item); err != nil || strings.ContainsAny(item, ", \t\r\n") {
+			return nil, fmt.Errorf("ip_allowlist: invalid ip or cidr %q", item)
+		}
+		seen[item] = true
+		out = append(out, item)
+	}
+	if len(out) > maxProxyKeyIPAllowlist {
+		return nil, fmt.Errorf("ip_allowlist must contain <= %d entries", maxProxyKeyIPAllowlist)
+	}
+	return out, nil
+}
+
+func proxyKeyIPAllowed(key *ProxyKey, ip string) bool {
+	if key == nil || len(key.IPAllowlist) == 0 {
+		return true
+	}
+	list, err := parseIPNetList(strings.Join(key.IPAllowlist, ","))
+	return err == nil && list.contains(ip)
+}
+
+// proxyKeyInFlight counts in-flight proxy requests by proxy key id.
+var (
+	proxyKeyInFlightMu sync.Mutex
+	proxyKeyInFlight   = map[int]int{}
+)
+
+// acquireProxyKeySlot reserves an in-flight slot for key, reporting false when its
+// max_concurrent limit is reached. The returned release frees the slot.
+func acquireProxyKeySlot(key *ProxyKey) (func(), bool) {
+	if key == nil || key.MaxConcurrent <= 0 {
+		return func() {}, true
+	}
+	proxyKeyInFlightMu.Lock()
+	defer proxyKeyInFlightMu.Unlock()
+	if proxyKeyInFlight[key.ID] >= key.MaxConcurrent {
+		return nil, false
+	}
+	proxyKeyInFlight[key.ID]++
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			proxyKeyInFlightMu.Lock()
+			defer proxyKeyInFlightMu.Unlock()
+			if proxyKeyInFlight[key.ID]--; proxyKeyInFlight[key.ID] <= 0 {
+				delete(proxyKeyInFlight, key.ID)
+			}
+		})
+	}, true
+}
+
+// UpdateProxyKeyLimits sets a key's max_concurrent and ip_allowlist.
+func (d *Database) UpdateProxyKeyLimits(id, maxConcurrent int, ipAllowlist []string) error {
+	raw, _ := json.Marshal(ipAllowlist)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	_, err := d.conn.Exec(`UPDATE proxy_keys SET max_concurrent = ?, ip_allowlist = ? WHERE id = ?`, maxConcurrent, string(raw), id)
+	return err
+}
+
+// PatchProxyKey handles PATCH /api/proxy/keys/{id}
+func (h *Handlers) PatchProxyKey(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(r)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "invalid id"})
+		return
+	}
+	var req patchProxyKeyRequest
+	if err := readJSON(r, &req); err != nil {
+		writeRequestBodyError(w, err)
+		return
+	}
+	if req.MaxConcurrent == nil && req.IPAllowlist == nil && req.FallbackTargetIDs == nil && req.FallbackTimeoutMs == nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "no fields provided"})
+		return
+	}
+	before, err := h.db.getProxyKeyByID(id)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return
+	}
+	if before == nil {
+		writeJSON(w, http.StatusNotFound, map[string]any{"detail": "proxy key not found"})
+		return
+	}
+	maxConcurrent, allowlist := before.MaxConcurrent, before.IPAllowlist
+	if req.MaxConcurrent != nil {
+		if detail := validateProxyKeyMaxConcurrent(*req.MaxConcurrent); detail != "" {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"detail": detail})
+			return
+		}
+		maxConcurrent = *req.MaxConcurrent
+	}
+	if req.IPAllowlist != nil {
+		if allowlist, err = normalizeProxyIPAllowlist(*req.IPAllowlist); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"detail": err.Error()})
+			return
+		}
+	}
+	chain, timeoutMs := before.FallbackTargetIDs, before.FallbackTimeoutMs
+	if req.FallbackTargetIDs != nil {
+		if chain, err = normalizeProxyFallbackChain(*req.FallbackTargetIDs); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"detail": err.Error()})
+			return
+		}
+		if detail := h.validateProxyFallbackTargets(chain, before.AllowedTargetIDs); detail != "" {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"detail": detail})
+			return
+		}
+	}
+	if req.FallbackTimeoutMs != nil {
+		if detail := validateProxyKeyFallbackTimeout(*req.FallbackTimeoutMs); detail != "" {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"detail": detail})
+			return
+		}
+		timeoutMs = *req.FallbackTimeoutMs
+	}
+	if err := h.db.UpdateProxyKeyLimits(id, maxConcurrent, allowlist); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return
+	}
+	if err := h.db.UpdateProxyKeyFallback(id, chain, timeoutMs); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return
+	}
+	item, err := h.db.getProxyKeyByID(id)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return
+	}
+	h.audit(r, "proxy_key.update", "proxy_key", id, auditDiff(before, item, nil, nil))
+	writeJSON(w, http.StatusOK, map[string]any{"item": item})
+}
@@ -0,0 +1,88 @@
+package app
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestProxyKeyIPAllowlistAndPatch(t *testing.T) {
+	db, err := NewDatabase(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDatabase failed: %v", err)
+	}
+	t.Cleanup(func() { _ = db.conn.Close() })
+	if err := db.EnsureProxySchema(); err != nil {
+		t.Fatalf("EnsureProxySchema failed: %v", err)
+	}
+	if err := db.EnsureAuditSchema(); err != nil {
+		t.Fatalf("EnsureAuditSchema failed: %v", err)
+	}
+	h := &Handlers{db: db}
+	key, token, err := db.CreateProxyKey("ci", nil, nil, "", 2, []string{"10.0.0.0/8"})
+	if err != nil {
+		t.Fatalf("CreateProxyKey failed: %v", err)
+	}
+	auth := func(remote string) error {
+		req := httptest.NewRequest(http.MethodGet, "/v1/models", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.RemoteAddr = remote
+		_, err := h.authenticateProxyRequest(req)
+		return err
+	}
+	if err := auth("10.1.2.3:4000"); err != nil {
+		t.Fatalf("allowed client should authenticate, got %v", err)
+	}
+	if err := auth("203.0.113.7:4000"); err != errProxyIPNotAllowed {
+		t.Fatalf("client outside ip_allowlist should be rejected, got %v", err)
+	}
+
+	id := strconv.Itoa(key.ID)
+	for body, want := range map[string]int{
+		`{"ip_allowlist":["*"]}`:                http.StatusBadRequest,
+		`{"max_concurrent":-1}`:                 http.StatusBadRequest,
+		`{}`:                                    http.StatusBadRequest,
+		`{"ip_allowlist":[" ", "203.0.113.7"]}`: http.StatusOK,
+	} {
+		req := httptest.NewRequest(http.MethodPatch, "/api/proxy/keys/"+id, bytes.NewBufferString(body))
+		req.SetPathValue("id", id)
+		rr := httptest.NewRecorder()
+		h.PatchProxyKey(rr, withAuthRole(req, authRoleAdmin))
+		if rr.Code != want {
+			t.Fatalf("PATCH %s: expected %d, got=%d body=%s", body, want, rr.Code, rr.Body.String())
+		}
+	}
+	if err := auth("203.0.113.7:4000"); err != nil {
+		t.Fatalf("patched ip_allowlist should allow the client, got %v", err)
+	}
+	updated, _ := db.getProxyKeyByID(key.ID)
+	if updated.MaxConcurrent != 2 || len(updated.IPAllowlist) != 1 {
+		t.Fatalf("patch should keep max_concurrent and replace the allowlist, got=%+v", updated)
+	}
+}
+
+func TestAcquireProxyKeySlot(t *testing.T) {
+	key := &ProxyKey{ID: 991, MaxConcurrent: 2}
+	r1, ok1 := acquireProxyKeySlot(key)
+	r2, ok2 := acquireProxyKeySlot(key)
+	if _, ok := acquireProxyKeySlot(key); !ok1 || !ok2 || ok {
+		t.Fatalf("expected two slots and a rejected third, got %v %v %v", ok1, ok2, ok)
+	}
+	r1()
+	r1()
+	r3, ok := acquireProxyKeySlot(key)
+	if !ok {
+		t.Fatalf("releasing a slot should admit the next request")
+	}
+	if _, ok := acquireProxyKeySlot(key); ok {
+		t.Fatalf("releasing twice must not free two slots")
+	}
+	r2()
+	r3()
+	if _, ok := acquireProxyKeySlot(&ProxyKey{ID: 992}); !ok {
+		t.Fatalf("keys without max_concurrent are unlimited")
+	}
+}
@@ -0,0 +1,360 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Every authenticated proxy request is recorded in proxy_requests (key, model, target,
+// status, duration, token usage; see usage.go) for the per-key stats dashboard, and published as a proxy_request
+// event on the admin-only proxy activity stream. Status 0 marks a request whose
+// upstream could not be reached; 0 or >= 400 counts as an error. Rows are kept for
+// proxyRequestRetention.
+
+const (
+	proxyRequestRetention     = 30 * 24 * time.Hour
+	proxyRequestPruneInterval = time.Hour
+	maxProxyStatsTopModels    = 50
+)
+
+// proxyStatsWindows are the selectable ?window= values of the key stats.
+var proxyStatsWindows = map[string]time.Duration{
+	"1h":  time.Hour,
+	"6h":  6 * time.Hour,
+	"24h": 24 * time.Hour,
+	"7d":  7 * 24 * time.Hour,
+	"30d": proxyRequestRetention,
+}
+
+// EnsureProxyRequestSchema creates the proxy request log.
+func (d *Database) EnsureProxyRequestSchema() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	_, err := d.conn.Exec(`
+		CREATE TABLE IF NOT EXISTS proxy_requests (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			key_id INTEGER NOT NULL,
+			target_id INTEGER NOT NULL DEFAULT 0,
+			model TEXT NOT NULL DEFAULT '',
+			status_code INTEGER NOT NULL DEFAULT 0,
+			duration_ms INTEGER NOT NULL DEFAULT 0,
+			created_at REAL NOT NULL,
+			prompt_tokens INTEGER NOT NULL DEFAULT 0,
+			completion_tokens INTEGER NOT NULL DEFAULT 0,
+			reasoning_tokens INTEGER NOT NULL DEFAULT 0
+		);
+		CREATE INDEX IF NOT EXISTS idx_proxy_requests_key_time ON proxy_requests(key_id, created_at);
+		CREATE INDEX IF NOT EXISTS idx_proxy_requests_time ON proxy_requests(created_at);
+	`)
+	if err != nil {
+		return err
+	}
+	existing, err := d.tableColumns("proxy_requests")
+	if err != nil {
+		return err
+	}
+	for _, column := range []string{"prompt_tokens", "completion_tokens", "reasoning_tokens"} {
+		if !existing[column] {
+			if _, err := d.conn.Exec("ALTER TABLE proxy_requests ADD COLUMN " + column + " INTEGER NOT NULL DEFAULT 0"); err != nil {
+				return err
+			}
+		}
+	}
+	_, err = d.conn.Exec(`CREATE INDEX IF NOT EXISTS idx_proxy_requests_target_time ON proxy_requests(target_id, created_at)`)
+	return err
+}
+
+// proxyActivity is one proxied request, as stored and published.
+type proxyActivity struct {
+	KeyID      int        `json:"key_id"`
+	KeyName    string     `json:"key_name"`
+	Model      string     `json:"model"`
+	TargetID   int        `json:"target_id,omitempty"`
+	StatusCode int        `json:"status_code"`
+	DurationMs int64      `json:"duration_ms"`
+	Error      string     `json:"error,omitempty"`
+	Usage      tokenUsage `json:"usage"`
+	At         float64    `json:"at"`
+}
+
+// InsertProxyRequest records a proxied request.
+func (d *Database) InsertProxyRequest(a proxyActivity) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	_, err := d.conn.Exec(`INSERT INTO proxy_requests (key_id, target_id, model, status_code, duration_ms, created_at, prompt_tokens, completion_tokens, reasoning_tokens)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		a.KeyID, a.TargetID, a.Model, a.StatusCode, a.DurationMs, a.At, a.Usage.PromptTokens, a.Usage.CompletionTokens, a.Usage.ReasoningTokens)
+	return err
+}
+
+// PruneProxyRequests deletes requests recorded before cutoff.
+func (d *Database) PruneProxyRequests(cutoff time.Time) (int64, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	res, err := d.conn.Exec(`DELETE FROM proxy_requests WHERE created_at < ?`, float64(cutoff.UnixMilli())/1000.0)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// proxyStatusCounts counts requests by status class; transport counts upstreams that
+// could not be reached.
+type proxyStatusCounts struct {
+	Success   int `json:"2xx"`
+	Redirect  int `json:"3xx"`
+	Client    int `json:"4xx"`
+	Server    int `json:"5xx"`
+	Transport int `json:"transport"`
+}
+
+// proxyLatency holds latency percentiles in ms over successful requests; nil without any.
+type proxyLatency struct {
+	P50 *float64 `json:"p50"`
+	P90 *float64 `json:"p90"`
+	P95 *float64 `json:"p95"`
+	P99 *float64 `json:"p99"`
+	Avg *float64 `json:"avg"`
+}
+
+type proxyModelStats struct {
+	Model        string   `json:"model"`
+	Requests     int      `json:"requests"`
+	Errors       int      `json:"errors"`
+	ErrorRate    float64  `json:"error_rate"`
+	AvgLatencyMs *float64 `json:"avg_latency_ms"`
+}
+
+// ProxyKeyStats is the usage of a proxy key over a window.
+type ProxyKeyStats struct {
+	KeyID     int               `json:"key_id"`
+	Window    string            `json:"window"`
+	Since     float64           `json:"since"`
+	Requests  int               `json:"requests"`
+	Errors    int               `json:"errors"`
+	ErrorRate float64           `json:"error_rate"`
+	Status    proxyStatusCounts `json:"status"`
+	LatencyMs proxyLatency      `json:"latency_ms"`
+	TopModels []proxyModelStats `json:"top_models"`
+}
+
+const proxyRequestErrorExpr = `(status_code = 0 OR status_code >= 400)`
+
+// GetProxyKeyStats aggregates the requests of key recorded since since.
+func (d *Database) GetProxyKeyStats(keyID int, since time.Time, topModels int) (*ProxyKeyStats, error) {
+	sinceTS := float64(since.UnixMilli()) / 1000.0
+	out := &ProxyKeyStats{KeyID: keyID, Since: sinceTS, TopModels: []proxyModelStats{}}
+	err := d.read.QueryRow(`
+		SELECT COUNT(*),
+			COALESCE(SUM(CASE WHEN `+proxyRequestErrorExpr+` THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN status_code BETWEEN 200 AND 299 THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN status_code BETWEEN 300 AND 399 THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN status_code BETWEEN 400 AND 499 THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN status_code >= 500 THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN status_code = 0 THEN 1 ELSE 0 END), 0)
+		FROM proxy_requests WHERE key_id = ? AND created_at >= ?`, keyID, sinceTS).
+		Scan(&out.Requests, &out.Errors, &out.Status.Success, &out.Status.Redirect, &out.Status.Client, &out.Status.Server, &out.Status.Transport)
+	if err != nil {
+		return nil, err
+	}
+	out.ErrorRate = proxyErrorRate(out.Errors, out.Requests)
+
+	// Nearest-rank percentiles: the smallest duration whose rank reaches ceil(n*p/100).
+	err = d.read.QueryRow(`
+		WITH ranked AS (
+			SELECT duration_ms,
+				ROW_NUMBER() OVER (ORDER BY duration_ms) AS rn,
+				COUNT(*) OVER () AS cnt
+			FROM proxy_requests
+			WHERE key_id = ? AND created_at >= ? AND NOT `+proxyRequestErrorExpr+`
+		)
+		SELECT MIN(CASE WHEN rn >= (cnt * 50 + 99) / 100 THEN duration_ms END),
+			MIN(CASE WHEN rn >= (cnt * 90 + 99) / 100 THEN duration_ms END),
+			MIN(CASE WHEN rn >= (cnt * 95 + 99) / 100 THEN duration_ms END),
+			MIN(CASE WHEN rn >= (cnt * 99 + 99) / 100 THEN duration_ms END),
+			AVG(duration_ms)
+		FROM ranked`, keyID, sinceTS).
+		Scan(&out.LatencyMs.P50, &out.LatencyMs.P90, &out.LatencyMs.P95, &out.LatencyMs.P99, &out.LatencyMs.Avg)
+	if err != nil {
+		return nil, err
+	}
+	out.LatencyMs.Avg = roundMs(out.LatencyMs.Avg)
+
+	rows, err := d.read.Query(`
+		SELECT model, COUNT(*),
+			SUM(CASE WHEN `+proxyRequestErrorExpr+` THEN 1 ELSE 0 END),
+			AVG(CASE WHEN NOT `+proxyRequestErrorExpr+` THEN duration_ms END)
+		FROM proxy_requests WHERE key_id = ? AND created_at >= ?
+		GROUP BY model ORDER BY COUNT(*) DESC, model LIMIT ?`, keyID, sinceTS, topModels)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var m proxyModelStats
+		if err := rows.Scan(&m.Model, &m.Requests, &m.Errors, &m.AvgLatencyMs); err != nil {
+			return nil, err
+		}
+		m.ErrorRate = proxyErrorRate(m.Errors, m.Requests)
+		m.AvgLatencyMs = roundMs(m.AvgLatencyMs)
+		out.TopModels = append(out.TopModels, m)
+	}
+	return out, rows.Err()
+}
+
+func proxyErrorRate(errors, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return math.Round(float64(errors)/float64(total)*10000) / 10000
+}
+
+// roundMs rounds a latency in ms to one decimal.
+func roundMs(v *float64) *float64 {
+	if v == nil {
+		return nil
+	}
+	r := math.Round(*v*10) / 10
+	return &r
+}
+
+// proxyStatusWriter records the status written to a proxy response, its token usage
+// once the upstream body is copied, and its body when the request is captured for the
+// proxy audit.
+type proxyStatusWriter struct {
+	http.ResponseWriter
+	status  int
+	capture *cappedBuffer
+	usage   *proxyUsageMeter
+}
+
+func (w *proxyStatusWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *proxyStatusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if w.capture != nil {
+		w.capture.Write(b)
+	}
+	if w.usage != nil {
+		w.usage.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *proxyStatusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *proxyStatusWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+// recordProxyActivity stores a finished proxy request and publishes it to the proxy
+// activity stream. Requests of the master token (key id 0) are only published.
+func (h *Handlers) recordProxyActivity(a proxyActivity) {
+	if a.KeyID > 0 {
+		if err := h.db.InsertProxyRequest(a); err != nil {
+			h.logger("proxy").Warn("record proxy request failed", "key_id", a.KeyID, "error", err)
+		}
+	}
+	if h.proxyEvents != nil {
+		if raw, err := json.Marshal(a); err == nil {
+			h.proxyEvents.Publish("proxy_request", string(raw))
+		}
+	}
+}
+
+// maybePruneProxyRequests drops proxy requests and proxy audit entries past their
+// retention at most once per proxyRequestPruneInterval.
+func (ms *MonitorService) maybePruneProxyRequests() {
+	ms.mu.Lock()
+	due := time.Since(ms.lastProxyPruneAt) >= proxyRequestPruneInterval
+	if due {
+		ms.lastProxyPruneAt = time.Now()
+	}
+	ms.mu.Unlock()
+	if !due {
+		return
+	}
+	go func() {
+		n, err := ms.db.PruneProxyRequests(time.Now().Add(-proxyRequestRetention))
+		if err != nil {
+			ms.logger().Error("proxy request prune failed", "error", err)
+			return
+		}
+		if n > 0 {
+			ms.logger().Info("proxy requests pruned", "rows", n)
+		}
+		if audit := proxyAuditActive.Load(); audit != nil {
+			n, err := ms.db.PruneProxyAudit(time.Now().Add(-audit.retention))
+			if err != nil {
+				ms.logger().Error("proxy audit prune failed", "error", err)
+			} else if n > 0 {
+				ms.logger().Info("proxy audit pruned", "rows", n)
+			}
+		}
+	}()
+}
+
+// ProxyKeyStats handles GET /api/proxy/keys/{id}/stats?window=24h&top=10
+func (h *Handlers) ProxyKeyStats(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(r)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "invalid id"})
+		return
+	}
+	window := r.URL.Query().Get("window")
+	if window == "" {
+		window = "24h"
+	}
+	span, ok := proxyStatsWindows[window]
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "window must be one of 1h, 6h, 24h, 7d, 30d"})
+		return
+	}
+	top := 10
+	if raw := r.URL.Query().Get("top"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxProxyStatsTopModels {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"detail": fmt.Sprintf("top must be between 1 and %d", maxProxyStatsTopModels)})
+			return
+		}
+		top = n
+	}
+	key, err := h.db.getProxyKeyByID(id)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return
+	}
+	if key == nil {
+		writeJSON(w, http.StatusNotFound, map[string]any{"detail": "proxy key not found"})
+		return
+	}
+	stats, err := h.db.GetProxyKeyStats(id, time.Now().Add(-span), top)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return
+	}
+	stats.Window = window
+	writeJSON(w, http.StatusOK, map[string]any{"item": stats})
+}
+
+// ProxyEvents handles GET /api/proxy/events, the SSE stream of proxy activity.
+func (h *Handlers) ProxyEvents(w http.ResponseWriter, r *http.Request) {
+	if h.proxyEvents == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]any{"detail": "proxy activity stream unavailable"})
+		return
+	}
+	h.proxyEvents.ServeHTTP(w, r)
+}
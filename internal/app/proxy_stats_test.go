@@ -0,0 +1,96 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestProxyKeyStatsAggregatesWindow(t *testing.T) {
+	db, err := NewDatabase(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDatabase failed: %v", err)
+	}
+	t.Cleanup(func() { _ = db.conn.Close() })
+	if err := db.EnsureProxySchema(); err != nil {
+		t.Fatalf("EnsureProxySchema failed: %v", err)
+	}
+	if err := db.EnsureProxyRequestSchema(); err != nil {
+		t.Fatalf("EnsureProxyRequestSchema failed: %v", err)
+	}
+	key, token, err := db.CreateProxyKey("ci", nil, nil, "", 0, nil)
+	if err != nil {
+		t.Fatalf("CreateProxyKey failed: %v", err)
+	}
+	now := float64(time.Now().UnixMilli()) / 1000.0
+	for i := 1; i <= 10; i++ {
+		_ = db.InsertProxyRequest(proxyActivity{KeyID: key.ID, Model: "relay/a", TargetID: 1, StatusCode: 200, DurationMs: int64(i * 100), At: now})
+	}
+	_ = db.InsertProxyRequest(proxyActivity{KeyID: key.ID, Model: "relay/b", TargetID: 1, StatusCode: 502, DurationMs: 5, At: now})
+	_ = db.InsertProxyRequest(proxyActivity{KeyID: key.ID, Model: "relay/b", TargetID: 1, StatusCode: 0, DurationMs: 9000, At: now})
+	_ = db.InsertProxyRequest(proxyActivity{KeyID: key.ID, Model: "relay/a", StatusCode: 200, DurationMs: 1, At: now - 2*3600})
+	_ = db.InsertProxyRequest(proxyActivity{KeyID: key.ID + 1, Model: "relay/a", StatusCode: 200, DurationMs: 1, At: now})
+
+	bus := NewSSEBus()
+	defer bus.Close()
+	sub := bus.subscribe(nil)
+	h := &Handlers{db: db, proxyEvents: bus}
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewBufferString(`{"messages":[]}`))
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr := httptest.NewRecorder()
+	h.ProxyChatCompletions(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("request without model should fail, got=%d body=%s", rr.Code, rr.Body.String())
+	}
+	select {
+	case ev := <-sub.ch:
+		var a proxyActivity
+		if ev.Event != "proxy_request" || json.Unmarshal([]byte(ev.Data), &a) != nil || a.KeyID != key.ID || a.StatusCode != http.StatusBadRequest {
+			t.Fatalf("unexpected proxy activity event: %+v", ev)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected a proxy_request event")
+	}
+
+	id := strconv.Itoa(key.ID)
+	get := func(query string) (int, *ProxyKeyStats) {
+		req := httptest.NewRequest(http.MethodGet, "/api/proxy/keys/"+id+"/stats"+query, nil)
+		req.SetPathValue("id", id)
+		rr := httptest.NewRecorder()
+		h.ProxyKeyStats(rr, withAuthRole(req, authRoleAdmin))
+		var resp struct {
+			Item *ProxyKeyStats `json:"item"`
+		}
+		_ = json.Unmarshal(rr.Body.Bytes(), &resp)
+		return rr.Code, resp.Item
+	}
+	code, stats := get("?window=1h")
+	if code != http.StatusOK || stats == nil {
+		t.Fatalf("expected stats, got=%d", code)
+	}
+	// 10 successes, a 502, a transport error and the 400 recorded above.
+	if stats.Requests != 13 || stats.Errors != 3 || stats.Status.Success != 10 || stats.Status.Client != 1 || stats.Status.Server != 1 || stats.Status.Transport != 1 {
+		t.Fatalf("unexpected counts: %+v", stats)
+	}
+	if *stats.LatencyMs.P50 != 500 || *stats.LatencyMs.P90 != 900 || *stats.LatencyMs.P99 != 1000 || *stats.LatencyMs.Avg != 550 {
+		t.Fatalf("unexpected latency percentiles: %+v", stats.LatencyMs)
+	}
+	if len(stats.TopModels) != 3 || stats.TopModels[0].Model != "relay/a" || stats.TopModels[0].Requests != 10 || stats.TopModels[1].ErrorRate != 1 {
+		t.Fatalf("unexpected top models: %+v", stats.TopModels)
+	}
+	if _, stats := get(""); stats.Window != "24h" || stats.Requests != 14 {
+		t.Fatalf("default window should be 24h, got %+v", stats)
+	}
+	if code, _ := get("?window=2d"); code != http.StatusBadRequest {
+		t.Fatalf("unknown window should be rejected, got=%d", code)
+	}
+
+	if n, err := db.PruneProxyRequests(time.Now().Add(-time.Hour)); err != nil || n != 1 {
+		t.Fatalf("prune should drop the old request, got n=%d err=%v", n, err)
+	}
+}
@@ -2,6 +2,7 @@ package app
 
 import (
 	"encoding/json"
+	"fmt"
 	"testing"
 )
 
@@ -98,3 +99,21 @@ func TestRewriteBodyModel(t *testing.T) {
 		t.Fatalf("expected invalid JSON error")
 	}
 }
+
+func TestRankProxyCandidatesByRecentHealth(t *testing.T) {
+	point := func(ok bool, d float64) ModelHistoryPoint { return ModelHistoryPoint{Success: ok, Duration: &d} }
+	targets := []Target{{ID: 1}, {ID: 2}, {ID: 3}, {ID: 4}}
+	histories := map[int]map[string][]ModelHistoryPoint{
+		1: {"gpt-4o": {point(false, 1), point(true, 1)}},
+		2: {"gpt-4o": {point(true, 3), point(true, 3)}},
+		3: {"gpt-4o": {point(true, 1), point(true, 2)}, "other": {point(false, 1)}},
+	}
+	rankProxyCandidates(targets, histories, "gpt-4o")
+	var got []int
+	for _, tg := range targets {
+		got = append(got, tg.ID)
+	}
+	if fmt.Sprint(got) != "[3 2 1 4]" {
+		t.Fatalf("expected best success rate then lowest latency first, got=%v", got)
+	}
+}
@@ -0,0 +1,167 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ProxyTransform rewrites proxied request bodies for a target before they are forwarded,
+// for upstreams that reject or mishandle some parameters. StripParams removes keys
+// (dotted paths reach into objects, e.g. "generationConfig.thinkingConfig"),
+// Defaults sets top-level keys the client left out (e.g. {"temperature": 0.7}), and
+// MaxTokens clamps max_tokens, max_completion_tokens, max_output_tokens and Gemini's
+// generationConfig.maxOutputTokens when the client asks for more. Detection requests
+// are not transformed.
+type ProxyTransform struct {
+	StripParams []string       `json:"strip_params,omitempty"`
+	Defaults    map[string]any `json:"defaults,omitempty"`
+	MaxTokens   int            `json:"max_tokens,omitempty"`
+}
+
+const (
+	maxProxyTransformParams = 50
+	maxProxyTransformTokens = 1048576
+)
+
+// proxyMaxTokensPaths are the output token limits clamped by ProxyTransform.MaxTokens.
+var proxyMaxTokensPaths = []string{"max_tokens", "max_completion_tokens", "max_output_tokens", "generationConfig.maxOutputTokens"}
+
+// Empty reports whether the transform changes nothing.
+func (t ProxyTransform) Empty() bool {
+	return len(t.StripParams) == 0 && len(t.Defaults) == 0 && t.MaxTokens == 0
+}
+
+// validateProxyTransform checks that proxy_transform is {strip_params, defaults, max_tokens}.
+func validateProxyTransform(v any) error {
+	item, ok := v.(map[string]any)
+	if !ok {
+		return fmt.Errorf("proxy_transform must be an object of {strip_params, defaults, max_tokens}")
+	}
+	for key, val := range item {
+		switch key {
+		case "strip_params":
+			params, ok := val.([]any)
+			if !ok || len(params) > maxProxyTransformParams {
+				return fmt.Errorf("proxy_transform.strip_params must be an array of <= %d strings", maxProxyTransformParams)
+			}
+			for _, p := range params {
+				s, ok := p.(string)
+				s = strings.TrimSpace(s)
+				if !ok || s == "" || len(s) > 128 || strings.Contains(s, "..") || strings.HasPrefix(s, ".") || strings.HasSuffix(s, ".") {
+					return fmt.Errorf("proxy_transform.strip_params items must be 1-128 char keys or dotted paths")
+				}
+				if s == "model" {
+					return fmt.Errorf("proxy_transform.strip_params must not strip model")
+				}
+			}
+		case "defaults":
+			defaults, ok := val.(map[string]any)
+			if !ok || len(defaults) > maxProxyTransformParams {
+				return fmt.Errorf("proxy_transform.defaults must be an object of <= %d keys", maxProxyTransformParams)
+			}
+			for k := range defaults {
+				if strings.TrimSpace(k) == "" || len(k) > 128 {
+					return fmt.Errorf("proxy_transform.defaults keys must be 1-128 chars")
+				}
+				if k == "model" {
+					return fmt.Errorf("proxy_transform.defaults must not set model")
+				}
+			}
+		case "max_tokens":
+			n, ok := anyInt(val)
+			if !ok || n < 0 || n > maxProxyTransformTokens {
+				return fmt.Errorf("proxy_transform.max_tokens must be an integer between 0 and %d", maxProxyTransformTokens)
+			}
+		default:
+			return fmt.Errorf("proxy_transform has unknown field %s", key)
+		}
+	}
+	return nil
+}
+
+func proxyTransformFromAny(v any) ProxyTransform {
+	var out ProxyTransform
+	if v == nil {
+		return out
+	}
+	raw, err := json.Marshal(v)
+	if err != nil || json.Unmarshal(raw, &out) != nil {
+		return ProxyTransform{}
+	}
+	params := make([]string, 0, len(out.StripParams))
+	for _, p := range out.StripParams {
+		if p = strings.TrimSpace(p); p != "" {
+			params = append(params, p)
+		}
+	}
+	out.StripParams = params
+	if len(out.StripParams) == 0 {
+		out.StripParams = nil
+	}
+	if len(out.Defaults) == 0 {
+		out.Defaults = nil
+	}
+	return out
+}
+
+// lookupPath returns the object holding the last key of a dotted path, and that key.
+func lookupPath(payload map[string]any, path string) (map[string]any, string) {
+	keys := strings.Split(path, ".")
+	obj := payload
+	for _, k := range keys[:len(keys)-1] {
+		next, ok := obj[k].(map[string]any)
+		if !ok {
+			return nil, ""
+		}
+		obj = next
+	}
+	return obj, keys[len(keys)-1]
+}
+
+// applyProxyTransform rewrites a JSON request body with t. It returns the body
+// unchanged when t is empty or changes nothing.
+func applyProxyTransform(body []byte, t ProxyTransform) ([]byte, error) {
+	if t.Empty() {
+		return body, nil
+	}
+	var payload map[string]any
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("invalid JSON body")
+	}
+	changed := false
+	for _, path := range t.StripParams {
+		if obj, key := lookupPath(payload, path); obj != nil {
+			if _, ok := obj[key]; ok {
+				delete(obj, key)
+				changed = true
+			}
+		}
+	}
+	for key, val := range t.Defaults {
+		if _, ok := payload[key]; !ok {
+			payload[key] = val
+			changed = true
+		}
+	}
+	if t.MaxTokens > 0 {
+		for _, path := range proxyMaxTokensPaths {
+			obj, key := lookupPath(payload, path)
+			if obj == nil {
+				continue
+			}
+			if n, ok := anyFloat(obj[key]); ok && n > float64(t.MaxTokens) {
+				obj[key] = t.MaxTokens
+				changed = true
+			}
+		}
+	}
+	if !changed {
+		return body, nil
+	}
+	out, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode JSON body")
+	}
+	return out, nil
+}
@@ -0,0 +1,81 @@
+package app
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+)
+
+func TestApplyProxyTransform(t *testing.T) {
+	transform := ProxyTransform{
+		StripParams: []string{"reasoning_effort", "generationConfig.thinkingConfig", "missing.path"},
+		Defaults:    map[string]any{"temperature": 0.2, "top_p": 0.9},
+		MaxTokens:   4096,
+	}
+	body := []byte(`{"model":"gpt-4o","reasoning_effort":"high","top_p":1,"max_tokens":100000,"max_completion_tokens":512,` +
+		`"generationConfig":{"maxOutputTokens":8192,"thinkingConfig":{"thinkingBudget":1024}}}`)
+	out, err := applyProxyTransform(body, transform)
+	if err != nil {
+		t.Fatalf("applyProxyTransform failed: %v", err)
+	}
+	var got map[string]any
+	if err := json.Unmarshal(out, &got); err != nil {
+		t.Fatalf("decode transformed body: %v", err)
+	}
+	if _, ok := got["reasoning_effort"]; ok {
+		t.Fatalf("reasoning_effort should be stripped, got=%s", out)
+	}
+	if got["temperature"] != 0.2 || got["top_p"] != 1.0 {
+		t.Fatalf("defaults should only fill missing keys, got=%s", out)
+	}
+	if got["max_tokens"] != 4096.0 || got["max_completion_tokens"] != 512.0 {
+		t.Fatalf("max_tokens should be clamped and smaller limits kept, got=%s", out)
+	}
+	gen := got["generationConfig"].(map[string]any)
+	if gen["maxOutputTokens"] != 4096.0 || gen["thinkingConfig"] != nil {
+		t.Fatalf("nested paths should be clamped and stripped, got=%s", out)
+	}
+
+	unchanged := []byte(`{"model":"m", "temperature":0.2}`)
+	if out, _ := applyProxyTransform(unchanged, ProxyTransform{Defaults: map[string]any{"temperature": 1}}); string(out) != string(unchanged) {
+		t.Fatalf("body should be forwarded as is when nothing changes, got=%s", out)
+	}
+	if _, err := applyProxyTransform([]byte("not-json"), transform); err == nil {
+		t.Fatalf("expected invalid JSON error")
+	}
+}
+
+func TestProxyTransformValidationAndStorage(t *testing.T) {
+	for _, bad := range []any{
+		"strip",
+		map[string]any{"strip_params": []any{"model"}},
+		map[string]any{"strip_params": []any{"a..b"}},
+		map[string]any{"defaults": map[string]any{"model": "x"}},
+		map[string]any{"max_tokens": -1},
+		map[string]any{"rename": map[string]any{}},
+	} {
+		if err := validateTargetPayload(map[string]any{"proxy_transform": bad}); err == nil {
+			t.Fatalf("proxy_transform %v should be rejected", bad)
+		}
+	}
+
+	db, err := NewDatabase(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDatabase failed: %v", err)
+	}
+	t.Cleanup(func() { _ = db.conn.Close() })
+	target, err := db.CreateTarget(map[string]any{
+		"name": "t1", "base_url": "http://127.0.0.1:1", "api_key": "k",
+		"proxy_transform": map[string]any{"strip_params": []any{" reasoning_effort "}, "max_tokens": 2048},
+	})
+	if err != nil {
+		t.Fatalf("CreateTarget failed: %v", err)
+	}
+	got, err := db.GetTarget(target.ID)
+	if err != nil || got == nil {
+		t.Fatalf("GetTarget failed: %v", err)
+	}
+	if len(got.ProxyTransform.StripParams) != 1 || got.ProxyTransform.StripParams[0] != "reasoning_effort" || got.ProxyTransform.MaxTokens != 2048 {
+		t.Fatalf("unexpected stored proxy_transform: %+v", got.ProxyTransform)
+	}
+}
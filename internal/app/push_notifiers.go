@@ -0,0 +1,110 @@
+package app
+
+import (
+	"context"
+	"fmt"
+)
+
+// Self-hosted phone push channels: ntfy, Gotify and Bark. Each maps the notification
+// severity to the service's own priority scale so outages can break through
+// do-not-disturb while informational messages stay quiet.
+
+// ntfyNotifier publishes to an ntfy topic; server_url defaults to https://ntfy.sh and
+// token is sent as a bearer token for protected topics.
+type ntfyNotifier struct {
+	serverURL string
+	topic     string
+	token     string
+}
+
+var ntfyPriority = map[string]int{severityInfo: 2, severityWarning: 3, severityError: 4, severityCritical: 5}
+
+func newNtfyNotifier(cfg map[string]any) (notifier, error) {
+	serverURL, err := configURL(cfg, "server_url", "https://ntfy.sh")
+	if err != nil {
+		return nil, err
+	}
+	return &ntfyNotifier{serverURL: serverURL, topic: configString(cfg, "topic"), token: configString(cfg, "token")}, nil
+}
+
+func (n *ntfyNotifier) Notify(ctx context.Context, msg Notification) error {
+	var headers map[string]string
+	if n.token != "" {
+		headers = map[string]string{"Authorization": "Bearer " + n.token}
+	}
+	_, err := postNotificationJSON(ctx, n.serverURL+"/", headers, map[string]any{
+		"topic":    n.topic,
+		"title":    msg.Title,
+		"message":  msg.Message,
+		"priority": ntfyPriority[msg.Severity],
+		"tags":     append([]string{msg.Severity}, msg.Tags...),
+	})
+	if err != nil {
+		return fmt.Errorf("ntfy publish failed: %w", err)
+	}
+	return nil
+}
+
+// gotifyNotifier posts a message with a Gotify application token.
+type gotifyNotifier struct {
+	serverURL string
+	token     string
+}
+
+var gotifyPriority = map[string]int{severityInfo: 2, severityWarning: 4, severityError: 6, severityCritical: 8}
+
+func newGotifyNotifier(cfg map[string]any) (notifier, error) {
+	serverURL, err := configURL(cfg, "server_url", "")
+	if err != nil {
+		return nil, err
+	}
+	return &gotifyNotifier{serverURL: serverURL, token: configString(cfg, "token")}, nil
+}
+
+func (g *gotifyNotifier) Notify(ctx context.Context, msg Notification) error {
+	_, err := postNotificationJSON(ctx, g.serverURL+"/message", map[string]string{"X-Gotify-Key": g.token}, map[string]any{
+		"title":    msg.Title,
+		"message":  msg.Message,
+		"priority": gotifyPriority[msg.Severity],
+	})
+	if err != nil {
+		return fmt.Errorf("gotify message failed: %w", err)
+	}
+	return nil
+}
+
+// barkNotifier pushes to an iOS device through a Bark server; server_url defaults to
+// https://api.day.app.
+type barkNotifier struct {
+	serverURL string
+	deviceKey string
+}
+
+var barkLevel = map[string]string{
+	severityInfo:     "passive",
+	severityWarning:  "active",
+	severityError:    "timeSensitive",
+	severityCritical: "critical",
+}
+
+func newBarkNotifier(cfg map[string]any) (notifier, error) {
+	serverURL, err := configURL(cfg, "server_url", "https://api.day.app")
+	if err != nil {
+		return nil, err
+	}
+	return &barkNotifier{serverURL: serverURL, deviceKey: configString(cfg, "device_key")}, nil
+}
+
+func (b *barkNotifier) Notify(ctx context.Context, msg Notification) error {
+	_, err := postNotificationJSON(ctx, b.serverURL+"/push", nil, map[string]any{
+		"device_key": b.deviceKey,
+		"title":      msg.Title,
+		"body":       msg.Message,
+		"level":      barkLevel[msg.Severity],
+		"group":      "api_monitor",
+	})
+	if err != nil {
+		return fmt.Errorf("bark push failed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPushNotifiersMapSeverity(t *testing.T) {
+	got := map[string]map[string]any{}
+	headers := map[string]string{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		got[r.URL.Path] = body
+		headers[r.URL.Path] = r.Header.Get("Authorization") + r.Header.Get("X-Gotify-Key")
+	}))
+	defer srv.Close()
+
+	n := Notification{Event: "incident_opened", Severity: severityCritical, Title: "prod is down", Message: "Incident opened", Tags: []string{"production"}}
+	for kind, cfg := range map[string]map[string]any{
+		"ntfy":   {"server_url": srv.URL, "topic": "alerts", "token": "tk_1"},
+		"gotify": {"server_url": srv.URL, "token": "app-token"},
+		"bark":   {"server_url": srv.URL, "device_key": "device-1"},
+	} {
+		notifier, err := buildNotifier(kind, cfg)
+		if err != nil {
+			t.Fatalf("%s: buildNotifier failed: %v", kind, err)
+		}
+		if err := notifier.Notify(context.Background(), n); err != nil {
+			t.Fatalf("%s: Notify failed: %v", kind, err)
+		}
+	}
+
+	if b := got["/"]; b["topic"] != "alerts" || b["priority"] != float64(5) || headers["/"] != "Bearer tk_1" {
+		t.Fatalf("unexpected ntfy publish: %v %q", b, headers["/"])
+	}
+	if b := got["/message"]; b["title"] != "prod is down" || b["priority"] != float64(8) || headers["/message"] != "app-token" {
+		t.Fatalf("unexpected gotify message: %v %q", b, headers["/message"])
+	}
+	if b := got["/push"]; b["device_key"] != "device-1" || b["level"] != "critical" || b["body"] != "Incident opened" {
+		t.Fatalf("unexpected bark push: %v", b)
+	}
+	if _, err := buildNotifier("gotify", map[string]any{"token": "x"}); err == nil {
+		t.Fatalf("gotify without server_url should be rejected")
+	}
+}
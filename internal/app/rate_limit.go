@@ -0,0 +1,133 @@
+package app
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Visitor rate limiting. Requests that authenticate as a visitor (anonymous visitor
+// mode, the visitor token or a read-only API token) draw from a token bucket per client
+// IP, as resolved by clientIPFromRequest. A bucket holds visitor_rate_limit_burst tokens
+// and refills at visitor_rate_limit_per_min; an empty bucket answers 429 with
+// Retry-After. Admin requests are never limited. A limit of 0 turns limiting off.
+
+const (
+	settingVisitorRateLimitPerMin = "visitor_rate_limit_per_min"
+	settingVisitorRateLimitBurst  = "visitor_rate_limit_burst"
+
+	maxVisitorRateLimit = 100000
+	// rateLimitMaxBuckets bounds the per-IP table; full (idle) buckets are pruned first.
+	rateLimitMaxBuckets = 4096
+)
+
+type rateBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+type rateLimiter struct {
+	mu      sync.Mutex
+	perMin  int
+	burst   int
+	buckets map[string]*rateBucket
+	now     func() time.Time
+}
+
+var visitorRateLimiter = newRateLimiter(time.Now)
+
+func newRateLimiter(nowFn func() time.Time) *rateLimiter {
+	return &rateLimiter{buckets: map[string]*rateBucket{}, now: nowFn}
+}
+
+// Configure sets the refill rate and bucket size; a burst of 0 uses perMin. Existing
+// buckets are dropped so a lowered limit applies at once.
+func (l *rateLimiter) Configure(perMin, burst int) {
+	perMin = min(max(perMin, 0), maxVisitorRateLimit)
+	burst = min(max(burst, 0), maxVisitorRateLimit)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.perMin, l.burst = perMin, burst
+	l.buckets = map[string]*rateBucket{}
+}
+
+// Config returns the configured rate per minute and the configured burst.
+func (l *rateLimiter) Config() (perMin, burst int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.perMin, l.burst
+}
+
+// Allow takes one token from the bucket of ip. When none is left it returns false and
+// how long until the next token.
+func (l *rateLimiter) Allow(ip string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.perMin <= 0 || ip == "" {
+		return true, 0
+	}
+	capacity := float64(l.burst)
+	if l.burst <= 0 {
+		capacity = float64(l.perMin)
+	}
+	rate := float64(l.perMin) / 60 // tokens per second
+	now := l.now()
+
+	b := l.buckets[ip]
+	if b == nil {
+		if len(l.buckets) >= rateLimitMaxBuckets {
+			l.prune(now, capacity, rate)
+		}
+		b = &rateBucket{tokens: capacity, last: now}
+		l.buckets[ip] = b
+	} else {
+		b.tokens = math.Min(capacity, b.tokens+now.Sub(b.last).Seconds()*rate)
+		b.last = now
+	}
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / rate * float64(time.Second))
+	}
+	b.tokens--
+	return true, 0
+}
+
+// prune drops buckets that have refilled completely; if none has, it starts over.
+func (l *rateLimiter) prune(now time.Time, capacity, rate float64) {
+	for ip, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*rate >= capacity {
+			delete(l.buckets, ip)
+		}
+	}
+	if len(l.buckets) >= rateLimitMaxBuckets {
+		l.buckets = map[string]*rateBucket{}
+	}
+}
+
+func setVisitorRateLimit(perMin, burst int) {
+	visitorRateLimiter.Configure(perMin, burst)
+}
+
+func getVisitorRateLimit() (perMin, burst int) {
+	return visitorRateLimiter.Config()
+}
+
+// visitorRateLimitMiddleware limits requests by visitors; it must run after the
+// principal is attached.
+func visitorRateLimitMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if authRoleFromRequest(r) != authRoleVisitor {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if ok, retryAfter := visitorRateLimiter.Allow(clientIPFromRequest(r)); !ok {
+			perMin, _ := getVisitorRateLimit()
+			w.Header().Set("Retry-After", strconv.Itoa(max(int(math.Ceil(retryAfter.Seconds())), 1)))
+			w.Header().Set("X-RateLimit-Limit", strconv.Itoa(perMin))
+			writeJSON(w, http.StatusTooManyRequests, map[string]any{"detail": "rate limit exceeded, please retry later"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
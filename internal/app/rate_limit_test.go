@@ -0,0 +1,68 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterRefillsOverTime(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	l := newRateLimiter(func() time.Time { return now })
+	l.Configure(60, 2)
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := l.Allow("10.0.0.1"); !ok {
+			t.Fatalf("request %d should fit in the burst", i+1)
+		}
+	}
+	ok, retry := l.Allow("10.0.0.1")
+	if ok || retry <= 0 || retry > time.Second {
+		t.Fatalf("third request should be limited for up to a second, got ok=%v retry=%s", ok, retry)
+	}
+	if ok, _ := l.Allow("10.0.0.2"); !ok {
+		t.Fatalf("other clients should have their own bucket")
+	}
+	now = now.Add(time.Second)
+	if ok, _ := l.Allow("10.0.0.1"); !ok {
+		t.Fatalf("one token should be refilled after a second at 60/min")
+	}
+
+	l.Configure(0, 0)
+	for i := 0; i < 10; i++ {
+		if ok, _ := l.Allow("10.0.0.1"); !ok {
+			t.Fatalf("a zero limit should disable limiting")
+		}
+	}
+}
+
+func TestVisitorRateLimitSkipsAdmins(t *testing.T) {
+	setAuthTokens("admin-token", "visitor-token")
+	setVisitorRateLimit(1, 1)
+	t.Cleanup(func() { setVisitorRateLimit(0, 0) })
+	handler := authAnyMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	do := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/targets", nil)
+		req.RemoteAddr = "192.0.2.10:5000"
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := do("visitor-token"); rr.Code != http.StatusOK {
+		t.Fatalf("first visitor request should pass, got status=%d", rr.Code)
+	}
+	rr := do("visitor-token")
+	if rr.Code != http.StatusTooManyRequests || rr.Header().Get("Retry-After") == "" {
+		t.Fatalf("second visitor request should be limited, got status=%d headers=%v", rr.Code, rr.Header())
+	}
+	for i := 0; i < 3; i++ {
+		if rr := do("admin-token"); rr.Code != http.StatusOK {
+			t.Fatalf("admin requests should not be limited, got status=%d", rr.Code)
+		}
+	}
+}
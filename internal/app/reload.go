@@ -0,0 +1,149 @@
+package app
+
+import (
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// reloadRuntimeConfig re-reads the settings-backed values from the database (including
+// the concurrency limits and the run deadline) and the route rules, then applies them to the running services. SSE clients and in-flight runs are
+// left alone. It returns the values now in effect.
+func (h *Handlers) reloadRuntimeConfig() (map[string]any, error) {
+	if err := reloadConfigFile(); err != nil {
+		return nil, err
+	}
+	settings, err := h.db.GetSettings([]string{
+		settingLogCleanupEnabled,
+		settingLogMaxSizeMB,
+		settingLogMaxAgeDays,
+		settingVisitorModeEnabled,
+		settingCertExpiryWarnDays,
+		settingMonitorPaused,
+		settingLatencyAnomalySigma,
+		settingLatencyAnomalyPct,
+		settingNotifyFlapThreshold,
+		settingPagerDutyRoutingKey,
+		settingAdminIPAllowlist,
+		settingWriteIPAllowlist,
+		settingTrustedProxies,
+		settingAPIKeyRedaction,
+		settingDetectConcurrency,
+		settingMaxParallelTargets,
+		settingMaxRunDurationS,
+		settingRunArchiveDays,
+		settingVisitorRateLimitPerMin,
+		settingVisitorRateLimitBurst,
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Validate the network policy first so a bad value leaves everything unchanged.
+	if _, err := parseIPNetList(settings[settingTrustedProxies]); err != nil {
+		return nil, err
+	}
+	if _, err := parseIPNetList(settings[settingAdminIPAllowlist]); err != nil {
+		return nil, err
+	}
+	if _, err := parseIPNetList(settings[settingWriteIPAllowlist]); err != nil {
+		return nil, err
+	}
+	if _, err := normalizeAPIKeyRedaction(settings[settingAPIKeyRedaction]); err != nil {
+		return nil, err
+	}
+	if err := h.monitor.ReloadRouteRules(); err != nil {
+		return nil, err
+	}
+	_ = setTrustedProxies(settings[settingTrustedProxies])
+	_ = setIPAllowlists(settings[settingAdminIPAllowlist], settings[settingWriteIPAllowlist])
+
+	cleanupEnabled, cleanupMaxMB := h.monitor.LogCleanupConfig()
+	cleanupEnabled = parseBoolString(settings[settingLogCleanupEnabled], cleanupEnabled)
+	cleanupMaxMB = parseIntString(settings[settingLogMaxSizeMB], cleanupMaxMB)
+	h.monitor.UpdateLogCleanupConfig(cleanupEnabled, cleanupMaxMB)
+	h.monitor.UpdateLogMaxAgeDays(parseIntString(settings[settingLogMaxAgeDays], h.monitor.LogMaxAgeDays()))
+	h.monitor.UpdateCertExpiryWarnDays(parseIntString(settings[settingCertExpiryWarnDays], h.monitor.CertExpiryWarnDays()))
+	sigma, pct := h.monitor.LatencyAnomalyConfig()
+	h.monitor.UpdateLatencyAnomalyConfig(
+		parseIntString(settings[settingLatencyAnomalySigma], sigma),
+		parseIntString(settings[settingLatencyAnomalyPct], pct),
+	)
+	setPagerDutyRoutingKey(settings[settingPagerDutyRoutingKey])
+	h.monitor.UpdateNotifyFlapThreshold(parseIntString(settings[settingNotifyFlapThreshold], h.monitor.NotifyFlapThreshold()))
+	h.monitor.SetSchedulerPaused(parseBoolString(settings[settingMonitorPaused], h.monitor.SchedulerPaused()))
+	setVisitorModeEnabled(parseBoolString(settings[settingVisitorModeEnabled], isVisitorModeEnabled()))
+	_ = setAPIKeyRedaction(settings[settingAPIKeyRedaction])
+	ratePerMin, rateBurst := getVisitorRateLimit()
+	setVisitorRateLimit(
+		parseIntString(settings[settingVisitorRateLimitPerMin], ratePerMin),
+		parseIntString(settings[settingVisitorRateLimitBurst], rateBurst),
+	)
+	ratePerMin, rateBurst = getVisitorRateLimit()
+
+	detect, parallel := h.monitor.Concurrency()
+	h.monitor.UpdateConcurrency(
+		parseIntString(settings[settingDetectConcurrency], detect),
+		parseIntString(settings[settingMaxParallelTargets], parallel),
+	)
+
+	runSeconds := parseIntString(settings[settingMaxRunDurationS], int(h.monitor.MaxRunDuration()/time.Second))
+	h.monitor.UpdateMaxRunDuration(time.Duration(runSeconds) * time.Second)
+	h.monitor.UpdateRunArchiveDays(parseIntString(settings[settingRunArchiveDays], h.monitor.RunArchiveDays()))
+
+	cleanupEnabled, cleanupMaxMB = h.monitor.LogCleanupConfig()
+	sigma, pct = h.monitor.LatencyAnomalyConfig()
+	detect, parallel = h.monitor.Concurrency()
+	h.logger("main").Info("configuration reloaded", "log_cleanup", cleanupEnabled, "max_mb", cleanupMaxMB,
+		"detect_concurrency", detect, "max_parallel_targets", parallel)
+	return map[string]any{
+		"log_cleanup_enabled":          cleanupEnabled,
+		"log_max_size_mb":              cleanupMaxMB,
+		"log_max_age_days":             h.monitor.LogMaxAgeDays(),
+		"cert_expiry_warn_days":        h.monitor.CertExpiryWarnDays(),
+		"latency_anomaly_sigma":        sigma,
+		"latency_anomaly_pct":          pct,
+		"notify_flap_threshold":        h.monitor.NotifyFlapThreshold(),
+		"monitor_paused":               h.monitor.SchedulerPaused(),
+		"visitor_mode_enabled":         isVisitorModeEnabled(),
+		"api_key_redaction":            getAPIKeyRedaction(),
+		"monitor_detect_concurrency":   detect,
+		"monitor_max_parallel_targets": parallel,
+		"max_run_duration_s":           int(h.monitor.MaxRunDuration() / time.Second),
+		"run_archive_days":             h.monitor.RunArchiveDays(),
+		"visitor_rate_limit_per_min":   ratePerMin,
+		"visitor_rate_limit_burst":     rateBurst,
+	}, nil
+}
+
+// AdminReload handles POST /api/admin/reload.
+func (h *Handlers) AdminReload(w http.ResponseWriter, r *http.Request) {
+	values, err := h.reloadRuntimeConfig()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": "reload failed: " + err.Error()})
+		return
+	}
+	h.audit(r, "config.reload", "settings", 0, nil)
+	writeJSON(w, http.StatusOK, map[string]any{"ok": true, "item": values})
+}
+
+// watchReloadSignal reloads the runtime configuration on every SIGHUP until stop is closed.
+func (h *Handlers) watchReloadSignal(stop <-chan struct{}) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGHUP)
+	go func() {
+		defer signal.Stop(ch)
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ch:
+				h.logger("main").Info("SIGHUP received, reloading configuration")
+				if _, err := h.reloadRuntimeConfig(); err != nil {
+					h.logger("main").Error("configuration reload failed", "error", err)
+				}
+			}
+		}
+	}()
+}
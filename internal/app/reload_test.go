@@ -0,0 +1,72 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func TestAdminReload_AppliesStoredSettings(t *testing.T) {
+	db, err := openDatabase(filepath.Join(t.TempDir(), "registry.db"))
+	if err != nil {
+		t.Fatalf("openDatabase failed: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	ms := NewMonitorService(MonitorConfig{DB: db, LogDir: t.TempDir(), DetectConcurrency: 3, MaxParallelTargets: 2})
+	h := &Handlers{db: db, monitor: ms}
+	t.Cleanup(func() {
+		_ = setIPAllowlists("", "")
+		_ = setTrustedProxies("*")
+	})
+
+	if _, err := db.CreateRouteRule("foo-*", "anthropic", 1, true, ""); err != nil {
+		t.Fatalf("CreateRouteRule failed: %v", err)
+	}
+	for key, value := range map[string]string{
+		settingLogMaxSizeMB:        "77",
+		settingCertExpiryWarnDays:  "5",
+		settingLatencyAnomalySigma: "4",
+		settingWriteIPAllowlist:    "10.0.0.0/8",
+		settingDetectConcurrency:   "6",
+	} {
+		if err := db.SetSetting(key, value); err != nil {
+			t.Fatalf("SetSetting failed: %v", err)
+		}
+	}
+	// The environment only seeds the stored setting on first start.
+	t.Setenv("MONITOR_MAX_PARALLEL_TARGETS", "9")
+
+	rec := httptest.NewRecorder()
+	h.AdminReload(rec, httptest.NewRequest(http.MethodPost, "/api/admin/reload", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("reload should succeed, got=%d body=%s", rec.Code, rec.Body.String())
+	}
+	if _, mb := ms.LogCleanupConfig(); mb != 77 {
+		t.Fatalf("log max size should be reloaded, got=%d", mb)
+	}
+	if ms.CertExpiryWarnDays() != 5 {
+		t.Fatalf("cert warn days should be reloaded, got=%d", ms.CertExpiryWarnDays())
+	}
+	if sigma, _ := ms.LatencyAnomalyConfig(); sigma != 4 {
+		t.Fatalf("anomaly sigma should be reloaded, got=%d", sigma)
+	}
+	if detect, parallel := ms.Concurrency(); detect != 6 || parallel != 2 {
+		t.Fatalf("concurrency should follow the stored settings, got=%d/%d", detect, parallel)
+	}
+	if route, _ := ms.resolveRoute("foo-1"); route != "anthropic" {
+		t.Fatalf("route rules should be reloaded, got=%q", route)
+	}
+	if ipAllowlistFor(ipAllowlistWrite).empty() {
+		t.Fatalf("write allowlist should be reloaded")
+	}
+
+	if err := db.SetSetting(settingTrustedProxies, "not-an-ip"); err != nil {
+		t.Fatalf("SetSetting failed: %v", err)
+	}
+	rec = httptest.NewRecorder()
+	h.AdminReload(rec, httptest.NewRequest(http.MethodPost, "/api/admin/reload", nil))
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("invalid stored value should fail the reload, got=%d", rec.Code)
+	}
+}
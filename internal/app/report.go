@@ -0,0 +1,514 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Summary reports. With REPORT_SCHEDULE set to "daily" or "weekly", an HTML summary of
+// the last finished day (or the last week, Monday to Monday UTC) is built from the
+// daily rollups once they cover the period and sent to REPORT_WEBHOOK_URL (as JSON) and
+// / or by email through REPORT_SMTP_ADDR. The summary lists the targets with the worst
+// uptime, models that started failing, latency regressions against the previous period
+// and targets whose upstream reported quota errors. The end of the last sent period is
+// kept in app_settings so a restart does not send it again.
+
+const (
+	reportDaily  = "daily"
+	reportWeekly = "weekly"
+
+	// reportTopN caps each list of a report.
+	reportTopN = 10
+	// A model is newly failing when its uptime falls below reportFailingPct after at
+	// least reportHealthyPct in the previous period.
+	reportFailingPct = 50.0
+	reportHealthyPct = 90.0
+	// A latency regression is an average latency at least reportLatencyRatio times,
+	// and reportLatencyMinDelta seconds above, the previous period's.
+	reportLatencyRatio    = 1.5
+	reportLatencyMinDelta = 0.5
+
+	settingReportLastUntil = "report_last_until"
+)
+
+// ReportTargetUptime is one target's uptime over a report period.
+type ReportTargetUptime struct {
+	TargetID   int     `json:"target_id"`
+	TargetName string  `json:"target_name"`
+	Total      int     `json:"total"`
+	Success    int     `json:"success"`
+	UptimePct  float64 `json:"uptime_pct"`
+}
+
+// ReportModelChange compares one model of one target with the previous period.
+type ReportModelChange struct {
+	TargetID            int      `json:"target_id"`
+	TargetName          string   `json:"target_name"`
+	Model               string   `json:"model"`
+	UptimePct           float64  `json:"uptime_pct"`
+	PreviousUptimePct   float64  `json:"previous_uptime_pct"`
+	AvgDuration         *float64 `json:"avg_duration,omitempty"`
+	PreviousAvgDuration *float64 `json:"previous_avg_duration,omitempty"`
+}
+
+// SummaryReport is the content of one scheduled report.
+type SummaryReport struct {
+	Period             string               `json:"period"`
+	Since              float64              `json:"since"`
+	Until              float64              `json:"until"`
+	GeneratedAt        float64              `json:"generated_at"`
+	Checks             int                  `json:"checks"`
+	UptimePct          float64              `json:"uptime_pct"`
+	WorstUptime        []ReportTargetUptime `json:"worst_uptime"`
+	NewlyFailing       []ReportModelChange  `json:"newly_failing"`
+	LatencyRegressions []ReportModelChange  `json:"latency_regressions"`
+	QuotaWarnings      []ErrorCategoryCount `json:"quota_warnings"`
+}
+
+// reportPeriodEnd returns the end of the last finished report period at now: today's
+// midnight UTC for daily reports, the latest Monday midnight UTC for weekly ones.
+func reportPeriodEnd(period string, now time.Time) time.Time {
+	day := now.UTC().Truncate(24 * time.Hour)
+	if period == reportWeekly {
+		day = day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	}
+	return day
+}
+
+func reportPeriodDays(period string) int {
+	if period == reportWeekly {
+		return 7
+	}
+	return 1
+}
+
+// modelAgg sums the rollups of one model of one target.
+type modelAgg struct {
+	total, success int
+	durSum         float64
+	durWeight      int
+}
+
+func (a *modelAgg) add(s DailyModelStats) {
+	a.total += s.Total
+	a.success += s.Success
+	if s.AvgDuration != nil {
+		a.durSum += *s.AvgDuration * float64(s.Success)
+		a.durWeight += s.Success
+	}
+}
+
+func (a *modelAgg) avgDuration() *float64 {
+	if a == nil || a.durWeight == 0 {
+		return nil
+	}
+	return roundSeconds(ptrTo(a.durSum / float64(a.durWeight)))
+}
+
+type modelKey struct {
+	targetID int
+	model    string
+}
+
+func aggregateRollups(stats []DailyModelStats) map[modelKey]*modelAgg {
+	out := map[modelKey]*modelAgg{}
+	for _, s := range stats {
+		k := modelKey{s.TargetID, s.Model}
+		if out[k] == nil {
+			out[k] = &modelAgg{}
+		}
+		out[k].add(s)
+	}
+	return out
+}
+
+// BuildSummaryReport builds the report of the period ending at until from the daily
+// rollups of that period and the one before it.
+func (d *Database) BuildSummaryReport(period string, until time.Time) (*SummaryReport, error) {
+	span := float64(reportPeriodDays(period) * rollupDaySeconds)
+	end := float64(until.Unix())
+	since := end - span
+	current, err := d.ListDailyStats(since, end, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	previous, err := d.ListDailyStats(since-span, since, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	targets, err := d.ListTargets()
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[int]string, len(targets))
+	for _, t := range targets {
+		names[t.ID] = t.Name
+	}
+
+	report := &SummaryReport{
+		Period:             period,
+		Since:              since,
+		Until:              end,
+		GeneratedAt:        float64(time.Now().UnixMilli()) / 1000.0,
+		WorstUptime:        []ReportTargetUptime{},
+		NewlyFailing:       []ReportModelChange{},
+		LatencyRegressions: []ReportModelChange{},
+		QuotaWarnings:      []ErrorCategoryCount{},
+	}
+	cur, prev := aggregateRollups(current), aggregateRollups(previous)
+	byTarget := map[int]*ReportTargetUptime{}
+	success := 0
+	for k, a := range cur {
+		report.Checks += a.total
+		success += a.success
+		t := byTarget[k.targetID]
+		if t == nil {
+			t = &ReportTargetUptime{TargetID: k.targetID, TargetName: names[k.targetID]}
+			byTarget[k.targetID] = t
+		}
+		t.Total += a.total
+		t.Success += a.success
+
+		p := prev[k]
+		if p == nil || p.total == 0 {
+			continue
+		}
+		change := ReportModelChange{
+			TargetID:            k.targetID,
+			TargetName:          names[k.targetID],
+			Model:               k.model,
+			UptimePct:           percentRate(a.success, a.total),
+			PreviousUptimePct:   percentRate(p.success, p.total),
+			AvgDuration:         a.avgDuration(),
+			PreviousAvgDuration: p.avgDuration(),
+		}
+		if change.UptimePct < reportFailingPct && change.PreviousUptimePct >= reportHealthyPct {
+			report.NewlyFailing = append(report.NewlyFailing, change)
+		}
+		if c, pv := change.AvgDuration, change.PreviousAvgDuration; c != nil && pv != nil && *pv > 0 &&
+			*c >= *pv*reportLatencyRatio && *c-*pv >= reportLatencyMinDelta {
+			report.LatencyRegressions = append(report.LatencyRegressions, change)
+		}
+	}
+	report.UptimePct = percentRate(success, report.Checks)
+
+	for _, t := range byTarget {
+		t.UptimePct = percentRate(t.Success, t.Total)
+		if t.Success < t.Total {
+			report.WorstUptime = append(report.WorstUptime, *t)
+		}
+	}
+	sort.Slice(report.WorstUptime, func(i, j int) bool {
+		a, b := report.WorstUptime[i], report.WorstUptime[j]
+		if a.UptimePct != b.UptimePct {
+			return a.UptimePct < b.UptimePct
+		}
+		return a.TargetID < b.TargetID
+	})
+	sort.Slice(report.NewlyFailing, func(i, j int) bool {
+		a, b := report.NewlyFailing[i], report.NewlyFailing[j]
+		if a.UptimePct != b.UptimePct {
+			return a.UptimePct < b.UptimePct
+		}
+		return a.TargetID < b.TargetID || (a.TargetID == b.TargetID && a.Model < b.Model)
+	})
+	sort.Slice(report.LatencyRegressions, func(i, j int) bool {
+		a, b := report.LatencyRegressions[i], report.LatencyRegressions[j]
+		return *a.AvgDuration / *a.PreviousAvgDuration > *b.AvgDuration / *b.PreviousAvgDuration
+	})
+	report.WorstUptime = report.WorstUptime[:min(len(report.WorstUptime), reportTopN)]
+	report.NewlyFailing = report.NewlyFailing[:min(len(report.NewlyFailing), reportTopN)]
+	report.LatencyRegressions = report.LatencyRegressions[:min(len(report.LatencyRegressions), reportTopN)]
+
+	categories, err := d.GetErrorCategories(since, end, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range categories {
+		if c.Category == errorCategoryQuotaExceeded && len(report.QuotaWarnings) < reportTopN {
+			report.QuotaWarnings = append(report.QuotaWarnings, c)
+		}
+	}
+	return report, nil
+}
+
+// Subject is the report's email subject and webhook title.
+func (r *SummaryReport) Subject() string {
+	day := func(ts float64) string { return time.Unix(int64(ts), 0).UTC().Format("2006-01-02") }
+	if r.Period == reportWeekly {
+		return fmt.Sprintf("API monitor weekly report %s – %s", day(r.Since), day(r.Until-1))
+	}
+	return "API monitor daily report " + day(r.Since)
+}
+
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"seconds": func(v *float64) string {
+		if v == nil {
+			return "-"
+		}
+		return strconv.FormatFloat(*v, 'f', 3, 64) + "s"
+	},
+}).Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>{{.Subject}}</title></head>
+<body style="font-family:-apple-system,Segoe UI,Helvetica,Arial,sans-serif;color:#18181b">
+<h2>{{.Subject}}</h2>
+<p>{{.Checks}} checks, {{.UptimePct}}% successful.</p>
+<h3>Worst uptime</h3>
+{{if .WorstUptime}}<table cellpadding="4" border="1" style="border-collapse:collapse">
+<tr><th>Target</th><th>Uptime</th><th>Checks</th></tr>
+{{range .WorstUptime}}<tr><td>{{.TargetName}}</td><td>{{.UptimePct}}%</td><td>{{.Success}} / {{.Total}}</td></tr>
+{{end}}</table>{{else}}<p>Every target was up for the whole period.</p>{{end}}
+<h3>Newly failing models</h3>
+{{if .NewlyFailing}}<table cellpadding="4" border="1" style="border-collapse:collapse">
+<tr><th>Target</th><th>Model</th><th>Uptime</th><th>Before</th></tr>
+{{range .NewlyFailing}}<tr><td>{{.TargetName}}</td><td>{{.Model}}</td><td>{{.UptimePct}}%</td><td>{{.PreviousUptimePct}}%</td></tr>
+{{end}}</table>{{else}}<p>None.</p>{{end}}
+<h3>Latency regressions</h3>
+{{if .LatencyRegressions}}<table cellpadding="4" border="1" style="border-collapse:collapse">
+<tr><th>Target</th><th>Model</th><th>Avg latency</th><th>Before</th></tr>
+{{range .LatencyRegressions}}<tr><td>{{.TargetName}}</td><td>{{.Model}}</td><td>{{seconds .AvgDuration}}</td><td>{{seconds .PreviousAvgDuration}}</td></tr>
+{{end}}</table>{{else}}<p>None.</p>{{end}}
+<h3>Quota warnings</h3>
+{{if .QuotaWarnings}}<table cellpadding="4" border="1" style="border-collapse:collapse">
+<tr><th>Target</th><th>Quota errors</th></tr>
+{{range .QuotaWarnings}}<tr><td>{{.TargetName}}</td><td>{{.Count}}</td></tr>
+{{end}}</table>{{else}}<p>None.</p>{{end}}
+</body></html>
+`))
+
+// HTML renders the report.
+func (r *SummaryReport) HTML() (string, error) {
+	var buf bytes.Buffer
+	if err := reportTemplate.Execute(&buf, r); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+type reportSender struct {
+	period     string
+	webhookURL string
+	smtpAddr   string
+	smtpUser   string
+	smtpPass   string
+	from       string
+	to         []string
+	client     *http.Client
+	log        *slog.Logger
+}
+
+var reportSenderInstance atomic.Pointer[reportSender]
+
+// reportSenderFromEnv builds the sender configured by the REPORT_* environment, or
+// returns nil when REPORT_SCHEDULE is unset.
+func reportSenderFromEnv() (*reportSender, error) {
+	period := strings.ToLower(strings.TrimSpace(os.Getenv("REPORT_SCHEDULE")))
+	if period == "" {
+		return nil, nil
+	}
+	if period != reportDaily && period != reportWeekly {
+		return nil, fmt.Errorf("REPORT_SCHEDULE must be daily or weekly, got %q", period)
+	}
+	s := &reportSender{
+		period:     period,
+		webhookURL: strings.TrimSpace(os.Getenv("REPORT_WEBHOOK_URL")),
+		smtpAddr:   strings.TrimSpace(os.Getenv("REPORT_SMTP_ADDR")),
+		smtpUser:   strings.TrimSpace(os.Getenv("REPORT_SMTP_USERNAME")),
+		smtpPass:   os.Getenv("REPORT_SMTP_PASSWORD"),
+		from:       strings.TrimSpace(os.Getenv("REPORT_EMAIL_FROM")),
+		client:     &http.Client{Timeout: 15 * time.Second},
+	}
+	for _, addr := range strings.Split(os.Getenv("REPORT_EMAIL_TO"), ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			s.to = append(s.to, addr)
+		}
+	}
+	if s.webhookURL != "" {
+		if u, err := url.Parse(s.webhookURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("REPORT_WEBHOOK_URL must be an http(s) URL, got %q", s.webhookURL)
+		}
+	}
+	if s.smtpAddr != "" && (s.from == "" || len(s.to) == 0) {
+		return nil, fmt.Errorf("REPORT_SMTP_ADDR needs REPORT_EMAIL_FROM and REPORT_EMAIL_TO")
+	}
+	if s.webhookURL == "" && s.smtpAddr == "" {
+		return nil, fmt.Errorf("REPORT_SCHEDULE needs REPORT_WEBHOOK_URL or REPORT_SMTP_ADDR")
+	}
+	return s, nil
+}
+
+// startReports installs the report sender configured by the environment. It is a
+// no-op when REPORT_SCHEDULE is unset.
+func startReports(logger *slog.Logger) error {
+	s, err := reportSenderFromEnv()
+	if err != nil || s == nil {
+		return err
+	}
+	s.log = componentLogger(logger, "report")
+	reportSenderInstance.Store(s)
+	s.log.Info("summary reports enabled", "schedule", s.period, "webhook", s.webhookURL != "", "email", len(s.to))
+	return nil
+}
+
+// Send delivers the report to every configured destination.
+func (s *reportSender) Send(ctx context.Context, report *SummaryReport) error {
+	body, err := report.HTML()
+	if err != nil {
+		return err
+	}
+	var errs []string
+	if s.webhookURL != "" {
+		if err := s.postWebhook(ctx, report, body); err != nil {
+			errs = append(errs, "webhook: "+err.Error())
+		}
+	}
+	if s.smtpAddr != "" {
+		if err := s.sendEmail(report.Subject(), body); err != nil {
+			errs = append(errs, "email: "+err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("send report: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func (s *reportSender) postWebhook(ctx context.Context, report *SummaryReport, body string) error {
+	payload, _ := json.Marshal(map[string]any{
+		"subject": report.Subject(),
+		"html":    body,
+		"report":  report,
+	})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.webhookURL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (s *reportSender) sendEmail(subject, body string) error {
+	return sendSMTPMail(s.smtpAddr, s.smtpUser, s.smtpPass, s.from, s.to, subject, "text/html", body)
+}
+
+// maybeSendReport sends the report of the last finished period when reports are on,
+// the period is rolled up and it has not been sent yet.
+func (ms *MonitorService) maybeSendReport() {
+	s := reportSenderInstance.Load()
+	if s == nil {
+		return
+	}
+	end := reportPeriodEnd(s.period, time.Now().Add(-rollupDelay))
+	pending, err := ms.db.firstPendingDay()
+	if err != nil || math.IsInf(pending, 1) || pending < float64(end.Unix()) {
+		return
+	}
+	last, _, err := ms.db.GetSetting(settingReportLastUntil)
+	if err != nil {
+		s.log.Warn("read last report failed", "error", err)
+		return
+	}
+	if v, err := strconv.ParseInt(last, 10, 64); err == nil && v >= end.Unix() {
+		return
+	}
+	report, err := ms.db.BuildSummaryReport(s.period, end)
+	if err != nil {
+		s.log.Error("build report failed", "error", err)
+		return
+	}
+	if err := s.Send(context.Background(), report); err != nil {
+		s.log.Error("report delivery failed", "error", err)
+		return
+	}
+	if err := ms.db.SetSetting(settingReportLastUntil, strconv.FormatInt(end.Unix(), 10)); err != nil {
+		s.log.Warn("store last report failed", "error", err)
+	}
+	s.log.Info("summary report sent", "period", s.period, "until", end.Format(time.RFC3339))
+}
+
+// reportRequestPeriod reads ?period=daily|weekly, defaulting to the configured schedule.
+func reportRequestPeriod(r *http.Request) (string, bool) {
+	period := r.URL.Query().Get("period")
+	if period == "" {
+		period = reportDaily
+		if s := reportSenderInstance.Load(); s != nil {
+			period = s.period
+		}
+	}
+	return period, period == reportDaily || period == reportWeekly
+}
+
+// AdminPreviewReport -- GET /api/admin/reports/preview?period=daily|weekly&format=html|json
+// Renders the report of the last finished period without sending it.
+func (h *Handlers) AdminPreviewReport(w http.ResponseWriter, r *http.Request) {
+	period, ok := reportRequestPeriod(r)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "period must be daily or weekly"})
+		return
+	}
+	report, err := h.db.BuildSummaryReport(period, reportPeriodEnd(period, time.Now()))
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return
+	}
+	if r.URL.Query().Get("format") == "json" {
+		writeJSON(w, http.StatusOK, map[string]any{"item": report})
+		return
+	}
+	body, err := report.HTML()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = io.WriteString(w, body)
+}
+
+// AdminSendReport -- POST /api/admin/reports/send?period=daily|weekly
+// Sends the report of the last finished period now, whether or not it was sent before.
+func (h *Handlers) AdminSendReport(w http.ResponseWriter, r *http.Request) {
+	s := reportSenderInstance.Load()
+	if s == nil {
+		writeJSON(w, http.StatusConflict, map[string]any{"detail": "summary reports are not configured (set REPORT_SCHEDULE)"})
+		return
+	}
+	period, ok := reportRequestPeriod(r)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "period must be daily or weekly"})
+		return
+	}
+	report, err := h.db.BuildSummaryReport(period, reportPeriodEnd(period, time.Now()))
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return
+	}
+	if err := s.Send(r.Context(), report); err != nil {
+		writeJSON(w, http.StatusBadGateway, map[string]any{"detail": err.Error()})
+		return
+	}
+	h.audit(r, "report.send", "settings", 0, nil)
+	writeJSON(w, http.StatusOK, map[string]any{"item": report})
+}
@@ -0,0 +1,91 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReportPeriodEnd(t *testing.T) {
+	now := time.Date(2026, 10, 14, 9, 30, 0, 0, time.UTC) // a Wednesday
+	if got := reportPeriodEnd(reportDaily, now); !got.Equal(time.Date(2026, 10, 14, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("daily period should end at midnight, got=%s", got)
+	}
+	if got := reportPeriodEnd(reportWeekly, now); !got.Equal(time.Date(2026, 10, 12, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("weekly period should end on Monday, got=%s", got)
+	}
+}
+
+func TestBuildSummaryReport(t *testing.T) {
+	db, target := newAnalyticsTestDB(t)
+	end := time.Date(2026, 10, 14, 0, 0, 0, 0, time.UTC)
+	today, yesterday := float64(end.Unix())-rollupDaySeconds, float64(end.Unix())-2*rollupDaySeconds
+	quota := "HTTP 429: You exceeded your current quota"
+	insertAnalyticsRows(t, db, target.ID, []DetectionResult{
+		{Model: "steady", Success: true, Duration: 1, Timestamp: yesterday + 1},
+		{Model: "steady", Success: true, Duration: 1, Timestamp: today + 1},
+		{Model: "broken", Success: true, Duration: 1, Timestamp: yesterday + 2},
+		{Model: "broken", Success: false, Duration: 1, Timestamp: today + 2, Error: &quota, ErrorCategory: errorCategoryQuotaExceeded},
+		{Model: "slow", Success: true, Duration: 1, Timestamp: yesterday + 3},
+		{Model: "slow", Success: true, Duration: 3, Timestamp: today + 3},
+	})
+	for _, day := range []float64{yesterday, today} {
+		if err := db.RollupDay(day); err != nil {
+			t.Fatalf("RollupDay failed: %v", err)
+		}
+	}
+
+	report, err := db.BuildSummaryReport(reportDaily, end)
+	if err != nil {
+		t.Fatalf("BuildSummaryReport failed: %v", err)
+	}
+	if report.Checks != 3 || len(report.WorstUptime) != 1 || report.WorstUptime[0].TargetName != "t1" {
+		t.Fatalf("report should cover the last day only, got=%+v", report)
+	}
+	if len(report.NewlyFailing) != 1 || report.NewlyFailing[0].Model != "broken" {
+		t.Fatalf("broken should be newly failing, got=%+v", report.NewlyFailing)
+	}
+	if len(report.LatencyRegressions) != 1 || report.LatencyRegressions[0].Model != "slow" {
+		t.Fatalf("slow should be a latency regression, got=%+v", report.LatencyRegressions)
+	}
+	if len(report.QuotaWarnings) != 1 || report.QuotaWarnings[0].Count != 1 {
+		t.Fatalf("quota errors should be reported, got=%+v", report.QuotaWarnings)
+	}
+	html, err := report.HTML()
+	if err != nil || !strings.Contains(html, "broken") || !strings.Contains(html, "3.000s") {
+		t.Fatalf("HTML should list the findings, got err=%v html=%s", err, html)
+	}
+}
+
+func TestReportSenderPostsWebhook(t *testing.T) {
+	var got map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(body, &got)
+	}))
+	defer srv.Close()
+	t.Setenv("REPORT_SCHEDULE", "weekly")
+	t.Setenv("REPORT_WEBHOOK_URL", srv.URL)
+	s, err := reportSenderFromEnv()
+	if err != nil || s == nil || s.period != reportWeekly {
+		t.Fatalf("reportSenderFromEnv should accept a webhook, got=%+v err=%v", s, err)
+	}
+
+	report := &SummaryReport{Period: reportWeekly, Since: 1760313600, Until: 1760918400}
+	if err := s.Send(context.Background(), report); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if got["subject"] != "API monitor weekly report 2025-10-13 – 2025-10-19" || !strings.Contains(got["html"].(string), "<h2>") {
+		t.Fatalf("webhook should receive the subject and HTML, got=%v", got)
+	}
+
+	t.Setenv("REPORT_WEBHOOK_URL", "")
+	if _, err := reportSenderFromEnv(); err == nil {
+		t.Fatalf("a schedule without a destination should be rejected")
+	}
+}
@@ -0,0 +1,146 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Request bodies. Every /api request body is capped by bodyLimitMiddleware, readJSON
+// rejects bodies with unknown fields (for typed requests) or trailing data, and
+// validation failures of a single field are answered as
+// {"detail": "...", "errors": [{"field": "...", "message": "..."}]}.
+
+const (
+	// apiBodyMaxBytes caps /api request bodies.
+	apiBodyMaxBytes = 2 << 20
+	// agentResultsMaxBytes caps agent result reports, which carry a whole run.
+	agentResultsMaxBytes = 32 << 20
+)
+
+// bodyLimitMiddleware caps the request body of /api routes; reads past the limit fail
+// with *http.MaxBytesError. Proxy endpoints enforce their own limit.
+func bodyLimitMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Body != nil && strings.HasPrefix(r.URL.Path, "/api/") {
+			limit := int64(apiBodyMaxBytes)
+			if r.URL.Path == "/api/agent/results" {
+				limit = agentResultsMaxBytes
+			}
+			if r.ContentLength > limit {
+				writeJSON(w, http.StatusRequestEntityTooLarge, map[string]any{"detail": bodyTooLargeDetail(limit)})
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, limit)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func bodyTooLargeDetail(limit int64) string {
+	return "request body too large (limit " + strconv.FormatInt(limit, 10) + " bytes)"
+}
+
+// fieldError is a validation failure of one request field.
+type fieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (e *fieldError) Error() string { return e.Message }
+
+// fieldErrorf returns a fieldError for field; the message should name the field.
+func fieldErrorf(field, format string, args ...any) error {
+	return &fieldError{Field: field, Message: fmt.Sprintf(format, args...)}
+}
+
+// asFieldError attributes err to field unless it already names one.
+func asFieldError(field string, err error) error {
+	var fe *fieldError
+	if err == nil || errors.As(err, &fe) {
+		return err
+	}
+	return &fieldError{Field: field, Message: err.Error()}
+}
+
+// writeValidationError answers 400 with err as the detail, listing the offending field
+// when err is a fieldError.
+func writeValidationError(w http.ResponseWriter, err error) {
+	body := map[string]any{"detail": err.Error()}
+	var fe *fieldError
+	if errors.As(err, &fe) {
+		body["errors"] = []*fieldError{fe}
+	}
+	writeJSON(w, http.StatusBadRequest, body)
+}
+
+// decodeJSONBody decodes exactly one JSON value from body into target. Struct targets
+// reject unknown fields; map targets accept any key.
+func decodeJSONBody(body io.Reader, target any) error {
+	dec := json.NewDecoder(body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(target); err != nil {
+		return err
+	}
+	if err := dec.Decode(&json.RawMessage{}); err != io.EOF {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return err
+		}
+		return errors.New("unexpected data after the JSON body")
+	}
+	return nil
+}
+
+// writeRequestBodyError answers a readJSON failure: 413 for an oversized body, 400 with
+// the offending field for unknown fields and type mismatches, else 400 "invalid JSON".
+func writeRequestBodyError(w http.ResponseWriter, err error) {
+	var tooLarge *http.MaxBytesError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &tooLarge):
+		writeJSON(w, http.StatusRequestEntityTooLarge, map[string]any{"detail": bodyTooLargeDetail(tooLarge.Limit)})
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		writeValidationError(w, fieldErrorf(typeErr.Field, "%s must be %s", typeErr.Field, jsonTypeName(typeErr.Type.Kind().String())))
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field, _ := strconv.Unquote(strings.TrimPrefix(err.Error(), "json: unknown field "))
+		writeValidationError(w, fieldErrorf(field, "unknown field %q", field))
+	default:
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "invalid JSON"})
+	}
+}
+
+// jsonTypeName names a Go kind the way a JSON client sees it.
+func jsonTypeName(kind string) string {
+	switch {
+	case kind == "bool":
+		return "a boolean"
+	case kind == "string":
+		return "a string"
+	case strings.HasPrefix(kind, "int"), strings.HasPrefix(kind, "uint"):
+		return "an integer"
+	case strings.HasPrefix(kind, "float"):
+		return "a number"
+	case kind == "slice", kind == "array":
+		return "an array"
+	default:
+		return "an object"
+	}
+}
+
+// validateHTTPURL checks that s parses as an absolute http(s) URL with a host.
+func validateHTTPURL(field, s string) error {
+	u, err := url.Parse(s)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fieldErrorf(field, "%s must be an http(s) URL with a host", field)
+	}
+	if u.Hostname() == "" {
+		return fieldErrorf(field, "%s must be an http(s) URL with a host", field)
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package app
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBodyLimitMiddlewareRejectsLargeBodies(t *testing.T) {
+	handler := bodyLimitMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]any
+		if err := readJSON(r, &payload); err != nil {
+			writeRequestBodyError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	big := `{"x":"` + strings.Repeat("a", apiBodyMaxBytes) + `"}`
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/targets", strings.NewReader(big)))
+	if rr.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("oversized body should be rejected, got status=%d", rr.Code)
+	}
+
+	// Without a Content-Length the limit is hit while decoding.
+	req := httptest.NewRequest(http.MethodPost, "/api/targets", io.MultiReader(strings.NewReader(big)))
+	req.ContentLength = -1
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("streamed oversized body should be rejected, got status=%d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/agent/results", strings.NewReader(big)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("agent results should have a larger limit, got status=%d", rr.Code)
+	}
+}
+
+func TestReadJSONReportsOffendingField(t *testing.T) {
+	decode := func(body string) map[string]any {
+		t.Helper()
+		var payload struct {
+			Name  string `json:"name"`
+			Limit int    `json:"limit"`
+		}
+		req := httptest.NewRequest(http.MethodPost, "/api/x", strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		if err := readJSON(req, &payload); err != nil {
+			writeRequestBodyError(rr, err)
+		}
+		out := map[string]any{"status": float64(rr.Code)}
+		_ = json.Unmarshal(rr.Body.Bytes(), &out)
+		return out
+	}
+	fieldOf := func(out map[string]any) string {
+		errs, _ := out["errors"].([]any)
+		if len(errs) != 1 {
+			return ""
+		}
+		field, _ := errs[0].(map[string]any)["field"].(string)
+		return field
+	}
+
+	if out := decode(`{"name":"a","limit":1}`); out["status"] != float64(http.StatusOK) {
+		t.Fatalf("valid body should decode, got=%v", out)
+	}
+	if out := decode(`{"name":"a","colour":"red"}`); out["status"] != float64(http.StatusBadRequest) || fieldOf(out) != "colour" {
+		t.Fatalf("unknown field should be named, got=%v", out)
+	}
+	if out := decode(`{"limit":"ten"}`); fieldOf(out) != "limit" || out["detail"] != "limit must be an integer" {
+		t.Fatalf("type mismatch should name the field, got=%v", out)
+	}
+	if out := decode(`{"name":"a"} {"name":"b"}`); out["status"] != float64(http.StatusBadRequest) || out["errors"] != nil {
+		t.Fatalf("trailing data should be rejected, got=%v", out)
+	}
+}
+
+func TestCreateTargetRejectsNonHTTPURLs(t *testing.T) {
+	db, err := NewDatabase(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDatabase failed: %v", err)
+	}
+	t.Cleanup(func() { _ = db.conn.Close() })
+	h := &Handlers{db: db, monitor: NewMonitorService(MonitorConfig{DB: db, LogDir: t.TempDir()})}
+
+	for _, tc := range []struct{ body, field string }{
+		{`{"name":"a","base_url":"ftp://example.com","api_key":"k"}`, "base_url"},
+		{`{"name":"a","base_url":"example.com","api_key":"k"}`, "base_url"},
+		{`{"name":"a","base_url":"https://example.com","api_key":"k","source_url":"not a url"}`, "source_url"},
+	} {
+		req := withAuthRole(httptest.NewRequest(http.MethodPost, "/api/targets", strings.NewReader(tc.body)), authRoleAdmin)
+		rr := httptest.NewRecorder()
+		h.CreateTarget(rr, req)
+		var out struct {
+			Errors []fieldError `json:"errors"`
+		}
+		_ = json.Unmarshal(rr.Body.Bytes(), &out)
+		if rr.Code != http.StatusBadRequest || len(out.Errors) != 1 || out.Errors[0].Field != tc.field {
+			t.Fatalf("%s should be rejected on %s, got status=%d body=%s", tc.body, tc.field, rr.Code, rr.Body.String())
+		}
+	}
+}
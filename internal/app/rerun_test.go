@@ -0,0 +1,154 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestTriggerManualFailedOnlyOverlaysLatestRun(t *testing.T) {
+	var mu sync.Mutex
+	probed := map[string]int{}
+	healthy := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/models" {
+			_, _ = w.Write([]byte(`{"data":[{"id":"gpt-a"},{"id":"gpt-b"},{"id":"gpt-c"}]}`))
+			return
+		}
+		var body struct {
+			Model string `json:"model"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		mu.Lock()
+		probed[body.Model]++
+		fail := body.Model == "gpt-b" && !healthy
+		mu.Unlock()
+		if fail {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte(`{"error":{"message":"upstream overloaded"}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"choices":[{"message":{"content":"ok"}}]}`))
+	}))
+	defer srv.Close()
+
+	db, err := NewDatabase(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDatabase failed: %v", err)
+	}
+	t.Cleanup(func() { _ = db.conn.Close() })
+	ms := NewMonitorService(MonitorConfig{DB: db, LogDir: t.TempDir()})
+	target, err := db.CreateTarget(map[string]any{"name": "relay", "base_url": srv.URL, "api_key": "k"})
+	if err != nil {
+		t.Fatalf("CreateTarget failed: %v", err)
+	}
+
+	if ok, msg := ms.TriggerManual(target.ID, runPlan{mode: runModeFailedOnly}); ok {
+		t.Fatalf("target without runs should have nothing to re-run, got msg=%s", msg)
+	}
+	if ok, msg := ms.TriggerTarget(target.ID, true); !ok {
+		t.Fatalf("TriggerTarget failed: %s", msg)
+	}
+	ms.WaitDetections()
+	if got, _ := db.GetTarget(target.ID); got.LastStatus == nil || *got.LastStatus != "degraded" {
+		t.Fatalf("full run with one failure should be degraded, got=%v", got.LastStatus)
+	}
+
+	mu.Lock()
+	healthy = true
+	probed = map[string]int{}
+	mu.Unlock()
+	if ok, msg := ms.TriggerManual(target.ID, runPlan{mode: runModeFailedOnly}); !ok {
+		t.Fatalf("TriggerManual failed: %s", msg)
+	}
+	ms.WaitDetections()
+	if len(probed) != 1 || probed["gpt-b"] != 1 {
+		t.Fatalf("only the failed model should be re-probed, got=%v", probed)
+	}
+
+	runs, _ := db.ListRuns(target.ID, 10)
+	if len(runs) != 2 || runs[0].Mode != runModeFailedOnly || runs[0].Total != 1 || runs[1].Mode != runModeFull {
+		t.Fatalf("re-run should be stored as a partial run, got=%+v", runs)
+	}
+	statuses, _ := db.GetLatestModelStatuses(target.ID)
+	if len(statuses) != 3 {
+		t.Fatalf("latest statuses should overlay the partial run on the full run, got=%+v", statuses)
+	}
+	for _, s := range statuses {
+		if !s.Success {
+			t.Fatalf("re-probed model should replace its failed row, got=%+v", s)
+		}
+	}
+	got, _ := db.GetTarget(target.ID)
+	if got.LastStatus == nil || *got.LastStatus != "healthy" || got.LastTotal == nil || *got.LastTotal != 3 {
+		t.Fatalf("target status should reflect the merged results, got status=%v total=%v", got.LastStatus, got.LastTotal)
+	}
+}
+
+func TestRunTargetModelSubset(t *testing.T) {
+	var mu sync.Mutex
+	var listed bool
+	probed := map[string]int{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/models" {
+			mu.Lock()
+			listed = true
+			mu.Unlock()
+			_, _ = w.Write([]byte(`{"data":[{"id":"gpt-a"}]}`))
+			return
+		}
+		var body struct {
+			Model string `json:"model"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		mu.Lock()
+		probed[body.Model]++
+		mu.Unlock()
+		_, _ = w.Write([]byte(`{"choices":[{"message":{"content":"ok"}}]}`))
+	}))
+	defer srv.Close()
+
+	db, err := NewDatabase(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDatabase failed: %v", err)
+	}
+	t.Cleanup(func() { _ = db.conn.Close() })
+	if err := db.EnsureAuditSchema(); err != nil {
+		t.Fatalf("EnsureAuditSchema failed: %v", err)
+	}
+	h := &Handlers{db: db, monitor: NewMonitorService(MonitorConfig{DB: db, LogDir: t.TempDir()})}
+	target, err := db.CreateTarget(map[string]any{"name": "relay", "base_url": srv.URL, "api_key": "k", "selected_models": []string{"gpt-a"}})
+	if err != nil {
+		t.Fatalf("CreateTarget failed: %v", err)
+	}
+
+	run := func(query, body string) int {
+		req := httptest.NewRequest(http.MethodPost, "/api/targets/1/run"+query, strings.NewReader(body))
+		req.SetPathValue("id", strconv.Itoa(target.ID))
+		rr := httptest.NewRecorder()
+		h.RunTarget(rr, withAuthRole(req, authRoleAdmin))
+		h.monitor.WaitDetections()
+		return rr.Code
+	}
+
+	if code := run("?mode=failed_only", `{"models":["gpt-b"]}`); code != http.StatusBadRequest {
+		t.Fatalf("models with another mode should be rejected, got=%d", code)
+	}
+	if code := run("", `{"models":[" "]}`); code != http.StatusBadRequest {
+		t.Fatalf("empty model list should be rejected, got=%d", code)
+	}
+	if code := run("", `{"models":["claude-3-5-sonnet","gpt-b","gpt-b"]}`); code != http.StatusOK {
+		t.Fatalf("subset run should start, got=%d", code)
+	}
+	if listed || len(probed) != 2 || probed["gpt-b"] != 1 || probed["claude-3-5-sonnet"] != 1 {
+		t.Fatalf("subset run should probe exactly the requested models, listed=%v probed=%v", listed, probed)
+	}
+	if runs, _ := db.ListRuns(target.ID, 10); len(runs) != 1 || runs[0].Mode != runModeSubset || runs[0].Total != 2 {
+		t.Fatalf("subset run should be stored as a partial run, got=%+v", runs)
+	}
+}
@@ -3,6 +3,7 @@ package app
 import (
 	"errors"
 	"fmt"
+	"io/fs"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -16,9 +17,45 @@ const cgroupUnlimitedThreshold = uint64(1 << 60)
 
 var cgroupFSRoot = "/sys/fs/cgroup"
 
+// recentGCPauses is how many of the latest GC pauses are reported.
+const recentGCPauses = 16
+
 type adminResourcesResponse struct {
 	SampleTimeMs int64                   `json:"sample_time_ms"`
 	Container    adminContainerResources `json:"container"`
+	Runtime      adminRuntimeResources   `json:"runtime"`
+	Storage      *adminStorageResources  `json:"storage,omitempty"`
+}
+
+// adminStorageResources describe the data directory and database growth.
+type adminStorageResources struct {
+	DataDir        string           `json:"data_dir"`
+	DiskTotalBytes *uint64          `json:"disk_total_bytes"`
+	DiskFreeBytes  *uint64          `json:"disk_free_bytes"`
+	DataDirBytes   int64            `json:"data_dir_bytes"`
+	DatabaseBytes  int64            `json:"database_bytes"`
+	WALBytes       int64            `json:"wal_bytes"`
+	LogDir         string           `json:"log_dir"`
+	LogDirBytes    int64            `json:"log_dir_bytes"`
+	LogFiles       int              `json:"log_files"`
+	TableRows      map[string]int64 `json:"table_rows"`
+	Detail         string           `json:"detail,omitempty"`
+}
+
+// adminRuntimeResources are Go runtime statistics of this process.
+type adminRuntimeResources struct {
+	GoVersion       string    `json:"go_version"`
+	GOMAXPROCS      int       `json:"gomaxprocs"`
+	Goroutines      int       `json:"goroutines"`
+	HeapAllocBytes  uint64    `json:"heap_alloc_bytes"`
+	HeapInuseBytes  uint64    `json:"heap_inuse_bytes"`
+	HeapSysBytes    uint64    `json:"heap_sys_bytes"`
+	HeapObjects     uint64    `json:"heap_objects"`
+	SysBytes        uint64    `json:"sys_bytes"`
+	NumGC           uint32    `json:"num_gc"`
+	GCPauseTotalMs  float64   `json:"gc_pause_total_ms"`
+	LastGCTimeMs    int64     `json:"last_gc_time_ms"`
+	RecentGCPauseMs []float64 `json:"recent_gc_pauses_ms"`
 }
 
 type adminContainerResources struct {
@@ -33,7 +70,82 @@ type adminContainerResources struct {
 
 // AdminGetResources handles GET /api/admin/resources
 func (h *Handlers) AdminGetResources(w http.ResponseWriter, r *http.Request) {
-	writeJSON(w, http.StatusOK, collectAdminResourcesSnapshot(time.Now()))
+	resp := collectAdminResourcesSnapshot(time.Now())
+	if h.db != nil {
+		logDir := ""
+		if h.monitor != nil {
+			logDir = h.monitor.logDir
+		}
+		storage := collectStorageResources(h.db, logDir)
+		resp.Storage = &storage
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// collectStorageResources measures the database files, the log directory and the
+// filesystem holding the data directory. Partial failures are reported in Detail.
+func collectStorageResources(db *Database, logDir string) adminStorageResources {
+	dataDir := filepath.Dir(db.path)
+	out := adminStorageResources{DataDir: dataDir, LogDir: logDir, TableRows: map[string]int64{}}
+	var problems []string
+
+	if total, free, err := diskUsage(dataDir); err != nil {
+		problems = append(problems, "disk usage: "+err.Error())
+	} else {
+		out.DiskTotalBytes = &total
+		out.DiskFreeBytes = &free
+	}
+	if fi, err := os.Stat(db.path); err == nil {
+		out.DatabaseBytes = fi.Size()
+	}
+	if fi, err := os.Stat(db.path + "-wal"); err == nil {
+		out.WALBytes = fi.Size()
+	}
+	if size, _, err := dirSize(dataDir); err != nil {
+		problems = append(problems, "data dir: "+err.Error())
+	} else {
+		out.DataDirBytes = size
+	}
+	if logDir != "" {
+		if size, files, err := dirSize(logDir); err != nil {
+			problems = append(problems, "log dir: "+err.Error())
+		} else {
+			out.LogDirBytes = size
+			out.LogFiles = files
+		}
+	}
+	if counts, err := db.TableRowCounts(); err != nil {
+		problems = append(problems, "row counts: "+err.Error())
+	} else {
+		out.TableRows = counts
+	}
+	out.Detail = strings.Join(problems, "; ")
+	return out
+}
+
+// dirSize sums the sizes of the regular files under root.
+func dirSize(root string) (int64, int, error) {
+	var total int64
+	files := 0
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) && path != root {
+				return nil // removed by log cleanup mid-walk
+			}
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		total += info.Size()
+		files++
+		return nil
+	})
+	return total, files, err
 }
 
 func collectAdminResourcesSnapshot(now time.Time) adminResourcesResponse {
@@ -43,6 +155,7 @@ func collectAdminResourcesSnapshot(now time.Time) adminResourcesResponse {
 			Available:     false,
 			CgroupVersion: 0,
 		},
+		Runtime: collectRuntimeResources(),
 	}
 
 	if runtime.GOOS != "linux" {
@@ -66,6 +179,37 @@ func collectAdminResourcesSnapshot(now time.Time) adminResourcesResponse {
 	return resp
 }
 
+func collectRuntimeResources() adminRuntimeResources {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	out := adminRuntimeResources{
+		GoVersion:       runtime.Version(),
+		GOMAXPROCS:      runtime.GOMAXPROCS(0),
+		Goroutines:      runtime.NumGoroutine(),
+		HeapAllocBytes:  ms.HeapAlloc,
+		HeapInuseBytes:  ms.HeapInuse,
+		HeapSysBytes:    ms.HeapSys,
+		HeapObjects:     ms.HeapObjects,
+		SysBytes:        ms.Sys,
+		NumGC:           ms.NumGC,
+		GCPauseTotalMs:  float64(ms.PauseTotalNs) / 1e6,
+		RecentGCPauseMs: []float64{},
+	}
+	if ms.LastGC > 0 {
+		out.LastGCTimeMs = int64(ms.LastGC / 1e6)
+	}
+	// PauseNs is a circular buffer; the most recent pause is at (NumGC+255)%256.
+	n := int(ms.NumGC)
+	if n > recentGCPauses {
+		n = recentGCPauses
+	}
+	for i := 0; i < n; i++ {
+		idx := (int(ms.NumGC) - 1 - i + len(ms.PauseNs)) % len(ms.PauseNs)
+		out.RecentGCPauseMs = append(out.RecentGCPauseMs, float64(ms.PauseNs[idx])/1e6)
+	}
+	return out
+}
+
 func buildCgroupUnavailableDetail(errV2, errV1 error) string {
 	base := "cgroup metrics unavailable"
 	switch {
@@ -5,6 +5,9 @@ import (
 	"math"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
 	"testing"
 	"time"
 )
@@ -150,4 +153,86 @@ func TestAdminGetResources_AuthorizedResponseShape(t *testing.T) {
 			t.Fatalf("missing container field: %s", key)
 		}
 	}
+
+	runtimeRaw, ok := payload["runtime"].(map[string]any)
+	if !ok {
+		t.Fatalf("missing runtime object")
+	}
+	for _, key := range []string{"goroutines", "heap_alloc_bytes", "num_gc", "gc_pause_total_ms", "recent_gc_pauses_ms"} {
+		if _, ok := runtimeRaw[key]; !ok {
+			t.Fatalf("missing runtime field: %s", key)
+		}
+	}
+	if n, _ := runtimeRaw["goroutines"].(float64); n < 1 {
+		t.Fatalf("goroutines should be positive, got=%v", runtimeRaw["goroutines"])
+	}
+}
+
+func TestCollectRuntimeResourcesRecentPauses(t *testing.T) {
+	runtime.GC()
+	res := collectRuntimeResources()
+	if res.NumGC < 1 || len(res.RecentGCPauseMs) == 0 {
+		t.Fatalf("a forced GC should be reported, got num_gc=%d pauses=%v", res.NumGC, res.RecentGCPauseMs)
+	}
+	if len(res.RecentGCPauseMs) > recentGCPauses {
+		t.Fatalf("recent pauses should be capped at %d, got=%d", recentGCPauses, len(res.RecentGCPauseMs))
+	}
+}
+
+func TestAdminPprofRequiresAdmin(t *testing.T) {
+	admin := NewAdminSessionManager("admin-pass", 24*time.Hour)
+	handler := adminAPIMiddleware(admin, adminPprofHandler())
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/admin/debug/pprof/", nil))
+	if rr.Code != http.StatusUnauthorized {
+		t.Fatalf("pprof without session should be 401, got=%d", rr.Code)
+	}
+
+	token, _ := admin.Login("admin-pass")
+	for _, path := range []string{"/api/admin/debug/pprof/", "/api/admin/debug/pprof/goroutine?debug=1"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.AddCookie(&http.Cookie{Name: adminSessionCookieName, Value: token, Path: "/"})
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s should be 200 for admin, got=%d body=%s", path, rr.Code, rr.Body.String())
+		}
+	}
+}
+
+func TestCollectStorageResources(t *testing.T) {
+	dir := t.TempDir()
+	db, err := NewDatabase(filepath.Join(dir, "registry.db"))
+	if err != nil {
+		t.Fatalf("NewDatabase: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.CreateTarget(map[string]any{"name": "a", "base_url": "https://a.example.com", "api_key": "sk"}); err != nil {
+		t.Fatalf("CreateTarget: %v", err)
+	}
+	logDir := filepath.Join(dir, "logs")
+	if err := os.MkdirAll(logDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(logDir, "target_1.jsonl"), make([]byte, 100), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	got := collectStorageResources(db, logDir)
+	if got.Detail != "" {
+		t.Fatalf("storage collection should succeed, got detail=%q", got.Detail)
+	}
+	if got.DiskTotalBytes == nil || got.DiskFreeBytes == nil || *got.DiskTotalBytes == 0 {
+		t.Fatalf("disk usage should be reported, got total=%v free=%v", got.DiskTotalBytes, got.DiskFreeBytes)
+	}
+	if got.DatabaseBytes <= 0 || got.LogDirBytes != 100 || got.LogFiles != 1 {
+		t.Fatalf("file sizes should be measured, got db=%d logs=%d files=%d", got.DatabaseBytes, got.LogDirBytes, got.LogFiles)
+	}
+	if got.DataDirBytes < got.DatabaseBytes+got.LogDirBytes {
+		t.Fatalf("data dir should include db and logs, got=%d", got.DataDirBytes)
+	}
+	if got.TableRows["targets"] != 1 || got.TableRows["runs"] != 0 {
+		t.Fatalf("row counts should cover every table, got=%v", got.TableRows)
+	}
 }
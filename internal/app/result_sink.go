@@ -0,0 +1,296 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Result sink. With RESULT_SINK set to "loki" or "elasticsearch" and RESULT_SINK_URL
+// pointing at the server, every detection result is streamed there as a structured
+// entry (the same fields as the run's JSONL log) in batches, a few seconds after it is
+// recorded. Shipping never blocks a run: when the queue is full, entries are dropped
+// and counted. With RESULT_SINK unset the sink is off.
+
+const (
+	resultSinkLoki          = "loki"
+	resultSinkElasticsearch = "elasticsearch"
+
+	resultSinkBatchSize     = 500
+	resultSinkQueueSize     = 8192
+	resultSinkFlushInterval = 2 * time.Second
+
+	defaultResultSinkIndex = "api-monitor-results"
+)
+
+type resultSink struct {
+	kind     string
+	endpoint string
+	headers  map[string]string
+	username string
+	password string
+	index    string
+	labels   map[string]string
+	client   *http.Client
+	log      *slog.Logger
+
+	queue   chan runLogRow
+	stop    chan struct{}
+	done    chan struct{}
+	dropped atomic.Int64
+}
+
+var resultSinkInstance atomic.Pointer[resultSink]
+
+// resultSinkFromEnv builds the sink configured by the RESULT_SINK_* environment, or
+// returns nil when RESULT_SINK is unset.
+func resultSinkFromEnv() (*resultSink, error) {
+	kind := strings.ToLower(strings.TrimSpace(os.Getenv("RESULT_SINK")))
+	if kind == "" {
+		return nil, nil
+	}
+	if kind != resultSinkLoki && kind != resultSinkElasticsearch {
+		return nil, fmt.Errorf("RESULT_SINK must be loki or elasticsearch, got %q", kind)
+	}
+	base := strings.TrimRight(strings.TrimSpace(os.Getenv("RESULT_SINK_URL")), "/")
+	u, err := url.Parse(base)
+	if base == "" || err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("RESULT_SINK_URL must be an http(s) URL, got %q", base)
+	}
+	headers, err := parseKeyValueList("RESULT_SINK_HEADERS", os.Getenv("RESULT_SINK_HEADERS"))
+	if err != nil {
+		return nil, err
+	}
+	labels, err := parseKeyValueList("RESULT_SINK_LABELS", os.Getenv("RESULT_SINK_LABELS"))
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := labels["job"]; !ok {
+		labels["job"] = "api_monitor"
+	}
+	s := &resultSink{
+		kind:     kind,
+		headers:  headers,
+		username: strings.TrimSpace(os.Getenv("RESULT_SINK_USERNAME")),
+		password: os.Getenv("RESULT_SINK_PASSWORD"),
+		labels:   labels,
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}
+	switch kind {
+	case resultSinkLoki:
+		s.endpoint = base + "/loki/api/v1/push"
+	case resultSinkElasticsearch:
+		s.endpoint = base + "/_bulk"
+		s.index = strings.TrimSpace(os.Getenv("RESULT_SINK_INDEX"))
+		if s.index == "" {
+			s.index = defaultResultSinkIndex
+		}
+	}
+	return s, nil
+}
+
+// startResultSink installs the sink configured by the environment and returns a
+// shutdown function that flushes pending entries. It is a no-op when RESULT_SINK is unset.
+func startResultSink(logger *slog.Logger) (func(context.Context), error) {
+	s, err := resultSinkFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	if s == nil {
+		return func(context.Context) {}, nil
+	}
+	s.log = componentLogger(logger, "result_sink")
+	s.queue = make(chan runLogRow, resultSinkQueueSize)
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+	go s.loop()
+	resultSinkInstance.Store(s)
+	s.log.Info("result sink enabled", "sink", s.kind, "endpoint", s.endpoint)
+	return s.shutdown, nil
+}
+
+// publishResult queues one detection result for the result sink, if one is configured.
+func publishResult(row runLogRow) {
+	if s := resultSinkInstance.Load(); s != nil {
+		select {
+		case s.queue <- row:
+		default:
+			s.dropped.Add(1)
+		}
+	}
+}
+
+func (s *resultSink) loop() {
+	defer close(s.done)
+	ticker := time.NewTicker(resultSinkFlushInterval)
+	defer ticker.Stop()
+	var batch []runLogRow
+	send := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := s.export(batch); err != nil {
+			s.log.Warn("ship results failed", "sink", s.kind, "entries", len(batch), "error", err)
+		}
+		batch = nil
+	}
+	add := func(row runLogRow) {
+		batch = append(batch, row)
+		if len(batch) >= resultSinkBatchSize {
+			send()
+		}
+	}
+	for {
+		select {
+		case row := <-s.queue:
+			add(row)
+		case <-ticker.C:
+			send()
+		case <-s.stop:
+			for {
+				select {
+				case row := <-s.queue:
+					add(row)
+				default:
+					send()
+					return
+				}
+			}
+		}
+	}
+}
+
+func (s *resultSink) shutdown(ctx context.Context) {
+	resultSinkInstance.CompareAndSwap(s, nil)
+	close(s.stop)
+	select {
+	case <-s.done:
+	case <-ctx.Done():
+	}
+	if n := s.dropped.Load(); n > 0 {
+		s.log.Warn("results dropped because the sink queue was full", "dropped", n)
+	}
+}
+
+func (s *resultSink) export(batch []runLogRow) error {
+	var body []byte
+	var err error
+	contentType := "application/json"
+	switch s.kind {
+	case resultSinkLoki:
+		body, err = s.lokiPayload(batch)
+	case resultSinkElasticsearch:
+		body, err = s.bulkPayload(batch)
+		contentType = "application/x-ndjson"
+	}
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, s.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if s.username != "" {
+		req.SetBasicAuth(s.username, s.password)
+	}
+	for k, v := range s.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("sink returned HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody[:min(len(respBody), 512)])))
+	}
+	if s.kind == resultSinkElasticsearch {
+		var result struct {
+			Errors bool `json:"errors"`
+		}
+		if json.Unmarshal(respBody, &result) == nil && result.Errors {
+			return fmt.Errorf("bulk request reported item errors")
+		}
+	}
+	return nil
+}
+
+// resultTime is the time of a result, falling back to now for rows without a timestamp.
+func resultTime(row runLogRow) time.Time {
+	if row.Timestamp > 0 {
+		return time.UnixMilli(int64(row.Timestamp * 1000))
+	}
+	return time.Now()
+}
+
+// lokiPayload groups the batch into one stream per target and protocol, keeping label
+// cardinality bounded; every other field stays in the JSON log line.
+func (s *resultSink) lokiPayload(batch []runLogRow) ([]byte, error) {
+	type stream struct {
+		Stream map[string]string `json:"stream"`
+		Values [][2]string       `json:"values"`
+	}
+	byKey := map[string]*stream{}
+	var keys []string
+	for _, row := range batch {
+		line, err := json.Marshal(row)
+		if err != nil {
+			return nil, err
+		}
+		key := row.TargetName + "\x00" + row.Protocol
+		st := byKey[key]
+		if st == nil {
+			labels := map[string]string{"target": row.TargetName, "protocol": row.Protocol}
+			for k, v := range s.labels {
+				labels[k] = v
+			}
+			st = &stream{Stream: labels}
+			byKey[key] = st
+			keys = append(keys, key)
+		}
+		st.Values = append(st.Values, [2]string{strconv.FormatInt(resultTime(row).UnixNano(), 10), string(line)})
+	}
+	sort.Strings(keys)
+	streams := make([]*stream, 0, len(keys))
+	for _, key := range keys {
+		streams = append(streams, byKey[key])
+	}
+	return json.Marshal(map[string]any{"streams": streams})
+}
+
+// bulkPayload renders the batch as an Elasticsearch _bulk request indexing one
+// document per result, with @timestamp set from the result time.
+func (s *resultSink) bulkPayload(batch []runLogRow) ([]byte, error) {
+	action, err := json.Marshal(map[string]any{"index": map[string]any{"_index": s.index}})
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	for _, row := range batch {
+		doc, err := json.Marshal(struct {
+			runLogRow
+			At string `json:"@timestamp"`
+		}{row, resultTime(row).UTC().Format(time.RFC3339Nano)})
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(action)
+		buf.WriteByte('\n')
+		buf.Write(doc)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,123 @@
+package app
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestResultSinkConfigValidation(t *testing.T) {
+	t.Setenv("RESULT_SINK", "")
+	if s, err := resultSinkFromEnv(); s != nil || err != nil {
+		t.Fatalf("unset RESULT_SINK should disable the sink, got=%v err=%v", s, err)
+	}
+	for kind, url := range map[string]string{"splunk": "http://x", "loki": "", "elasticsearch": "ftp://x"} {
+		t.Setenv("RESULT_SINK", kind)
+		t.Setenv("RESULT_SINK_URL", url)
+		if _, err := resultSinkFromEnv(); err == nil {
+			t.Fatalf("RESULT_SINK=%s RESULT_SINK_URL=%q should be rejected", kind, url)
+		}
+	}
+}
+
+func TestResultSinkPushesToLoki(t *testing.T) {
+	var mu sync.Mutex
+	var gotPath, gotTenant string
+	var payload struct {
+		Streams []struct {
+			Stream map[string]string `json:"stream"`
+			Values [][2]string       `json:"values"`
+		} `json:"streams"`
+	}
+	loki := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		gotPath, gotTenant = r.URL.Path, r.Header.Get("X-Scope-OrgID")
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer loki.Close()
+
+	t.Setenv("RESULT_SINK", "loki")
+	t.Setenv("RESULT_SINK_URL", loki.URL+"/")
+	t.Setenv("RESULT_SINK_HEADERS", "X-Scope-OrgID=ops")
+	t.Setenv("RESULT_SINK_LABELS", "env=prod")
+	shutdown, err := startResultSink(nil)
+	if err != nil {
+		t.Fatalf("startResultSink failed: %v", err)
+	}
+	publishResult(runLogRow{DetectionResult: DetectionResult{Protocol: "openai", Model: "gpt-a", Success: true, Timestamp: 1700000000.5}, TargetID: 3, RunID: 9, TargetName: "relay"})
+	publishResult(runLogRow{DetectionResult: DetectionResult{Protocol: "openai", Model: "gpt-b", Timestamp: 1700000001}, TargetID: 3, RunID: 9, TargetName: "relay"})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	shutdown(ctx)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if gotPath != "/loki/api/v1/push" || gotTenant != "ops" {
+		t.Fatalf("push should go to the Loki API with the extra headers, got path=%s tenant=%q", gotPath, gotTenant)
+	}
+	if len(payload.Streams) != 1 || len(payload.Streams[0].Values) != 2 {
+		t.Fatalf("results of one target and protocol should share a stream, got=%+v", payload.Streams)
+	}
+	labels := payload.Streams[0].Stream
+	if labels["target"] != "relay" || labels["protocol"] != "openai" || labels["job"] != "api_monitor" || labels["env"] != "prod" {
+		t.Fatalf("stream labels should carry target, protocol and static labels, got=%v", labels)
+	}
+	value := payload.Streams[0].Values[0]
+	if value[0] != "1700000000500000000" || !strings.Contains(value[1], `"model":"gpt-a"`) || !strings.Contains(value[1], `"run_id":9`) {
+		t.Fatalf("entry should be the JSON result at its timestamp, got=%v", value)
+	}
+}
+
+func TestResultSinkPushesToElasticsearch(t *testing.T) {
+	var mu sync.Mutex
+	var gotPath, gotUser string
+	var lines []map[string]any
+	es := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		gotPath = r.URL.Path
+		gotUser, _, _ = r.BasicAuth()
+		sc := bufio.NewScanner(r.Body)
+		for sc.Scan() {
+			var line map[string]any
+			_ = json.Unmarshal(sc.Bytes(), &line)
+			lines = append(lines, line)
+		}
+		_, _ = w.Write([]byte(`{"errors":false,"items":[]}`))
+	}))
+	defer es.Close()
+
+	t.Setenv("RESULT_SINK", "elasticsearch")
+	t.Setenv("RESULT_SINK_URL", es.URL)
+	t.Setenv("RESULT_SINK_USERNAME", "elastic")
+	t.Setenv("RESULT_SINK_PASSWORD", "secret")
+	t.Setenv("RESULT_SINK_INDEX", "monitor")
+	shutdown, err := startResultSink(nil)
+	if err != nil {
+		t.Fatalf("startResultSink failed: %v", err)
+	}
+	publishResult(runLogRow{DetectionResult: DetectionResult{Protocol: "anthropic", Model: "claude-x", Timestamp: 1700000000}, TargetID: 1, RunID: 2, TargetName: "relay"})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	shutdown(ctx)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if gotPath != "/_bulk" || gotUser != "elastic" || len(lines) != 2 {
+		t.Fatalf("bulk request should carry one action and one document, got path=%s user=%q lines=%v", gotPath, gotUser, lines)
+	}
+	if action, _ := lines[0]["index"].(map[string]any); action["_index"] != "monitor" {
+		t.Fatalf("action should index into RESULT_SINK_INDEX, got=%v", lines[0])
+	}
+	if doc := lines[1]; doc["model"] != "claude-x" || doc["target_name"] != "relay" || doc["@timestamp"] != "2023-11-14T22:13:20Z" {
+		t.Fatalf("document should be the result with @timestamp, got=%v", doc)
+	}
+}
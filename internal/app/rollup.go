@@ -0,0 +1,234 @@
+package app
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Daily rollups. Once a UTC day is over, the scheduler aggregates its run_models rows
+// into model_daily_stats: one row per target, model and day with the check counts and
+// the average and p95 latency of successful checks. Day-bucketed analytics read the
+// rolled-up days from the rollup table and only the rest from run_models, and run
+// archival never removes rows of a day that is not rolled up yet, so raw rows can be
+// archived after a few days without losing long-term history.
+
+const (
+	rollupDaySeconds = 86400
+	// rollupInterval is how often the scheduler looks for finished days to roll up.
+	rollupInterval = time.Hour
+	// rollupDelay is how long after midnight UTC a day is rolled up, so rows of runs
+	// that crossed midnight are stored first.
+	rollupDelay = 15 * time.Minute
+)
+
+// DailyModelStats is the rollup of one model of one target over one UTC day.
+type DailyModelStats struct {
+	TargetID    int      `json:"target_id"`
+	Model       string   `json:"model"`
+	Day         float64  `json:"day"`
+	Total       int      `json:"total"`
+	Success     int      `json:"success"`
+	SuccessRate float64  `json:"success_rate"`
+	AvgDuration *float64 `json:"avg_duration"`
+	P95Duration *float64 `json:"p95_duration"`
+}
+
+// firstPendingDay returns the start of the first day whose rows are not rolled up yet:
+// the day after the last rolled-up one, or the day of the oldest row when nothing is
+// rolled up. It returns +Inf when nothing is rolled up and there are no rows.
+func (d *Database) firstPendingDay() (float64, error) {
+	var last *float64
+	if err := d.read.QueryRow(`SELECT MAX(day) FROM model_daily_stats`).Scan(&last); err != nil {
+		return 0, err
+	}
+	if last != nil {
+		return *last + rollupDaySeconds, nil
+	}
+	var first *float64
+	if err := d.read.QueryRow(`SELECT MIN(timestamp) FROM run_models`).Scan(&first); err != nil {
+		return 0, err
+	}
+	if first == nil {
+		return math.Inf(1), nil
+	}
+	return math.Floor(*first/rollupDaySeconds) * rollupDaySeconds, nil
+}
+
+// RollupDay aggregates the run_models rows of the UTC day starting at day, replacing an
+// earlier rollup of the same day.
+func (d *Database) RollupDay(day float64) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	_, err := d.conn.Exec(`
+		WITH win AS (
+			SELECT target_id, model, success, duration
+			FROM run_models
+			WHERE timestamp >= ? AND timestamp < ?
+		),
+		ok AS (
+			SELECT target_id, model, duration,
+				ROW_NUMBER() OVER (PARTITION BY target_id, model ORDER BY duration) AS rn,
+				COUNT(*) OVER (PARTITION BY target_id, model) AS cnt
+			FROM win
+			WHERE success = 1
+		),
+		p95 AS (
+			SELECT target_id, model, MIN(duration) AS duration
+			FROM ok
+			WHERE rn >= (cnt * 95 + 99) / 100
+			GROUP BY target_id, model
+		),
+		agg AS (
+			SELECT target_id, model, COUNT(*) AS total, SUM(success) AS success,
+				AVG(CASE WHEN success = 1 THEN duration END) AS avg_duration
+			FROM win
+			GROUP BY target_id, model
+		)
+		INSERT OR REPLACE INTO model_daily_stats (target_id, model, day, total, success, avg_duration, p95_duration)
+		SELECT a.target_id, a.model, ?, a.total, a.success, a.avg_duration, p.duration
+		FROM agg a
+		JOIN targets t ON t.id = a.target_id
+		LEFT JOIN p95 p ON p.target_id = a.target_id AND p.model = a.model`,
+		day, day+rollupDaySeconds, day)
+	return err
+}
+
+// RollupFinishedDays rolls up every day after the last rolled-up one that ended at
+// least rollupDelay before now, and returns how many days it rolled up.
+func (d *Database) RollupFinishedDays(now time.Time) (int, error) {
+	from, err := d.firstPendingDay()
+	if err != nil {
+		return 0, err
+	}
+	end := math.Floor(float64(now.Add(-rollupDelay).Unix())/rollupDaySeconds) * rollupDaySeconds
+	days := 0
+	for day := from; day < end; day += rollupDaySeconds {
+		if err := d.RollupDay(day); err != nil {
+			return days, fmt.Errorf("roll up %s: %w", time.Unix(int64(day), 0).UTC().Format("2006-01-02"), err)
+		}
+		days++
+	}
+	return days, nil
+}
+
+// ListDailyStats returns the rollups of days within [since, until), oldest first.
+func (d *Database) ListDailyStats(since, until float64, targetID *int, model *string) ([]DailyModelStats, error) {
+	args := []any{since, until}
+	filter := ""
+	if targetID != nil {
+		filter += " AND s.target_id = ?"
+		args = append(args, *targetID)
+	}
+	if model != nil {
+		filter += " AND s.model = ?"
+		args = append(args, *model)
+	}
+	rows, err := d.read.Query(`
+		SELECT s.target_id, s.model, s.day, s.total, s.success, s.avg_duration, s.p95_duration
+		FROM model_daily_stats s
+		JOIN targets t ON t.id = s.target_id AND t.deleted_at IS NULL
+		WHERE s.day >= ? AND s.day < ?`+filter+`
+		ORDER BY s.day, s.target_id, s.model`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []DailyModelStats{}
+	for rows.Next() {
+		var s DailyModelStats
+		if err := rows.Scan(&s.TargetID, &s.Model, &s.Day, &s.Total, &s.Success, &s.AvgDuration, &s.P95Duration); err != nil {
+			return nil, err
+		}
+		s.SuccessRate = percentRate(s.Success, s.Total)
+		s.AvgDuration = roundSeconds(s.AvgDuration)
+		s.P95Duration = roundSeconds(s.P95Duration)
+		items = append(items, s)
+	}
+	return items, rows.Err()
+}
+
+// roundSeconds rounds a duration in seconds to milliseconds.
+func roundSeconds(v *float64) *float64 {
+	if v == nil {
+		return nil
+	}
+	r := math.Round(*v*1000.0) / 1000.0
+	return &r
+}
+
+// maybeRollupDays rolls up finished days in the background at most once per
+// rollupInterval.
+func (ms *MonitorService) maybeRollupDays() {
+	ms.mu.Lock()
+	due := time.Since(ms.lastRollupAt) >= rollupInterval
+	if due {
+		ms.lastRollupAt = time.Now()
+	}
+	ms.mu.Unlock()
+	if !due {
+		return
+	}
+	go func() {
+		if _, err := ms.rollupDays(); err != nil {
+			ms.logger().Error("daily rollup failed", "error", err)
+			return
+		}
+		ms.maybeSendReport()
+	}()
+}
+
+// rollupDays rolls up finished days; it shares archiveMu with run archival so rows are
+// never archived while their day is being rolled up.
+func (ms *MonitorService) rollupDays() (int, error) {
+	ms.archiveMu.Lock()
+	defer ms.archiveMu.Unlock()
+	days, err := ms.db.RollupFinishedDays(time.Now())
+	if days > 0 {
+		ms.logger().Info("daily stats rolled up", "days", days)
+	}
+	return days, err
+}
+
+// DailyStats -- GET /api/analytics/daily?since=&until=&target_id=&model=
+// Returns the daily rollups of the window (default last 30 days).
+func (h *Handlers) DailyStats(w http.ResponseWriter, r *http.Request) {
+	since, until, ok := queryTimeRange(r, 24*30)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "since/until must be unix timestamps with since < until"})
+		return
+	}
+	var targetID *int
+	if s := r.URL.Query().Get("target_id"); s != "" {
+		id, err := strconv.Atoi(s)
+		if err != nil || id < 1 {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "invalid target_id"})
+			return
+		}
+		targetID = &id
+	}
+	var model *string
+	if s := r.URL.Query().Get("model"); s != "" {
+		model = &s
+	}
+	items, err := h.db.ListDailyStats(since, until, targetID, model)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return
+	}
+	visible := items[:0]
+	for _, item := range items {
+		if principalAllowsTarget(r, item.TargetID) {
+			visible = append(visible, item)
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"since": since,
+		"until": until,
+		"items": visible,
+	})
+}
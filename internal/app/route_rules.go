@@ -0,0 +1,393 @@
+package app
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Detection routes a model can be probed with.
+var validDetectionRoutes = map[string]bool{
+	"chat":      true,
+	"responses": true,
+	"anthropic": true,
+	"gemini":    true,
+}
+
+// RouteRule maps model IDs matching Pattern to a detection route.
+// Pattern uses the same syntax as include/exclude patterns: a case-insensitive glob,
+// or a regular expression when prefixed with "re:". Rules are evaluated against the
+// lowercased model name (channel prefix stripped) in ascending priority order, before
+// the built-in rules.
+type RouteRule struct {
+	ID          int     `json:"id"`
+	Pattern     string  `json:"pattern"`
+	Route       string  `json:"route"`
+	Priority    int     `json:"priority"`
+	Enabled     bool    `json:"enabled"`
+	Description string  `json:"description"`
+	CreatedAt   float64 `json:"created_at"`
+	UpdatedAt   float64 `json:"updated_at"`
+}
+
+type routeRuleRequest struct {
+	Pattern     *string `json:"pattern"`
+	Route       *string `json:"route"`
+	Priority    *int    `json:"priority"`
+	Enabled     *bool   `json:"enabled"`
+	Description *string `json:"description"`
+}
+
+type compiledRouteRule struct {
+	id      int
+	pattern *regexp.Regexp
+	route   string
+}
+
+// EnsureRouteRuleSchema creates the route_rules table.
+func (d *Database) EnsureRouteRuleSchema() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	_, err := d.conn.Exec(`
+		CREATE TABLE IF NOT EXISTS route_rules (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			pattern TEXT NOT NULL,
+			route TEXT NOT NULL,
+			priority INTEGER NOT NULL DEFAULT 100,
+			enabled INTEGER NOT NULL DEFAULT 1,
+			description TEXT NOT NULL DEFAULT '',
+			created_at REAL NOT NULL,
+			updated_at REAL NOT NULL
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_route_rules_priority
+		ON route_rules(priority, id);
+	`)
+	if err != nil {
+		return fmt.Errorf("init route rule schema: %w", err)
+	}
+	return nil
+}
+
+const routeRuleColumns = `id, pattern, route, priority, enabled, description, created_at, updated_at`
+
+func scanRouteRule(r interface{ Scan(dest ...any) error }) (*RouteRule, error) {
+	var rule RouteRule
+	var enabled int
+	if err := r.Scan(
+		&rule.ID, &rule.Pattern, &rule.Route, &rule.Priority,
+		&enabled, &rule.Description, &rule.CreatedAt, &rule.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+	rule.Enabled = enabled != 0
+	return &rule, nil
+}
+
+// ListRouteRules returns all rules in evaluation order.
+func (d *Database) ListRouteRules() ([]RouteRule, error) {
+	rows, err := d.read.Query("SELECT " + routeRuleColumns + " FROM route_rules ORDER BY priority ASC, id ASC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]RouteRule, 0)
+	for rows.Next() {
+		rule, err := scanRouteRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *rule)
+	}
+	return out, rows.Err()
+}
+
+// GetRouteRule returns a rule by id, or nil when it does not exist.
+func (d *Database) GetRouteRule(id int) (*RouteRule, error) {
+	row := d.read.QueryRow("SELECT "+routeRuleColumns+" FROM route_rules WHERE id = ?", id)
+	rule, err := scanRouteRule(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return rule, err
+}
+
+// CreateRouteRule inserts a rule.
+func (d *Database) CreateRouteRule(pattern, route string, priority int, enabled bool, description string) (*RouteRule, error) {
+	now := float64(time.Now().UnixMilli()) / 1000.0
+	d.mu.Lock()
+	res, err := d.conn.Exec(`
+		INSERT INTO route_rules (pattern, route, priority, enabled, description, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		pattern, route, priority, boolToInt(enabled), description, now, now,
+	)
+	d.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	id, _ := res.LastInsertId()
+	return d.GetRouteRule(int(id))
+}
+
+// UpdateRouteRule saves all editable fields of rule.
+func (d *Database) UpdateRouteRule(rule *RouteRule) (*RouteRule, error) {
+	d.mu.Lock()
+	_, err := d.conn.Exec(`
+		UPDATE route_rules
+		SET pattern = ?, route = ?, priority = ?, enabled = ?, description = ?, updated_at = ?
+		WHERE id = ?`,
+		rule.Pattern, rule.Route, rule.Priority, boolToInt(rule.Enabled), rule.Description,
+		float64(time.Now().UnixMilli())/1000.0, rule.ID,
+	)
+	d.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return d.GetRouteRule(rule.ID)
+}
+
+// DeleteRouteRule removes a rule.
+func (d *Database) DeleteRouteRule(id int) (bool, error) {
+	d.mu.Lock()
+	res, err := d.conn.Exec("DELETE FROM route_rules WHERE id = ?", id)
+	d.mu.Unlock()
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+func validateRouteRule(rule *RouteRule) error {
+	rule.Pattern = strings.TrimSpace(rule.Pattern)
+	rule.Route = strings.ToLower(strings.TrimSpace(rule.Route))
+	rule.Description = strings.TrimSpace(rule.Description)
+	if rule.Pattern == "" || len(rule.Pattern) > 256 {
+		return fmt.Errorf("pattern must be 1-256 chars")
+	}
+	if _, err := compileModelPatterns([]string{rule.Pattern}); err != nil {
+		return err
+	}
+	if !validDetectionRoutes[rule.Route] {
+		return fmt.Errorf("route must be one of chat, responses, anthropic, gemini")
+	}
+	if rule.Priority < 0 || rule.Priority > 100000 {
+		return fmt.Errorf("priority must be between 0 and 100000")
+	}
+	if len(rule.Description) > 512 {
+		return fmt.Errorf("description must be <= 512 chars")
+	}
+	return nil
+}
+
+func compileRouteRules(rules []RouteRule, logger *slog.Logger) []compiledRouteRule {
+	out := make([]compiledRouteRule, 0, len(rules))
+	for _, rule := range rules {
+		if !rule.Enabled {
+			continue
+		}
+		compiled, err := compileModelPatterns([]string{rule.Pattern})
+		if err != nil || len(compiled) == 0 {
+			logger.Warn("skip invalid route rule", "rule_id", rule.ID, "error", err)
+			continue
+		}
+		out = append(out, compiledRouteRule{id: rule.ID, pattern: compiled[0], route: rule.Route})
+	}
+	return out
+}
+
+// ReloadRouteRules loads enabled route rules from the database into the monitor.
+func (ms *MonitorService) ReloadRouteRules() error {
+	rules, err := ms.db.ListRouteRules()
+	if err != nil {
+		return err
+	}
+	compiled := ms.applyRouteRules(rules)
+	ms.logger().Info("route rules loaded", "count", len(compiled))
+	return nil
+}
+
+// applyRouteRules replaces the active custom rules; agents use it with rules received from the server.
+func (ms *MonitorService) applyRouteRules(rules []RouteRule) []compiledRouteRule {
+	compiled := compileRouteRules(rules, ms.logger())
+	ms.routeMu.Lock()
+	ms.customRoutes = compiled
+	ms.routeMu.Unlock()
+	return compiled
+}
+
+// resolveRoute returns the detection route for modelID and the id of the custom rule
+// that matched (0 when a built-in rule or the chat default applied).
+func (ms *MonitorService) resolveRoute(modelID string) (string, int) {
+	parts := strings.SplitN(modelID, "/", 2)
+	actual := strings.ToLower(parts[len(parts)-1])
+
+	ms.routeMu.RLock()
+	custom := ms.customRoutes
+	ms.routeMu.RUnlock()
+	for _, rule := range custom {
+		if rule.pattern.MatchString(actual) {
+			return rule.route, rule.id
+		}
+	}
+	for _, rule := range routeRules {
+		if rule.pattern.MatchString(actual) {
+			return rule.route, 0
+		}
+	}
+	return "chat", 0
+}
+
+// ----------------------- Admin API -----------------------
+
+func (h *Handlers) reloadRouteRules(w http.ResponseWriter) bool {
+	if err := h.monitor.ReloadRouteRules(); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return false
+	}
+	return true
+}
+
+// AdminListRouteRules handles GET /api/admin/route-rules
+func (h *Handlers) AdminListRouteRules(w http.ResponseWriter, r *http.Request) {
+	items, err := h.db.ListRouteRules()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return
+	}
+	builtin := make([]map[string]any, 0, len(routeRules))
+	for _, rule := range routeRules {
+		builtin = append(builtin, map[string]any{"pattern": "re:" + rule.pattern.String(), "route": rule.route})
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"items": items, "builtin": builtin})
+}
+
+// AdminCreateRouteRule handles POST /api/admin/route-rules
+func (h *Handlers) AdminCreateRouteRule(w http.ResponseWriter, r *http.Request) {
+	var req routeRuleRequest
+	if err := readJSON(r, &req); err != nil {
+		writeRequestBodyError(w, err)
+		return
+	}
+	rule := RouteRule{Priority: 100, Enabled: true}
+	applyRouteRuleRequest(&rule, &req)
+	if err := validateRouteRule(&rule); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": err.Error()})
+		return
+	}
+	item, err := h.db.CreateRouteRule(rule.Pattern, rule.Route, rule.Priority, rule.Enabled, rule.Description)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return
+	}
+	if !h.reloadRouteRules(w) {
+		return
+	}
+	h.audit(r, "route_rule.create", "route_rule", item.ID, auditDiff(nil, item, nil, nil))
+	writeJSON(w, http.StatusOK, map[string]any{"ok": true, "item": item})
+}
+
+// AdminPatchRouteRule handles PATCH /api/admin/route-rules/{id}
+func (h *Handlers) AdminPatchRouteRule(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(r)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "invalid id"})
+		return
+	}
+	rule, err := h.db.GetRouteRule(id)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return
+	}
+	if rule == nil {
+		writeJSON(w, http.StatusNotFound, map[string]any{"detail": "route rule not found"})
+		return
+	}
+	var req routeRuleRequest
+	if err := readJSON(r, &req); err != nil {
+		writeRequestBodyError(w, err)
+		return
+	}
+	before := *rule
+	applyRouteRuleRequest(rule, &req)
+	if err := validateRouteRule(rule); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": err.Error()})
+		return
+	}
+	item, err := h.db.UpdateRouteRule(rule)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return
+	}
+	if !h.reloadRouteRules(w) {
+		return
+	}
+	h.audit(r, "route_rule.update", "route_rule", id, auditDiff(&before, item, map[string]bool{"updated_at": true}, nil))
+	writeJSON(w, http.StatusOK, map[string]any{"ok": true, "item": item})
+}
+
+// AdminDeleteRouteRule handles DELETE /api/admin/route-rules/{id}
+func (h *Handlers) AdminDeleteRouteRule(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(r)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "invalid id"})
+		return
+	}
+	deleted, err := h.db.DeleteRouteRule(id)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return
+	}
+	if !deleted {
+		writeJSON(w, http.StatusNotFound, map[string]any{"detail": "route rule not found"})
+		return
+	}
+	if !h.reloadRouteRules(w) {
+		return
+	}
+	h.audit(r, "route_rule.delete", "route_rule", id, nil)
+	writeJSON(w, http.StatusOK, map[string]any{"ok": true})
+}
+
+// AdminResolveRoute handles GET /api/admin/route-rules/resolve?model=...
+func (h *Handlers) AdminResolveRoute(w http.ResponseWriter, r *http.Request) {
+	model := strings.TrimSpace(r.URL.Query().Get("model"))
+	if model == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "model is required"})
+		return
+	}
+	route, ruleID := h.monitor.resolveRoute(model)
+	var matched any
+	if ruleID > 0 {
+		matched = ruleID
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"item": map[string]any{
+		"model":   model,
+		"route":   route,
+		"rule_id": matched,
+	}})
+}
+
+func applyRouteRuleRequest(rule *RouteRule, req *routeRuleRequest) {
+	if req.Pattern != nil {
+		rule.Pattern = *req.Pattern
+	}
+	if req.Route != nil {
+		rule.Route = *req.Route
+	}
+	if req.Priority != nil {
+		rule.Priority = *req.Priority
+	}
+	if req.Enabled != nil {
+		rule.Enabled = *req.Enabled
+	}
+	if req.Description != nil {
+		rule.Description = *req.Description
+	}
+}
@@ -0,0 +1,56 @@
+package app
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestResolveRoute_CustomRulesBeforeBuiltin(t *testing.T) {
+	db, err := NewDatabase(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDatabase failed: %v", err)
+	}
+	t.Cleanup(func() { _ = db.conn.Close() })
+	if err := db.EnsureRouteRuleSchema(); err != nil {
+		t.Fatalf("EnsureRouteRuleSchema failed: %v", err)
+	}
+	ms := NewMonitorService(MonitorConfig{DB: db, LogDir: t.TempDir()})
+
+	if route, _ := ms.resolveRoute("gpt-5.1"); route != "responses" {
+		t.Fatalf("built-in rule should route gpt-5.1 to responses, got=%s", route)
+	}
+
+	if _, err := db.CreateRouteRule("o4-*", "responses", 10, true, ""); err != nil {
+		t.Fatalf("CreateRouteRule failed: %v", err)
+	}
+	chatRule, err := db.CreateRouteRule("gpt-5.1*", "chat", 20, true, "")
+	if err != nil {
+		t.Fatalf("CreateRouteRule failed: %v", err)
+	}
+	if _, err := db.CreateRouteRule("kimi-*", "anthropic", 30, false, ""); err != nil {
+		t.Fatalf("CreateRouteRule failed: %v", err)
+	}
+	if err := ms.ReloadRouteRules(); err != nil {
+		t.Fatalf("ReloadRouteRules failed: %v", err)
+	}
+
+	cases := map[string]string{
+		"o4-mini":         "responses",
+		"channel/gpt-5.1": "chat",
+		"kimi-k2":         "chat",
+		"claude-sonnet-4": "anthropic",
+	}
+	for model, want := range cases {
+		if got := ms.chooseRoute(model); got != want {
+			t.Fatalf("model %s should route to %s, got=%s", model, want, got)
+		}
+	}
+	if _, ruleID := ms.resolveRoute("gpt-5.1"); ruleID != chatRule.ID {
+		t.Fatalf("gpt-5.1 should match rule %d, got=%d", chatRule.ID, ruleID)
+	}
+
+	bad := &RouteRule{Pattern: "x", Route: "grpc"}
+	if err := validateRouteRule(bad); err == nil {
+		t.Fatalf("unknown route should fail validation")
+	}
+}
@@ -6,7 +6,7 @@ import (
 	"encoding/hex"
 	"fmt"
 	"io/fs"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
@@ -104,6 +104,54 @@ func resolveOptionalRuntimeSecret(db *Database, envName, settingKey string) (str
 	return "", false, nil
 }
 
+// openDatabase opens the registry database and applies every schema migration.
+func openDatabase(dbPath string) (*Database, error) {
+	if err := loadEncryptionKeyFromEnv(); err != nil {
+		return nil, err
+	}
+	db, err := NewDatabaseWithOptions(dbPath, DatabaseOptions{
+		ReadConns:   envInt("MONITOR_DB_READ_CONNS", defaultDBReadConns),
+		BusyTimeout: time.Duration(envInt("MONITOR_DB_BUSY_TIMEOUT_MS", int(defaultDBBusyTimeout/time.Millisecond))) * time.Millisecond,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("database init failed: %w", err)
+	}
+	steps := []struct {
+		name string
+		fn   func() error
+	}{
+		{"proxy", db.EnsureProxySchema},
+		{"proxy request", db.EnsureProxyRequestSchema},
+		{"proxy audit", db.EnsureProxyAuditSchema},
+		{"route rule", db.EnsureRouteRuleSchema},
+		{"notification", db.EnsureNotificationSchema},
+		{"agent", db.EnsureAgentSchema},
+		{"incident", db.EnsureIncidentSchema},
+		{"audit", db.EnsureAuditSchema},
+		{"api token", db.EnsureAPITokenSchema},
+		{"template", db.EnsureTemplateSchema},
+		{"note", db.EnsureNoteSchema},
+	}
+	for _, step := range steps {
+		if err := step.fn(); err != nil {
+			_ = db.Close()
+			return nil, fmt.Errorf("%s schema init failed: %w", step.name, err)
+		}
+	}
+	if err := db.VerifySecrets(); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+func dataDirFromEnv() string {
+	if dataDir := os.Getenv("DATA_DIR"); dataDir != "" {
+		return dataDir
+	}
+	return "data"
+}
+
 // serveEmbeddedHTML 返回一个从嵌入文件系统中读取并响应 HTML 文件的处理器。
 func serveEmbeddedHTML(webFS fs.FS, filePath string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -118,47 +166,147 @@ func serveEmbeddedHTML(webFS fs.FS, filePath string) http.HandlerFunc {
 }
 
 func Start(webFS fs.FS) {
-	// ---- Config from environment ----
-	dataDir := os.Getenv("DATA_DIR")
-	if dataDir == "" {
-		dataDir = "data"
+	baseLogger := slog.Default()
+	logger := componentLogger(baseLogger, "main")
+	shutdownTracing, err := startTracing(baseLogger)
+	if err != nil {
+		fatal(logger, "tracing configuration invalid", "error", err)
+	}
+	shutdownResultSink, err := startResultSink(baseLogger)
+	if err != nil {
+		fatal(logger, "result sink configuration invalid", "error", err)
 	}
+	shutdownMQTT, err := startMQTTPublisher(baseLogger)
+	if err != nil {
+		fatal(logger, "mqtt configuration invalid", "error", err)
+	}
+	shutdownMetricsPush, err := startMetricsPusher(baseLogger)
+	if err != nil {
+		fatal(logger, "metrics push configuration invalid", "error", err)
+	}
+	if err := startReports(baseLogger); err != nil {
+		fatal(logger, "report configuration invalid", "error", err)
+	}
+	if err := startHeartbeat(baseLogger); err != nil {
+		fatal(logger, "heartbeat configuration invalid", "error", err)
+	}
+	if err := startProxyAudit(baseLogger); err != nil {
+		fatal(logger, "proxy audit configuration invalid", "error", err)
+	}
+
+	// ---- Config from environment ----
+	dataDir := dataDirFromEnv()
 	dbPath := filepath.Join(dataDir, "registry.db")
 	logDir := filepath.Join(dataDir, "logs")
 
+	// ---- Startup validation (before any state is opened) ----
+	diagnostics := validateStartupEnvironment(webFS, dataDir)
+	if diagnostics.HasFatal() {
+		diagnostics.Log()
+		fatal(logger, "fatal configuration errors, refusing to start")
+	}
+
 	logCleanupEnabled := envBool("LOG_CLEANUP_ENABLED", true)
 	logMaxSizeMB := envInt("LOG_MAX_SIZE_MB", 500)
+	logMaxAgeDays := envInt("LOG_MAX_AGE_DAYS", 0)
 	defaultIntervalMin := envInt("DEFAULT_INTERVAL_MIN", 30)
 	monitorDetectConcurrency := envInt("MONITOR_DETECT_CONCURRENCY", 3)
 	monitorMaxParallelTargets := envInt("MONITOR_MAX_PARALLEL_TARGETS", 2)
+	monitorMaxRunDurationS := envInt("MONITOR_MAX_RUN_DURATION_S", 3600)
+	runArchiveDays := envInt("MONITOR_RUN_ARCHIVE_DAYS", 0)
+	visitorRateLimitPerMin := envInt("VISITOR_RATE_LIMIT_PER_MIN", 0)
+	visitorRateLimitBurst := envInt("VISITOR_RATE_LIMIT_BURST", 0)
+	certExpiryWarnDays := envInt("CERT_EXPIRY_WARN_DAYS", 14)
+	latencyAnomalySigma := envInt("LATENCY_ANOMALY_SIGMA", 3)
+	latencyAnomalyPct := envInt("LATENCY_ANOMALY_PCT", 0)
+	notifyFlapThreshold := envInt("NOTIFY_FLAP_THRESHOLD", 6)
 	if defaultIntervalMin < 1 || defaultIntervalMin > 1440 {
 		defaultIntervalMin = 30
 	}
 	proxyMasterTokenDefault := strings.TrimSpace(os.Getenv("PROXY_MASTER_TOKEN"))
+	tlsConfig := tlsServeConfigFromEnv(dataDir)
+	setSecureCookies(tlsConfig.Enabled())
+	adminIPAllowlistDefault := strings.TrimSpace(os.Getenv("ADMIN_IP_ALLOWLIST"))
+	writeIPAllowlistDefault := strings.TrimSpace(os.Getenv("WRITE_IP_ALLOWLIST"))
+	trustedProxiesDefault, ok := os.LookupEnv("TRUSTED_PROXIES")
+	if !ok {
+		trustedProxiesDefault = "*"
+	}
+	apiKeyRedactionDefault, err := normalizeAPIKeyRedaction(os.Getenv("API_KEY_REDACTION"))
+	if err != nil {
+		logger.Warn("invalid API_KEY_REDACTION, using visitors", "error", err)
+		apiKeyRedactionDefault = apiKeyRedactionVisitors
+	}
 	port := envInt("PORT", 8081)
 
 	// ---- Database ----
-	db, err := NewDatabase(dbPath)
+	db, err := openDatabase(dbPath)
 	if err != nil {
-		log.Fatalf("database init failed: %v", err)
-	}
-	if err := db.EnsureProxySchema(); err != nil {
-		log.Fatalf("proxy schema init failed: %v", err)
+		fatal(logger, "open database failed", "error", err)
 	}
+	setAPITokenStore(db)
 	if err := db.EnsureSettingDefault(settingLogCleanupEnabled, strconv.FormatBool(logCleanupEnabled)); err != nil {
-		log.Fatalf("settings init failed: %v", err)
+		fatal(logger, "settings init failed", "error", err)
 	}
 	if err := db.EnsureSettingDefault(settingLogMaxSizeMB, strconv.Itoa(logMaxSizeMB)); err != nil {
-		log.Fatalf("settings init failed: %v", err)
+		fatal(logger, "settings init failed", "error", err)
+	}
+	if err := db.EnsureSettingDefault(settingLogMaxAgeDays, strconv.Itoa(logMaxAgeDays)); err != nil {
+		fatal(logger, "settings init failed", "error", err)
 	}
 	if err := db.EnsureSettingDefault(settingDefaultIntervalMin, strconv.Itoa(defaultIntervalMin)); err != nil {
-		log.Fatalf("settings init failed: %v", err)
+		fatal(logger, "settings init failed", "error", err)
 	}
 	if err := db.EnsureSettingDefault(settingProxyMasterToken, proxyMasterTokenDefault); err != nil {
-		log.Fatalf("settings init failed: %v", err)
+		fatal(logger, "settings init failed", "error", err)
 	}
 	if err := db.EnsureSettingDefault(settingVisitorModeEnabled, "true"); err != nil {
-		log.Fatalf("settings init failed: %v", err)
+		fatal(logger, "settings init failed", "error", err)
+	}
+	if err := db.EnsureSettingDefault(settingCertExpiryWarnDays, strconv.Itoa(certExpiryWarnDays)); err != nil {
+		fatal(logger, "settings init failed", "error", err)
+	}
+	if err := db.EnsureSettingDefault(settingMonitorPaused, "false"); err != nil {
+		fatal(logger, "settings init failed", "error", err)
+	}
+	if err := db.EnsureSettingDefault(settingLatencyAnomalySigma, strconv.Itoa(latencyAnomalySigma)); err != nil {
+		fatal(logger, "settings init failed", "error", err)
+	}
+	if err := db.EnsureSettingDefault(settingLatencyAnomalyPct, strconv.Itoa(latencyAnomalyPct)); err != nil {
+		fatal(logger, "settings init failed", "error", err)
+	}
+	if err := db.EnsureSettingDefault(settingNotifyFlapThreshold, strconv.Itoa(notifyFlapThreshold)); err != nil {
+		fatal(logger, "settings init failed", "error", err)
+	}
+	if err := db.EnsureSettingDefault(settingAdminIPAllowlist, adminIPAllowlistDefault); err != nil {
+		fatal(logger, "settings init failed", "error", err)
+	}
+	if err := db.EnsureSettingDefault(settingWriteIPAllowlist, writeIPAllowlistDefault); err != nil {
+		fatal(logger, "settings init failed", "error", err)
+	}
+	if err := db.EnsureSettingDefault(settingTrustedProxies, strings.TrimSpace(trustedProxiesDefault)); err != nil {
+		fatal(logger, "settings init failed", "error", err)
+	}
+	if err := db.EnsureSettingDefault(settingAPIKeyRedaction, apiKeyRedactionDefault); err != nil {
+		fatal(logger, "settings init failed", "error", err)
+	}
+	if err := db.EnsureSettingDefault(settingDetectConcurrency, strconv.Itoa(monitorDetectConcurrency)); err != nil {
+		fatal(logger, "settings init failed", "error", err)
+	}
+	if err := db.EnsureSettingDefault(settingMaxParallelTargets, strconv.Itoa(monitorMaxParallelTargets)); err != nil {
+		fatal(logger, "settings init failed", "error", err)
+	}
+	if err := db.EnsureSettingDefault(settingMaxRunDurationS, strconv.Itoa(monitorMaxRunDurationS)); err != nil {
+		fatal(logger, "settings init failed", "error", err)
+	}
+	if err := db.EnsureSettingDefault(settingRunArchiveDays, strconv.Itoa(runArchiveDays)); err != nil {
+		fatal(logger, "settings init failed", "error", err)
+	}
+	if err := db.EnsureSettingDefault(settingVisitorRateLimitPerMin, strconv.Itoa(visitorRateLimitPerMin)); err != nil {
+		fatal(logger, "settings init failed", "error", err)
+	}
+	if err := db.EnsureSettingDefault(settingVisitorRateLimitBurst, strconv.Itoa(visitorRateLimitBurst)); err != nil {
+		fatal(logger, "settings init failed", "error", err)
 	}
 	runtimeAdminAPIToken, adminTokenGenerated, err := resolveRuntimeSecret(
 		db,
@@ -167,7 +315,7 @@ func Start(webFS fs.FS) {
 		"amtk-",
 	)
 	if err != nil {
-		log.Fatalf("admin api token init failed: %v", err)
+		fatal(logger, "admin api token init failed", "error", err)
 	}
 
 	runtimeVisitorAPIToken, _, err := resolveOptionalRuntimeSecret(
@@ -176,72 +324,169 @@ func Start(webFS fs.FS) {
 		settingRuntimeVisitorAPIToken,
 	)
 	if err != nil {
-		log.Fatalf("visitor api token init failed: %v", err)
+		fatal(logger, "visitor api token init failed", "error", err)
 	}
 	setAuthTokens(runtimeAdminAPIToken, runtimeVisitorAPIToken)
 
 	settingValues, err := db.GetSettings([]string{
 		settingLogCleanupEnabled,
 		settingLogMaxSizeMB,
+		settingLogMaxAgeDays,
 		settingVisitorModeEnabled,
+		settingCertExpiryWarnDays,
+		settingMonitorPaused,
+		settingLatencyAnomalySigma,
+		settingLatencyAnomalyPct,
+		settingNotifyFlapThreshold,
+		settingPagerDutyRoutingKey,
+		settingAdminIPAllowlist,
+		settingWriteIPAllowlist,
+		settingTrustedProxies,
+		settingAPIKeyRedaction,
+		settingDetectConcurrency,
+		settingMaxParallelTargets,
+		settingMaxRunDurationS,
+		settingRunArchiveDays,
+		settingVisitorRateLimitPerMin,
+		settingVisitorRateLimitBurst,
 	})
 	if err != nil {
-		log.Fatalf("settings load failed: %v", err)
+		fatal(logger, "settings load failed", "error", err)
+	}
+	if err := setAPIKeyRedaction(settingValues[settingAPIKeyRedaction]); err != nil {
+		fatal(logger, "api key redaction invalid", "error", err)
+	}
+	if err := setIPAllowlists(settingValues[settingAdminIPAllowlist], settingValues[settingWriteIPAllowlist]); err != nil {
+		fatal(logger, "ip allowlist invalid", "error", err)
+	}
+	if err := setTrustedProxies(settingValues[settingTrustedProxies]); err != nil {
+		fatal(logger, "trusted proxies invalid", "error", err)
 	}
 	logCleanupEnabled = parseBoolString(settingValues[settingLogCleanupEnabled], logCleanupEnabled)
 	logMaxSizeMB = parseIntString(settingValues[settingLogMaxSizeMB], logMaxSizeMB)
 	if logMaxSizeMB < 0 {
 		logMaxSizeMB = 0
 	}
+	logMaxAgeDays = parseIntString(settingValues[settingLogMaxAgeDays], logMaxAgeDays)
+	certExpiryWarnDays = parseIntString(settingValues[settingCertExpiryWarnDays], certExpiryWarnDays)
+	if certExpiryWarnDays < 0 {
+		certExpiryWarnDays = 0
+	}
+	latencyAnomalySigma = parseIntString(settingValues[settingLatencyAnomalySigma], latencyAnomalySigma)
+	latencyAnomalyPct = parseIntString(settingValues[settingLatencyAnomalyPct], latencyAnomalyPct)
+	notifyFlapThreshold = parseIntString(settingValues[settingNotifyFlapThreshold], notifyFlapThreshold)
+	setPagerDutyRoutingKey(settingValues[settingPagerDutyRoutingKey])
+	monitorDetectConcurrency = parseIntString(settingValues[settingDetectConcurrency], monitorDetectConcurrency)
+	monitorMaxParallelTargets = parseIntString(settingValues[settingMaxParallelTargets], monitorMaxParallelTargets)
+	monitorMaxRunDurationS = parseIntString(settingValues[settingMaxRunDurationS], monitorMaxRunDurationS)
+	runArchiveDays = parseIntString(settingValues[settingRunArchiveDays], runArchiveDays)
+	monitorPaused := parseBoolString(settingValues[settingMonitorPaused], false)
 	visitorModeEnabled := parseBoolString(settingValues[settingVisitorModeEnabled], true)
 	setVisitorModeEnabled(visitorModeEnabled)
-	log.Printf("[main] database opened: %s", dbPath)
+	setVisitorRateLimit(
+		parseIntString(settingValues[settingVisitorRateLimitPerMin], visitorRateLimitPerMin),
+		parseIntString(settingValues[settingVisitorRateLimitBurst], visitorRateLimitBurst),
+	)
+	logger.Info("database opened", "path", dbPath)
+
+	proxyMasterToken, _, err := db.GetSetting(settingProxyMasterToken)
+	if err != nil {
+		fatal(logger, "settings load failed", "error", err)
+	}
+	diagnostics.merge(validateRuntimeConfig(runtimeDiagnosticsInput{
+		AdminToken:        runtimeAdminAPIToken,
+		VisitorToken:      runtimeVisitorAPIToken,
+		ProxyMasterToken:  strings.TrimSpace(proxyMasterToken),
+		VisitorMode:       visitorModeEnabled,
+		LogCleanupEnabled: logCleanupEnabled,
+		LogMaxSizeMB:      logMaxSizeMB,
+		LogMaxAgeDays:     logMaxAgeDays,
+	}))
+	diagnostics.Log()
+	if diagnostics.HasFatal() {
+		fatal(logger, "fatal configuration errors, refusing to start")
+	}
+	setStartupDiagnostics(diagnostics)
 
 	// ---- Monitor Service ----
 	monitor := NewMonitorService(MonitorConfig{
-		DB:                 db,
-		LogDir:             logDir,
-		DetectConcurrency:  monitorDetectConcurrency,
-		MaxParallelTargets: monitorMaxParallelTargets,
-		EnableLogCleanup:   logCleanupEnabled,
-		LogMaxBytes:        int64(logMaxSizeMB) * 1024 * 1024,
+		DB:                  db,
+		LogDir:              logDir,
+		DetectConcurrency:   monitorDetectConcurrency,
+		MaxParallelTargets:  monitorMaxParallelTargets,
+		MaxRunDuration:      time.Duration(monitorMaxRunDurationS) * time.Second,
+		EnableLogCleanup:    logCleanupEnabled,
+		LogMaxBytes:         int64(logMaxSizeMB) * 1024 * 1024,
+		LogMaxAgeDays:       logMaxAgeDays,
+		CertExpiryWarnDays:  certExpiryWarnDays,
+		SchedulerPaused:     monitorPaused,
+		LatencyAnomalySigma: latencyAnomalySigma,
+		LatencyAnomalyPct:   latencyAnomalyPct,
+		NotifyFlapThreshold: notifyFlapThreshold,
+		RunArchiveDays:      runArchiveDays,
+		ArchiveDir:          filepath.Join(dataDir, "archives"),
+		Logger:              baseLogger,
 	})
+	if err := monitor.ReloadRouteRules(); err != nil {
+		fatal(logger, "route rules load failed", "error", err)
+	}
+	if err := monitor.ReloadNotifications(); err != nil {
+		fatal(logger, "notifications load failed", "error", err)
+	}
 
 	// ---- SSE Event Bus ----
 	bus := NewSSEBus()
+	shutdownFanout, err := startEventFanout(baseLogger, bus.Publish)
+	if err != nil {
+		fatal(logger, "event fan-out configuration invalid", "error", err)
+	}
 	monitor.SetEventCallback(func(eventType, data string) {
 		bus.Publish(eventType, data)
+		publishMQTTEvent(eventType, data)
+		publishFanoutEvent(eventType, data)
+		pushRunMetrics(eventType, data)
 	})
 	monitor.Start()
 
-	log.Printf("[main] log cleanup config enabled=%v max_mb=%d", logCleanupEnabled, logMaxSizeMB)
-	log.Println("[main] auth=enabled")
+	logger.Info("log cleanup config", "enabled", logCleanupEnabled, "max_mb", logMaxSizeMB, "max_age_days", logMaxAgeDays)
+	if monitorPaused {
+		logger.Info("scheduler paused (POST /api/admin/monitor/resume to resume)")
+	}
+	logger.Info("auth enabled")
 	if adminTokenGenerated {
-		log.Printf("[main] generated API_MONITOR_TOKEN_ADMIN=%s", runtimeAdminAPIToken)
-		log.Println("[main] save this token now; it is required for write operations and /admin/login")
+		logger.Warn("generated API_MONITOR_TOKEN_ADMIN", "token", runtimeAdminAPIToken)
+		logger.Warn("save this token now; it is required for write operations and /admin/login")
 	}
 	if runtimeVisitorAPIToken == "" {
 		if visitorModeEnabled {
-			log.Println("[main] visitor mode=enabled (anonymous access, no token required)")
+			logger.Info("visitor mode enabled (anonymous access, no token required)")
 		} else {
-			log.Println("[main] visitor mode=disabled")
+			logger.Info("visitor mode disabled")
 		}
 	} else {
-		log.Println("[main] visitor mode=enabled (token required)")
+		logger.Info("visitor mode enabled (token required)")
 	}
 
 	adminSessions := NewAdminSessionManager(runtimeAdminAPIToken, 24*time.Hour)
 	if adminSessions.Enabled() {
-		log.Println("[main] admin panel=enabled")
+		logger.Info("admin panel enabled")
 	} else {
-		log.Fatal("[main] admin panel token is empty")
+		fatal(logger, "admin panel token is empty")
 	}
 
+	// OIDC browser sessions also authenticate dashboard API calls with their mapped role.
+	RegisterAuthenticator(oidcSessionAuthenticator{admin: adminSessions})
+
 	// ---- Handlers ----
-	h := &Handlers{db: db, monitor: monitor, bus: bus, admin: adminSessions}
+	proxyEvents := NewSSEBus()
+	h := &Handlers{db: db, monitor: monitor, bus: bus, admin: adminSessions, oidc: NewOIDCClient(), log: baseLogger, proxyEvents: proxyEvents}
+	h.sync, err = newTargetSyncerFromEnv(db, monitor, baseLogger)
+	if err != nil {
+		fatal(logger, "target sync configuration invalid", "error", err)
+	}
 
 	// ---- Router (Go 1.22+ ServeMux with path params) ----
-	mux := http.NewServeMux()
+	mux := newRouteMux()
 
 	// Static pages (no auth)
 	webContent, _ := fs.Sub(webFS, "web")
@@ -264,7 +509,7 @@ func Start(webFS fs.FS) {
 
 	mux.HandleFunc("GET /viewer.html", serveEmbeddedHTML(webFS, "web/log_viewer.html"))
 	mux.HandleFunc("GET /analysis.html", serveEmbeddedHTML(webFS, "web/analysis.html"))
-	mux.HandleFunc("GET /admin/login", serveEmbeddedHTML(webFS, "web/admin_login.html"))
+	mux.Handle("GET /admin/login", ipAllowlistMiddleware(ipAllowlistAdmin, serveEmbeddedHTML(webFS, "web/admin_login.html")))
 	mux.Handle("GET /admin.html", adminPageMiddleware(adminSessions, serveEmbeddedHTML(webFS, "web/admin.html")))
 	mux.Handle("GET /admin", adminPageMiddleware(adminSessions, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		http.Redirect(w, r, "/admin.html", http.StatusFound)
@@ -276,34 +521,117 @@ func Start(webFS fs.FS) {
 
 	// Health (no auth)
 	mux.HandleFunc("GET /api/health", h.Health)
-	mux.HandleFunc("POST /api/admin/login", h.AdminLogin)
+	mux.Handle("GET /api/openapi.json", openAPIHandler(mux))
+	mux.Handle("POST /api/admin/login", ipAllowlistMiddleware(ipAllowlistAdmin, http.HandlerFunc(h.AdminLogin)))
+	mux.Handle("GET /api/admin/oidc", ipAllowlistMiddleware(ipAllowlistAdmin, http.HandlerFunc(h.OIDCStatus)))
+	mux.Handle("GET /api/admin/oidc/login", ipAllowlistMiddleware(ipAllowlistAdmin, http.HandlerFunc(h.OIDCLogin)))
+	mux.Handle("GET /api/admin/oidc/callback", ipAllowlistMiddleware(ipAllowlistAdmin, http.HandlerFunc(h.OIDCCallback)))
 
 	// SSE (auth)
 	mux.Handle("GET /api/events", authAnyMiddleware(bus))
+	mux.Handle("GET /api/ws", authAnyMiddleware(bus.WebSocketHandler()))
 
 	// Protected API
 	mux.Handle("GET /api/dashboard", authAnyMiddleware(http.HandlerFunc(h.Dashboard)))
-	mux.Handle("GET /api/targets", authAnyMiddleware(http.HandlerFunc(h.ListTargets)))
+	mux.Handle("GET /api/incidents", authAnyMiddleware(http.HandlerFunc(h.ListIncidents)))
+	mux.Handle("GET /api/incidents/{id}", authAnyMiddleware(http.HandlerFunc(h.GetIncident)))
+	mux.Handle("POST /api/incidents/{id}/ack", authAnyMiddleware(http.HandlerFunc(h.AcknowledgeIncident)))
+	mux.Handle("POST /api/incidents/{id}/notes", authAnyMiddleware(http.HandlerFunc(h.AddIncidentNote)))
+	mux.Handle("GET /api/analytics/timeseries", authAnyMiddleware(compressMiddleware(http.HandlerFunc(h.Timeseries))))
+	mux.Handle("GET /api/analytics/models/{model}/compare", authAnyMiddleware(compressMiddleware(http.HandlerFunc(h.CompareModel))))
+	mux.Handle("GET /api/analytics/daily", authAnyMiddleware(compressMiddleware(http.HandlerFunc(h.DailyStats))))
+	mux.Handle("GET /api/analytics/errors", authAnyMiddleware(compressMiddleware(http.HandlerFunc(h.ErrorCategories))))
+	mux.Handle("GET /api/monitor/queue", authAnyMiddleware(http.HandlerFunc(h.MonitorQueue)))
+	mux.Handle("GET /api/targets", authAnyMiddleware(compressMiddleware(http.HandlerFunc(h.ListTargets))))
 	mux.Handle("GET /api/targets/{id}", authAnyMiddleware(http.HandlerFunc(h.GetTarget)))
 	mux.Handle("POST /api/targets", authAnyMiddleware(http.HandlerFunc(h.CreateTarget)))
+	mux.Handle("POST /api/targets/bulk", authAnyMiddleware(http.HandlerFunc(h.BulkTargets)))
+	mux.Handle("GET /api/targets/trash", authAnyMiddleware(http.HandlerFunc(h.ListTrash)))
+	mux.Handle("POST /api/targets/validate", authAnyMiddleware(http.HandlerFunc(h.ValidateTarget)))
+	mux.Handle("PATCH /api/targets/reorder", authAnyMiddleware(http.HandlerFunc(h.ReorderTargets)))
 	mux.Handle("PATCH /api/targets/{id}", authAnyMiddleware(http.HandlerFunc(h.PatchTarget)))
 	mux.Handle("DELETE /api/targets/{id}", authAnyMiddleware(http.HandlerFunc(h.DeleteTarget)))
 	mux.Handle("POST /api/targets/{id}/run", authAnyMiddleware(http.HandlerFunc(h.RunTarget)))
+	mux.Handle("POST /api/targets/{id}/cancel", authAnyMiddleware(http.HandlerFunc(h.CancelTarget)))
+	mux.Handle("POST /api/targets/{id}/test", authAnyMiddleware(http.HandlerFunc(h.TestTarget)))
+	mux.Handle("POST /api/targets/{id}/clone", authAnyMiddleware(http.HandlerFunc(h.CloneTarget)))
+	mux.Handle("POST /api/targets/{id}/restore", authAnyMiddleware(http.HandlerFunc(h.RestoreTarget)))
 	mux.Handle("GET /api/targets/{id}/runs", authAnyMiddleware(http.HandlerFunc(h.ListRuns)))
-	mux.Handle("GET /api/targets/{id}/logs", authAnyMiddleware(http.HandlerFunc(h.GetLogs)))
+	mux.Handle("GET /api/targets/{id}/runs/compare", authAnyMiddleware(http.HandlerFunc(h.CompareRuns)))
+	mux.Handle("GET /api/targets/{id}/notes", authAnyMiddleware(http.HandlerFunc(h.ListTargetNotes)))
+	mux.Handle("POST /api/targets/{id}/notes", authAnyMiddleware(http.HandlerFunc(h.CreateTargetNote)))
+	mux.Handle("DELETE /api/targets/{id}/notes/{note_id}", authAnyMiddleware(http.HandlerFunc(h.DeleteTargetNote)))
+	mux.Handle("GET /api/targets/{id}/logs", authAnyMiddleware(compressMiddleware(http.HandlerFunc(h.GetLogs))))
 	mux.Handle("GET /api/targets/{id}/models", authAnyMiddleware(http.HandlerFunc(h.GetTargetModels)))
+	mux.Handle("GET /api/targets/{id}/api-key", authAnyMiddleware(http.HandlerFunc(h.RevealTargetAPIKey)))
+	mux.Handle("GET /api/targets/{id}/usage", authAnyMiddleware(http.HandlerFunc(h.TargetUsage)))
+	mux.Handle("GET /api/targets/{id}/capabilities", authAnyMiddleware(http.HandlerFunc(h.ListTargetCapabilities)))
+	mux.Handle("GET /api/targets/{id}/models/{model}/history", authAnyMiddleware(http.HandlerFunc(h.ModelHistory)))
+	mux.Handle("GET /api/targets/{id}/keys", authAnyMiddleware(http.HandlerFunc(h.ListTargetKeys)))
+	mux.Handle("GET /api/targets/{id}/analytics/status-codes", authAnyMiddleware(compressMiddleware(http.HandlerFunc(h.StatusCodes))))
 	mux.Handle("PATCH /api/targets/{id}/models", authAnyMiddleware(http.HandlerFunc(h.PatchTargetModels)))
 	mux.Handle("GET /api/proxy/keys", adminAPIMiddleware(adminSessions, http.HandlerFunc(h.ListProxyKeys)))
 	mux.Handle("POST /api/proxy/keys", adminAPIMiddleware(adminSessions, http.HandlerFunc(h.CreateProxyKey)))
+	mux.Handle("PATCH /api/proxy/keys/{id}", adminAPIMiddleware(adminSessions, http.HandlerFunc(h.PatchProxyKey)))
 	mux.Handle("DELETE /api/proxy/keys/{id}", adminAPIMiddleware(adminSessions, http.HandlerFunc(h.RevokeProxyKey)))
+	mux.Handle("GET /api/proxy/keys/{id}/stats", adminAPIMiddleware(adminSessions, http.HandlerFunc(h.ProxyKeyStats)))
+	mux.Handle("GET /api/proxy/events", adminAPIMiddleware(adminSessions, http.HandlerFunc(h.ProxyEvents)))
+	mux.Handle("GET /api/proxy/audit", adminAPIMiddleware(adminSessions, http.HandlerFunc(h.ListProxyAudit)))
+	mux.Handle("GET /api/proxy/audit/{id}", adminAPIMiddleware(adminSessions, http.HandlerFunc(h.GetProxyAudit)))
 	mux.Handle("POST /api/admin/logout", adminAPIMiddleware(adminSessions, http.HandlerFunc(h.AdminLogout)))
 	mux.Handle("GET /api/admin/settings", adminAPIMiddleware(adminSessions, http.HandlerFunc(h.AdminGetSettings)))
 	mux.Handle("PATCH /api/admin/settings", adminAPIMiddleware(adminSessions, http.HandlerFunc(h.AdminPatchSettings)))
+	mux.Handle("POST /api/admin/monitor/pause", adminAPIMiddleware(adminSessions, http.HandlerFunc(h.AdminPauseMonitor)))
+	mux.Handle("POST /api/admin/monitor/resume", adminAPIMiddleware(adminSessions, http.HandlerFunc(h.AdminResumeMonitor)))
+	mux.Handle("POST /api/admin/reload", adminAPIMiddleware(adminSessions, http.HandlerFunc(h.AdminReload)))
+	mux.Handle("GET /api/admin/sync", adminAPIMiddleware(adminSessions, http.HandlerFunc(h.AdminGetTargetSync)))
+	mux.Handle("POST /api/admin/sync", adminAPIMiddleware(adminSessions, http.HandlerFunc(h.AdminRunTargetSync)))
+	mux.Handle("GET /api/admin/audit", adminAPIMiddleware(adminSessions, http.HandlerFunc(h.AdminListAudit)))
 	mux.Handle("GET /api/admin/resources", adminAPIMiddleware(adminSessions, http.HandlerFunc(h.AdminGetResources)))
+	mux.Handle("GET /api/admin/logs/usage", adminAPIMiddleware(adminSessions, http.HandlerFunc(h.AdminGetLogUsage)))
+	mux.Handle("/api/admin/debug/pprof/", adminAPIMiddleware(adminSessions, adminPprofHandler()))
+	mux.Handle("GET /api/admin/diagnostics", adminAPIMiddleware(adminSessions, http.HandlerFunc(h.AdminGetDiagnostics)))
+	mux.Handle("GET /api/admin/route-rules", adminAPIMiddleware(adminSessions, http.HandlerFunc(h.AdminListRouteRules)))
+	mux.Handle("POST /api/admin/route-rules", adminAPIMiddleware(adminSessions, http.HandlerFunc(h.AdminCreateRouteRule)))
+	mux.Handle("GET /api/admin/route-rules/resolve", adminAPIMiddleware(adminSessions, http.HandlerFunc(h.AdminResolveRoute)))
+	mux.Handle("PATCH /api/admin/route-rules/{id}", adminAPIMiddleware(adminSessions, http.HandlerFunc(h.AdminPatchRouteRule)))
+	mux.Handle("DELETE /api/admin/route-rules/{id}", adminAPIMiddleware(adminSessions, http.HandlerFunc(h.AdminDeleteRouteRule)))
+	mux.Handle("GET /api/admin/notifications/channels", adminAPIMiddleware(adminSessions, http.HandlerFunc(h.AdminListNotificationChannels)))
+	mux.Handle("POST /api/admin/notifications/channels", adminAPIMiddleware(adminSessions, http.HandlerFunc(h.AdminCreateNotificationChannel)))
+	mux.Handle("PATCH /api/admin/notifications/channels/{id}", adminAPIMiddleware(adminSessions, http.HandlerFunc(h.AdminPatchNotificationChannel)))
+	mux.Handle("DELETE /api/admin/notifications/channels/{id}", adminAPIMiddleware(adminSessions, http.HandlerFunc(h.AdminDeleteNotificationChannel)))
+	mux.Handle("POST /api/admin/notifications/channels/{id}/test", adminAPIMiddleware(adminSessions, http.HandlerFunc(h.AdminTestNotificationChannel)))
+	mux.Handle("GET /api/admin/notifications/routes", adminAPIMiddleware(adminSessions, http.HandlerFunc(h.AdminListNotificationRoutes)))
+	mux.Handle("POST /api/admin/notifications/routes", adminAPIMiddleware(adminSessions, http.HandlerFunc(h.AdminCreateNotificationRoute)))
+	mux.Handle("PATCH /api/admin/notifications/routes/{id}", adminAPIMiddleware(adminSessions, http.HandlerFunc(h.AdminPatchNotificationRoute)))
+	mux.Handle("DELETE /api/admin/notifications/routes/{id}", adminAPIMiddleware(adminSessions, http.HandlerFunc(h.AdminDeleteNotificationRoute)))
+	mux.Handle("GET /api/admin/templates", adminAPIMiddleware(adminSessions, http.HandlerFunc(h.AdminListTemplates)))
+	mux.Handle("POST /api/admin/templates", adminAPIMiddleware(adminSessions, http.HandlerFunc(h.AdminCreateTemplate)))
+	mux.Handle("PATCH /api/admin/templates/{id}", adminAPIMiddleware(adminSessions, http.HandlerFunc(h.AdminPatchTemplate)))
+	mux.Handle("DELETE /api/admin/templates/{id}", adminAPIMiddleware(adminSessions, http.HandlerFunc(h.AdminDeleteTemplate)))
+	mux.Handle("GET /api/admin/agents", adminAPIMiddleware(adminSessions, http.HandlerFunc(h.AdminListAgents)))
+	mux.Handle("POST /api/admin/agents", adminAPIMiddleware(adminSessions, http.HandlerFunc(h.AdminCreateAgent)))
+	mux.Handle("DELETE /api/admin/agents/{id}", adminAPIMiddleware(adminSessions, http.HandlerFunc(h.AdminRevokeAgent)))
+	mux.Handle("GET /api/admin/api-tokens", adminAPIMiddleware(adminSessions, http.HandlerFunc(h.AdminListAPITokens)))
+	mux.Handle("POST /api/admin/api-tokens", adminAPIMiddleware(adminSessions, http.HandlerFunc(h.AdminCreateAPIToken)))
+	mux.Handle("DELETE /api/admin/api-tokens/{id}", adminAPIMiddleware(adminSessions, http.HandlerFunc(h.AdminRevokeAPIToken)))
+	mux.Handle("DELETE /api/admin/trash/{id}", adminAPIMiddleware(adminSessions, http.HandlerFunc(h.AdminPurgeTarget)))
+	mux.Handle("GET /api/admin/archives", adminAPIMiddleware(adminSessions, http.HandlerFunc(h.AdminListArchives)))
+	mux.Handle("POST /api/admin/archives/run", adminAPIMiddleware(adminSessions, http.HandlerFunc(h.AdminRunArchive)))
+	mux.Handle("GET /api/admin/archives/{name}", adminAPIMiddleware(adminSessions, http.HandlerFunc(h.AdminDownloadArchive)))
+	mux.Handle("GET /api/admin/reports/preview", adminAPIMiddleware(adminSessions, http.HandlerFunc(h.AdminPreviewReport)))
+	mux.Handle("POST /api/admin/reports/send", adminAPIMiddleware(adminSessions, http.HandlerFunc(h.AdminSendReport)))
 	mux.Handle("GET /api/admin/channels", adminAPIMiddleware(adminSessions, http.HandlerFunc(h.AdminListChannels)))
 	mux.Handle("PATCH /api/admin/channels/{id}/advanced", adminAPIMiddleware(adminSessions, http.HandlerFunc(h.AdminPatchChannelAdvanced)))
 	mux.Handle("GET /api/admin/channels/{id}/models", adminAPIMiddleware(adminSessions, http.HandlerFunc(h.AdminGetChannelModels)))
+	mux.Handle("GET /api/admin/channels/{id}/api-key", adminAPIMiddleware(adminSessions, http.HandlerFunc(h.RevealTargetAPIKey)))
 	mux.Handle("PATCH /api/admin/channels/{id}/models", adminAPIMiddleware(adminSessions, http.HandlerFunc(h.AdminPatchChannelModels)))
+	mux.Handle("POST /api/admin/import/oneapi", adminAPIMiddleware(adminSessions, http.HandlerFunc(h.AdminImportOneAPI)))
+
+	// Probe agent endpoints (authenticated by agent token)
+	mux.Handle("POST /api/agent/register", agentAPIMiddleware(db, http.HandlerFunc(h.AgentRegister)))
+	mux.Handle("GET /api/agent/assignments", agentAPIMiddleware(db, http.HandlerFunc(h.AgentAssignments)))
+	mux.Handle("POST /api/agent/results", agentAPIMiddleware(db, http.HandlerFunc(h.AgentSubmitResults)))
 
 	// Public proxy endpoints (authenticated by proxy key in Authorization header)
 	mux.HandleFunc("GET /v1/models", h.ProxyModels)
@@ -316,48 +644,66 @@ func Start(webFS fs.FS) {
 	addr := fmt.Sprintf("0.0.0.0:%d", port)
 	srv := &http.Server{
 		Addr:    addr,
-		Handler: mux,
+		Handler: apiVersionMiddleware(bodyLimitMiddleware(mux)),
 	}
 
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
 	defer stop()
+	h.watchReloadSignal(ctx.Done())
+	if h.sync != nil {
+		go h.sync.Run(ctx)
+	}
 
 	go func() {
-		log.Printf("[main] api_monitor started on %s", addr)
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("server error: %v", err)
+		scheme := "http"
+		if tlsConfig.Enabled() {
+			scheme = "https"
+		}
+		logger.Info("api_monitor started", "addr", addr, "scheme", scheme)
+		if err := listenAndServe(srv, tlsConfig); err != nil && err != http.ErrServerClosed {
+			fatal(logger, "server error", "error", err)
 		}
 	}()
 
 	<-ctx.Done()
-	log.Println("[main] shutdown signal received, stopping...")
+	logger.Info("shutdown signal received, stopping")
 
 	// 1. Stop scheduler so no new detections are triggered
-	log.Println("[main] stopping monitor scheduler...")
+	logger.Info("stopping monitor scheduler")
 	monitor.StopScheduler()
 
 	// 2. Close SSE bus to disconnect all SSE clients
-	log.Println("[main] closing SSE connections...")
+	logger.Info("closing SSE connections")
 	bus.Close()
+	proxyEvents.Close()
 
 	// 3. Shutdown HTTP server (now quick since SSE clients are gone)
 	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	if err := srv.Shutdown(shutdownCtx); err != nil {
-		log.Printf("[main] HTTP server shutdown error: %v", err)
+		logger.Error("HTTP server shutdown failed", "error", err)
 	} else {
-		log.Println("[main] HTTP server stopped")
+		logger.Info("HTTP server stopped")
 	}
 
 	// 4. Wait for in-flight detections to finish
-	log.Println("[main] waiting for running detections to finish...")
+	logger.Info("waiting for running detections to finish")
 	monitor.WaitDetections()
 
-	// 5. Close database
-	log.Println("[main] closing database...")
+	// 5. Flush pending trace spans and detection results
+	flushCtx, cancelFlush := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelFlush()
+	shutdownTracing(flushCtx)
+	shutdownResultSink(flushCtx)
+	shutdownMQTT(flushCtx)
+	shutdownFanout(flushCtx)
+	shutdownMetricsPush(flushCtx)
+
+	// 6. Close database
+	logger.Info("closing database")
 	if err := db.Close(); err != nil {
-		log.Printf("[main] database close error: %v", err)
+		logger.Error("database close failed", "error", err)
 	}
 
-	log.Println("[main] shutdown completed")
+	logger.Info("shutdown completed")
 }
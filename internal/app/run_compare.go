@@ -0,0 +1,208 @@
+package app
+
+import (
+	"cmp"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+)
+
+// Run comparison: what changed between two runs of a target, per model and endpoint.
+// Used after an incident to see which models broke, which came back and how latency moved.
+
+// runCompareSide is one model's result in one of the compared runs.
+type runCompareSide struct {
+	Success    bool     `json:"success"`
+	Duration   *float64 `json:"duration"`
+	Error      *string  `json:"error,omitempty"`
+	StatusCode *int     `json:"status_code,omitempty"`
+}
+
+// runCompareEntry is a model (and endpoint, when probed on several) present in either run.
+type runCompareEntry struct {
+	Model    string          `json:"model"`
+	Endpoint string          `json:"endpoint,omitempty"`
+	Base     *runCompareSide `json:"base,omitempty"`
+	Head     *runCompareSide `json:"head,omitempty"`
+	// DurationDelta is head minus base duration in seconds, for models successful in both.
+	DurationDelta *float64 `json:"duration_delta,omitempty"`
+	// DurationDeltaPct is DurationDelta relative to the base duration.
+	DurationDeltaPct *float64 `json:"duration_delta_pct,omitempty"`
+}
+
+// runComparison is the body of GET /api/targets/{id}/runs/compare.
+type runComparison struct {
+	Base         *Run              `json:"base"`
+	Head         *Run              `json:"head"`
+	NewlyFailed  []runCompareEntry `json:"newly_failed"`
+	Recovered    []runCompareEntry `json:"recovered"`
+	Disappeared  []runCompareEntry `json:"disappeared"`
+	Appeared     []runCompareEntry `json:"appeared"`
+	StillFailing []runCompareEntry `json:"still_failing"`
+	Latency      []runCompareEntry `json:"latency"`
+	UnchangedOK  int               `json:"unchanged_ok"`
+	// MedianDelta is the median DurationDelta over Latency, in seconds.
+	MedianDelta *float64 `json:"median_duration_delta"`
+}
+
+type runCompareKey struct {
+	model, endpoint string
+}
+
+// indexRunRows keys rows by model and endpoint; a later row for the same key wins.
+func indexRunRows(rows []ModelRow) (map[runCompareKey]*runCompareSide, []runCompareKey) {
+	index := map[runCompareKey]*runCompareSide{}
+	var order []runCompareKey
+	for _, row := range rows {
+		if row.Model == nil || *row.Model == "" {
+			continue
+		}
+		key := runCompareKey{model: *row.Model}
+		if row.Endpoint != nil {
+			key.endpoint = *row.Endpoint
+		}
+		if _, seen := index[key]; !seen {
+			order = append(order, key)
+		}
+		index[key] = &runCompareSide{Success: row.Success, Duration: row.Duration, Error: row.Error, StatusCode: row.StatusCode}
+	}
+	return index, order
+}
+
+// compareRuns diffs the rows of two runs. Models missing from a partial run were simply
+// not probed, so disappeared is only reported for a full head run and appeared only
+// against a full base run.
+func compareRuns(base, head *Run, baseRows, headRows []ModelRow) runComparison {
+	out := runComparison{
+		Base: base, Head: head,
+		NewlyFailed: []runCompareEntry{}, Recovered: []runCompareEntry{},
+		Disappeared: []runCompareEntry{}, Appeared: []runCompareEntry{},
+		StillFailing: []runCompareEntry{}, Latency: []runCompareEntry{},
+	}
+	baseIndex, baseOrder := indexRunRows(baseRows)
+	headIndex, headOrder := indexRunRows(headRows)
+
+	var deltas []float64
+	for _, key := range headOrder {
+		h := headIndex[key]
+		entry := runCompareEntry{Model: key.model, Endpoint: key.endpoint, Head: h}
+		b, ok := baseIndex[key]
+		if !ok {
+			if base.Mode == runModeFull {
+				out.Appeared = append(out.Appeared, entry)
+			}
+			continue
+		}
+		entry.Base = b
+		switch {
+		case b.Success && !h.Success:
+			out.NewlyFailed = append(out.NewlyFailed, entry)
+		case !b.Success && h.Success:
+			out.Recovered = append(out.Recovered, entry)
+		case !b.Success && !h.Success:
+			out.StillFailing = append(out.StillFailing, entry)
+		default:
+			out.UnchangedOK++
+			if b.Duration != nil && h.Duration != nil {
+				delta := *h.Duration - *b.Duration
+				entry.DurationDelta = &delta
+				if *b.Duration > 0 {
+					pct := math.Round(delta/(*b.Duration)*1000) / 10
+					entry.DurationDeltaPct = &pct
+				}
+				deltas = append(deltas, delta)
+				out.Latency = append(out.Latency, entry)
+			}
+		}
+	}
+	if head.Mode == runModeFull {
+		for _, key := range baseOrder {
+			if _, ok := headIndex[key]; !ok {
+				out.Disappeared = append(out.Disappeared, runCompareEntry{Model: key.model, Endpoint: key.endpoint, Base: baseIndex[key]})
+			}
+		}
+	}
+
+	// Biggest latency changes first, in either direction.
+	slices.SortStableFunc(out.Latency, func(a, b runCompareEntry) int {
+		return cmp.Compare(math.Abs(*b.DurationDelta), math.Abs(*a.DurationDelta))
+	})
+	if len(deltas) > 0 {
+		slices.Sort(deltas)
+		median := deltas[len(deltas)/2]
+		if len(deltas)%2 == 0 {
+			median = (deltas[len(deltas)/2-1] + deltas[len(deltas)/2]) / 2
+		}
+		out.MedianDelta = &median
+	}
+	return out
+}
+
+// CompareRuns -- GET /api/targets/{id}/runs/compare?base=<run>&head=<run>
+// head defaults to the latest run and base to the run before head.
+func (h *Handlers) CompareRuns(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(r)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "invalid id"})
+		return
+	}
+	target, err := h.db.GetTarget(id)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return
+	}
+	if target == nil {
+		writeJSON(w, http.StatusNotFound, map[string]any{"detail": "target not found"})
+		return
+	}
+
+	loadRun := func(param string, fallback func() (*Run, error)) (*Run, bool) {
+		var run *Run
+		var err error
+		if raw := r.URL.Query().Get(param); raw != "" {
+			runID, convErr := strconv.Atoi(raw)
+			if convErr != nil || runID < 1 {
+				writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "invalid " + param})
+				return nil, false
+			}
+			run, err = h.db.GetRun(id, runID)
+		} else {
+			run, err = fallback()
+		}
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+			return nil, false
+		}
+		if run == nil {
+			writeJSON(w, http.StatusNotFound, map[string]any{"detail": param + " run not found"})
+			return nil, false
+		}
+		return run, true
+	}
+
+	head, ok := loadRun("head", func() (*Run, error) { return h.db.GetLatestRun(id) })
+	if !ok {
+		return
+	}
+	base, ok := loadRun("base", func() (*Run, error) { return h.db.GetPreviousRun(id, head.ID) })
+	if !ok {
+		return
+	}
+	if base.ID == head.ID {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "base and head must be different runs"})
+		return
+	}
+
+	baseRows, err := h.db.ListLogs(id, &base.ID, 20000)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return
+	}
+	headRows, err := h.db.ListLogs(id, &head.ID, 20000)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"item": compareRuns(base, head, baseRows, headRows)})
+}
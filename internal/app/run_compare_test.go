@@ -0,0 +1,100 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestCompareRuns(t *testing.T) {
+	db, err := NewDatabase(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDatabase failed: %v", err)
+	}
+	t.Cleanup(func() { _ = db.conn.Close() })
+	h := &Handlers{db: db}
+	target, err := db.CreateTarget(map[string]any{"name": "relay", "base_url": "https://relay.example.com", "api_key": "k"})
+	if err != nil {
+		t.Fatalf("CreateTarget failed: %v", err)
+	}
+
+	failed := "HTTP 500"
+	record := func(rows []DetectionResult) int {
+		runID, err := db.CreateRun(target.ID, 1, "", nil, runModeFull)
+		if err != nil {
+			t.Fatalf("CreateRun failed: %v", err)
+		}
+		if err := db.InsertModelRows(runID, target.ID, rows); err != nil {
+			t.Fatalf("InsertModelRows failed: %v", err)
+		}
+		if err := db.FinishRun(runID, "completed", 2, len(rows), 0, 0, nil); err != nil {
+			t.Fatalf("FinishRun failed: %v", err)
+		}
+		return runID
+	}
+	base := record([]DetectionResult{
+		{Model: "steady", Success: true, Duration: 1.0},
+		{Model: "slower", Success: true, Duration: 1.0},
+		{Model: "breaks", Success: true, Duration: 1.0},
+		{Model: "heals", Success: false, Error: &failed},
+		{Model: "gone", Success: true, Duration: 1.0},
+	})
+	head := record([]DetectionResult{
+		{Model: "steady", Success: true, Duration: 1.1},
+		{Model: "slower", Success: true, Duration: 3.0},
+		{Model: "breaks", Success: false, Error: &failed},
+		{Model: "heals", Success: true, Duration: 1.0},
+		{Model: "new", Success: true, Duration: 1.0},
+	})
+
+	compare := func(query string) (int, runComparison) {
+		req := httptest.NewRequest(http.MethodGet, "/api/targets/1/runs/compare"+query, nil)
+		req.SetPathValue("id", strconv.Itoa(target.ID))
+		rr := httptest.NewRecorder()
+		h.CompareRuns(rr, withAuthRole(req, authRoleAdmin))
+		var out struct {
+			Item runComparison `json:"item"`
+		}
+		_ = json.Unmarshal(rr.Body.Bytes(), &out)
+		return rr.Code, out.Item
+	}
+
+	code, got := compare("?base=" + strconv.Itoa(base) + "&head=" + strconv.Itoa(head))
+	if code != http.StatusOK {
+		t.Fatalf("compare should succeed, got=%d", code)
+	}
+	names := func(entries []runCompareEntry) []string {
+		out := []string{}
+		for _, e := range entries {
+			out = append(out, e.Model)
+		}
+		return out
+	}
+	for label, pair := range map[string][2][]string{
+		"newly_failed": {names(got.NewlyFailed), {"breaks"}},
+		"recovered":    {names(got.Recovered), {"heals"}},
+		"disappeared":  {names(got.Disappeared), {"gone"}},
+		"appeared":     {names(got.Appeared), {"new"}},
+		"latency":      {names(got.Latency), {"slower", "steady"}},
+	} {
+		if len(pair[0]) != len(pair[1]) || (len(pair[0]) > 0 && pair[0][0] != pair[1][0]) {
+			t.Fatalf("%s should be %v, got=%v", label, pair[1], pair[0])
+		}
+	}
+	if d := got.Latency[0].DurationDelta; d == nil || *d != 2.0 || *got.Latency[0].DurationDeltaPct != 200 {
+		t.Fatalf("latency delta should be head minus base, got=%+v", got.Latency[0])
+	}
+
+	if code, def := compare(""); code != http.StatusOK || def.Base.ID != base || def.Head.ID != head {
+		t.Fatalf("defaults should compare the latest run with the one before it, got=%d %+v", code, def)
+	}
+	if code, _ := compare("?base=" + strconv.Itoa(head)); code != http.StatusBadRequest {
+		t.Fatalf("comparing a run with itself should be rejected, got=%d", code)
+	}
+	if code, _ := compare("?base=999"); code != http.StatusNotFound {
+		t.Fatalf("unknown run should be 404, got=%d", code)
+	}
+}
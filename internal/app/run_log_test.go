@@ -0,0 +1,122 @@
+package app
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFinishedRunLogIsCompressed(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/models" {
+			_, _ = w.Write([]byte(`{"data":[{"id":"gpt-a"}]}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"choices":[{"message":{"content":"ok"}}]}`))
+	}))
+	defer srv.Close()
+
+	db, err := NewDatabase(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDatabase failed: %v", err)
+	}
+	t.Cleanup(func() { _ = db.conn.Close() })
+	logDir := t.TempDir()
+	ms := NewMonitorService(MonitorConfig{DB: db, LogDir: logDir})
+	target, err := db.CreateTarget(map[string]any{"name": "relay", "base_url": srv.URL, "api_key": "k"})
+	if err != nil {
+		t.Fatalf("CreateTarget failed: %v", err)
+	}
+	if ok, msg := ms.TriggerTarget(target.ID, true); !ok {
+		t.Fatalf("TriggerTarget failed: %s", msg)
+	}
+	ms.WaitDetections()
+
+	got, _ := db.GetTarget(target.ID)
+	if got.LastLogFile == nil || !strings.HasSuffix(*got.LastLogFile, ".jsonl.gz") {
+		t.Fatalf("target should point at the compressed log, got=%v", got.LastLogFile)
+	}
+	run, _ := db.GetLatestRun(target.ID)
+	if run == nil || run.LogFile == nil || *run.LogFile != *got.LastLogFile {
+		t.Fatalf("run should point at the compressed log, got=%+v", run)
+	}
+	if _, err := os.Stat(strings.TrimSuffix(*got.LastLogFile, ".gz")); !os.IsNotExist(err) {
+		t.Fatalf("plain log should be removed, stat err=%v", err)
+	}
+	f, err := os.Open(*got.LastLogFile)
+	if err != nil {
+		t.Fatalf("compressed log should exist: %v", err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("log should be gzip: %v", err)
+	}
+	raw, _ := io.ReadAll(gz)
+	if !strings.Contains(string(raw), `"model":"gpt-a"`) {
+		t.Fatalf("compressed log should hold the run rows, got=%s", raw)
+	}
+	entries, _ := os.ReadDir(logDir)
+	if len(entries) != 1 {
+		t.Fatalf("log dir should hold only the compressed log, got=%d entries", len(entries))
+	}
+}
+
+func TestLogCleanupRemovesLogsPastTheirAge(t *testing.T) {
+	db, err := NewDatabase(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDatabase failed: %v", err)
+	}
+	t.Cleanup(func() { _ = db.conn.Close() })
+	logDir := t.TempDir()
+	ms := NewMonitorService(MonitorConfig{DB: db, LogDir: logDir, EnableLogCleanup: true, LogMaxBytes: 1 << 30, LogMaxAgeDays: 30})
+	h := &Handlers{db: db, monitor: ms}
+	strict, _ := db.CreateTarget(map[string]any{"name": "strict", "base_url": "https://example.com", "api_key": "k", "log_max_age_days": float64(3)})
+	loose, _ := db.CreateTarget(map[string]any{"name": "loose", "base_url": "https://example.com", "api_key": "k"})
+
+	writeLog := func(targetID int, name string, age time.Duration) string {
+		path := filepath.Join(logDir, fmt.Sprintf("target_%d_%s.jsonl.gz", targetID, name))
+		if err := os.WriteFile(path, []byte("log"), 0o644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+		at := time.Now().Add(-age)
+		_ = os.Chtimes(path, at, at)
+		return path
+	}
+	strictOld := writeLog(strict.ID, "a", 5*24*time.Hour)
+	strictNew := writeLog(strict.ID, "b", time.Hour)
+	looseMid := writeLog(loose.ID, "a", 5*24*time.Hour)
+	looseOld := writeLog(loose.ID, "b", 40*24*time.Hour)
+
+	ms.cleanupDataLogs()
+	for path, kept := range map[string]bool{strictOld: false, strictNew: true, looseMid: true, looseOld: false} {
+		if _, err := os.Stat(path); (err == nil) != kept {
+			t.Fatalf("%s kept=%v, want kept=%v", filepath.Base(path), err == nil, kept)
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	h.AdminGetLogUsage(rr, withAuthRole(httptest.NewRequest(http.MethodGet, "/api/admin/logs/usage", nil), authRoleAdmin))
+	var resp struct {
+		Items []LogUsage `json:"items"`
+		Files int        `json:"files"`
+	}
+	_ = json.Unmarshal(rr.Body.Bytes(), &resp)
+	if resp.Files != 2 || len(resp.Items) != 2 {
+		t.Fatalf("usage should report one log per target, got=%s", rr.Body.String())
+	}
+	for _, u := range resp.Items {
+		want := map[int]int{strict.ID: 3, loose.ID: 30}[u.TargetID]
+		if u.Files != 1 || u.Bytes != 3 || u.MaxAgeDays != want {
+			t.Fatalf("usage of target %d should be one 3-byte log with max age %d, got=%+v", u.TargetID, want, u)
+		}
+	}
+}
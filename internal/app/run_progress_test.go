@@ -0,0 +1,92 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestRunEmitsProgressEvents(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/models":
+			_, _ = w.Write([]byte(`{"data":[{"id":"gpt-a"},{"id":"gpt-b"},{"id":"claude-3-5-sonnet"}]}`))
+		case "/v1/responses":
+			_, _ = w.Write([]byte(`{"output":[{"type":"message","content":[{"type":"output_text","text":"ok"}]}]}`))
+		case "/v1/messages":
+			_, _ = w.Write([]byte(`{"type":"message","content":[{"type":"text","text":"ok"}]}`))
+		default:
+			_, _ = w.Write([]byte(`{"choices":[{"message":{"content":"ok"}}]}`))
+		}
+	}))
+	defer srv.Close()
+
+	db, err := NewDatabase(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDatabase failed: %v", err)
+	}
+	t.Cleanup(func() { _ = db.conn.Close() })
+	ms := NewMonitorService(MonitorConfig{DB: db, LogDir: t.TempDir()})
+	type event struct {
+		kind string
+		data map[string]any
+	}
+	var mu sync.Mutex
+	var events []event
+	ms.SetEventCallback(func(eventType, data string) {
+		switch eventType {
+		case "run_started", "model_checked", "run_progress":
+			var payload map[string]any
+			_ = json.Unmarshal([]byte(data), &payload)
+			mu.Lock()
+			events = append(events, event{eventType, payload})
+			mu.Unlock()
+		}
+	})
+	target, err := db.CreateTarget(map[string]any{"name": "relay", "base_url": srv.URL, "api_key": "k", "probe_endpoints": []string{"responses"}})
+	if err != nil {
+		t.Fatalf("CreateTarget failed: %v", err)
+	}
+	if ok, msg := ms.TriggerTarget(target.ID, true); !ok {
+		t.Fatalf("TriggerTarget failed: %s", msg)
+	}
+	ms.WaitDetections()
+
+	// gpt-a and gpt-b on chat and responses, claude on messages.
+	const planned = 5
+	mu.Lock()
+	defer mu.Unlock()
+	if len(events) == 0 || events[0].kind != "run_started" || events[0].data["total"] != float64(planned) {
+		t.Fatalf("run_started should come first with the planned route count, got=%+v", events)
+	}
+	checked, done := 0, 0
+	for i, ev := range events[1:] {
+		switch ev.kind {
+		case "model_checked":
+			checked++
+			if ev.data["model"] == "" || ev.data["endpoint"] == "" || ev.data["success"] != true {
+				t.Fatalf("model_checked should describe the row, got=%+v", ev.data)
+			}
+		case "run_progress":
+			done++
+			if ev.data["done"] != float64(done) || ev.data["total"] != float64(planned) {
+				t.Fatalf("run_progress #%d should report done=%d of %d, got=%+v", i, done, planned, ev.data)
+			}
+			if checked != done {
+				t.Fatalf("run_progress should follow each model_checked, got checked=%d done=%d", checked, done)
+			}
+		default:
+			t.Fatalf("unexpected %s after run_started", ev.kind)
+		}
+	}
+	rows, err := db.GetLatestModelStatuses(target.ID)
+	if err != nil {
+		t.Fatalf("GetLatestModelStatuses failed: %v", err)
+	}
+	if checked != planned || done != planned || len(rows) != planned {
+		t.Fatalf("expected one model_checked per result row, checked=%d done=%d rows=%d", checked, done, len(rows))
+	}
+}
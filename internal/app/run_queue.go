@@ -0,0 +1,185 @@
+package app
+
+import (
+	"net/http"
+	"slices"
+	"time"
+)
+
+// Run queue. When max_parallel_targets runs are in flight, forced (manual) runs wait
+// here instead of being rejected and start as slots free up, highest priority first and
+// FIFO within a priority. Scheduled runs are still skipped while the queue is busy; the
+// next scan picks them up.
+
+// queuedRun is a forced run waiting for a free slot.
+type queuedRun struct {
+	targetID   int
+	plan       runPlan
+	enqueuedAt time.Time
+}
+
+// activeRun describes a local run in flight, for the queue view and the stuck-run watchdog.
+type activeRun struct {
+	seq       uint64
+	mode      string
+	priority  int
+	startedAt time.Time
+	// deadline is the run's max duration; 0 means none.
+	deadline time.Duration
+}
+
+// newActiveRunLocked numbers a run that is about to start. ms.mu must be held.
+func (ms *MonitorService) newActiveRunLocked(plan runPlan) activeRun {
+	ms.runSeq++
+	return activeRun{seq: ms.runSeq, mode: plan.mode, priority: plan.priority, startedAt: time.Now()}
+}
+
+// runQueueEntry is one row of GET /api/monitor/queue.
+type runQueueEntry struct {
+	TargetID   int    `json:"target_id"`
+	TargetName string `json:"target_name"`
+	// Position is 1-based within pending runs and 0 for running ones.
+	Position int    `json:"position"`
+	Mode     string `json:"mode"`
+	Priority int    `json:"priority"`
+	// Agent marks running targets leased to a remote agent.
+	Agent      bool     `json:"agent,omitempty"`
+	EnqueuedAt *float64 `json:"enqueued_at,omitempty"`
+	StartedAt  *float64 `json:"started_at,omitempty"`
+}
+
+// enqueueRunLocked inserts a run behind every queued run of the same or higher
+// priority and returns its 1-based position. ms.mu must be held.
+func (ms *MonitorService) enqueueRunLocked(targetID int, plan runPlan) int {
+	entry := &queuedRun{targetID: targetID, plan: plan, enqueuedAt: time.Now()}
+	pos := len(ms.runQueue)
+	for i, q := range ms.runQueue {
+		if q.plan.priority < plan.priority {
+			pos = i
+			break
+		}
+	}
+	ms.runQueue = slices.Insert(ms.runQueue, pos, entry)
+	return pos + 1
+}
+
+// queuePositionLocked returns the 1-based queue position of a target, or 0 when it is
+// not queued. ms.mu must be held.
+func (ms *MonitorService) queuePositionLocked(targetID int) int {
+	for i, q := range ms.runQueue {
+		if q.targetID == targetID {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// QueuePosition returns the 1-based queue position of a target, or 0 when it is not queued.
+func (ms *MonitorService) QueuePosition(targetID int) int {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	return ms.queuePositionLocked(targetID)
+}
+
+// dequeueRun drops a queued run; it reports whether the target was queued.
+func (ms *MonitorService) dequeueRun(targetID int) bool {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	pos := ms.queuePositionLocked(targetID)
+	if pos == 0 {
+		return false
+	}
+	ms.runQueue = slices.Delete(ms.runQueue, pos-1, pos)
+	return true
+}
+
+// startQueuedRuns starts queued runs while slots are free. It is called whenever a
+// slot may have been released and on every scheduler scan.
+func (ms *MonitorService) startQueuedRuns() {
+	for {
+		ms.mu.Lock()
+		if ms.queueStopped || len(ms.runQueue) == 0 || len(ms.runningTargets) >= ms.maxParallelTargets {
+			ms.mu.Unlock()
+			return
+		}
+		next := ms.runQueue[0]
+		ms.runQueue = ms.runQueue[1:]
+		if ms.runningTargets[next.targetID] {
+			// Leased to an agent or started directly while it waited.
+			ms.mu.Unlock()
+			continue
+		}
+		ms.runningTargets[next.targetID] = true
+		ms.activeRuns[next.targetID] = ms.newActiveRunLocked(next.plan)
+		ms.mu.Unlock()
+
+		target, err := ms.db.GetTarget(next.targetID)
+		if err != nil || target == nil {
+			ms.mu.Lock()
+			delete(ms.runningTargets, next.targetID)
+			delete(ms.activeRuns, next.targetID)
+			ms.mu.Unlock()
+			continue
+		}
+		ms.logger().Info("queued run started", "target", target.Name, "target_id", target.ID,
+			"waited_ms", time.Since(next.enqueuedAt).Milliseconds())
+		ms.wg.Add(1)
+		go ms.runTargetSafe(target, next.plan)
+	}
+}
+
+// RunQueue returns running runs followed by pending ones in start order.
+func (ms *MonitorService) RunQueue() (running, pending []runQueueEntry) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	running = make([]runQueueEntry, 0, len(ms.runningTargets))
+	for id := range ms.runningTargets {
+		entry := runQueueEntry{TargetID: id, Mode: runModeFull}
+		if run, ok := ms.activeRuns[id]; ok {
+			startedAt := float64(run.startedAt.UnixMilli()) / 1000.0
+			entry.Mode, entry.Priority, entry.StartedAt = run.mode, run.priority, &startedAt
+		} else if _, ok := ms.agentLeases[id]; ok {
+			entry.Agent = true
+		}
+		running = append(running, entry)
+	}
+	slices.SortFunc(running, func(a, b runQueueEntry) int { return a.TargetID - b.TargetID })
+	pending = make([]runQueueEntry, 0, len(ms.runQueue))
+	for i, q := range ms.runQueue {
+		enqueuedAt := float64(q.enqueuedAt.UnixMilli()) / 1000.0
+		pending = append(pending, runQueueEntry{
+			TargetID:   q.targetID,
+			Position:   i + 1,
+			Mode:       q.plan.mode,
+			Priority:   q.plan.priority,
+			EnqueuedAt: &enqueuedAt,
+		})
+	}
+	return running, pending
+}
+
+// MonitorQueue -- GET /api/monitor/queue
+func (h *Handlers) MonitorQueue(w http.ResponseWriter, r *http.Request) {
+	running, pending := h.monitor.RunQueue()
+	targets, err := h.db.ListTargets()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return
+	}
+	names := make(map[int]string, len(targets))
+	for _, t := range targets {
+		names[t.ID] = t.Name
+	}
+	for i := range running {
+		running[i].TargetName = names[running[i].TargetID]
+	}
+	for i := range pending {
+		pending[i].TargetName = names[pending[i].TargetID]
+	}
+	_, maxParallel := h.monitor.Concurrency()
+	writeJSON(w, http.StatusOK, map[string]any{
+		"max_parallel_targets": maxParallel,
+		"running":              running,
+		"pending":              pending,
+	})
+}
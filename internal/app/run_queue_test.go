@@ -0,0 +1,155 @@
+package app
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRunQueueOrdersForcedRuns(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{}, 8)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/models" {
+			_, _ = w.Write([]byte(`{"data":[{"id":"gpt-a"}]}`))
+			return
+		}
+		_, _ = io.Copy(io.Discard, r.Body)
+		started <- struct{}{}
+		<-release
+		_, _ = w.Write([]byte(`{"choices":[{"message":{"content":"ok"}}]}`))
+	}))
+	defer srv.Close()
+
+	db, err := NewDatabase(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDatabase failed: %v", err)
+	}
+	t.Cleanup(func() { _ = db.conn.Close() })
+	ms := NewMonitorService(MonitorConfig{DB: db, LogDir: t.TempDir(), MaxParallelTargets: 1})
+
+	var ids []int
+	for _, name := range []string{"a", "b", "c", "d"} {
+		target, err := db.CreateTarget(map[string]any{"name": name, "base_url": srv.URL, "api_key": "k"})
+		if err != nil {
+			t.Fatalf("CreateTarget failed: %v", err)
+		}
+		ids = append(ids, target.ID)
+	}
+	a, b, c, d := ids[0], ids[1], ids[2], ids[3]
+
+	if ok, msg := ms.TriggerTarget(a, true); !ok || msg != "target started" {
+		t.Fatalf("first run should start, got ok=%v msg=%s", ok, msg)
+	}
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("first run did not start")
+	}
+	if ok, msg := ms.TriggerTarget(b, true); !ok || msg != "target queued" {
+		t.Fatalf("forced run over the limit should be queued, got ok=%v msg=%s", ok, msg)
+	}
+	if ok, msg := ms.TriggerManual(c, runPlan{mode: runModeFull, priority: 5}); !ok || ms.QueuePosition(c) != 1 || ms.QueuePosition(b) != 2 {
+		t.Fatalf("higher priority run should jump the queue, got ok=%v msg=%s", ok, msg)
+	}
+	if ok, msg := ms.TriggerTarget(b, true); ok || msg != "target already queued" {
+		t.Fatalf("queued target should not be queued twice, got ok=%v msg=%s", ok, msg)
+	}
+	if ok, _ := ms.TriggerTarget(d, false); ok {
+		t.Fatalf("scheduled run should be skipped while the limit is reached")
+	}
+
+	running, pending := ms.RunQueue()
+	if len(running) != 1 || running[0].TargetID != a || len(pending) != 2 || pending[0].TargetID != c || pending[1].Position != 2 {
+		t.Fatalf("queue view should list running and pending runs, got running=%+v pending=%+v", running, pending)
+	}
+
+	if ok, _ := ms.CancelTarget(b); !ok || ms.QueuePosition(b) != 0 {
+		t.Fatalf("cancel should drop a queued run")
+	}
+	close(release)
+	ms.WaitDetections()
+
+	for id, want := range map[int]int{a: 1, b: 0, c: 1, d: 0} {
+		if runs, _ := db.ListRuns(id, 10); len(runs) != want {
+			t.Fatalf("target %d should have %d runs, got=%d", id, want, len(runs))
+		}
+	}
+	if _, pending := ms.RunQueue(); len(pending) != 0 {
+		t.Fatalf("queue should drain, got=%+v", pending)
+	}
+}
+
+func TestConcurrencySettingsAndTargetOverride(t *testing.T) {
+	var mu sync.Mutex
+	inFlight, peak := 0, 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/models" {
+			_, _ = w.Write([]byte(`{"data":[{"id":"gpt-a"},{"id":"gpt-b"},{"id":"gpt-c"},{"id":"gpt-d"}]}`))
+			return
+		}
+		_, _ = io.Copy(io.Discard, r.Body)
+		mu.Lock()
+		inFlight++
+		peak = max(peak, inFlight)
+		mu.Unlock()
+		time.Sleep(30 * time.Millisecond)
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+		_, _ = w.Write([]byte(`{"choices":[{"message":{"content":"ok"}}]}`))
+	}))
+	defer srv.Close()
+
+	db, err := openDatabase(filepath.Join(t.TempDir(), "registry.db"))
+	if err != nil {
+		t.Fatalf("openDatabase failed: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	ms := NewMonitorService(MonitorConfig{DB: db, LogDir: t.TempDir(), DetectConcurrency: 1, MaxParallelTargets: 2})
+	h := &Handlers{db: db, monitor: ms}
+
+	patch := func(body string) int {
+		req := httptest.NewRequest(http.MethodPatch, "/api/admin/settings", strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		h.AdminPatchSettings(rr, withAuthRole(req, authRoleAdmin))
+		return rr.Code
+	}
+	if code := patch(`{"max_parallel_targets":0}`); code != http.StatusBadRequest {
+		t.Fatalf("out-of-range max_parallel_targets should be rejected, got=%d", code)
+	}
+	if code := patch(`{"detect_concurrency":4,"max_parallel_targets":5}`); code != http.StatusOK {
+		t.Fatalf("settings patch should succeed, got=%d", code)
+	}
+	if detect, parallel := ms.Concurrency(); detect != 4 || parallel != 5 {
+		t.Fatalf("concurrency should apply immediately, got=%d/%d", detect, parallel)
+	}
+	if stored, _ := db.GetSettings([]string{settingDetectConcurrency}); stored[settingDetectConcurrency] != "4" {
+		t.Fatalf("concurrency should be persisted, got=%v", stored)
+	}
+
+	target, err := db.CreateTarget(map[string]any{"name": "relay", "base_url": srv.URL, "api_key": "k", "detect_concurrency": 1})
+	if err != nil {
+		t.Fatalf("CreateTarget failed: %v", err)
+	}
+	ms.TriggerTarget(target.ID, true)
+	ms.WaitDetections()
+	if peak != 1 {
+		t.Fatalf("per-target detect_concurrency should cap in-flight probes, got peak=%d", peak)
+	}
+
+	peak = 0
+	if _, err := db.UpdateTarget(target.ID, map[string]any{"detect_concurrency": 0}); err != nil {
+		t.Fatalf("UpdateTarget failed: %v", err)
+	}
+	ms.TriggerTarget(target.ID, true)
+	ms.WaitDetections()
+	if peak < 2 {
+		t.Fatalf("target without an override should use the global concurrency, got peak=%d", peak)
+	}
+}
@@ -0,0 +1,110 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Run deadline and stuck-run watchdog. A run gets max_run_duration_s (the target's, or
+// the global setting) to finish; past it the outstanding probes are aborted and the run
+// is closed as "timeout" with the rows gathered so far. A run that still has not returned
+// stuckRunGrace after its deadline, e.g. wedged on a database call, is reclaimed by the
+// watchdog on the next scheduler scan: its slot is freed, and its run row, like any row
+// left "running" by a previous process, is closed as timeout.
+
+// errRunDeadline is the context cause of a run that hit its deadline.
+var errRunDeadline = errors.New("run exceeded max duration")
+
+// maxRunDurationS caps the run deadline setting and the per-target override (one day).
+const maxRunDurationS = 86400
+
+// stuckRunGrace is how long past its deadline a run may take to wind down before the
+// watchdog reclaims its slot. Orphaned rows younger than this are also left alone.
+const stuckRunGrace = 2 * time.Minute
+
+// runDeadline returns the max duration of a run of target; 0 means none.
+func (ms *MonitorService) runDeadline(target *Target) time.Duration {
+	if target.MaxRunDurationS > 0 {
+		return time.Duration(target.MaxRunDurationS) * time.Second
+	}
+	return ms.MaxRunDuration()
+}
+
+// reclaimStuckRuns frees the slots of local runs stuck past their deadline and closes
+// every run row still "running" that no live run owns.
+func (ms *MonitorService) reclaimStuckRuns() {
+	now := time.Now()
+	reclaimed := map[int]time.Duration{}
+	ms.mu.Lock()
+	for targetID, run := range ms.activeRuns {
+		if run.deadline <= 0 || now.Sub(run.startedAt) < run.deadline+stuckRunGrace {
+			continue
+		}
+		if cancel, ok := ms.runCancels[targetID]; ok {
+			cancel()
+		}
+		delete(ms.runningTargets, targetID)
+		delete(ms.runCancels, targetID)
+		delete(ms.activeRuns, targetID)
+		reclaimed[targetID] = run.deadline
+	}
+	ms.mu.Unlock()
+	if len(reclaimed) > 0 {
+		defer ms.startQueuedRuns()
+	}
+
+	runs, err := ms.db.ListRunningRuns()
+	if err != nil {
+		ms.logger().Error("list running runs failed", "error", err)
+		return
+	}
+	// A live run (local or leased to an agent) owns the newest running row of its target.
+	newest := map[int]int{}
+	for _, run := range runs {
+		newest[run.TargetID] = run.ID
+	}
+	for _, run := range runs {
+		ms.mu.Lock()
+		owned := ms.runningTargets[run.TargetID] && newest[run.TargetID] == run.ID
+		ms.mu.Unlock()
+		deadline, stuck := reclaimed[run.TargetID]
+		startedAt := time.UnixMilli(int64(run.StartedAt * 1000))
+		if owned || (!stuck && now.Sub(startedAt) < stuckRunGrace) {
+			continue
+		}
+
+		msg := "run lost: no detection in progress for it"
+		if stuck {
+			msg = fmt.Sprintf("%s of %s; reclaimed by the watchdog", errRunDeadline, deadline)
+		}
+		endedAt := float64(now.UnixMilli()) / 1000.0
+		if err := ms.db.FinishRun(run.ID, "timeout", endedAt, run.Total, run.Success, run.Fail, &msg); err != nil {
+			ms.logger().Error("finish run failed", "target_id", run.TargetID, "run_id", run.ID, "status", "timeout", "error", err)
+			continue
+		}
+		ms.logger().Warn("stuck run reclaimed", "target_id", run.TargetID, "run_id", run.ID, "detail", msg)
+		if !stuck {
+			continue
+		}
+		target, err := ms.db.GetTarget(run.TargetID)
+		if err != nil || target == nil {
+			continue
+		}
+		logFile := ""
+		if run.LogFile != nil {
+			logFile = *run.LogFile
+		}
+		if err := ms.db.UpdateTargetAfterRun(target.ID, endedAt, "timeout", run.Total, run.Success, run.Fail, logFile, &msg); err != nil {
+			ms.logger().Error("update target after run failed", "target", target.Name, "target_id", target.ID, "run_id", run.ID, "status", "timeout", "error", err)
+		}
+		ms.emitJSON("run_completed", map[string]any{
+			"target_id":   target.ID,
+			"target_name": target.Name,
+			"status":      "timeout",
+			"total":       run.Total,
+			"success":     run.Success,
+			"fail":        run.Fail,
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunDeadlineTimesOutRun(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/models" {
+			_, _ = w.Write([]byte(`{"data":[{"id":"gpt-a"},{"id":"gpt-b"}]}`))
+			return
+		}
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	db, err := NewDatabase(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDatabase failed: %v", err)
+	}
+	t.Cleanup(func() { _ = db.conn.Close() })
+	ms := NewMonitorService(MonitorConfig{DB: db, LogDir: t.TempDir(), MaxRunDuration: time.Hour})
+
+	target, err := db.CreateTarget(map[string]any{
+		"name": "slow", "base_url": srv.URL, "api_key": "k", "timeout_s": 60, "max_run_duration_s": 1,
+	})
+	if err != nil {
+		t.Fatalf("CreateTarget failed: %v", err)
+	}
+	if got := ms.runDeadline(target); got != time.Second {
+		t.Fatalf("target override should win over the global deadline, got=%s", got)
+	}
+
+	start := time.Now()
+	if ok, msg := ms.TriggerTarget(target.ID, true); !ok {
+		t.Fatalf("run should start, got=%s", msg)
+	}
+	ms.WaitDetections()
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Fatalf("run should stop at its deadline, took %s", elapsed)
+	}
+	run, err := db.GetLatestRun(target.ID)
+	if err != nil || run == nil {
+		t.Fatalf("GetLatestRun failed: %v", err)
+	}
+	if run.Status != "timeout" || run.Error == nil || !strings.Contains(*run.Error, "max duration") {
+		t.Fatalf("run past its deadline should finish as timeout, got=%+v", run)
+	}
+	if got, _ := db.GetTarget(target.ID); got.LastStatus == nil || *got.LastStatus != "timeout" {
+		t.Fatalf("target should report the timeout, got=%v", got.LastStatus)
+	}
+	if ms.IsTargetRunning(target.ID) {
+		t.Fatalf("timed out run should free its slot")
+	}
+}
+
+func TestWatchdogReclaimsStuckRuns(t *testing.T) {
+	db, err := NewDatabase(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDatabase failed: %v", err)
+	}
+	t.Cleanup(func() { _ = db.conn.Close() })
+	ms := NewMonitorService(MonitorConfig{DB: db, LogDir: t.TempDir()})
+
+	var ids []int
+	for _, name := range []string{"stuck", "orphan", "live"} {
+		target, err := db.CreateTarget(map[string]any{"name": name, "base_url": "https://example.com", "api_key": "k"})
+		if err != nil {
+			t.Fatalf("CreateTarget failed: %v", err)
+		}
+		ids = append(ids, target.ID)
+	}
+	stuck, orphan, live := ids[0], ids[1], ids[2]
+	old := float64(time.Now().Add(-time.Hour).UnixMilli()) / 1000.0
+
+	runIDs := map[int]int{}
+	for _, id := range ids {
+		runID, err := db.CreateRun(id, old, "", nil, runModeFull)
+		if err != nil {
+			t.Fatalf("CreateRun failed: %v", err)
+		}
+		runIDs[id] = runID
+	}
+	cancelled := false
+	ms.mu.Lock()
+	ms.runningTargets[stuck] = true
+	ms.runCancels[stuck] = func() { cancelled = true }
+	ms.activeRuns[stuck] = activeRun{seq: 1, mode: runModeFull, startedAt: time.Now().Add(-time.Hour), deadline: time.Minute}
+	ms.runningTargets[live] = true
+	ms.activeRuns[live] = activeRun{seq: 2, mode: runModeFull, startedAt: time.Now()}
+	ms.mu.Unlock()
+
+	ms.reclaimStuckRuns()
+
+	if !cancelled || ms.IsTargetRunning(stuck) {
+		t.Fatalf("stuck run should be cancelled and its slot freed")
+	}
+	if !ms.IsTargetRunning(live) {
+		t.Fatalf("run without a deadline should keep its slot")
+	}
+	for id, want := range map[int]string{stuck: "timeout", orphan: "timeout", live: "running"} {
+		runs, _ := db.ListRuns(id, 1)
+		if len(runs) != 1 || runs[0].ID != runIDs[id] || runs[0].Status != want {
+			t.Fatalf("run of target %d should be %s, got=%+v", id, want, runs)
+		}
+	}
+	if got, _ := db.GetTarget(stuck); got.LastStatus == nil || *got.LastStatus != "timeout" {
+		t.Fatalf("reclaimed target should report the timeout, got=%v", got.LastStatus)
+	}
+
+	// A late finish from the reclaimed run must not overwrite the timeout.
+	if err := db.FinishRun(runIDs[stuck], "completed", old, 1, 1, 0, nil); err != nil {
+		t.Fatalf("FinishRun failed: %v", err)
+	}
+	if runs, _ := db.ListRuns(stuck, 1); runs[0].Status != "timeout" {
+		t.Fatalf("finished run should not be rewritten, got=%s", runs[0].Status)
+	}
+}
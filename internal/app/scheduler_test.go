@@ -0,0 +1,121 @@
+package app
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestScanDueTargets_SkipsWhenPaused(t *testing.T) {
+	db, err := NewDatabase(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDatabase failed: %v", err)
+	}
+	t.Cleanup(func() { _ = db.conn.Close() })
+	ms := NewMonitorService(MonitorConfig{DB: db, LogDir: t.TempDir(), SchedulerPaused: true})
+
+	target, err := db.CreateTarget(map[string]any{"name": "t1", "base_url": "http://127.0.0.1:1", "api_key": "k"})
+	if err != nil {
+		t.Fatalf("CreateTarget failed: %v", err)
+	}
+
+	ms.ScanDueTargets()
+	ms.WaitDetections()
+	if runs, _ := db.ListRuns(target.ID, 10); len(runs) != 0 {
+		t.Fatalf("paused scheduler should not start runs, got=%d", len(runs))
+	}
+
+	ms.SetSchedulerPaused(false)
+	if ms.SchedulerPaused() {
+		t.Fatalf("scheduler should be resumed")
+	}
+	ms.ScanDueTargets()
+	ms.WaitDetections()
+	if runs, _ := db.ListRuns(target.ID, 10); len(runs) != 1 {
+		t.Fatalf("resumed scheduler should start the due target, got=%d", len(runs))
+	}
+}
+
+func TestListDueTargets_SkipsSnoozed(t *testing.T) {
+	db, err := NewDatabase(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDatabase failed: %v", err)
+	}
+	t.Cleanup(func() { _ = db.conn.Close() })
+
+	target, err := db.CreateTarget(map[string]any{"name": "t1", "base_url": "https://example.com", "api_key": "k"})
+	if err != nil {
+		t.Fatalf("CreateTarget failed: %v", err)
+	}
+	now := 1_700_000_000.0
+	updated, err := db.UpdateTarget(target.ID, map[string]any{"snoozed_until": now + 3600})
+	if err != nil || !updated.Snoozed(now) {
+		t.Fatalf("target should be snoozed, got=%+v err=%v", updated, err)
+	}
+	if due, _ := db.ListDueTargets(now, nil); len(due) != 0 {
+		t.Fatalf("snoozed target should not be due, got=%d", len(due))
+	}
+	if due, _ := db.ListDueTargets(now+3600, nil); len(due) != 1 {
+		t.Fatalf("target should be due once the snooze ends, got=%d", len(due))
+	}
+
+	updated, err = db.UpdateTarget(target.ID, map[string]any{"snoozed_until": nil})
+	if err != nil || updated.SnoozedUntil != nil {
+		t.Fatalf("null should clear the snooze, got=%v err=%v", updated.SnoozedUntil, err)
+	}
+	if err := validateTargetPayload(map[string]any{"snoozed_until": "tomorrow"}); err == nil {
+		t.Fatalf("non-numeric snoozed_until should be rejected")
+	}
+}
+
+func TestNextRetryInterval_BacksOffToIntervalMin(t *testing.T) {
+	target := &Target{IntervalMin: 30, FastRetryMin: 5}
+	var got []int
+	for i := 0; i < 5; i++ {
+		next := nextRetryInterval(target, "down")
+		if next == nil {
+			t.Fatalf("down run should schedule a fast retry")
+		}
+		got = append(got, *next)
+		target.RetryIntervalMin = next
+	}
+	want := []int{5, 10, 20, 30, 30}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("retry intervals should back off to interval_min, got=%v", got)
+		}
+	}
+	if next := nextRetryInterval(target, "healthy"); next != nil {
+		t.Fatalf("healthy run should restore interval_min, got=%d", *next)
+	}
+	target.FastRetryMin = 0
+	if next := nextRetryInterval(target, "degraded"); next != nil {
+		t.Fatalf("fast retry disabled should keep interval_min, got=%d", *next)
+	}
+}
+
+func TestListDueTargets_HonorsRetryInterval(t *testing.T) {
+	db, err := NewDatabase(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDatabase failed: %v", err)
+	}
+	t.Cleanup(func() { _ = db.conn.Close() })
+
+	target, err := db.CreateTarget(map[string]any{"name": "t1", "base_url": "https://example.com", "api_key": "k", "interval_min": 30, "fast_retry_min": 5})
+	if err != nil || target.FastRetryMin != 5 {
+		t.Fatalf("CreateTarget should store fast_retry_min, got=%+v err=%v", target, err)
+	}
+	now := 1_700_000_000.0
+	if err := db.UpdateTargetAfterRun(target.ID, now, "down", 1, 0, 1, "", nil); err != nil {
+		t.Fatalf("UpdateTargetAfterRun failed: %v", err)
+	}
+	if due, _ := db.ListDueTargets(now+6*60, nil); len(due) != 0 {
+		t.Fatalf("target should wait interval_min without a retry interval, got=%d", len(due))
+	}
+	retry := 5
+	if err := db.SetTargetRetryInterval(target.ID, &retry); err != nil {
+		t.Fatalf("SetTargetRetryInterval failed: %v", err)
+	}
+	if due, _ := db.ListDueTargets(now+6*60, nil); len(due) != 1 {
+		t.Fatalf("target should be due after the retry interval, got=%d", len(due))
+	}
+}
@@ -0,0 +1,239 @@
+package app
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+// Secrets at rest: when API_MONITOR_ENCRYPTION_KEY (or _FILE) is set, target API keys
+// and secret settings are stored as "enc:v1:" + base64(nonce || AES-256-GCM ciphertext).
+// Values without the prefix are plaintext rows written before encryption was enabled;
+// they stay readable and are encrypted by "api-monitor migrate --encrypt-secrets".
+
+const encryptedSecretPrefix = "enc:v1:"
+
+// encryptedSettings are the app_settings keys stored encrypted.
+var encryptedSettings = map[string]bool{
+	settingProxyMasterToken:    true,
+	settingOIDCClientSecret:    true,
+	settingS3SecretAccessKey:   true,
+	settingPagerDutyRoutingKey: true,
+}
+
+var secretsAEAD atomic.Pointer[cipher.AEAD]
+
+var errSecretKeyMissing = errors.New("database holds encrypted secrets but API_MONITOR_ENCRYPTION_KEY is not set")
+
+// setEncryptionKey installs the master key; an empty secret disables encryption.
+// Any string is accepted and stretched to a 256-bit key with SHA-256, so use a long
+// random value (e.g. openssl rand -base64 32).
+func setEncryptionKey(secret string) error {
+	if secret == "" {
+		secretsAEAD.Store(nil)
+		return nil
+	}
+	key := sha256.Sum256([]byte(secret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return err
+	}
+	secretsAEAD.Store(&aead)
+	return nil
+}
+
+// loadEncryptionKeyFromEnv reads API_MONITOR_ENCRYPTION_KEY, or the file named by
+// API_MONITOR_ENCRYPTION_KEY_FILE, and installs it.
+func loadEncryptionKeyFromEnv() error {
+	secret := strings.TrimSpace(os.Getenv("API_MONITOR_ENCRYPTION_KEY"))
+	if path := strings.TrimSpace(os.Getenv("API_MONITOR_ENCRYPTION_KEY_FILE")); path != "" {
+		if secret != "" {
+			return fmt.Errorf("set only one of API_MONITOR_ENCRYPTION_KEY and API_MONITOR_ENCRYPTION_KEY_FILE")
+		}
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("read API_MONITOR_ENCRYPTION_KEY_FILE: %w", err)
+		}
+		secret = strings.TrimSpace(string(raw))
+		if secret == "" {
+			return fmt.Errorf("API_MONITOR_ENCRYPTION_KEY_FILE %s is empty", path)
+		}
+	}
+	return setEncryptionKey(secret)
+}
+
+func encryptionEnabled() bool {
+	return secretsAEAD.Load() != nil
+}
+
+func isEncryptedSecret(value string) bool {
+	return strings.HasPrefix(value, encryptedSecretPrefix)
+}
+
+// encryptSecret seals plain with the master key. Without a key, or for an empty or
+// already encrypted value, it returns the input unchanged.
+func encryptSecret(plain string) (string, error) {
+	p := secretsAEAD.Load()
+	if p == nil || plain == "" || isEncryptedSecret(plain) {
+		return plain, nil
+	}
+	aead := *p
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(plain), nil)
+	return encryptedSecretPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// decryptSecret opens a value written by encryptSecret; plaintext passes through.
+func decryptSecret(value string) (string, error) {
+	if !isEncryptedSecret(value) {
+		return value, nil
+	}
+	p := secretsAEAD.Load()
+	if p == nil {
+		return "", errSecretKeyMissing
+	}
+	aead := *p
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, encryptedSecretPrefix))
+	if err != nil || len(raw) < aead.NonceSize() {
+		return "", fmt.Errorf("malformed encrypted secret")
+	}
+	plain, err := aead.Open(nil, raw[:aead.NonceSize()], raw[aead.NonceSize():], nil)
+	if err != nil {
+		return "", fmt.Errorf("decrypt secret failed (wrong API_MONITOR_ENCRYPTION_KEY?)")
+	}
+	return string(plain), nil
+}
+
+// encryptSetting encrypts value when key is one of encryptedSettings.
+func encryptSetting(key, value string) (string, error) {
+	if !encryptedSettings[key] {
+		return value, nil
+	}
+	return encryptSecret(value)
+}
+
+// decryptSetting reverses encryptSetting.
+func decryptSetting(key, value string) (string, error) {
+	if !encryptedSettings[key] {
+		return value, nil
+	}
+	return decryptSecret(value)
+}
+
+// VerifySecrets checks that every encrypted value in the database can be opened with
+// the configured key, so a missing or wrong key fails at startup instead of per request.
+func (d *Database) VerifySecrets() error {
+	values, err := d.storedSecrets()
+	if err != nil {
+		return err
+	}
+	for _, v := range values {
+		if _, err := decryptSecret(v.value); err != nil {
+			return fmt.Errorf("%s: %w", v.label, err)
+		}
+	}
+	return nil
+}
+
+type storedSecret struct {
+	label    string
+	value    string
+	targetID int
+	// column is the targets column of a target secret (api_key, api_keys).
+	column  string
+	setting string
+}
+
+func (d *Database) storedSecrets() ([]storedSecret, error) {
+	var out []storedSecret
+	rows, err := d.read.Query("SELECT id, api_key, api_keys FROM targets ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var id int
+		var apiKey, apiKeys string
+		if err := rows.Scan(&id, &apiKey, &apiKeys); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		out = append(out, storedSecret{label: fmt.Sprintf("target %d api_key", id), value: apiKey, targetID: id, column: "api_key"})
+		if apiKeys != "[]" {
+			out = append(out, storedSecret{label: fmt.Sprintf("target %d api_keys", id), value: apiKeys, targetID: id, column: "api_keys"})
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	// Read the raw column; GetSetting would already decrypt.
+	for key := range encryptedSettings {
+		var value string
+		err := d.read.QueryRow("SELECT value FROM app_settings WHERE key = ?", key).Scan(&value)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, storedSecret{label: "setting " + key, value: value, setting: key})
+	}
+	return out, nil
+}
+
+// EncryptStoredSecrets encrypts every plaintext target API key and secret setting
+// with the configured key and returns how many values were rewritten.
+func (d *Database) EncryptStoredSecrets() (int, error) {
+	if !encryptionEnabled() {
+		return 0, fmt.Errorf("API_MONITOR_ENCRYPTION_KEY is not set")
+	}
+	values, err := d.storedSecrets()
+	if err != nil {
+		return 0, err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	tx, err := d.conn.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	n := 0
+	for _, v := range values {
+		if v.value == "" || isEncryptedSecret(v.value) {
+			continue
+		}
+		sealed, err := encryptSecret(v.value)
+		if err != nil {
+			return 0, err
+		}
+		if v.setting != "" {
+			_, err = tx.Exec("UPDATE app_settings SET value = ? WHERE key = ?", sealed, v.setting)
+		} else {
+			_, err = tx.Exec("UPDATE targets SET "+v.column+" = ? WHERE id = ?", sealed, v.targetID)
+		}
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", v.label, err)
+		}
+		n++
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
@@ -0,0 +1,141 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func withEncryptionKey(t *testing.T, secret string) {
+	t.Helper()
+	if err := setEncryptionKey(secret); err != nil {
+		t.Fatalf("setEncryptionKey: %v", err)
+	}
+	t.Cleanup(func() { _ = setEncryptionKey("") })
+}
+
+func TestEncryptSecretRoundTrip(t *testing.T) {
+	withEncryptionKey(t, "correct horse battery staple")
+	sealed, err := encryptSecret("sk-live-123")
+	if err != nil {
+		t.Fatalf("encryptSecret: %v", err)
+	}
+	if !strings.HasPrefix(sealed, encryptedSecretPrefix) || strings.Contains(sealed, "sk-live-123") {
+		t.Fatalf("sealed value should be prefixed ciphertext, got=%q", sealed)
+	}
+	again, _ := encryptSecret("sk-live-123")
+	if again == sealed {
+		t.Fatalf("each encryption should use a fresh nonce")
+	}
+	if plain, err := decryptSecret(sealed); err != nil || plain != "sk-live-123" {
+		t.Fatalf("decryptSecret should recover the key, got=%q err=%v", plain, err)
+	}
+	if plain, err := decryptSecret("legacy-plaintext"); err != nil || plain != "legacy-plaintext" {
+		t.Fatalf("plaintext should pass through, got=%q err=%v", plain, err)
+	}
+
+	withEncryptionKey(t, "another key")
+	if _, err := decryptSecret(sealed); err == nil {
+		t.Fatalf("a wrong key should fail to decrypt")
+	}
+	withEncryptionKey(t, "")
+	if _, err := decryptSecret(sealed); err != errSecretKeyMissing {
+		t.Fatalf("missing key should be reported, got=%v", err)
+	}
+}
+
+func TestTargetAPIKeyEncryptedAtRest(t *testing.T) {
+	withEncryptionKey(t, "master")
+	db, err := NewDatabase(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDatabase: %v", err)
+	}
+	defer db.Close()
+
+	target, err := db.CreateTarget(map[string]any{"name": "a", "base_url": "https://a.example.com", "api_key": "sk-create"})
+	if err != nil {
+		t.Fatalf("CreateTarget: %v", err)
+	}
+	if target.APIKey != "sk-create" {
+		t.Fatalf("scanTarget should decrypt api_key, got=%q", target.APIKey)
+	}
+	var raw string
+	if err := db.conn.QueryRow("SELECT api_key FROM targets WHERE id = ?", target.ID).Scan(&raw); err != nil {
+		t.Fatal(err)
+	}
+	if !isEncryptedSecret(raw) {
+		t.Fatalf("api_key should be stored encrypted, got=%q", raw)
+	}
+
+	updated, err := db.UpdateTarget(target.ID, map[string]any{"api_key": "sk-update"})
+	if err != nil || updated.APIKey != "sk-update" {
+		t.Fatalf("UpdateTarget should store and return the new key, got=%v err=%v", updated, err)
+	}
+	if err := db.SetSetting(settingProxyMasterToken, "master-token"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.conn.QueryRow("SELECT value FROM app_settings WHERE key = ?", settingProxyMasterToken).Scan(&raw); err != nil {
+		t.Fatal(err)
+	}
+	if !isEncryptedSecret(raw) {
+		t.Fatalf("proxy master token should be stored encrypted, got=%q", raw)
+	}
+	values, err := db.GetSettings([]string{settingProxyMasterToken})
+	if err != nil || values[settingProxyMasterToken] != "master-token" {
+		t.Fatalf("GetSettings should decrypt, got=%v err=%v", values, err)
+	}
+	if v, ok, err := db.GetSetting(settingProxyMasterToken); err != nil || !ok || v != "master-token" {
+		t.Fatalf("GetSetting should decrypt, got=%q ok=%v err=%v", v, ok, err)
+	}
+
+	withEncryptionKey(t, "")
+	if err := db.VerifySecrets(); err == nil {
+		t.Fatalf("VerifySecrets should fail without the key")
+	}
+}
+
+func TestEncryptStoredSecretsMigratesPlaintext(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	db, err := NewDatabase(path)
+	if err != nil {
+		t.Fatalf("NewDatabase: %v", err)
+	}
+	defer db.Close()
+	target, err := db.CreateTarget(map[string]any{"name": "a", "base_url": "https://a.example.com", "api_key": "sk-plain"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SetSetting(settingProxyMasterToken, "plain-token"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.EncryptStoredSecrets(); err == nil {
+		t.Fatalf("EncryptStoredSecrets should require a key")
+	}
+
+	keyFile := filepath.Join(t.TempDir(), "key")
+	if err := os.WriteFile(keyFile, []byte("file-secret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("API_MONITOR_ENCRYPTION_KEY", "")
+	t.Setenv("API_MONITOR_ENCRYPTION_KEY_FILE", keyFile)
+	if err := loadEncryptionKeyFromEnv(); err != nil {
+		t.Fatalf("loadEncryptionKeyFromEnv: %v", err)
+	}
+	t.Cleanup(func() { _ = setEncryptionKey("") })
+
+	n, err := db.EncryptStoredSecrets()
+	if err != nil || n != 2 {
+		t.Fatalf("two plaintext secrets should be encrypted, got=%d err=%v", n, err)
+	}
+	if n, _ := db.EncryptStoredSecrets(); n != 0 {
+		t.Fatalf("a second pass should be a no-op, got=%d", n)
+	}
+	got, err := db.GetTarget(target.ID)
+	if err != nil || got.APIKey != "sk-plain" {
+		t.Fatalf("migrated key should decrypt, got=%v err=%v", got, err)
+	}
+	if err := db.VerifySecrets(); err != nil {
+		t.Fatalf("VerifySecrets should pass with the key, got=%v", err)
+	}
+}
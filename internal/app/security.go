@@ -184,10 +184,31 @@ func writeBlockedAuthResponse(w http.ResponseWriter, retryAfter time.Duration) {
 	})
 }
 
+// clientIPFromRequest returns the client address. Forwarding headers are only believed
+// when the direct peer is a trusted proxy; X-Forwarded-For is then read right to left and
+// the first hop that is not itself a trusted proxy wins.
 func clientIPFromRequest(r *http.Request) string {
 	if r == nil {
 		return ""
 	}
+	remote := remoteIPFromRequest(r)
+	trusted := getTrustedProxies()
+	if !trusted.all {
+		if remote == "" || !trusted.contains(remote) {
+			return remote
+		}
+		if ip, ok := extractValidIP(r.Header.Get("CF-Connecting-IP")); ok {
+			return ip
+		}
+		if ip, ok := lastUntrustedForwardedIP(r.Header.Get("X-Forwarded-For"), trusted); ok {
+			return ip
+		}
+		if ip, ok := extractValidIP(r.Header.Get("X-Real-IP")); ok {
+			return ip
+		}
+		return remote
+	}
+
 	if ip, ok := extractValidIP(r.Header.Get("CF-Connecting-IP")); ok {
 		return ip
 	}
@@ -197,7 +218,10 @@ func clientIPFromRequest(r *http.Request) string {
 	if ip, ok := extractValidIP(r.Header.Get("X-Real-IP")); ok {
 		return ip
 	}
+	return remote
+}
 
+func remoteIPFromRequest(r *http.Request) string {
 	host := strings.TrimSpace(r.RemoteAddr)
 	if h, _, err := net.SplitHostPort(host); err == nil {
 		host = h
@@ -208,6 +232,25 @@ func clientIPFromRequest(r *http.Request) string {
 	return ""
 }
 
+// lastUntrustedForwardedIP walks X-Forwarded-For from the nearest hop outwards. If every
+// hop is trusted the original client (leftmost entry) is returned.
+func lastUntrustedForwardedIP(header string, trusted ipNetList) (string, bool) {
+	parts := strings.Split(header, ",")
+	first := ""
+	for i := len(parts) - 1; i >= 0; i-- {
+		ip, ok := extractValidIP(parts[i])
+		if !ok {
+			// A malformed hop cannot be attributed; stop rather than trust what lies beyond it.
+			break
+		}
+		if !trusted.contains(ip) {
+			return ip, true
+		}
+		first = ip
+	}
+	return first, first != ""
+}
+
 func extractValidIP(raw string) (string, bool) {
 	raw = strings.TrimSpace(raw)
 	if raw == "" {
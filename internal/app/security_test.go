@@ -81,6 +81,61 @@ func TestClientIPFromRequestPriority(t *testing.T) {
 	}
 }
 
+func TestClientIPFromRequest_TrustedProxies(t *testing.T) {
+	if err := setTrustedProxies("10.0.0.0/8"); err != nil {
+		t.Fatalf("setTrustedProxies failed: %v", err)
+	}
+	t.Cleanup(func() { _ = setTrustedProxies("*") })
+
+	req := httptest.NewRequest(http.MethodGet, "/api/health", nil)
+	req.RemoteAddr = "10.0.0.9:4567"
+	req.Header.Set("X-Forwarded-For", "1.1.1.1, 203.0.113.7, 10.0.0.6")
+	if got := clientIPFromRequest(req); got != "203.0.113.7" {
+		t.Fatalf("expected nearest untrusted X-Forwarded-For hop, got=%s", got)
+	}
+
+	spoofed := httptest.NewRequest(http.MethodGet, "/api/health", nil)
+	spoofed.RemoteAddr = "198.51.100.4:4567"
+	spoofed.Header.Set("X-Forwarded-For", "10.0.0.1")
+	spoofed.Header.Set("CF-Connecting-IP", "10.0.0.1")
+	if got := clientIPFromRequest(spoofed); got != "198.51.100.4" {
+		t.Fatalf("headers from an untrusted peer should be ignored, got=%s", got)
+	}
+}
+
+func TestIPAllowlistMiddleware_WriteScope(t *testing.T) {
+	if err := setIPAllowlists("", "192.0.2.0/24, 2001:db8::1"); err != nil {
+		t.Fatalf("setIPAllowlists failed: %v", err)
+	}
+	t.Cleanup(func() { _ = setIPAllowlists("", "") })
+	h := ipAllowlistMiddleware(ipAllowlistWrite, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	cases := []struct {
+		method, remote string
+		want           int
+	}{
+		{http.MethodGet, "198.51.100.4:1", http.StatusNoContent},
+		{http.MethodPatch, "198.51.100.4:1", http.StatusForbidden},
+		{http.MethodPatch, "192.0.2.10:1", http.StatusNoContent},
+		{http.MethodDelete, "[2001:db8::1]:1", http.StatusNoContent},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(c.method, "/api/targets/1", nil)
+		req.RemoteAddr = c.remote
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != c.want {
+			t.Fatalf("%s from %s should return %d, got=%d", c.method, c.remote, c.want, rec.Code)
+		}
+	}
+
+	if _, err := parseIPNetList("10.0.0.0/33"); err == nil {
+		t.Fatalf("invalid CIDR should be rejected")
+	}
+}
+
 func TestAuthAnyMiddleware_BlockedIP(t *testing.T) {
 	setAuthTokens("admin-token", "visitor-token")
 
@@ -1,9 +1,10 @@
 package app
 
 import (
-	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -13,36 +14,121 @@ import (
 // SSE Event Bus
 // ---------------------------------------------------------------------------
 
-// SSEBus broadcasts events to connected SSE clients.
+// busEvent is one published event; transports format it for the wire.
+type busEvent struct {
+	Event string
+	Data  string
+}
+
+// eventFilter selects which bus events a subscriber receives. Empty sets match everything.
+type eventFilter struct {
+	events  map[string]bool
+	targets map[int]bool
+}
+
+func newEventFilter(events []string, targets []int) *eventFilter {
+	f := &eventFilter{events: map[string]bool{}, targets: map[int]bool{}}
+	for _, e := range events {
+		if e = strings.TrimSpace(e); e != "" {
+			f.events[e] = true
+		}
+	}
+	for _, id := range targets {
+		if id > 0 {
+			f.targets[id] = true
+		}
+	}
+	return f
+}
+
+// parseEventFilter reads ?events=a,b&targets=1,2 from a subscription request.
+func parseEventFilter(r *http.Request) (*eventFilter, error) {
+	q := r.URL.Query()
+	var events []string
+	if raw := strings.TrimSpace(q.Get("events")); raw != "" {
+		events = strings.Split(raw, ",")
+	}
+	var targets []int
+	if raw := strings.TrimSpace(q.Get("targets")); raw != "" {
+		for _, part := range strings.Split(raw, ",") {
+			id, err := strconv.Atoi(strings.TrimSpace(part))
+			if err != nil || id < 1 {
+				return nil, fmt.Errorf("targets must be a comma-separated list of ids")
+			}
+			targets = append(targets, id)
+		}
+	}
+	return newEventFilter(events, targets), nil
+}
+
+// matches reports whether an event passes the filter. targetID is 0 for events that are
+// not about a single target (e.g. connected); those always pass the target filter.
+func (f *eventFilter) matches(event string, targetID int) bool {
+	if f == nil {
+		return true
+	}
+	if len(f.events) > 0 && !f.events[event] {
+		return false
+	}
+	if len(f.targets) > 0 && targetID > 0 && !f.targets[targetID] {
+		return false
+	}
+	return true
+}
+
+func eventTargetID(data string) int {
+	var probe struct {
+		TargetID *int `json:"target_id"`
+	}
+	if err := json.Unmarshal([]byte(data), &probe); err != nil || probe.TargetID == nil {
+		return 0
+	}
+	return *probe.TargetID
+}
+
+// busSubscriber is one connected client with its current filter.
+type busSubscriber struct {
+	ch     chan busEvent
+	filter *eventFilter
+}
+
+// SSEBus broadcasts events to connected SSE and WebSocket clients.
 type SSEBus struct {
 	mu          sync.Mutex
-	subscribers map[chan string]struct{}
+	subscribers map[*busSubscriber]struct{}
 	closed      bool
 }
 
 // NewSSEBus creates a new SSE event bus.
 func NewSSEBus() *SSEBus {
 	return &SSEBus{
-		subscribers: make(map[chan string]struct{}),
+		subscribers: make(map[*busSubscriber]struct{}),
 	}
 }
 
-func (b *SSEBus) subscribe() chan string {
-	ch := make(chan string, 64)
+func (b *SSEBus) subscribe(filter *eventFilter) *busSubscriber {
+	sub := &busSubscriber{ch: make(chan busEvent, 64), filter: filter}
 	b.mu.Lock()
 	if b.closed {
 		b.mu.Unlock()
-		close(ch)
-		return ch
+		close(sub.ch)
+		return sub
 	}
-	b.subscribers[ch] = struct{}{}
+	b.subscribers[sub] = struct{}{}
+	b.mu.Unlock()
+	return sub
+}
+
+func (b *SSEBus) unsubscribe(sub *busSubscriber) {
+	b.mu.Lock()
+	delete(b.subscribers, sub)
 	b.mu.Unlock()
-	return ch
 }
 
-func (b *SSEBus) unsubscribe(ch chan string) {
+// setFilter replaces a subscriber's filter; later events are matched against it.
+func (b *SSEBus) setFilter(sub *busSubscriber, filter *eventFilter) {
 	b.mu.Lock()
-	delete(b.subscribers, ch)
+	sub.filter = filter
 	b.mu.Unlock()
 }
 
@@ -54,23 +140,27 @@ func (b *SSEBus) Close() {
 		return
 	}
 	b.closed = true
-	for ch := range b.subscribers {
-		close(ch)
-		delete(b.subscribers, ch)
+	for sub := range b.subscribers {
+		close(sub.ch)
+		delete(b.subscribers, sub)
 	}
 }
 
-// Publish sends an SSE event to all connected clients.
+// Publish sends an event to every connected client whose filter matches.
 func (b *SSEBus) Publish(event, data string) {
-	msg := fmt.Sprintf("event: %s\ndata: %s\n\n", event, data)
+	msg := busEvent{Event: event, Data: data}
+	targetID := eventTargetID(data)
 	b.mu.Lock()
 	defer b.mu.Unlock()
 	if b.closed {
 		return
 	}
-	for ch := range b.subscribers {
+	for sub := range b.subscribers {
+		if !sub.filter.matches(event, targetID) {
+			continue
+		}
 		select {
-		case ch <- msg:
+		case sub.ch <- msg:
 		default:
 			// drop for slow consumers
 		}
@@ -78,20 +168,26 @@ func (b *SSEBus) Publish(event, data string) {
 }
 
 // ServeHTTP implements the SSE endpoint handler.
+// Optional ?events=run_completed,target_updated&targets=1,2 limit what this client receives.
 func (b *SSEBus) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	flusher, ok := w.(http.Flusher)
 	if !ok {
 		http.Error(w, "streaming not supported", http.StatusInternalServerError)
 		return
 	}
+	filter, err := parseEventFilter(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": err.Error()})
+		return
+	}
 
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Connection", "keep-alive")
 	w.Header().Set("X-Accel-Buffering", "no")
 
-	ch := b.subscribe()
-	defer b.unsubscribe(ch)
+	sub := b.subscribe(filter)
+	defer b.unsubscribe(sub)
 
 	// Initial heartbeat
 	fmt.Fprint(w, "event: connected\ndata: ok\n\n")
@@ -103,12 +199,12 @@ func (b *SSEBus) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	for {
 		select {
-		case msg, ok := <-ch:
+		case msg, ok := <-sub.ch:
 			if !ok {
 				// Bus closed, exit gracefully
 				return
 			}
-			fmt.Fprint(w, msg)
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", msg.Event, msg.Data)
 			flusher.Flush()
 		case <-heartbeat.C:
 			fmt.Fprint(w, ": heartbeat\n\n")
@@ -118,158 +214,3 @@ func (b *SSEBus) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 }
-
-// ---------------------------------------------------------------------------
-// Auth Middleware
-// ---------------------------------------------------------------------------
-
-type authRole string
-
-const (
-	authRoleUnknown authRole = "unknown"
-	authRoleVisitor authRole = "visitor"
-	authRoleAdmin   authRole = "admin"
-)
-
-type authRoleContextKey struct{}
-
-var authRoleKey = authRoleContextKey{}
-
-var authAdminToken string
-var authVisitorToken string
-var authVisitorModeEnabled bool
-var authTokenMu sync.RWMutex
-
-func setAuthTokens(adminToken, visitorToken string) {
-	authTokenMu.Lock()
-	authAdminToken = strings.TrimSpace(adminToken)
-	authVisitorToken = strings.TrimSpace(visitorToken)
-	authTokenMu.Unlock()
-}
-
-func setVisitorModeEnabled(enabled bool) {
-	authTokenMu.Lock()
-	authVisitorModeEnabled = enabled
-	authTokenMu.Unlock()
-}
-
-func isVisitorModeEnabled() bool {
-	authTokenMu.RLock()
-	defer authTokenMu.RUnlock()
-	return authVisitorModeEnabled
-}
-
-func getAdminAuthToken() string {
-	authTokenMu.RLock()
-	defer authTokenMu.RUnlock()
-	return authAdminToken
-}
-
-func getVisitorAuthToken() string {
-	authTokenMu.RLock()
-	defer authTokenMu.RUnlock()
-	return authVisitorToken
-}
-
-func setAuthToken(token string) {
-	setAuthTokens(token, getVisitorAuthToken())
-}
-
-func getAuthToken() string {
-	return getAdminAuthToken()
-}
-
-func withAuthRole(r *http.Request, role authRole) *http.Request {
-	ctx := context.WithValue(r.Context(), authRoleKey, role)
-	return r.WithContext(ctx)
-}
-
-func authRoleFromRequest(r *http.Request) authRole {
-	if r == nil {
-		return authRoleUnknown
-	}
-	role, ok := r.Context().Value(authRoleKey).(authRole)
-	if !ok || role == "" {
-		return authRoleUnknown
-	}
-	return role
-}
-
-func authenticateRequestRole(r *http.Request) (authRole, bool) {
-	adminToken := getAdminAuthToken()
-	visitorToken := getVisitorAuthToken()
-	if adminToken == "" {
-		return authRoleUnknown, false
-	}
-
-	auth := strings.TrimSpace(r.Header.Get("Authorization"))
-	if auth == "Bearer "+adminToken {
-		return authRoleAdmin, true
-	}
-	if visitorToken != "" && auth == "Bearer "+visitorToken {
-		return authRoleVisitor, true
-	}
-
-	if r.Method == http.MethodGet && r.URL.Path == "/api/events" {
-		queryToken := strings.TrimSpace(r.URL.Query().Get("token"))
-		if queryToken == adminToken {
-			return authRoleAdmin, true
-		}
-		if visitorToken != "" && queryToken == visitorToken {
-			return authRoleVisitor, true
-		}
-	}
-
-	// Anonymous visitor: visitor mode enabled with no token configured
-	if isVisitorModeEnabled() && visitorToken == "" && (auth == "" || auth == "Bearer ") {
-		return authRoleVisitor, true
-	}
-
-	return authRoleUnknown, false
-}
-
-// authAnyMiddleware allows both admin token and visitor token.
-func authAnyMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if getAdminAuthToken() == "" {
-			writeJSON(w, http.StatusServiceUnavailable, map[string]any{"detail": "auth token not initialized"})
-			return
-		}
-		clientIP := clientIPFromRequest(r)
-		if blocked, retryAfter := globalAuthFailureProtector.IsBlocked(authFailureScopeToken, clientIP); blocked {
-			writeBlockedAuthResponse(w, retryAfter)
-			return
-		}
-		role, ok := authenticateRequestRole(r)
-		if ok {
-			globalAuthFailureProtector.Clear(authFailureScopeToken, clientIP)
-			next.ServeHTTP(w, withAuthRole(r, role))
-			return
-		}
-		globalAuthFailureProtector.RecordFailure(authFailureScopeToken, clientIP)
-		writeJSON(w, http.StatusUnauthorized, map[string]any{"detail": "unauthorized"})
-	})
-}
-
-// authAdminTokenMiddleware allows admin token only.
-func authAdminTokenMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if getAdminAuthToken() == "" {
-			writeJSON(w, http.StatusServiceUnavailable, map[string]any{"detail": "auth token not initialized"})
-			return
-		}
-		clientIP := clientIPFromRequest(r)
-		if blocked, retryAfter := globalAuthFailureProtector.IsBlocked(authFailureScopeToken, clientIP); blocked {
-			writeBlockedAuthResponse(w, retryAfter)
-			return
-		}
-		role, ok := authenticateRequestRole(r)
-		if ok && role == authRoleAdmin {
-			globalAuthFailureProtector.Clear(authFailureScopeToken, clientIP)
-			next.ServeHTTP(w, withAuthRole(r, role))
-			return
-		}
-		globalAuthFailureProtector.RecordFailure(authFailureScopeToken, clientIP)
-		writeJSON(w, http.StatusUnauthorized, map[string]any{"detail": "admin token required"})
-	})
-}
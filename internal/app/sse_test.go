@@ -0,0 +1,37 @@
+package app
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSSEBus_FiltersPerSubscriber(t *testing.T) {
+	bus := NewSSEBus()
+	defer bus.Close()
+
+	filter, err := parseEventFilter(httptest.NewRequest("GET", "/api/events?targets=1,2&events=run_completed", nil))
+	if err != nil {
+		t.Fatalf("parseEventFilter failed: %v", err)
+	}
+	filtered := bus.subscribe(filter)
+	all := bus.subscribe(nil)
+
+	bus.Publish("run_completed", `{"target_id":3}`)
+	bus.Publish("target_updated", `{"target_id":1}`)
+	bus.Publish("run_completed", `{"target_id":2}`)
+	bus.Publish("run_completed", `{"status":"ok"}`)
+
+	if got := len(all.ch); got != 4 {
+		t.Fatalf("unfiltered subscriber should get every event, got=%d", got)
+	}
+	if got := len(filtered.ch); got != 2 {
+		t.Fatalf("filtered subscriber should get 2 events, got=%d", got)
+	}
+	if ev := <-filtered.ch; ev.Data != `{"target_id":2}` {
+		t.Fatalf("first filtered event should be target 2, got=%s", ev.Data)
+	}
+
+	if _, err := parseEventFilter(httptest.NewRequest("GET", "/api/events?targets=abc", nil)); err == nil {
+		t.Fatalf("non-numeric targets should be rejected")
+	}
+}
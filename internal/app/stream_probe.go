@@ -0,0 +1,255 @@
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"strings"
+	"time"
+)
+
+// streamChunk is what a protocol-specific parser extracts from one SSE event.
+type streamChunk struct {
+	Text string
+	// OutputTokens is the upstream-reported completion token count, 0 when absent.
+	OutputTokens int
+	Error        string
+}
+
+type streamChunkParser func(event string, data map[string]any) streamChunk
+
+// StreamResult holds the outcome of a streaming HTTP request.
+type StreamResult struct {
+	StatusCode int
+	// Text and JSONBody carry the raw body when the upstream did not return 200.
+	Text     string
+	JSONBody any
+	Content  string
+	// StreamError is an error event reported inside a 200 stream.
+	StreamError   string
+	ElapsedMs     int
+	FirstTokenMs  *float64
+	OutputTokens  int
+	TokensCounted bool
+	// Model is the model id echoed by the first chunk that names one.
+	Model string
+	// Usage is the token usage reported by the stream's usage events.
+	Usage  tokenUsage
+	Timing httpTiming
+}
+
+// httpStream sends a streaming request and consumes the SSE response, recording when the
+// first text delta arrived and how many output tokens were produced.
+func httpStream(ctx context.Context, client *http.Client, method, reqURL string, headers map[string]string, body any, parse streamChunkParser) (*StreamResult, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("marshal body: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, reqURL, bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "text/event-stream")
+
+	timer := newRequestTimer()
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), timer.trace()))
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		elapsedMs := int(time.Since(start).Milliseconds())
+		return nil, fmt.Errorf("HTTP %s %s failed (%dms): %w", method, reqURL, elapsedMs, err)
+	}
+	defer resp.Body.Close()
+
+	out := &StreamResult{StatusCode: resp.StatusCode}
+	bodyStart := time.Now()
+	if resp.StatusCode != http.StatusOK {
+		raw, _ := io.ReadAll(resp.Body)
+		out.Text = string(raw)
+		if len(raw) > 0 {
+			_ = json.Unmarshal(raw, &out.JSONBody)
+		}
+	} else if err := consumeSSE(resp.Body, parse, start, out); err != nil {
+		out.StreamError = err.Error()
+	}
+	timer.bodyRead(time.Since(bodyStart))
+	out.ElapsedMs = int(time.Since(start).Milliseconds())
+	out.Timing = timer.result()
+	return out, nil
+}
+
+func consumeSSE(r io.Reader, parse streamChunkParser, start time.Time, out *StreamResult) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4<<20)
+
+	var content strings.Builder
+	textChars := 0
+	event := ""
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		switch {
+		case line == "":
+			event = ""
+			continue
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+			continue
+		case !strings.HasPrefix(line, "data:"):
+			continue
+		}
+		payload := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if payload == "" || payload == "[DONE]" {
+			continue
+		}
+		var obj map[string]any
+		if err := json.Unmarshal([]byte(payload), &obj); err != nil {
+			continue
+		}
+		if msg := checkResponseBodyForError(obj); msg != "" {
+			out.StreamError = msg
+			continue
+		}
+		if out.Model == "" {
+			out.Model = servedModel(obj)
+		}
+		out.Usage.merge(parseUsage(obj))
+		chunk := parse(event, obj)
+		if chunk.Error != "" {
+			out.StreamError = chunk.Error
+		}
+		if chunk.Text != "" {
+			if out.FirstTokenMs == nil {
+				ms := float64(time.Since(start).Microseconds()) / 1000.0
+				out.FirstTokenMs = &ms
+			}
+			textChars += len(chunk.Text)
+			if content.Len() < 500 {
+				content.WriteString(chunk.Text)
+			}
+		}
+		if chunk.OutputTokens > 0 {
+			out.OutputTokens = chunk.OutputTokens
+			out.TokensCounted = true
+		}
+	}
+	out.Content = truncStr(strings.TrimSpace(content.String()), 500)
+	if !out.TokensCounted && textChars > 0 {
+		// No usage block: approximate with the common ~4 chars per token heuristic.
+		out.OutputTokens = max(1, textChars/4)
+	}
+	return scanner.Err()
+}
+
+// streamThroughput returns output tokens per second measured from the first token to the end of the stream.
+func streamThroughput(res *StreamResult) *float64 {
+	if res.FirstTokenMs == nil || res.OutputTokens <= 0 {
+		return nil
+	}
+	windowMs := float64(res.ElapsedMs) - *res.FirstTokenMs
+	if windowMs < 1 {
+		return nil
+	}
+	tps := float64(res.OutputTokens) / (windowMs / 1000.0)
+	return &tps
+}
+
+func nestedMap(m map[string]any, key string) map[string]any {
+	v, _ := m[key].(map[string]any)
+	return v
+}
+
+func intField(m map[string]any, key string) int {
+	if m == nil {
+		return 0
+	}
+	f, _ := toFloat64(m[key])
+	return int(f)
+}
+
+func parseChatStreamChunk(_ string, data map[string]any) streamChunk {
+	var chunk streamChunk
+	if choices, ok := data["choices"].([]any); ok && len(choices) > 0 {
+		if c0, ok := choices[0].(map[string]any); ok {
+			delta := nestedMap(c0, "delta")
+			for _, key := range []string{"content", "reasoning_content"} {
+				if s, ok := delta[key].(string); ok && s != "" {
+					chunk.Text = s
+					break
+				}
+			}
+		}
+	}
+	chunk.OutputTokens = intField(nestedMap(data, "usage"), "completion_tokens")
+	return chunk
+}
+
+func parseResponsesStreamChunk(event string, data map[string]any) streamChunk {
+	var chunk streamChunk
+	typ, _ := data["type"].(string)
+	if typ == "" {
+		typ = event
+	}
+	switch typ {
+	case "response.output_text.delta", "response.reasoning_summary_text.delta":
+		chunk.Text, _ = data["delta"].(string)
+	case "response.completed":
+		chunk.OutputTokens = intField(nestedMap(nestedMap(data, "response"), "usage"), "output_tokens")
+	case "response.failed":
+		chunk.Error = "response failed"
+		if msg, ok := nestedMap(nestedMap(data, "response"), "error")["message"].(string); ok && msg != "" {
+			chunk.Error = msg
+		}
+	}
+	return chunk
+}
+
+func parseAnthropicStreamChunk(event string, data map[string]any) streamChunk {
+	var chunk streamChunk
+	typ, _ := data["type"].(string)
+	if typ == "" {
+		typ = event
+	}
+	switch typ {
+	case "content_block_delta":
+		delta := nestedMap(data, "delta")
+		for _, key := range []string{"text", "thinking"} {
+			if s, ok := delta[key].(string); ok && s != "" {
+				chunk.Text = s
+				break
+			}
+		}
+	case "message_delta":
+		chunk.OutputTokens = intField(nestedMap(data, "usage"), "output_tokens")
+	}
+	return chunk
+}
+
+func parseGeminiStreamChunk(_ string, data map[string]any) streamChunk {
+	var chunk streamChunk
+	if candidates, ok := data["candidates"].([]any); ok && len(candidates) > 0 {
+		if c0, ok := candidates[0].(map[string]any); ok {
+			parts, _ := nestedMap(c0, "content")["parts"].([]any)
+			for _, part := range parts {
+				p, ok := part.(map[string]any)
+				if !ok {
+					continue
+				}
+				if s, ok := p["text"].(string); ok && s != "" {
+					chunk.Text += s
+				}
+			}
+		}
+	}
+	chunk.OutputTokens = intField(nestedMap(data, "usageMetadata"), "candidatesTokenCount")
+	return chunk
+}
@@ -0,0 +1,60 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHTTPStream_ChatFirstTokenAndThroughput(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		flusher := w.(http.Flusher)
+		time.Sleep(20 * time.Millisecond)
+		for _, piece := range []string{"gpt", "-4o"} {
+			fmt.Fprintf(w, "data: {\"choices\":[{\"delta\":{\"content\":%q}}]}\n\n", piece)
+			flusher.Flush()
+			time.Sleep(10 * time.Millisecond)
+		}
+		fmt.Fprint(w, "data: {\"choices\":[],\"usage\":{\"completion_tokens\":3}}\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer srv.Close()
+
+	res, err := httpStream(context.Background(), httpClient(5, false, nil, tlsFingerprintNone), "POST", srv.URL, nil, map[string]any{"stream": true}, parseChatStreamChunk)
+	if err != nil {
+		t.Fatalf("httpStream failed: %v", err)
+	}
+	if res.Content != "gpt-4o" {
+		t.Fatalf("stream content should be concatenated deltas, got=%q", res.Content)
+	}
+	if res.FirstTokenMs == nil || *res.FirstTokenMs < 15 {
+		t.Fatalf("first token latency should include the initial delay, got=%v", res.FirstTokenMs)
+	}
+	if !res.TokensCounted || res.OutputTokens != 3 {
+		t.Fatalf("output tokens should come from usage, got=%d counted=%v", res.OutputTokens, res.TokensCounted)
+	}
+	if tps := streamThroughput(res); tps == nil || *tps <= 0 {
+		t.Fatalf("tokens per second should be positive, got=%v", tps)
+	}
+}
+
+func TestParseAnthropicStreamChunk(t *testing.T) {
+	delta := parseAnthropicStreamChunk("content_block_delta", map[string]any{
+		"type":  "content_block_delta",
+		"delta": map[string]any{"type": "text_delta", "text": "hi"},
+	})
+	if delta.Text != "hi" {
+		t.Fatalf("content_block_delta text should be extracted, got=%q", delta.Text)
+	}
+	usage := parseAnthropicStreamChunk("message_delta", map[string]any{
+		"type":  "message_delta",
+		"usage": map[string]any{"output_tokens": float64(12)},
+	})
+	if usage.OutputTokens != 12 {
+		t.Fatalf("message_delta usage should be extracted, got=%d", usage.OutputTokens)
+	}
+}
@@ -0,0 +1,76 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Duplicate targets. Two targets with the same normalized base_url and the same api_key
+// probe the same upstream channel, so monitoring both only doubles the upstream spend.
+// POST /api/targets answers 409 with the existing targets unless ?allow_duplicate=1 is
+// set; cloning a target is deliberate and is not checked.
+
+// targetDuplicateRef names an existing target that a new one duplicates.
+type targetDuplicateRef struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// duplicateBaseURL normalizes baseURL for comparison: trailing slashes and /v1 are
+// dropped and the scheme and host are lower-cased.
+func duplicateBaseURL(baseURL string) string {
+	u := normalizeBaseURL(baseURL)
+	parsed, err := url.Parse(u)
+	if err != nil || parsed.Host == "" {
+		return strings.ToLower(u)
+	}
+	parsed.Scheme = strings.ToLower(parsed.Scheme)
+	parsed.Host = strings.ToLower(parsed.Host)
+	return parsed.String()
+}
+
+// FindDuplicateTargets returns the targets (not in the trash) whose normalized base URL
+// and API key equal the given ones.
+func (d *Database) FindDuplicateTargets(baseURL, apiKey string) ([]targetDuplicateRef, error) {
+	apiKey = strings.TrimSpace(apiKey)
+	if apiKey == "" {
+		return nil, nil
+	}
+	targets, err := d.ListTargets()
+	if err != nil {
+		return nil, err
+	}
+	want := duplicateBaseURL(baseURL)
+	var out []targetDuplicateRef
+	for _, t := range targets {
+		if t.APIKey == apiKey && duplicateBaseURL(t.BaseURL) == want {
+			out = append(out, targetDuplicateRef{ID: t.ID, Name: t.Name})
+		}
+	}
+	return out, nil
+}
+
+// rejectDuplicateTarget answers 409 and returns true when payload duplicates an existing
+// target and the request does not allow it.
+func (h *Handlers) rejectDuplicateTarget(w http.ResponseWriter, r *http.Request, payload map[string]any) bool {
+	if queryFlag(r, "allow_duplicate") {
+		return false
+	}
+	baseURL, _ := payload["base_url"].(string)
+	apiKey, _ := payload["api_key"].(string)
+	dups, err := h.db.FindDuplicateTargets(baseURL, apiKey)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return true
+	}
+	if len(dups) == 0 {
+		return false
+	}
+	writeJSON(w, http.StatusConflict, map[string]any{
+		"detail":     fmt.Sprintf("target %q (#%d) already monitors this base_url with the same api_key; add ?allow_duplicate=1 to create it anyway", dups[0].Name, dups[0].ID),
+		"duplicates": dups,
+	})
+	return true
+}
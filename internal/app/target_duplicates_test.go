@@ -0,0 +1,44 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCreateTargetRejectsDuplicates(t *testing.T) {
+	db, err := NewDatabase(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDatabase failed: %v", err)
+	}
+	t.Cleanup(func() { _ = db.conn.Close() })
+	existing, err := db.CreateTarget(map[string]any{"name": "a", "base_url": "https://API.example.com/v1/", "api_key": "sk-1"})
+	if err != nil {
+		t.Fatalf("CreateTarget failed: %v", err)
+	}
+	h := &Handlers{db: db, monitor: NewMonitorService(MonitorConfig{DB: db, LogDir: t.TempDir()})}
+	create := func(url, body string) *httptest.ResponseRecorder {
+		req := withAuthRole(httptest.NewRequest(http.MethodPost, url, strings.NewReader(body)), authRoleAdmin)
+		rr := httptest.NewRecorder()
+		h.CreateTarget(rr, req)
+		return rr
+	}
+
+	rr := create("/api/targets", `{"name":"b","base_url":"https://api.example.com","api_key":"sk-1"}`)
+	var out struct {
+		Duplicates []targetDuplicateRef `json:"duplicates"`
+	}
+	_ = json.Unmarshal(rr.Body.Bytes(), &out)
+	if rr.Code != http.StatusConflict || len(out.Duplicates) != 1 || out.Duplicates[0].ID != existing.ID {
+		t.Fatalf("same url and key should conflict with the existing target, got status=%d body=%s", rr.Code, rr.Body.String())
+	}
+	if rr := create("/api/targets", `{"name":"c","base_url":"https://api.example.com","api_key":"sk-2"}`); rr.Code != http.StatusOK {
+		t.Fatalf("another key on the same url should be allowed, got status=%d body=%s", rr.Code, rr.Body.String())
+	}
+	if rr := create("/api/targets?allow_duplicate=1", `{"name":"d","base_url":"https://api.example.com","api_key":"sk-1"}`); rr.Code != http.StatusOK {
+		t.Fatalf("allow_duplicate should override the check, got status=%d body=%s", rr.Code, rr.Body.String())
+	}
+}
@@ -0,0 +1,371 @@
+package app
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// TargetPatch is a typed set of target settings. UpdateTargetPatch writes the fields
+// that are set; CreateTargetPatch fills the rest with column defaults. A nil pointer,
+// slice or map is unset, so an empty slice or map clears the column.
+type TargetPatch struct {
+	Name                         *string
+	BaseURL                      *string
+	APIKey                       *string
+	Enabled                      *bool
+	IntervalMin                  *int
+	TimeoutS                     *float64
+	VerifySSL                    *bool
+	Prompt                       *string
+	AnthropicVersion             *string
+	MaxModels                    *int
+	SourceURL                    nullable[string]
+	SortOrder                    *int
+	VisitorChannelActionsEnabled *bool
+	SelectedModels               []string
+	ExtraHeaders                 map[string]string
+	ProxyURL                     *string
+	TLSFingerprint               *string
+	ModelOverrides               map[string]ModelOverride
+	IncludePatterns              []string
+	ExcludePatterns              []string
+	ProbeEndpoints               []string
+	StreamProbe                  *bool
+	ToolProbe                    *bool
+	CapabilityProbes             []string
+	SnoozedUntil                 nullable[float64]
+	FastRetryMin                 *int
+	TemplateID                   nullable[int]
+	DetectConcurrency            *int
+	MaxRunDurationS              *int
+	DebugCapture                 *bool
+	LogMaxAgeDays                *int
+	BalanceProbe                 *string
+	BalanceAlertBelow            *float64
+	APIKeys                      []string
+	Tags                         []string
+	Severity                     *string
+	ProxyTransform               *ProxyTransform
+}
+
+// nullable is a patch value for a nullable column: when Set, Value is written and nil
+// stores NULL.
+type nullable[T any] struct {
+	Set   bool
+	Value *T
+}
+
+func setNull[T any](v *T) nullable[T] {
+	return nullable[T]{Set: true, Value: v}
+}
+
+func ptrTo[T any](v T) *T {
+	return &v
+}
+
+// targetPatchFromPayload decodes a JSON target payload, as accepted by the targets API
+// and checked by validateTargetPayload. Unknown keys are ignored. A null value clears a
+// nullable column and resets any other column to its zero value.
+func targetPatchFromPayload(payload map[string]any) TargetPatch {
+	var p TargetPatch
+	for key, val := range payload {
+		switch key {
+		case "name":
+			p.Name = ptrTo(stringFromAny(val, ""))
+		case "base_url":
+			p.BaseURL = ptrTo(stringFromAny(val, ""))
+		case "api_key":
+			p.APIKey = ptrTo(stringFromAny(val, ""))
+		case "prompt":
+			p.Prompt = ptrTo(stringFromAny(val, ""))
+		case "anthropic_version":
+			p.AnthropicVersion = ptrTo(stringFromAny(val, ""))
+		case "proxy_url":
+			p.ProxyURL = ptrTo(stringFromAny(val, ""))
+		case "tls_fingerprint":
+			p.TLSFingerprint = ptrTo(stringFromAny(val, tlsFingerprintChrome))
+		case "enabled":
+			p.Enabled = ptrTo(boolFromAny(val, false))
+		case "verify_ssl":
+			p.VerifySSL = ptrTo(boolFromAny(val, false))
+		case "visitor_channel_actions_enabled":
+			p.VisitorChannelActionsEnabled = ptrTo(boolFromAny(val, false))
+		case "stream_probe":
+			p.StreamProbe = ptrTo(boolFromAny(val, false))
+		case "tool_probe":
+			p.ToolProbe = ptrTo(boolFromAny(val, false))
+		case "debug_capture":
+			p.DebugCapture = ptrTo(boolFromAny(val, false))
+		case "interval_min":
+			p.IntervalMin = ptrTo(intFromAny(val, 0))
+		case "max_models":
+			p.MaxModels = ptrTo(intFromAny(val, 0))
+		case "sort_order":
+			p.SortOrder = ptrTo(intFromAny(val, 0))
+		case "fast_retry_min":
+			p.FastRetryMin = ptrTo(intFromAny(val, 0))
+		case "detect_concurrency":
+			p.DetectConcurrency = ptrTo(intFromAny(val, 0))
+		case "max_run_duration_s":
+			p.MaxRunDurationS = ptrTo(intFromAny(val, 0))
+		case "log_max_age_days":
+			p.LogMaxAgeDays = ptrTo(intFromAny(val, 0))
+		case "timeout_s":
+			p.TimeoutS = ptrTo(floatFromAny(val, 30.0))
+		case "balance_probe":
+			p.BalanceProbe = ptrTo(stringFromAny(val, ""))
+		case "balance_alert_below":
+			p.BalanceAlertBelow = ptrTo(floatFromAny(val, 0))
+		case "selected_models":
+			p.SelectedModels = stringSliceFromAny(val)
+		case "api_keys":
+			p.APIKeys = stringSliceFromAny(val)
+		case "tags":
+			p.Tags = normalizeTargetTags(stringSliceFromAny(val))
+		case "severity":
+			p.Severity = ptrTo(strings.ToLower(strings.TrimSpace(stringFromAny(val, severityCritical))))
+		case "include_patterns":
+			p.IncludePatterns = stringSliceFromAny(val)
+		case "exclude_patterns":
+			p.ExcludePatterns = stringSliceFromAny(val)
+		case "probe_endpoints":
+			p.ProbeEndpoints = stringSliceFromAny(val)
+		case "capability_probes":
+			p.CapabilityProbes = stringSliceFromAny(val)
+		case "extra_headers":
+			p.ExtraHeaders = stringMapFromAny(val)
+		case "model_overrides":
+			p.ModelOverrides = modelOverridesFromAny(val)
+		case "proxy_transform":
+			p.ProxyTransform = ptrTo(proxyTransformFromAny(val))
+		case "source_url":
+			p.SourceURL = setNull(nullStringFromAny(val))
+		case "template_id":
+			// null unlinks the target from its template
+			var id *int
+			if n, ok := anyInt(val); ok && n > 0 {
+				id = &n
+			}
+			p.TemplateID = setNull(id)
+		case "snoozed_until":
+			// null or a past timestamp clears the snooze
+			var until *float64
+			if val != nil {
+				until = ptrTo(floatFromAny(val, 0))
+			}
+			p.SnoozedUntil = setNull(until)
+		}
+	}
+	return p
+}
+
+// withDefaults returns p with unset fields filled with the defaults of a new target.
+func (p TargetPatch) withDefaults() TargetPatch {
+	setDefault(&p.Name, "")
+	setDefault(&p.BaseURL, "")
+	setDefault(&p.APIKey, "")
+	setDefault(&p.Enabled, true)
+	setDefault(&p.IntervalMin, 30)
+	setDefault(&p.TimeoutS, 30.0)
+	setDefault(&p.VerifySSL, false)
+	setDefault(&p.Prompt, defaultTargetPrompt)
+	setDefault(&p.AnthropicVersion, defaultAnthropicVersion)
+	setDefault(&p.MaxModels, 0)
+	setDefault(&p.SortOrder, 0)
+	setDefault(&p.VisitorChannelActionsEnabled, false)
+	setDefault(&p.ProxyURL, "")
+	setDefault(&p.TLSFingerprint, tlsFingerprintChrome)
+	setDefault(&p.StreamProbe, false)
+	setDefault(&p.ToolProbe, false)
+	setDefault(&p.FastRetryMin, 0)
+	setDefault(&p.DetectConcurrency, 0)
+	setDefault(&p.MaxRunDurationS, 0)
+	setDefault(&p.DebugCapture, false)
+	setDefault(&p.LogMaxAgeDays, 0)
+	setDefault(&p.BalanceProbe, "")
+	setDefault(&p.BalanceAlertBelow, 0.0)
+	setDefault(&p.Severity, severityCritical)
+	for _, s := range []*[]string{&p.SelectedModels, &p.IncludePatterns, &p.ExcludePatterns, &p.ProbeEndpoints, &p.CapabilityProbes, &p.APIKeys, &p.Tags} {
+		if *s == nil {
+			*s = []string{}
+		}
+	}
+	if p.ExtraHeaders == nil {
+		p.ExtraHeaders = map[string]string{}
+	}
+	if p.ModelOverrides == nil {
+		p.ModelOverrides = map[string]ModelOverride{}
+	}
+	setDefault(&p.ProxyTransform, ProxyTransform{})
+	return p
+}
+
+func setDefault[T any](field **T, def T) {
+	if *field == nil {
+		*field = &def
+	}
+}
+
+// columns returns the set fields as target column names and SQL arguments. The API key
+// is sealed and list and map columns are JSON-encoded.
+func (p TargetPatch) columns() ([]string, []any, error) {
+	var cols []string
+	var args []any
+	add := func(col string, val any) {
+		cols = append(cols, col)
+		args = append(args, val)
+	}
+	addString := func(col string, v *string) {
+		if v != nil {
+			add(col, *v)
+		}
+	}
+	addBool := func(col string, v *bool) {
+		if v != nil {
+			add(col, boolToInt(*v))
+		}
+	}
+	addInt := func(col string, v *int) {
+		if v != nil {
+			add(col, *v)
+		}
+	}
+	addJSON := func(col string, set bool, v any) {
+		if set {
+			raw, _ := json.Marshal(v)
+			add(col, string(raw))
+		}
+	}
+
+	addString("name", p.Name)
+	addString("base_url", p.BaseURL)
+	if p.APIKey != nil {
+		sealed, err := encryptSecret(*p.APIKey)
+		if err != nil {
+			return nil, nil, err
+		}
+		add("api_key", sealed)
+	}
+	if p.APIKeys != nil {
+		raw, _ := json.Marshal(p.APIKeys)
+		sealed := string(raw)
+		if len(p.APIKeys) > 0 {
+			var err error
+			if sealed, err = encryptSecret(sealed); err != nil {
+				return nil, nil, err
+			}
+		}
+		add("api_keys", sealed)
+	}
+	addBool("enabled", p.Enabled)
+	addInt("interval_min", p.IntervalMin)
+	if p.TimeoutS != nil {
+		add("timeout_s", *p.TimeoutS)
+	}
+	addBool("verify_ssl", p.VerifySSL)
+	addString("prompt", p.Prompt)
+	addString("anthropic_version", p.AnthropicVersion)
+	addInt("max_models", p.MaxModels)
+	if p.SourceURL.Set {
+		add("source_url", p.SourceURL.Value)
+	}
+	addInt("sort_order", p.SortOrder)
+	addBool("visitor_channel_actions_enabled", p.VisitorChannelActionsEnabled)
+	addJSON("selected_models", p.SelectedModels != nil, p.SelectedModels)
+	addJSON("extra_headers", p.ExtraHeaders != nil, p.ExtraHeaders)
+	if p.ProxyURL != nil {
+		add("proxy_url", strings.TrimSpace(*p.ProxyURL))
+	}
+	if p.TLSFingerprint != nil {
+		add("tls_fingerprint", strings.TrimSpace(*p.TLSFingerprint))
+	}
+	addJSON("model_overrides", p.ModelOverrides != nil, p.ModelOverrides)
+	addJSON("include_patterns", p.IncludePatterns != nil, p.IncludePatterns)
+	addJSON("exclude_patterns", p.ExcludePatterns != nil, p.ExcludePatterns)
+	addJSON("probe_endpoints", p.ProbeEndpoints != nil, p.ProbeEndpoints)
+	addBool("stream_probe", p.StreamProbe)
+	addBool("tool_probe", p.ToolProbe)
+	addJSON("capability_probes", p.CapabilityProbes != nil, p.CapabilityProbes)
+	if p.SnoozedUntil.Set {
+		add("snoozed_until", p.SnoozedUntil.Value)
+	}
+	addInt("fast_retry_min", p.FastRetryMin)
+	if p.TemplateID.Set {
+		add("template_id", p.TemplateID.Value)
+	}
+	addInt("detect_concurrency", p.DetectConcurrency)
+	addInt("max_run_duration_s", p.MaxRunDurationS)
+	addBool("debug_capture", p.DebugCapture)
+	addInt("log_max_age_days", p.LogMaxAgeDays)
+	if p.BalanceProbe != nil {
+		add("balance_probe", strings.TrimSpace(*p.BalanceProbe))
+	}
+	if p.BalanceAlertBelow != nil {
+		add("balance_alert_below", *p.BalanceAlertBelow)
+	}
+	addJSON("tags", p.Tags != nil, p.Tags)
+	addString("severity", p.Severity)
+	addJSON("proxy_transform", p.ProxyTransform != nil, p.ProxyTransform)
+	return cols, args, nil
+}
+
+// CreateTargetPatch inserts a new target and returns it. A sort order of 0 places it last.
+func (d *Database) CreateTargetPatch(p TargetPatch) (*Target, error) {
+	p = p.withDefaults()
+	now := float64(time.Now().UnixMilli()) / 1000.0
+
+	defer d.touchTargets()
+	d.mu.Lock()
+	if *p.SortOrder <= 0 {
+		if err := d.conn.QueryRow("SELECT COALESCE(MAX(sort_order), 0) + 1 FROM targets").Scan(p.SortOrder); err != nil {
+			d.mu.Unlock()
+			return nil, err
+		}
+	}
+	cols, args, err := p.columns()
+	if err != nil {
+		d.mu.Unlock()
+		return nil, err
+	}
+	cols = append(cols, "created_at", "updated_at")
+	args = append(args, now, now)
+	res, err := d.conn.Exec(
+		"INSERT INTO targets ("+strings.Join(cols, ", ")+") VALUES (?"+strings.Repeat(", ?", len(cols)-1)+")",
+		args...,
+	)
+	d.mu.Unlock()
+
+	if err != nil {
+		return nil, d.explainTrashedName(err, p.Name)
+	}
+	id, _ := res.LastInsertId()
+	return d.GetTarget(int(id))
+}
+
+// UpdateTargetPatch writes the set fields of p to a target and returns it.
+func (d *Database) UpdateTargetPatch(targetID int, p TargetPatch) (*Target, error) {
+	cols, args, err := p.columns()
+	if err != nil {
+		return nil, err
+	}
+	if len(cols) == 0 {
+		return d.GetTarget(targetID)
+	}
+	setClauses := make([]string, 0, len(cols)+1)
+	for _, col := range cols {
+		setClauses = append(setClauses, col+" = ?")
+	}
+	setClauses = append(setClauses, "updated_at = ?")
+	args = append(args, float64(time.Now().UnixMilli())/1000.0, targetID)
+
+	defer d.touchTargets()
+	d.mu.Lock()
+	_, err = d.conn.Exec("UPDATE targets SET "+strings.Join(setClauses, ", ")+" WHERE id = ?", args...)
+	d.mu.Unlock()
+
+	if err != nil {
+		return nil, d.explainTrashedName(err, p.Name)
+	}
+	return d.GetTarget(targetID)
+}
@@ -0,0 +1,56 @@
+package app
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestTargetPatch_CreateAndUpdate(t *testing.T) {
+	db, err := NewDatabase(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDatabase failed: %v", err)
+	}
+	t.Cleanup(func() { _ = db.conn.Close() })
+
+	created, err := db.CreateTargetPatch(TargetPatch{
+		Name: ptrTo("typed"), BaseURL: ptrTo("https://example.com"), APIKey: ptrTo("k"),
+		SelectedModels: []string{"gpt-a"}, TemplateID: setNull[int](nil),
+	})
+	if err != nil {
+		t.Fatalf("CreateTargetPatch failed: %v", err)
+	}
+	if !created.Enabled || created.IntervalMin != 30 || created.TimeoutS != 30 || created.Prompt != defaultTargetPrompt ||
+		created.TLSFingerprint != tlsFingerprintChrome || created.SortOrder != 1 || len(created.SelectedModels) != 1 {
+		t.Fatalf("unset fields should get the column defaults, got=%+v", created)
+	}
+
+	snooze := 4102444800.0
+	updated, err := db.UpdateTargetPatch(created.ID, TargetPatch{
+		IntervalMin: ptrTo(5), SelectedModels: []string{}, SnoozedUntil: setNull(&snooze), SourceURL: setNull(ptrTo("https://src")),
+	})
+	if err != nil {
+		t.Fatalf("UpdateTargetPatch failed: %v", err)
+	}
+	if updated.IntervalMin != 5 || len(updated.SelectedModels) != 0 || updated.SnoozedUntil == nil || updated.SourceURL == nil {
+		t.Fatalf("set fields should be written, got=%+v", updated)
+	}
+	if updated.Name != "typed" || !updated.Enabled || updated.Prompt != defaultTargetPrompt {
+		t.Fatalf("unset fields should be left unchanged, got=%+v", updated)
+	}
+
+	// The JSON payload form: null clears nullable columns on update and means "default" on create.
+	updated, err = db.UpdateTarget(created.ID, map[string]any{"snoozed_until": nil, "source_url": nil, "bogus": 1})
+	if err != nil {
+		t.Fatalf("UpdateTarget failed: %v", err)
+	}
+	if updated.SnoozedUntil != nil || updated.SourceURL != nil {
+		t.Fatalf("null should clear nullable columns, got=%+v", updated)
+	}
+	fromMap, err := db.CreateTarget(map[string]any{"name": "map", "base_url": "https://example.com", "api_key": "k", "enabled": nil, "interval_min": float64(10)})
+	if err != nil {
+		t.Fatalf("CreateTarget failed: %v", err)
+	}
+	if !fromMap.Enabled || fromMap.IntervalMin != 10 || fromMap.SortOrder != 2 {
+		t.Fatalf("payload create should decode values and default null ones, got=%+v", fromMap)
+	}
+}
@@ -0,0 +1,733 @@
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// GitOps target sync: when TARGETS_SYNC_URL is set, a targets manifest (JSON or YAML,
+// e.g. a git raw link) is fetched every TARGETS_SYNC_INTERVAL_S and reconciled with
+// the database. Synced targets are tagged with source_url "<manifest url>#<name>";
+// only those are disabled when they disappear from the manifest, hand-made targets
+// are never touched unless the manifest adopts them by name + base_url.
+
+const (
+	targetSyncModeApply  = "apply"
+	targetSyncModeReport = "report"
+
+	targetSyncMinInterval = 30 * time.Second
+)
+
+// targetSyncFields are the manifest keys besides api_key_env; they match the
+// CreateTarget payload. Keys a manifest entry omits are left as they are.
+var targetSyncFields = func() map[string]bool {
+	out := map[string]bool{"api_key": true, "api_keys": true}
+	for key := range targetBundleFields(Target{}, false) {
+		out[key] = true
+	}
+	delete(out, "sort_order")
+	return out
+}()
+
+type targetSyncChange struct {
+	Name     string   `json:"name"`
+	Action   string   `json:"action"`
+	TargetID int      `json:"target_id,omitempty"`
+	Fields   []string `json:"fields,omitempty"`
+	Applied  bool     `json:"applied"`
+	Detail   string   `json:"detail,omitempty"`
+}
+
+// targetSyncStatus describes the last sync. Drift is true when the database
+// differed from the manifest; in apply mode Applied says whether it was fixed.
+type targetSyncStatus struct {
+	Enabled         bool               `json:"enabled"`
+	URL             string             `json:"url,omitempty"`
+	Mode            string             `json:"mode,omitempty"`
+	IntervalS       int                `json:"interval_s,omitempty"`
+	DisableMissing  bool               `json:"disable_missing"`
+	LastSyncAt      *float64           `json:"last_sync_at"`
+	LastError       string             `json:"last_error,omitempty"`
+	ManifestTargets int                `json:"manifest_targets"`
+	InSync          int                `json:"in_sync"`
+	Drift           bool               `json:"drift"`
+	Changes         []targetSyncChange `json:"changes"`
+}
+
+type targetSyncer struct {
+	db             *Database
+	monitor        *MonitorService
+	log            *slog.Logger
+	url            string
+	sourceURL      string
+	token          string
+	mode           string
+	interval       time.Duration
+	disableMissing bool
+	client         *http.Client
+
+	// runMu serializes syncs; mu guards status.
+	runMu  sync.Mutex
+	mu     sync.Mutex
+	status targetSyncStatus
+}
+
+// newTargetSyncerFromEnv returns the syncer configured by TARGETS_SYNC_*, or nil
+// when TARGETS_SYNC_URL is not set.
+func newTargetSyncerFromEnv(db *Database, monitor *MonitorService, logger *slog.Logger) (*targetSyncer, error) {
+	raw := strings.TrimSpace(os.Getenv("TARGETS_SYNC_URL"))
+	if raw == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("TARGETS_SYNC_URL must be an http(s) URL")
+	}
+	mode := strings.ToLower(strings.TrimSpace(os.Getenv("TARGETS_SYNC_MODE")))
+	switch mode {
+	case "":
+		mode = targetSyncModeApply
+	case targetSyncModeApply, targetSyncModeReport:
+	default:
+		return nil, fmt.Errorf("TARGETS_SYNC_MODE must be apply or report")
+	}
+	interval := time.Duration(envInt("TARGETS_SYNC_INTERVAL_S", 300)) * time.Second
+	if interval < targetSyncMinInterval {
+		interval = targetSyncMinInterval
+	}
+	s := &targetSyncer{
+		db:             db,
+		monitor:        monitor,
+		log:            componentLogger(logger, "sync"),
+		url:            raw,
+		sourceURL:      redactedSyncURL(u),
+		token:          strings.TrimSpace(os.Getenv("TARGETS_SYNC_TOKEN")),
+		mode:           mode,
+		interval:       interval,
+		disableMissing: envBool("TARGETS_SYNC_DISABLE_MISSING", true),
+		client:         &http.Client{Timeout: 30 * time.Second},
+	}
+	s.status = s.baseStatus()
+	return s, nil
+}
+
+// redactedSyncURL drops credentials and the query, which may carry a token.
+func redactedSyncURL(u *url.URL) string {
+	return (&url.URL{Scheme: u.Scheme, Host: u.Host, Path: u.Path}).String()
+}
+
+func (s *targetSyncer) baseStatus() targetSyncStatus {
+	return targetSyncStatus{
+		Enabled:        true,
+		URL:            s.sourceURL,
+		Mode:           s.mode,
+		IntervalS:      int(s.interval / time.Second),
+		DisableMissing: s.disableMissing,
+		Changes:        []targetSyncChange{},
+	}
+}
+
+// Status returns the result of the last sync.
+func (s *targetSyncer) Status() targetSyncStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status
+}
+
+// Run syncs immediately and then every interval until ctx is done. Changes applied
+// by scheduled syncs are audited as the system actor.
+func (s *targetSyncer) Run(ctx context.Context) {
+	s.log.Info("target sync enabled", "url", s.sourceURL, "mode", s.mode, "interval", s.interval)
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		status := s.Sync(ctx)
+		if diff := targetSyncAuditDiff(status); diff != nil {
+			err := s.db.InsertAudit(AuditEntry{
+				Timestamp:    float64(time.Now().UnixMilli()) / 1000.0,
+				ActorRole:    "system",
+				ActorMethod:  "sync",
+				Action:       "target.sync",
+				ResourceType: "target",
+				Diff:         diff,
+			})
+			if err != nil {
+				s.log.Error("record audit entry failed", "error", err)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sync fetches the manifest and reconciles it once.
+func (s *targetSyncer) Sync(ctx context.Context) targetSyncStatus {
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+	status := s.baseStatus()
+	now := float64(time.Now().UnixMilli()) / 1000.0
+	status.LastSyncAt = &now
+
+	entries, err := s.fetchManifest(ctx)
+	if err == nil {
+		status.ManifestTargets = len(entries)
+		err = s.reconcile(entries, &status)
+	}
+	if err != nil {
+		status.LastError = err.Error()
+		s.log.Warn("target sync failed", "error", err)
+	} else if status.Drift {
+		s.log.Info("target sync found drift", "changes", len(status.Changes), "mode", s.mode)
+	}
+	s.mu.Lock()
+	s.status = status
+	s.mu.Unlock()
+	return status
+}
+
+func (s *targetSyncer) fetchManifest(ctx context.Context) ([]map[string]any, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch manifest: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch manifest: HTTP %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 8<<20))
+	if err != nil {
+		return nil, fmt.Errorf("fetch manifest: %w", err)
+	}
+	return parseTargetManifest(data)
+}
+
+// parseTargetManifest accepts {"targets": [...]} or a bare list, as JSON or YAML,
+// and returns one CreateTarget-style payload per entry.
+func parseTargetManifest(data []byte) ([]map[string]any, error) {
+	var root any
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') {
+		if err := json.Unmarshal(trimmed, &root); err != nil {
+			return nil, fmt.Errorf("manifest: %w", err)
+		}
+	} else {
+		v, err := parseYAMLDocument(data)
+		if err != nil {
+			return nil, fmt.Errorf("manifest: %w", err)
+		}
+		root = v
+	}
+	if m, ok := root.(map[string]any); ok {
+		root = m["targets"]
+	}
+	list, ok := root.([]any)
+	if !ok || len(list) == 0 {
+		return nil, fmt.Errorf("manifest must list targets")
+	}
+	entries := make([]map[string]any, 0, len(list))
+	names := map[string]bool{}
+	for i, item := range list {
+		entry, ok := item.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("targets[%d] must be an object", i)
+		}
+		name := strings.TrimSpace(stringFromAny(entry["name"], ""))
+		if name == "" {
+			return nil, fmt.Errorf("targets[%d]: name is required", i)
+		}
+		if names[name] {
+			return nil, fmt.Errorf("targets[%d]: duplicate name %q", i, name)
+		}
+		names[name] = true
+		payload := map[string]any{}
+		for key, value := range entry {
+			switch {
+			case key == "api_key_env":
+				env := strings.TrimSpace(stringFromAny(value, ""))
+				apiKey := os.Getenv(env)
+				if env == "" || apiKey == "" {
+					return nil, fmt.Errorf("targets[%d]: environment variable %q for api_key_env is not set", i, env)
+				}
+				payload["api_key"] = apiKey
+			case targetSyncFields[key]:
+				payload[key] = value
+			default:
+				return nil, fmt.Errorf("targets[%d]: unknown field %q", i, key)
+			}
+		}
+		payload["name"] = name
+		if err := validateTargetPayload(payload); err != nil {
+			return nil, fmt.Errorf("targets[%d]: %w", i, err)
+		}
+		entries = append(entries, payload)
+	}
+	return entries, nil
+}
+
+// reconcile diffs entries against the database and, in apply mode, writes the
+// changes. It fills status.Changes, InSync and Drift.
+func (s *targetSyncer) reconcile(entries []map[string]any, status *targetSyncStatus) error {
+	targets, err := s.db.ListTargets()
+	if err != nil {
+		return err
+	}
+	prefix := s.sourceURL + "#"
+	bySource := map[string]*Target{}
+	byName := map[string]*Target{}
+	for i := range targets {
+		t := &targets[i]
+		if t.SourceURL != nil && strings.HasPrefix(*t.SourceURL, prefix) {
+			bySource[*t.SourceURL] = t
+		}
+		byName[t.Name+"\x00"+normalizeBaseURL(t.BaseURL)] = t
+	}
+
+	apply := s.mode == targetSyncModeApply
+	seen := map[int]bool{}
+	var changedIDs []int
+	for _, payload := range entries {
+		name := payload["name"].(string)
+		sourceURL := prefix + name
+		payload["source_url"] = sourceURL
+		existing := bySource[sourceURL]
+		if existing == nil {
+			if baseURL, ok := payload["base_url"].(string); ok {
+				existing = byName[name+"\x00"+normalizeBaseURL(baseURL)]
+			}
+		}
+
+		if existing == nil {
+			change := targetSyncChange{Name: name, Action: "create"}
+			if baseURL, _ := payload["base_url"].(string); len(baseURL) < 3 {
+				change.Detail = "base_url is required for a new target"
+			} else if key, _ := payload["api_key"].(string); key == "" {
+				change.Detail = "api_key or api_key_env is required for a new target"
+			} else if apply {
+				if target, err := s.db.CreateTarget(payload); err != nil {
+					change.Detail = err.Error()
+				} else {
+					change.TargetID, change.Applied = target.ID, true
+					changedIDs = append(changedIDs, target.ID)
+				}
+			}
+			status.Changes = append(status.Changes, change)
+			continue
+		}
+
+		seen[existing.ID] = true
+		fields := targetSyncDrift(existing, payload)
+		if len(fields) == 0 {
+			status.InSync++
+			continue
+		}
+		change := targetSyncChange{Name: name, Action: "update", TargetID: existing.ID, Fields: fields}
+		if apply {
+			updates := map[string]any{}
+			for _, field := range fields {
+				updates[field] = payload[field]
+			}
+			if _, err := s.db.UpdateTarget(existing.ID, updates); err != nil {
+				change.Detail = err.Error()
+			} else {
+				change.Applied = true
+				changedIDs = append(changedIDs, existing.ID)
+			}
+		}
+		status.Changes = append(status.Changes, change)
+	}
+
+	if s.disableMissing {
+		for _, t := range bySource {
+			if seen[t.ID] || !t.Enabled {
+				continue
+			}
+			change := targetSyncChange{Name: t.Name, Action: "disable", TargetID: t.ID, Fields: []string{"enabled"}}
+			if apply {
+				if _, err := s.db.UpdateTargetPatch(t.ID, TargetPatch{Enabled: ptrTo(false)}); err != nil {
+					change.Detail = err.Error()
+				} else {
+					change.Applied = true
+					changedIDs = append(changedIDs, t.ID)
+				}
+			}
+			status.Changes = append(status.Changes, change)
+		}
+	}
+	sort.SliceStable(status.Changes, func(i, j int) bool { return status.Changes[i].Name < status.Changes[j].Name })
+	status.Drift = len(status.Changes) > 0
+	if len(changedIDs) > 0 && s.monitor != nil {
+		s.monitor.emitJSON("target_updated", map[string]any{"ids": changedIDs, "action": "sync"})
+	}
+	return nil
+}
+
+// targetSyncDrift lists the manifest fields whose value differs from t.
+func targetSyncDrift(t *Target, payload map[string]any) []string {
+	current := targetBundleFields(*t, true)
+	var fields []string
+	for key, want := range payload {
+		have, ok := current[key]
+		if !ok && key != "source_url" {
+			continue
+		}
+		if key == "base_url" {
+			if normalizeBaseURL(stringFromAny(want, "")) != normalizeBaseURL(t.BaseURL) {
+				fields = append(fields, key)
+			}
+			continue
+		}
+		if !reflect.DeepEqual(normalizeSyncValue(have), normalizeSyncValue(want)) {
+			fields = append(fields, key)
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// normalizeSyncValue round-trips v through JSON so typed struct fields compare
+// equal to decoded manifest values; empty lists and objects compare equal to null.
+func normalizeSyncValue(v any) any {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var out any
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return v
+	}
+	switch x := out.(type) {
+	case []any:
+		if len(x) == 0 {
+			return nil
+		}
+	case map[string]any:
+		if len(x) == 0 {
+			return nil
+		}
+	}
+	return out
+}
+
+// targetSyncAuditDiff summarizes the applied changes of status, or nil if none.
+func targetSyncAuditDiff(status targetSyncStatus) map[string]any {
+	var created, updated, disabled []int
+	for _, c := range status.Changes {
+		if !c.Applied {
+			continue
+		}
+		switch c.Action {
+		case "create":
+			created = append(created, c.TargetID)
+		case "update":
+			updated = append(updated, c.TargetID)
+		case "disable":
+			disabled = append(disabled, c.TargetID)
+		}
+	}
+	if len(created)+len(updated)+len(disabled) == 0 {
+		return nil
+	}
+	return map[string]any{
+		"source":   map[string]any{"from": nil, "to": status.URL},
+		"created":  map[string]any{"from": nil, "to": created},
+		"updated":  map[string]any{"from": nil, "to": updated},
+		"disabled": map[string]any{"from": nil, "to": disabled},
+	}
+}
+
+// AdminGetTargetSync handles GET /api/admin/sync: the last sync result and drift.
+func (h *Handlers) AdminGetTargetSync(w http.ResponseWriter, r *http.Request) {
+	if h.sync == nil {
+		writeJSON(w, http.StatusOK, map[string]any{"item": targetSyncStatus{Changes: []targetSyncChange{}}})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"item": h.sync.Status()})
+}
+
+// AdminRunTargetSync handles POST /api/admin/sync: sync now and return the result.
+func (h *Handlers) AdminRunTargetSync(w http.ResponseWriter, r *http.Request) {
+	if h.sync == nil {
+		writeJSON(w, http.StatusConflict, map[string]any{"detail": "target sync is not configured (set TARGETS_SYNC_URL)"})
+		return
+	}
+	status := h.sync.Sync(r.Context())
+	if diff := targetSyncAuditDiff(status); diff != nil {
+		h.audit(r, "target.sync", "target", 0, diff)
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"item": status})
+}
+
+// ---------------------------------------------------------------------------
+// YAML manifests
+// ---------------------------------------------------------------------------
+
+type yamlLine struct {
+	indent int
+	text   string
+	no     int
+}
+
+// parseYAMLDocument decodes the block YAML subset used by manifests: nested
+// mappings and "- " sequences by indentation, scalars (strings, numbers, booleans,
+// null) and inline [a, b] / {k: v} collections. Numbers decode as float64, as in JSON.
+func parseYAMLDocument(data []byte) (any, error) {
+	var lines []yamlLine
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	no := 0
+	for sc.Scan() {
+		no++
+		raw := strings.TrimRight(stripConfigComment(sc.Text()), " \t\r")
+		text := strings.TrimLeft(raw, " ")
+		if text == "" || text == "---" {
+			continue
+		}
+		if strings.HasPrefix(text, "\t") {
+			return nil, fmt.Errorf("line %d: tabs are not allowed for indentation", no)
+		}
+		lines = append(lines, yamlLine{indent: len(raw) - len(text), text: text, no: no})
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(lines) == 0 {
+		return nil, nil
+	}
+	p := &yamlParser{lines: lines}
+	v, err := p.block(lines[0].indent)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.lines) {
+		return nil, fmt.Errorf("line %d: unexpected indentation", p.lines[p.pos].no)
+	}
+	return v, nil
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+func isYAMLSeqItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+func (p *yamlParser) block(indent int) (any, error) {
+	if isYAMLSeqItem(p.lines[p.pos].text) {
+		return p.sequence(indent)
+	}
+	return p.mapping(indent)
+}
+
+// child parses the nested block after a "key:" or "-" line at indent, if any.
+func (p *yamlParser) child(indent int) (any, error) {
+	if p.pos >= len(p.lines) {
+		return nil, nil
+	}
+	next := p.lines[p.pos]
+	if next.indent > indent || (next.indent == indent && isYAMLSeqItem(next.text)) {
+		return p.block(next.indent)
+	}
+	return nil, nil
+}
+
+func (p *yamlParser) sequence(indent int) (any, error) {
+	items := []any{}
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if line.indent != indent || !isYAMLSeqItem(line.text) {
+			break
+		}
+		rest := strings.TrimLeft(strings.TrimPrefix(line.text, "-"), " ")
+		if rest == "" {
+			p.pos++
+			v, err := p.child(indent + 1)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, v)
+			continue
+		}
+		if _, _, ok := yamlKeyValue(rest); ok {
+			// "- key: value" opens a mapping indented to the key's column.
+			p.lines[p.pos] = yamlLine{indent: indent + len(line.text) - len(rest), text: rest, no: line.no}
+			v, err := p.mapping(p.lines[p.pos].indent)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, v)
+			continue
+		}
+		v, err := parseYAMLScalar(rest)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line.no, err)
+		}
+		items = append(items, v)
+		p.pos++
+	}
+	return items, nil
+}
+
+func (p *yamlParser) mapping(indent int) (any, error) {
+	out := map[string]any{}
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if line.indent < indent || (line.indent == indent && isYAMLSeqItem(line.text)) {
+			break
+		}
+		if line.indent > indent {
+			return nil, fmt.Errorf("line %d: unexpected indentation", line.no)
+		}
+		key, value, ok := yamlKeyValue(line.text)
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected key: value", line.no)
+		}
+		if _, dup := out[key]; dup {
+			return nil, fmt.Errorf("line %d: duplicate key %q", line.no, key)
+		}
+		p.pos++
+		if value == "" {
+			v, err := p.child(indent)
+			if err != nil {
+				return nil, err
+			}
+			out[key] = v
+			continue
+		}
+		v, err := parseYAMLScalar(value)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line.no, err)
+		}
+		out[key] = v
+	}
+	return out, nil
+}
+
+// yamlKeyValue splits "key: value" (or "key:"); a colon inside a scalar such as a
+// URL does not count because it is not followed by a space.
+func yamlKeyValue(text string) (string, string, bool) {
+	if isQuotedConfigScalar(text) || strings.HasPrefix(text, "[") || strings.HasPrefix(text, "{") {
+		return "", "", false
+	}
+	idx := strings.Index(text, ": ")
+	if idx < 0 {
+		if !strings.HasSuffix(text, ":") {
+			return "", "", false
+		}
+		idx = len(text) - 1
+	}
+	key := unquoteConfigScalar(strings.TrimSpace(text[:idx]))
+	if key == "" {
+		return "", "", false
+	}
+	return key, strings.TrimSpace(text[idx+1:]), true
+}
+
+func parseYAMLScalar(v string) (any, error) {
+	v = strings.TrimSpace(v)
+	switch {
+	case isQuotedConfigScalar(v):
+		return unquoteConfigScalar(v), nil
+	case strings.HasPrefix(v, "["):
+		if !strings.HasSuffix(v, "]") {
+			return nil, fmt.Errorf("unterminated list")
+		}
+		items := []any{}
+		for _, part := range splitYAMLFlow(v[1 : len(v)-1]) {
+			item, err := parseYAMLScalar(part)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	case strings.HasPrefix(v, "{"):
+		if !strings.HasSuffix(v, "}") {
+			return nil, fmt.Errorf("unterminated object")
+		}
+		out := map[string]any{}
+		for _, part := range splitYAMLFlow(v[1 : len(v)-1]) {
+			key, value, ok := yamlKeyValue(part)
+			if !ok {
+				return nil, fmt.Errorf("expected key: value in %q", part)
+			}
+			item, err := parseYAMLScalar(value)
+			if err != nil {
+				return nil, err
+			}
+			out[key] = item
+		}
+		return out, nil
+	}
+	switch v {
+	case "", "~", "null":
+		return nil, nil
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	}
+	if c := v[0]; (c >= '0' && c <= '9') || c == '-' || c == '+' || c == '.' {
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return f, nil
+		}
+	}
+	return v, nil
+}
+
+// splitYAMLFlow splits the inside of a flow collection on top-level commas.
+func splitYAMLFlow(s string) []string {
+	var parts []string
+	depth, start := 0, 0
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[' || c == '{':
+			depth++
+		case c == ']' || c == '}':
+			depth--
+		case c == ',' && depth == 0:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	parts = append(parts, s[start:])
+	out := parts[:0]
+	for _, part := range parts {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
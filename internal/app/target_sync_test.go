@@ -0,0 +1,148 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestParseTargetManifestYAML(t *testing.T) {
+	t.Setenv("RELAY_KEY", "sk-from-env")
+	manifest := `
+# managed by git
+targets:
+  - name: relay
+    base_url: https://relay.example.com/v1
+    api_key_env: RELAY_KEY
+    interval_min: 10
+    selected_models: [gpt-4o, "claude-3-5-sonnet"]
+    extra_headers: {X-Team: infra}
+  - name: backup
+    base_url: "https://backup.example.com"
+    api_key: sk-inline
+    enabled: false
+    exclude_patterns:
+      - "*-preview"
+`
+	entries, err := parseTargetManifest([]byte(manifest))
+	if err != nil {
+		t.Fatalf("parseTargetManifest failed: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("manifest should have 2 targets, got=%d", len(entries))
+	}
+	relay := entries[0]
+	if relay["api_key"] != "sk-from-env" || relay["interval_min"] != 10.0 {
+		t.Fatalf("relay should resolve api_key_env and numbers, got=%v", relay)
+	}
+	if !reflect.DeepEqual(relay["selected_models"], []any{"gpt-4o", "claude-3-5-sonnet"}) {
+		t.Fatalf("inline list should parse, got=%v", relay["selected_models"])
+	}
+	if !reflect.DeepEqual(relay["extra_headers"], map[string]any{"X-Team": "infra"}) {
+		t.Fatalf("inline object should parse, got=%v", relay["extra_headers"])
+	}
+	if entries[1]["enabled"] != false || !reflect.DeepEqual(entries[1]["exclude_patterns"], []any{"*-preview"}) {
+		t.Fatalf("backup should parse bool and block list, got=%v", entries[1])
+	}
+
+	for _, bad := range []string{
+		"targets: []",
+		"targets:\n  - name: a\n  - name: a\n",
+		"targets:\n  - name: a\n    colour: red\n",
+		"targets:\n  - name: a\n    api_key_env: MISSING_SYNC_KEY\n",
+	} {
+		if _, err := parseTargetManifest([]byte(bad)); err == nil {
+			t.Fatalf("parseTargetManifest should reject %q", bad)
+		}
+	}
+}
+
+func TestTargetSyncReconcile(t *testing.T) {
+	db, err := NewDatabase(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDatabase failed: %v", err)
+	}
+	t.Cleanup(func() { _ = db.conn.Close() })
+
+	var mu sync.Mutex
+	manifest := `[
+		{"name": "relay", "base_url": "https://relay.example.com", "api_key": "sk-relay", "interval_min": 10},
+		{"name": "manual", "base_url": "https://manual.example.com", "interval_min": 15}
+	]`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer git-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		_, _ = w.Write([]byte(manifest))
+	}))
+	defer server.Close()
+
+	manual, err := db.CreateTarget(map[string]any{"name": "manual", "base_url": "https://manual.example.com", "api_key": "sk-manual"})
+	if err != nil {
+		t.Fatalf("CreateTarget failed: %v", err)
+	}
+	untouched, err := db.CreateTarget(map[string]any{"name": "other", "base_url": "https://other.example.com", "api_key": "sk-other"})
+	if err != nil {
+		t.Fatalf("CreateTarget failed: %v", err)
+	}
+
+	t.Setenv("TARGETS_SYNC_URL", server.URL+"/targets.json?ref=main")
+	t.Setenv("TARGETS_SYNC_TOKEN", "git-token")
+	t.Setenv("TARGETS_SYNC_MODE", "report")
+	s, err := newTargetSyncerFromEnv(db, nil, nil)
+	if err != nil {
+		t.Fatalf("newTargetSyncerFromEnv failed: %v", err)
+	}
+
+	report := s.Sync(t.Context())
+	if report.LastError != "" || !report.Drift || len(report.Changes) != 2 {
+		t.Fatalf("report mode should find 2 changes, got=%+v", report)
+	}
+	if targets, _ := db.ListTargets(); len(targets) != 2 {
+		t.Fatalf("report mode should not write, got=%d targets", len(targets))
+	}
+
+	s.mode = targetSyncModeApply
+	applied := s.Sync(t.Context())
+	for _, c := range applied.Changes {
+		if !c.Applied {
+			t.Fatalf("apply mode should apply every change, got=%+v", c)
+		}
+		if c.Name == "manual" && !reflect.DeepEqual(c.Fields, []string{"interval_min", "source_url"}) {
+			t.Fatalf("manual target should be adopted with its drifted fields, got=%v", c.Fields)
+		}
+	}
+	adopted, _ := db.GetTarget(manual.ID)
+	if adopted.IntervalMin != 15 || adopted.APIKey != "sk-manual" || adopted.SourceURL == nil ||
+		*adopted.SourceURL != server.URL+"/targets.json#manual" {
+		t.Fatalf("adopted target should be updated and tagged without the query, got=%+v", adopted)
+	}
+
+	if again := s.Sync(t.Context()); again.Drift || again.InSync != 2 {
+		t.Fatalf("second sync should be in sync, got=%+v", again)
+	}
+
+	mu.Lock()
+	manifest = `targets:
+  - name: relay
+    base_url: https://relay.example.com
+    interval_min: 10
+`
+	mu.Unlock()
+	removed := s.Sync(t.Context())
+	if removed.LastError != "" || len(removed.Changes) != 1 || removed.Changes[0].Action != "disable" {
+		t.Fatalf("target removed from the manifest should be disabled, got=%+v", removed)
+	}
+	if got, _ := db.GetTarget(manual.ID); got.Enabled {
+		t.Fatalf("removed managed target should be disabled")
+	}
+	if got, _ := db.GetTarget(untouched.ID); !got.Enabled {
+		t.Fatalf("targets not managed by the manifest should be left alone")
+	}
+}
@@ -0,0 +1,112 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Connectivity pre-check: GET /v1/models with the credentials of a target that is not
+// saved yet, so a typo in base_url or api_key shows up before the first scheduled run.
+
+// targetValidation is the outcome of a pre-check.
+type targetValidation struct {
+	OK bool `json:"ok"`
+	// Models is everything /v1/models returned; Selected is what the target would
+	// actually probe after selected_models and include/exclude patterns.
+	Models     []string `json:"models"`
+	Selected   []string `json:"selected_models"`
+	DurationMs int64    `json:"duration_ms"`
+	Detail     string   `json:"detail,omitempty"`
+}
+
+// previewTarget builds the transient target a create payload would produce, with the
+// same defaults as Database.CreateTarget for the fields the models request uses.
+func previewTarget(payload map[string]any) *Target {
+	return &Target{
+		Name:             strings.TrimSpace(stringFromAny(payload["name"], "")),
+		BaseURL:          strings.TrimSpace(stringFromAny(payload["base_url"], "")),
+		APIKey:           strings.TrimSpace(stringFromAny(payload["api_key"], "")),
+		Enabled:          true,
+		TimeoutS:         floatFromAny(payload["timeout_s"], 30.0),
+		VerifySSL:        boolFromAny(payload["verify_ssl"], false),
+		Prompt:           defaultTargetPrompt,
+		AnthropicVersion: defaultAnthropicVersion,
+		SelectedModels:   stringSliceFromAny(payload["selected_models"]),
+		ExtraHeaders:     stringMapFromAny(payload["extra_headers"]),
+		ProxyURL:         strings.TrimSpace(stringFromAny(payload["proxy_url"], "")),
+		TLSFingerprint:   strings.TrimSpace(stringFromAny(payload["tls_fingerprint"], tlsFingerprintChrome)),
+		IncludePatterns:  stringSliceFromAny(payload["include_patterns"]),
+		ExcludePatterns:  stringSliceFromAny(payload["exclude_patterns"]),
+		ModelOverrides:   map[string]ModelOverride{},
+	}
+}
+
+// validateTargetConnectivity lists the target's models. A failure is reported in the
+// result rather than as an error so callers can return it to the user verbatim.
+func (h *Handlers) validateTargetConnectivity(ctx context.Context, target *Target) targetValidation {
+	out := targetValidation{Models: []string{}, Selected: []string{}}
+	client, err := targetHTTPClient(target)
+	if err != nil {
+		out.Detail = err.Error()
+		return out
+	}
+	start := time.Now()
+	models, err := h.monitor.getModels(ctx, target, client)
+	out.DurationMs = time.Since(start).Milliseconds()
+	if err != nil {
+		out.Detail = err.Error()
+		return out
+	}
+	out.OK = true
+	out.Models = models
+	out.Selected = filterModelsBySelection(models, target.SelectedModels, target.IncludePatterns, target.ExcludePatterns)
+	if len(out.Selected) == 0 {
+		out.OK = false
+		out.Detail = "no model left after selected_models / include / exclude filters"
+	}
+	return out
+}
+
+// queryFlag reports whether a boolean query parameter is set (?x, ?x=1, ?x=true).
+func queryFlag(r *http.Request, name string) bool {
+	values, ok := r.URL.Query()[name]
+	if !ok {
+		return false
+	}
+	v := strings.ToLower(strings.TrimSpace(values[0]))
+	return v == "" || v == "1" || v == "true" || v == "yes"
+}
+
+// ValidateTarget -- POST /api/targets/validate
+// Takes the same body as POST /api/targets (name optional) and reports whether
+// GET /v1/models succeeds with it, without saving anything. 200 with the discovered
+// models on success, 422 with the upstream error otherwise.
+func (h *Handlers) ValidateTarget(w http.ResponseWriter, r *http.Request) {
+	var payload map[string]any
+	if err := readJSON(r, &payload); err != nil {
+		writeRequestBodyError(w, err)
+		return
+	}
+	if err := h.applyTemplate(payload); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": err.Error()})
+		return
+	}
+	baseURL, _ := payload["base_url"].(string)
+	apiKey, _ := payload["api_key"].(string)
+	if len(baseURL) < 3 || apiKey == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "base_url, api_key are required"})
+		return
+	}
+	if err := validateTargetPayload(payload); err != nil {
+		writeValidationError(w, err)
+		return
+	}
+	result := h.validateTargetConnectivity(r.Context(), previewTarget(payload))
+	status := http.StatusOK
+	if !result.OK {
+		status = http.StatusUnprocessableEntity
+	}
+	writeJSON(w, status, result)
+}
@@ -0,0 +1,140 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestCreateTargetValidate(t *testing.T) {
+	db, err := NewDatabase(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDatabase failed: %v", err)
+	}
+	t.Cleanup(func() { _ = db.conn.Close() })
+	if err := db.EnsureAuditSchema(); err != nil {
+		t.Fatalf("EnsureAuditSchema failed: %v", err)
+	}
+	if err := db.EnsureTemplateSchema(); err != nil {
+		t.Fatalf("EnsureTemplateSchema failed: %v", err)
+	}
+	h := &Handlers{db: db, monitor: NewMonitorService(MonitorConfig{DB: db, LogDir: t.TempDir()})}
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/models" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer sk-good" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error":{"message":"invalid api key"}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"data":[{"id":"gpt-4o"},{"id":"gpt-4o-mini"},{"id":"claude-3-5-sonnet"}]}`))
+	}))
+	defer upstream.Close()
+
+	post := func(handler http.HandlerFunc, path, body string) (int, map[string]any) {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		handler(rr, withAuthRole(req, authRoleAdmin))
+		var out map[string]any
+		_ = json.Unmarshal(rr.Body.Bytes(), &out)
+		return rr.Code, out
+	}
+
+	code, out := post(h.ValidateTarget, "/api/targets/validate",
+		`{"base_url":"`+upstream.URL+`","api_key":"sk-good","include_patterns":["gpt-*"]}`)
+	if code != http.StatusOK || out["ok"] != true {
+		t.Fatalf("validate should succeed, got=%d body=%v", code, out)
+	}
+	if models, _ := out["models"].([]any); len(models) != 3 {
+		t.Fatalf("validate should return every discovered model, got=%v", out["models"])
+	}
+	if selected, _ := out["selected_models"].([]any); len(selected) != 2 {
+		t.Fatalf("validate should apply include patterns, got=%v", out["selected_models"])
+	}
+
+	code, out = post(h.CreateTarget, "/api/targets?validate=1",
+		`{"name":"typo","base_url":"`+upstream.URL+`","api_key":"sk-bad"}`)
+	if code != http.StatusUnprocessableEntity || !strings.Contains(out["detail"].(string), "HTTP 401") {
+		t.Fatalf("create with a bad key should fail the pre-check, got=%d body=%v", code, out)
+	}
+	code, _ = post(h.CreateTarget, "/api/targets?dry_run=1",
+		`{"name":"dry","base_url":"`+upstream.URL+`","api_key":"sk-good"}`)
+	if targets, _ := db.ListTargets(); code != http.StatusOK || len(targets) != 0 {
+		t.Fatalf("failed pre-check and dry run should not save, got=%d targets=%d", code, len(targets))
+	}
+
+	code, out = post(h.CreateTarget, "/api/targets?validate=true",
+		`{"name":"relay","base_url":"`+upstream.URL+`","api_key":"sk-good"}`)
+	if code != http.StatusOK || out["item"] == nil || out["validation"] == nil {
+		t.Fatalf("create with a good key should save and return the validation, got=%d body=%v", code, out)
+	}
+	if targets, _ := db.ListTargets(); len(targets) != 1 {
+		t.Fatalf("validated create should save the target, got=%d", len(targets))
+	}
+}
+
+func TestTestTargetProbesOneModel(t *testing.T) {
+	db, err := NewDatabase(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDatabase failed: %v", err)
+	}
+	t.Cleanup(func() { _ = db.conn.Close() })
+	if err := db.EnsureAuditSchema(); err != nil {
+		t.Fatalf("EnsureAuditSchema failed: %v", err)
+	}
+	h := &Handlers{db: db, monitor: NewMonitorService(MonitorConfig{DB: db, LogDir: t.TempDir()})}
+
+	var gotBody map[string]any
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			http.NotFound(w, r)
+			return
+		}
+		_ = json.NewDecoder(r.Body).Decode(&gotBody)
+		_, _ = w.Write([]byte(`{"choices":[{"message":{"content":"pong"}}]}`))
+	}))
+	defer upstream.Close()
+
+	target, err := db.CreateTarget(map[string]any{"name": "relay", "base_url": upstream.URL, "api_key": "sk-test"})
+	if err != nil {
+		t.Fatalf("CreateTarget failed: %v", err)
+	}
+
+	test := func(body string) (int, map[string]any) {
+		req := httptest.NewRequest(http.MethodPost, "/api/targets/1/test", strings.NewReader(body))
+		req.SetPathValue("id", strconv.Itoa(target.ID))
+		rr := httptest.NewRecorder()
+		h.TestTarget(rr, withAuthRole(req, authRoleAdmin))
+		var out map[string]any
+		_ = json.Unmarshal(rr.Body.Bytes(), &out)
+		return rr.Code, out
+	}
+
+	code, out := test(`{"model":"gpt-4o","prompt":"ping"}`)
+	item, _ := out["item"].(map[string]any)
+	if code != http.StatusOK || item["success"] != true || item["content"] != "pong" || item["model"] != "gpt-4o" {
+		t.Fatalf("test should return the detection result, got=%d body=%v", code, out)
+	}
+	messages, _ := gotBody["messages"].([]any)
+	if len(messages) == 0 || !strings.Contains(fmt.Sprint(messages), "ping") {
+		t.Fatalf("test should send the prompt override, got=%v", gotBody)
+	}
+	if runs, _ := db.ListRuns(target.ID, 10); len(runs) != 0 {
+		t.Fatalf("test should not create a run, got=%d", len(runs))
+	}
+
+	if code, _ := test(`{"prompt":"ping"}`); code != http.StatusBadRequest {
+		t.Fatalf("missing model should be rejected, got=%d", code)
+	}
+	if code, _ := test(`{"model":"gpt-4o","route":"anthropic"}`); code != http.StatusBadRequest {
+		t.Fatalf("route outside the model's routes should be rejected, got=%d", code)
+	}
+}
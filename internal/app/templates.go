@@ -0,0 +1,408 @@
+package app
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// TargetTemplate holds reusable target defaults. POST /api/targets with
+// "template": "<name or id>" fills every field the payload leaves out from Fields
+// and links the new target through template_id.
+type TargetTemplate struct {
+	ID          int            `json:"id"`
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	Fields      map[string]any `json:"fields"`
+	Targets     int            `json:"targets"`
+	CreatedAt   float64        `json:"created_at"`
+	UpdatedAt   float64        `json:"updated_at"`
+}
+
+// templateFields are the target settings a template may carry; identity and
+// credentials (name, base_url, api_key) always come from the target itself.
+var templateFields = map[string]bool{
+	"interval_min": true, "timeout_s": true, "verify_ssl": true, "prompt": true,
+	"anthropic_version": true, "max_models": true, "extra_headers": true, "proxy_url": true,
+	"tls_fingerprint": true, "model_overrides": true, "include_patterns": true,
+	"exclude_patterns": true, "probe_endpoints": true, "stream_probe": true, "tool_probe": true, "capability_probes": true, "fast_retry_min": true,
+	"detect_concurrency": true, "max_run_duration_s": true, "debug_capture": true,
+	"log_max_age_days": true, "balance_probe": true, "balance_alert_below": true, "tags": true,
+	"severity": true, "proxy_transform": true,
+}
+
+type templateRequest struct {
+	Name        *string        `json:"name"`
+	Description *string        `json:"description"`
+	Fields      map[string]any `json:"fields"`
+	// Propagate copies changed fields to linked targets on PATCH.
+	Propagate bool `json:"propagate"`
+}
+
+// EnsureTemplateSchema creates the target_templates table.
+func (d *Database) EnsureTemplateSchema() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	_, err := d.conn.Exec(`
+		CREATE TABLE IF NOT EXISTS target_templates (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL UNIQUE,
+			description TEXT NOT NULL DEFAULT '',
+			fields TEXT NOT NULL DEFAULT '{}',
+			created_at REAL NOT NULL,
+			updated_at REAL NOT NULL
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_targets_template
+		ON targets(template_id);
+	`)
+	if err != nil {
+		return fmt.Errorf("init template schema: %w", err)
+	}
+	return nil
+}
+
+const templateColumns = `id, name, description, fields, created_at, updated_at,
+	(SELECT COUNT(*) FROM targets WHERE targets.template_id = target_templates.id AND targets.deleted_at IS NULL)`
+
+func scanTemplate(r interface{ Scan(dest ...any) error }) (*TargetTemplate, error) {
+	var t TargetTemplate
+	var fieldsRaw string
+	if err := r.Scan(&t.ID, &t.Name, &t.Description, &fieldsRaw, &t.CreatedAt, &t.UpdatedAt, &t.Targets); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(fieldsRaw), &t.Fields); err != nil || t.Fields == nil {
+		t.Fields = map[string]any{}
+	}
+	return &t, nil
+}
+
+// ListTemplates returns all templates by name.
+func (d *Database) ListTemplates() ([]TargetTemplate, error) {
+	rows, err := d.read.Query("SELECT " + templateColumns + " FROM target_templates ORDER BY name ASC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]TargetTemplate, 0)
+	for rows.Next() {
+		t, err := scanTemplate(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *t)
+	}
+	return out, rows.Err()
+}
+
+// GetTemplate returns a template by id, or nil when it does not exist.
+func (d *Database) GetTemplate(id int) (*TargetTemplate, error) {
+	t, err := scanTemplate(d.read.QueryRow("SELECT "+templateColumns+" FROM target_templates WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return t, err
+}
+
+// FindTemplate resolves a template reference: an id, or a name.
+func (d *Database) FindTemplate(ref any) (*TargetTemplate, error) {
+	if id, ok := anyInt(ref); ok {
+		return d.GetTemplate(id)
+	}
+	name, _ := ref.(string)
+	t, err := scanTemplate(d.read.QueryRow("SELECT "+templateColumns+" FROM target_templates WHERE name = ?", strings.TrimSpace(name)))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return t, err
+}
+
+// CreateTemplate inserts a template.
+func (d *Database) CreateTemplate(name, description string, fields map[string]any) (*TargetTemplate, error) {
+	raw, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	now := float64(time.Now().UnixMilli()) / 1000.0
+	d.mu.Lock()
+	res, err := d.conn.Exec(
+		"INSERT INTO target_templates (name, description, fields, created_at, updated_at) VALUES (?, ?, ?, ?, ?)",
+		name, description, string(raw), now, now,
+	)
+	d.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	id, _ := res.LastInsertId()
+	return d.GetTemplate(int(id))
+}
+
+// UpdateTemplate saves the editable fields of t.
+func (d *Database) UpdateTemplate(t *TargetTemplate) (*TargetTemplate, error) {
+	raw, err := json.Marshal(t.Fields)
+	if err != nil {
+		return nil, err
+	}
+	d.mu.Lock()
+	_, err = d.conn.Exec(
+		"UPDATE target_templates SET name = ?, description = ?, fields = ?, updated_at = ? WHERE id = ?",
+		t.Name, t.Description, string(raw), float64(time.Now().UnixMilli())/1000.0, t.ID,
+	)
+	d.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return d.GetTemplate(t.ID)
+}
+
+// DeleteTemplate removes a template and unlinks its targets, which keep their settings.
+func (d *Database) DeleteTemplate(id int) (bool, error) {
+	defer d.touchTargets()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	tx, err := d.conn.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("UPDATE targets SET template_id = NULL WHERE template_id = ?", id); err != nil {
+		return false, err
+	}
+	res, err := tx.Exec("DELETE FROM target_templates WHERE id = ?", id)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, tx.Commit()
+}
+
+// ListTemplateTargetIDs returns the targets linked to a template.
+func (d *Database) ListTemplateTargetIDs(templateID int) ([]int, error) {
+	rows, err := d.read.Query("SELECT id FROM targets WHERE template_id = ? AND deleted_at IS NULL ORDER BY id", templateID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func validateTemplate(t *TargetTemplate) error {
+	t.Name = strings.TrimSpace(t.Name)
+	t.Description = strings.TrimSpace(t.Description)
+	if t.Name == "" || len(t.Name) > 128 {
+		return fmt.Errorf("name must be 1-128 chars")
+	}
+	if len(t.Description) > 512 {
+		return fmt.Errorf("description must be <= 512 chars")
+	}
+	for key := range t.Fields {
+		if !templateFields[key] {
+			return fmt.Errorf("fields.%s cannot be set by a template", key)
+		}
+	}
+	if err := validateTargetPayload(t.Fields); err != nil {
+		return fmt.Errorf("fields: %w", err)
+	}
+	return nil
+}
+
+// applyTemplate resolves payload["template"] and fills the fields the payload does
+// not set. It returns a client-facing error for unknown templates.
+func (h *Handlers) applyTemplate(payload map[string]any) error {
+	ref, ok := payload["template"]
+	if !ok {
+		return nil
+	}
+	delete(payload, "template")
+	if ref == nil {
+		return nil
+	}
+	tpl, err := h.db.FindTemplate(ref)
+	if err != nil {
+		return err
+	}
+	if tpl == nil {
+		return fmt.Errorf("template %v not found", ref)
+	}
+	for key, value := range tpl.Fields {
+		if _, set := payload[key]; !set {
+			payload[key] = value
+		}
+	}
+	payload["template_id"] = tpl.ID
+	return nil
+}
+
+// changedTemplateFields lists the fields after sets to a new value. Fields dropped from
+// a template are not propagated; linked targets keep whatever value they have.
+func changedTemplateFields(before, after map[string]any) []string {
+	var keys []string
+	for key, value := range after {
+		if old, ok := before[key]; !ok || !reflect.DeepEqual(old, value) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// AdminListTemplates handles GET /api/admin/templates
+func (h *Handlers) AdminListTemplates(w http.ResponseWriter, r *http.Request) {
+	items, err := h.db.ListTemplates()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"items": items})
+}
+
+// AdminCreateTemplate handles POST /api/admin/templates
+// Body: {"name":"openrouter","description":"...","fields":{"timeout_s":60,"extra_headers":{...}}}
+func (h *Handlers) AdminCreateTemplate(w http.ResponseWriter, r *http.Request) {
+	var req templateRequest
+	if err := readJSON(r, &req); err != nil {
+		writeRequestBodyError(w, err)
+		return
+	}
+	tpl := TargetTemplate{Fields: map[string]any{}}
+	if req.Name != nil {
+		tpl.Name = *req.Name
+	}
+	if req.Description != nil {
+		tpl.Description = *req.Description
+	}
+	if req.Fields != nil {
+		tpl.Fields = req.Fields
+	}
+	if err := validateTemplate(&tpl); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": err.Error()})
+		return
+	}
+	if existing, err := h.db.FindTemplate(tpl.Name); err == nil && existing != nil {
+		writeJSON(w, http.StatusConflict, map[string]any{"detail": "template name already exists"})
+		return
+	}
+	item, err := h.db.CreateTemplate(tpl.Name, tpl.Description, tpl.Fields)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return
+	}
+	h.audit(r, "template.create", "template", item.ID, auditDiff(nil, item, map[string]bool{"targets": true}, nil))
+	writeJSON(w, http.StatusOK, map[string]any{"ok": true, "item": item})
+}
+
+// AdminPatchTemplate handles PATCH /api/admin/templates/{id}
+// Body: {"name":..., "description":..., "fields":{...}, "propagate":true}. fields
+// replaces the whole set; with propagate, fields that changed are written to every
+// linked target, overwriting their own values for those fields only.
+func (h *Handlers) AdminPatchTemplate(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(r)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "invalid id"})
+		return
+	}
+	tpl, err := h.db.GetTemplate(id)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return
+	}
+	if tpl == nil {
+		writeJSON(w, http.StatusNotFound, map[string]any{"detail": "template not found"})
+		return
+	}
+	var req templateRequest
+	if err := readJSON(r, &req); err != nil {
+		writeRequestBodyError(w, err)
+		return
+	}
+	before := *tpl
+	if req.Name != nil {
+		tpl.Name = *req.Name
+	}
+	if req.Description != nil {
+		tpl.Description = *req.Description
+	}
+	if req.Fields != nil {
+		tpl.Fields = req.Fields
+	}
+	if err := validateTemplate(tpl); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": err.Error()})
+		return
+	}
+	if tpl.Name != before.Name {
+		if existing, err := h.db.FindTemplate(tpl.Name); err == nil && existing != nil {
+			writeJSON(w, http.StatusConflict, map[string]any{"detail": "template name already exists"})
+			return
+		}
+	}
+	item, err := h.db.UpdateTemplate(tpl)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return
+	}
+
+	propagated := []int{}
+	changed := changedTemplateFields(before.Fields, item.Fields)
+	if req.Propagate && len(changed) > 0 {
+		ids, err := h.db.ListTemplateTargetIDs(id)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+			return
+		}
+		updates := make(map[string]any, len(changed))
+		for _, key := range changed {
+			updates[key] = item.Fields[key]
+		}
+		for _, targetID := range ids {
+			if _, err := h.db.UpdateTarget(targetID, updates); err != nil {
+				writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": fmt.Sprintf("propagate to target %d: %v", targetID, err)})
+				return
+			}
+			propagated = append(propagated, targetID)
+		}
+		if len(propagated) > 0 {
+			h.monitor.emitJSON("target_updated", map[string]any{"ids": propagated, "action": "template"})
+		}
+	}
+	diff := auditDiff(&before, item, map[string]bool{"updated_at": true, "targets": true}, nil)
+	if len(propagated) > 0 {
+		diff["propagated"] = map[string]any{"from": nil, "to": propagated}
+	}
+	h.audit(r, "template.update", "template", id, diff)
+	writeJSON(w, http.StatusOK, map[string]any{"ok": true, "item": item, "propagated": propagated})
+}
+
+// AdminDeleteTemplate handles DELETE /api/admin/templates/{id}
+func (h *Handlers) AdminDeleteTemplate(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(r)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "invalid id"})
+		return
+	}
+	deleted, err := h.db.DeleteTemplate(id)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return
+	}
+	if !deleted {
+		writeJSON(w, http.StatusNotFound, map[string]any{"detail": "template not found"})
+		return
+	}
+	h.audit(r, "template.delete", "template", id, nil)
+	writeJSON(w, http.StatusOK, map[string]any{"ok": true})
+}
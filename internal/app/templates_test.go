@@ -0,0 +1,81 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestTargetTemplatesCreateAndPropagate(t *testing.T) {
+	db, err := NewDatabase(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDatabase failed: %v", err)
+	}
+	t.Cleanup(func() { _ = db.conn.Close() })
+	for _, fn := range []func() error{db.EnsureAuditSchema, db.EnsureTemplateSchema} {
+		if err := fn(); err != nil {
+			t.Fatalf("schema init failed: %v", err)
+		}
+	}
+	h := &Handlers{db: db, monitor: NewMonitorService(MonitorConfig{DB: db, LogDir: t.TempDir()})}
+
+	call := func(handler http.HandlerFunc, method, path, id, body string) (int, map[string]any) {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if id != "" {
+			req.SetPathValue("id", id)
+		}
+		rr := httptest.NewRecorder()
+		handler(rr, withAuthRole(req, authRoleAdmin))
+		var out map[string]any
+		_ = json.Unmarshal(rr.Body.Bytes(), &out)
+		return rr.Code, out
+	}
+
+	code, _ := call(h.AdminCreateTemplate, http.MethodPost, "/api/admin/templates", "",
+		`{"name":"openrouter","fields":{"api_key":"sk-x"}}`)
+	if code != http.StatusBadRequest {
+		t.Fatalf("templates should not carry api_key, got=%d", code)
+	}
+	code, created := call(h.AdminCreateTemplate, http.MethodPost, "/api/admin/templates", "",
+		`{"name":"openrouter","fields":{"timeout_s":90,"verify_ssl":true,"extra_headers":{"HTTP-Referer":"https://example.com"}}}`)
+	if code != http.StatusOK {
+		t.Fatalf("create template should succeed, got=%d body=%v", code, created)
+	}
+	tplID := strconv.Itoa(int(created["item"].(map[string]any)["id"].(float64)))
+
+	code, out := call(h.CreateTarget, http.MethodPost, "/api/targets", "",
+		`{"name":"or-1","base_url":"https://openrouter.ai/api","api_key":"sk-or-1","template":"openrouter","verify_ssl":false}`)
+	if code != http.StatusOK {
+		t.Fatalf("create target from template should succeed, got=%d body=%v", code, out)
+	}
+	item := out["item"].(map[string]any)
+	if item["timeout_s"] != 90.0 || item["verify_ssl"] != false || item["template_id"] != created["item"].(map[string]any)["id"] {
+		t.Fatalf("template defaults should fill unset fields only, got=%v", item)
+	}
+	if code, _ := call(h.CreateTarget, http.MethodPost, "/api/targets", "",
+		`{"name":"or-2","base_url":"https://openrouter.ai/api","api_key":"sk-or-2","template":"missing"}`); code != http.StatusBadRequest {
+		t.Fatalf("unknown template should be rejected, got=%d", code)
+	}
+
+	code, patched := call(h.AdminPatchTemplate, http.MethodPatch, "/api/admin/templates/"+tplID, tplID,
+		`{"fields":{"timeout_s":120,"verify_ssl":true,"extra_headers":{"HTTP-Referer":"https://example.com"}},"propagate":true}`)
+	if code != http.StatusOK {
+		t.Fatalf("patch template should succeed, got=%d body=%v", code, patched)
+	}
+	target, _ := db.GetTarget(int(item["id"].(float64)))
+	if target.TimeoutS != 120 || target.VerifySSL {
+		t.Fatalf("propagation should only copy changed fields, got timeout=%v verify_ssl=%v", target.TimeoutS, target.VerifySSL)
+	}
+
+	if code, _ := call(h.AdminDeleteTemplate, http.MethodDelete, "/api/admin/templates/"+tplID, tplID, ""); code != http.StatusOK {
+		t.Fatalf("delete template should succeed, got=%d", code)
+	}
+	target, _ = db.GetTarget(target.ID)
+	if target.TemplateID != nil || target.TimeoutS != 120 {
+		t.Fatalf("deleting a template should unlink targets and keep settings, got=%+v", target)
+	}
+}
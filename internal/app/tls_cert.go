@@ -0,0 +1,104 @@
+package app
+
+import (
+	"crypto/x509"
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// tlsCertInfo summarizes one certificate of an upstream chain.
+type tlsCertInfo struct {
+	Subject  string  `json:"subject"`
+	Issuer   string  `json:"issuer"`
+	NotAfter float64 `json:"not_after"`
+}
+
+// tlsCertObserver collects peer certificates seen by an outbound client during one run.
+// It keeps the chain whose earliest expiry is soonest, i.e. the worst case.
+type tlsCertObserver struct {
+	mu       sync.Mutex
+	chain    []tlsCertInfo
+	notAfter time.Time
+}
+
+func (o *tlsCertObserver) observe(certs []*x509.Certificate) {
+	if o == nil || len(certs) == 0 {
+		return
+	}
+	chain := make([]tlsCertInfo, 0, len(certs))
+	var earliest time.Time
+	for _, c := range certs {
+		chain = append(chain, tlsCertInfo{
+			Subject:  c.Subject.String(),
+			Issuer:   c.Issuer.String(),
+			NotAfter: float64(c.NotAfter.UnixMilli()) / 1000.0,
+		})
+		if earliest.IsZero() || c.NotAfter.Before(earliest) {
+			earliest = c.NotAfter
+		}
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.notAfter.IsZero() || earliest.Before(o.notAfter) {
+		o.notAfter = earliest
+		o.chain = chain
+	}
+}
+
+// Result returns the observed chain and its earliest expiry; ok is false when no TLS handshake happened.
+func (o *tlsCertObserver) Result() (chain []tlsCertInfo, notAfter time.Time, ok bool) {
+	if o == nil {
+		return nil, time.Time{}, false
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.notAfter.IsZero() {
+		return nil, time.Time{}, false
+	}
+	return append([]tlsCertInfo(nil), o.chain...), o.notAfter, true
+}
+
+// certCapturingTransport records resp.TLS certificates for transports based on crypto/tls.
+type certCapturingTransport struct {
+	base     http.RoundTripper
+	observer *tlsCertObserver
+}
+
+func (t *certCapturingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err == nil && resp.TLS != nil {
+		t.observer.observe(resp.TLS.PeerCertificates)
+	}
+	return resp, err
+}
+
+// certExpiryWarning returns a message when notAfter falls within warnDays of now.
+// warnDays <= 0 disables the check.
+func certExpiryWarning(notAfter, now time.Time, warnDays int) string {
+	if warnDays <= 0 || notAfter.IsZero() {
+		return ""
+	}
+	remaining := notAfter.Sub(now)
+	if remaining <= 0 {
+		return fmt.Sprintf("TLS certificate expired at %s", notAfter.UTC().Format(time.RFC3339))
+	}
+	if remaining > time.Duration(warnDays)*24*time.Hour {
+		return ""
+	}
+	days := int(math.Floor(remaining.Hours() / 24))
+	return fmt.Sprintf("TLS certificate expires in %d day(s) at %s", days, notAfter.UTC().Format(time.RFC3339))
+}
+
+// observeClientCerts hooks observer into client's transport so every TLS handshake is recorded.
+func observeClientCerts(client *http.Client, observer *tlsCertObserver) {
+	switch tr := client.Transport.(type) {
+	case *utlsTransport:
+		tr.certObserver = observer
+	case nil:
+	default:
+		client.Transport = &certCapturingTransport{base: tr, observer: observer}
+	}
+}
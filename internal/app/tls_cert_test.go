@@ -0,0 +1,53 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCertExpiryWarning(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	if msg := certExpiryWarning(now.Add(30*24*time.Hour), now, 14); msg != "" {
+		t.Fatalf("cert expiring in 30 days should not warn with 14-day threshold, got=%q", msg)
+	}
+	if msg := certExpiryWarning(now.Add(5*24*time.Hour), now, 14); !strings.Contains(msg, "expires in 5 day(s)") {
+		t.Fatalf("cert expiring in 5 days should warn, got=%q", msg)
+	}
+	if msg := certExpiryWarning(now.Add(-time.Hour), now, 14); !strings.Contains(msg, "expired") {
+		t.Fatalf("expired cert should warn, got=%q", msg)
+	}
+	if msg := certExpiryWarning(now.Add(-time.Hour), now, 0); msg != "" {
+		t.Fatalf("threshold 0 should disable the check, got=%q", msg)
+	}
+}
+
+func TestObserveClientCerts_CapturesPeerChain(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+	want := srv.Certificate().NotAfter
+
+	for _, fp := range []string{tlsFingerprintNone, tlsFingerprintGolang} {
+		client := httpClient(5, false, nil, fp)
+		observer := &tlsCertObserver{}
+		observeClientCerts(client, observer)
+
+		resp, err := client.Get(srv.URL)
+		if err != nil {
+			t.Fatalf("fingerprint %s: request failed: %v", fp, err)
+		}
+		resp.Body.Close()
+
+		chain, notAfter, ok := observer.Result()
+		if !ok || len(chain) == 0 {
+			t.Fatalf("fingerprint %s: observer should capture the peer chain", fp)
+		}
+		if !notAfter.Equal(want) {
+			t.Fatalf("fingerprint %s: not_after should be %v, got=%v", fp, want, notAfter)
+		}
+	}
+}
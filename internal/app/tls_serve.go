@@ -0,0 +1,116 @@
+package app
+
+import (
+	"crypto/tls"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// tlsServeConfig selects how the HTTP server is exposed: plain HTTP (the default),
+// a static certificate pair, or certificates obtained from Let's Encrypt.
+type tlsServeConfig struct {
+	CertFile string
+	KeyFile  string
+	// AutocertDomains enables autocert; only these host names get certificates.
+	AutocertDomains  []string
+	AutocertEmail    string
+	AutocertCacheDir string
+	// AutocertHTTPAddr serves HTTP-01 challenges and redirects other HTTP traffic to HTTPS.
+	AutocertHTTPAddr string
+}
+
+func tlsServeConfigFromEnv(dataDir string) tlsServeConfig {
+	cfg := tlsServeConfig{
+		CertFile:         strings.TrimSpace(os.Getenv("TLS_CERT")),
+		KeyFile:          strings.TrimSpace(os.Getenv("TLS_KEY")),
+		AutocertDomains:  splitCommaList(os.Getenv("AUTOCERT_DOMAINS")),
+		AutocertEmail:    strings.TrimSpace(os.Getenv("AUTOCERT_EMAIL")),
+		AutocertCacheDir: strings.TrimSpace(os.Getenv("AUTOCERT_CACHE_DIR")),
+		AutocertHTTPAddr: strings.TrimSpace(os.Getenv("AUTOCERT_HTTP_ADDR")),
+	}
+	if cfg.AutocertCacheDir == "" {
+		cfg.AutocertCacheDir = filepath.Join(dataDir, "autocert")
+	}
+	if cfg.AutocertHTTPAddr == "" {
+		cfg.AutocertHTTPAddr = ":80"
+	}
+	return cfg
+}
+
+func (c tlsServeConfig) autocert() bool {
+	return len(c.AutocertDomains) > 0
+}
+
+// Enabled reports whether the server speaks HTTPS.
+func (c tlsServeConfig) Enabled() bool {
+	return c.autocert() || c.CertFile != "" || c.KeyFile != ""
+}
+
+// validateTLSServeConfig reports TLS settings that would make the listener fail.
+func validateTLSServeConfig(r *diagnosticsReport, c tlsServeConfig) {
+	if c.autocert() && (c.CertFile != "" || c.KeyFile != "") {
+		r.add(diagnosticFatal, "tls_conflict", "AUTOCERT_DOMAINS cannot be combined with TLS_CERT/TLS_KEY")
+		return
+	}
+	if c.autocert() {
+		if err := checkDirWritable(c.AutocertCacheDir); err != nil {
+			r.add(diagnosticFatal, "tls_autocert_cache", "AUTOCERT_CACHE_DIR %q is not usable: %v", c.AutocertCacheDir, err)
+		}
+		return
+	}
+	if c.CertFile == "" && c.KeyFile == "" {
+		return
+	}
+	if c.CertFile == "" || c.KeyFile == "" {
+		r.add(diagnosticFatal, "tls_incomplete", "TLS_CERT and TLS_KEY must be set together")
+		return
+	}
+	if _, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile); err != nil {
+		r.add(diagnosticFatal, "tls_keypair_invalid", "TLS_CERT/TLS_KEY cannot be loaded: %v", err)
+	}
+}
+
+// secureCookies marks session cookies Secure once the server itself terminates TLS.
+var secureCookies atomic.Bool
+
+func setSecureCookies(enabled bool) {
+	secureCookies.Store(enabled)
+}
+
+// listenAndServe starts srv according to cfg. In autocert mode it also runs the
+// HTTP-01 challenge listener, which stops when srv is shut down.
+func listenAndServe(srv *http.Server, cfg tlsServeConfig) error {
+	if !cfg.Enabled() {
+		return srv.ListenAndServe()
+	}
+	if !cfg.autocert() {
+		return srv.ListenAndServeTLS(cfg.CertFile, cfg.KeyFile)
+	}
+
+	m := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(cfg.AutocertDomains...),
+		Cache:      autocert.DirCache(cfg.AutocertCacheDir),
+		Email:      cfg.AutocertEmail,
+	}
+	srv.TLSConfig = m.TLSConfig()
+	challenge := &http.Server{
+		Addr:              cfg.AutocertHTTPAddr,
+		Handler:           m.HTTPHandler(nil),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	srv.RegisterOnShutdown(func() { _ = challenge.Close() })
+	go func() {
+		componentLogger(nil, "main").Info("autocert challenge listener started", "addr", cfg.AutocertHTTPAddr, "domains", strings.Join(cfg.AutocertDomains, ","))
+		if err := challenge.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			componentLogger(nil, "main").Error("autocert challenge listener failed", "error", err)
+		}
+	}()
+	return srv.ListenAndServeTLS("", "")
+}
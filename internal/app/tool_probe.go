@@ -0,0 +1,85 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Tool-use probe. Several Claude-compatible gateways silently drop the tools block and
+// answer with plain text, which breaks agents while ordinary checks still pass. With
+// tool_probe on, the anthropic route sends a tools block with tool_choice forcing
+// probeToolName, and the check only succeeds when the reply holds a tool_use content
+// item for it with an object input. The probe is sent without streaming; the tool_use
+// items are stored in tool_calls.
+
+const (
+	probeToolName      = "get_weather"
+	toolProbeMaxTokens = 200
+	toolProbePrompt    = "What is the weather in Paris right now? Use the get_weather tool."
+)
+
+// anthropicToolProbeBody is the Messages request of the tool-use probe.
+func anthropicToolProbeBody(modelID string, maxTokens int) map[string]any {
+	return map[string]any{
+		"model":      modelID,
+		"max_tokens": maxTokens,
+		"messages":   []map[string]any{{"role": "user", "content": toolProbePrompt}},
+		"tools": []map[string]any{{
+			"name":        probeToolName,
+			"description": "Get the current weather for a city.",
+			"input_schema": map[string]any{
+				"type":       "object",
+				"properties": map[string]any{"city": map[string]any{"type": "string", "description": "City name"}},
+				"required":   []string{"city"},
+			},
+		}},
+		"tool_choice": map[string]any{"type": "tool", "name": probeToolName},
+	}
+}
+
+// anthropicToolUses returns the tool_use content items of a Messages response.
+func anthropicToolUses(body any) []map[string]any {
+	m, ok := body.(map[string]any)
+	if !ok {
+		return nil
+	}
+	content, _ := m["content"].([]any)
+	var out []map[string]any
+	for _, block := range content {
+		if b, ok := block.(map[string]any); ok && b["type"] == "tool_use" {
+			out = append(out, b)
+		}
+	}
+	return out
+}
+
+// checkToolUses validates the tool_use items of the probe, returning a summary of the
+// call on success.
+func checkToolUses(items []map[string]any) (string, error) {
+	if len(items) == 0 {
+		return "", fmt.Errorf("tool_use missing: the reply has no tool_use content item (tools dropped by the gateway?)")
+	}
+	for _, item := range items {
+		name, _ := item["name"].(string)
+		input, ok := item["input"].(map[string]any)
+		if name != probeToolName || !ok {
+			continue
+		}
+		raw, _ := json.Marshal(input)
+		return truncStr(name+" "+string(raw), 500), nil
+	}
+	return "", fmt.Errorf("tool_use invalid: expected a %s call with an object input", probeToolName)
+}
+
+// encodeToolCalls renders tool_use items as the tool_calls column ([{id,name,input}]).
+func encodeToolCalls(items []map[string]any) string {
+	calls := make([]map[string]any, 0, len(items))
+	for _, item := range items {
+		calls = append(calls, map[string]any{"id": item["id"], "name": item["name"], "input": item["input"]})
+	}
+	raw, err := json.Marshal(calls)
+	if err != nil {
+		return "[]"
+	}
+	return string(raw)
+}
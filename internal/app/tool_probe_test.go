@@ -0,0 +1,48 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestToolProbeRequiresToolUse(t *testing.T) {
+	dropTools := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if _, ok := body["tools"]; !ok || body["stream"] == true {
+			t.Errorf("tool probe should send tools without streaming, got %v", body)
+		}
+		if dropTools {
+			_, _ = w.Write([]byte(`{"type":"message","content":[{"type":"text","text":"It is sunny in Paris."}]}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"type":"message","content":[{"type":"tool_use","id":"toolu_1","name":"get_weather","input":{"city":"Paris"}}]}`))
+	}))
+	defer srv.Close()
+
+	db, err := NewDatabase(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDatabase failed: %v", err)
+	}
+	t.Cleanup(func() { _ = db.conn.Close() })
+	ms := NewMonitorService(MonitorConfig{DB: db, LogDir: t.TempDir()})
+	target := &Target{ID: 1, BaseURL: srv.URL, APIKey: "k", AnthropicVersion: "2023-06-01", ToolProbe: true, StreamProbe: true}
+	client := httpClient(5, false, nil, tlsFingerprintNone)
+
+	row := ms.detectOne(context.Background(), target, "claude-x", "anthropic", client)
+	if !row.Success || row.ToolCallsCount != 1 || !strings.Contains(row.ToolCalls, `"name":"get_weather"`) || row.Content != `get_weather {"city":"Paris"}` {
+		t.Fatalf("tool_use reply should pass, got=%+v", row)
+	}
+
+	dropTools = true
+	row = ms.detectOne(context.Background(), target, "claude-x", "anthropic", client)
+	if row.Success || row.Error == nil || !strings.Contains(*row.Error, "tool_use missing") || row.ToolCalls != "[]" {
+		t.Fatalf("a reply without tool_use should fail, got=%+v", row)
+	}
+}
@@ -0,0 +1,400 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Tracing is a small OpenTelemetry-compatible span recorder. Spans are exported in
+// batches as OTLP/HTTP JSON to OTEL_EXPORTER_OTLP_TRACES_ENDPOINT, or to
+// OTEL_EXPORTER_OTLP_ENDPOINT + "/v1/traces". With neither set, tracing is off and
+// every span call is a no-op.
+
+const (
+	spanKindInternal = 1
+	spanKindServer   = 2
+	spanKindClient   = 3
+
+	spanStatusOK    = 1
+	spanStatusError = 2
+
+	traceBatchSize     = 256
+	traceQueueSize     = 4096
+	traceFlushInterval = 5 * time.Second
+)
+
+type spanContext struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+}
+
+func (sc spanContext) valid() bool {
+	return sc.TraceID != [16]byte{} && sc.SpanID != [8]byte{}
+}
+
+// traceparent formats sc as a W3C traceparent header value.
+func (sc spanContext) traceparent() string {
+	return "00-" + hex.EncodeToString(sc.TraceID[:]) + "-" + hex.EncodeToString(sc.SpanID[:]) + "-01"
+}
+
+// parseTraceparent parses a W3C traceparent header value.
+func parseTraceparent(value string) (spanContext, bool) {
+	var sc spanContext
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return sc, false
+	}
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return sc, false
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return sc, false
+	}
+	return sc, sc.valid()
+}
+
+type spanContextKey struct{}
+
+func spanContextFrom(ctx context.Context) (spanContext, bool) {
+	sc, ok := ctx.Value(spanContextKey{}).(spanContext)
+	return sc, ok && sc.valid()
+}
+
+// span is one recorded operation. A nil *span is valid and ignores every call.
+type span struct {
+	sc       spanContext
+	parent   [8]byte
+	name     string
+	kind     int
+	start    time.Time
+	end      time.Time
+	mu       sync.Mutex
+	attrs    map[string]any
+	status   int
+	errMsg   string
+	finished bool
+}
+
+// startSpan starts a span named name as a child of the span in ctx, if any.
+// attrs are key/value pairs. It returns ctx unchanged and a nil span when tracing is off.
+func startSpan(ctx context.Context, name string, attrs ...any) (context.Context, *span) {
+	return startSpanKind(ctx, name, spanKindInternal, attrs...)
+}
+
+func startSpanKind(ctx context.Context, name string, kind int, attrs ...any) (context.Context, *span) {
+	if traceExporterInstance.Load() == nil {
+		return ctx, nil
+	}
+	s := &span{name: name, kind: kind, start: time.Now(), attrs: map[string]any{}}
+	if parent, ok := spanContextFrom(ctx); ok {
+		s.sc.TraceID = parent.TraceID
+		s.parent = parent.SpanID
+	} else {
+		_, _ = rand.Read(s.sc.TraceID[:])
+	}
+	_, _ = rand.Read(s.sc.SpanID[:])
+	s.SetAttrs(attrs...)
+	return context.WithValue(ctx, spanContextKey{}, s.sc), s
+}
+
+// SetAttrs records key/value pairs on the span.
+func (s *span) SetAttrs(kv ...any) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := 0; i+1 < len(kv); i += 2 {
+		if key, ok := kv[i].(string); ok {
+			s.attrs[key] = kv[i+1]
+		}
+	}
+}
+
+// End finishes the span and queues it for export. A non-nil err marks it failed.
+func (s *span) End(err error) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.finished {
+		s.mu.Unlock()
+		return
+	}
+	s.finished = true
+	s.end = time.Now()
+	if err != nil {
+		s.status = spanStatusError
+		s.errMsg = err.Error()
+	} else if s.status == 0 {
+		s.status = spanStatusOK
+	}
+	s.mu.Unlock()
+	if exp := traceExporterInstance.Load(); exp != nil {
+		exp.enqueue(s)
+	}
+}
+
+// injectTraceContext sets the traceparent header for the span in ctx.
+func injectTraceContext(ctx context.Context, header http.Header) {
+	if sc, ok := spanContextFrom(ctx); ok {
+		header.Set("Traceparent", sc.traceparent())
+	}
+}
+
+// extractTraceContext returns ctx carrying the remote parent from r's traceparent header.
+func extractTraceContext(ctx context.Context, r *http.Request) context.Context {
+	if sc, ok := parseTraceparent(r.Header.Get("Traceparent")); ok {
+		return context.WithValue(ctx, spanContextKey{}, sc)
+	}
+	return ctx
+}
+
+// ---------------------------------------------------------------------------
+// OTLP/HTTP exporter
+// ---------------------------------------------------------------------------
+
+type traceExporter struct {
+	endpoint    string
+	headers     map[string]string
+	serviceName string
+	client      *http.Client
+	log         *slog.Logger
+
+	queue   chan *span
+	stop    chan struct{}
+	done    chan struct{}
+	dropped atomic.Int64
+}
+
+var traceExporterInstance atomic.Pointer[traceExporter]
+
+// tracesEndpointFromEnv returns the OTLP traces URL, or "" when tracing is not configured.
+func tracesEndpointFromEnv() string {
+	if v := strings.TrimSpace(os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT")); v != "" {
+		return v
+	}
+	if v := strings.TrimSpace(os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")); v != "" {
+		return strings.TrimRight(v, "/") + "/v1/traces"
+	}
+	return ""
+}
+
+// parseOTLPHeaders parses OTEL_EXPORTER_OTLP_HEADERS ("k1=v1,k2=v2").
+func parseOTLPHeaders(value string) (map[string]string, error) {
+	return parseKeyValueList("OTEL_EXPORTER_OTLP_HEADERS", value)
+}
+
+// parseKeyValueList parses the "k1=v1,k2=v2" value of the env variable name.
+func parseKeyValueList(name, value string) (map[string]string, error) {
+	out := map[string]string{}
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(item, "=")
+		if !ok || strings.TrimSpace(k) == "" {
+			return nil, fmt.Errorf("%s entry %q must be key=value", name, item)
+		}
+		out[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	return out, nil
+}
+
+// startTracing installs the exporter configured by the OTEL_* environment and returns
+// a shutdown function that flushes pending spans. It is a no-op when no endpoint is set.
+func startTracing(logger *slog.Logger) (func(context.Context), error) {
+	endpoint := tracesEndpointFromEnv()
+	if endpoint == "" {
+		return func(context.Context) {}, nil
+	}
+	headers, err := parseOTLPHeaders(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS"))
+	if err != nil {
+		return nil, err
+	}
+	serviceName := strings.TrimSpace(os.Getenv("OTEL_SERVICE_NAME"))
+	if serviceName == "" {
+		serviceName = "api_monitor"
+	}
+	exp := &traceExporter{
+		endpoint:    endpoint,
+		headers:     headers,
+		serviceName: serviceName,
+		client:      &http.Client{Timeout: 10 * time.Second},
+		log:         componentLogger(logger, "tracing"),
+		queue:       make(chan *span, traceQueueSize),
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	go exp.loop()
+	traceExporterInstance.Store(exp)
+	exp.log.Info("tracing enabled", "endpoint", endpoint, "service", serviceName)
+	return exp.shutdown, nil
+}
+
+func (e *traceExporter) enqueue(s *span) {
+	select {
+	case e.queue <- s:
+	default:
+		e.dropped.Add(1)
+	}
+}
+
+func (e *traceExporter) loop() {
+	defer close(e.done)
+	ticker := time.NewTicker(traceFlushInterval)
+	defer ticker.Stop()
+	var batch []*span
+	send := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := e.export(batch); err != nil {
+			e.log.Warn("export spans failed", "spans", len(batch), "error", err)
+		}
+		batch = nil
+	}
+	drain := func() {
+		for {
+			select {
+			case s := <-e.queue:
+				batch = append(batch, s)
+				if len(batch) >= traceBatchSize {
+					send()
+				}
+			default:
+				send()
+				return
+			}
+		}
+	}
+	for {
+		select {
+		case s := <-e.queue:
+			batch = append(batch, s)
+			if len(batch) >= traceBatchSize {
+				send()
+			}
+		case <-ticker.C:
+			send()
+		case <-e.stop:
+			drain()
+			return
+		}
+	}
+}
+
+func (e *traceExporter) shutdown(ctx context.Context) {
+	traceExporterInstance.CompareAndSwap(e, nil)
+	close(e.stop)
+	select {
+	case <-e.done:
+	case <-ctx.Done():
+	}
+	if n := e.dropped.Load(); n > 0 {
+		e.log.Warn("spans dropped because the export queue was full", "dropped", n)
+	}
+}
+
+func (e *traceExporter) export(batch []*span) error {
+	spans := make([]map[string]any, 0, len(batch))
+	for _, s := range batch {
+		spans = append(spans, s.otlp())
+	}
+	payload := map[string]any{
+		"resourceSpans": []any{map[string]any{
+			"resource": map[string]any{
+				"attributes": otlpAttributes(map[string]any{"service.name": e.serviceName}),
+			},
+			"scopeSpans": []any{map[string]any{
+				"scope": map[string]any{"name": "api_monitor"},
+				"spans": spans,
+			}},
+		}},
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("collector returned HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (s *span) otlp() map[string]any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := map[string]any{
+		"traceId":           hex.EncodeToString(s.sc.TraceID[:]),
+		"spanId":            hex.EncodeToString(s.sc.SpanID[:]),
+		"name":              s.name,
+		"kind":              s.kind,
+		"startTimeUnixNano": strconv.FormatInt(s.start.UnixNano(), 10),
+		"endTimeUnixNano":   strconv.FormatInt(s.end.UnixNano(), 10),
+		"attributes":        otlpAttributes(s.attrs),
+		"status":            map[string]any{"code": s.status, "message": s.errMsg},
+	}
+	if s.parent != [8]byte{} {
+		out["parentSpanId"] = hex.EncodeToString(s.parent[:])
+	}
+	return out
+}
+
+// otlpAttributes converts attrs to OTLP KeyValue form.
+func otlpAttributes(attrs map[string]any) []map[string]any {
+	out := make([]map[string]any, 0, len(attrs))
+	for k, v := range attrs {
+		var value map[string]any
+		switch x := v.(type) {
+		case string:
+			value = map[string]any{"stringValue": x}
+		case bool:
+			value = map[string]any{"boolValue": x}
+		case int:
+			value = map[string]any{"intValue": strconv.Itoa(x)}
+		case int64:
+			value = map[string]any{"intValue": strconv.FormatInt(x, 10)}
+		case float64:
+			value = map[string]any{"doubleValue": x}
+		default:
+			value = map[string]any{"stringValue": fmt.Sprint(x)}
+		}
+		out = append(out, map[string]any{"key": k, "value": value})
+	}
+	return out
+}
+
+// traceDB runs fn inside a "db.<op>" client span.
+func traceDB(ctx context.Context, op string, fn func() error) error {
+	_, s := startSpanKind(ctx, "db."+op, spanKindClient, "db.system", "sqlite", "db.operation", op)
+	err := fn()
+	s.End(err)
+	return err
+}
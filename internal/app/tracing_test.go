@@ -0,0 +1,137 @@
+package app
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	const value = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	sc, ok := parseTraceparent(value)
+	if !ok {
+		t.Fatalf("parseTraceparent should accept %q", value)
+	}
+	if got := sc.traceparent(); got != value {
+		t.Fatalf("traceparent round trip should match, got=%q", got)
+	}
+	for _, bad := range []string{"", "00-abc-def-01", "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01"} {
+		if _, ok := parseTraceparent(bad); ok {
+			t.Fatalf("parseTraceparent should reject %q", bad)
+		}
+	}
+}
+
+func TestSpansAreNoopWithoutExporter(t *testing.T) {
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+	shutdown, err := startTracing(nil)
+	if err != nil {
+		t.Fatalf("startTracing without endpoint should succeed, got=%v", err)
+	}
+	defer shutdown(context.Background())
+
+	ctx, s := startSpan(context.Background(), "noop")
+	if s != nil {
+		t.Fatalf("startSpan should return a nil span when tracing is off")
+	}
+	s.SetAttrs("k", "v")
+	s.End(nil)
+	header := http.Header{}
+	injectTraceContext(ctx, header)
+	if header.Get("Traceparent") != "" {
+		t.Fatalf("no traceparent should be injected without a span, got=%q", header.Get("Traceparent"))
+	}
+}
+
+func TestTracingExportsOTLPAndPropagates(t *testing.T) {
+	var mu sync.Mutex
+	var spans []map[string]any
+	var authHeader string
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" {
+			http.NotFound(w, r)
+			return
+		}
+		var payload struct {
+			ResourceSpans []struct {
+				ScopeSpans []struct {
+					Spans []map[string]any `json:"spans"`
+				} `json:"scopeSpans"`
+			} `json:"resourceSpans"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+		mu.Lock()
+		authHeader = r.Header.Get("Authorization")
+		for _, rs := range payload.ResourceSpans {
+			for _, ss := range rs.ScopeSpans {
+				spans = append(spans, ss.Spans...)
+			}
+		}
+		mu.Unlock()
+	}))
+	defer collector.Close()
+
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", collector.URL)
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+	t.Setenv("OTEL_EXPORTER_OTLP_HEADERS", "Authorization=Bearer otel")
+	shutdown, err := startTracing(nil)
+	if err != nil {
+		t.Fatalf("startTracing should succeed, got=%v", err)
+	}
+
+	incoming := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	incoming.Header.Set("Traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx, parent := startSpanKind(extractTraceContext(incoming.Context(), incoming), "proxy.request", spanKindServer)
+	childCtx, child := startSpan(ctx, "proxy.upstream", "target.id", 7)
+	header := http.Header{}
+	injectTraceContext(childCtx, header)
+	child.End(nil)
+	parent.End(context.Canceled)
+
+	flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	shutdown(flushCtx)
+
+	upstream, ok := parseTraceparent(header.Get("Traceparent"))
+	if !ok {
+		t.Fatalf("upstream traceparent should be valid, got=%q", header.Get("Traceparent"))
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if authHeader != "Bearer otel" {
+		t.Fatalf("OTLP headers should be sent, got=%q", authHeader)
+	}
+	if len(spans) != 2 {
+		t.Fatalf("collector should receive 2 spans, got=%d", len(spans))
+	}
+	byName := map[string]map[string]any{}
+	for _, s := range spans {
+		byName[s["name"].(string)] = s
+		if s["traceId"] != "4bf92f3577b34da6a3ce929d0e0e4736" {
+			t.Fatalf("span should continue the incoming trace, got=%v", s["traceId"])
+		}
+	}
+	if byName["proxy.request"]["parentSpanId"] != "00f067aa0ba902b7" {
+		t.Fatalf("server span should be parented to the remote caller, got=%v", byName["proxy.request"]["parentSpanId"])
+	}
+	if byName["proxy.upstream"]["parentSpanId"] != byName["proxy.request"]["spanId"] {
+		t.Fatalf("client span should be parented to the server span, got=%v", byName["proxy.upstream"]["parentSpanId"])
+	}
+	if byName["proxy.upstream"]["spanId"] != hex.EncodeToString(upstream.SpanID[:]) {
+		t.Fatalf("injected traceparent should name the client span, got=%q", header.Get("Traceparent"))
+	}
+	status := byName["proxy.request"]["status"].(map[string]any)
+	if status["code"].(float64) != spanStatusError {
+		t.Fatalf("failed span should carry error status, got=%v", status)
+	}
+	if _, s := startSpan(context.Background(), "after"); s != nil {
+		t.Fatalf("exporter should be uninstalled after shutdown")
+	}
+}
@@ -0,0 +1,162 @@
+package app
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Target trash. Deleting a target only sets deleted_at: it disappears from listings, the
+// scheduler and the proxy, but its runs and history stay until it is restored or an
+// admin purges it. A trashed target still holds its name, so a new target cannot take
+// the name until the old one is purged.
+
+// ListDeletedTargets returns the targets in the trash, most recently deleted first.
+func (d *Database) ListDeletedTargets() ([]Target, error) {
+	rows, err := d.read.Query(`
+		SELECT ` + targetColumns + ` FROM targets
+		WHERE deleted_at IS NOT NULL
+		ORDER BY deleted_at DESC, id DESC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	targets := []Target{}
+	for rows.Next() {
+		t, err := scanTarget(rows)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, *t)
+	}
+	return targets, rows.Err()
+}
+
+// GetDeletedTarget returns a target in the trash by id, or nil if there is none.
+func (d *Database) GetDeletedTarget(targetID int) (*Target, error) {
+	t, err := scanTarget(d.read.QueryRow("SELECT "+targetColumns+" FROM targets WHERE id = ? AND deleted_at IS NOT NULL", targetID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return t, err
+}
+
+// explainTrashedName replaces a unique-name error from writing name with one pointing
+// at the trashed target that holds the name.
+func (d *Database) explainTrashedName(err error, name *string) error {
+	if err == nil || name == nil || !strings.Contains(err.Error(), "UNIQUE constraint failed: targets.name") {
+		return err
+	}
+	var id int
+	if d.read.QueryRow("SELECT id FROM targets WHERE name = ? AND deleted_at IS NOT NULL", *name).Scan(&id) != nil {
+		return err
+	}
+	return fmt.Errorf("name %q is used by deleted target %d in the trash; restore or purge it first", *name, id)
+}
+
+// RestoreTarget takes a target out of the trash.
+func (d *Database) RestoreTarget(targetID int) (bool, error) {
+	defer d.touchTargets()
+	d.mu.Lock()
+	res, err := d.conn.Exec("UPDATE targets SET deleted_at = NULL, updated_at = ? WHERE id = ? AND deleted_at IS NOT NULL",
+		float64(time.Now().UnixMilli())/1000.0, targetID)
+	d.mu.Unlock()
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// PurgeTarget permanently removes a target in the trash with its runs and history.
+func (d *Database) PurgeTarget(targetID int) (bool, error) {
+	defer d.touchTargets()
+	d.mu.Lock()
+	res, err := d.conn.Exec("DELETE FROM targets WHERE id = ? AND deleted_at IS NOT NULL", targetID)
+	d.mu.Unlock()
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// ListTrash -- GET /api/targets/trash
+func (h *Handlers) ListTrash(w http.ResponseWriter, r *http.Request) {
+	targets, err := h.db.ListDeletedTargets()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return
+	}
+	targets = filterTargetsForPrincipal(r, targets)
+	items := make([]map[string]any, 0, len(targets))
+	for i := range targets {
+		t := &targets[i]
+		item := h.targetRuntimeFieldsWithData(r, t, false, nil)
+		item["deleted_at"] = t.DeletedAt
+		item["can_operate"] = h.canOperateChannels(r, t)
+		items = append(items, item)
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"items": items})
+}
+
+// RestoreTarget -- POST /api/targets/{id}/restore
+func (h *Handlers) RestoreTarget(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(r)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "invalid id"})
+		return
+	}
+	existing, err := h.db.GetDeletedTarget(id)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return
+	}
+	if existing == nil {
+		writeJSON(w, http.StatusNotFound, map[string]any{"detail": "target not found in trash"})
+		return
+	}
+	if !h.requireChannelOperationPermission(w, r, existing) {
+		return
+	}
+	if _, err := h.db.RestoreTarget(id); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return
+	}
+	restored, err := h.db.GetTarget(id)
+	if err != nil || restored == nil {
+		writeJSON(w, http.StatusNotFound, map[string]any{"detail": "target not found"})
+		return
+	}
+	h.audit(r, "target.restore", "target", id, auditTargetDiff(existing, restored))
+	writeJSON(w, http.StatusOK, map[string]any{"ok": true, "item": h.targetRuntimeFields(r, restored)})
+}
+
+// AdminPurgeTarget -- DELETE /api/admin/trash/{id}
+// Only targets already in the trash can be purged.
+func (h *Handlers) AdminPurgeTarget(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(r)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "invalid id"})
+		return
+	}
+	existing, err := h.db.GetDeletedTarget(id)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return
+	}
+	if existing == nil {
+		writeJSON(w, http.StatusNotFound, map[string]any{"detail": "target not found in trash"})
+		return
+	}
+	if _, err := h.db.PurgeTarget(id); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return
+	}
+	h.audit(r, "target.purge", "target", id, auditTargetDiff(existing, nil))
+	writeJSON(w, http.StatusOK, map[string]any{"ok": true})
+}
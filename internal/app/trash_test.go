@@ -0,0 +1,80 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTargetTrashRestoreAndPurge(t *testing.T) {
+	db, err := NewDatabase(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDatabase failed: %v", err)
+	}
+	t.Cleanup(func() { _ = db.conn.Close() })
+	h := &Handlers{db: db, monitor: NewMonitorService(MonitorConfig{DB: db, LogDir: t.TempDir()})}
+
+	target, err := db.CreateTarget(map[string]any{"name": "a", "base_url": "https://example.com", "api_key": "k"})
+	if err != nil {
+		t.Fatalf("CreateTarget failed: %v", err)
+	}
+	runID, err := db.CreateRun(target.ID, float64(time.Now().Unix()), "", nil, runModeFull)
+	if err != nil {
+		t.Fatalf("CreateRun failed: %v", err)
+	}
+	call := func(method, path string, handler http.HandlerFunc) *httptest.ResponseRecorder {
+		req := withAuthRole(httptest.NewRequest(method, path, nil), authRoleAdmin)
+		req.SetPathValue("id", strconv.Itoa(target.ID))
+		rr := httptest.NewRecorder()
+		handler(rr, req)
+		return rr
+	}
+
+	if rr := call(http.MethodDelete, "/api/targets/1", h.DeleteTarget); rr.Code != http.StatusOK {
+		t.Fatalf("delete should succeed, got status=%d body=%s", rr.Code, rr.Body.String())
+	}
+	if got, _ := db.GetTarget(target.ID); got != nil {
+		t.Fatalf("trashed target should be hidden, got=%+v", got)
+	}
+	if targets, _ := db.ListTargets(); len(targets) != 0 {
+		t.Fatalf("trashed target should leave the list, got=%d", len(targets))
+	}
+	if rr := call(http.MethodGet, "/api/targets/trash", h.ListTrash); !strings.Contains(rr.Body.String(), `"deleted_at":`) {
+		t.Fatalf("trash should list the target, got=%s", rr.Body.String())
+	}
+	if run, _ := db.GetRun(target.ID, runID); run == nil {
+		t.Fatalf("soft delete should keep the runs")
+	}
+	if _, err := db.CreateTarget(map[string]any{"name": "a", "base_url": "https://example.com", "api_key": "k"}); err == nil || !strings.Contains(err.Error(), "trash") {
+		t.Fatalf("taking a trashed name should point at the trash, got=%v", err)
+	}
+
+	if rr := call(http.MethodPost, "/api/targets/1/restore", h.RestoreTarget); rr.Code != http.StatusOK {
+		t.Fatalf("restore should succeed, got status=%d body=%s", rr.Code, rr.Body.String())
+	}
+	if got, _ := db.GetTarget(target.ID); got == nil || got.DeletedAt != nil {
+		t.Fatalf("restored target should be back, got=%+v", got)
+	}
+	if rr := call(http.MethodDelete, "/api/admin/trash/1", h.AdminPurgeTarget); rr.Code != http.StatusNotFound {
+		t.Fatalf("purge should only take trashed targets, got status=%d", rr.Code)
+	}
+
+	if ok, _ := db.DeleteTarget(target.ID); !ok {
+		t.Fatalf("second delete should trash the target")
+	}
+	if rr := call(http.MethodDelete, "/api/admin/trash/1", h.AdminPurgeTarget); rr.Code != http.StatusOK {
+		t.Fatalf("purge should succeed, got status=%d body=%s", rr.Code, rr.Body.String())
+	}
+	if trashed, _ := db.GetDeletedTarget(target.ID); trashed != nil {
+		t.Fatalf("purged target should be gone")
+	}
+	var runs int
+	_ = db.conn.QueryRow("SELECT COUNT(*) FROM runs WHERE target_id = ?", target.ID).Scan(&runs)
+	if runs != 0 {
+		t.Fatalf("purge should cascade to runs, got=%d", runs)
+	}
+}
@@ -0,0 +1,271 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Token accounting. The usage block of detection and proxy responses is parsed into
+// prompt, completion and reasoning tokens: OpenAI chat (usage.prompt_tokens, ...),
+// Responses and Anthropic (input_tokens / output_tokens, Anthropic cache reads and
+// writes counted as prompt tokens) and Gemini (usageMetadata). Detection usage is stored
+// on run_models, proxy usage on proxy_requests; GET /api/targets/{id}/usage sums both
+// per target so the cost of probing and of proxied traffic can be tracked.
+
+// maxProxyUsageBody caps the non-streaming proxy response buffered to read its usage.
+const maxProxyUsageBody = 4 << 20
+
+// tokenUsage is the token accounting of one response.
+type tokenUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	ReasoningTokens  int `json:"reasoning_tokens"`
+}
+
+func (u tokenUsage) empty() bool {
+	return u.PromptTokens == 0 && u.CompletionTokens == 0 && u.ReasoningTokens == 0
+}
+
+// merge keeps the larger count of each field; streams repeat cumulative usage or split
+// it over several events (Anthropic message_start and message_delta).
+func (u *tokenUsage) merge(o tokenUsage) {
+	u.PromptTokens = max(u.PromptTokens, o.PromptTokens)
+	u.CompletionTokens = max(u.CompletionTokens, o.CompletionTokens)
+	u.ReasoningTokens = max(u.ReasoningTokens, o.ReasoningTokens)
+}
+
+// parseUsage reads the token usage of a response body or stream chunk.
+func parseUsage(body any) tokenUsage {
+	var u tokenUsage
+	m, ok := body.(map[string]any)
+	if !ok {
+		return u
+	}
+	if meta := nestedMap(m, "usageMetadata"); meta != nil {
+		u.PromptTokens = intField(meta, "promptTokenCount")
+		u.CompletionTokens = intField(meta, "candidatesTokenCount")
+		u.ReasoningTokens = intField(meta, "thoughtsTokenCount")
+		return u
+	}
+	for _, obj := range []map[string]any{m, nestedMap(m, "message"), nestedMap(m, "response")} {
+		usage := nestedMap(obj, "usage")
+		if usage == nil {
+			continue
+		}
+		if _, ok := usage["prompt_tokens"]; ok {
+			u.PromptTokens = intField(usage, "prompt_tokens")
+		} else {
+			u.PromptTokens = intField(usage, "input_tokens") + intField(usage, "cache_creation_input_tokens") + intField(usage, "cache_read_input_tokens")
+		}
+		if _, ok := usage["completion_tokens"]; ok {
+			u.CompletionTokens = intField(usage, "completion_tokens")
+		} else {
+			u.CompletionTokens = intField(usage, "output_tokens")
+		}
+		u.ReasoningTokens = max(intField(nestedMap(usage, "completion_tokens_details"), "reasoning_tokens"),
+			intField(nestedMap(usage, "output_tokens_details"), "reasoning_tokens"))
+		return u
+	}
+	return u
+}
+
+// setUsage copies u onto a detection row when the upstream reported any.
+func setUsage(row *DetectionResult, u tokenUsage) {
+	if u.empty() {
+		return
+	}
+	row.PromptTokens = ptrTo(u.PromptTokens)
+	row.CompletionTokens = ptrTo(u.CompletionTokens)
+	row.ReasoningTokens = ptrTo(u.ReasoningTokens)
+}
+
+// proxyUsageMeter reads the token usage of a proxied response as it is written. SSE
+// bodies are scanned line by line; other bodies are buffered up to maxProxyUsageBody
+// and parsed at the end. Compressed bodies are not read.
+type proxyUsageMeter struct {
+	sse   bool
+	buf   []byte
+	over  bool
+	usage tokenUsage
+}
+
+// newProxyUsageMeter returns a meter for a response with header h, or nil when the
+// body is encoded.
+func newProxyUsageMeter(h http.Header) *proxyUsageMeter {
+	if enc := h.Get("Content-Encoding"); enc != "" && enc != "identity" {
+		return nil
+	}
+	return &proxyUsageMeter{sse: strings.HasPrefix(h.Get("Content-Type"), "text/event-stream")}
+}
+
+func (m *proxyUsageMeter) Write(p []byte) {
+	if m.over {
+		return
+	}
+	m.buf = append(m.buf, p...)
+	if m.sse {
+		for {
+			i := bytes.IndexByte(m.buf, '\n')
+			if i < 0 {
+				break
+			}
+			m.line(m.buf[:i])
+			m.buf = m.buf[i+1:]
+		}
+	}
+	if len(m.buf) > maxProxyUsageBody {
+		m.over = true
+		m.buf = nil
+	}
+}
+
+func (m *proxyUsageMeter) line(line []byte) {
+	payload, ok := bytes.CutPrefix(bytes.TrimSpace(line), []byte("data:"))
+	if !ok || !bytes.Contains(payload, []byte("sage")) {
+		return
+	}
+	var obj map[string]any
+	if json.Unmarshal(bytes.TrimSpace(payload), &obj) == nil {
+		m.usage.merge(parseUsage(obj))
+	}
+}
+
+// result returns the usage read from the response.
+func (m *proxyUsageMeter) result() tokenUsage {
+	if m == nil {
+		return tokenUsage{}
+	}
+	if !m.sse && !m.over && len(m.buf) > 0 {
+		var body any
+		if json.Unmarshal(m.buf, &body) == nil {
+			return parseUsage(body)
+		}
+	}
+	return m.usage
+}
+
+// usageTotals sums the token usage of a set of requests; Requests counts those that
+// reported usage.
+type usageTotals struct {
+	Requests         int   `json:"requests"`
+	PromptTokens     int64 `json:"prompt_tokens"`
+	CompletionTokens int64 `json:"completion_tokens"`
+	ReasoningTokens  int64 `json:"reasoning_tokens"`
+	TotalTokens      int64 `json:"total_tokens"`
+}
+
+func (t *usageTotals) add(o usageTotals) {
+	t.Requests += o.Requests
+	t.PromptTokens += o.PromptTokens
+	t.CompletionTokens += o.CompletionTokens
+	t.ReasoningTokens += o.ReasoningTokens
+	t.TotalTokens += o.TotalTokens
+}
+
+type modelUsage struct {
+	Model     string      `json:"model"`
+	Detection usageTotals `json:"detection"`
+	Proxy     usageTotals `json:"proxy"`
+}
+
+// TargetUsage is the token consumption of a target over a window.
+type TargetUsage struct {
+	TargetID  int          `json:"target_id"`
+	Window    string       `json:"window"`
+	Since     float64      `json:"since"`
+	Detection usageTotals  `json:"detection"`
+	Proxy     usageTotals  `json:"proxy"`
+	Models    []modelUsage `json:"models"`
+}
+
+// GetTargetUsage sums the token usage of a target's detections and proxied requests
+// since since, per model. Proxied models requested as "<channel>/<model>" are counted
+// under the bare model.
+func (d *Database) GetTargetUsage(target *Target, since time.Time) (*TargetUsage, error) {
+	targetID := target.ID
+	sinceTS := float64(since.UnixMilli()) / 1000.0
+	out := &TargetUsage{TargetID: targetID, Since: sinceTS, Models: []modelUsage{}}
+	byModel := map[string]*modelUsage{}
+	collect := func(query, trimPrefix string, pick func(*modelUsage) *usageTotals) error {
+		rows, err := d.read.Query(query, targetID, sinceTS)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var model string
+			var t usageTotals
+			if err := rows.Scan(&model, &t.Requests, &t.PromptTokens, &t.CompletionTokens, &t.ReasoningTokens); err != nil {
+				return err
+			}
+			t.TotalTokens = t.PromptTokens + t.CompletionTokens
+			model = strings.TrimPrefix(model, trimPrefix)
+			m := byModel[model]
+			if m == nil {
+				m = &modelUsage{Model: model}
+				byModel[model] = m
+			}
+			pick(m).add(t)
+		}
+		return rows.Err()
+	}
+	err := collect(`
+		SELECT model, COUNT(*), COALESCE(SUM(prompt_tokens), 0), COALESCE(SUM(completion_tokens), 0), COALESCE(SUM(reasoning_tokens), 0)
+		FROM run_models
+		WHERE target_id = ? AND timestamp >= ? AND (prompt_tokens IS NOT NULL OR completion_tokens IS NOT NULL)
+		GROUP BY model`, "", func(m *modelUsage) *usageTotals { return &m.Detection })
+	if err != nil {
+		return nil, err
+	}
+	err = collect(`
+		SELECT model, COUNT(*), SUM(prompt_tokens), SUM(completion_tokens), SUM(reasoning_tokens)
+		FROM proxy_requests
+		WHERE target_id = ? AND created_at >= ? AND (prompt_tokens > 0 OR completion_tokens > 0)
+		GROUP BY model`, target.Name+"/", func(m *modelUsage) *usageTotals { return &m.Proxy })
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range byModel {
+		out.Detection.add(m.Detection)
+		out.Proxy.add(m.Proxy)
+		out.Models = append(out.Models, *m)
+	}
+	sort.Slice(out.Models, func(i, j int) bool {
+		a, b := out.Models[i], out.Models[j]
+		if ta, tb := a.Detection.TotalTokens+a.Proxy.TotalTokens, b.Detection.TotalTokens+b.Proxy.TotalTokens; ta != tb {
+			return ta > tb
+		}
+		return a.Model < b.Model
+	})
+	return out, nil
+}
+
+// TargetUsage -- GET /api/targets/{id}/usage?window=24h
+// Token consumption of the target's detections and proxied requests; window is one
+// of 1h, 6h, 24h (default), 7d, 30d.
+func (h *Handlers) TargetUsage(w http.ResponseWriter, r *http.Request) {
+	target, ok := h.loadNoteTarget(w, r)
+	if !ok {
+		return
+	}
+	window := r.URL.Query().Get("window")
+	if window == "" {
+		window = "24h"
+	}
+	span, ok := proxyStatsWindows[window]
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"detail": "window must be one of 1h, 6h, 24h, 7d, 30d"})
+		return
+	}
+	usage, err := h.db.GetTargetUsage(target, time.Now().Add(-span))
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"detail": err.Error()})
+		return
+	}
+	usage.Window = window
+	writeJSON(w, http.StatusOK, map[string]any{"item": usage})
+}
@@ -0,0 +1,98 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestParseUsage(t *testing.T) {
+	for _, c := range []struct {
+		body string
+		want tokenUsage
+	}{
+		{`{"usage":{"prompt_tokens":12,"completion_tokens":30,"completion_tokens_details":{"reasoning_tokens":20}}}`, tokenUsage{12, 30, 20}},
+		{`{"usage":{"input_tokens":7,"output_tokens":9,"output_tokens_details":{"reasoning_tokens":4}}}`, tokenUsage{7, 9, 4}},
+		{`{"usage":{"input_tokens":5,"cache_read_input_tokens":100,"output_tokens":3}}`, tokenUsage{105, 3, 0}},
+		{`{"type":"message_start","message":{"usage":{"input_tokens":8,"output_tokens":1}}}`, tokenUsage{8, 1, 0}},
+		{`{"type":"response.completed","response":{"usage":{"input_tokens":6,"output_tokens":2}}}`, tokenUsage{6, 2, 0}},
+		{`{"usageMetadata":{"promptTokenCount":4,"candidatesTokenCount":5,"thoughtsTokenCount":6}}`, tokenUsage{4, 5, 6}},
+		{`{"choices":[]}`, tokenUsage{}},
+	} {
+		var body any
+		_ = json.Unmarshal([]byte(c.body), &body)
+		if got := parseUsage(body); got != c.want {
+			t.Errorf("parseUsage(%s) = %+v, want %+v", c.body, got, c.want)
+		}
+	}
+}
+
+func TestTargetUsageSumsDetectionAndProxyTokens(t *testing.T) {
+	db, _ := newAnalyticsTestDB(t)
+	for _, ensure := range []func() error{db.EnsureProxySchema, db.EnsureProxyRequestSchema} {
+		if err := ensure(); err != nil {
+			t.Fatalf("schema failed: %v", err)
+		}
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if body["stream"] == true {
+			w.Header().Set("Content-Type", "text/event-stream")
+			_, _ = w.Write([]byte("data: {\"choices\":[{\"delta\":{\"content\":\"hi\"}}]}\n\n"))
+			_, _ = w.Write([]byte("data: {\"choices\":[],\"usage\":{\"prompt_tokens\":100,\"completion_tokens\":50}}\n\ndata: [DONE]\n\n"))
+			return
+		}
+		_, _ = w.Write([]byte(`{"choices":[{"message":{"content":"hi"}}],"usage":{"prompt_tokens":10,"completion_tokens":2,"completion_tokens_details":{"reasoning_tokens":1}}}`))
+	}))
+	defer srv.Close()
+	target, err := db.CreateTarget(map[string]any{"name": "relay", "base_url": srv.URL, "api_key": "k"})
+	if err != nil {
+		t.Fatalf("CreateTarget failed: %v", err)
+	}
+
+	ms := NewMonitorService(MonitorConfig{DB: db, LogDir: t.TempDir()})
+	row := ms.detectOne(context.Background(), target, "m", "chat", httpClient(5, false, nil, tlsFingerprintNone))
+	if row.PromptTokens == nil || *row.PromptTokens != 10 || *row.CompletionTokens != 2 || *row.ReasoningTokens != 1 {
+		t.Fatalf("detection usage should be parsed, got %+v", row)
+	}
+	insertAnalyticsRows(t, db, target.ID, []DetectionResult{row})
+
+	_, token, err := db.CreateProxyKey("ci", nil, nil, "", 0, nil)
+	if err != nil {
+		t.Fatalf("CreateProxyKey failed: %v", err)
+	}
+	h := &Handlers{db: db}
+	for _, body := range []string{`{"model":"relay/m","messages":[]}`, `{"model":"relay/m","stream":true,"messages":[]}`} {
+		req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewBufferString(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		h.ProxyChatCompletions(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("proxy request failed: %d %s", rr.Code, rr.Body.String())
+		}
+	}
+
+	id := strconv.Itoa(target.ID)
+	req := httptest.NewRequest(http.MethodGet, "/api/targets/"+id+"/usage?window=7d", nil)
+	req.SetPathValue("id", id)
+	rr := httptest.NewRecorder()
+	h.TargetUsage(rr, req)
+	var resp struct {
+		Item TargetUsage `json:"item"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil || rr.Code != http.StatusOK {
+		t.Fatalf("TargetUsage failed: %d %s", rr.Code, rr.Body.String())
+	}
+	u := resp.Item
+	if u.Detection.Requests != 1 || u.Detection.TotalTokens != 12 || u.Detection.ReasoningTokens != 1 {
+		t.Fatalf("unexpected detection totals: %+v", u.Detection)
+	}
+	if u.Proxy.Requests != 2 || u.Proxy.PromptTokens != 110 || u.Proxy.CompletionTokens != 52 || len(u.Models) != 1 || u.Models[0].Model != "m" {
+		t.Fatalf("unexpected proxy totals: %+v models=%+v", u.Proxy, u.Models)
+	}
+}
@@ -0,0 +1,129 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+const (
+	wsPingInterval = 30 * time.Second
+	// wsReadTimeout closes connections whose client sent nothing (not even a ping message) for this long.
+	wsReadTimeout  = 90 * time.Second
+	wsWriteTimeout = 10 * time.Second
+	wsMaxMessage   = 64 << 10
+)
+
+// wsClientMessage is sent by WebSocket clients.
+type wsClientMessage struct {
+	Type    string   `json:"type"`
+	Events  []string `json:"events"`
+	Targets []int    `json:"targets"`
+}
+
+// wsServerMessage is sent to WebSocket clients.
+type wsServerMessage struct {
+	Type    string          `json:"type"`
+	Event   string          `json:"event,omitempty"`
+	Data    json.RawMessage `json:"data,omitempty"`
+	Events  []string        `json:"events,omitempty"`
+	Targets []int           `json:"targets,omitempty"`
+	Detail  string          `json:"detail,omitempty"`
+}
+
+// WebSocketHandler mirrors the bus over WebSocket for clients behind proxies that buffer SSE.
+// Authentication is done by the wrapping middleware before the upgrade.
+func (b *SSEBus) WebSocketHandler() http.Handler {
+	return websocket.Server{
+		// Browsers cannot send Authorization on upgrade; the token query parameter is checked
+		// before the handshake, so cross-origin checks add nothing here.
+		Handshake: func(*websocket.Config, *http.Request) error { return nil },
+		Handler:   b.serveWebSocket,
+	}
+}
+
+func (b *SSEBus) serveWebSocket(ws *websocket.Conn) {
+	defer ws.Close()
+	ws.MaxPayloadBytes = wsMaxMessage
+
+	// The query string sets the initial filter, same as SSE; subscribe messages replace it.
+	filter, err := parseEventFilter(ws.Request())
+	if err != nil {
+		_ = websocket.JSON.Send(ws, wsServerMessage{Type: "error", Detail: err.Error()})
+		return
+	}
+	sub := b.subscribe(filter)
+	defer b.unsubscribe(sub)
+
+	replies := make(chan wsServerMessage, 8)
+	done := make(chan struct{})
+
+	// Reader: handles subscribe/ping messages; protocol pongs are consumed by the websocket package.
+	go func() {
+		defer close(done)
+		for {
+			_ = ws.SetReadDeadline(time.Now().Add(wsReadTimeout))
+			var msg wsClientMessage
+			if err := websocket.JSON.Receive(ws, &msg); err != nil {
+				return
+			}
+			var reply wsServerMessage
+			switch msg.Type {
+			case "subscribe":
+				b.setFilter(sub, newEventFilter(msg.Events, msg.Targets))
+				reply = wsServerMessage{Type: "subscribed", Events: msg.Events, Targets: msg.Targets}
+			case "ping":
+				reply = wsServerMessage{Type: "pong"}
+			default:
+				reply = wsServerMessage{Type: "error", Detail: "unknown message type"}
+			}
+			select {
+			case replies <- reply:
+			default:
+			}
+		}
+	}()
+
+	send := func(msg wsServerMessage) bool {
+		_ = ws.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+		return websocket.JSON.Send(ws, msg) == nil
+	}
+	if !send(wsServerMessage{Type: "connected"}) {
+		return
+	}
+
+	ping := time.NewTicker(wsPingInterval)
+	defer ping.Stop()
+	for {
+		select {
+		case ev, ok := <-sub.ch:
+			if !ok {
+				// Bus closed, exit gracefully
+				return
+			}
+			data := json.RawMessage(ev.Data)
+			if !json.Valid(data) {
+				data, _ = json.Marshal(ev.Data)
+			}
+			if !send(wsServerMessage{Type: "event", Event: ev.Event, Data: data}) {
+				return
+			}
+		case reply := <-replies:
+			if !send(reply) {
+				return
+			}
+		case <-ping.C:
+			_ = ws.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+			ws.PayloadType = websocket.PingFrame
+			_, err := ws.Write(nil)
+			ws.PayloadType = websocket.TextFrame
+			if err != nil {
+				return
+			}
+		case <-done:
+			return
+		}
+	}
+}
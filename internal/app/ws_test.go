@@ -0,0 +1,55 @@
+package app
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+func TestWebSocket_SubscriptionFiltersEvents(t *testing.T) {
+	bus := NewSSEBus()
+	srv := httptest.NewServer(bus.WebSocketHandler())
+	defer srv.Close()
+	defer bus.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(srv.URL, "http") + "/api/ws"
+	ws, err := websocket.Dial(wsURL, "", srv.URL)
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	defer ws.Close()
+	_ = ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	var msg wsServerMessage
+	if err := websocket.JSON.Receive(ws, &msg); err != nil || msg.Type != "connected" {
+		t.Fatalf("first message should be connected, got=%+v err=%v", msg, err)
+	}
+
+	if err := websocket.JSON.Send(ws, wsClientMessage{Type: "subscribe", Events: []string{"run_completed"}, Targets: []int{2}}); err != nil {
+		t.Fatalf("subscribe failed: %v", err)
+	}
+	if err := websocket.JSON.Receive(ws, &msg); err != nil || msg.Type != "subscribed" {
+		t.Fatalf("subscribe should be acknowledged, got=%+v err=%v", msg, err)
+	}
+
+	bus.Publish("run_completed", `{"target_id":1}`)
+	bus.Publish("target_updated", `{"target_id":2}`)
+	bus.Publish("run_completed", `{"target_id":2,"status":"healthy"}`)
+
+	if err := websocket.JSON.Receive(ws, &msg); err != nil {
+		t.Fatalf("receive event failed: %v", err)
+	}
+	if msg.Type != "event" || msg.Event != "run_completed" || !strings.Contains(string(msg.Data), `"target_id":2`) {
+		t.Fatalf("only the matching event should be delivered, got=%+v data=%s", msg, msg.Data)
+	}
+
+	if err := websocket.JSON.Send(ws, wsClientMessage{Type: "ping"}); err != nil {
+		t.Fatalf("ping failed: %v", err)
+	}
+	if err := websocket.JSON.Receive(ws, &msg); err != nil || msg.Type != "pong" {
+		t.Fatalf("ping should be answered with pong, got=%+v err=%v", msg, err)
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"embed"
+	"os"
 
 	"api_monitor/internal/app"
 )
@@ -10,5 +11,5 @@ import (
 var webFS embed.FS
 
 func main() {
-	app.Start(webFS)
+	os.Exit(app.RunCLI(webFS, os.Args[1:]))
 }
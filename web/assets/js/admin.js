@@ -218,6 +218,21 @@
 
             clearAlert();
 
+            const oidcError = new URLSearchParams(window.location.search).get('oidc_error');
+            if (oidcError) {
+                showAlert('error', oidcError);
+            }
+            try {
+                const oidc = await apiJSON('/api/v1/admin/oidc', {}, false);
+                if (oidc.enabled) {
+                    const btn = dom.byId('oidc-login-btn');
+                    btn?.classList.remove('hidden');
+                    btn?.classList.add('flex');
+                }
+            } catch (_) {
+                // SSO stays hidden when its status cannot be loaded.
+            }
+
             form.addEventListener('submit', async (e) => {
                 e.preventDefault();
                 clearAlert();
@@ -225,7 +240,7 @@
                 submitBtn.textContent = 'Signing in...';
 
                 try {
-                    await apiJSON('/api/admin/login', {
+                    await apiJSON('/api/v1/admin/login', {
                         method: 'POST',
                         body: JSON.stringify({
                             password: passwordInput.value || ''
@@ -274,13 +289,17 @@
             if (refreshChannelsBtn) {
                 refreshChannelsBtn.addEventListener('click', () => this.loadChannels());
             }
+            const oneAPIImportBtn = dom.byId('oneapi-import-btn');
+            if (oneAPIImportBtn) {
+                oneAPIImportBtn.addEventListener('click', () => this.importOneAPI());
+            }
             if (resourceRefreshBtn) {
                 resourceRefreshBtn.addEventListener('click', () => this.loadResources(true));
             }
             if (logoutBtn) {
                 logoutBtn.addEventListener('click', async () => {
                     try {
-                        await apiJSON('/api/admin/logout', { method: 'POST' }, false);
+                        await apiJSON('/api/v1/admin/logout', { method: 'POST' }, false);
                     } catch (err) {
                         // ignore logout error
                     }
@@ -376,6 +395,39 @@
             this.setResourceDetail(detail || 'Container cgroup metrics unavailable');
         },
 
+        applyRuntimeSnapshot(runtime) {
+            if (!runtime || typeof runtime !== 'object') {
+                ['resource-goroutines', 'resource-heap', 'resource-gc-count', 'resource-gc-pause']
+                    .forEach((id) => this.setResourceText(id, '--'));
+                return;
+            }
+            const goroutines = Number(runtime.goroutines);
+            this.setResourceText('resource-goroutines', Number.isFinite(goroutines) ? String(goroutines) : '--');
+            this.setResourceText('resource-heap', `${this.formatBytes(runtime.heap_inuse_bytes)} / ${this.formatBytes(runtime.heap_sys_bytes)}`);
+            const numGC = Number(runtime.num_gc);
+            this.setResourceText('resource-gc-count', Number.isFinite(numGC) ? String(numGC) : '--');
+            const pauses = Array.isArray(runtime.recent_gc_pauses_ms) ? runtime.recent_gc_pauses_ms : [];
+            const lastPause = pauses.length > 0 ? Number(pauses[0]) : NaN;
+            this.setResourceText('resource-gc-pause', Number.isFinite(lastPause) ? `${lastPause.toFixed(2)} ms` : '--');
+        },
+
+        applyStorageSnapshot(storage) {
+            if (!storage || typeof storage !== 'object') {
+                ['resource-disk-free', 'resource-db-size', 'resource-wal-size', 'resource-log-size', 'resource-table-rows']
+                    .forEach((id) => this.setResourceText(id, '--'));
+                return;
+            }
+            const total = storage.disk_total_bytes == null ? null : Number(storage.disk_total_bytes);
+            const free = storage.disk_free_bytes == null ? null : Number(storage.disk_free_bytes);
+            this.setResourceText('resource-disk-free', free == null ? '--' : `${this.formatBytes(free)} / ${this.formatBytes(total)}`);
+            this.setResourceText('resource-db-size', this.formatBytes(storage.database_bytes));
+            this.setResourceText('resource-wal-size', this.formatBytes(storage.wal_bytes));
+            this.setResourceText('resource-log-size', `${this.formatBytes(storage.log_dir_bytes)} (${Number(storage.log_files) || 0} files)`);
+            const rows = storage.table_rows && typeof storage.table_rows === 'object' ? storage.table_rows : {};
+            const parts = Object.keys(rows).sort().map((name) => `${name}: ${rows[name]}`);
+            this.setResourceText('resource-table-rows', parts.length > 0 ? `Rows — ${parts.join(' · ')}` : '--');
+        },
+
         normalizeResourceSample(payload) {
             if (!payload || typeof payload !== 'object') return null;
             const sampleTimeMs = Number(payload.sample_time_ms);
@@ -413,6 +465,8 @@
         },
 
         applyResourceSnapshot(payload) {
+            this.applyRuntimeSnapshot(payload && payload.runtime);
+            this.applyStorageSnapshot(payload && payload.storage);
             const sample = this.normalizeResourceSample(payload);
             if (!sample || !Number.isFinite(sample.sampleTimeMs)) {
                 this.setResourceUnavailable('Invalid resource payload');
@@ -458,7 +512,7 @@
                 this.setBusy([buttonId], true, { [buttonId]: 'Loading...' });
             }
             try {
-                const data = await apiJSON('/api/admin/resources');
+                const data = await apiJSON('/api/v1/admin/resources');
                 this.applyResourceSnapshot(data || null);
             } catch (err) {
                 this.setResourceUnavailable(err.message || 'Failed to load resources');
@@ -490,8 +544,24 @@
                 this.updateVisitorModeUI(visitorModeToggle.checked);
             }
             if (proxyInput) proxyInput.value = this.item.proxy_master_token || '';
+            const redactionSelect = dom.byId('api-key-redaction');
+            if (redactionSelect) redactionSelect.value = this.item.api_key_redaction || 'visitors';
             if (cleanupEnabledInput) cleanupEnabledInput.checked = !!this.item.log_cleanup_enabled;
             if (cleanupSizeInput) cleanupSizeInput.value = this.item.log_max_size_mb ?? 500;
+            const cleanupAgeInput = dom.byId('log-max-age-days');
+            if (cleanupAgeInput) cleanupAgeInput.value = this.item.log_max_age_days ?? 0;
+            const detectInput = dom.byId('detect-concurrency');
+            if (detectInput) detectInput.value = this.item.detect_concurrency ?? 3;
+            const parallelInput = dom.byId('max-parallel-targets');
+            if (parallelInput) parallelInput.value = this.item.max_parallel_targets ?? 2;
+            const runDurationInput = dom.byId('max-run-duration-s');
+            if (runDurationInput) runDurationInput.value = this.item.max_run_duration_s ?? 3600;
+            const archiveDaysInput = dom.byId('run-archive-days');
+            if (archiveDaysInput) archiveDaysInput.value = this.item.run_archive_days ?? 0;
+            const ratePerMinInput = dom.byId('visitor-rate-limit-per-min');
+            if (ratePerMinInput) ratePerMinInput.value = this.item.visitor_rate_limit_per_min ?? 0;
+            const rateBurstInput = dom.byId('visitor-rate-limit-burst');
+            if (rateBurstInput) rateBurstInput.value = this.item.visitor_rate_limit_burst ?? 0;
         },
 
         updateVisitorModeUI(enabled) {
@@ -512,6 +582,14 @@
             const token = String(dom.byId('proxy-master-token')?.value || '').trim();
             const cleanupEnabled = !!dom.byId('log-cleanup-enabled')?.checked;
             const maxMB = parseIntStrict(dom.byId('log-max-size-mb')?.value, 500);
+            const maxAgeDays = parseIntStrict(dom.byId('log-max-age-days')?.value, 0);
+            const apiKeyRedaction = String(dom.byId('api-key-redaction')?.value || 'visitors');
+            const detectConcurrency = parseIntStrict(dom.byId('detect-concurrency')?.value, 3);
+            const maxParallelTargets = parseIntStrict(dom.byId('max-parallel-targets')?.value, 2);
+            const maxRunDurationS = parseIntStrict(dom.byId('max-run-duration-s')?.value, 3600);
+            const runArchiveDays = parseIntStrict(dom.byId('run-archive-days')?.value, 0);
+            const visitorRatePerMin = parseIntStrict(dom.byId('visitor-rate-limit-per-min')?.value, 0);
+            const visitorRateBurst = parseIntStrict(dom.byId('visitor-rate-limit-burst')?.value, 0);
 
             if (!apiMonitorTokenAdmin || apiMonitorTokenAdmin.length > 256) {
                 throw new Error('api_monitor_token_admin must be 1-256 chars');
@@ -522,6 +600,27 @@
             if (maxMB < 0 || maxMB > 102400) {
                 throw new Error('log_max_size_mb must be between 0 and 102400');
             }
+            if (maxAgeDays < 0 || maxAgeDays > 3650) {
+                throw new Error('log_max_age_days must be between 0 and 3650');
+            }
+            if (detectConcurrency < 1 || detectConcurrency > 64) {
+                throw new Error('detect_concurrency must be between 1 and 64');
+            }
+            if (maxParallelTargets < 1 || maxParallelTargets > 64) {
+                throw new Error('max_parallel_targets must be between 1 and 64');
+            }
+            if (maxRunDurationS < 0 || maxRunDurationS > 86400) {
+                throw new Error('max_run_duration_s must be between 0 and 86400');
+            }
+            if (runArchiveDays < 0 || runArchiveDays > 3650) {
+                throw new Error('run_archive_days must be between 0 and 3650');
+            }
+            if (visitorRatePerMin < 0 || visitorRatePerMin > 100000) {
+                throw new Error('visitor_rate_limit_per_min must be between 0 and 100000');
+            }
+            if (visitorRateBurst < 0 || visitorRateBurst > 100000) {
+                throw new Error('visitor_rate_limit_burst must be between 0 and 100000');
+            }
 
             return {
                 api_monitor_token_admin: apiMonitorTokenAdmin,
@@ -529,7 +628,15 @@
                 visitor_mode_enabled: visitorModeEnabled,
                 proxy_master_token: token,
                 log_cleanup_enabled: cleanupEnabled,
-                log_max_size_mb: maxMB
+                log_max_size_mb: maxMB,
+                log_max_age_days: maxAgeDays,
+                api_key_redaction: apiKeyRedaction,
+                detect_concurrency: detectConcurrency,
+                max_parallel_targets: maxParallelTargets,
+                max_run_duration_s: maxRunDurationS,
+                run_archive_days: runArchiveDays,
+                visitor_rate_limit_per_min: visitorRatePerMin,
+                visitor_rate_limit_burst: visitorRateBurst
             };
         },
 
@@ -543,7 +650,7 @@
                     [buttonId]: 'Saving...'
                 });
 
-                const data = await apiJSON('/api/admin/settings', {
+                const data = await apiJSON('/api/v1/admin/settings', {
                     method: 'PATCH',
                     body: JSON.stringify(payload)
                 });
@@ -559,7 +666,7 @@
 
         async loadSettings() {
             try {
-                const data = await apiJSON('/api/admin/settings');
+                const data = await apiJSON('/api/v1/admin/settings');
                 this.applySettings(data.item || null);
             } catch (err) {
                 showAlert('error', err.message || 'Failed to load settings');
@@ -688,7 +795,7 @@
             }
 
             try {
-                const data = await apiJSON(`/api/admin/channels/${channelID}/models`);
+                const data = await apiJSON(`/api/v1/admin/channels/${channelID}/models`);
                 const item = data.item || {};
                 this.modelSelectionAvailable = Array.isArray(item.available_models) ? item.available_models : [];
                 const selected = Array.isArray(item.selected_models) ? item.selected_models : [];
@@ -722,7 +829,7 @@
             this.setChannelModelsError('');
             try {
                 const selectedModels = Array.from(this.modelSelectionChecked);
-                const data = await apiJSON(`/api/admin/channels/${channelID}/models`, {
+                const data = await apiJSON(`/api/v1/admin/channels/${channelID}/models`, {
                     method: 'PATCH',
                     body: JSON.stringify({ selected_models: selectedModels })
                 });
@@ -806,7 +913,7 @@
             clearAlert();
             this.setBusy(['refresh-channels-btn'], true, { 'refresh-channels-btn': 'Loading...' });
             try {
-                const data = await apiJSON('/api/admin/channels');
+                const data = await apiJSON('/api/v1/admin/channels');
                 this.channels = Array.isArray(data.items) ? data.items : [];
                 this.renderChannels();
             } catch (err) {
@@ -816,9 +923,45 @@
             }
         },
 
+        async importOneAPI() {
+            clearAlert();
+            const resultEl = dom.byId('oneapi-import-result');
+            const payload = {
+                base_url: (dom.byId('oneapi-base-url')?.value || '').trim(),
+                token: (dom.byId('oneapi-token')?.value || '').trim(),
+                user_id: parseIntStrict(dom.byId('oneapi-user-id')?.value, 0),
+                include_disabled: !!dom.byId('oneapi-include-disabled')?.checked,
+                dry_run: !!dom.byId('oneapi-dry-run')?.checked
+            };
+            this.setBusy(['oneapi-import-btn'], true, { 'oneapi-import-btn': 'Importing...' });
+            try {
+                const data = await apiJSON('/api/v1/admin/import/oneapi', {
+                    method: 'POST',
+                    body: JSON.stringify(payload)
+                });
+                const lines = [`${data.dry_run ? 'Dry run: ' : ''}${data.channels} channels, ${data.created} to create, ${data.updated} to update, ${data.skipped} skipped`];
+                for (const item of data.items || []) {
+                    if (item.action === 'skip' && item.detail) {
+                        lines.push(`#${item.channel_id} ${item.name}: ${item.detail}`);
+                    }
+                }
+                if (resultEl) {
+                    resultEl.textContent = lines.join('\n');
+                    resultEl.classList.remove('hidden');
+                }
+                if (!data.dry_run) {
+                    await this.loadChannels();
+                }
+            } catch (err) {
+                showAlert('error', err.message || 'One-API import failed');
+            } finally {
+                this.setBusy(['oneapi-import-btn'], false);
+            }
+        },
+
         async setChannelVisitorActions(channelID, enabled, sourceEl) {
             try {
-                const data = await apiJSON(`/api/admin/channels/${channelID}/advanced`, {
+                const data = await apiJSON(`/api/v1/admin/channels/${channelID}/advanced`, {
                     method: 'PATCH',
                     body: JSON.stringify({ visitor_channel_actions_enabled: !!enabled })
                 });
@@ -927,7 +1070,7 @@
             const saveButtonId = 'channel-advanced-save-btn';
             this.setBusy([saveButtonId], true, { [saveButtonId]: 'Saving...' });
             try {
-                const data = await apiJSON(`/api/admin/channels/${id}/advanced`, {
+                const data = await apiJSON(`/api/v1/admin/channels/${id}/advanced`, {
                     method: 'PATCH',
                     body: JSON.stringify(payload)
                 });
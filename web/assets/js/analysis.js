@@ -82,7 +82,7 @@ async function fetchLogs(targetId) {
 
     try {
         // Fetch last 500 logs ?limit=500
-        const res = await Utils.authFetch(`/api/targets/${targetId}/logs?limit=500`);
+        const res = await Utils.authFetch(`/api/v1/targets/${targetId}/logs?limit=500`);
         if (!res.ok) throw new Error('Failed to fetch logs');
 
         const data = await res.json();
@@ -471,6 +471,21 @@ function updateAnalytics() {
         tbody.appendChild(row);
     });
 
+    // --- Error Categories ---
+    const categoryCounts = {};
+    filteredLogs.filter(l => !l.success).forEach(l => {
+        const category = l.error_category || 'other';
+        categoryCounts[category] = (categoryCounts[category] || 0) + 1;
+    });
+    const categoryList = document.getElementById('errorCategoryList');
+    categoryList.innerHTML = '';
+    Object.entries(categoryCounts).sort((a, b) => b[1] - a[1]).forEach(([category, count]) => {
+        const chip = document.createElement('span');
+        chip.className = "px-2 py-0.5 rounded-full font-mono bg-zinc-100 dark:bg-zinc-800 text-zinc-700 dark:text-zinc-300 border border-zinc-200 dark:border-zinc-700";
+        chip.textContent = `${category} · ${count}`;
+        categoryList.appendChild(chip);
+    });
+
     // --- Error Breakdown ---
     const errorCounts = {};
     filteredLogs.filter(l => !l.success && l.error).forEach(l => {
@@ -558,7 +573,10 @@ function openModal(id) {
     document.getElementById('modalId').innerText = `ID: ${log.id}`;
     document.getElementById('modalProtocol').innerText = log.protocol;
     document.getElementById('modalModel').innerText = log.model;
-    document.getElementById('modalLatency').innerText = (log.duration * 1000).toFixed(2) + ' ms';
+    let latencyText = (log.duration * 1000).toFixed(2) + ' ms';
+    if (typeof log.ttft_ms === 'number') latencyText += ` · TTFT ${log.ttft_ms.toFixed(0)} ms`;
+    if (typeof log.tokens_per_sec === 'number') latencyText += ` · ${log.tokens_per_sec.toFixed(1)} tok/s`;
+    document.getElementById('modalLatency').innerText = latencyText;
     document.getElementById('modalStream').innerText = log.stream ? 'True' : 'False';
 
     // Header status
@@ -12,6 +12,7 @@ function dashboard() {
         form: {},
         formError: '',
         authRole: 'visitor',
+        schedulerPaused: false,
 
         // Model Selection state
         modelModalOpen: false,
@@ -24,7 +25,7 @@ function dashboard() {
         modelChecked: new Set(),
 
         defaultForm: {
-            name: '', base_url: '', api_key: '', source_url: '',
+            name: '', base_url: '', api_key: '', api_keys_text: '', source_url: '',
             interval_min: 30, timeout_s: 30
         },
 
@@ -37,26 +38,131 @@ function dashboard() {
         },
 
 
+        // Live transport state: SSE first, WebSocket after repeated SSE failures,
+        // and only the fallback polling in init() once both keep failing.
+        sseFailures: 0,
+        wsFailures: 0,
+        // target_id -> { done, total } for runs in progress
+        progress: {},
+
+        onRunEvent(type, raw) {
+            let data = raw;
+            if (typeof raw === 'string') {
+                try { data = JSON.parse(raw); } catch (e) { data = {}; }
+            }
+            const id = data && data.target_id;
+            if (type === 'run_completed') {
+                if (id) delete this.progress[id];
+                this.loadData();
+                return;
+            }
+            if (!id) return;
+            this.progress = { ...this.progress, [id]: { done: data.done || 0, total: data.total || 0 } };
+            if (type === 'run_started') {
+                const t = this.targets.find(x => x.id === id);
+                if (t) t.running = true;
+            }
+        },
+
+        progressText(t) {
+            const p = this.progress[t.id];
+            if (!t.running || !p || !p.total) return '';
+            return `${p.done}/${p.total}`;
+        },
+
+        // Reconnect delay: 5s doubling per consecutive failure, capped at 60s.
+        reconnectDelay(failures) {
+            return Math.min(5000 * Math.pow(2, Math.max(0, failures - 1)), 60000);
+        },
+
+        // Both transports failed: rely on polling and try SSE again later.
+        fallbackToPolling() {
+            console.warn('Live updates unavailable, falling back to polling');
+            this.sseFailures = 0;
+            this.wsFailures = 0;
+            setTimeout(() => this.connectSSE(), 300000);
+        },
+
         connectSSE() {
             try {
-                const es = Utils.createEventSource('/api/events');
-                es.addEventListener('run_completed', () => this.loadData());
+                const es = Utils.createEventSource('/api/v1/events?events=run_completed,target_updated,run_started,run_progress,monitor_paused,targets_reordered');
+                let connected = false;
+                es.addEventListener('connected', () => {
+                    connected = true;
+                    this.sseFailures = 0;
+                    this.wsFailures = 0;
+                });
+                es.addEventListener('run_completed', (e) => this.onRunEvent('run_completed', e.data));
                 es.addEventListener('target_updated', () => this.loadData());
+                es.addEventListener('monitor_paused', () => this.loadData());
+                es.addEventListener('targets_reordered', () => this.loadData());
+                es.addEventListener('run_started', (e) => this.onRunEvent('run_started', e.data));
+                es.addEventListener('run_progress', (e) => this.onRunEvent('run_progress', e.data));
                 es.onerror = () => {
                     es.close();
-                    // Reconnect after 5s
-                    setTimeout(() => this.connectSSE(), 5000);
+                    if (connected) {
+                        setTimeout(() => this.connectSSE(), this.reconnectDelay(1));
+                        return;
+                    }
+                    // SSE that never connects is usually buffered by a proxy: switch to WebSocket
+                    if (++this.sseFailures >= 2) {
+                        this.connectWS();
+                        return;
+                    }
+                    setTimeout(() => this.connectSSE(), this.reconnectDelay(this.sseFailures));
                 };
             } catch (e) {
                 console.warn('SSE not available, using polling', e);
             }
         },
 
+        connectWS() {
+            try {
+                const ws = Utils.createWebSocket('/api/v1/ws?events=run_completed,target_updated,run_started,run_progress,monitor_paused,targets_reordered');
+                let pinger = null;
+                let opened = false;
+                ws.onopen = () => {
+                    opened = true;
+                    this.wsFailures = 0;
+                    pinger = setInterval(() => ws.send(JSON.stringify({ type: 'ping' })), 25000);
+                };
+                ws.onmessage = (msg) => {
+                    let payload = null;
+                    try { payload = JSON.parse(msg.data); } catch (e) { return; }
+                    if (payload.type !== 'event') return;
+                    if (['target_updated', 'monitor_paused', 'targets_reordered'].includes(payload.event)) {
+                        this.loadData();
+                    } else {
+                        this.onRunEvent(payload.event, payload.data);
+                    }
+                };
+                ws.onclose = () => {
+                    if (pinger) clearInterval(pinger);
+                    if (opened) {
+                        // A working session dropped: start over from SSE.
+                        this.sseFailures = 0;
+                        setTimeout(() => this.connectSSE(), this.reconnectDelay(1));
+                        return;
+                    }
+                    // Rejected upgrades (401, proxies blocking WebSocket) will not fix themselves quickly.
+                    if (++this.wsFailures >= 3) {
+                        this.fallbackToPolling();
+                        return;
+                    }
+                    setTimeout(() => this.connectWS(), this.reconnectDelay(this.wsFailures));
+                };
+            } catch (e) {
+                console.warn('WebSocket not available, using polling', e);
+                this.fallbackToPolling();
+            }
+        },
+
         async loadData() {
             try {
-                const res = await Utils.authFetch('/api/targets');
+                const res = await Utils.authFetch('/api/v1/targets');
                 const data = await res.json();
                 this.targets = data.items || [];
+                this.schedulerPaused = !!data.scheduler_paused;
                 const permissions = data.permissions || {};
                 this.authRole = permissions.role || 'visitor';
             } catch (e) {
@@ -77,6 +183,8 @@ function dashboard() {
             if (this.filterStatus !== 'all') {
                 if (this.filterStatus === 'down') {
                     items = items.filter(t => ['down', 'error'].includes(t.last_status));
+                } else if (this.filterStatus === 'snoozed') {
+                    items = items.filter(t => t.snoozed);
                 } else {
                     items = items.filter(t => t.last_status === this.filterStatus);
                 }
@@ -106,11 +214,12 @@ function dashboard() {
             // Count total models
             const models = this.targets.reduce((sum, t) => sum + (t.latest_models?.length || 0), 0);
 
-            // Count healthy models (success=true)
+            // Count healthy models (success=true, serving the requested model)
             const healthy = this.targets.reduce((sum, t) => {
-                const successCount = (t.latest_models || []).filter(m => m.success).length;
+                const successCount = (t.latest_models || []).filter(m => m.success && !m.mismatch).length;
                 return sum + successCount;
             }, 0);
+            const mismatch = this.targets.reduce((sum, t) => sum + (t.latest_models || []).filter(m => m.mismatch).length, 0);
 
             const activeTargets = this.targets.filter(t => t.enabled && t.last_total > 0);
             let rate = 0;
@@ -119,7 +228,7 @@ function dashboard() {
                 rate = Math.round(sumRate / activeTargets.length);
             }
 
-            return { targets: total, healthy, models, rate };
+            return { targets: total, healthy, mismatch, models, rate };
         },
 
         // Actions
@@ -134,7 +243,7 @@ function dashboard() {
             this.editingId = t.id;
             this._editOriginalUrl = t.base_url;
             this._editOriginalKey = t.api_key;
-            this.form = { ...t };
+            this.form = { ...t, api_keys_text: (t.api_keys || []).join('\n') };
             this.formError = '';
             this.modalOpen = true;
         },
@@ -143,8 +252,22 @@ function dashboard() {
             this.modalOpen = false;
         },
 
+        async revealedKey(t) {
+            if (!t.api_key_masked) return t.api_key;
+            try {
+                const res = await Utils.authFetch(`/api/v1/targets/${t.id}/api-key`);
+                if (res.ok) {
+                    const data = await res.json();
+                    return data.item?.api_key || t.api_key;
+                }
+            } catch (err) {
+                console.error('Failed to reveal key', err);
+            }
+            return t.api_key;
+        },
+
         async copyConfig(t) {
-            const text = `${t.base_url}\n${t.api_key}`;
+            const text = `${t.base_url}\n${await this.revealedKey(t)}`;
             try {
                 await navigator.clipboard.writeText(text);
             } catch (err) {
@@ -176,11 +299,12 @@ function dashboard() {
                     name: this.form.name,
                     base_url: this.form.base_url,
                     api_key: this.form.api_key,
+                    api_keys: (this.form.api_keys_text || '').split('\n').map(k => k.trim()).filter(Boolean),
                     source_url: this.form.source_url ?? null,
                     interval_min: this.form.interval_min,
                     timeout_s: this.form.timeout_s
                 };
-                let url = '/api/targets';
+                let url = '/api/v1/targets';
                 let method = 'POST';
 
                 if (this.editingId) {
@@ -188,11 +312,21 @@ function dashboard() {
                     method = 'PATCH';
                 }
 
-                const res = await Utils.authFetch(url, {
+                const send = (target) => Utils.authFetch(target, {
                     method,
                     headers: { 'Content-Type': 'application/json' },
                     body: JSON.stringify(payload)
                 });
+                let res = await send(url);
+
+                // Same base_url + api_key as an existing channel: ask before monitoring it twice.
+                if (res.status === 409 && !this.editingId) {
+                    const err = await res.json();
+                    if (!Array.isArray(err.duplicates)) throw new Error(err.detail || 'Failed');
+                    const names = err.duplicates.map(d => `#${d.id} ${d.name}`).join(', ');
+                    if (!confirm(`This URL and key are already monitored by ${names}. Create a duplicate channel anyway?`)) return;
+                    res = await send(`${url}?allow_duplicate=1`);
+                }
 
                 if (!res.ok) {
                     const err = await res.json();
@@ -222,12 +356,13 @@ function dashboard() {
             }
         },
 
-        async runTarget(id) {
+        async runTarget(id, mode) {
             const t = this.targets.find(x => x.id === id);
             if (t && t.running) return;
             if (t) t.running = true;
             try {
-                const res = await Utils.authFetch(`/api/targets/${id}/run`, { method: 'POST' });
+                const url = mode ? `/api/v1/targets/${id}/run?mode=${mode}` : `/api/v1/targets/${id}/run`;
+                const res = await Utils.authFetch(url, { method: 'POST' });
                 if (!res.ok) throw new Error('Unsuccessful');
                 // Poll immediately
                 setTimeout(() => this.loadData(), 1000);
@@ -237,10 +372,83 @@ function dashboard() {
             }
         },
 
+        async testModel(t, m) {
+            m.testing = true;
+            try {
+                const res = await Utils.authFetch(`/api/v1/targets/${t.id}/test`, {
+                    method: 'POST',
+                    headers: { 'Content-Type': 'application/json' },
+                    body: JSON.stringify({ model: m.model })
+                });
+                const data = await res.json().catch(() => ({}));
+                if (!res.ok) throw new Error(data.detail || 'Test failed');
+                const row = data.item;
+                const lines = [
+                    `${row.model} via ${row.endpoint || row.route}: ${row.success ? 'OK' : 'FAILED'} in ${Utils.fmtDuration(row.duration).text}`
+                ];
+                if (row.status_code) lines.push(`HTTP ${row.status_code}`);
+                if (row.error) lines.push(row.error);
+                if (row.content) lines.push('', row.content);
+                alert(lines.join('\n'));
+            } catch (e) {
+                alert(e.message);
+            } finally {
+                m.testing = false;
+            }
+        },
+
+        async toggleSnooze(t) {
+            const snoozedUntil = t.snoozed ? null : Date.now() / 1000 + 4 * 3600;
+            try {
+                const res = await Utils.authFetch(`/api/v1/targets/${t.id}`, {
+                    method: 'PATCH',
+                    headers: { 'Content-Type': 'application/json' },
+                    body: JSON.stringify({ snoozed_until: snoozedUntil })
+                });
+                if (!res.ok) throw new Error('Snooze failed');
+                await this.loadData();
+            } catch (e) {
+                console.error(e);
+            }
+        },
+
+        async cancelTarget(id) {
+            try {
+                const res = await Utils.authFetch(`/api/v1/targets/${id}/cancel`, { method: 'POST' });
+                if (!res.ok) throw new Error('Cancel failed');
+                setTimeout(() => this.loadData(), 1000);
+            } catch (e) {
+                console.error(e);
+            }
+        },
+
+        async cloneTarget(t) {
+            const name = prompt('Name for the cloned channel:', `${t.name} (copy)`);
+            if (name === null) return;
+            const apiKey = prompt('API key for the clone (leave empty to reuse the current key):', '');
+            if (apiKey === null) return;
+            const body = { name: name.trim() };
+            if (apiKey.trim()) body.api_key = apiKey.trim();
+            try {
+                const res = await Utils.authFetch(`/api/v1/targets/${t.id}/clone`, {
+                    method: 'POST',
+                    headers: { 'Content-Type': 'application/json' },
+                    body: JSON.stringify(body)
+                });
+                if (!res.ok) {
+                    const data = await res.json().catch(() => ({}));
+                    throw new Error(data.detail || 'Clone failed');
+                }
+                await this.loadData();
+            } catch (e) {
+                alert(e.message);
+            }
+        },
+
         async deleteTarget(id) {
             if (!confirm('Are you sure you want to delete this channel?')) return;
             try {
-                const res = await Utils.authFetch(`/api/targets/${id}`, { method: 'DELETE' });
+                const res = await Utils.authFetch(`/api/v1/targets/${id}`, { method: 'DELETE' });
                 if (!res.ok) throw new Error('Delete failed');
                 await this.loadData();
             } catch (e) {
@@ -250,7 +458,7 @@ function dashboard() {
 
         async toggleEnabled(t) {
             try {
-                await Utils.authFetch(`/api/targets/${t.id}`, {
+                await Utils.authFetch(`/api/v1/targets/${t.id}`, {
                     method: 'PATCH',
                     headers: { 'Content-Type': 'application/json' },
                     body: JSON.stringify({ enabled: !t.enabled })
@@ -371,7 +579,8 @@ function dashboard() {
             if (!target || !target.interval_min) return 'NEXT UPDATE --';
             if (!target.last_run_at) return 'NEXT UPDATE SOON';
             const now = Date.now() / 1000;
-            const eta = Number(target.last_run_at) + Number(target.interval_min) * 60 - now;
+            const intervalMin = target.retry_interval_min || target.interval_min;
+            const eta = Number(target.last_run_at) + Number(intervalMin) * 60 - now;
             if (!Number.isFinite(eta) || eta <= 0) return 'NEXT UPDATE SOON';
             const mm = Math.floor(eta / 60);
             const ss = Math.floor(eta % 60);
@@ -431,10 +640,25 @@ function dashboard() {
                 const [item] = this.targets.splice(fromIndex, 1);
                 // Insert at new position
                 this.targets.splice(toIndex, 0, item);
+                this.saveOrder();
             }
             this.dragSourceId = null;
         },
 
+        async saveOrder() {
+            try {
+                const res = await Utils.authFetch('/api/v1/targets/reorder', {
+                    method: 'PATCH',
+                    headers: { 'Content-Type': 'application/json' },
+                    body: JSON.stringify({ ids: this.targets.map(t => t.id) })
+                });
+                if (!res.ok) throw new Error('Reorder failed');
+            } catch (e) {
+                console.error(e);
+                await this.loadData();
+            }
+        },
+
         // Model Selection methods
         async openModelSelection(targetId) {
             const t = this.targets.find(x => x.id === targetId);
@@ -448,7 +672,7 @@ function dashboard() {
             this.modelModalOpen = true;
 
             try {
-                const res = await Utils.authFetch(`/api/targets/${targetId}/models`);
+                const res = await Utils.authFetch(`/api/v1/targets/${targetId}/models`);
                 if (!res.ok) {
                     const err = await res.json();
                     throw new Error(err.detail || 'Failed to load models');
@@ -517,7 +741,7 @@ function dashboard() {
             this.modelModalError = '';
             try {
                 const selectedModels = Array.from(this.modelChecked);
-                const res = await Utils.authFetch(`/api/targets/${this.modelSelectingId}/models`, {
+                const res = await Utils.authFetch(`/api/v1/targets/${this.modelSelectingId}/models`, {
                     method: 'PATCH',
                     headers: { 'Content-Type': 'application/json' },
                     body: JSON.stringify({ selected_models: selectedModels })
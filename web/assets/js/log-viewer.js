@@ -22,8 +22,8 @@ function logViewer() {
             this.loading = true;
             try {
                 const [tRes, lRes] = await Promise.all([
-                    Utils.authFetch(`/api/targets/${this.targetId}`),
-                    Utils.authFetch(`/api/targets/${this.targetId}/logs?limit=100&scope=latest`)
+                    Utils.authFetch(`/api/v1/targets/${this.targetId}`),
+                    Utils.authFetch(`/api/v1/targets/${this.targetId}/logs?limit=100&scope=latest`)
                 ]);
 
                 if (tRes.ok) {
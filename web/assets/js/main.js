@@ -62,6 +62,20 @@ const Utils = {
         return new EventSource(url);
     },
 
+    /**
+     * Create a WebSocket to a same-origin path with token support.
+     */
+    createWebSocket(path) {
+        const proto = window.location.protocol === 'https:' ? 'wss:' : 'ws:';
+        let url = `${proto}//${window.location.host}${path}`;
+        const token = this.getToken();
+        if (token) {
+            const sep = path.includes('?') ? '&' : '?';
+            url += `${sep}token=${encodeURIComponent(token)}`;
+        }
+        return new WebSocket(url);
+    },
+
     async copyToClipboard(text) {
         try {
             await navigator.clipboard.writeText(text ?? '');